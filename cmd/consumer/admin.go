@@ -0,0 +1,289 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/kafka"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/logging"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/quality"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/scaling"
+)
+
+// lagTimeout bounds the offset requests of /lag and /metrics
+const lagTimeout = 5 * time.Second
+
+// consumerStats counts handled messages; handlers may run on several workers
+type consumerStats struct {
+	processed   atomic.Int64
+	failed      atomic.Int64
+	skipped     atomic.Int64 // Replay chunks and messages behind a partition checkpoint
+	lastMessage atomic.Int64 // Unix nanoseconds of the last handled message, 0 before the first
+	running     atomic.Bool  // Set while Run is consuming
+	draining    atomic.Bool  // Set once Shutdown is called
+	rate        *scaling.RateMeter
+}
+
+// record counts a handled message
+func (s *consumerStats) record(counter *atomic.Int64) {
+	counter.Add(1)
+	s.lastMessage.Store(time.Now().UnixNano())
+	s.rate.Add(1)
+}
+
+// serveAdmin serves the health, stats, lag, scaling, metrics and reload endpoints on port
+// until the context is cancelled
+func (cs *ConsumerService) serveAdmin(ctx context.Context, port string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", cs.handleLiveness)
+	mux.HandleFunc("/readyz", cs.handleReadiness)
+	mux.HandleFunc("/stats", cs.handleStats)
+	mux.HandleFunc("/lag", cs.handleLag)
+	mux.HandleFunc("/scaling", cs.handleScaling)
+	mux.HandleFunc("/metrics", cs.handleMetrics)
+	mux.HandleFunc("/reload", cs.handleReload)
+
+	server := &http.Server{
+		Addr:         ":" + port,
+		Handler:      mux,
+		ReadTimeout:  15 * time.Second,
+		WriteTimeout: 15 * time.Second,
+		IdleTimeout:  60 * time.Second,
+	}
+	go func() {
+		logging.Info("Consumer admin server starting", "port", port)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logging.Error("Consumer admin server failed", "error", err)
+		}
+	}()
+
+	<-ctx.Done()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	server.Shutdown(shutdownCtx)
+}
+
+// handleLiveness reports that the process is running and serving requests
+func (cs *ConsumerService) handleLiveness(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":  "alive",
+		"service": "analytics-consumer",
+	})
+}
+
+// handleReadiness reports whether the consumer is consuming: 200 while it is, 503
+// before it starts and once it is shutting down
+func (cs *ConsumerService) handleReadiness(w http.ResponseWriter, r *http.Request) {
+	status, consumer := "healthy", "up"
+	switch {
+	case cs.stats.draining.Load():
+		status, consumer = "unhealthy", "shutting down"
+	case !cs.stats.running.Load():
+		status, consumer = "unhealthy", "not consuming"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if status != "healthy" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  status,
+		"service": "analytics-consumer",
+		"checks":  map[string]string{"consumer": consumer},
+	})
+}
+
+// handleStats returns the consumer's current analytics snapshot
+func (cs *ConsumerService) handleStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cs.analyticsService.GetSnapshot())
+}
+
+// handleLag returns the consumer group's lag per partition and in total
+func (cs *ConsumerService) handleLag(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), lagTimeout)
+	defer cancel()
+
+	lags, err := cs.consumer.Lag(ctx)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to fetch consumer lag: %v", err), http.StatusServiceUnavailable)
+		return
+	}
+	var total int64
+	for _, lag := range lags {
+		total += lag.Lag
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"total_lag":  total,
+		"partitions": lags,
+	})
+}
+
+// handleMetrics exposes the message counters, lag and core analytics counters in the
+// Prometheus text format
+func (cs *ConsumerService) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	fmt.Fprintln(w, "# HELP analytics_consumer_messages_total Kafka messages handled by the consumer, by result.")
+	fmt.Fprintln(w, "# TYPE analytics_consumer_messages_total counter")
+	fmt.Fprintf(w, "analytics_consumer_messages_total{result=\"processed\"} %d\n", cs.stats.processed.Load())
+	fmt.Fprintf(w, "analytics_consumer_messages_total{result=\"failed\"} %d\n", cs.stats.failed.Load())
+	fmt.Fprintf(w, "analytics_consumer_messages_total{result=\"skipped\"} %d\n", cs.stats.skipped.Load())
+
+	if last := cs.stats.lastMessage.Load(); last > 0 {
+		fmt.Fprintln(w, "# HELP analytics_consumer_last_message_timestamp_seconds When the consumer last handled a message.")
+		fmt.Fprintln(w, "# TYPE analytics_consumer_last_message_timestamp_seconds gauge")
+		fmt.Fprintf(w, "analytics_consumer_last_message_timestamp_seconds %.3f\n", float64(last)/float64(time.Second))
+	}
+
+	ready := 0
+	if cs.stats.running.Load() && !cs.stats.draining.Load() {
+		ready = 1
+	}
+	fmt.Fprintln(w, "# HELP analytics_consumer_ready Whether the consumer is consuming.")
+	fmt.Fprintln(w, "# TYPE analytics_consumer_ready gauge")
+	fmt.Fprintf(w, "analytics_consumer_ready %d\n", ready)
+
+	// Lag is left out of the scrape when the brokers can't be reached
+	ctx, cancel := context.WithTimeout(r.Context(), lagTimeout)
+	defer cancel()
+	var totalLag *int64
+	if lags, err := cs.consumer.Lag(ctx); err != nil {
+		logging.Debug("Failed to fetch consumer lag for metrics", "error", err)
+	} else {
+		totalLag = writeLagMetrics(w, lags)
+	}
+	scaling.WritePrometheus(w, "analytics_consumer_scaling", cs.scalingMetrics(totalLag))
+
+	snapshot := cs.analyticsService.GetSnapshot()
+	fmt.Fprintln(w, "# HELP analytics_events_total Events counted by the consumer's analytics.")
+	fmt.Fprintln(w, "# TYPE analytics_events_total counter")
+	fmt.Fprintf(w, "analytics_events_total %d\n", snapshot.TotalEvents)
+
+	types := make([]models.EventType, 0, len(snapshot.EventsByType))
+	for eventType := range snapshot.EventsByType {
+		types = append(types, eventType)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	fmt.Fprintln(w, "# HELP analytics_events_by_type_total Events counted by the consumer's analytics, by event type.")
+	fmt.Fprintln(w, "# TYPE analytics_events_by_type_total counter")
+	for _, eventType := range types {
+		fmt.Fprintf(w, "analytics_events_by_type_total{type=%q} %d\n", eventType, snapshot.EventsByType[eventType])
+	}
+
+	fmt.Fprintln(w, "# HELP analytics_unique_users Distinct users seen.")
+	fmt.Fprintln(w, "# TYPE analytics_unique_users gauge")
+	fmt.Fprintf(w, "analytics_unique_users %d\n", snapshot.UniqueUsers)
+	fmt.Fprintln(w, "# HELP analytics_active_sessions Sessions active within the session timeout.")
+	fmt.Fprintln(w, "# TYPE analytics_active_sessions gauge")
+	fmt.Fprintf(w, "analytics_active_sessions %d\n", snapshot.ActiveSessions)
+
+	writeSessionMetrics(w, snapshot.Sessions)
+
+	if snapshot.Quality != nil {
+		quality.WritePrometheus(w, "analytics_consumer_quality", *snapshot.Quality)
+	}
+	if snapshot.Latency != nil {
+		writeLatencyMetrics(w, *snapshot.Latency)
+	}
+
+	if cs.backpressure != nil {
+		signal := cs.backpressure.Current()
+		throttled := 0
+		if signal.Throttled {
+			throttled = 1
+		}
+		fmt.Fprintln(w, "# HELP analytics_consumer_queue_depth Fetched messages waiting for or being handled by a worker.")
+		fmt.Fprintln(w, "# TYPE analytics_consumer_queue_depth gauge")
+		fmt.Fprintf(w, "analytics_consumer_queue_depth %d\n", signal.QueueDepth)
+		fmt.Fprintln(w, "# HELP analytics_consumer_backpressure Whether the consumer is asking producers to throttle ingestion.")
+		fmt.Fprintln(w, "# TYPE analytics_consumer_backpressure gauge")
+		fmt.Fprintf(w, "analytics_consumer_backpressure %d\n", throttled)
+	}
+
+	if cs.deduplicator != nil {
+		stats := cs.deduplicator.Stats()
+		fmt.Fprintln(w, "# HELP analytics_consumer_duplicates_total Redelivered events dropped by deduplication.")
+		fmt.Fprintln(w, "# TYPE analytics_consumer_duplicates_total counter")
+		fmt.Fprintf(w, "analytics_consumer_duplicates_total %d\n", stats.Duplicates)
+	}
+	if cs.joiner != nil {
+		stats := cs.joiner.Stats()
+		fmt.Fprintln(w, "# HELP analytics_consumer_joins_total Stream join matches, by outcome.")
+		fmt.Fprintln(w, "# TYPE analytics_consumer_joins_total counter")
+		fmt.Fprintf(w, "analytics_consumer_joins_total{outcome=\"joined\"} %d\n", stats.Joined)
+		fmt.Fprintf(w, "analytics_consumer_joins_total{outcome=\"expired\"} %d\n", stats.Expired)
+		fmt.Fprintln(w, "# HELP analytics_consumer_joins_pending Events waiting for a stream join match.")
+		fmt.Fprintln(w, "# TYPE analytics_consumer_joins_pending gauge")
+		fmt.Fprintf(w, "analytics_consumer_joins_pending %d\n", stats.Pending)
+	}
+}
+
+// writeLagMetrics writes the lag of each partition and in total, returning the total
+func writeLagMetrics(w http.ResponseWriter, lags []kafka.PartitionLag) *int64 {
+	var total int64
+	fmt.Fprintln(w, "# HELP analytics_consumer_lag Messages the consumer group has not yet committed, by partition.")
+	fmt.Fprintln(w, "# TYPE analytics_consumer_lag gauge")
+	for _, lag := range lags {
+		total += lag.Lag
+		fmt.Fprintf(w, "analytics_consumer_lag{topic=%q,partition=\"%d\"} %d\n", lag.Topic, lag.Partition, lag.Lag)
+	}
+	fmt.Fprintln(w, "# HELP analytics_consumer_lag_total Messages the consumer group has not yet committed.")
+	fmt.Fprintln(w, "# TYPE analytics_consumer_lag_total gauge")
+	fmt.Fprintf(w, "analytics_consumer_lag_total %d\n", total)
+	return &total
+}
+
+// writeLatencyMetrics writes the latency of each pipeline stage as a Prometheus summary
+func writeLatencyMetrics(w http.ResponseWriter, latency models.LatencyStats) {
+	stages := []struct {
+		name  string
+		stats models.LatencyPercents
+	}{
+		{"ingest", latency.Ingest},
+		{"broker", latency.Broker},
+		{"end_to_end", latency.EndToEnd},
+	}
+	fmt.Fprintf(w, "# HELP analytics_pipeline_latency_seconds Time events take to reach each pipeline stage, with quantiles over the last %d minutes.\n", latency.WindowMinutes)
+	fmt.Fprintln(w, "# TYPE analytics_pipeline_latency_seconds summary")
+	for _, stage := range stages {
+		for _, quantile := range []struct {
+			q  string
+			ms float64
+		}{{"0.5", stage.stats.P50Ms}, {"0.9", stage.stats.P90Ms}, {"0.95", stage.stats.P95Ms}, {"0.99", stage.stats.P99Ms}} {
+			fmt.Fprintf(w, "analytics_pipeline_latency_seconds{stage=%q,quantile=%q} %g\n", stage.name, quantile.q, quantile.ms/1000)
+		}
+		fmt.Fprintf(w, "analytics_pipeline_latency_seconds_sum{stage=%q} %g\n", stage.name, stage.stats.SumMs/1000)
+		fmt.Fprintf(w, "analytics_pipeline_latency_seconds_count{stage=%q} %d\n", stage.name, stage.stats.Count)
+	}
+}
+
+// writeSessionMetrics writes the lengths of ended sessions as a Prometheus histogram
+func writeSessionMetrics(w http.ResponseWriter, sessions models.SessionStats) {
+	fmt.Fprintln(w, "# HELP analytics_session_duration_seconds Length of ended sessions.")
+	fmt.Fprintln(w, "# TYPE analytics_session_duration_seconds histogram")
+	var cumulative int64
+	for _, bucket := range sessions.DurationHistogram {
+		cumulative += bucket.Count
+		if bucket.MaxSeconds > 0 {
+			fmt.Fprintf(w, "analytics_session_duration_seconds_bucket{le=\"%g\"} %d\n", bucket.MaxSeconds, cumulative)
+		}
+	}
+	fmt.Fprintf(w, "analytics_session_duration_seconds_bucket{le=\"+Inf\"} %d\n", sessions.Sessions)
+	fmt.Fprintf(w, "analytics_session_duration_seconds_sum %g\n", sessions.AverageDurationSeconds*float64(sessions.Sessions))
+	fmt.Fprintf(w, "analytics_session_duration_seconds_count %d\n", sessions.Sessions)
+
+	fmt.Fprintln(w, "# HELP analytics_session_pages Average page views of ended sessions.")
+	fmt.Fprintln(w, "# TYPE analytics_session_pages gauge")
+	fmt.Fprintf(w, "analytics_session_pages %g\n", sessions.PagesPerSession)
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/analytics"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/kafka/kafkatest"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/pipeline"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/scaling"
+)
+
+func get(handler http.HandlerFunc, path string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	handler(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+	return recorder
+}
+
+func TestAdminReadiness(t *testing.T) {
+	consumer := kafkatest.NewConsumer("analytics-events")
+	cs := NewConsumerService(consumer, pipeline.NewEngine(analytics.NewService()), nil, nil, nil)
+
+	if recorder := get(cs.handleReadiness, "/readyz"); recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 before consuming, got %d", recorder.Code)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- cs.Run(ctx) }()
+	waitUntil(t, func() bool { return get(cs.handleReadiness, "/readyz").Code == http.StatusOK })
+
+	cs.Shutdown(ctx)
+	<-done
+	if recorder := get(cs.handleReadiness, "/readyz"); recorder.Code != http.StatusServiceUnavailable || !strings.Contains(recorder.Body.String(), "shutting down") {
+		t.Errorf("expected 503 once shut down, got %d: %s", recorder.Code, recorder.Body)
+	}
+	if recorder := get(cs.handleLiveness, "/healthz"); recorder.Code != http.StatusOK {
+		t.Errorf("expected liveness to stay 200, got %d", recorder.Code)
+	}
+}
+
+func TestAdminStatsLagAndMetrics(t *testing.T) {
+	consumer := kafkatest.NewConsumer("analytics-events")
+	cs := NewConsumerService(consumer, pipeline.NewEngine(analytics.NewService()), nil, nil, nil)
+	cs.latency = analytics.NewLatencyTracker(5 * time.Minute)
+	cs.analyticsService.SetLatencyTracker(cs.latency)
+	consumer.Publish(
+		&models.AnalyticsEvent{ID: "e1", Type: models.PageView, Timestamp: time.Now(), UserID: "u1", Path: "/"},
+		&models.AnalyticsEvent{ID: "r1", Type: models.SessionReplay, Timestamp: time.Now(), UserID: "u1"},
+	)
+
+	var lag struct {
+		TotalLag int64 `json:"total_lag"`
+	}
+	if err := json.NewDecoder(get(cs.handleLag, "/lag").Body).Decode(&lag); err != nil || lag.TotalLag != 2 {
+		t.Errorf("expected a lag of 2 before consuming, got %+v (%v)", lag, err)
+	}
+
+	consumeAll(t, cs)
+
+	var snapshot models.MetricsSnapshot
+	if err := json.NewDecoder(get(cs.handleStats, "/stats").Body).Decode(&snapshot); err != nil || snapshot.TotalEvents != 1 {
+		t.Errorf("expected the snapshot with 1 event, got %d (%v)", snapshot.TotalEvents, err)
+	}
+
+	metrics := get(cs.handleMetrics, "/metrics").Body.String()
+	for _, line := range []string{
+		`analytics_consumer_messages_total{result="processed"} 1`,
+		`analytics_consumer_messages_total{result="skipped"} 1`,
+		`analytics_consumer_lag{topic="analytics-events",partition="0"} 0`,
+		`analytics_events_by_type_total{type="page_view"} 1`,
+		`analytics_events_total 1`,
+		`analytics_session_duration_seconds_bucket{le="+Inf"} 0`,
+		`analytics_pipeline_latency_seconds_count{stage="end_to_end"} 1`,
+	} {
+		if !strings.Contains(metrics, line+"\n") {
+			t.Errorf("expected metrics to contain %q, got:\n%s", line, metrics)
+		}
+	}
+}
+
+func TestAdminScaling(t *testing.T) {
+	consumer := kafkatest.NewConsumer("analytics-events")
+	cs := NewConsumerService(consumer, pipeline.NewEngine(analytics.NewService()), nil, nil, nil)
+	cs.localState = []string{"dedupe"}
+	consumer.Publish(
+		&models.AnalyticsEvent{ID: "e1", Type: models.PageView, Timestamp: time.Now(), UserID: "u1"},
+		&models.AnalyticsEvent{ID: "e2", Type: models.Click, Timestamp: time.Now(), UserID: "u1"},
+	)
+
+	var before scaling.Metrics
+	if err := json.NewDecoder(get(cs.handleScaling, "/scaling").Body).Decode(&before); err != nil || before.ConsumerLag == nil || *before.ConsumerLag != 2 {
+		t.Fatalf("expected a lag of 2 before consuming, got %+v (%v)", before, err)
+	}
+	if before.Stateless == nil || *before.Stateless || before.LocalState[0] != "dedupe" {
+		t.Errorf("expected local dedupe state to be reported, got %+v", before)
+	}
+
+	consumeAll(t, cs)
+
+	var after scaling.Metrics
+	json.NewDecoder(get(cs.handleScaling, "/scaling").Body).Decode(&after)
+	if *after.ConsumerLag != 0 || after.EventsPerSecond <= 0 || after.WindowSeconds != 60 {
+		t.Errorf("expected the consumed events in the rate, got %+v", after)
+	}
+	metrics := get(cs.handleMetrics, "/metrics").Body.String()
+	if !strings.Contains(metrics, "analytics_consumer_scaling_consumer_lag 0\n") || !strings.Contains(metrics, "analytics_consumer_scaling_stateless 0\n") {
+		t.Errorf("expected scaling gauges in metrics, got:\n%s", metrics)
+	}
+}
+
+// waitUntil polls condition until it holds, failing the test after a few seconds
+func waitUntil(t *testing.T, condition func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); !condition(); time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for condition")
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/constants"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/backpressure"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/kafka"
+)
+
+// newBackpressureMonitor creates the monitor publishing this consumer's throttle state
+// to Redis
+func newBackpressureMonitor(ctx context.Context, consumer *kafka.Consumer) (*backpressure.Monitor, backpressure.Store, error) {
+	if constants.RedisURL == "" {
+		return nil, nil, fmt.Errorf("REDIS_URL is required")
+	}
+	store, err := backpressure.NewRedisStore(ctx, constants.RedisURL, time.Duration(constants.BackpressureSignalTTLSeconds)*time.Second)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "consumer"
+	}
+	instance := fmt.Sprintf("%s-%d", host, os.Getpid())
+
+	thresholds := backpressure.Thresholds{
+		MaxLag:        int64(constants.BackpressureMaxLag),
+		MaxQueueDepth: constants.BackpressureMaxQueueDepth,
+		RetryAfter:    time.Duration(constants.BackpressureRetryAfterSeconds) * time.Second,
+	}
+	return backpressure.NewMonitor(store, instance, thresholds, consumerLag(consumer), consumer.QueueDepth), store, nil
+}
+
+// consumerLag returns a function summing the consumer group's lag over its partitions
+func consumerLag(consumer *kafka.Consumer) func(ctx context.Context) (int64, error) {
+	return func(ctx context.Context) (int64, error) {
+		ctx, cancel := context.WithTimeout(ctx, lagTimeout)
+		defer cancel()
+		lags, err := consumer.Lag(ctx)
+		if err != nil {
+			return 0, err
+		}
+		var total int64
+		for _, lag := range lags {
+			total += lag.Lag
+		}
+		return total, nil
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/constants"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/bus"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/kafka"
+)
+
+// openMessageBus connects to the broker named by MESSAGE_BUS, or returns nil for Kafka,
+// which the consumer uses through package kafka
+func openMessageBus(ctx context.Context) (bus.MessageBus, error) {
+	if strings.EqualFold(constants.MessageBus, bus.KindKafka) {
+		return nil, nil
+	}
+	return bus.Open(ctx, bus.Config{
+		Kind:              constants.MessageBus,
+		NATSURL:           constants.NATSURL,
+		NATSStream:        constants.NATSStream,
+		NATSSubjectPrefix: constants.NATSSubjectPrefix,
+		RabbitMQURL:       constants.RabbitMQURL,
+		RabbitMQExchange:  constants.RabbitMQExchange,
+		RabbitMQPrefetch:  constants.RabbitMQPrefetch,
+	})
+}
+
+// newBusConsumer consumes the configured topic list or single topic through the bus.
+// Topic patterns need Kafka's topic discovery.
+func newBusConsumer(messageBus bus.MessageBus) (*bus.Consumer, error) {
+	if constants.KafkaTopicPattern != "" {
+		return nil, fmt.Errorf("KAFKA_TOPIC_PATTERN is only supported with Kafka, list the topics in KAFKA_TOPICS")
+	}
+	topics := constants.KafkaTopics
+	if len(topics) == 0 {
+		topics = []string{constants.KafkaTopic}
+	}
+
+	consumer := bus.NewConsumer(messageBus, topics, constants.ConsumerGroup)
+	if len(constants.ConsumerEventTypes) > 0 {
+		consumer.SetHeaderFilter(kafka.EventTypeFilter(constants.ConsumerEventTypes))
+	}
+	return consumer, nil
+}
+
+// newTopicProducer publishes to topic through the message bus, or Kafka without one
+func newTopicProducer(messageBus bus.MessageBus, topic string) kafka.EventProducer {
+	if messageBus != nil {
+		return bus.NewProducer(messageBus, topic, kafka.KeyByEventID)
+	}
+	return kafka.NewProducer([]string{constants.KafkaBrokers}, topic)
+}
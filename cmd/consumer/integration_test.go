@@ -0,0 +1,243 @@
+//go:build integration
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	kafkago "github.com/segmentio/kafka-go"
+	tckafka "github.com/testcontainers/testcontainers-go/modules/kafka"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/analytics"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/dedupe"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/kafka"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/pipeline"
+)
+
+// The integration suite runs the producer binary and the consumer service against a
+// Kafka broker in a container. It needs Docker and runs with:
+//
+//	go test -tags integration ./cmd/consumer/
+
+const kafkaImage = "confluentinc/confluent-local:7.5.0"
+
+// startKafka starts a single node Kafka cluster and returns its broker addresses
+func startKafka(t *testing.T) []string {
+	t.Helper()
+	ctx := context.Background()
+
+	container, err := tckafka.Run(ctx, kafkaImage, tckafka.WithClusterID("integration"))
+	if err != nil {
+		t.Fatalf("failed to start Kafka: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := container.Terminate(context.Background()); err != nil {
+			t.Logf("failed to stop Kafka: %v", err)
+		}
+	})
+
+	brokers, err := container.Brokers(ctx)
+	if err != nil {
+		t.Fatalf("failed to get broker addresses: %v", err)
+	}
+	return brokers
+}
+
+// createTopic creates a topic with the given number of partitions
+func createTopic(t *testing.T, broker, topic string, partitions int) {
+	t.Helper()
+	conn, err := kafkago.Dial("tcp", broker)
+	if err != nil {
+		t.Fatalf("failed to connect to Kafka: %v", err)
+	}
+	defer conn.Close()
+
+	controller, err := conn.Controller()
+	if err != nil {
+		t.Fatalf("failed to find the controller: %v", err)
+	}
+	controllerConn, err := kafkago.Dial("tcp", net.JoinHostPort(controller.Host, strconv.Itoa(controller.Port)))
+	if err != nil {
+		t.Fatalf("failed to connect to the controller: %v", err)
+	}
+	defer controllerConn.Close()
+
+	if err := controllerConn.CreateTopics(kafkago.TopicConfig{Topic: topic, NumPartitions: partitions, ReplicationFactor: 1}); err != nil {
+		t.Fatalf("failed to create topic %s: %v", topic, err)
+	}
+}
+
+// startProducer builds and runs the producer service against the broker, returning
+// its base URL once it reports ready
+func startProducer(t *testing.T, broker, topic string) string {
+	t.Helper()
+	dir := t.TempDir()
+
+	binary := filepath.Join(dir, "producer")
+	build := exec.Command("go", "build", "-o", binary, "../producer")
+	if output, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("failed to build the producer: %v\n%s", err, output)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to find a free port: %v", err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	cmd := exec.Command(binary)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"KAFKA_BROKERS="+broker,
+		"KAFKA_TOPIC="+topic,
+		"SERVER_PORT="+strconv.Itoa(port),
+		"HISTORY_STORE_DIR="+filepath.Join(dir, "history"),
+		"REPLAY_STORE_DIR="+filepath.Join(dir, "replay"),
+		"META_EVENTS_ENABLED=false",
+		"LOG_LEVEL=warn",
+	)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("failed to start the producer: %v", err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Signal(os.Interrupt)
+		cmd.Wait()
+	})
+
+	baseURL := fmt.Sprintf("http://127.0.0.1:%d", port)
+	waitFor(t, 30*time.Second, "producer to become ready", func() bool {
+		resp, err := http.Get(baseURL + "/readyz")
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return resp.StatusCode == http.StatusOK
+	})
+	return baseURL
+}
+
+// postEvents sends events to the producer's ingestion endpoint
+func postEvents(t *testing.T, baseURL string, events ...models.AnalyticsEvent) {
+	t.Helper()
+	for _, event := range events {
+		body, err := json.Marshal(event)
+		if err != nil {
+			t.Fatalf("failed to encode event: %v", err)
+		}
+		resp, err := http.Post(baseURL+"/event", "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("failed to post event %s: %v", event.ID, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusAccepted {
+			t.Fatalf("expected event %s accepted, got %d", event.ID, resp.StatusCode)
+		}
+	}
+}
+
+// runConsumer starts a consumer service in the group; stop drains it and waits for it to return
+func runConsumer(t *testing.T, broker, topic, group string) (cs *ConsumerService, stop func()) {
+	t.Helper()
+	consumer := kafka.NewConsumer([]string{broker}, topic, group)
+	deduplicator := dedupe.NewDeduplicator(dedupe.NewMemoryStore(1000, time.Hour))
+	cs = NewConsumerService(consumer, pipeline.NewEngine(analytics.NewService()), nil, deduplicator, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- cs.Run(ctx) }()
+
+	return cs, func() {
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer shutdownCancel()
+		if err := cs.Shutdown(shutdownCtx); err != nil {
+			t.Errorf("consumer shutdown failed: %v", err)
+		}
+		cancel()
+		if err := <-done; err != nil && err != context.Canceled {
+			t.Errorf("consumer failed: %v", err)
+		}
+	}
+}
+
+// waitFor polls condition until it holds or the timeout passes
+func waitFor(t *testing.T, timeout time.Duration, what string, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+}
+
+func TestEndToEnd(t *testing.T) {
+	brokers := startKafka(t)
+	broker := brokers[0]
+	topic := "analytics-events-integration"
+	group := "analytics-integration"
+	createTopic(t, broker, topic, 3)
+	baseURL := startProducer(t, broker, topic)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	first := []models.AnalyticsEvent{
+		{ID: "e1", Type: models.PageView, Timestamp: now, UserID: "u1", SessionID: "s1", URL: "https://example.com/", Path: "/", Referrer: "https://www.google.com/", Metadata: map[string]interface{}{"load_time": 420.0}},
+		{ID: "e2", Type: models.PageView, Timestamp: now, UserID: "u2", SessionID: "s2", URL: "https://example.com/pricing", Path: "/pricing"},
+		{ID: "e3", Type: models.Click, Timestamp: now, UserID: "u1", SessionID: "s1", URL: "https://example.com/", Path: "/", Metadata: map[string]interface{}{"element_id": "signup"}},
+		// A retry of e3 from the tracker is dropped by deduplication
+		{ID: "e3", Type: models.Click, Timestamp: now, UserID: "u1", SessionID: "s1", URL: "https://example.com/", Path: "/", Metadata: map[string]interface{}{"element_id": "signup"}},
+	}
+	postEvents(t, baseURL, first...)
+
+	cs, stop := runConsumer(t, broker, topic, group)
+	waitFor(t, 60*time.Second, "the first events to be consumed", func() bool {
+		return cs.analyticsService.GetSnapshot().TotalEvents >= 3
+	})
+	// Let any further deliveries arrive before asserting on the counts
+	time.Sleep(2 * time.Second)
+	stop()
+
+	snapshot := cs.analyticsService.GetSnapshot()
+	if snapshot.TotalEvents != 3 || snapshot.UniqueUsers != 2 {
+		t.Errorf("expected 3 events from 2 users, got %d from %d", snapshot.TotalEvents, snapshot.UniqueUsers)
+	}
+	if snapshot.EventsByType[models.PageView] != 2 || snapshot.EventsByType[models.Click] != 1 {
+		t.Errorf("unexpected events by type %v", snapshot.EventsByType)
+	}
+	if len(snapshot.TopPages) == 0 || snapshot.TopPages[0].Path != "/" {
+		t.Errorf("expected / as the top page, got %+v", snapshot.TopPages)
+	}
+	if snapshot.PerformanceMetrics.AverageLoadTime != 420 {
+		t.Errorf("expected the load time metadata to survive serialization, got %v", snapshot.PerformanceMetrics.AverageLoadTime)
+	}
+
+	// A new member of the group resumes after the committed offsets, so only the new
+	// event is delivered
+	postEvents(t, baseURL, models.AnalyticsEvent{ID: "e4", Type: models.PageView, Timestamp: now, UserID: "u3", SessionID: "s3", URL: "https://example.com/docs", Path: "/docs"})
+
+	resumed, stopResumed := runConsumer(t, broker, topic, group)
+	waitFor(t, 60*time.Second, "the new event to be consumed", func() bool {
+		return resumed.analyticsService.GetSnapshot().TotalEvents >= 1
+	})
+	time.Sleep(2 * time.Second)
+	stopResumed()
+
+	if snapshot := resumed.analyticsService.GetSnapshot(); snapshot.TotalEvents != 1 || snapshot.EventsByType[models.PageView] != 1 {
+		t.Errorf("expected only the uncommitted event redelivered, got %d events: %v", snapshot.TotalEvents, snapshot.EventsByType)
+	}
+}
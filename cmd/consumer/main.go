@@ -3,48 +3,236 @@ package main
 import (
 	"context"
 	"fmt"
-	"log"
 	"os"
 	"os/signal"
+	"regexp"
+	"slices"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/Hilina-t/go-kafka-analytics-pipeline/constants"
 	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/analytics"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/backpressure"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/bots"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/dedupe"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/features"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/forward"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/hll"
 	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/kafka"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/logging"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/meta"
 	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/pipeline"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/quality"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/reload"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/scaling"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/sharedstate"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/sinks"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/store"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/streamjoin"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/wal"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/utils"
 )
 
 // ConsumerService handles event processing and analytics
 type ConsumerService struct {
-	consumer         *kafka.Consumer
+	consumer         kafka.EventConsumer
+	handlers         *kafka.HandlerRegistry // Processing per event type; other types go to processMessage
+	engine           *pipeline.Engine
 	analyticsService *analytics.Service
+	metaEmitter      *meta.Emitter
+	deduplicator     *dedupe.Deduplicator
+	sinkPipeline     *sinks.Pipeline           // nil unless raw events are exported
+	checkpoints      *partitionCheckpoints     // nil unless partition assignments are tracked
+	joiner           *streamjoin.Joiner        // nil unless join rules are configured
+	reloader         *reload.Reloader          // Applies configuration changes on SIGHUP and /reload
+	backpressure     *backpressure.Monitor     // nil unless BACKPRESSURE_ENABLED is set
+	quality          *quality.Tracker          // Data quality of consumed events, nil when not tracked
+	latency          *analytics.LatencyTracker // Pipeline latency of consumed events, nil when not tracked
+	wal              *wal.Log                  // Counted events for recovery, nil unless WAL_DIR is set
+	localState       []string                  // State kept in process memory, reported to autoscalers
+	stats            consumerStats
 }
 
-// NewConsumerService creates a new consumer service
-func NewConsumerService(consumer *kafka.Consumer, analyticsService *analytics.Service) *ConsumerService {
-	return &ConsumerService{
+// NewConsumerService creates a new consumer service, registering deduplication and
+// the sinks with the engine
+func NewConsumerService(consumer kafka.EventConsumer, engine *pipeline.Engine, metaEmitter *meta.Emitter, deduplicator *dedupe.Deduplicator, sinkPipeline *sinks.Pipeline) *ConsumerService {
+	cs := &ConsumerService{
 		consumer:         consumer,
-		analyticsService: analyticsService,
+		engine:           engine,
+		analyticsService: engine.Service(),
+		metaEmitter:      metaEmitter,
+		deduplicator:     deduplicator,
+		sinkPipeline:     sinkPipeline,
+	}
+	cs.stats.rate = scaling.NewRateMeter(scaling.DefaultWindow)
+
+	// Replay chunks are stored by the replay writer and are not analytics events, and
+	// erasure tombstones delete the user's data instead of being counted
+	cs.handlers = kafka.NewHandlerRegistry(cs.processMessage)
+	cs.handlers.Register(models.SessionReplay, cs.skipMessage)
+	cs.handlers.Register(models.UserErasure, cs.processErasure)
+	cs.handlers.SetOperationalHook(metaEmitter.Emit)
+
+	// Drop redeliveries so at-least-once delivery doesn't inflate counters
+	if deduplicator != nil {
+		engine.RegisterProcessor(pipeline.ProcessorFunc(cs.dropDuplicate))
+	}
+	// Export processed events to the warehouse sinks
+	if sinkPipeline != nil {
+		engine.RegisterOutput(pipeline.ProcessorFunc(func(ctx context.Context, event *models.AnalyticsEvent) (bool, error) {
+			sinkPipeline.Add(ctx, event)
+			return true, nil
+		}))
+	}
+	return cs
+}
+
+// Run consumes and processes messages until ctx is cancelled or the service is shut down
+func (cs *ConsumerService) Run(ctx context.Context) error {
+	cs.stats.running.Store(true)
+	defer cs.stats.running.Store(false)
+	return cs.consumer.ConsumeMessages(ctx, cs.handlers.Dispatch)
+}
+
+// Shutdown stops consuming once in-flight messages are processed and committed
+func (cs *ConsumerService) Shutdown(ctx context.Context) error {
+	cs.stats.draining.Store(true)
+	return cs.consumer.Shutdown(ctx)
+}
+
+// dropDuplicate skips events whose ID has already been processed
+func (cs *ConsumerService) dropDuplicate(ctx context.Context, event *models.AnalyticsEvent) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+
+	if cs.deduplicator.IsDuplicate(ctx, event.ID) {
+		logging.Info("Skipping duplicate event", "event_id", event.ID, "event_type", event.Type)
+		return false, nil
+	}
+	return true, nil
+}
+
+// watchAlerts logs alerts as they fire or resolve and reports fired alerts as meta events
+func (cs *ConsumerService) watchAlerts(ctx context.Context) {
+	alerts, unsubscribe := cs.engine.SubscribeAlerts(64)
+	defer unsubscribe()
+
+	for {
+		select {
+		case alert := <-alerts:
+			if alert.Resolved {
+				logging.Info("Alert resolved", "alert", alert.Name, "severity", alert.Severity, "message", alert.Message)
+				continue
+			}
+			logging.Warn("Alert fired", "alert", alert.Name, "severity", alert.Severity, "message", alert.Message)
+			cs.metaEmitter.Emit(models.OperationAlertFired, map[string]interface{}{
+				"alert_name":    alert.Name,
+				"alert_type":    alert.Type,
+				"severity":      alert.Severity,
+				"threshold":     alert.Threshold,
+				"current_value": alert.CurrentValue,
+			})
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// processMessage aggregates and exports the events of types without a handler of
+// their own
+func (cs *ConsumerService) processMessage(msg *kafka.Message) error {
+	event := msg.Event
+	logger := logging.With("topic", msg.Topic, "event_id", event.ID, "event_type", event.Type)
+	logger.Debug("Processing event", "user_id", event.UserID, "url", event.URL)
+
+	// Skip redeliveries of messages counted before the partition was last revoked
+	if cs.checkpoints != nil && cs.checkpoints.seen(msg) {
+		logger.Info("Skipping message behind partition checkpoint", "partition", msg.Partition, "offset", msg.Offset)
+		cs.stats.record(&cs.stats.skipped)
+		return nil
+	}
+
+	// Check the event's data quality as it arrived, before deduplication drops repeats
+	if cs.quality != nil {
+		if problems := cs.quality.Observe(event, time.Now()); len(problems) > 0 {
+			logger.Debug("Event failed data quality checks", "problems", strings.Join(problems, ","))
+		}
+	}
+
+	// Deduplicate, aggregate and export the event
+	if err := cs.engine.Process(context.Background(), event); err != nil {
+		logger.Error("Failed to process analytics event", "error", err)
+		if cs.deduplicator != nil {
+			cs.deduplicator.Release(context.Background(), event.ID)
+		}
+		cs.stats.record(&cs.stats.failed)
+		return err
+	}
+	if cs.checkpoints != nil {
+		cs.checkpoints.record(msg)
+	}
+	if err := cs.logCounted(msg); err != nil {
+		logger.Warn("Failed to append event to the write-ahead log", "error", err)
+	}
+	if cs.latency != nil {
+		cs.latency.Observe(event.Timestamp, msg.Time, time.Now())
+	}
+	cs.stats.record(&cs.stats.processed)
+	return nil
+}
+
+// dropOnError acknowledges failed events of the given types without retrying them.
+// Types with a handler of their own, such as erasures, keep its error policy.
+func (cs *ConsumerService) dropOnError(eventTypes []string) {
+	for _, name := range eventTypes {
+		eventType := models.EventType(name)
+		if slices.Contains(cs.handlers.Registered(), eventType) {
+			logging.Warn("Ignoring CONSUMER_DROP_ON_ERROR entry with a dedicated handler", "event_type", name)
+			continue
+		}
+		cs.handlers.Register(eventType, cs.processMessage, kafka.WithErrorPolicy(kafka.ErrorDrop))
 	}
 }
 
-// processEvent handles incoming events from Kafka
-func (cs *ConsumerService) processEvent(event *models.AnalyticsEvent) error {
-	log.Printf("Processing %s event for user %s on %s", event.Type, event.UserID, event.URL)
+// skipMessage acknowledges messages the consumer doesn't process
+func (cs *ConsumerService) skipMessage(msg *kafka.Message) error {
+	cs.stats.record(&cs.stats.skipped)
+	return nil
+}
 
-	// Process the event through analytics service
-	if err := cs.analyticsService.ProcessEvent(event); err != nil {
-		log.Printf("Error processing analytics event: %v", err)
+// processErasure deletes the data of the user named by an erasure tombstone
+func (cs *ConsumerService) processErasure(msg *kafka.Message) error {
+	logger := logging.With("topic", msg.Topic, "event_id", msg.Event.ID, "event_type", msg.Event.Type)
+	if err := cs.eraseUser(logger, msg.Event.UserID); err != nil {
+		cs.stats.record(&cs.stats.failed)
 		return err
 	}
+	cs.stats.record(&cs.stats.processed)
+	return nil
+}
 
-	// Check for alerts
-	alerts := cs.analyticsService.CheckAlerts()
-	for _, alert := range alerts {
-		log.Printf("ALERT [%s]: %s", alert.Severity, alert.Message)
+// eraseUser removes a user's data from the analytics and from the sinks storing raw events
+func (cs *ConsumerService) eraseUser(logger logging.Logger, userID string) error {
+	result := cs.analyticsService.EraseUser(userID)
+	logger.Info("Erased user data", "recent_events", result.RecentEvents, "sessions", len(result.Sessions), "estimated", result.Estimated)
+
+	// Recovery must not bring the user's events back
+	if cs.wal != nil {
+		if err := cs.wal.DeleteUser(userID); err != nil {
+			return fmt.Errorf("failed to erase user from the write-ahead log: %w", err)
+		}
 	}
 
+	if cs.sinkPipeline == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := cs.sinkPipeline.DeleteUser(ctx, userID); err != nil {
+		return fmt.Errorf("failed to erase user from sinks: %w", err)
+	}
 	return nil
 }
 
@@ -57,6 +245,14 @@ func (cs *ConsumerService) printStats() {
 	fmt.Printf("Total Events: %d\n", snapshot.TotalEvents)
 	fmt.Printf("Unique Users: %d\n", snapshot.UniqueUsers)
 	fmt.Printf("Active Sessions: %d\n", snapshot.ActiveSessions)
+	if cs.deduplicator != nil {
+		stats := cs.deduplicator.Stats()
+		fmt.Printf("Duplicates Dropped: %d (of %d checked)\n", stats.Duplicates, stats.Checked)
+	}
+	if cs.joiner != nil {
+		stats := cs.joiner.Stats()
+		fmt.Printf("Conversion Paths: %d joined, %d pending, %d expired\n", stats.Joined, stats.Pending, stats.Expired)
+	}
 
 	fmt.Println("\nEvents by Type:")
 	for eventType, count := range snapshot.EventsByType {
@@ -87,6 +283,10 @@ func (cs *ConsumerService) printStats() {
 
 	fmt.Printf("\nPerformance Metrics:")
 	fmt.Printf("  Average Load Time: %.1fms\n", snapshot.PerformanceMetrics.AverageLoadTime)
+	fmt.Printf("  p75/p95/p99 Load Time: %.1fms / %.1fms / %.1fms\n",
+		snapshot.PerformanceMetrics.P75LoadTime,
+		snapshot.PerformanceMetrics.P95LoadTime,
+		snapshot.PerformanceMetrics.P99LoadTime)
 	fmt.Printf("  Fast Pages: %d, Slow Pages: %d\n",
 		snapshot.PerformanceMetrics.FastPagesCount,
 		snapshot.PerformanceMetrics.SlowPagesCount)
@@ -95,52 +295,314 @@ func (cs *ConsumerService) printStats() {
 }
 
 func main() {
+	// Structured logs at the configured level and format
+	logger, err := logging.New(os.Stderr, logging.Config{Level: constants.LogLevel, Format: constants.LogFormat})
+	if err != nil {
+		logging.Fatal("Invalid logging configuration", "error", err)
+	}
+	logging.SetDefault(logger.With("service", "consumer"))
 
-	log.Printf("Starting enhanced consumer with brokers: %s, topic: %s, group: %s",
-		constants.KafkaBrokers, constants.KafkaTopic, constants.ConsumerGroup)
+	logging.Info("Starting enhanced consumer", "brokers", constants.KafkaBrokers, "group", constants.ConsumerGroup)
+
+	if err := utils.ConfigFileError(); err != nil {
+		logging.Warn("Failed to load CONFIG_FILE, using the environment only", "error", err)
+	}
 
 	// Create analytics service
-	analyticsService := analytics.NewService()
-
-	// Add some default alert configurations
-	analyticsService.AddAlert(models.AlertConfig{
-		Name:          "High Load Time Alert",
-		Type:          "performance",
-		Metric:        "average_load_time",
-		Threshold:     5000, // 5 seconds
-		Operator:      "gt",
-		Enabled:       true,
-		WindowMinutes: 5,
+	analyticsService := analytics.NewServiceWithRetention(retentionConfig())
+	featureStates, err := features.Parse(constants.FeatureFlags)
+	if err != nil {
+		logging.Warn("Invalid FEATURE_FLAGS, using the defaults", "error", err)
+	}
+	featureFlags := features.New()
+	featureFlags.Set(featureStates)
+	analyticsService.SetFeatures(featureFlags)
+	qualityTracker := quality.NewTracker(qualityConfig())
+	analyticsService.SetQualityTracker(qualityTracker)
+	latencyTracker := analytics.NewLatencyTracker(time.Duration(constants.LatencyWindowMinutes) * time.Minute)
+	analyticsService.SetLatencyTracker(latencyTracker)
+	analyticsService.SetEventTime(analytics.EventTimeConfig{
+		MaxOutOfOrder:   time.Duration(constants.WatermarkDelaySeconds) * time.Second,
+		AllowedLateness: time.Duration(constants.AllowedLatenessHours) * time.Hour,
 	})
-
-	analyticsService.AddAlert(models.AlertConfig{
-		Name:          "Traffic Surge Alert",
-		Type:          "traffic",
-		Metric:        "total_events",
-		Threshold:     1000, // 1000 events
-		Operator:      "gt",
-		Enabled:       true,
-		WindowMinutes: 5,
+	botPolicy, err := bots.ResolvePolicy(constants.BotPolicy, constants.ExcludeBots)
+	if err != nil {
+		logging.Warn("Invalid BOT_POLICY", "using", botPolicy, "error", err)
+	}
+	botDetector, err := bots.NewDetector(bots.Config{UserAgentPatterns: constants.BotUserAgents, IPRanges: constants.BotIPRanges})
+	if err != nil {
+		logging.Warn("Invalid BOT_IP_RANGES, using the default detector", "error", err)
+		botDetector, _ = bots.NewDetector(bots.Config{})
+	}
+	analyticsService.SetBotPolicy(botPolicy, botDetector)
+	analyticsService.SetCampaignGoal(analytics.ParseGoal(constants.CampaignGoal))
+	channelRules, err := analytics.ParseChannelRules(constants.ChannelRules)
+	if err != nil {
+		logging.Warn("Invalid CHANNEL_RULES, using the built-in channels", "error", err)
+	}
+	analyticsService.SetChannelRules(channelRules)
+	analyticsService.SetCommerceCurrency(constants.CommerceCurrency)
+	analyticsService.SetUniqueCounting(hll.Config{
+		Threshold: constants.UniqueExactThreshold,
+		Precision: uint8(constants.UniqueHLLPrecision),
+	})
+	analyticsService.SetTopLimits(constants.TopPagesLimit, constants.TopSourcesLimit)
+	analyticsService.SetURLRules(analytics.URLRules{
+		Normalize:      constants.NormalizePageURLs,
+		KeepParams:     constants.PageURLKeepParams,
+		VariantParams:  constants.PageURLVariantParams,
+		AllowedDomains: constants.AllowedDomains,
 	})
 
-	// Create Kafka consumer
-	consumer := kafka.NewConsumer([]string{constants.KafkaBrokers}, constants.KafkaTopic, constants.ConsumerGroup)
-	defer consumer.Close()
+	// Register user-defined aggregation rules for custom event types
+	customRules, err := analytics.ParseCustomMetricRules(constants.CustomMetrics)
+	if err != nil {
+		logging.Fatal("Invalid CUSTOM_METRICS", "error", err)
+	}
+	for _, rule := range customRules {
+		analyticsService.RegisterCustomMetric(rule)
+	}
 
-	// Create consumer service
-	consumerService := NewConsumerService(consumer, analyticsService)
+	// Load alert configurations saved through the producer's /alerts/config API, or the defaults
+	alertStore, err := store.NewFileStore(constants.HistoryStoreDir)
+	if err != nil {
+		logging.Fatal("Failed to open alert config store", "error", err)
+	}
+	if err := analyticsService.LoadAlerts(context.Background(), alertStore); err != nil {
+		logging.Warn("Failed to load saved alert configs, using defaults", "error", err)
+		analyticsService.SetAlerts(analytics.DefaultAlerts())
+	}
+
+	// Track the conversion goals saved through the producer's /goals API, or those in GOALS
+	if err := analyticsService.LoadGoals(context.Background(), alertStore, configuredGoals()); err != nil {
+		logging.Warn("Failed to load saved goals", "error", err)
+	}
 
 	// Create context for graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	// Expire old sessions, hourly data and recent events
+	go analyticsService.RunCleanup(ctx)
+
+	// Connect to NATS or RabbitMQ when configured in place of Kafka
+	messageBus, err := openMessageBus(ctx)
+	if err != nil {
+		logging.Fatal("Failed to connect to the message bus", "bus", constants.MessageBus, "error", err)
+	}
+	if messageBus != nil {
+		defer messageBus.Close()
+	}
+
+	// Create the consumer; Kafka's adds partition-aware workers, lag and rebalance tracking
+	var consumer kafka.EventConsumer
+	var kafkaConsumer *kafka.Consumer
+	if messageBus != nil {
+		busConsumer, err := newBusConsumer(messageBus)
+		if err != nil {
+			logging.Fatal("Failed to create consumer", "error", err)
+		}
+		consumer = busConsumer
+		logging.Info("Consuming through the message bus", "bus", constants.MessageBus)
+	} else {
+		kafkaConsumer, err = newConsumer(ctx)
+		if err != nil {
+			logging.Fatal("Failed to create consumer", "error", err)
+		}
+		kafkaConsumer.SetWorkers(constants.ConsumerWorkers)
+		kafkaConsumer.SetRetryPolicy(kafka.RetryPolicy{
+			InitialBackoff: time.Duration(constants.ConsumerRetryBackoffMs) * time.Millisecond,
+			MaxBackoff:     time.Duration(constants.ConsumerRetryMaxBackoffMs) * time.Millisecond,
+			MaxRetries:     constants.ConsumerMaxFetchRetries,
+			FailFast:       constants.ConsumerFailFast,
+		})
+		if len(constants.ConsumerEventTypes) > 0 {
+			// Filter on the event-type header so other events are skipped without decoding
+			kafkaConsumer.SetHeaderFilter(kafka.EventTypeFilter(constants.ConsumerEventTypes))
+		}
+		consumer = kafkaConsumer
+	}
+	defer consumer.Close()
+	if len(constants.ConsumerEventTypes) > 0 {
+		logging.Info("Consuming only selected event types", "event_types", strings.Join(constants.ConsumerEventTypes, ","))
+	}
+	logging.Info("Consuming topics", "topics", strings.Join(consumer.Topics(), ","))
+
+	// Publish the consumer's own operational events to the meta topic
+	var metaEmitter *meta.Emitter
+	if constants.MetaEventsEnabled {
+		metaProducer := newTopicProducer(messageBus, constants.MetaTopic)
+		defer metaProducer.Close()
+
+		metaEmitter = meta.NewEmitter(metaProducer, "consumer")
+		go metaEmitter.Run(ctx)
+	}
+	if kafkaConsumer != nil {
+		// Count undecodable messages towards data quality as well
+		kafkaConsumer.SetOperationalHook(qualityHook(qualityTracker, metaEmitter.Emit))
+	}
+
+	// Replicas an autoscaler adds or removes must not hold state of their own
+	localState := consumerLocalState()
+	if constants.ConsumerStateExternalized && len(localState) > 0 {
+		logging.Fatal("CONSUMER_STATE_EXTERNALIZED is set but state is kept in memory", "local_state", strings.Join(localState, ","))
+	}
+
+	// Deduplicate events by ID, sharing state through Redis when configured
+	var deduplicator *dedupe.Deduplicator
+	if constants.DedupeEnabled {
+		ttl := time.Duration(constants.DedupeTTLSeconds) * time.Second
+		var store dedupe.Store = dedupe.NewMemoryStore(constants.DedupeCacheSize, ttl)
+		if constants.RedisURL != "" {
+			redisStore, err := dedupe.NewRedisStore(ctx, constants.RedisURL, ttl)
+			if err != nil {
+				logging.Fatal("Failed to create Redis dedupe store", "error", err)
+			}
+			store = redisStore
+		}
+		deduplicator = dedupe.NewDeduplicator(store)
+		defer deduplicator.Close()
+	}
+
+	// Count events together with the other consumer replicas
+	if constants.SharedAnalytics {
+		sharedState, err := sharedstate.NewRedisState(ctx, constants.RedisURL, sharedstate.Options{
+			MaxKeys: constants.SharedAnalyticsMaxPages,
+			TTL:     time.Duration(constants.SharedAnalyticsTTLHours) * time.Hour,
+		})
+		if err != nil {
+			logging.Fatal("Failed to connect to shared analytics state", "error", err)
+		}
+		defer sharedState.Close()
+		go sharedState.Run(ctx, time.Duration(constants.SharedAnalyticsFlushSeconds)*time.Second)
+		analyticsService.SetSharedState(sharedState, true)
+		logging.Info("Sharing analytics counters through Redis")
+	}
+
+	// Stream processed events and periodic snapshots to the configured warehouse sinks
+	var sinkPipeline *sinks.Pipeline
+	sinksDone := make(chan struct{})
+	if len(constants.Sinks) > 0 {
+		configured, err := newSinks(ctx)
+		if err != nil {
+			logging.Fatal("Failed to create sinks", "error", err)
+		}
+		sinkPipeline = sinks.NewPipeline(configured, constants.SinkBatchSize)
+		if constants.SinkSpoolDir != "" {
+			if err := sinkPipeline.SetSpool(constants.SinkSpoolDir, int64(constants.SinkSpoolMaxMB)<<20); err != nil {
+				logging.Fatal("Failed to open sink spool", "error", err)
+			}
+		}
+		logging.Info("Exporting to sinks", "sinks", strings.Join(constants.Sinks, ","))
+
+		go func() {
+			defer close(sinksDone)
+			sinkPipeline.Run(ctx,
+				time.Duration(constants.SinkFlushSeconds)*time.Second,
+				time.Duration(constants.SinkSnapshotSeconds)*time.Second,
+				analyticsService.GetSnapshot)
+		}()
+	} else {
+		close(sinksDone)
+	}
+
+	// Create consumer service
+	var eventPipeline *sinks.Pipeline
+	if constants.SinkEvents {
+		eventPipeline = sinkPipeline
+	}
+	consumerService := NewConsumerService(consumer, pipeline.NewEngine(analyticsService), metaEmitter, deduplicator, eventPipeline)
+	consumerService.dropOnError(constants.ConsumerDropOnError)
+	consumerService.localState = localState
+	consumerService.quality = qualityTracker
+	consumerService.latency = latencyTracker
+	go consumerService.watchAlerts(ctx)
+
+	// Evaluate alerts on a schedule so quiet periods are checked too
+	go analyticsService.RunAlerts(ctx, time.Duration(constants.AlertCheckSeconds)*time.Second)
+
+	// Apply configuration changes on SIGHUP
+	consumerService.reloader = newReloader(analyticsService, featureFlags, alertStore, alertStore)
+	go consumerService.reloader.Run(ctx)
+
+	// Join related events of a session into conversion path events on the join topic
+	if constants.JoinRules != "" {
+		rules, err := streamjoin.ParseRules(constants.JoinRules)
+		if err != nil {
+			logging.Fatal("Invalid JOIN_RULES", "error", err)
+		}
+		joinProducer := newTopicProducer(messageBus, constants.JoinTopic)
+		defer joinProducer.Close()
+
+		consumerService.joiner = streamjoin.NewJoiner(rules, joinProducer)
+		consumerService.joiner.SetMaxPending(constants.JoinMaxPending)
+		consumerService.engine.RegisterOutput(consumerService.joiner)
+		go consumerService.joiner.Run(ctx, time.Minute)
+		logging.Info("Joining events", "rules", len(rules), "topic", constants.JoinTopic)
+	}
+
+	// Track partition assignments so replicas can split the topic's partitions
+	if constants.ConsumerPartitionTracking {
+		if kafkaConsumer != nil {
+			consumerService.checkpoints = newPartitionCheckpoints(eventPipeline)
+			kafkaConsumer.SetRebalanceListener(consumerService.checkpoints)
+		} else {
+			logging.Warn("CONSUMER_PARTITION_TRACKING only applies to Kafka, ignoring it", "bus", constants.MessageBus)
+		}
+	}
+
+	// Rebuild the analytics from the events counted by previous runs, then log new ones
+	if constants.WALDir != "" {
+		retention := retentionConfig().HourlyWindow
+		eventLog, err := wal.Open(constants.WALDir, wal.Options{SegmentBytes: int64(constants.WALSegmentMB) << 20, Retention: retention})
+		if err != nil {
+			logging.Fatal("Failed to open the write-ahead log", "error", err)
+		}
+		defer eventLog.Close()
+		consumerService.wal = eventLog
+
+		started := time.Now()
+		recovered, err := consumerService.recoverFromWAL(ctx, started.Add(-retention), kafkaConsumer != nil)
+		if err != nil {
+			logging.Fatal("Failed to recover from the write-ahead log", "error", err)
+		}
+		logging.Info("Recovered analytics from the write-ahead log", "events", recovered, "took", time.Since(started))
+		go eventLog.Run(ctx, time.Duration(max(constants.WALSyncSeconds, 1))*time.Second)
+	}
+
+	// Ask producers to throttle ingestion while this consumer falls behind
+	if constants.BackpressureEnabled && kafkaConsumer == nil {
+		logging.Warn("BACKPRESSURE_ENABLED only applies to Kafka, ignoring it", "bus", constants.MessageBus)
+	} else if constants.BackpressureEnabled {
+		monitor, backpressureStore, err := newBackpressureMonitor(ctx, kafkaConsumer)
+		if err != nil {
+			logging.Fatal("Failed to set up back-pressure", "error", err)
+		}
+		defer backpressureStore.Close()
+		consumerService.backpressure = monitor
+		go monitor.Run(ctx, time.Duration(constants.BackpressureCheckSeconds)*time.Second)
+		logging.Info("Publishing back-pressure state", "max_lag", constants.BackpressureMaxLag, "max_queue_depth", constants.BackpressureMaxQueueDepth)
+	}
+
+	// Serve probes, stats, lag and metrics for orchestrators and operators
+	if constants.ConsumerAdminPort != "" {
+		go consumerService.serveAdmin(ctx, constants.ConsumerAdminPort)
+	}
+
 	// Handle graceful shutdown
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
 
 	go func() {
 		<-sigChan
-		log.Println("\nReceived shutdown signal, printing final stats...")
+		logging.Info("Received shutdown signal, draining in-flight messages")
+
+		drainCtx, drainCancel := context.WithTimeout(context.Background(), time.Duration(constants.ShutdownDrainSeconds)*time.Second)
+		if err := consumerService.Shutdown(drainCtx); err != nil {
+			logging.Warn("Consumer shutdown incomplete", "error", err)
+		}
+		drainCancel()
+
 		consumerService.printStats()
 		cancel()
 	}()
@@ -160,13 +622,117 @@ func main() {
 	}()
 
 	// Start consuming events
-	log.Println("Enhanced consumer started, waiting for events...")
-	log.Println("Real-time analytics processing enabled with alerts")
-	if err := consumer.ConsumeEvents(ctx, consumerService.processEvent); err != nil {
-		if err == context.Canceled {
-			log.Println("Consumer stopped gracefully")
-		} else {
-			log.Fatalf("Consumer error: %v", err)
+	logging.Info("Enhanced consumer started, waiting for events")
+	if err := consumerService.Run(ctx); err != nil && err != context.Canceled {
+		logging.Fatal("Consumer error", "error", err)
+	}
+
+	// Wait for the shutdown handler to finish draining and print final stats,
+	// then for the sinks to write what is still buffered
+	<-ctx.Done()
+	<-sinksDone
+	logging.Info("Consumer stopped gracefully")
+}
+
+// newConsumer creates a consumer for the configured topic pattern, topic list or single topic
+func newConsumer(ctx context.Context) (*kafka.Consumer, error) {
+	brokers := []string{constants.KafkaBrokers}
+
+	switch {
+	case constants.KafkaTopicPattern != "":
+		pattern, err := regexp.Compile(constants.KafkaTopicPattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid KAFKA_TOPIC_PATTERN: %w", err)
+		}
+		refresh := time.Duration(constants.TopicRefreshSeconds) * time.Second
+		return kafka.NewPatternConsumer(ctx, brokers, pattern, constants.ConsumerGroup, refresh)
+	case len(constants.KafkaTopics) > 0:
+		return kafka.NewMultiTopicConsumer(brokers, constants.KafkaTopics, constants.ConsumerGroup), nil
+	default:
+		return kafka.NewConsumer(brokers, constants.KafkaTopic, constants.ConsumerGroup), nil
+	}
+}
+
+// forwardConfig returns the delivery settings of a forwarding sink that sends the given
+// event types, or every type when empty
+func forwardConfig(eventTypes []string) forward.Config {
+	config := forward.DefaultConfig()
+	config.MaxAttempts = constants.ForwardMaxAttempts
+	config.Timeout = time.Duration(constants.ForwardTimeoutSeconds) * time.Second
+	config.QueueSize = constants.ForwardQueueSize
+	config.MaxRetryTime = time.Duration(constants.ForwardMaxRetrySeconds) * time.Second
+	for _, eventType := range eventTypes {
+		config.EventTypes = append(config.EventTypes, models.EventType(eventType))
+	}
+	return config
+}
+
+// newSinks creates the sinks named in SINKS
+func newSinks(ctx context.Context) ([]sinks.Sink, error) {
+	archiveConfig := sinks.ArchiveConfig{
+		Prefix:        constants.S3Prefix,
+		FileRows:      constants.ArchiveFileRows,
+		FlushInterval: time.Duration(constants.ArchiveFlushSeconds) * time.Second,
+		MaxAttempts:   constants.ArchiveMaxAttempts,
+	}
+
+	var configured []sinks.Sink
+	for _, name := range constants.Sinks {
+		var sink sinks.Sink
+		var err error
+
+		switch name {
+		case "clickhouse":
+			sink, err = sinks.NewClickHouseSink(ctx, constants.ClickHouseURL, constants.ClickHouseDatabase)
+		case "postgres":
+			if constants.PostgresDSN == "" {
+				err = fmt.Errorf("POSTGRES_DSN is required")
+			} else {
+				sink, err = sinks.NewPostgresSink(ctx, constants.PostgresDSN)
+			}
+		case "s3":
+			if constants.S3Bucket == "" {
+				err = fmt.Errorf("S3_BUCKET is required")
+			} else {
+				var store *sinks.S3Store
+				if store, err = sinks.NewS3Store(ctx, constants.S3Bucket); err == nil {
+					sink = sinks.NewArchiveSink(store, archiveConfig)
+				}
+			}
+		case "local":
+			var store *sinks.LocalStore
+			if store, err = sinks.NewLocalStore(constants.ArchiveDir); err == nil {
+				sink = sinks.NewArchiveSink(store, archiveConfig)
+			}
+		case "ga4":
+			var destination *forward.GA4
+			config := forward.GA4Config{Endpoint: constants.GA4Endpoint, MeasurementID: constants.GA4MeasurementID, APISecret: constants.GA4APISecret}
+			if destination, err = forward.NewGA4(config); err == nil {
+				sink = forward.New(destination, forwardConfig(constants.GA4EventTypes))
+			}
+		case "segment":
+			var destination *forward.Segment
+			config := forward.SegmentConfig{Endpoint: constants.SegmentEndpoint, WriteKey: constants.SegmentWriteKey}
+			if destination, err = forward.NewSegment(config); err == nil {
+				sink = forward.New(destination, forwardConfig(constants.SegmentEventTypes))
+			}
+		case "amplitude":
+			var destination *forward.Amplitude
+			config := forward.AmplitudeConfig{Endpoint: constants.AmplitudeEndpoint, APIKey: constants.AmplitudeAPIKey}
+			if destination, err = forward.NewAmplitude(config); err == nil {
+				sink = forward.New(destination, forwardConfig(constants.AmplitudeEventTypes))
+			}
+		default:
+			err = fmt.Errorf("unknown sink")
+		}
+
+		if err != nil {
+			for _, s := range configured {
+				s.Close()
+			}
+			return nil, fmt.Errorf("%s: %w", name, err)
 		}
+		configured = append(configured, sink)
 	}
+	return configured, nil
 }
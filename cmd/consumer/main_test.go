@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/analytics"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/dedupe"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/kafka/kafkatest"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/pipeline"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/wal"
+)
+
+// consumeAll runs the service until every published message has been processed
+func consumeAll(t *testing.T, cs *ConsumerService) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- cs.Run(ctx) }()
+	if err := cs.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+}
+
+func TestConsumerServiceProcessesMessages(t *testing.T) {
+	consumer := kafkatest.NewConsumer("analytics-events")
+	deduplicator := dedupe.NewDeduplicator(dedupe.NewMemoryStore(100, time.Hour))
+	cs := NewConsumerService(consumer, pipeline.NewEngine(analytics.NewService()), nil, deduplicator, nil)
+
+	now := time.Now()
+	consumer.Publish(
+		&models.AnalyticsEvent{ID: "e1", Type: models.PageView, Timestamp: now, UserID: "u1", Path: "/"},
+		&models.AnalyticsEvent{ID: "e2", Type: models.Click, Timestamp: now, UserID: "u2", Path: "/"},
+		// Redelivered after a rebalance
+		&models.AnalyticsEvent{ID: "e1", Type: models.PageView, Timestamp: now, UserID: "u1", Path: "/"},
+	)
+	consumeAll(t, cs)
+
+	for _, result := range consumer.Results() {
+		if result.Err != nil {
+			t.Errorf("message %d failed: %v", result.Message.Offset, result.Err)
+		}
+	}
+	snapshot := cs.analyticsService.GetSnapshot()
+	if snapshot.TotalEvents != 2 || snapshot.UniqueUsers != 2 {
+		t.Errorf("expected 2 events from 2 users, got %d from %d", snapshot.TotalEvents, snapshot.UniqueUsers)
+	}
+	if stats := deduplicator.Stats(); stats.Duplicates != 1 {
+		t.Errorf("expected the redelivery dropped, got %+v", stats)
+	}
+}
+
+func TestConsumerServiceErasesUsers(t *testing.T) {
+	consumer := kafkatest.NewConsumer("analytics-events")
+	cs := NewConsumerService(consumer, pipeline.NewEngine(analytics.NewService()), nil, nil, nil)
+
+	consumer.Publish(
+		&models.AnalyticsEvent{ID: "e1", Type: models.PageView, Timestamp: time.Now(), UserID: "u1", SessionID: "s1", Path: "/"},
+		models.NewUserErasure("t1", "u1"),
+		&models.AnalyticsEvent{ID: "r1", Type: models.SessionReplay, Timestamp: time.Now(), UserID: "u2"},
+	)
+	consumeAll(t, cs)
+
+	if results := consumer.Results(); len(results) != 3 {
+		t.Fatalf("expected 3 handled messages, got %d", len(results))
+	}
+	// Nothing of u1 is left for a second erasure to remove
+	if result := cs.analyticsService.EraseUser("u1"); result.RecentEvents != 0 || len(result.Sessions) != 0 {
+		t.Errorf("expected u1's data erased by the tombstone, got %+v", result)
+	}
+	if snapshot := cs.analyticsService.GetSnapshot(); snapshot.EventsByType[models.SessionReplay] != 0 || snapshot.EventsByType[models.UserErasure] != 0 {
+		t.Errorf("expected tombstones and replay chunks not to be counted, got %v", snapshot.EventsByType)
+	}
+}
+
+func TestConsumerServiceRecoversFromWAL(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	events := []*models.AnalyticsEvent{
+		{ID: "e1", Type: models.PageView, Timestamp: now, UserID: "u1", Path: "/"},
+		{ID: "e2", Type: models.Click, Timestamp: now, UserID: "u2", Path: "/"},
+		{ID: "e3", Type: models.PageView, Timestamp: now, UserID: "u2", Path: "/pricing"},
+	}
+
+	// runConsumer counts the published events after recovering the previous runs' events
+	runConsumer := func(events ...*models.AnalyticsEvent) *ConsumerService {
+		t.Helper()
+		eventLog, err := wal.Open(dir, wal.Options{})
+		if err != nil {
+			t.Fatalf("Open failed: %v", err)
+		}
+		defer eventLog.Close()
+
+		consumer := kafkatest.NewConsumer("analytics-events")
+		cs := NewConsumerService(consumer, pipeline.NewEngine(analytics.NewService()), nil, nil, nil)
+		cs.wal = eventLog
+		if _, err := cs.recoverFromWAL(context.Background(), now.Add(-time.Hour), true); err != nil {
+			t.Fatalf("recoverFromWAL failed: %v", err)
+		}
+		consumer.Publish(events...)
+		consumeAll(t, cs)
+		return cs
+	}
+
+	runConsumer(events[0], events[1])
+
+	// The restarted consumer is redelivered the messages after the last commit
+	cs := runConsumer(events...)
+	snapshot := cs.analyticsService.GetSnapshot()
+	if snapshot.TotalEvents != 3 || snapshot.UniqueUsers != 2 {
+		t.Errorf("expected e1 and e2 recovered and e3 counted, got %d events from %d users", snapshot.TotalEvents, snapshot.UniqueUsers)
+	}
+	if skipped := cs.stats.skipped.Load(); skipped != 2 {
+		t.Errorf("expected the 2 logged redeliveries skipped, got %d", skipped)
+	}
+}
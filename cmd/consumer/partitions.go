@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/kafka"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/logging"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/sinks"
+)
+
+// partitionID identifies a topic partition
+type partitionID struct {
+	topic     string
+	partition int
+}
+
+// partitionCheckpoints tracks the last offset this consumer counted in each partition.
+// When a partition comes back after a commit was lost, messages up to the checkpoint
+// are skipped instead of being counted twice. Redeliveries to other replicas are
+// caught by event ID deduplication shared through Redis.
+type partitionCheckpoints struct {
+	processed map[partitionID]int64
+	sinks     *sinks.Pipeline // flushed on revocation; nil when not exporting
+	mu        sync.Mutex
+}
+
+// newPartitionCheckpoints creates an empty checkpoint set
+func newPartitionCheckpoints(sinkPipeline *sinks.Pipeline) *partitionCheckpoints {
+	return &partitionCheckpoints{
+		processed: make(map[partitionID]int64),
+		sinks:     sinkPipeline,
+	}
+}
+
+// PartitionsAssigned keeps checkpoints still ahead of the committed offset and drops
+// those another member has moved past
+func (p *partitionCheckpoints) PartitionsAssigned(ctx context.Context, partitions []kafka.TopicPartition) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, tp := range partitions {
+		id := partitionID{topic: tp.Topic, partition: tp.Partition}
+		if last, ok := p.processed[id]; ok && tp.Offset > last {
+			delete(p.processed, id)
+		} else if ok {
+			logging.Info("Resuming partition behind local checkpoint", "topic", tp.Topic, "partition", tp.Partition,
+				"committed", tp.Offset, "checkpoint", last)
+		}
+	}
+}
+
+// PartitionsRevoked writes buffered sink events so the next owner starts from a
+// consistent export
+func (p *partitionCheckpoints) PartitionsRevoked(ctx context.Context, partitions []kafka.TopicPartition) {
+	if p.sinks != nil {
+		flushCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		defer cancel()
+		p.sinks.Flush(flushCtx)
+	}
+}
+
+// seen reports whether the message was already counted by this consumer
+func (p *partitionCheckpoints) seen(msg *kafka.Message) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	last, ok := p.processed[partitionID{topic: msg.Topic, partition: msg.Partition}]
+	return ok && msg.Offset <= last
+}
+
+// record advances the partition's checkpoint to the message
+func (p *partitionCheckpoints) record(msg *kafka.Message) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	id := partitionID{topic: msg.Topic, partition: msg.Partition}
+	if last, ok := p.processed[id]; !ok || msg.Offset > last {
+		p.processed[id] = msg.Offset
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/constants"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/logging"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/quality"
+)
+
+// qualityConfig returns the data quality checks from the environment
+func qualityConfig() quality.Config {
+	config := quality.DefaultConfig()
+	config.RequiredFields = constants.QualityRequiredFields
+	config.MaxFutureSkew = time.Duration(constants.QualityMaxFutureSeconds) * time.Second
+	config.MaxAge = time.Duration(constants.QualityMaxAgeHours) * time.Hour
+	config.Window = time.Duration(constants.QualityWindowMinutes) * time.Minute
+	config.DuplicateIDs = constants.QualityDuplicateCacheSize
+	if err := quality.ValidateConfig(config); err != nil {
+		logging.Warn("Invalid QUALITY_REQUIRED_FIELDS, ignoring unknown fields", "error", err)
+	}
+	return config
+}
+
+// qualityHook counts messages the Kafka consumer could not decode as malformed events
+// before passing every operational event on to next
+func qualityHook(tracker *quality.Tracker, next models.OperationalHook) models.OperationalHook {
+	return func(operation string, details map[string]interface{}) {
+		if operation == models.OperationDecodeError {
+			tracker.Malformed(time.Now())
+		}
+		next(operation, details)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/constants"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/analytics"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/features"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/logging"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/reload"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/store"
+)
+
+// retentionConfig returns the configured in-memory retention
+func retentionConfig() analytics.RetentionConfig {
+	return analytics.RetentionConfig{
+		RecentEvents:    constants.RecentEventsLimit,
+		EventTTL:        time.Duration(constants.EventTTLMinutes) * time.Minute,
+		HourlyWindow:    time.Duration(constants.HourlyRetentionHours) * time.Hour,
+		SessionTimeout:  time.Duration(constants.SessionTimeoutMinutes) * time.Minute,
+		CleanupInterval: time.Duration(constants.AnalyticsCleanupSeconds) * time.Second,
+	}
+}
+
+// configuredGoals returns the conversion goals set in GOALS
+func configuredGoals() []models.ConversionGoal {
+	goals, err := analytics.ParseGoals(constants.Goals)
+	if err != nil {
+		logging.Warn("Invalid GOALS, no goals configured", "error", err)
+	}
+	return goals
+}
+
+// newReloader registers the settings the consumer applies without a restart: feature
+// flags, retention, and the alert rules and conversion goals saved by the producer
+func newReloader(service *analytics.Service, flags *features.Flags, alertStore store.AlertConfigStore, goalStore store.GoalStore) *reload.Reloader {
+	r := reload.New(constants.ConfigFile, constants.Reload)
+	r.Register(reload.Step{
+		Name: "features",
+		Keys: []string{"FEATURE_FLAGS"},
+		Apply: func(context.Context) error {
+			states, err := features.Parse(constants.FeatureFlags)
+			if err != nil {
+				return err
+			}
+			flags.Set(states)
+			return nil
+		},
+	})
+	r.Register(reload.Step{
+		Name: "retention",
+		Keys: []string{"RECENT_EVENTS_LIMIT", "EVENT_TTL_MINUTES", "HOURLY_RETENTION_HOURS", "SESSION_TIMEOUT_MINUTES"},
+		Apply: func(context.Context) error {
+			service.SetRetention(retentionConfig())
+			return nil
+		},
+	})
+	r.Register(reload.Step{
+		Name: "alerts",
+		Apply: func(ctx context.Context) error {
+			return service.LoadAlerts(ctx, alertStore)
+		},
+	})
+	r.Register(reload.Step{
+		Name: "goals",
+		Keys: []string{"GOALS"},
+		Apply: func(ctx context.Context) error {
+			goals, err := analytics.ParseGoals(constants.Goals)
+			if err != nil {
+				return err
+			}
+			return service.LoadGoals(ctx, goalStore, goals)
+		},
+	})
+	return r
+}
+
+// handleReload re-reads CONFIG_FILE and applies the settings that can change without a
+// restart, like SIGHUP
+func (cs *ConsumerService) handleReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	result := cs.reloader.Reload(r.Context())
+	status := http.StatusOK
+	if len(result.Errors) > 0 {
+		status = http.StatusUnprocessableEntity
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/constants"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/logging"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/scaling"
+)
+
+// consumerLocalState names the state this configuration keeps in process memory, which
+// replicas added or removed by an autoscaler would not share
+func consumerLocalState() []string {
+	var local []string
+	if !constants.SharedAnalytics {
+		local = append(local, "analytics") // Each replica counts only its own events
+	}
+	if constants.DedupeEnabled && constants.RedisURL == "" {
+		local = append(local, "dedupe") // Redeliveries to another replica are counted again
+	}
+	if constants.JoinRules != "" {
+		local = append(local, "stream_joins") // Pending joins are lost when partitions move
+	}
+	return local
+}
+
+// scalingMetrics returns the consumer's load for autoscalers; lag is nil when it
+// couldn't be measured
+func (cs *ConsumerService) scalingMetrics(lag *int64) scaling.Metrics {
+	metrics := scaling.NewMetrics("consumer", cs.stats.rate).WithState(cs.localState)
+	metrics.ConsumerLag = lag
+	if queue, ok := cs.consumer.(interface{ QueueDepth() int }); ok {
+		depth := queue.QueueDepth()
+		metrics.QueueDepth = &depth
+	}
+	return metrics
+}
+
+// totalLag returns the consumer group's lag summed over its partitions, or nil when the
+// brokers can't be reached
+func (cs *ConsumerService) totalLag(ctx context.Context) *int64 {
+	ctx, cancel := context.WithTimeout(ctx, lagTimeout)
+	defer cancel()
+	lags, err := cs.consumer.Lag(ctx)
+	if err != nil {
+		logging.Debug("Failed to fetch consumer lag for scaling", "error", err)
+		return nil
+	}
+	var total int64
+	for _, lag := range lags {
+		total += lag.Lag
+	}
+	return &total
+}
+
+// handleScaling returns the consumer's throughput, lag, queue depth and statelessness in
+// the shape KEDA's metrics-api scaler reads
+func (cs *ConsumerService) handleScaling(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(cs.scalingMetrics(cs.totalLag(r.Context())))
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/kafka"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/wal"
+)
+
+// logCounted appends a counted event to the write-ahead log, if one is kept. A failed
+// append only weakens recovery, so the event is still acknowledged.
+func (cs *ConsumerService) logCounted(msg *kafka.Message) error {
+	if cs.wal == nil {
+		return nil
+	}
+	return cs.wal.Append(wal.Record{Topic: msg.Topic, Partition: msg.Partition, Offset: msg.Offset, Event: *msg.Event})
+}
+
+// recoverFromWAL counts again the events previous runs logged since the given time. The
+// messages after the group's last commit are redelivered, so they are skipped by offset
+// when trackOffsets is set (Kafka), and by event ID when deduplicating.
+func (cs *ConsumerService) recoverFromWAL(ctx context.Context, since time.Time, trackOffsets bool) (int, error) {
+	if trackOffsets && cs.checkpoints == nil {
+		cs.checkpoints = newPartitionCheckpoints(nil)
+	}
+
+	// Redeliveries dropped by deduplication were logged as well, but count once
+	counted := make(map[string]bool)
+	recovered := 0
+	_, err := cs.wal.Replay(ctx, since, func(record wal.Record) error {
+		event := record.Event
+		if trackOffsets {
+			cs.checkpoints.record(&kafka.Message{Topic: record.Topic, Partition: record.Partition, Offset: record.Offset})
+		}
+		if counted[event.ID] {
+			return nil
+		}
+		counted[event.ID] = true
+
+		cs.analyticsService.RecoverEvent(&event)
+		if cs.deduplicator != nil {
+			checkCtx, cancel := context.WithTimeout(ctx, time.Second)
+			cs.deduplicator.IsDuplicate(checkCtx, event.ID) // Marks the ID as seen
+			cancel()
+		}
+		recovered++
+		return nil
+	})
+	return recovered, err
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+	"github.com/google/uuid"
+)
+
+// Realistic values for the fields the analytics service breaks down by
+var (
+	userAgents = []string{
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+		"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.1 Safari/605.1.15",
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:121.0) Gecko/20100101 Firefox/121.0",
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 17_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.1 Mobile/15E148 Safari/604.1",
+		"Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Mobile Safari/537.36",
+		"Mozilla/5.0 (iPad; CPU OS 17_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.1 Mobile/15E148 Safari/604.1",
+	}
+	referrers = []string{"", "", "https://www.google.com/", "https://twitter.com/", "https://news.ycombinator.com/", "https://github.com/"}
+	elements  = []string{"signup-button", "nav-pricing", "buy-now", "read-more", "footer-contact"}
+)
+
+// eventMix assigns each event type a relative weight
+type eventMix struct {
+	types   []models.EventType
+	weights []int
+	total   int
+}
+
+// parseMix parses weights such as "page_view=70,click=25,session=5"
+func parseMix(spec string) (eventMix, error) {
+	var mix eventMix
+	for _, part := range strings.Split(spec, ",") {
+		name, weight, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return mix, fmt.Errorf("invalid mix entry %q, expected type=weight", part)
+		}
+		w, err := strconv.Atoi(weight)
+		if err != nil || w < 0 {
+			return mix, fmt.Errorf("invalid weight for %s: %q", name, weight)
+		}
+		mix.types = append(mix.types, models.EventType(name))
+		mix.weights = append(mix.weights, w)
+		mix.total += w
+	}
+	if mix.total == 0 {
+		return mix, fmt.Errorf("event mix must have a positive weight")
+	}
+	return mix, nil
+}
+
+func (m eventMix) pick(rng *rand.Rand) models.EventType {
+	n := rng.Intn(m.total)
+	for i, w := range m.weights {
+		if n < w {
+			return m.types[i]
+		}
+		n -= w
+	}
+	return m.types[len(m.types)-1]
+}
+
+// visitor is a simulated user with a stable browser and session
+type visitor struct {
+	id        string
+	sessionID string
+	userAgent string
+	ip        string
+}
+
+// generator produces synthetic events. It is not safe for concurrent use.
+type generator struct {
+	rng      *rand.Rand
+	site     string
+	visitors []visitor
+	paths    []string
+	pageDist *rand.Zipf // nil picks pages uniformly
+	mix      eventMix
+}
+
+// newGenerator creates a generator with a pool of users visiting pages paths. With skew
+// above 1, page popularity follows a Zipf distribution, so a few pages get most views.
+func newGenerator(seed int64, site string, users, pages int, skew float64, mix eventMix) *generator {
+	rng := rand.New(rand.NewSource(seed))
+
+	g := &generator{rng: rng, site: site, mix: mix}
+	for i := 0; i < users; i++ {
+		g.visitors = append(g.visitors, visitor{
+			id:        fmt.Sprintf("loadgen-user-%d", i),
+			sessionID: uuid.NewString(),
+			userAgent: userAgents[rng.Intn(len(userAgents))],
+			ip:        fmt.Sprintf("203.0.113.%d", rng.Intn(254)+1),
+		})
+	}
+
+	g.paths = append(g.paths, "/")
+	for i := 1; i < pages; i++ {
+		g.paths = append(g.paths, fmt.Sprintf("/page/%d", i))
+	}
+	if skew > 1 && pages > 1 {
+		g.pageDist = rand.NewZipf(rng, skew, 1, uint64(pages-1))
+	}
+	return g
+}
+
+// next returns a new event stamped with the current time
+func (g *generator) next() models.AnalyticsEvent {
+	v := &g.visitors[g.rng.Intn(len(g.visitors))]
+
+	// Occasionally start a new session so session metrics move
+	if g.rng.Intn(50) == 0 {
+		v.sessionID = uuid.NewString()
+	}
+
+	path := g.paths[g.rng.Intn(len(g.paths))]
+	if g.pageDist != nil {
+		path = g.paths[g.pageDist.Uint64()]
+	}
+
+	event := models.AnalyticsEvent{
+		ID:        uuid.NewString(),
+		Type:      g.mix.pick(g.rng),
+		Timestamp: time.Now().UTC(),
+		UserID:    v.id,
+		SessionID: v.sessionID,
+		URL:       "https://" + g.site + path,
+		Path:      path,
+		Referrer:  referrers[g.rng.Intn(len(referrers))],
+		UserAgent: v.userAgent,
+		IPAddress: v.ip,
+		Metadata:  map[string]interface{}{"source": "loadgen"},
+	}
+
+	switch event.Type {
+	case models.PageView:
+		event.Metadata["load_time"] = float64(200 + g.rng.Intn(1800))
+	case models.Click:
+		event.Metadata["element_id"] = elements[g.rng.Intn(len(elements))]
+	case models.Session:
+		event.Metadata["duration"] = float64(10 + g.rng.Intn(600))
+	}
+	return event
+}
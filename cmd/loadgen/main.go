@@ -0,0 +1,345 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/constants"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/kafka"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+// options are the command line flags
+type options struct {
+	target       string
+	url          string
+	apiKey       string
+	brokers      string
+	topic        string
+	rate         float64
+	duration     time.Duration
+	count        int
+	workers      int
+	users        int
+	pages        int
+	skew         float64
+	mix          string
+	site         string
+	seed         int64
+	batchTimeout time.Duration
+	jsonReport   bool
+	minRate      float64
+	maxP99       time.Duration
+	maxErrorRate float64
+}
+
+func parseFlags() options {
+	var opts options
+	flag.StringVar(&opts.target, "target", "http", "Where to send events: http or kafka")
+	flag.StringVar(&opts.url, "url", "http://localhost:"+constants.ServerPort+"/event", "Producer event endpoint for -target http")
+	flag.StringVar(&opts.apiKey, "api-key", "", "Ingest API key sent as X-API-Key")
+	flag.StringVar(&opts.brokers, "brokers", constants.KafkaBrokers, "Comma-separated Kafka brokers for -target kafka")
+	flag.StringVar(&opts.topic, "topic", constants.KafkaTopic, "Topic for -target kafka")
+	flag.Float64Var(&opts.rate, "rate", 100, "Target events per second, or 0 to send as fast as possible")
+	flag.DurationVar(&opts.duration, "duration", 30*time.Second, "How long to run")
+	flag.IntVar(&opts.count, "count", 0, "Stop after this many events, or 0 to run for -duration")
+	flag.IntVar(&opts.workers, "workers", 16, "Concurrent senders")
+	flag.IntVar(&opts.users, "users", 1000, "Size of the simulated user pool")
+	flag.IntVar(&opts.pages, "pages", 50, "Number of distinct page URLs")
+	flag.Float64Var(&opts.skew, "skew", 1.2, "Zipf exponent for page popularity (> 1), or 0 for uniform")
+	flag.StringVar(&opts.mix, "mix", "page_view=70,click=25,session=5", "Relative weights of event types")
+	flag.StringVar(&opts.site, "site", "loadgen.example.com", "Host of generated URLs")
+	flag.Int64Var(&opts.seed, "seed", 1, "Random seed, for repeatable runs")
+	flag.DurationVar(&opts.batchTimeout, "batch-timeout", 10*time.Millisecond, "Kafka writer batch timeout for -target kafka")
+	flag.BoolVar(&opts.jsonReport, "json", false, "Print the report as JSON")
+	flag.Float64Var(&opts.minRate, "min-rate", 0, "Exit non-zero if achieved events per second is below this")
+	flag.DurationVar(&opts.maxP99, "max-p99", 0, "Exit non-zero if the p99 send latency is above this")
+	flag.Float64Var(&opts.maxErrorRate, "max-error-rate", 0.01, "Exit non-zero if the fraction of failed sends is above this")
+	flag.Parse()
+	return opts
+}
+
+// sender delivers one event, returning once it is acknowledged
+type sender interface {
+	Send(ctx context.Context, event models.AnalyticsEvent) error
+	Close() error
+}
+
+// httpSender posts events to the producer's /event endpoint
+type httpSender struct {
+	client *http.Client
+	url    string
+	apiKey string
+}
+
+func (h *httpSender) Send(ctx context.Context, event models.AnalyticsEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if h.apiKey != "" {
+		req.Header.Set("X-API-Key", h.apiKey)
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (h *httpSender) Close() error {
+	h.client.CloseIdleConnections()
+	return nil
+}
+
+// kafkaSender writes events straight to a topic, bypassing the producer service
+type kafkaSender struct {
+	producer *kafka.Producer
+	topic    string
+}
+
+func (k *kafkaSender) Send(ctx context.Context, event models.AnalyticsEvent) error {
+	return k.producer.SendToTopic(ctx, k.topic, k.producer.EventKey(&event), event)
+}
+
+func (k *kafkaSender) Close() error {
+	return k.producer.Close()
+}
+
+func newSender(opts options) (sender, error) {
+	switch opts.target {
+	case "http":
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.MaxIdleConnsPerHost = opts.workers
+		return &httpSender{
+			client: &http.Client{Transport: transport, Timeout: 10 * time.Second},
+			url:    opts.url,
+			apiKey: opts.apiKey,
+		}, nil
+	case "kafka":
+		producer := kafka.NewProducer(strings.Split(opts.brokers, ","), opts.topic)
+		producer.SetBatchTimeout(opts.batchTimeout)
+		return &kafkaSender{producer: producer, topic: opts.topic}, nil
+	default:
+		return nil, fmt.Errorf("unknown -target %q (use http or kafka)", opts.target)
+	}
+}
+
+// report summarises a run
+type report struct {
+	Target     string        `json:"target"`
+	Sent       int           `json:"sent"`
+	Failed     int           `json:"failed"`
+	ErrorRate  float64       `json:"error_rate"`
+	Seconds    float64       `json:"seconds"`
+	Rate       float64       `json:"events_per_second"`
+	TargetRate float64       `json:"target_events_per_second"`
+	Latency    latencyReport `json:"latency_ms"`
+	p99        time.Duration
+}
+
+// latencyReport holds send latency percentiles in milliseconds
+type latencyReport struct {
+	P50 float64 `json:"p50"`
+	P90 float64 `json:"p90"`
+	P99 float64 `json:"p99"`
+	Max float64 `json:"max"`
+}
+
+func main() {
+	opts := parseFlags()
+
+	mix, err := parseMix(opts.mix)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if opts.users < 1 || opts.pages < 1 || opts.workers < 1 {
+		fmt.Fprintln(os.Stderr, "-users, -pages and -workers must be positive")
+		os.Exit(2)
+	}
+
+	snd, err := newSender(opts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	defer snd.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), opts.duration)
+	defer cancel()
+
+	// Stop early on interrupt; the partial run is still reported
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigChan
+		cancel()
+	}()
+
+	gen := newGenerator(opts.seed, opts.site, opts.users, opts.pages, opts.skew, mix)
+	result := run(ctx, snd, gen, opts)
+
+	if opts.jsonReport {
+		json.NewEncoder(os.Stdout).Encode(result)
+	} else {
+		fmt.Printf("Target:     %s\n", result.Target)
+		fmt.Printf("Sent:       %d (%d failed, %.2f%%)\n", result.Sent, result.Failed, result.ErrorRate*100)
+		fmt.Printf("Duration:   %.1fs\n", result.Seconds)
+		fmt.Printf("Throughput: %.1f events/s (target %.0f)\n", result.Rate, result.TargetRate)
+		fmt.Printf("Latency ms: p50 %.2f  p90 %.2f  p99 %.2f  max %.2f\n",
+			result.Latency.P50, result.Latency.P90, result.Latency.P99, result.Latency.Max)
+	}
+
+	if failures := checkThresholds(result, opts); len(failures) > 0 {
+		for _, failure := range failures {
+			fmt.Fprintln(os.Stderr, "FAIL:", failure)
+		}
+		os.Exit(1)
+	}
+}
+
+// run sends events until the context ends or -count is reached. A single dispatcher paces
+// events at the target rate; workers send them and record latencies.
+func run(ctx context.Context, snd sender, gen *generator, opts options) report {
+	events := make(chan models.AnalyticsEvent, opts.workers)
+
+	var (
+		mu        sync.Mutex
+		latencies []time.Duration
+		failed    int
+		lastErr   error
+		wg        sync.WaitGroup
+	)
+	for i := 0; i < opts.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for event := range events {
+				start := time.Now()
+				err := snd.Send(context.Background(), event)
+				elapsed := time.Since(start)
+
+				mu.Lock()
+				latencies = append(latencies, elapsed)
+				if err != nil {
+					failed++
+					lastErr = err
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	start := time.Now()
+	var interval time.Duration
+	if opts.rate > 0 {
+		interval = time.Duration(float64(time.Second) / opts.rate)
+	}
+
+dispatch:
+	for i := 0; opts.count == 0 || i < opts.count; i++ {
+		// Schedule from the start time rather than sleeping a fixed interval, so slow
+		// sends are caught up instead of lowering the achieved rate
+		if interval > 0 {
+			if wait := time.Until(start.Add(time.Duration(i) * interval)); wait > 0 {
+				select {
+				case <-ctx.Done():
+					break dispatch
+				case <-time.After(wait):
+				}
+			}
+		}
+		select {
+		case <-ctx.Done():
+			break dispatch
+		case events <- gen.next():
+		}
+	}
+	close(events)
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	if lastErr != nil && !errors.Is(lastErr, context.Canceled) {
+		fmt.Fprintf(os.Stderr, "Last send error: %v\n", lastErr)
+	}
+	return summarize(opts, latencies, failed, elapsed)
+}
+
+// summarize computes throughput and latency percentiles
+func summarize(opts options, latencies []time.Duration, failed int, elapsed time.Duration) report {
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	percentile := func(p float64) time.Duration {
+		if len(latencies) == 0 {
+			return 0
+		}
+		return latencies[int(p*float64(len(latencies)-1))]
+	}
+	ms := func(d time.Duration) float64 {
+		return float64(d.Microseconds()) / 1000
+	}
+
+	r := report{
+		Target:     opts.target,
+		Sent:       len(latencies),
+		Failed:     failed,
+		Seconds:    elapsed.Seconds(),
+		TargetRate: opts.rate,
+		Latency: latencyReport{
+			P50: ms(percentile(0.50)),
+			P90: ms(percentile(0.90)),
+			P99: ms(percentile(0.99)),
+			Max: ms(percentile(1)),
+		},
+		p99: percentile(0.99),
+	}
+	if r.Sent > 0 {
+		r.ErrorRate = float64(failed) / float64(r.Sent)
+	}
+	if elapsed > 0 {
+		r.Rate = float64(r.Sent-failed) / elapsed.Seconds()
+	}
+	return r
+}
+
+// checkThresholds returns the CI regression checks the run failed
+func checkThresholds(r report, opts options) []string {
+	var failures []string
+	if r.Sent == 0 {
+		failures = append(failures, "no events were sent")
+	}
+	if opts.minRate > 0 && r.Rate < opts.minRate {
+		failures = append(failures, fmt.Sprintf("throughput %.1f events/s is below -min-rate %.1f", r.Rate, opts.minRate))
+	}
+	if opts.maxP99 > 0 && r.p99 > opts.maxP99 {
+		failures = append(failures, fmt.Sprintf("p99 latency %v is above -max-p99 %v", r.p99, opts.maxP99))
+	}
+	if r.ErrorRate > opts.maxErrorRate {
+		failures = append(failures, fmt.Sprintf("error rate %.2f%% is above -max-error-rate %.2f%%", r.ErrorRate*100, opts.maxErrorRate*100))
+	}
+	return failures
+}
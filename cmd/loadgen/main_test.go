@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+func TestHTTPSenderAcceptsAccepted(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-API-Key") != "key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	snd := &httpSender{client: server.Client(), url: server.URL, apiKey: "key"}
+	if err := snd.Send(context.Background(), models.AnalyticsEvent{Type: models.PageView}); err != nil {
+		t.Errorf("expected 202 to count as sent, got %v", err)
+	}
+	snd.apiKey = "wrong"
+	if err := snd.Send(context.Background(), models.AnalyticsEvent{Type: models.PageView}); err == nil {
+		t.Error("expected 401 to count as failed")
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"text/tabwriter"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+	"github.com/spf13/cobra"
+)
+
+func newAlertsCommand(opts *options, client *apiClient) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "alerts",
+		Short: "Manage alert configs",
+		Long: "List, create, update, enable, disable and delete the producer's alert configs through\n" +
+			"/alerts/config. Changes need an admin key in --api-key.",
+	}
+
+	// configURL addresses all configs, or the one named when name is set
+	configURL := func(name string) string {
+		u := endpoint(opts.producerURL, "/alerts/config")
+		if name != "" {
+			u += "?name=" + url.QueryEscape(name)
+		}
+		return u
+	}
+
+	var asJSON bool
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "List alert configs",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var response struct {
+				Alerts []models.AlertConfig `json:"alerts"`
+			}
+			if err := client.do(cmd.Context(), http.MethodGet, configURL(""), nil, &response); err != nil {
+				return err
+			}
+			if asJSON {
+				return printJSON(cmd.OutOrStdout(), response.Alerts)
+			}
+			return printAlertConfigs(cmd.OutOrStdout(), response.Alerts)
+		},
+	}
+	list.Flags().BoolVar(&asJSON, "json", false, "Print the configs as JSON")
+
+	get := &cobra.Command{
+		Use:   "get NAME",
+		Short: "Print an alert config as JSON",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var config models.AlertConfig
+			if err := client.do(cmd.Context(), http.MethodGet, configURL(args[0]), nil, &config); err != nil {
+				return err
+			}
+			return printJSON(cmd.OutOrStdout(), config)
+		},
+	}
+
+	var file string
+	create := &cobra.Command{
+		Use:   "create -f FILE",
+		Short: "Create an alert config from a JSON file",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			config, err := readAlertConfig(cmd.InOrStdin(), file)
+			if err != nil {
+				return err
+			}
+			if err := client.do(cmd.Context(), http.MethodPost, configURL(""), config, &config); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Created alert %q\n", config.Name)
+			return nil
+		},
+	}
+	create.Flags().StringVarP(&file, "file", "f", "", "JSON alert config, or - for standard input")
+	create.MarkFlagRequired("file")
+
+	update := &cobra.Command{
+		Use:   "update NAME -f FILE",
+		Short: "Replace an alert config with one from a JSON file",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			config, err := readAlertConfig(cmd.InOrStdin(), file)
+			if err != nil {
+				return err
+			}
+			if err := client.do(cmd.Context(), http.MethodPut, configURL(args[0]), config, &config); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Updated alert %q\n", config.Name)
+			return nil
+		},
+	}
+	update.Flags().StringVarP(&file, "file", "f", "", "JSON alert config, or - for standard input")
+	update.MarkFlagRequired("file")
+
+	// toggle builds the enable and disable commands
+	toggle := func(enabled bool) *cobra.Command {
+		verb := map[bool]string{true: "enable", false: "disable"}[enabled]
+		return &cobra.Command{
+			Use:   verb + " NAME",
+			Short: fmt.Sprintf("%s an alert", map[bool]string{true: "Enable", false: "Disable"}[enabled]),
+			Args:  cobra.ExactArgs(1),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				body := map[string]bool{"enabled": enabled}
+				if err := client.do(cmd.Context(), http.MethodPatch, configURL(args[0]), body, nil); err != nil {
+					return err
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "Alert %q %sd\n", args[0], verb)
+				return nil
+			},
+		}
+	}
+
+	remove := &cobra.Command{
+		Use:   "delete NAME",
+		Short: "Delete an alert config",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := client.do(cmd.Context(), http.MethodDelete, configURL(args[0]), nil, nil); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Deleted alert %q\n", args[0])
+			return nil
+		},
+	}
+
+	cmd.AddCommand(list, get, create, update, toggle(true), toggle(false), remove)
+	return cmd
+}
+
+// readAlertConfig decodes an alert config from a file, or from stdin when path is "-"
+func readAlertConfig(stdin io.Reader, path string) (models.AlertConfig, error) {
+	var config models.AlertConfig
+	reader := stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return config, err
+		}
+		defer file.Close()
+		reader = file
+	}
+
+	decoder := json.NewDecoder(reader)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&config); err != nil {
+		return config, fmt.Errorf("invalid alert config: %w", err)
+	}
+	return config, nil
+}
+
+// printAlertConfigs writes the configs as a table
+func printAlertConfigs(w io.Writer, configs []models.AlertConfig) error {
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "NAME\tTYPE\tCONDITION\tWINDOW\tENABLED")
+	for _, config := range configs {
+		condition := fmt.Sprintf("%s %s %g", config.Metric, config.Operator, config.Threshold)
+		if config.Path != "" {
+			condition += " on " + config.Path
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\t%dm\t%t\n", config.Name, config.Type, condition, config.WindowMinutes, config.Enabled)
+	}
+	return table.Flush()
+}
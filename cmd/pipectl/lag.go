@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/kafka"
+	"github.com/spf13/cobra"
+)
+
+// lagOptions are the flags of the lag command
+type lagOptions struct {
+	group  string
+	asJSON bool
+}
+
+// lagReport is the consumer admin server's /lag response
+type lagReport struct {
+	TotalLag   int64                `json:"total_lag"`
+	Partitions []kafka.PartitionLag `json:"partitions"`
+}
+
+func newLagCommand(opts *options, client *apiClient) *cobra.Command {
+	var lag lagOptions
+	cmd := &cobra.Command{
+		Use:   "lag",
+		Short: "Show consumer lag per partition",
+		Long: "Show how far the consumer is behind the end of each partition, from the consumer admin\n" +
+			"server's /lag endpoint. With --group the lag of that consumer group on --topic is read\n" +
+			"from the brokers instead, which also works while no consumer is running.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var report lagReport
+			if lag.group != "" {
+				partitions, err := kafka.GroupLag(cmd.Context(), strings.Split(opts.brokers, ","), lag.group, []string{opts.topic})
+				if err != nil {
+					return err
+				}
+				report.Partitions = partitions
+				for _, partition := range partitions {
+					report.TotalLag += partition.Lag
+				}
+			} else if err := client.do(cmd.Context(), http.MethodGet, endpoint(opts.consumerURL, "/lag"), nil, &report); err != nil {
+				return err
+			}
+
+			if lag.asJSON {
+				return printJSON(cmd.OutOrStdout(), report)
+			}
+			return printLag(cmd.OutOrStdout(), report)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&lag.group, "group", "", "Read the lag of this consumer group from the brokers")
+	flags.BoolVar(&lag.asJSON, "json", false, "Print the lag as JSON")
+	return cmd
+}
+
+// printLag writes the lag as a table with a total line
+func printLag(w io.Writer, report lagReport) error {
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(table, "TOPIC\tPARTITION\tCOMMITTED\tEND\tLAG\t")
+	for _, partition := range report.Partitions {
+		fmt.Fprintf(table, "%s\t%d\t%d\t%d\t%d\t\n", partition.Topic, partition.Partition, partition.Committed, partition.End, partition.Lag)
+	}
+	fmt.Fprintf(table, "TOTAL\t\t\t\t%d\t\n", report.TotalLag)
+	return table.Flush()
+}
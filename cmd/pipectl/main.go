@@ -0,0 +1,141 @@
+// Command pipectl operates a running pipeline: it sends test events, tails the events
+// topic, reports consumer lag, dumps analytics snapshots, manages alert configs and
+// resets analytics, so operators don't need to assemble curl commands.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/constants"
+	"github.com/spf13/cobra"
+)
+
+// options are the flags shared by all commands
+type options struct {
+	producerURL string
+	consumerURL string
+	apiKey      string
+	brokers     string
+	topic       string
+	timeout     time.Duration
+}
+
+// apiClient calls the producer and consumer HTTP APIs
+type apiClient struct {
+	http   *http.Client
+	apiKey string
+}
+
+// do sends a request with an optional JSON body and decodes a JSON response into out,
+// if out is not nil. Responses other than 2xx are returned as errors with their body.
+func (c *apiClient) do(ctx context.Context, method, url string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("%s %s returned %s: %s", method, url, resp.Status, strings.TrimSpace(string(message)))
+	}
+	if out == nil {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode response of %s: %w", url, err)
+	}
+	return nil
+}
+
+// newRootCommand builds the command tree. Output goes to the command's writer, so
+// tests can capture it.
+func newRootCommand() *cobra.Command {
+	opts := &options{}
+	client := &apiClient{http: &http.Client{}}
+
+	root := &cobra.Command{
+		Use:           "pipectl",
+		Short:         "Operate the analytics pipeline",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			client.http.Timeout = opts.timeout
+			client.apiKey = opts.apiKey
+			if client.apiKey == "" {
+				client.apiKey = os.Getenv("PIPECTL_API_KEY")
+			}
+		},
+	}
+
+	flags := root.PersistentFlags()
+	flags.StringVar(&opts.producerURL, "producer", "http://localhost:"+constants.ServerPort, "Base URL of the producer service")
+	flags.StringVar(&opts.consumerURL, "consumer", "http://localhost:"+constants.ConsumerAdminPort, "Base URL of the consumer admin server")
+	flags.StringVar(&opts.apiKey, "api-key", "", "API key sent as X-API-Key, an admin key for reset and alert changes, or $PIPECTL_API_KEY when not set")
+	flags.StringVar(&opts.brokers, "brokers", constants.KafkaBrokers, "Comma-separated Kafka brokers")
+	flags.StringVar(&opts.topic, "topic", constants.KafkaTopic, "Events topic")
+	flags.DurationVar(&opts.timeout, "timeout", 10*time.Second, "Timeout of each HTTP request")
+
+	root.AddCommand(
+		newProduceCommand(opts, client),
+		newTailCommand(opts),
+		newLagCommand(opts, client),
+		newSnapshotCommand(opts, client),
+		newAlertsCommand(opts, client),
+		newResetCommand(opts, client),
+	)
+	return root
+}
+
+// printJSON writes v as indented JSON
+func printJSON(w io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// endpoint joins a base URL and a path
+func endpoint(base, path string) string {
+	return strings.TrimRight(base, "/") + path
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := newRootCommand().ExecuteContext(ctx); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+// run executes pipectl against server with the given arguments and stdin, returning
+// the output
+func run(t *testing.T, server *httptest.Server, stdin string, args ...string) (string, error) {
+	t.Helper()
+	cmd := newRootCommand()
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetIn(strings.NewReader(stdin))
+	cmd.SetArgs(append([]string{"--producer", server.URL, "--consumer", server.URL, "--api-key", "secret"}, args...))
+	err := cmd.Execute()
+	return out.String(), err
+}
+
+func TestProduceSendsBatches(t *testing.T) {
+	var batches [][]models.AnalyticsEvent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/events/batch" || r.Header.Get("X-API-Key") != "secret" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL)
+		}
+		var batch []models.AnalyticsEvent
+		json.NewDecoder(r.Body).Decode(&batch)
+		batches = append(batches, batch)
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]int{"accepted": len(batch), "rejected": 0})
+	}))
+	defer server.Close()
+
+	out, err := run(t, server, "", "produce", "-n", "150", "--type", "click", "--users", "3")
+	if err != nil {
+		t.Fatalf("produce failed: %v", err)
+	}
+	if !strings.Contains(out, "Sent 150 events: 150 accepted, 0 rejected") {
+		t.Errorf("Unexpected output %q", out)
+	}
+	if len(batches) != 2 || len(batches[0]) != produceBatchSize || len(batches[1]) != 50 {
+		t.Fatalf("Expected batches of 100 and 50 events, got %d batches", len(batches))
+	}
+
+	users := make(map[string]bool)
+	for _, event := range batches[0] {
+		users[event.UserID] = true
+		if event.Type != models.Click || event.Path != "/" || event.Metadata["source"] != "pipectl" {
+			t.Fatalf("Unexpected event %+v", event)
+		}
+	}
+	if len(users) != 3 {
+		t.Errorf("Expected events from 3 users, got %d", len(users))
+	}
+
+	if _, err := run(t, server, "", "produce", "--type", "Not Valid"); err == nil {
+		t.Error("Expected an invalid event type to be rejected")
+	}
+}
+
+func TestAlertsCommands(t *testing.T) {
+	var requests []string
+	var created models.AlertConfig
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.RequestURI())
+		switch r.Method {
+		case http.MethodGet:
+			json.NewEncoder(w).Encode(map[string]interface{}{"alerts": []models.AlertConfig{
+				{Name: "Error Rate", Type: "error", Metric: "error_rate", Operator: "gt", Threshold: 5, WindowMinutes: 5, Enabled: true},
+			}})
+		case http.MethodPost:
+			json.NewDecoder(r.Body).Decode(&created)
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(created)
+		case http.MethodPatch:
+			json.NewEncoder(w).Encode(models.AlertConfig{Name: "Error Rate"})
+		case http.MethodDelete:
+			http.Error(w, "Alert config not found", http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	out, err := run(t, server, "", "alerts", "list")
+	if err != nil {
+		t.Fatalf("alerts list failed: %v", err)
+	}
+	if !strings.Contains(out, "Error Rate") || !strings.Contains(out, "error_rate gt 5") {
+		t.Errorf("Unexpected list output %q", out)
+	}
+
+	path := filepath.Join(t.TempDir(), "alert.json")
+	os.WriteFile(path, []byte(`{"name": "Slow Pages", "type": "performance", "metric": "avg_load_time", "operator": "gt", "threshold": 3000, "enabled": true}`), 0o644)
+	if _, err := run(t, server, "", "alerts", "create", "-f", path); err != nil {
+		t.Fatalf("alerts create failed: %v", err)
+	}
+	if created.Name != "Slow Pages" || created.Threshold != 3000 {
+		t.Errorf("Unexpected created config %+v", created)
+	}
+	if _, err := run(t, server, `{"name": "X", "unknown": 1}`, "alerts", "create", "-f", "-"); err == nil {
+		t.Error("Expected a config with unknown fields to be rejected")
+	}
+
+	if _, err := run(t, server, "", "alerts", "disable", "Error Rate"); err != nil {
+		t.Fatalf("alerts disable failed: %v", err)
+	}
+	if _, err := run(t, server, "", "alerts", "delete", "Missing"); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("Expected delete of a missing alert to report 404, got %v", err)
+	}
+
+	want := []string{
+		"GET /alerts/config",
+		"POST /alerts/config",
+		"PATCH /alerts/config?name=Error+Rate",
+		"DELETE /alerts/config?name=Missing",
+	}
+	if strings.Join(requests, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Expected requests %v, got %v", want, requests)
+	}
+}
+
+func TestResetAsksForConfirmation(t *testing.T) {
+	resets := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/admin/reset" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL)
+		}
+		resets++
+		json.NewEncoder(w).Encode(map[string]string{"status": "reset"})
+	}))
+	defer server.Close()
+
+	if _, err := run(t, server, "n\n", "reset"); err == nil || resets != 0 {
+		t.Fatalf("Expected a declined reset not to be sent, got %v after %d resets", err, resets)
+	}
+	if _, err := run(t, server, "y\n", "reset"); err != nil || resets != 1 {
+		t.Fatalf("Expected a confirmed reset, got %v after %d resets", err, resets)
+	}
+	if out, err := run(t, server, "", "reset", "--yes"); err != nil || resets != 2 || !strings.Contains(out, "Analytics reset") {
+		t.Fatalf("Expected reset --yes to reset without asking, got %q, %v", out, err)
+	}
+}
+
+func TestSnapshotAndLag(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/stats":
+			json.NewEncoder(w).Encode(map[string]interface{}{"total_events": 42})
+		case "/lag":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"total_lag": 7,
+				"partitions": []map[string]interface{}{
+					{"topic": "analytics-events", "partition": 0, "committed_offset": 10, "end_offset": 17, "lag": 7},
+				},
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	out, err := run(t, server, "", "snapshot", "--from-consumer")
+	if err != nil || !strings.Contains(out, `"total_events": 42`) {
+		t.Fatalf("Unexpected snapshot %q, %v", out, err)
+	}
+	if _, err := run(t, server, "", "snapshot"); err == nil {
+		t.Error("Expected a failed request to be reported")
+	}
+
+	out, err = run(t, server, "", "lag")
+	if err != nil {
+		t.Fatalf("lag failed: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 3 || !strings.Contains(lines[1], "analytics-events") || !strings.HasSuffix(strings.TrimSpace(lines[2]), "7") {
+		t.Errorf("Unexpected lag table %q", out)
+	}
+}
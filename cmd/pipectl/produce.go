@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+)
+
+// produceBatchSize is the number of events posted per /events/batch request
+const produceBatchSize = 100
+
+// produceOptions are the flags of the produce command
+type produceOptions struct {
+	count     int
+	eventType string
+	url       string
+	users     int
+}
+
+func newProduceCommand(opts *options, client *apiClient) *cobra.Command {
+	var produce produceOptions
+	cmd := &cobra.Command{
+		Use:   "produce",
+		Short: "Send test events through the producer",
+		Long: "Send test events to the producer's /events/batch endpoint, so they go through the same\n" +
+			"validation, bot detection and sampling as real traffic. Events carry \"source\": \"pipectl\"\n" +
+			"metadata so they can be told apart.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if produce.count <= 0 {
+				return fmt.Errorf("--count must be positive")
+			}
+			if produce.users <= 0 {
+				return fmt.Errorf("--users must be positive")
+			}
+			if !models.EventType(produce.eventType).Valid() {
+				return fmt.Errorf("invalid event type %q", produce.eventType)
+			}
+			page, err := url.Parse(produce.url)
+			if err != nil || page.Host == "" {
+				return fmt.Errorf("invalid --url %q", produce.url)
+			}
+
+			events := testEvents(produce, page, time.Now())
+			accepted, rejected := 0, 0
+			for start := 0; start < len(events); start += produceBatchSize {
+				end := min(start+produceBatchSize, len(events))
+				var result struct {
+					Accepted int `json:"accepted"`
+					Rejected int `json:"rejected"`
+				}
+				err := client.do(cmd.Context(), http.MethodPost, endpoint(opts.producerURL, "/events/batch"), events[start:end], &result)
+				if err != nil {
+					return err
+				}
+				accepted += result.Accepted
+				rejected += result.Rejected
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Sent %d events: %d accepted, %d rejected\n", len(events), accepted, rejected)
+			return nil
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.IntVarP(&produce.count, "count", "n", 10, "Number of events to send")
+	flags.StringVar(&produce.eventType, "type", string(models.PageView), "Event type")
+	flags.StringVar(&produce.url, "url", "https://pipectl.example.com/", "Page URL of the events")
+	flags.IntVar(&produce.users, "users", 1, "Number of distinct users the events are spread over")
+	return cmd
+}
+
+// testEvents builds count events spread round-robin over the given number of users, one
+// session per user
+func testEvents(produce produceOptions, page *url.URL, now time.Time) []models.AnalyticsEvent {
+	events := make([]models.AnalyticsEvent, produce.count)
+	for i := range events {
+		user := i % produce.users
+		events[i] = models.AnalyticsEvent{
+			ID:        uuid.New().String(),
+			Type:      models.EventType(produce.eventType),
+			Timestamp: now,
+			UserID:    fmt.Sprintf("pipectl-user-%d", user),
+			SessionID: fmt.Sprintf("pipectl-session-%d-%d", now.Unix(), user),
+			URL:       page.String(),
+			Path:      page.Path,
+			UserAgent: "pipectl",
+			Metadata:  map[string]interface{}{"source": "pipectl"},
+		}
+	}
+	return events
+}
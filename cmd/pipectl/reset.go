@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+func newResetCommand(opts *options, client *apiClient) *cobra.Command {
+	var yes bool
+	cmd := &cobra.Command{
+		Use:   "reset",
+		Short: "Reset the producer's in-memory analytics",
+		Long: "Clear the producer's live analytics through /admin/reset. Persisted history, alert configs\n" +
+			"and events in Kafka are kept. Asks for confirmation unless --yes is set.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !yes {
+				fmt.Fprintf(cmd.OutOrStdout(), "Reset analytics of %s? [y/N] ", opts.producerURL)
+				answer, _ := bufio.NewReader(cmd.InOrStdin()).ReadString('\n')
+				if answer = strings.ToLower(strings.TrimSpace(answer)); answer != "y" && answer != "yes" {
+					return fmt.Errorf("reset cancelled")
+				}
+			}
+
+			var result struct {
+				Status string `json:"status"`
+			}
+			if err := client.do(cmd.Context(), http.MethodPost, endpoint(opts.producerURL, "/admin/reset"), nil, &result); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Analytics %s\n", result.Status)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "Do not ask for confirmation")
+	return cmd
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/spf13/cobra"
+)
+
+func newSnapshotCommand(opts *options, client *apiClient) *cobra.Command {
+	var fromConsumer bool
+	cmd := &cobra.Command{
+		Use:   "snapshot",
+		Short: "Dump the current analytics snapshot",
+		Long: "Print the analytics snapshot of a running instance as JSON: the producer's /analytics, or\n" +
+			"with --from-consumer the consumer admin server's /stats.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			url := endpoint(opts.producerURL, "/analytics")
+			if fromConsumer {
+				url = endpoint(opts.consumerURL, "/stats")
+			}
+
+			// Decoded generically so fields the models don't know about are kept
+			var snapshot map[string]interface{}
+			if err := client.do(cmd.Context(), http.MethodGet, url, nil, &snapshot); err != nil {
+				return err
+			}
+			return printJSON(cmd.OutOrStdout(), snapshot)
+		},
+	}
+
+	cmd.Flags().BoolVar(&fromConsumer, "from-consumer", false, "Read the snapshot from the consumer instead of the producer")
+	return cmd
+}
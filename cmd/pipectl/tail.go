@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/kafka"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+	"github.com/spf13/cobra"
+)
+
+// tailOptions are the flags of the tail command
+type tailOptions struct {
+	since  time.Duration
+	types  []string
+	asJSON bool
+}
+
+func newTailCommand(opts *options) *cobra.Command {
+	var tail tailOptions
+	cmd := &cobra.Command{
+		Use:   "tail",
+		Short: "Follow the events topic",
+		Long: "Print events as they are written to the events topic, until interrupted. The topic is read\n" +
+			"outside any consumer group, so running consumers are unaffected.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var since time.Time
+			if tail.since > 0 {
+				since = time.Now().Add(-tail.since)
+			}
+			types := make(map[models.EventType]bool, len(tail.types))
+			for _, eventType := range tail.types {
+				types[models.EventType(eventType)] = true
+			}
+
+			out := cmd.OutOrStdout()
+			return kafka.Tail(cmd.Context(), strings.Split(opts.brokers, ","), opts.topic, since, func(message *kafka.Message) {
+				if len(types) > 0 && !types[message.Event.Type] {
+					return
+				}
+				if tail.asJSON {
+					printJSON(out, message.Event)
+					return
+				}
+				printEvent(out, message)
+			})
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.DurationVar(&tail.since, "since", 0, "Start this far back instead of at the end of the topic, e.g. 5m")
+	flags.StringSliceVar(&tail.types, "type", nil, "Only print events of these types")
+	flags.BoolVar(&tail.asJSON, "json", false, "Print each event as JSON")
+	return cmd
+}
+
+// printEvent writes one line per event: time, position, type, user and URL, followed by
+// the metadata if there is any
+func printEvent(w io.Writer, message *kafka.Message) {
+	event := message.Event
+	line := fmt.Sprintf("%s  %d:%-8d  %-12s  user=%-16s  %s",
+		event.Timestamp.UTC().Format(time.RFC3339), message.Partition, message.Offset,
+		event.Type, event.UserID, event.URL)
+	if len(event.Metadata) > 0 {
+		line += fmt.Sprintf("  %v", event.Metadata)
+	}
+	fmt.Fprintln(w, line)
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/constants"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/kafka"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/logging"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+// Sources a rebuild reads from
+const (
+	rebuildFromKafka = "kafka" // Replay retained events from a timestamp
+	rebuildFromStore = "store" // Restore persisted hourly rollups
+)
+
+// rebuildStatus reports the progress of the latest rebuild
+type rebuildStatus struct {
+	State      string     `json:"state"` // idle, running, completed or failed
+	Source     string     `json:"source,omitempty"`
+	From       *time.Time `json:"from,omitempty"`
+	To         *time.Time `json:"to,omitempty"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Events     int64      `json:"events"`  // Events replayed from Kafka
+	Rollups    int        `json:"rollups"` // Hours restored from the store
+	Error      string     `json:"error,omitempty"`
+}
+
+// rebuildTracker runs at most one rebuild at a time and records its progress
+type rebuildTracker struct {
+	mu     sync.Mutex
+	status rebuildStatus
+}
+
+// start marks a rebuild as running, reporting false if one already is
+func (t *rebuildTracker) start(source string, from, to time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.status.State == "running" {
+		return false
+	}
+	now := time.Now()
+	t.status = rebuildStatus{State: "running", Source: source, StartedAt: &now}
+	if !from.IsZero() {
+		t.status.From, t.status.To = &from, &to
+	}
+	return true
+}
+
+// update applies a change to the running rebuild's status
+func (t *rebuildTracker) update(change func(*rebuildStatus)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	change(&t.status)
+}
+
+// finish records the outcome of the running rebuild
+func (t *rebuildTracker) finish(err error) {
+	t.update(func(status *rebuildStatus) {
+		now := time.Now()
+		status.FinishedAt = &now
+		status.State = "completed"
+		if err != nil {
+			status.State = "failed"
+			status.Error = err.Error()
+		}
+	})
+}
+
+// snapshot returns a copy of the current status
+func (t *rebuildTracker) snapshot() rebuildStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.status.State == "" {
+		return rebuildStatus{State: "idle"}
+	}
+	return t.status
+}
+
+// handleAdminReset clears the in-memory analytics, keeping configuration. Persisted
+// hourly history is not changed.
+func (s *Server) handleAdminReset(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.rebuilds.snapshot().State == "running" {
+		http.Error(w, "A rebuild is running", http.StatusConflict)
+		return
+	}
+
+	s.analyticsService.Reset()
+	s.analyticsCache.Invalidate()
+	logging.FromContext(r.Context()).Info("Analytics reset")
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":         "reset",
+		"complete_since": s.analyticsService.CompleteSince(),
+	})
+}
+
+// handleAdminRollups reports the rollup job's last run on GET, and runs it on POST
+func (s *Server) handleAdminRollups(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		if err := s.history.RollUp(r.Context(), time.Now()); err != nil {
+			logging.FromContext(r.Context()).Error("Rollup job failed", "error", err)
+			http.Error(w, fmt.Sprintf("Rollup job failed: %v", err), http.StatusInternalServerError)
+			return
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.history.RollupStatus())
+}
+
+// handleAdminRebuild reports the latest rebuild on GET. POST resets the analytics and
+// rebuilds them in the background, from events retained in Kafka since ?from= (source=kafka,
+// the default) or from the persisted hourly rollups of the in-memory window (source=store).
+func (s *Server) handleAdminRebuild(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.rebuilds.snapshot())
+		return
+	case http.MethodPost:
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	source := query.Get("source")
+	if source == "" {
+		source = rebuildFromKafka
+	}
+
+	var from, to time.Time
+	switch source {
+	case rebuildFromKafka:
+		var err error
+		if from, err = time.Parse(time.RFC3339, query.Get("from")); err != nil {
+			http.Error(w, "Missing or invalid from parameter, expected RFC3339", http.StatusBadRequest)
+			return
+		}
+		// Events ingested after the request are counted live, so the replay stops here
+		to = time.Now()
+		if !from.Before(to) {
+			http.Error(w, "from must be in the past", http.StatusBadRequest)
+			return
+		}
+	case rebuildFromStore:
+	default:
+		http.Error(w, fmt.Sprintf("Unknown source %q (use kafka or store)", source), http.StatusBadRequest)
+		return
+	}
+
+	if !s.rebuilds.start(source, from, to) {
+		http.Error(w, "A rebuild is already running", http.StatusConflict)
+		return
+	}
+
+	logger := logging.FromContext(r.Context()).With("source", source)
+	go func() {
+		err := s.rebuild(context.Background(), source, from, to)
+		s.rebuilds.finish(err)
+		if err != nil {
+			logger.Error("Analytics rebuild failed", "error", err)
+			return
+		}
+		logger.Info("Analytics rebuild completed", "events", s.rebuilds.snapshot().Events)
+	}()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(s.rebuilds.snapshot())
+}
+
+// rebuild resets the analytics and reconstructs them from the source
+func (s *Server) rebuild(ctx context.Context, source string, from, to time.Time) error {
+	s.analyticsService.Reset()
+	s.analyticsCache.Invalidate()
+
+	if source == rebuildFromStore {
+		restored, err := s.history.Restore(ctx)
+		s.rebuilds.update(func(status *rebuildStatus) { status.Rollups = restored })
+		return err
+	}
+
+	// An event routed to several topics is stored in each of them but counted once
+	topics := s.router.Topics()
+	seen := make(map[string]bool)
+	for _, topic := range topics {
+		if topic == constants.ReplayTopic {
+			continue
+		}
+
+		readRange := kafka.Range{Partition: -1, ToOffset: -1, FromTime: from, ToTime: to}
+		_, err := kafka.ReadRange(ctx, []string{constants.KafkaBrokers}, topic, readRange, func(msg *kafka.Message) error {
+			event := msg.Event
+			if event.Type == models.SessionReplay {
+				return nil
+			}
+			if len(topics) > 1 {
+				if seen[event.ID] {
+					return nil
+				}
+				seen[event.ID] = true
+			}
+
+			s.rebuilds.update(func(status *rebuildStatus) { status.Events++ })
+			return s.analyticsService.ProcessEvent(event)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to replay %s: %w", topic, err)
+		}
+	}
+
+	s.analyticsService.SetCompleteSince(from)
+	return nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/logging"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+// handleAlertConfigs manages alert configurations: GET lists them (or one with ?name=),
+// POST creates, PUT replaces, PATCH enables or disables and DELETE removes the ?name= alert
+func (s *Server) handleAlertConfigs(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.getAlertConfigs(w, r)
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		s.adminAuth.middleware(s.changeAlertConfig)(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// getAlertConfigs returns all alert configurations, or the one named by ?name=
+func (s *Server) getAlertConfigs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if name := r.URL.Query().Get("name"); name != "" {
+		config, ok := s.analyticsService.AlertConfig(name)
+		if !ok {
+			http.Error(w, "Alert config not found", http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(config)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"alerts": s.analyticsService.AlertConfigs(),
+	})
+}
+
+// changeAlertConfig applies a create, update, toggle or delete and persists the result
+func (s *Server) changeAlertConfig(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("name")
+	if r.Method != http.MethodPost && name == "" {
+		http.Error(w, "Missing name parameter", http.StatusBadRequest)
+		return
+	}
+
+	// Serialize changes so the store always receives the latest configuration
+	s.alertConfigMu.Lock()
+	defer s.alertConfigMu.Unlock()
+
+	status := http.StatusOK
+	var (
+		config models.AlertConfig
+		err    error
+	)
+	switch r.Method {
+	case http.MethodPost:
+		if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+			return
+		}
+		err = s.analyticsService.CreateAlert(config)
+		status = http.StatusCreated
+
+	case http.MethodPut:
+		if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+			return
+		}
+		if config.Name == "" {
+			config.Name = name
+		}
+		err = s.analyticsService.UpdateAlert(name, config)
+
+	case http.MethodPatch:
+		var body struct {
+			Enabled *bool `json:"enabled"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Enabled == nil {
+			http.Error(w, `Request body must be {"enabled": true|false}`, http.StatusBadRequest)
+			return
+		}
+		err = s.analyticsService.SetAlertEnabled(name, *body.Enabled)
+		config, _ = s.analyticsService.AlertConfig(name)
+
+	case http.MethodDelete:
+		err = s.analyticsService.RemoveAlert(name)
+		status = http.StatusNoContent
+	}
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := s.alertStore.SaveAlertConfigs(r.Context(), s.analyticsService.AlertConfigs()); err != nil {
+		// The change is live but will not survive a restart
+		logging.FromContext(r.Context()).Error("Failed to persist alert configs", "error", err)
+		http.Error(w, "Alert config applied but could not be saved", http.StatusInternalServerError)
+		return
+	}
+
+	if status == http.StatusNoContent {
+		w.WriteHeader(status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(config)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/constants"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/bus"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/kafka"
+)
+
+// openMessageBus connects to the broker named by MESSAGE_BUS, or returns nil for Kafka,
+// which the producer uses through package kafka for its partitioning, compression and
+// retry settings
+func openMessageBus(ctx context.Context) (bus.MessageBus, error) {
+	if strings.EqualFold(constants.MessageBus, bus.KindKafka) {
+		return nil, nil
+	}
+	return bus.Open(ctx, bus.Config{
+		Kind:              constants.MessageBus,
+		NATSURL:           constants.NATSURL,
+		NATSStream:        constants.NATSStream,
+		NATSSubjectPrefix: constants.NATSSubjectPrefix,
+		RabbitMQURL:       constants.RabbitMQURL,
+		RabbitMQExchange:  constants.RabbitMQExchange,
+		RabbitMQPrefetch:  constants.RabbitMQPrefetch,
+	})
+}
+
+// newTopicProducer publishes to topic through the message bus, or Kafka without one
+func newTopicProducer(messageBus bus.MessageBus, topic string) kafka.EventProducer {
+	if messageBus != nil {
+		return bus.NewProducer(messageBus, topic, kafka.KeyByEventID)
+	}
+	return kafka.NewProducer([]string{constants.KafkaBrokers}, topic)
+}
+
+// newTopicConsumer consumes topic in group through the message bus, or Kafka without one
+func newTopicConsumer(messageBus bus.MessageBus, topic, group string) kafka.EventConsumer {
+	return newTopicsConsumer(messageBus, []string{topic}, group)
+}
+
+// newTopicsConsumer consumes several topics in one group
+func newTopicsConsumer(messageBus bus.MessageBus, topics []string, group string) kafka.EventConsumer {
+	if messageBus != nil {
+		return bus.NewConsumer(messageBus, topics, group)
+	}
+	return kafka.NewMultiTopicConsumer([]string{constants.KafkaBrokers}, topics, group)
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/logging"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+// handleDashboards manages dashboards: GET lists them (or one with ?name=), POST creates,
+// PUT replaces and DELETE removes the ?name= dashboard
+func (s *Server) handleDashboards(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.getDashboards(w, r)
+	case http.MethodPost, http.MethodPut, http.MethodDelete:
+		s.adminAuth.middleware(s.changeDashboard)(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// getDashboards returns all dashboards, or the one named by ?name=
+func (s *Server) getDashboards(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if name := r.URL.Query().Get("name"); name != "" {
+		dashboard, ok := s.analyticsService.Dashboard(name)
+		if !ok {
+			http.Error(w, "Dashboard not found", http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(dashboard)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"dashboards": s.analyticsService.Dashboards(),
+	})
+}
+
+// handleDashboardData returns the current data of each widget of the ?name= dashboard
+func (s *Server) handleDashboardData(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	dashboard, ok := s.analyticsService.Dashboard(r.URL.Query().Get("name"))
+	if !ok {
+		http.Error(w, "Dashboard not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.analyticsService.DashboardData(dashboard))
+}
+
+// changeDashboard applies a create, update or delete and persists the result
+func (s *Server) changeDashboard(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("name")
+	if r.Method != http.MethodPost && name == "" {
+		http.Error(w, "Missing name parameter", http.StatusBadRequest)
+		return
+	}
+
+	// Serialize changes so the store always receives the latest dashboards
+	s.dashboardMu.Lock()
+	defer s.dashboardMu.Unlock()
+
+	status := http.StatusOK
+	var (
+		dashboard models.Dashboard
+		err       error
+	)
+	switch r.Method {
+	case http.MethodPost:
+		if err := json.NewDecoder(r.Body).Decode(&dashboard); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+			return
+		}
+		err = s.analyticsService.CreateDashboard(dashboard)
+		status = http.StatusCreated
+
+	case http.MethodPut:
+		if err := json.NewDecoder(r.Body).Decode(&dashboard); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+			return
+		}
+		if dashboard.Name == "" {
+			dashboard.Name = name
+		}
+		err = s.analyticsService.UpdateDashboard(name, dashboard)
+
+	case http.MethodDelete:
+		err = s.analyticsService.RemoveDashboard(name)
+		status = http.StatusNoContent
+	}
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := s.dashboardStore.SaveDashboards(r.Context(), s.analyticsService.Dashboards()); err != nil {
+		// The change is live but will not survive a restart
+		logging.FromContext(r.Context()).Error("Failed to persist dashboards", "error", err)
+		http.Error(w, "Dashboard applied but could not be saved", http.StatusInternalServerError)
+		return
+	}
+
+	if status == http.StatusNoContent {
+		w.WriteHeader(status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(dashboard)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/constants"
+	"github.com/klauspost/compress/zstd"
+)
+
+// zstdMaxWindow bounds the memory a zstd frame may ask the decoder for
+const zstdMaxWindow = 8 << 20
+
+// bodyDecoders open the request body encodings the producer understands
+var bodyDecoders = map[string]func(io.Reader) (io.ReadCloser, error){
+	"gzip": func(r io.Reader) (io.ReadCloser, error) {
+		return gzip.NewReader(r)
+	},
+	// HTTP deflate is zlib-wrapped (RFC 9110)
+	"deflate": func(r io.Reader) (io.ReadCloser, error) {
+		return zlib.NewReader(r)
+	},
+	"zstd": func(r io.Reader) (io.ReadCloser, error) {
+		decoder, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxWindow(zstdMaxWindow))
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	},
+}
+
+// acceptedEncodings returns the enabled body encodings, in the order configured
+func acceptedEncodings() []string {
+	var encodings []string
+	for _, encoding := range constants.IngestEncodings {
+		encoding = strings.ToLower(strings.TrimSpace(encoding))
+		if _, ok := bodyDecoders[encoding]; ok {
+			encodings = append(encodings, encoding)
+		}
+	}
+	return encodings
+}
+
+// advertiseIngestLimits tells clients which body encodings are accepted and how large
+// the decoded body may be, so they can size and compress their requests
+func advertiseIngestLimits(w http.ResponseWriter, maxBytes int64) {
+	encodings := acceptedEncodings()
+	if len(encodings) == 0 {
+		encodings = []string{"identity"}
+	}
+	w.Header().Set("Accept-Encoding", strings.Join(encodings, ", "))
+	w.Header().Set("X-Max-Body-Bytes", strconv.FormatInt(maxBytes, 10))
+}
+
+// errUnsupportedEncoding is returned by decodeBody for encodings that aren't enabled
+var errUnsupportedEncoding = errors.New("unsupported content encoding")
+
+// decodeBody wraps body in a decoder for the request's Content-Encoding. Bodies without
+// an encoding, or with identity, are returned as they are.
+func decodeBody(r *http.Request, body io.Reader) (io.ReadCloser, error) {
+	encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+	if encoding == "" || encoding == "identity" {
+		return io.NopCloser(body), nil
+	}
+	for _, accepted := range acceptedEncodings() {
+		if encoding == accepted {
+			return bodyDecoders[encoding](body)
+		}
+	}
+	return nil, errUnsupportedEncoding
+}
+
+// wireReader remembers the last error of the request body, so failures of the
+// connection can be told apart from malformed compressed data
+type wireReader struct {
+	r   io.Reader
+	err error
+}
+
+func (w *wireReader) Read(p []byte) (int, error) {
+	n, err := w.r.Read(p)
+	if err != nil && err != io.EOF {
+		w.err = err
+	}
+	return n, err
+}
+
+// readDecoded reads a decoded body of at most maxBytes, failing once it grows past the
+// limit so compressed bodies can't expand without bound
+func readDecoded(decoded io.Reader, maxBytes int64) ([]byte, error) {
+	body, err := io.ReadAll(io.LimitReader(decoded, maxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > maxBytes {
+		return nil, &http.MaxBytesError{Limit: maxBytes}
+	}
+	return body, nil
+}
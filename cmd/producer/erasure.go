@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/logging"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/replay"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/spool"
+	"github.com/google/uuid"
+)
+
+// erasureRequest names the user to erase. Sessions already known to belong to the user
+// may be listed so their replays are deleted even once they have left the recent events.
+type erasureRequest struct {
+	UserID     string   `json:"user_id"`
+	SessionIDs []string `json:"session_ids,omitempty"`
+}
+
+// handleErasure removes everything stored for a user: their recent events, per-user
+// aggregates and sessions in this producer's analytics, their spooled events and their
+// session replays. A
+// user_erasure tombstone is written to every event topic so consumers delete the user's
+// data from their own analytics and the warehouse sinks.
+func (s *Server) handleErasure(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req erasureRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	if req.UserID == "" {
+		http.Error(w, "Missing user_id", http.StatusBadRequest)
+		return
+	}
+	for _, sessionID := range req.SessionIDs {
+		if !replay.ValidSessionID(sessionID) {
+			http.Error(w, fmt.Sprintf("Invalid session ID %q", sessionID), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// With hashing enabled the pipeline only knows the user by the current period's hash.
+	// Hashes from earlier periods cannot be linked to the user, by design.
+	userID := req.UserID
+	if s.scrubber != nil {
+		userID = s.scrubber.UserID(userID)
+	}
+
+	// Send the tombstone first so a failure can be retried before anything is removed
+	tombstone := models.NewUserErasure(uuid.New().String(), userID)
+	logger := logging.FromContext(r.Context()).With("event_id", tombstone.ID)
+	ctx := context.Background()
+	topics := s.router.Topics()
+	if s.shadow != nil {
+		// Mirrored events must be erased from the shadow pipeline as well
+		topics = append(topics, s.shadow.Topic())
+	}
+	for _, topic := range topics {
+		if err := s.sendEvent(ctx, topic, tombstone); err != nil {
+			logger.Error("Failed to send erasure tombstone", "topic", topic, "error", err)
+			http.Error(w, "Failed to send erasure request", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Spooled events of the user would otherwise reach Kafka after the tombstone
+	if s.spool != nil {
+		removed, err := s.spool.Remove(func(record spool.Record) bool {
+			return record.Event.UserID == userID && record.Event.Type != models.UserErasure
+		})
+		if err != nil {
+			logger.Error("Failed to remove spooled events", "error", err)
+			http.Error(w, "Failed to remove spooled events", http.StatusInternalServerError)
+			return
+		}
+		if removed > 0 {
+			logger.Info("Removed spooled events of erased user", "events", removed)
+		}
+	}
+
+	result := s.analyticsService.EraseUser(userID)
+	s.analyticsCache.Invalidate()
+
+	for _, sessionID := range slices.Concat(result.Sessions, req.SessionIDs) {
+		if err := s.replayStore.DeleteSession(r.Context(), sessionID); err != nil {
+			logger.Error("Failed to delete session replay", "session_id", sessionID, "error", err)
+			http.Error(w, "Failed to delete session replays", http.StatusInternalServerError)
+			return
+		}
+		if s.sessionStore != nil {
+			if err := s.sessionStore.DeleteSession(r.Context(), sessionID); err != nil {
+				logger.Error("Failed to delete session timeline", "session_id", sessionID, "error", err)
+				http.Error(w, "Failed to delete session timelines", http.StatusInternalServerError)
+				return
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "erased",
+		"id":     tombstone.ID,
+		"result": result,
+	})
+}
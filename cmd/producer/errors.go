@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/errs"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/ingest"
+)
+
+// kindStatuses maps error kinds to the status they are answered with
+var kindStatuses = map[error]int{
+	errs.ErrInvalidEvent:     http.StatusBadRequest,
+	errs.ErrInvalidRequest:   http.StatusBadRequest,
+	errs.ErrNotFound:         http.StatusNotFound,
+	errs.ErrConflict:         http.StatusConflict,
+	errs.ErrThrottled:        http.StatusTooManyRequests,
+	errs.ErrKafkaUnavailable: http.StatusServiceUnavailable,
+	errs.ErrStoreTimeout:     http.StatusGatewayTimeout,
+}
+
+// httpStatus returns the status an error is answered with; unclassified errors are 500
+func httpStatus(err error) int {
+	if status, ok := kindStatuses[errs.KindOf(err)]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// writeError responds with err's message and the status of its kind, e.g. 404 for
+// errs.ErrNotFound and 409 for errs.ErrConflict
+func writeError(w http.ResponseWriter, err error) {
+	http.Error(w, err.Error(), httpStatus(err))
+}
+
+// ingestError returns an ingestion error as an *ingest.Error. Errors of other types
+// are reported with the status and code of their errs kind, and unclassified ones as
+// 400 invalid_event.
+func ingestError(err error) *ingest.Error {
+	if err == nil {
+		return nil
+	}
+	var ingestErr *ingest.Error
+	if errors.As(err, &ingestErr) {
+		return ingestErr
+	}
+	if errs.KindOf(err) != nil {
+		return ingest.Errorf(httpStatus(err), errs.Code(err), "%v", err)
+	}
+	return ingest.Errorf(http.StatusBadRequest, "invalid_event", "%v", err)
+}
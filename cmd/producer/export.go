@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/constants"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/analytics"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/export"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/kafka"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/logging"
+)
+
+// errExportLimit stops a raw event export once ExportMaxEvents rows are written
+var errExportLimit = errors.New("export row limit reached")
+
+// startExport validates the method and format and sets the download headers.
+// It writes an error response and returns false if the request cannot be served.
+func startExport(w http.ResponseWriter, r *http.Request, name string) (export.RowWriter, bool) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return nil, false
+	}
+
+	format, err := export.ParseFormat(r.URL.Query().Get("format"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+
+	filename := fmt.Sprintf("%s-%s.%s", name, time.Now().UTC().Format("20060102T150405Z"), format)
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	return export.NewWriter(w, format), true
+}
+
+// exportQuery parses list query parameters for an export, which includes every item up to
+// MaxQueryLimit unless a limit is given
+func exportQuery(values url.Values) (analytics.Query, error) {
+	query, err := analytics.ParseQuery(values)
+	if err != nil {
+		return query, err
+	}
+	if values.Get("limit") == "" {
+		query.Limit = analytics.MaxQueryLimit
+	}
+	return query, nil
+}
+
+// finishExport closes the file; errors can only be logged since the response has started
+func finishExport(r *http.Request, name string, writer export.RowWriter, err error) {
+	if closeErr := writer.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Export failed", "export", name, "error", err)
+	}
+}
+
+// handleExportPages downloads tracked pages as CSV or XLSX
+func (s *Server) handleExportPages(w http.ResponseWriter, r *http.Request) {
+	query, err := exportQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid query: %v", err), http.StatusBadRequest)
+		return
+	}
+	page, err := s.analyticsService.QueryPages(query)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid query: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	writer, ok := startExport(w, r, "pages")
+	if !ok {
+		return
+	}
+	finishExport(r, "pages", writer, export.WritePages(writer, page.Items))
+}
+
+// handleExportSources downloads traffic sources as CSV or XLSX
+func (s *Server) handleExportSources(w http.ResponseWriter, r *http.Request) {
+	query, err := exportQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid query: %v", err), http.StatusBadRequest)
+		return
+	}
+	page, err := s.analyticsService.QuerySources(query)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid query: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	writer, ok := startExport(w, r, "sources")
+	if !ok {
+		return
+	}
+	finishExport(r, "sources", writer, export.WriteSources(writer, page.Items))
+}
+
+// handleExportHourly downloads the rollup series for a time range as CSV or XLSX
+func (s *Server) handleExportHourly(w http.ResponseWriter, r *http.Request) {
+	from, to, granularity, err := parseHistoryRange(r.URL.Query())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid history query: %v", err), http.StatusBadRequest)
+		return
+	}
+	rollups, err := s.history.Query(r.Context(), from, to, granularity)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid history query: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	writer, ok := startExport(w, r, "history")
+	if !ok {
+		return
+	}
+	finishExport(r, "history", writer, export.WriteRollups(writer, rollups))
+}
+
+// handleExportEvents streams raw events retained in Kafka for a time range as CSV or XLSX.
+// The topic defaults to KAFKA_TOPIC; events routed elsewhere are exported with ?topic=.
+func (s *Server) handleExportEvents(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("from") == "" {
+		http.Error(w, "Missing from parameter", http.StatusBadRequest)
+		return
+	}
+	from, to, _, err := parseHistoryRange(query)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid export query: %v", err), http.StatusBadRequest)
+		return
+	}
+	if !from.Before(to) {
+		http.Error(w, "Invalid export query: from must be before to", http.StatusBadRequest)
+		return
+	}
+	topic := query.Get("topic")
+	if topic == "" {
+		topic = constants.KafkaTopic
+	}
+
+	// Check Kafka first, since errors after the header row can no longer change the status
+	if err := s.producer.Ping(r.Context()); err != nil {
+		http.Error(w, "Kafka unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	writer, ok := startExport(w, r, "events")
+	if !ok {
+		return
+	}
+	if err := writer.WriteRow(export.EventHeader); err != nil {
+		finishExport(r, "events", writer, err)
+		return
+	}
+
+	// Cancel the read once the row limit is reached; ReadRange otherwise runs to the end of the range
+	ctx, cancel := context.WithCancelCause(r.Context())
+	defer cancel(nil)
+
+	rows := 0
+	readRange := kafka.Range{Partition: -1, ToOffset: -1, FromTime: from, ToTime: to}
+	_, err = kafka.ReadRange(ctx, []string{constants.KafkaBrokers}, topic, readRange, func(msg *kafka.Message) error {
+		if rows >= constants.ExportMaxEvents {
+			cancel(errExportLimit)
+			return errExportLimit
+		}
+		rows++
+		return writer.WriteRow(export.EventRow(msg.Event))
+	})
+	if errors.Is(context.Cause(ctx), errExportLimit) {
+		logging.FromContext(r.Context()).Info("Event export stopped at the row limit", "topic", topic, "rows", rows)
+		err = nil
+	}
+	finishExport(r, "events", writer, err)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/constants"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/analytics"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/logging"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+// configuredGoals returns the conversion goals set in GOALS
+func configuredGoals() []models.ConversionGoal {
+	goals, err := analytics.ParseGoals(constants.Goals)
+	if err != nil {
+		logging.Warn("Invalid GOALS, no goals configured", "error", err)
+	}
+	return goals
+}
+
+// handleGoals manages conversion goals: GET lists them with their completions (or one
+// with ?name=), POST creates, PUT replaces and DELETE removes the ?name= goal
+func (s *Server) handleGoals(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.getGoals(w, r)
+	case http.MethodPost, http.MethodPut, http.MethodDelete:
+		s.adminAuth.middleware(s.changeGoal)(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// getGoals returns all goals and their completions, or the goal named by ?name=
+func (s *Server) getGoals(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if name := r.URL.Query().Get("name"); name != "" {
+		goal, ok := s.analyticsService.Goal(name)
+		if !ok {
+			http.Error(w, "Goal not found", http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(goal)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"goals":   s.analyticsService.Goals(),
+		"results": s.analyticsService.GetGoalMetrics(),
+	})
+}
+
+// changeGoal applies a create, update or delete and persists the result
+func (s *Server) changeGoal(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("name")
+	if r.Method != http.MethodPost && name == "" {
+		http.Error(w, "Missing name parameter", http.StatusBadRequest)
+		return
+	}
+
+	// Serialize changes so the store always receives the latest goals
+	s.goalMu.Lock()
+	defer s.goalMu.Unlock()
+
+	status := http.StatusOK
+	var (
+		goal models.ConversionGoal
+		err  error
+	)
+	switch r.Method {
+	case http.MethodPost:
+		if err := json.NewDecoder(r.Body).Decode(&goal); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+			return
+		}
+		err = s.analyticsService.CreateGoal(goal)
+		status = http.StatusCreated
+
+	case http.MethodPut:
+		if err := json.NewDecoder(r.Body).Decode(&goal); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+			return
+		}
+		if goal.Name == "" {
+			goal.Name = name
+		}
+		err = s.analyticsService.UpdateGoal(name, goal)
+
+	case http.MethodDelete:
+		err = s.analyticsService.RemoveGoal(name)
+		status = http.StatusNoContent
+	}
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := s.goalStore.SaveGoals(r.Context(), s.analyticsService.Goals()); err != nil {
+		// The change is live but will not survive a restart
+		logging.FromContext(r.Context()).Error("Failed to persist goals", "error", err)
+		http.Error(w, "Goal applied but could not be saved", http.StatusInternalServerError)
+		return
+	}
+
+	if status == http.StatusNoContent {
+		w.WriteHeader(status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(goal)
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/backpressure"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/kafka"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/spool"
+)
+
+const (
+	// A successful write this recent proves connectivity without dialing a broker
+	recentWriteWindow = 30 * time.Second
+
+	// Broker ping results are reused for this long so probes do not dial on every request
+	pingCacheTTL = 5 * time.Second
+
+	pingTimeout = 2 * time.Second
+)
+
+// Overall health states reported by /readyz and /health
+const (
+	healthHealthy   = "healthy"
+	healthDegraded  = "degraded"  // Serving, but a non-critical check is failing
+	healthUnhealthy = "unhealthy" // Not ready for traffic
+)
+
+// componentHealth is the result of one check
+type componentHealth struct {
+	Status string `json:"status"` // "up", "down" or "simulated"
+	Error  string `json:"error,omitempty"`
+}
+
+// healthReport is the readiness response body
+type healthReport struct {
+	Status  string                     `json:"status"`
+	Service string                     `json:"service"`
+	Checks  map[string]componentHealth `json:"checks"`
+	Kafka   kafka.WriteStatus          `json:"kafka"`
+	Spooled int                        `json:"spooled_events,omitempty"`
+}
+
+// healthChecker decides whether the producer can accept events
+type healthChecker struct {
+	producer     kafka.EventProducer
+	spool        *spool.Spool       // nil when spooling is disabled
+	simulated    bool               // Set in simulation mode, where Kafka is not used
+	backpressure *backpressure.Gate // nil unless BACKPRESSURE_ENABLED is set
+	draining     atomic.Bool        // Set during shutdown so load balancers stop routing traffic
+
+	pingErr  error
+	pingedAt time.Time
+	pingMu   sync.Mutex
+}
+
+// newHealthChecker creates a checker for the producer's Kafka connection and event spool
+func newHealthChecker(producer kafka.EventProducer, eventSpool *spool.Spool) *healthChecker {
+	return &healthChecker{producer: producer, spool: eventSpool}
+}
+
+// ping checks broker connectivity, reusing a recent result
+func (h *healthChecker) ping(ctx context.Context) error {
+	h.pingMu.Lock()
+	defer h.pingMu.Unlock()
+
+	if time.Since(h.pingedAt) < pingCacheTTL {
+		return h.pingErr
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+	h.pingErr = h.producer.Ping(ctx)
+	h.pingedAt = time.Now()
+	return h.pingErr
+}
+
+// report runs the checks. Kafka connectivity is critical; failing writes to a
+// reachable cluster (e.g. a missing topic) only degrade the service.
+func (h *healthChecker) report(ctx context.Context) healthReport {
+	status := h.producer.WriteStatus()
+	report := healthReport{
+		Status:  healthHealthy,
+		Service: "analytics-producer",
+		Checks:  make(map[string]componentHealth),
+		Kafka:   status,
+	}
+
+	if h.draining.Load() {
+		report.Status = healthUnhealthy
+		report.Checks["server"] = componentHealth{Status: "down", Error: "shutting down"}
+		return report
+	}
+	report.Checks["server"] = componentHealth{Status: "up"}
+	if h.simulated {
+		report.Checks["kafka"] = componentHealth{Status: "simulated"}
+		return report
+	}
+
+	// A recent successful write is enough; otherwise ask a broker
+	if !status.Failing() && time.Since(status.LastSuccess) < recentWriteWindow {
+		report.Checks["kafka"] = componentHealth{Status: "up"}
+	} else if err := h.ping(ctx); err != nil {
+		report.Status = healthUnhealthy
+		report.Checks["kafka"] = componentHealth{Status: "down", Error: err.Error()}
+	} else {
+		report.Checks["kafka"] = componentHealth{Status: "up"}
+	}
+
+	// Events are still accepted while the spool has room
+	if h.spool != nil {
+		report.Spooled = h.spool.Pending()
+		if h.spool.Full() {
+			report.Status = healthUnhealthy
+			report.Checks["spool"] = componentHealth{Status: "down", Error: "spool is full"}
+		} else {
+			report.Checks["spool"] = componentHealth{Status: "up"}
+			if report.Status == healthUnhealthy {
+				report.Status = healthDegraded
+			}
+		}
+	}
+
+	if status.Failing() {
+		report.Checks["kafka_writes"] = componentHealth{Status: "down", Error: status.LastError}
+		if report.Status == healthHealthy {
+			report.Status = healthDegraded
+		}
+	} else {
+		report.Checks["kafka_writes"] = componentHealth{Status: "up"}
+	}
+
+	// Events are rejected with 429 while consumers are behind, but the producer stays ready
+	if h.backpressure != nil {
+		if state := h.backpressure.State(); state.Throttled {
+			report.Checks["backpressure"] = componentHealth{Status: "down", Error: state.Reason}
+			if report.Status == healthHealthy {
+				report.Status = healthDegraded
+			}
+		} else {
+			report.Checks["backpressure"] = componentHealth{Status: "up"}
+		}
+	}
+
+	return report
+}
+
+// handleLiveness reports that the process is running and serving requests
+func (s *Server) handleLiveness(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":  "alive",
+		"service": "analytics-producer",
+	})
+}
+
+// handleReadiness reports whether the producer can accept events: 200 when healthy
+// or degraded, 503 when Kafka is unreachable or the server is shutting down
+func (s *Server) handleReadiness(w http.ResponseWriter, r *http.Request) {
+	report := s.health.report(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if report.Status == healthUnhealthy {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/constants"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/ingest"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/logging"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/privacy"
+)
+
+// writeIngestError responds with err, asking clients to back off when throttled or
+// when Kafka is unavailable
+func writeIngestError(w http.ResponseWriter, err *ingest.Error) {
+	if err.Status == http.StatusTooManyRequests || err.Status == http.StatusServiceUnavailable {
+		w.Header().Set("Retry-After", strconv.Itoa(max(int(err.RetryAfter/time.Second), 1)))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(err.Status)
+	json.NewEncoder(w).Encode(err)
+}
+
+// backpressureError rejects events while consumers report they are falling behind, so
+// clients retry later instead of growing the backlog
+func (s *Server) backpressureError() *ingest.Error {
+	state := s.backpressure.State()
+	if !state.Throttled {
+		return nil
+	}
+	err := ingest.Errorf(http.StatusTooManyRequests, "backpressure", "Pipeline is overloaded, retry later: %s", state.Reason)
+	err.RetryAfter = state.RetryAfter
+	return err
+}
+
+// readIngestBody reads a request body of at most maxBytes whose JSON nests no deeper
+// than maxDepth. Bodies compressed with an enabled Content-Encoding are decoded first,
+// and the limit applies to both the compressed and the decoded body. Bodies over the
+// limit are cut off unread, and the connection closed.
+func readIngestBody(w http.ResponseWriter, r *http.Request, maxBytes int64, maxDepth int) ([]byte, *ingest.Error) {
+	advertiseIngestLimits(w, maxBytes)
+	if r.ContentLength > maxBytes {
+		return nil, &ingest.Error{Status: http.StatusRequestEntityTooLarge, Code: "body_too_large",
+			Message: fmt.Sprintf("Request body exceeds %d bytes", maxBytes), Limit: maxBytes}
+	}
+
+	wire := &wireReader{r: http.MaxBytesReader(w, r.Body, maxBytes)}
+	body, err := func() ([]byte, error) {
+		decoded, err := decodeBody(r, wire)
+		if err != nil {
+			return nil, err
+		}
+		defer decoded.Close()
+		return readDecoded(decoded, maxBytes)
+	}()
+	if err != nil {
+		// Errors of the connection take precedence over the decoder's view of them
+		if wire.err != nil {
+			err = wire.err
+		}
+		var tooLarge *http.MaxBytesError
+		switch {
+		case errors.As(err, &tooLarge):
+			return nil, &ingest.Error{Status: http.StatusRequestEntityTooLarge, Code: "body_too_large",
+				Message: fmt.Sprintf("Request body exceeds %d bytes", maxBytes), Limit: maxBytes}
+		case errors.Is(err, os.ErrDeadlineExceeded):
+			return nil, ingest.Errorf(http.StatusRequestTimeout, "request_timeout", "Request body was not received in time")
+		case errors.Is(err, errUnsupportedEncoding):
+			return nil, ingest.Errorf(http.StatusUnsupportedMediaType, "unsupported_encoding",
+				"Content-Encoding %q is not supported", r.Header.Get("Content-Encoding"))
+		case wire.err == nil && r.Header.Get("Content-Encoding") != "":
+			return nil, ingest.Errorf(http.StatusBadRequest, "invalid_encoding", "Request body is not valid %s: %v",
+				r.Header.Get("Content-Encoding"), err)
+		default:
+			return nil, ingest.Errorf(http.StatusBadRequest, "invalid_body", "Failed to read request body: %v", err)
+		}
+	}
+	if len(body) == 0 {
+		return nil, ingest.Errorf(http.StatusBadRequest, "invalid_json", "Request body is empty")
+	}
+	if depth := jsonDepth(body, maxDepth); depth > maxDepth {
+		return nil, &ingest.Error{Status: http.StatusBadRequest, Code: "too_deep",
+			Message: fmt.Sprintf("JSON nests deeper than %d levels", maxDepth), Limit: int64(maxDepth)}
+	}
+	return body, nil
+}
+
+// jsonDepth returns the deepest nesting of objects and arrays in data, stopping early
+// once it exceeds limit. Malformed JSON is left for the decoder to reject.
+func jsonDepth(data []byte, limit int) int {
+	depth, deepest := 0, 0
+	inString, escaped := false, false
+	for _, c := range data {
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+		switch c {
+		case '"':
+			inString = true
+		case '{', '[':
+			depth++
+			if depth > deepest {
+				deepest = depth
+				if deepest > limit {
+					return deepest
+				}
+			}
+		case '}', ']':
+			depth--
+		}
+	}
+	return deepest
+}
+
+// withReadTimeout gives ingestion requests INGEST_READ_TIMEOUT_SECONDS to send their
+// body, so slow clients release their connection before the server-wide read timeout
+func withReadTimeout(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if constants.IngestReadTimeoutSeconds > 0 {
+			deadline := time.Now().Add(time.Duration(constants.IngestReadTimeoutSeconds) * time.Second)
+			// Not every ResponseWriter supports deadlines, e.g. in tests; the server's applies then
+			if err := http.NewResponseController(w).SetReadDeadline(deadline); err != nil && !errors.Is(err, http.ErrNotSupported) {
+				logging.FromContext(r.Context()).Debug("Failed to set read deadline", "error", err)
+			}
+		}
+		next(w, r)
+	}
+}
+
+// batchResult reports the outcome of one event of a batch
+type batchResult struct {
+	Index  int    `json:"index"`
+	ID     string `json:"id,omitempty"`
+	Status string `json:"status"` // accepted, sampled_out, dropped_bot or rejected
+	Code   string `json:"code,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// handleEventBatch ingests a JSON array of events. Each event goes through the same
+// checks as /event and is accepted or rejected on its own; the response reports the
+// outcome of every event by its index in the array.
+func (s *Server) handleEventBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if constants.RespectDoNotTrack && privacy.DoNotTrack(r) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]string{"status": "not_tracked"})
+		return
+	}
+
+	if ingestErr := s.backpressureError(); ingestErr != nil {
+		writeIngestError(w, ingestErr)
+		return
+	}
+
+	w.Header().Set("X-Max-Batch-Events", strconv.Itoa(constants.MaxBatchEvents))
+
+	// The array adds one level of nesting to each event
+	body, ingestErr := readIngestBody(w, r, int64(constants.MaxBatchBytes), constants.MaxJSONDepth+1)
+	if ingestErr != nil {
+		s.metaEmitter.Emit(models.OperationIngestError, map[string]interface{}{"error": ingestErr.Message})
+		writeIngestError(w, ingestErr)
+		return
+	}
+
+	var raw []json.RawMessage
+	if err := json.Unmarshal(body, &raw); err != nil {
+		s.quality.Malformed(time.Now())
+		s.metaEmitter.Emit(models.OperationIngestError, map[string]interface{}{"error": err.Error()})
+		writeIngestError(w, ingest.Errorf(http.StatusBadRequest, "invalid_json", "Request body must be a JSON array of events: %v", err))
+		return
+	}
+	if len(raw) == 0 {
+		writeIngestError(w, ingest.Errorf(http.StatusBadRequest, "empty_batch", "Batch contains no events"))
+		return
+	}
+	if len(raw) > constants.MaxBatchEvents {
+		writeIngestError(w, &ingest.Error{Status: http.StatusRequestEntityTooLarge, Code: "too_many_events",
+			Message: fmt.Sprintf("Batch contains more than %d events", constants.MaxBatchEvents), Limit: int64(constants.MaxBatchEvents)})
+		return
+	}
+
+	results := make([]batchResult, len(raw))
+	accepted, serverError := 0, false
+	for i, message := range raw {
+		results[i] = batchResult{Index: i}
+		var event models.AnalyticsEvent
+		if err := json.Unmarshal(message, &event); err != nil {
+			s.quality.Malformed(time.Now())
+			s.metaEmitter.Emit(models.OperationIngestError, map[string]interface{}{"error": err.Error()})
+			results[i].Status, results[i].Code, results[i].Error = "rejected", "invalid_json", fmt.Sprintf("Invalid event: %v", err)
+			continue
+		}
+
+		status, ingestErr := s.ingestEvent(r, &event)
+		results[i].ID = event.ID
+		if ingestErr != nil {
+			results[i].Status, results[i].Code, results[i].Error = "rejected", ingestErr.Code, ingestErr.Message
+			serverError = serverError || ingestErr.Status >= http.StatusInternalServerError
+			continue
+		}
+		results[i].Status = status
+		accepted++
+	}
+
+	// Events that failed to send can be retried by index; the others must not be resent
+	code := http.StatusAccepted
+	if serverError {
+		code = http.StatusInternalServerError
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"accepted": accepted,
+		"rejected": len(raw) - accepted,
+		"results":  results,
+	})
+}
@@ -3,40 +3,321 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
-	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/Hilina-t/go-kafka-analytics-pipeline/constants"
 	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/analytics"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/backpressure"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/bots"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/bus"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/features"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/hll"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/ingest"
 	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/kafka"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/logging"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/meta"
 	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/notify"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/privacy"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/quality"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/ratelimit"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/reload"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/replay"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/sampling"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/scaling"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/sessions"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/shadow"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/sharedstate"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/simulate"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/sinks"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/spool"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/store"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/webhooks"
 	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/websocket"
-	"github.com/google/uuid"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/utils"
 )
 
 type Server struct {
-	producer         *kafka.Producer
+	producer         kafka.EventProducer
+	spool            *spool.Spool // nil unless SPOOL_DIR is set
+	health           *healthChecker
+	router           *kafka.Router
 	analyticsService *analytics.Service
 	wsHub            *websocket.Hub
+	snapshotCache    *analytics.SnapshotCache // Public stats
+	analyticsCache   *analytics.SnapshotCache // /analytics and WebSocket broadcasts
+	history          *analytics.History
+	alertStore       store.AlertConfigStore
+	alertConfigMu    sync.Mutex // Serializes alert config changes and saves
+	alertLogStore    store.AlertLogStore
+	dashboardStore   store.DashboardStore
+	dashboardMu      sync.Mutex // Serializes dashboard changes and saves
+	goalStore        store.GoalStore
+	goalMu           sync.Mutex // Serializes goal changes and saves
+	replayStore      replay.Store
+	sessionStore     sessions.Store // nil unless SESSION_STORE_DIR is set
+	publicSites      map[string]bool
+	publicLimiter    *ratelimit.Limiter
+	ingestAuth       *apiKeyAuth
+	adminAuth        *apiKeyAuth
+	cors             *corsPolicy
+	sampler          *sampling.Sampler
+	botPolicy        bots.Policy
+	botDetector      *bots.Detector
+	scrubber         *privacy.Scrubber // nil when no anonymization is configured
+	allowedDomains   analytics.URLRules
+	rejectDomains    bool // Events for hosts outside allowedDomains are rejected, not counted apart
+	webhookAdapters  map[string]webhooks.Adapter
+	rebuilds         rebuildTracker
+	formatOptions    analytics.FormatOptions
+	metaEmitter      *meta.Emitter
+	metaService      *analytics.Service
+	simulator        *simulate.Simulator // nil unless generating synthetic traffic
+	backpressure     *backpressure.Gate  // nil unless BACKPRESSURE_ENABLED is set
+	ingestChain      ingest.Chain        // Stages every ingested event passes through
+	geoHeaders       geoHeaders          // Headers enrichGeo reads the visitor location from
+	shadow           *shadow.Mirror      // nil unless SHADOW_TOPIC is set
+	shadowConsumers  shadowConsumers     // Consumers compared by /admin/shadow
+	quality          *quality.Tracker    // Data quality of received events
+	ingestRate       *scaling.RateMeter  // Events received per second, for autoscalers
+	notifier         *notify.Scheduler   // nil unless WEBHOOK_URLS is set
+	features         *features.Flags
+	reloader         *reload.Reloader
 	port             string
 }
 
-func NewServer(producer *kafka.Producer, port string) *Server {
-	analyticsService := analytics.NewService()
-	wsHub := websocket.NewHub(analyticsService)
+func NewServer(producer kafka.EventProducer, eventSpool *spool.Spool, router *kafka.Router, historyStore store.Store, alertStore store.AlertConfigStore, alertLogStore store.AlertLogStore, dashboardStore store.DashboardStore, goalStore store.GoalStore, replayStore replay.Store, metaEmitter *meta.Emitter, port string) *Server {
+	analyticsService := analytics.NewServiceWithRetention(retentionConfig())
+	featureStates, err := features.Parse(constants.FeatureFlags)
+	if err != nil {
+		logging.Warn("Invalid FEATURE_FLAGS, using the defaults", "error", err)
+	}
+	featureFlags := features.New()
+	featureFlags.Set(featureStates)
+	analyticsService.SetFeatures(featureFlags)
+	analyticsService.SetEventTime(analytics.EventTimeConfig{
+		MaxOutOfOrder:   time.Duration(constants.WatermarkDelaySeconds) * time.Second,
+		AllowedLateness: time.Duration(constants.AllowedLatenessHours) * time.Hour,
+	})
+	botPolicy, err := bots.ResolvePolicy(constants.BotPolicy, constants.ExcludeBots)
+	if err != nil {
+		logging.Warn("Invalid BOT_POLICY", "using", botPolicy, "error", err)
+	}
+	botDetector, err := bots.NewDetector(bots.Config{UserAgentPatterns: constants.BotUserAgents, IPRanges: constants.BotIPRanges})
+	if err != nil {
+		logging.Warn("Invalid BOT_IP_RANGES, using the default detector", "error", err)
+		botDetector, _ = bots.NewDetector(bots.Config{})
+	}
+	analyticsService.SetBotPolicy(botPolicy, botDetector)
+	qualityTracker := quality.NewTracker(qualityConfig())
+	analyticsService.SetQualityTracker(qualityTracker)
+	analyticsService.SetCampaignGoal(analytics.ParseGoal(constants.CampaignGoal))
+	channelRules, err := analytics.ParseChannelRules(constants.ChannelRules)
+	if err != nil {
+		logging.Warn("Invalid CHANNEL_RULES, using the built-in channels", "error", err)
+	}
+	analyticsService.SetChannelRules(channelRules)
+	analyticsService.SetCommerceCurrency(constants.CommerceCurrency)
+	analyticsService.SetUniqueCounting(hll.Config{
+		Threshold: constants.UniqueExactThreshold,
+		Precision: uint8(constants.UniqueHLLPrecision),
+	})
+	analyticsService.SetHeatmapOptions(constants.HeatmapGridSize, constants.HeatmapMaxPages)
+	analyticsService.SetTopLimits(constants.TopPagesLimit, constants.TopSourcesLimit)
+	analyticsService.SetURLRules(analytics.URLRules{
+		Normalize:      constants.NormalizePageURLs,
+		KeepParams:     constants.PageURLKeepParams,
+		VariantParams:  constants.PageURLVariantParams,
+		AllowedDomains: constants.AllowedDomains,
+	})
+	rejectDomains := strings.EqualFold(constants.DomainPolicy, "reject")
+	if !rejectDomains && !strings.EqualFold(constants.DomainPolicy, "bucket") {
+		logging.Warn("Invalid DOMAIN_POLICY, counting events for other hosts apart", "policy", constants.DomainPolicy)
+	}
+
+	// Report the counters the consumer replicas share; the producer's own counts
+	// would repeat the consumers' events, so it only reads them
+	if constants.SharedAnalytics {
+		connectCtx, connectCancel := context.WithTimeout(context.Background(), 10*time.Second)
+		sharedState, err := sharedstate.NewRedisState(connectCtx, constants.RedisURL, sharedstate.Options{})
+		connectCancel()
+		if err != nil {
+			logging.Fatal("Failed to connect to shared analytics state", "error", err)
+		}
+		// Kept open for the life of the server
+		analyticsService.SetSharedState(sharedState, false)
+	}
+
+	formatOptions := analytics.FormatOptions{
+		Precision:    constants.SnapshotPrecision,
+		LoadTimeUnit: constants.SnapshotLoadTimeUnit,
+		Locale:       constants.SnapshotLocale,
+	}
+	if err := formatOptions.Validate(); err != nil {
+		logging.Warn("Invalid snapshot format configuration, using defaults", "error", err)
+		formatOptions = analytics.DefaultFormatOptions()
+	}
+
+	analyticsCache := analytics.NewSnapshotCache(analyticsService, time.Duration(constants.AnalyticsCacheSeconds)*time.Second)
+	wsHub := websocket.NewHub(analyticsService, formatOptions)
+	wsHub.SetSnapshotCache(analyticsCache)
+	wsHub.SetAuth(websocket.AuthConfig{
+		AllowedOrigins: constants.WSAllowedOrigins,
+		ReadTokens:     constants.WSReadTokens,
+		AdminTokens:    constants.WSAdminTokens,
+		JWTSecret:      constants.WSJWTSecret,
+	})
+	wsHub.SetConfig(websocket.HubConfig{
+		SnapshotInterval:       time.Duration(constants.WSSnapshotIntervalSeconds) * time.Second,
+		ActiveVisitorsInterval: time.Duration(constants.WSActiveVisitorsSeconds) * time.Second,
+		PingPeriod:             time.Duration(constants.WSPingPeriodSeconds) * time.Second,
+		EventRateLimit:         constants.WSEventRateLimit,
+		BroadcastBufferSize:    constants.WSBroadcastBufferSize,
+		QueueSize:              constants.WSClientQueueSize,
+	})
+
+	// Restore alert configurations managed through /alerts/config
+	if err := analyticsService.LoadAlerts(context.Background(), alertStore); err != nil {
+		logging.Warn("Failed to load saved alert configs, using defaults", "error", err)
+		analyticsService.SetAlerts(analytics.DefaultAlerts())
+	}
+
+	// Restore the alerts fired before the last restart, for /alerts/recent and new dashboard clients
+	if err := analyticsService.LoadRecentAlerts(context.Background(), alertLogStore); err != nil {
+		logging.Warn("Failed to load recent alerts", "error", err)
+	}
+
+	// Restore dashboards managed through /dashboards
+	if err := analyticsService.LoadDashboards(context.Background(), dashboardStore); err != nil {
+		logging.Warn("Failed to load saved dashboards", "error", err)
+	}
+
+	// Restore conversion goals managed through /goals, or those configured in GOALS
+	if err := analyticsService.LoadGoals(context.Background(), goalStore, configuredGoals()); err != nil {
+		logging.Warn("Failed to load saved goals", "error", err)
+	}
+	analyticsService.SubscribeGoals(wsHub.BroadcastGoalCompletion)
+
+	customRules, err := analytics.ParseCustomMetricRules(constants.CustomMetrics)
+	if err != nil {
+		logging.Warn("Invalid CUSTOM_METRICS, no custom metrics registered", "error", err)
+	}
+	for _, rule := range customRules {
+		analyticsService.RegisterCustomMetric(rule)
+	}
+
+	experimentGoals, err := analytics.ParseExperimentGoals(constants.ExperimentGoals)
+	if err != nil {
+		logging.Warn("Invalid EXPERIMENT_GOALS, using the default click goal", "error", err)
+	}
+	for experimentID, goal := range experimentGoals {
+		analyticsService.SetExperimentGoal(experimentID, goal)
+	}
+
+	ipMode, err := privacy.ParseIPMode(constants.PrivacyIPMode)
+	if err != nil {
+		logging.Warn("Invalid PRIVACY_IP_MODE, keeping IP addresses", "error", err)
+	}
+	privacyConfig := privacy.Config{
+		IPMode:        ipMode,
+		HashUserIDs:   constants.PrivacyHashUserIDs,
+		SaltSecret:    constants.PrivacySaltSecret,
+		SaltRotation:  time.Duration(constants.PrivacySaltRotationHours) * time.Hour,
+		StripMetadata: constants.PrivacyStripMetadata,
+	}
+	var scrubber *privacy.Scrubber
+	if privacyConfig.Enabled() {
+		scrubber = privacy.NewScrubber(privacyConfig)
+	}
+
+	samplingRules, err := sampling.ParseRules(constants.SamplingRules)
+	if err != nil {
+		logging.Warn("Invalid SAMPLING_RULES, keeping all events", "error", err)
+	}
+
+	var notifier *notify.Scheduler
+	if len(constants.WebhookURLs) > 0 {
+		notifier, err = newNotifier(analyticsService)
+		if err != nil {
+			logging.Warn("Invalid webhook configuration, webhooks disabled", "error", err)
+		}
+	}
+
+	history := analytics.NewHistory(analyticsService, historyStore)
+	history.SetRetention(analytics.RollupRetention{
+		Hour:  time.Duration(constants.RollupHourlyRetentionDays) * 24 * time.Hour,
+		Day:   time.Duration(constants.RollupDailyRetentionDays) * 24 * time.Hour,
+		Week:  time.Duration(constants.RollupWeeklyRetentionDays) * 24 * time.Hour,
+		Month: time.Duration(constants.RollupMonthlyRetentionDays) * 24 * time.Hour,
+	})
+	switch constants.HistoryBackend {
+	case "clickhouse":
+		timeout := time.Duration(constants.HistoryQueryTimeoutSeconds) * time.Second
+		history.SetWarehouse(sinks.NewClickHouseWarehouse(constants.ClickHouseURL, constants.ClickHouseDatabase, timeout))
+	case "memory", "":
+	default:
+		logging.Warn("Unknown HISTORY_BACKEND, using in-memory history", "backend", constants.HistoryBackend)
+	}
 
-	return &Server{
+	publicSites := make(map[string]bool)
+	for _, site := range constants.PublicStatsSites {
+		publicSites[strings.TrimPrefix(strings.ToLower(site), "www.")] = true
+	}
+
+	s := &Server{
 		producer:         producer,
+		spool:            eventSpool,
+		health:           newHealthChecker(producer, eventSpool),
+		router:           router,
 		analyticsService: analyticsService,
 		wsHub:            wsHub,
+		snapshotCache:    analytics.NewSnapshotCache(analyticsService, time.Duration(constants.PublicStatsCacheSeconds)*time.Second),
+		analyticsCache:   analyticsCache,
+		history:          history,
+		alertStore:       alertStore,
+		alertLogStore:    alertLogStore,
+		dashboardStore:   dashboardStore,
+		goalStore:        goalStore,
+		replayStore:      replayStore,
+		publicSites:      publicSites,
+		publicLimiter:    ratelimit.NewLimiter(constants.PublicStatsRateLimit, constants.PublicStatsRateLimit),
+		ingestRate:       scaling.NewRateMeter(scaling.DefaultWindow),
+		formatOptions:    formatOptions,
+		ingestAuth:       newAPIKeyAuth(constants.IngestAPIKeys, constants.IngestRateLimit, constants.IngestRateBurst),
+		adminAuth:        newAdminAuth(constants.AdminAPIKeys, constants.IngestRateLimit, constants.IngestRateBurst),
+		sampler:          sampling.NewSampler(samplingRules),
+		botPolicy:        botPolicy,
+		botDetector:      botDetector,
+		scrubber:         scrubber,
+		allowedDomains:   analytics.URLRules{AllowedDomains: constants.AllowedDomains},
+		rejectDomains:    rejectDomains,
+		webhookAdapters:  newWebhookAdapters(),
+		cors:             newCORSPolicy(constants.CORSAllowedOrigins, constants.CORSAllowedMethods, constants.CORSAllowedHeaders, constants.CORSMaxAgeSeconds),
+		metaEmitter:      metaEmitter,
+		metaService:      newMetaService(),
+		notifier:         notifier,
+		features:         featureFlags,
+		geoHeaders:       geoHeaders{country: constants.GeoCountryHeader, region: constants.GeoRegionHeader},
+		shadow:           shadow.NewMirror(constants.ShadowTopic, constants.ShadowPercent, constants.ShadowQueueSize),
+		shadowConsumers:  newShadowConsumers(constants.ShadowPrimaryStatsURL, constants.ShadowStatsURL),
+		quality:          qualityTracker,
 		port:             port,
 	}
+	s.reloader = s.newReloader()
+	s.useIngestStages(constants.IngestMiddleware)
+	return s
 }
 
 func (s *Server) handleEvent(w http.ResponseWriter, r *http.Request) {
@@ -45,52 +326,49 @@ func (s *Server) handleEvent(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var event models.AnalyticsEvent
-	if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
-		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+	// Visitors who opted out are acknowledged without their event being read
+	if constants.RespectDoNotTrack && privacy.DoNotTrack(r) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]string{"status": "not_tracked"})
 		return
 	}
 
-	// Set ID and timestamp if not provided
-	if event.ID == "" {
-		event.ID = uuid.New().String()
-	}
-	if event.Timestamp.IsZero() {
-		event.Timestamp = time.Now()
+	// Consumers falling behind ask clients to back off before the body is read
+	if ingestErr := s.backpressureError(); ingestErr != nil {
+		writeIngestError(w, ingestErr)
+		return
 	}
 
-	ctx := context.Background()
-	if err := s.producer.SendEvent(ctx, event.ID, event); err != nil {
-		log.Printf("Failed to send event: %v", err)
-		http.Error(w, "Failed to send event", http.StatusInternalServerError)
+	body, ingestErr := readIngestBody(w, r, int64(constants.MaxEventBytes), constants.MaxJSONDepth)
+	if ingestErr != nil {
+		s.metaEmitter.Emit(models.OperationIngestError, map[string]interface{}{"error": ingestErr.Message})
+		writeIngestError(w, ingestErr)
 		return
 	}
 
-	// Process event for real-time analytics
-	if err := s.analyticsService.ProcessEvent(&event); err != nil {
-		log.Printf("Failed to process analytics event: %v", err)
+	var event models.AnalyticsEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		s.quality.Malformed(time.Now())
+		s.metaEmitter.Emit(models.OperationIngestError, map[string]interface{}{"error": err.Error()})
+		writeIngestError(w, ingest.Errorf(http.StatusBadRequest, "invalid_json", "Invalid request body: %v", err))
+		return
 	}
 
-	// Broadcast event to WebSocket clients
-	s.wsHub.BroadcastEvent(&event)
+	status, ingestErr := s.ingestEvent(r, &event)
+	if ingestErr != nil {
+		writeIngestError(w, ingestErr)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusAccepted)
 	json.NewEncoder(w).Encode(map[string]string{
-		"status": "accepted",
+		"status": status,
 		"id":     event.ID,
 	})
 }
 
-func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{
-		"status":  "healthy",
-		"service": "analytics-producer",
-	})
-}
-
 func (s *Server) handleDashboard(w http.ResponseWriter, r *http.Request) {
 	// Serve the dashboard HTML file
 	dashboardPath := filepath.Join("web", "dashboard.html")
@@ -98,42 +376,527 @@ func (s *Server) handleDashboard(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handleAnalytics(w http.ResponseWriter, r *http.Request) {
-	snapshot := s.analyticsService.GetSnapshot()
+	formatOptions, err := analytics.ParseFormatOptions(r.URL.Query(), s.formatOptions)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid format options: %v", err), http.StatusBadRequest)
+		return
+	}
+	limit, err := analytics.ParseTopLimit(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Snapshots are computed at most once per ANALYTICS_CACHE_SECONDS; clients holding the
+	// current one are answered without formatting or encoding it again
+	cached, version := s.analyticsCache.GetTop(limit)
+	etag := snapshotETag(version, limit, formatOptions)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Last-Modified", version.Modified.UTC().Format(http.TimeFormat))
+	w.Header().Set("Cache-Control", fmt.Sprintf("max-age=%d", int(s.analyticsCache.TTL()/time.Second)))
+	if notModified(r, etag, version.Modified) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	snapshot := analytics.FormatSnapshot(cached, formatOptions)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(snapshot)
 }
 
+// snapshotETag returns a weak validator for a snapshot served with the given limit and
+// format. It is weak because the snapshot's timestamp changes without its content.
+func snapshotETag(version analytics.SnapshotVersion, limit int, format analytics.FormatOptions) string {
+	return fmt.Sprintf(`W/"%016x-%d-%d-%s-%s"`, version.Fingerprint, limit, format.Precision, format.LoadTimeUnit, format.Locale)
+}
+
+// notModified evaluates a request's If-None-Match, or when absent its If-Modified-Since,
+// against the current validators (RFC 9110 section 13.2.2)
+func notModified(r *http.Request, etag string, modified time.Time) bool {
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		for _, candidate := range strings.Split(match, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	return err == nil && !modified.Truncate(time.Second).After(since)
+}
+
+func (s *Server) handleAnalyticsHistory(w http.ResponseWriter, r *http.Request) {
+	from, to, granularity, err := parseHistoryRange(r.URL.Query())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid history query: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	rollups, source, err := s.history.Rollups(r.Context(), from, to, granularity)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid history query: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"from":        from,
+		"to":          to,
+		"granularity": granularity,
+		"source":      source,
+		"data":        rollups,
+	})
+}
+
+// parseHistoryRange reads the from, to and granularity parameters of a history query,
+// defaulting to the last 24 hours at hourly granularity
+func parseHistoryRange(query url.Values) (time.Time, time.Time, models.Granularity, error) {
+	to := time.Now()
+	from := to.Add(-24 * time.Hour)
+	granularity := models.GranularityHour
+
+	if v := query.Get("from"); v != "" {
+		parsed, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return from, to, granularity, fmt.Errorf("invalid from parameter, expected RFC3339")
+		}
+		from = parsed
+	}
+	if v := query.Get("to"); v != "" {
+		parsed, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return from, to, granularity, fmt.Errorf("invalid to parameter, expected RFC3339")
+		}
+		to = parsed
+	}
+	if v := query.Get("granularity"); v != "" {
+		granularity = models.Granularity(v)
+	}
+	return from, to, granularity, nil
+}
+
+// handleAlerts lists active alerts and the history of resolved alerts
+func (s *Server) handleAlerts(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"active":  s.analyticsService.GetActiveAlerts(),
+		"history": s.analyticsService.GetAlertHistory(),
+	})
+}
+
+// handleRecentAlerts lists the latest state of recently fired alerts, most recent first.
+// ?limit= caps the list and ?unresolved=true leaves out resolved alerts.
+func (s *Server) handleRecentAlerts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	limit := 0
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+	}
+	unresolved := r.URL.Query().Get("unresolved") == "true"
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"alerts": s.analyticsService.RecentAlerts(limit, unresolved),
+	})
+}
+
+// handleExperiments returns live results for all experiments, or for one with ?id=
+func (s *Server) handleExperiments(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if id := r.URL.Query().Get("id"); id != "" {
+		result, ok := s.analyticsService.GetExperimentResult(id)
+		if !ok {
+			http.Error(w, "Experiment not found", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(result)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"experiments": s.analyticsService.GetExperimentResults(),
+	})
+}
+
+// handleCustomMetrics lists custom metric values, or registers a rule on POST
+func (s *Server) handleCustomMetrics(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(s.analyticsService.GetCustomMetrics())
+	case http.MethodPost:
+		s.adminAuth.middleware(s.registerCustomMetric)(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// registerCustomMetric registers a custom metric rule from the request body
+func (s *Server) registerCustomMetric(w http.ResponseWriter, r *http.Request) {
+	var rule models.CustomMetricRule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err := s.analyticsService.RegisterCustomMetric(rule); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(rule)
+}
+
+// newNotifier creates the webhook scheduler from the WEBHOOK_* settings
+func newNotifier(analyticsService *analytics.Service) (*notify.Scheduler, error) {
+	reports, err := notify.ParsePeriods(constants.WebhookReports)
+	if err != nil {
+		return nil, err
+	}
+	milestones, err := notify.ParseMilestones(constants.WebhookMilestones)
+	if err != nil {
+		return nil, err
+	}
+
+	config := notify.DefaultConfig()
+	config.URLs = constants.WebhookURLs
+	config.Template = constants.WebhookTemplate
+	config.Reports = reports
+	config.Milestones = milestones
+	config.TrafficRecords = constants.WebhookTrafficRecords
+	config.MaxAttempts = constants.WebhookMaxAttempts
+	return notify.New(config, analyticsService)
+}
+
+// runAlertChecks evaluates alerts every interval, pushes notifications to dashboard clients
+// and saves the recent alerts so they survive a restart
+func (s *Server) runAlertChecks(ctx context.Context, interval time.Duration) {
+	unsubscribe := s.analyticsService.SubscribeAlerts(func(alert models.Alert) {
+		logging.Info("Alert notification", "alert", alert.Name, "severity", alert.Severity, "resolved", alert.Resolved, "message", alert.Message)
+		s.wsHub.BroadcastAlert(alert)
+		if err := s.alertLogStore.SaveRecentAlerts(ctx, s.analyticsService.RecentAlerts(0, false)); err != nil {
+			logging.Warn("Failed to save recent alerts", "error", err)
+		}
+	})
+	defer unsubscribe()
+
+	s.analyticsService.RunAlerts(ctx, interval)
+}
+
+// newMetaService creates the analytics of the pipeline's meta events, counting them per
+// operation and per reporting component as custom metrics
+func newMetaService() *analytics.Service {
+	service := analytics.NewService()
+	for name, field := range map[string]string{"operations": "operation", "components": "component"} {
+		rule := models.CustomMetricRule{Name: name, EventType: models.MetaEvent, Kind: models.AggregateCountBy, Field: field}
+		if err := service.RegisterCustomMetric(rule); err != nil {
+			logging.Fatal("Invalid meta event metric", "name", name, "error", err)
+		}
+	}
+	return service
+}
+
+// handleInternalAnalytics serves the pipeline's self-monitoring view aggregated from meta events
+func (s *Server) handleInternalAnalytics(w http.ResponseWriter, r *http.Request) {
+	snapshot := s.metaService.GetSnapshot()
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(snapshot)
+}
+
+// consumeMetaEvents aggregates operational events from all pipeline components
+func (s *Server) consumeMetaEvents(ctx context.Context, consumer kafka.EventConsumer) {
+	err := consumer.ConsumeMessages(ctx, func(message *kafka.Message) error {
+		return s.metaService.ProcessEvent(message.Event)
+	})
+	if err != nil && ctx.Err() == nil {
+		logging.Error("Meta event consumer stopped", "error", err)
+	}
+}
+
+// consumeReplayEvents writes session replay chunks from the replay topic to the replay store
+func (s *Server) consumeReplayEvents(ctx context.Context, consumer kafka.EventConsumer) {
+	err := consumer.ConsumeMessages(ctx, func(message *kafka.Message) error {
+		event := message.Event
+		// Replays of erased users are deleted by the erasure request itself
+		if event.Type == models.UserErasure {
+			return nil
+		}
+
+		chunk, err := replay.ChunkFromEvent(event)
+		if err != nil {
+			// Malformed chunks can never be stored, so drop them instead of retrying
+			logging.Warn("Dropping replay event", "event_id", event.ID, "error", err)
+			return nil
+		}
+		return s.replayStore.SaveChunk(ctx, chunk)
+	})
+	if err != nil && ctx.Err() == nil {
+		logging.Error("Replay consumer stopped", "error", err)
+	}
+}
+
+// expireReplays removes replays without new chunks for longer than ttl, checking every interval
+func (s *Server) expireReplays(ctx context.Context, ttl, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			removed, err := s.replayStore.Expire(ctx, now.Add(-ttl))
+			if err != nil {
+				logging.Warn("Failed to expire session replays", "error", err)
+			} else if removed > 0 {
+				logging.Debug("Expired session replays", "sessions", removed)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// handleReplay streams a session's replay chunks as newline-delimited JSON, in sequence order
+func (s *Server) handleReplay(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.URL.Query().Get("session_id")
+	if !replay.ValidSessionID(sessionID) {
+		http.Error(w, "Missing or invalid session_id parameter", http.StatusBadRequest)
+		return
+	}
+
+	logger := logging.FromContext(r.Context()).With("session_id", sessionID)
+	chunks, err := s.replayStore.SessionChunks(r.Context(), sessionID)
+	if err != nil {
+		logger.Error("Failed to read replay", "error", err)
+		http.Error(w, "Failed to read replay", http.StatusInternalServerError)
+		return
+	}
+	if len(chunks) == 0 {
+		http.Error(w, "No replay recorded for session", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+
+	encoder := json.NewEncoder(w)
+	for _, chunk := range chunks {
+		events, err := chunk.Events()
+		if err != nil {
+			logger.Warn("Skipping unreadable replay chunk", "sequence", chunk.Sequence, "error", err)
+			continue
+		}
+		encoder.Encode(map[string]interface{}{
+			"sequence":  chunk.Sequence,
+			"timestamp": chunk.Timestamp,
+			"events":    events,
+		})
+	}
+}
+
 func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	s.wsHub.ServeWS(w, r)
 }
 
-func (s *Server) Start(ctx context.Context) error {
-	// Start WebSocket hub in a goroutine
-	go s.wsHub.Run()
+func (s *Server) handleEventStream(w http.ResponseWriter, r *http.Request) {
+	s.wsHub.ServeSSE(w, r)
+}
+
+func (s *Server) handleSampling(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"rules": s.sampler.Rules(),
+		"stats": s.sampler.Stats(),
+	})
+}
+
+func (s *Server) handleWebSocketStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.wsHub.Stats())
+}
+
+// handleWebSocketClients lists the connected WebSocket and SSE clients, or the one named
+// by ?id=, and disconnects the client named by ?id= on DELETE
+func (s *Server) handleWebSocketClients(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		if id == "" {
+			json.NewEncoder(w).Encode(s.wsHub.Clients())
+			return
+		}
+		client, ok := s.wsHub.Client(id)
+		if !ok {
+			http.Error(w, "Client not found", http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(client)
+
+	case http.MethodDelete:
+		if id == "" {
+			http.Error(w, "Missing id parameter", http.StatusBadRequest)
+			return
+		}
+		if !s.wsHub.Disconnect(id) {
+			http.Error(w, "Client not found", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
 
+// routes registers the producer's endpoints with their middleware
+func (s *Server) routes() *http.ServeMux {
 	mux := http.NewServeMux()
-	mux.HandleFunc("/event", s.handleEvent)
-	mux.HandleFunc("/health", s.handleHealth)
+	mux.HandleFunc("/event", s.cors.middleware(s.ingestAuth.middleware(withReadTimeout(s.handleEvent))))
+	mux.HandleFunc("/events/batch", s.cors.middleware(s.ingestAuth.middleware(withReadTimeout(s.handleEventBatch))))
+	mux.HandleFunc("/collect", s.cors.middleware(s.ingestAuth.middleware(withReadTimeout(s.handleEvent))))
+	mux.HandleFunc("/webhooks/", withReadTimeout(s.handleWebhook))
+	mux.HandleFunc("/health", s.handleReadiness) // Kept for existing monitors
+	mux.HandleFunc("/healthz", s.handleLiveness)
+	mux.HandleFunc("/readyz", s.handleReadiness)
 	mux.HandleFunc("/", s.handleDashboard)
 	mux.HandleFunc("/analytics", s.handleAnalytics)
+	mux.HandleFunc("/analytics/history", s.handleAnalyticsHistory)
+	mux.HandleFunc("/analytics/history/pages", s.handleHistoryPages)
+	mux.HandleFunc("/analytics/history/funnel", s.handleHistoryFunnel)
+	mux.HandleFunc("/analytics/pages", s.handleQueryPages)
+	mux.HandleFunc("/analytics/sources", s.handleQuerySources)
+	mux.HandleFunc("/analytics/devices", s.handleQueryDevices)
+	mux.HandleFunc("/analytics/events", s.handleQueryEvents)
+	mux.HandleFunc("/analytics/heatmap", s.handleHeatmap)
+	mux.HandleFunc("/analytics/paths", s.handlePathFlow)
+	mux.HandleFunc("/analytics/active", s.handleActiveVisitors)
+	mux.HandleFunc("/analytics/quality", s.handleQuality)
+	mux.HandleFunc("/metrics", s.handleMetrics)
+	mux.HandleFunc("/scaling", s.handleScaling)
+	mux.HandleFunc("/export/pages", s.handleExportPages)
+	mux.HandleFunc("/export/sources", s.handleExportSources)
+	mux.HandleFunc("/export/hourly", s.handleExportHourly)
+	mux.HandleFunc("/export/events", s.adminAuth.middleware(s.handleExportEvents))
 	mux.HandleFunc("/ws", s.handleWebSocket)
+	mux.HandleFunc("/events/stream", s.handleEventStream)
+	mux.HandleFunc("/ws/stats", s.ingestAuth.middleware(s.handleWebSocketStats))
+	mux.HandleFunc("/ws/clients", splitAuth(http.MethodDelete, s.adminAuth, s.ingestAuth, s.handleWebSocketClients))
+	mux.HandleFunc("/badge/visitors.svg", s.handleVisitorsBadge)
+	mux.HandleFunc("/public/stats", s.handlePublicStats)
+	mux.HandleFunc("/internal/analytics", s.handleInternalAnalytics)
+	mux.HandleFunc("/alerts", s.handleAlerts)
+	mux.HandleFunc("/alerts/config", s.handleAlertConfigs)
+	mux.HandleFunc("/alerts/recent", s.handleRecentAlerts)
+	mux.HandleFunc("/experiments", s.handleExperiments)
+	mux.HandleFunc("/custom-metrics", s.handleCustomMetrics)
+	mux.HandleFunc("/dashboards", s.handleDashboards)
+	mux.HandleFunc("/dashboards/data", s.handleDashboardData)
+	mux.HandleFunc("/goals", s.handleGoals)
+	mux.HandleFunc("/replay", s.adminAuth.middleware(s.handleReplay))
+	mux.HandleFunc("/sessions/", s.adminAuth.middleware(s.handleSessionTimeline))
+	mux.HandleFunc("/privacy/erase", s.adminAuth.middleware(s.handleErasure))
+	mux.HandleFunc("/admin/reset", s.adminAuth.middleware(s.handleAdminReset))
+	mux.HandleFunc("/admin/rebuild", s.adminAuth.middleware(s.handleAdminRebuild))
+	mux.HandleFunc("/admin/reload", s.adminAuth.middleware(s.handleAdminReload))
+	mux.HandleFunc("/admin/rollups", s.adminAuth.middleware(s.handleAdminRollups))
+	mux.HandleFunc("/admin/shadow", s.adminAuth.middleware(s.handleAdminShadow))
+	mux.HandleFunc("/admin/features", s.handleFeatures)
+	mux.HandleFunc("/sampling", s.handleSampling)
+	return mux
+}
+
+func (s *Server) Start(ctx context.Context) error {
+	// Start WebSocket hub in a goroutine
+	go s.wsHub.Run()
+
+	// Evaluate alerts and notify dashboard clients
+	go s.runAlertChecks(ctx, time.Duration(constants.AlertCheckSeconds)*time.Second)
+
+	// Expire old sessions, hourly data and recent events
+	go s.analyticsService.RunCleanup(ctx)
+	go s.metaService.RunCleanup(ctx)
+
+	// Send events spooled during Kafka outages once brokers recover
+	if s.spool != nil {
+		go s.runSpoolDrain(ctx, time.Duration(constants.SpoolDrainSeconds)*time.Second)
+	}
+
+	// Persist hourly rollups for historical queries
+	go s.history.Run(ctx, time.Duration(constants.HistoryFlushSeconds)*time.Second)
+
+	// Summarize hours into days, weeks and months and expire old rollups
+	go s.history.RunRollups(ctx, time.Duration(constants.RollupIntervalMinutes)*time.Minute)
+
+	// Post scheduled reports and milestones to webhooks
+	if s.notifier != nil {
+		go s.notifier.Run(ctx, time.Duration(constants.WebhookCheckSeconds)*time.Second)
+	}
+
+	// Apply configuration changes on SIGHUP
+	go s.reloader.Run(ctx)
+
+	// Follow the consumers' back-pressure state
+	if s.backpressure != nil {
+		go s.backpressure.Run(ctx, time.Duration(constants.BackpressureCheckSeconds)*time.Second)
+	}
+
+	// Write mirrored events to the shadow topic
+	if s.shadow != nil {
+		go s.shadow.Run(ctx, s.sendShadow)
+	}
+
+	// Generate synthetic traffic in place of trackers
+	if s.simulator != nil {
+		go s.runSimulation(ctx, s.simulator, time.Duration(constants.SimulateBackfillHours)*time.Hour)
+	}
 
 	server := &http.Server{
-		Addr:         ":" + s.port,
-		Handler:      mux,
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 15 * time.Second,
-		IdleTimeout:  60 * time.Second,
+		Addr:              ":" + s.port,
+		Handler:           withRequestID(s.routes()),
+		ReadHeaderTimeout: time.Duration(constants.HTTPReadHeaderTimeoutSeconds) * time.Second,
+		ReadTimeout:       time.Duration(constants.HTTPReadTimeoutSeconds) * time.Second,
+		WriteTimeout:      time.Duration(constants.HTTPWriteTimeoutSeconds) * time.Second,
+		IdleTimeout:       time.Duration(constants.HTTPIdleTimeoutSeconds) * time.Second,
+		MaxHeaderBytes:    constants.HTTPMaxHeaderBytes,
 	}
 
 	// Start server in a goroutine
 	go func() {
-		log.Printf("Producer server starting on port %s", s.port)
-		log.Printf("Dashboard available at http://localhost:%s", s.port)
-		log.Printf("WebSocket endpoint: ws://localhost:%s/ws", s.port)
+		logging.Info("Producer server starting", "port", s.port,
+			"dashboard", "http://localhost:"+s.port,
+			"websocket", "ws://localhost:"+s.port+"/ws",
+			"sse", "http://localhost:"+s.port+"/events/stream")
+		if !s.ingestAuth.enabled() {
+			logging.Warn("INGEST_API_KEYS is not set, /event accepts unauthenticated requests")
+		}
+		if !s.adminAuth.enabled() {
+			logging.Warn("ADMIN_API_KEYS is not set, the admin endpoints are disabled")
+		}
+		if len(constants.WSReadTokens) == 0 && len(constants.WSAdminTokens) == 0 && constants.WSJWTSecret == "" {
+			logging.Warn("No WebSocket tokens are configured, /ws and /events/stream clients get admin access without authentication")
+		}
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Server failed: %v", err)
+			logging.Fatal("Server failed", "error", err)
 		}
 	}()
 
@@ -144,34 +907,202 @@ func (s *Server) Start(ctx context.Context) error {
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	log.Println("Shutting down server gracefully...")
+	logging.Info("Shutting down server gracefully")
+	s.health.draining.Store(true)
+
+	// Close WebSocket clients with reconnect hints; hijacked connections are not closed by server.Shutdown
+	if err := s.wsHub.Shutdown(shutdownCtx); err != nil {
+		logging.Warn("WebSocket hub shutdown incomplete", "error", err)
+	}
 	return server.Shutdown(shutdownCtx)
 }
 
 func main() {
-	// Create Kafka producer
-	producer := kafka.NewProducer([]string{constants.KafkaBrokers}, constants.KafkaTopic)
+	// Structured logs at the configured level and format
+	logger, err := logging.New(os.Stderr, logging.Config{Level: constants.LogLevel, Format: constants.LogFormat})
+	if err != nil {
+		logging.Fatal("Invalid logging configuration", "error", err)
+	}
+	logging.SetDefault(logger.With("service", "producer"))
+	if err := utils.ConfigFileError(); err != nil {
+		logging.Warn("Failed to load CONFIG_FILE, using the environment only", "error", err)
+	}
+
+	simulation := flag.Bool("simulate", constants.Simulate, "Serve the dashboard with seeded synthetic traffic, without Kafka")
+	flag.Parse()
+
+	// Connect to NATS or RabbitMQ when configured in place of Kafka
+	var messageBus bus.MessageBus
+	if !*simulation {
+		messageBus, err = openMessageBus(context.Background())
+		if err != nil {
+			logging.Fatal("Failed to connect to the message bus", "bus", constants.MessageBus, "error", err)
+		}
+		if messageBus != nil {
+			defer messageBus.Close()
+			logging.Info("Publishing events through the message bus", "bus", constants.MessageBus)
+		}
+	}
+
+	// Choose message keys and partitioning so related events stay in order
+	keyStrategy, err := kafka.ParseKeyStrategy(constants.KafkaPartitionKey)
+	if err != nil {
+		logging.Fatal("Invalid KAFKA_PARTITION_KEY", "error", err)
+	}
+
+	var producer kafka.EventProducer
+	if messageBus != nil {
+		producer = bus.NewProducer(messageBus, constants.KafkaTopic, keyStrategy)
+	} else {
+		kafkaProducer := kafka.NewProducer([]string{constants.KafkaBrokers}, constants.KafkaTopic)
+		balancer, err := kafka.NewBalancer(constants.KafkaBalancer)
+		if err != nil {
+			logging.Fatal("Invalid KAFKA_BALANCER", "error", err)
+		}
+		kafkaProducer.SetKeyStrategy(keyStrategy)
+		kafkaProducer.SetBalancer(balancer)
+
+		compression, err := kafka.ParseCompression(constants.KafkaCompression)
+		if err != nil {
+			logging.Fatal("Invalid KAFKA_COMPRESSION", "error", err)
+		}
+		kafkaProducer.SetCompression(compression)
+
+		// Retry transient write errors before failing (or spooling) the request
+		kafkaProducer.SetRetryPolicy(kafka.WriteRetryPolicy{
+			MaxAttempts:    constants.KafkaWriteAttempts,
+			InitialBackoff: time.Duration(constants.KafkaWriteBackoffMs) * time.Millisecond,
+			MaxBackoff:     time.Duration(constants.KafkaWriteMaxBackoffMs) * time.Millisecond,
+			MaxElapsed:     time.Duration(constants.KafkaWriteMaxElapsedMs) * time.Millisecond,
+		})
+		kafkaProducer.SetIdempotent(constants.KafkaIdempotent)
+		producer = kafkaProducer
+	}
 	defer producer.Close()
 
-	// Create and start server
-	server := NewServer(producer, constants.ServerPort)
+	// Route events to topics based on configured rules
+	routes, err := kafka.ParseRouteRules(constants.KafkaRoutes)
+	if err != nil {
+		logging.Fatal("Invalid KAFKA_ROUTES", "error", err)
+	}
+	// Session replay chunks always go to their own topic
+	routes = append([]kafka.RouteRule{{EventType: models.SessionReplay, Topic: constants.ReplayTopic}}, routes...)
+	router := kafka.NewRouter(constants.KafkaTopic, routes)
+
+	// Optionally buffer events on disk while Kafka is unavailable
+	var eventSpool *spool.Spool
+	if constants.SpoolDir != "" && !*simulation {
+		eventSpool, err = spool.Open(constants.SpoolDir, int64(constants.SpoolMaxMB)<<20)
+		if err != nil {
+			logging.Fatal("Failed to open event spool", "error", err)
+		}
+		defer eventSpool.Close()
+		if pending := eventSpool.Pending(); pending > 0 {
+			logging.Info("Recovered spooled events", "events", pending)
+		}
+	}
+
+	// Open the historical rollup store, which also keeps alert configurations
+	historyStore, err := store.NewFileStore(constants.HistoryStoreDir)
+	if err != nil {
+		logging.Fatal("Failed to open history store", "error", err)
+	}
+	defer historyStore.Close()
+
+	// Open the session replay store
+	replayStore, err := replay.NewFileStore(constants.ReplayStoreDir)
+	if err != nil {
+		logging.Fatal("Failed to open replay store", "error", err)
+	}
+	defer replayStore.Close()
 
 	// Handle graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	// Publish the producer's own operational events to the meta topic
+	var metaEmitter *meta.Emitter
+	if constants.MetaEventsEnabled && !*simulation {
+		metaProducer := newTopicProducer(messageBus, constants.MetaTopic)
+		defer metaProducer.Close()
+
+		metaEmitter = meta.NewEmitter(metaProducer, "producer")
+		go metaEmitter.Run(ctx)
+	}
+
+	// Simulated rollups are kept in memory so they never mix with persisted history
+	var rollupStore store.Store = historyStore
+	if *simulation {
+		rollupStore = store.NewMemoryStore()
+	}
+
+	// Create and start server
+	server := NewServer(producer, eventSpool, router, rollupStore, historyStore, historyStore, historyStore, historyStore, replayStore, metaEmitter, constants.ServerPort)
+
+	if *simulation {
+		simConfig := simulate.DefaultConfig()
+		simConfig.Seed = int64(constants.SimulateSeed)
+		simConfig.Rate = float64(constants.SimulateRate)
+		simConfig.Users = constants.SimulateUsers
+		server.simulator = simulate.New(simConfig)
+		server.health.simulated = true
+		logging.Warn("Simulation mode: generating synthetic traffic, Kafka is not used", "seed", constants.SimulateSeed, "rate", constants.SimulateRate)
+	} else {
+		// Reject events with 429 while consumers report they are falling behind
+		if constants.BackpressureEnabled {
+			backpressureStore, err := backpressure.NewRedisStore(ctx, constants.RedisURL, time.Duration(constants.BackpressureSignalTTLSeconds)*time.Second)
+			if err != nil {
+				logging.Fatal("Failed to set up back-pressure", "error", err)
+			}
+			defer backpressureStore.Close()
+			server.backpressure = backpressure.NewGate(backpressureStore, time.Duration(constants.BackpressureSignalTTLSeconds)*time.Second)
+			server.health.backpressure = server.backpressure
+		}
+
+		// Write session replay chunks from the replay topic to the replay store
+		replayConsumer := newTopicConsumer(messageBus, constants.ReplayTopic, constants.ReplayConsumerGroup)
+		defer replayConsumer.Close()
+		go server.consumeReplayEvents(ctx, replayConsumer)
+		if constants.ReplayTTLHours > 0 {
+			go server.expireReplays(ctx, time.Duration(constants.ReplayTTLHours)*time.Hour, time.Hour)
+		}
+
+		// Keep the events of each session for their timeline
+		if constants.SessionStoreDir != "" {
+			sessionStore, err := sessions.NewFileStore(constants.SessionStoreDir)
+			if err != nil {
+				logging.Fatal("Failed to open session store", "error", err)
+			}
+			defer sessionStore.Close()
+			server.sessionStore = sessionStore
+
+			topics := slices.DeleteFunc(router.Topics(), func(topic string) bool { return topic == constants.ReplayTopic })
+			sessionConsumer := newTopicsConsumer(messageBus, topics, constants.SessionStoreGroup)
+			defer sessionConsumer.Close()
+			go server.consumeSessionEvents(ctx, sessionConsumer)
+			go server.expireSessions(ctx, time.Duration(constants.SessionStoreTTLHours)*time.Hour, time.Hour)
+		}
+
+		// Aggregate meta events from all components for the internal view
+		if constants.MetaEventsEnabled {
+			metaConsumer := newTopicConsumer(messageBus, constants.MetaTopic, constants.MetaConsumerGroup)
+			defer metaConsumer.Close()
+			go server.consumeMetaEvents(ctx, metaConsumer)
+		}
+	}
+
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
 
 	go func() {
 		<-sigChan
-		log.Println("Received shutdown signal...")
+		logging.Info("Received shutdown signal")
 		cancel()
 	}()
 
 	if err := server.Start(ctx); err != nil && err != http.ErrServerClosed {
-		log.Fatalf("Server failed: %v", err)
+		logging.Fatal("Server failed", "error", err)
 	}
 
-	log.Println("Server stopped gracefully")
+	logging.Info("Server stopped gracefully")
 }
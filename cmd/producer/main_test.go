@@ -0,0 +1,1123 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/constants"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/analytics"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/backpressure"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/errs"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/features"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/ingest"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/kafka"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/kafka/kafkatest"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/meta"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/replay"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/scaling"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/sessions"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/shadow"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/spool"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/store"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/webhooks"
+	"github.com/klauspost/compress/zstd"
+)
+
+// newTestServer creates a server on in-memory stores that sends to a mock producer
+func newTestServer(t *testing.T) (*Server, *kafkatest.Producer) {
+	t.Helper()
+	producer := kafkatest.NewProducer(constants.KafkaTopic)
+	replayStore, err := replay.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create replay store: %v", err)
+	}
+	memoryStore := store.NewMemoryStore()
+	router := kafka.NewRouter(constants.KafkaTopic, nil)
+	return NewServer(producer, nil, router, memoryStore, memoryStore, memoryStore, memoryStore, memoryStore, replayStore, nil, "0"), producer
+}
+
+func postEvent(s *Server, body string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	s.handleEvent(recorder, httptest.NewRequest(http.MethodPost, "/event", bytes.NewBufferString(body)))
+	return recorder
+}
+
+func TestHandleEventSendsToKafka(t *testing.T) {
+	server, producer := newTestServer(t)
+
+	recorder := postEvent(server, `{"id":"evt-1","type":"page_view","user_id":"u1","session_id":"s1","url":"https://example.com/pricing","path":"/pricing"}`)
+	if recorder.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", recorder.Code, recorder.Body)
+	}
+
+	sent := producer.Sent()
+	if len(sent) != 1 || sent[0].Topic != constants.KafkaTopic || sent[0].Key != "evt-1" {
+		t.Fatalf("expected the event sent to the default topic keyed by ID, got %+v", sent)
+	}
+	if event := sent[0].Value.(*models.AnalyticsEvent); event.Path != "/pricing" || event.Timestamp.IsZero() {
+		t.Errorf("expected the decoded event with a timestamp, got %+v", event)
+	}
+	if snapshot := server.analyticsService.GetSnapshot(); snapshot.TotalEvents != 1 {
+		t.Errorf("expected the event in the producer's analytics, got %d events", snapshot.TotalEvents)
+	}
+}
+
+func TestHandleEventRejectsInvalidEvents(t *testing.T) {
+	server, producer := newTestServer(t)
+
+	for _, body := range []string{`{not json`, `{"type":"Not A Type"}`, `{"type":"purchase"}`} {
+		if recorder := postEvent(server, body); recorder.Code != http.StatusBadRequest {
+			t.Errorf("expected 400 for %s, got %d", body, recorder.Code)
+		}
+	}
+	if len(producer.Sent()) != 0 {
+		t.Errorf("expected no invalid events sent, got %d", len(producer.Sent()))
+	}
+}
+
+func TestHandleEventReportsKafkaFailures(t *testing.T) {
+	server, producer := newTestServer(t)
+	producer.FailSends(errors.New("broker unavailable"))
+
+	if recorder := postEvent(server, `{"type":"click","user_id":"u1"}`); recorder.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500 without a spool, got %d", recorder.Code)
+	}
+	if snapshot := server.analyticsService.GetSnapshot(); snapshot.TotalEvents != 0 {
+		t.Errorf("expected a failed event not to be counted, got %d events", snapshot.TotalEvents)
+	}
+
+	// Clients retry later while Kafka is unavailable, and never resend invalid events
+	producer.FailSends(errs.Errorf(errs.ErrKafkaUnavailable, "failed to write message: %w", io.ErrUnexpectedEOF))
+	recorder := postEvent(server, `{"type":"click","user_id":"u1"}`)
+	if recorder.Code != http.StatusServiceUnavailable || recorder.Header().Get("Retry-After") == "" || !strings.Contains(recorder.Body.String(), `"send_failed"`) {
+		t.Errorf("expected 503 send_failed with Retry-After, got %d %v %s", recorder.Code, recorder.Header(), recorder.Body)
+	}
+	producer.FailSends(errs.Errorf(errs.ErrInvalidEvent, "failed to write message: %w", errors.New("message too large")))
+	if recorder := postEvent(server, `{"type":"click","user_id":"u1"}`); recorder.Code != http.StatusBadRequest || !strings.Contains(recorder.Body.String(), `"invalid_event"`) {
+		t.Errorf("expected 400 invalid_event, got %d %s", recorder.Code, recorder.Body)
+	}
+}
+
+// rejectingProducer fails to send one event as Kafka does an oversized one
+type rejectingProducer struct {
+	*kafkatest.Producer
+	reject string
+}
+
+func (p *rejectingProducer) SendToTopic(ctx context.Context, topic, key string, value interface{}) error {
+	if event, ok := value.(models.AnalyticsEvent); ok && event.ID == p.reject {
+		return errs.Errorf(errs.ErrInvalidEvent, "failed to write message: %w", errors.New("message too large"))
+	}
+	return p.Producer.SendToTopic(ctx, topic, key, value)
+}
+
+func TestSpoolSkipsEventsKafkaCannotAccept(t *testing.T) {
+	eventSpool, err := spool.Open(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("failed to open spool: %v", err)
+	}
+	defer eventSpool.Close()
+	producer := &rejectingProducer{Producer: kafkatest.NewProducer(constants.KafkaTopic), reject: "evt-big"}
+	memoryStore := store.NewMemoryStore()
+	router := kafka.NewRouter(constants.KafkaTopic, nil)
+	server := NewServer(producer, eventSpool, router, memoryStore, memoryStore, memoryStore, memoryStore, memoryStore, nil, nil, "0")
+
+	// A permanent failure is reported rather than spooled
+	producer.FailSends(errs.Errorf(errs.ErrInvalidEvent, "failed to write message: %w", errors.New("message too large")))
+	if recorder := postEvent(server, `{"id":"evt-0","type":"click","user_id":"u1"}`); recorder.Code != http.StatusBadRequest || eventSpool.Pending() != 0 {
+		t.Fatalf("expected 400 and nothing spooled, got %d with %d spooled", recorder.Code, eventSpool.Pending())
+	}
+
+	// Events spooled while Kafka was down include one it can never accept
+	producer.FailSends(errs.Errorf(errs.ErrKafkaUnavailable, "failed to write message: %w", io.ErrUnexpectedEOF))
+	for _, id := range []string{"evt-1", "evt-big", "evt-2"} {
+		if recorder := postEvent(server, `{"id":"`+id+`","type":"click","user_id":"u1"}`); recorder.Code != http.StatusAccepted {
+			t.Fatalf("expected %s spooled with 202, got %d", id, recorder.Code)
+		}
+	}
+	producer.FailSends(nil)
+	server.drainSpool(context.Background())
+
+	if eventSpool.Pending() != 0 {
+		t.Fatalf("expected the drain to get past the rejected event, %d still spooled", eventSpool.Pending())
+	}
+	var sent []string
+	for _, message := range producer.Sent() {
+		sent = append(sent, message.Key)
+	}
+	if strings.Join(sent, ",") != "evt-1,evt-2" {
+		t.Errorf("expected the other events sent in order, got %v", sent)
+	}
+}
+
+func TestErasureRemovesSpooledEvents(t *testing.T) {
+	eventSpool, err := spool.Open(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("failed to open spool: %v", err)
+	}
+	defer eventSpool.Close()
+	producer := kafkatest.NewProducer(constants.KafkaTopic)
+	memoryStore := store.NewMemoryStore()
+	replayStore, err := replay.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create replay store: %v", err)
+	}
+	router := kafka.NewRouter(constants.KafkaTopic, nil)
+	server := NewServer(producer, eventSpool, router, memoryStore, memoryStore, memoryStore, memoryStore, memoryStore, replayStore, nil, "0")
+
+	producer.FailSends(errs.Errorf(errs.ErrKafkaUnavailable, "failed to write message: %w", io.ErrUnexpectedEOF))
+	postEvent(server, `{"id":"evt-1","type":"click","user_id":"u1","session_id":"s1"}`)
+	postEvent(server, `{"id":"evt-2","type":"click","user_id":"u2","session_id":"s2"}`)
+
+	recorder := httptest.NewRecorder()
+	server.handleErasure(recorder, httptest.NewRequest(http.MethodPost, "/privacy/erase", strings.NewReader(`{"user_id":"u1"}`)))
+	if recorder.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", recorder.Code, recorder.Body)
+	}
+
+	producer.FailSends(nil)
+	server.drainSpool(context.Background())
+	var sent []string
+	for _, message := range producer.Sent() {
+		event := message.Value.(models.AnalyticsEvent)
+		sent = append(sent, string(event.Type)+":"+event.UserID)
+	}
+	if strings.Join(sent, ",") != "click:u2,user_erasure:u1" {
+		t.Errorf("expected u1's spooled click removed and the tombstone kept, got %v", sent)
+	}
+}
+
+func TestHandleEventEnforcesLimits(t *testing.T) {
+	server, producer := newTestServer(t)
+
+	oversized := `{"type":"click","metadata":{"note":"` + strings.Repeat("x", constants.MaxEventBytes) + `"}}`
+	deep := `{"type":"click","metadata":` + strings.Repeat(`{"a":`, constants.MaxJSONDepth) + `1` + strings.Repeat(`}`, constants.MaxJSONDepth) + `}`
+	for _, tc := range []struct {
+		body   string
+		status int
+		code   string
+	}{
+		{oversized, http.StatusRequestEntityTooLarge, "body_too_large"},
+		{deep, http.StatusBadRequest, "too_deep"},
+		{`{"type":"click"} {"type":"click"}`, http.StatusBadRequest, "invalid_json"},
+		{`{"type":"Not A Type"}`, http.StatusBadRequest, "invalid_event_type"},
+		{`{"type":"user_erasure","user_id":"u1"}`, http.StatusBadRequest, "reserved_event_type"},
+		{`{"type":"pipeline_meta"}`, http.StatusBadRequest, "reserved_event_type"},
+	} {
+		recorder := postEvent(server, tc.body)
+		var body ingest.Error
+		if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+			t.Fatalf("expected a JSON error body, got %q", recorder.Body)
+		}
+		if recorder.Code != tc.status || body.Code != tc.code || body.Message == "" {
+			t.Errorf("expected %d %s, got %d %+v", tc.status, tc.code, recorder.Code, body)
+		}
+	}
+	if len(producer.Sent()) != 0 {
+		t.Errorf("expected no events sent, got %d", len(producer.Sent()))
+	}
+}
+
+func TestCompressedBodiesAreDecoded(t *testing.T) {
+	server, producer := newTestServer(t)
+
+	compress := func(encoding string, data []byte) []byte {
+		var buf bytes.Buffer
+		var writer io.WriteCloser
+		switch encoding {
+		case "gzip":
+			writer = gzip.NewWriter(&buf)
+		case "deflate":
+			writer = zlib.NewWriter(&buf)
+		case "zstd":
+			writer, _ = zstd.NewWriter(&buf)
+		default:
+			return data
+		}
+		writer.Write(data)
+		writer.Close()
+		return buf.Bytes()
+	}
+	send := func(path, encoding string, body []byte) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body))
+		if encoding != "" {
+			request.Header.Set("Content-Encoding", encoding)
+		}
+		recorder := httptest.NewRecorder()
+		if path == "/events/batch" {
+			server.handleEventBatch(recorder, request)
+		} else {
+			server.handleEvent(recorder, request)
+		}
+		return recorder
+	}
+
+	for _, encoding := range []string{"gzip", "deflate", "zstd"} {
+		recorder := send("/event", encoding, compress(encoding, []byte(`{"type":"click","user_id":"u1"}`)))
+		if recorder.Code != http.StatusAccepted {
+			t.Fatalf("expected a %s event to be accepted, got %d %s", encoding, recorder.Code, recorder.Body)
+		}
+		if got := recorder.Header().Get("Accept-Encoding"); got != "gzip, deflate, zstd" {
+			t.Errorf("expected the accepted encodings to be advertised, got %q", got)
+		}
+		if got := recorder.Header().Get("X-Max-Body-Bytes"); got != strconv.Itoa(constants.MaxEventBytes) {
+			t.Errorf("expected the body limit to be advertised, got %q", got)
+		}
+	}
+
+	recorder := send("/events/batch", "GZIP", compress("gzip", []byte(`[{"type":"click"},{"type":"page_view"}]`)))
+	if recorder.Code != http.StatusAccepted || !strings.Contains(recorder.Body.String(), `"accepted":2`) {
+		t.Fatalf("expected a gzip batch to be accepted, got %d %s", recorder.Code, recorder.Body)
+	}
+	if got := recorder.Header().Get("X-Max-Batch-Events"); got != strconv.Itoa(constants.MaxBatchEvents) {
+		t.Errorf("expected the batch limit to be advertised, got %q", got)
+	}
+
+	// Decoding stops at the limit, however well the body compresses
+	bomb := compress("gzip", []byte(`{"type":"click","metadata":{"note":"`+strings.Repeat("x", constants.MaxEventBytes)+`"}}`))
+	for _, tc := range []struct {
+		encoding string
+		body     []byte
+		status   int
+		code     string
+	}{
+		{"gzip", bomb, http.StatusRequestEntityTooLarge, "body_too_large"},
+		{"gzip", []byte(`{"type":"click"}`), http.StatusBadRequest, "invalid_encoding"},
+		{"zstd", compress("gzip", []byte(`{"type":"click"}`)), http.StatusBadRequest, "invalid_encoding"},
+		{"gzip", compress("gzip", []byte(`{"type":"click"}`))[:10], http.StatusBadRequest, "invalid_encoding"},
+		{"br", []byte(`{"type":"click"}`), http.StatusUnsupportedMediaType, "unsupported_encoding"},
+	} {
+		recorder := send("/event", tc.encoding, tc.body)
+		var body ingest.Error
+		json.Unmarshal(recorder.Body.Bytes(), &body)
+		if recorder.Code != tc.status || body.Code != tc.code {
+			t.Errorf("expected %s body to get %d %s, got %d %+v", tc.encoding, tc.status, tc.code, recorder.Code, body)
+		}
+	}
+	if len(producer.Sent()) != 5 {
+		t.Errorf("expected only the decodable events to be sent, got %d", len(producer.Sent()))
+	}
+}
+
+func TestAnalyticsSnapshotSupportsConditionalRequests(t *testing.T) {
+	server, _ := newTestServer(t)
+	postEvent(server, `{"type":"page_view","user_id":"u1","url":"https://example.com/"}`)
+
+	get := func(target string, header http.Header) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodGet, target, nil)
+		for name, values := range header {
+			request.Header[name] = values
+		}
+		recorder := httptest.NewRecorder()
+		server.handleAnalytics(recorder, request)
+		return recorder
+	}
+
+	first := get("/analytics", nil)
+	etag, modified := first.Header().Get("ETag"), first.Header().Get("Last-Modified")
+	if first.Code != http.StatusOK || !strings.HasPrefix(etag, `W/"`) || modified == "" {
+		t.Fatalf("expected a snapshot with validators, got %d, ETag %q, Last-Modified %q", first.Code, etag, modified)
+	}
+	if got := first.Header().Get("Cache-Control"); got != "max-age="+strconv.Itoa(constants.AnalyticsCacheSeconds) {
+		t.Errorf("expected the cache lifetime as max-age, got %q", got)
+	}
+
+	for name, header := range map[string]http.Header{
+		"If-None-Match":     {"If-None-Match": {`"other", ` + strings.TrimPrefix(etag, "W/")}},
+		"If-Modified-Since": {"If-Modified-Since": {modified}},
+	} {
+		if recorder := get("/analytics", header); recorder.Code != http.StatusNotModified || recorder.Body.Len() != 0 {
+			t.Errorf("%s: expected 304 without a body, got %d", name, recorder.Code)
+		}
+	}
+	if recorder := get("/analytics?precision=0", http.Header{"If-None-Match": {etag}}); recorder.Code != http.StatusOK || recorder.Header().Get("ETag") == etag {
+		t.Errorf("expected another format to have its own ETag, got %d", recorder.Code)
+	}
+
+	// A reset is visible at once, with a new ETag
+	server.handleAdminReset(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/admin/reset", nil))
+	recorder := get("/analytics", http.Header{"If-None-Match": {etag}})
+	var snapshot models.MetricsSnapshot
+	json.Unmarshal(recorder.Body.Bytes(), &snapshot)
+	if recorder.Code != http.StatusOK || snapshot.TotalEvents != 0 {
+		t.Errorf("expected the reset snapshot, got %d with %d events", recorder.Code, snapshot.TotalEvents)
+	}
+}
+
+func TestIngestChainRunsCustomStages(t *testing.T) {
+	server, producer := newTestServer(t)
+	server.ingestChain.Use(
+		ingest.Validate(func(r *http.Request, event *models.AnalyticsEvent) error {
+			if r.Header.Get("X-Tenant") == "" {
+				return ingest.Errorf(http.StatusForbidden, "missing_tenant", "X-Tenant header is required")
+			}
+			return nil
+		}),
+		ingest.Enrich(func(r *http.Request, event *models.AnalyticsEvent) {
+			if event.Metadata == nil {
+				event.Metadata = make(map[string]interface{})
+			}
+			event.Metadata["tenant"] = r.Header.Get("X-Tenant")
+		}),
+	)
+
+	recorder := postEvent(server, `{"type":"click","user_id":"u1"}`)
+	var body ingest.Error
+	json.Unmarshal(recorder.Body.Bytes(), &body)
+	if recorder.Code != http.StatusForbidden || body.Code != "missing_tenant" {
+		t.Errorf("expected 403 missing_tenant, got %d %+v", recorder.Code, body)
+	}
+
+	request := httptest.NewRequest(http.MethodPost, "/events/batch", strings.NewReader(`[{"type":"click"},{"type":"Not A Type"}]`))
+	request.Header.Set("X-Tenant", "acme")
+	batch := httptest.NewRecorder()
+	server.handleEventBatch(batch, request)
+	if batch.Code != http.StatusAccepted || !strings.Contains(batch.Body.String(), `"invalid_event_type"`) {
+		t.Fatalf("expected the valid event accepted and the invalid one rejected, got %d %s", batch.Code, batch.Body)
+	}
+
+	sent := producer.Sent()
+	if len(sent) != 1 {
+		t.Fatalf("expected only the tenant's valid event sent, got %d", len(sent))
+	}
+	if event := sent[0].Value.(*models.AnalyticsEvent); event.Metadata["tenant"] != "acme" || event.ID == "" {
+		t.Errorf("expected the event enriched after the built-in stages, got %+v", event)
+	}
+}
+
+func TestIngestSetsCountryFromGeoHeaders(t *testing.T) {
+	server, producer := newTestServer(t)
+	server.geoHeaders = geoHeaders{country: "CF-IPCountry", region: "CF-Region-Code"}
+
+	for _, body := range []string{`{"type":"page_view"}`, `{"type":"page_view","metadata":{"country":"fr"}}`} {
+		request := httptest.NewRequest(http.MethodPost, "/event", strings.NewReader(body))
+		request.Header.Set("CF-IPCountry", "de")
+		request.Header.Set("CF-Region-Code", "BE")
+		recorder := httptest.NewRecorder()
+		server.handleEvent(recorder, request)
+		if recorder.Code != http.StatusAccepted {
+			t.Fatalf("expected 202, got %d: %s", recorder.Code, recorder.Body)
+		}
+	}
+
+	sent := producer.Sent()
+	if len(sent) != 2 {
+		t.Fatalf("expected 2 events sent, got %d", len(sent))
+	}
+	if event := sent[0].Value.(*models.AnalyticsEvent); event.Metadata["country"] != "DE" || event.Metadata["region"] != "BE" {
+		t.Errorf("expected the location from the headers, got %v", event.Metadata)
+	}
+	if event := sent[1].Value.(*models.AnalyticsEvent); event.Metadata["country"] != "fr" || event.Metadata["region"] != nil {
+		t.Errorf("expected the tracker's country kept, got %v", event.Metadata)
+	}
+
+	geo := server.analyticsService.GetGeoStats()
+	if len(geo.Countries) != 2 || len(geo.Regions) != 1 || geo.Regions[0].Name != "DE-BE" {
+		t.Errorf("expected page views from DE and FR, got %+v", geo)
+	}
+}
+
+func TestHandleEventRejectsUnderBackpressure(t *testing.T) {
+	server, producer := newTestServer(t)
+	signals := backpressure.NewMemoryStore()
+	server.backpressure = backpressure.NewGate(signals, time.Minute)
+	server.health.backpressure = server.backpressure
+	ctx := context.Background()
+
+	signals.Publish(ctx, backpressure.Signal{Instance: "consumer-1", Throttled: true, Reason: "consumer lag 500 exceeds 100", RetryAfterSeconds: 20, UpdatedAt: time.Now()})
+	server.backpressure.Refresh(ctx)
+
+	recorder := postEvent(server, `{"type":"click"}`)
+	var body ingest.Error
+	json.Unmarshal(recorder.Body.Bytes(), &body)
+	if recorder.Code != http.StatusTooManyRequests || body.Code != "backpressure" || recorder.Header().Get("Retry-After") != "20" {
+		t.Errorf("expected 429 backpressure with Retry-After 20, got %d %+v %q", recorder.Code, body, recorder.Header().Get("Retry-After"))
+	}
+	batch := httptest.NewRecorder()
+	server.handleEventBatch(batch, httptest.NewRequest(http.MethodPost, "/events/batch", strings.NewReader(`[{"type":"click"}]`)))
+	if batch.Code != http.StatusTooManyRequests {
+		t.Errorf("expected batches rejected too, got %d", batch.Code)
+	}
+	if report := server.health.report(ctx); report.Status != healthDegraded || report.Checks["backpressure"].Status != "down" {
+		t.Errorf("expected a degraded but ready producer, got %+v", report)
+	}
+
+	signals.Remove(ctx, "consumer-1")
+	server.backpressure.Refresh(ctx)
+	if recorder := postEvent(server, `{"type":"click"}`); recorder.Code != http.StatusAccepted {
+		t.Errorf("expected events accepted once cleared, got %d", recorder.Code)
+	}
+	if len(producer.Sent()) != 1 {
+		t.Errorf("expected only the last event sent, got %d", len(producer.Sent()))
+	}
+}
+
+func TestHandleEventBatch(t *testing.T) {
+	server, producer := newTestServer(t)
+
+	post := func(body string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		server.handleEventBatch(recorder, httptest.NewRequest(http.MethodPost, "/events/batch", bytes.NewBufferString(body)))
+		return recorder
+	}
+
+	recorder := post(`[{"id":"evt-1","type":"click","user_id":"u1"},{"type":"Not A Type"},{"type":"page_view","timestamp":1}]`)
+	if recorder.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", recorder.Code, recorder.Body)
+	}
+	var response struct {
+		Accepted int           `json:"accepted"`
+		Rejected int           `json:"rejected"`
+		Results  []batchResult `json:"results"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Accepted != 1 || response.Rejected != 2 || len(response.Results) != 3 {
+		t.Fatalf("expected 1 accepted and 2 rejected, got %+v", response)
+	}
+	if r := response.Results[0]; r.Status != "accepted" || r.ID != "evt-1" {
+		t.Errorf("expected the first event accepted, got %+v", r)
+	}
+	if r := response.Results[1]; r.Status != "rejected" || r.Code != "invalid_event_type" || r.Index != 1 {
+		t.Errorf("expected the second event rejected for its type, got %+v", r)
+	}
+	if r := response.Results[2]; r.Status != "rejected" || r.Code != "invalid_json" {
+		t.Errorf("expected the third event rejected as malformed, got %+v", r)
+	}
+	if len(producer.Sent()) != 1 {
+		t.Errorf("expected only the valid event sent, got %d", len(producer.Sent()))
+	}
+
+	if recorder := post(`[]`); recorder.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an empty batch, got %d", recorder.Code)
+	}
+	if recorder := post(`{"type":"click"}`); recorder.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a body that isn't an array, got %d", recorder.Code)
+	}
+	tooMany := "[" + strings.TrimSuffix(strings.Repeat(`{"type":"click"},`, constants.MaxBatchEvents+1), ",") + "]"
+	if recorder := post(tooMany); recorder.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413 for too many events, got %d", recorder.Code)
+	}
+}
+
+func TestJSONDepth(t *testing.T) {
+	for _, tc := range []struct {
+		data  string
+		depth int
+	}{
+		{`1`, 0},
+		{`{"a":[1,{"b":2}]}`, 3},
+		{`{"a":"{[{[not nesting]}]}"}`, 1},
+		{`{"a":"escaped \" {[{"}`, 1},
+	} {
+		if depth := jsonDepth([]byte(tc.data), 10); depth != tc.depth {
+			t.Errorf("expected depth %d for %s, got %d", tc.depth, tc.data, depth)
+		}
+	}
+}
+
+func TestHandleReadiness(t *testing.T) {
+	server, producer := newTestServer(t)
+
+	recorder := httptest.NewRecorder()
+	server.handleReadiness(recorder, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected 200 with a reachable broker, got %d", recorder.Code)
+	}
+
+	// A fresh checker so the cached ping result is not reused
+	server.health = newHealthChecker(producer, nil)
+	producer.FailPings(errors.New("no brokers"))
+	recorder = httptest.NewRecorder()
+	server.handleReadiness(recorder, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 without a reachable broker, got %d", recorder.Code)
+	}
+
+	var report healthReport
+	if err := json.NewDecoder(recorder.Body).Decode(&report); err != nil {
+		t.Fatalf("invalid health report: %v", err)
+	}
+	if report.Checks["kafka"].Status != "down" || report.Checks["kafka"].Error != "no brokers" {
+		t.Errorf("expected kafka reported down, got %+v", report.Checks)
+	}
+}
+
+func TestAdminReloadAppliesSettings(t *testing.T) {
+	server, _ := newTestServer(t)
+	t.Setenv("SAMPLING_RULES", "click=0.5")
+	t.Setenv("FEATURE_FLAGS", "path_flow=false")
+	t.Cleanup(constants.Reload)
+
+	recorder := httptest.NewRecorder()
+	server.handleAdminReload(recorder, httptest.NewRequest(http.MethodPost, "/admin/reload", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", recorder.Code, recorder.Body)
+	}
+	if rules := server.sampler.Rules(); len(rules) != 1 || rules[0].Rate != 0.5 {
+		t.Errorf("expected the new sampling rules applied, got %+v", rules)
+	}
+	if server.features.Enabled(features.PathFlow) {
+		t.Error("expected path flow disabled")
+	}
+
+	// Invalid settings are reported and the current ones kept
+	t.Setenv("SAMPLING_RULES", "click=2")
+	recorder = httptest.NewRecorder()
+	server.handleAdminReload(recorder, httptest.NewRequest(http.MethodPost, "/admin/reload", nil))
+	if recorder.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d", recorder.Code)
+	}
+	if rules := server.sampler.Rules(); len(rules) != 1 || rules[0].Rate != 0.5 {
+		t.Errorf("expected the previous sampling rules kept, got %+v", rules)
+	}
+}
+
+func TestShadowMirrorCopiesSelectedEvents(t *testing.T) {
+	server, producer := newTestServer(t)
+	server.shadow = shadow.NewMirror("analytics-shadow", 100, 0)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go server.shadow.Run(ctx, server.sendShadow)
+
+	consumer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(models.MetricsSnapshot{TotalEvents: 1})
+	}))
+	defer consumer.Close()
+	server.shadowConsumers = newShadowConsumers(consumer.URL, consumer.URL)
+
+	if recorder := postEvent(server, `{"type":"click","user_id":"u1"}`); recorder.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", recorder.Code, recorder.Body)
+	}
+	// The shadow copy is written in the background
+	var sent []kafkatest.Sent
+	for deadline := time.Now().Add(5 * time.Second); len(sent) < 2 && time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		sent = producer.Sent()
+	}
+	if len(sent) != 2 || sent[0].Topic != constants.KafkaTopic || sent[1].Topic != "analytics-shadow" {
+		t.Fatalf("expected the event sent to the primary and shadow topics, got %+v", sent)
+	}
+
+	recorder := httptest.NewRecorder()
+	server.handleAdminShadow(recorder, httptest.NewRequest(http.MethodGet, "/admin/shadow", nil))
+	var body struct {
+		Mirror     shadow.Stats      `json:"mirror"`
+		Comparison shadow.Comparison `json:"comparison"`
+	}
+	json.Unmarshal(recorder.Body.Bytes(), &body)
+	if body.Mirror.Topic != "analytics-shadow" || len(body.Comparison.Metrics) == 0 {
+		t.Errorf("expected mirror stats and a comparison, got %s", recorder.Body)
+	}
+}
+
+func TestQualityReportsInvalidEvents(t *testing.T) {
+	server, _ := newTestServer(t)
+
+	postEvent(server, `{"type":"click","user_id":`)
+	postEvent(server, `{"type":"click","session_id":"s1","url":"https://example.com"}`)
+
+	recorder := httptest.NewRecorder()
+	server.handleQuality(recorder, httptest.NewRequest(http.MethodGet, "/analytics/quality", nil))
+	var stats models.QualityStats
+	json.Unmarshal(recorder.Body.Bytes(), &stats)
+	if stats.Events != 2 || stats.Malformed != 1 || stats.MissingFields["user_id"] != 1 {
+		t.Errorf("expected a malformed and an incomplete event, got %s", recorder.Body)
+	}
+
+	recorder = httptest.NewRecorder()
+	server.handleMetrics(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if !strings.Contains(recorder.Body.String(), `analytics_producer_quality_problems_total{problem="malformed"} 1`) {
+		t.Errorf("expected quality metrics, got:\n%s", recorder.Body)
+	}
+}
+
+// adminRequest returns a request carrying the admin key set by useAdminKey
+func adminRequest(method, target string, body io.Reader) *http.Request {
+	req := httptest.NewRequest(method, target, body)
+	req.Header.Set("X-API-Key", "admin-key")
+	return req
+}
+
+// useAdminKey makes the server accept "admin-key" on its admin endpoints
+func useAdminKey(s *Server) {
+	s.adminAuth = newAdminAuth([]string{"admin-key"}, 600, 100)
+}
+
+func TestGoalsAreManagedAndSaved(t *testing.T) {
+	server, _ := newTestServer(t)
+	useAdminKey(server)
+
+	recorder := httptest.NewRecorder()
+	server.handleGoals(recorder, adminRequest(http.MethodPost, "/goals", strings.NewReader(`{"name":"signup","event_type":"signup","value":20}`)))
+	if recorder.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", recorder.Code, recorder.Body)
+	}
+	recorder = httptest.NewRecorder()
+	server.handleGoals(recorder, adminRequest(http.MethodPost, "/goals", strings.NewReader(`{"name":"signup","path":"/welcome"}`)))
+	if recorder.Code != http.StatusConflict {
+		t.Errorf("expected 409 for a duplicate goal, got %d", recorder.Code)
+	}
+
+	postEvent(server, `{"type":"signup","user_id":"u1","session_id":"s1"}`)
+
+	recorder = httptest.NewRecorder()
+	server.handleGoals(recorder, httptest.NewRequest(http.MethodGet, "/goals", nil))
+	var body struct {
+		Goals   []models.ConversionGoal `json:"goals"`
+		Results []models.GoalMetric     `json:"results"`
+	}
+	json.Unmarshal(recorder.Body.Bytes(), &body)
+	if len(body.Results) != 1 || body.Results[0].Completions != 1 || body.Results[0].Value != 20 {
+		t.Errorf("expected one completion of the signup goal, got %s", recorder.Body)
+	}
+	if saved, ok, _ := server.goalStore.LoadGoals(context.Background()); !ok || len(saved) != 1 {
+		t.Errorf("expected the goal saved, got %+v", saved)
+	}
+
+	recorder = httptest.NewRecorder()
+	server.handleGoals(recorder, adminRequest(http.MethodDelete, "/goals?name=signup", nil))
+	if recorder.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d: %s", recorder.Code, recorder.Body)
+	}
+	if saved, _, _ := server.goalStore.LoadGoals(context.Background()); len(saved) != 0 {
+		t.Errorf("expected no saved goals, got %+v", saved)
+	}
+}
+
+func TestEventsForOtherDomainsAreRejected(t *testing.T) {
+	server, producer := newTestServer(t)
+	server.allowedDomains = analytics.URLRules{AllowedDomains: []string{"example.com"}}
+	server.rejectDomains = true
+
+	recorder := postEvent(server, `{"type":"page_view","user_id":"u1","url":"https://staging.example.org/"}`)
+	var body ingest.Error
+	json.Unmarshal(recorder.Body.Bytes(), &body)
+	if recorder.Code != http.StatusForbidden || body.Code != "domain_not_allowed" {
+		t.Errorf("expected 403 domain_not_allowed, got %d %+v", recorder.Code, body)
+	}
+
+	for _, url := range []string{"https://example.com/", "https://shop.example.com/cart", ""} {
+		if recorder := postEvent(server, `{"type":"page_view","user_id":"u1","url":"`+url+`"}`); recorder.Code != http.StatusAccepted {
+			t.Errorf("expected %q to be accepted, got %d: %s", url, recorder.Code, recorder.Body)
+		}
+	}
+	if sent := len(producer.Sent()); sent != 3 {
+		t.Errorf("expected 3 events sent, got %d", sent)
+	}
+}
+
+func TestWebhooksAreVerifiedAndIngested(t *testing.T) {
+	server, producer := newTestServer(t)
+	server.webhookAdapters = map[string]webhooks.Adapter{"generic": webhooks.NewGeneric("secret")}
+
+	post := func(path, body, signature string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		request.Header.Set("X-Webhook-Signature", signature)
+		recorder := httptest.NewRecorder()
+		server.handleWebhook(recorder, request)
+		return recorder
+	}
+	body := `[{"id":"w1","type":"signup","user_id":"u1"},{"id":"w2","type":"purchase","user_id":"u1"}]`
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte(body))
+	signature := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	if recorder := post("/webhooks/stripe", body, signature); recorder.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a provider without a secret, got %d", recorder.Code)
+	}
+	recorder := post("/webhooks/generic", body, "sha256=00")
+	var ingestErr ingest.Error
+	json.Unmarshal(recorder.Body.Bytes(), &ingestErr)
+	if recorder.Code != http.StatusUnauthorized || ingestErr.Code != "invalid_signature" {
+		t.Errorf("expected 401 invalid_signature, got %d %+v", recorder.Code, ingestErr)
+	}
+
+	recorder = post("/webhooks/generic", body, signature)
+	var response struct {
+		Accepted int           `json:"accepted"`
+		Results  []batchResult `json:"results"`
+	}
+	json.Unmarshal(recorder.Body.Bytes(), &response)
+	if recorder.Code != http.StatusAccepted || response.Accepted != 1 || response.Results[1].Code != "invalid_event" {
+		t.Fatalf("expected the signup accepted and the purchase without an order rejected, got %d: %s", recorder.Code, recorder.Body)
+	}
+	sent := producer.Sent()
+	if len(sent) != 1 || sent[0].Value.(*models.AnalyticsEvent).Metadata[webhooks.MetadataProvider] != "generic" {
+		t.Errorf("expected the signup sent tagged with its provider, got %+v", sent)
+	}
+}
+
+func TestSessionTimeline(t *testing.T) {
+	server, _ := newTestServer(t)
+	get := func(path string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		server.handleSessionTimeline(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		return recorder
+	}
+	if recorder := get("/sessions/s1"); recorder.Code != http.StatusNotFound {
+		t.Errorf("expected 404 while timelines are disabled, got %d", recorder.Code)
+	}
+
+	sessionStore, err := sessions.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create session store: %v", err)
+	}
+	server.sessionStore = sessionStore
+	ctx := context.Background()
+	start := time.Now().Add(-time.Minute)
+	for _, event := range []*models.AnalyticsEvent{
+		{ID: "e1", Type: models.PageView, SessionID: "s1", UserID: "u1", Path: "/", Timestamp: start},
+		{ID: "e2", Type: models.Click, SessionID: "s1", UserID: "u1", Path: "/", Timestamp: start.Add(10 * time.Second)},
+		{ID: "e3", Type: models.PageView, UserID: "u1", Path: "/", Timestamp: start}, // No session
+	} {
+		if err := server.storeSessionEvent(ctx, event); err != nil {
+			t.Fatalf("storeSessionEvent failed: %v", err)
+		}
+	}
+
+	recorder := get("/sessions/s1")
+	var timeline sessions.Timeline
+	json.Unmarshal(recorder.Body.Bytes(), &timeline)
+	if recorder.Code != http.StatusOK || timeline.Events != 2 || timeline.Clicks != 1 || timeline.DurationMs != 10000 {
+		t.Fatalf("unexpected timeline %d: %s", recorder.Code, recorder.Body)
+	}
+	if recorder := get("/sessions/s..1"); recorder.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an invalid session ID, got %d", recorder.Code)
+	}
+
+	// Erasure tombstones remove the user's sessions
+	server.storeSessionEvent(ctx, models.NewUserErasure("t1", "u1"))
+	if recorder := get("/sessions/s1"); recorder.Code != http.StatusNotFound {
+		t.Errorf("expected the erased session to be gone, got %d", recorder.Code)
+	}
+}
+
+func TestScalingReportsIngestRate(t *testing.T) {
+	server, _ := newTestServer(t)
+	for i := 0; i < 3; i++ {
+		postEvent(server, `{"type":"page_view","user_id":"u1"}`)
+	}
+
+	recorder := httptest.NewRecorder()
+	server.handleScaling(recorder, httptest.NewRequest(http.MethodGet, "/scaling", nil))
+	var metrics scaling.Metrics
+	if err := json.Unmarshal(recorder.Body.Bytes(), &metrics); err != nil {
+		t.Fatalf("invalid scaling response: %v", err)
+	}
+	if metrics.Service != "producer" || metrics.EventsPerSecond != 3.0/60 || metrics.QueueDepth != nil || metrics.Stateless != nil {
+		t.Errorf("unexpected scaling metrics %s", recorder.Body)
+	}
+
+	recorder = httptest.NewRecorder()
+	server.handleMetrics(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if !strings.Contains(recorder.Body.String(), "analytics_producer_scaling_events_per_second 0.05\n") {
+		t.Errorf("expected the ingest rate gauge, got:\n%s", recorder.Body)
+	}
+}
+
+func TestAdminAuthFailsClosed(t *testing.T) {
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) }
+	request := func(handler http.HandlerFunc, method, key string) int {
+		req := httptest.NewRequest(method, "/admin/reset", nil)
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		recorder := httptest.NewRecorder()
+		handler(recorder, req)
+		return recorder.Code
+	}
+
+	disabled := newAdminAuth(nil, 600, 100).middleware(ok)
+	if code := request(disabled, http.MethodPost, ""); code != http.StatusForbidden {
+		t.Errorf("expected 403 without ADMIN_API_KEYS, got %d", code)
+	}
+
+	admin := newAdminAuth([]string{"admin-key"}, 600, 100)
+	if code := request(admin.middleware(ok), http.MethodPost, "ingest-key"); code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for a non-admin key, got %d", code)
+	}
+	if code := request(admin.middleware(ok), http.MethodPost, "admin-key"); code != http.StatusNoContent {
+		t.Errorf("expected the admin key accepted, got %d", code)
+	}
+
+	// Listing clients takes an ingest key, disconnecting them an admin key
+	clients := splitAuth(http.MethodDelete, admin, newAPIKeyAuth([]string{"ingest-key"}, 600, 100), ok)
+	if code := request(clients, http.MethodGet, "ingest-key"); code != http.StatusNoContent {
+		t.Errorf("expected GET with an ingest key accepted, got %d", code)
+	}
+	if code := request(clients, http.MethodDelete, "ingest-key"); code != http.StatusUnauthorized {
+		t.Errorf("expected DELETE with an ingest key rejected, got %d", code)
+	}
+}
+
+// routeStatus sends a request through the producer's routes, with key as X-API-Key if set
+func routeStatus(s *Server, method, target, key string) int {
+	req := httptest.NewRequest(method, target, nil)
+	if key != "" {
+		req.Header.Set("X-API-Key", key)
+	}
+	recorder := httptest.NewRecorder()
+	s.routes().ServeHTTP(recorder, req)
+	return recorder.Code
+}
+
+func TestReplayRequiresAdminKey(t *testing.T) {
+	server, _ := newTestServer(t)
+	server.ingestAuth = newAPIKeyAuth([]string{"ingest-key"}, 600, 100)
+	server.adminAuth = newAdminAuth(nil, 600, 100)
+
+	if code := routeStatus(server, http.MethodGet, "/replay?session_id=s1", "ingest-key"); code != http.StatusForbidden {
+		t.Errorf("expected 403 without ADMIN_API_KEYS, got %d", code)
+	}
+	useAdminKey(server)
+	if code := routeStatus(server, http.MethodGet, "/replay?session_id=s1", "ingest-key"); code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for an ingest key, got %d", code)
+	}
+	if code := routeStatus(server, http.MethodGet, "/replay?session_id=s1", "admin-key"); code != http.StatusNotFound {
+		t.Errorf("expected the admin key to reach the replay, got %d", code)
+	}
+}
+
+func TestCORS(t *testing.T) {
+	const site, other = "https://shop.example.com", "https://evil.example.org"
+	methods, headers := []string{"POST", "OPTIONS"}, []string{"Content-Type", "X-API-Key"}
+	listed := newCORSPolicy([]string{site}, methods, headers, 600)
+	wildcard := newCORSPolicy([]string{"*"}, methods, headers, 600)
+
+	for _, tc := range []struct {
+		name        string
+		policy      *corsPolicy
+		method      string
+		path        string
+		origin      string
+		credentials bool
+		status      int
+		allowOrigin string
+		preflight   bool
+	}{
+		{"preflight", listed, http.MethodOptions, "/event", site, false, http.StatusNoContent, site, true},
+		{"batch preflight", listed, http.MethodOptions, "/events/batch", site, false, http.StatusNoContent, site, true},
+		{"collect preflight", listed, http.MethodOptions, "/collect", site, false, http.StatusNoContent, site, true},
+		{"disallowed preflight", listed, http.MethodOptions, "/event", other, false, http.StatusForbidden, "", false},
+		{"post", listed, http.MethodPost, "/event", site, true, http.StatusAccepted, site, false},
+		{"collect post", listed, http.MethodPost, "/collect", site, true, http.StatusAccepted, site, false},
+		{"missing key", listed, http.MethodPost, "/event", site, false, http.StatusUnauthorized, site, false},
+		{"disallowed post", listed, http.MethodPost, "/event", other, true, http.StatusAccepted, "", false},
+		{"wildcard with credentials", wildcard, http.MethodPost, "/event", other, true, http.StatusAccepted, other, false},
+		{"same origin", listed, http.MethodPost, "/event", "", true, http.StatusAccepted, "", false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			server, _ := newTestServer(t)
+			server.ingestAuth = newAPIKeyAuth([]string{"ingest-key"}, 600, 100)
+			server.cors = tc.policy
+
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(`{"type":"click","user_id":"u1"}`))
+			if tc.origin != "" {
+				req.Header.Set("Origin", tc.origin)
+			}
+			if tc.method == http.MethodOptions {
+				req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+				req.Header.Set("Access-Control-Request-Headers", "content-type, x-api-key")
+			}
+			if tc.credentials {
+				req.Header.Set("X-API-Key", "ingest-key")
+				req.Header.Set("Cookie", "session=abc")
+			}
+			recorder := httptest.NewRecorder()
+			server.routes().ServeHTTP(recorder, req)
+			header := recorder.Header()
+
+			if recorder.Code != tc.status {
+				t.Errorf("expected %d, got %d: %s", tc.status, recorder.Code, recorder.Body)
+			}
+			if got := header.Get("Access-Control-Allow-Origin"); got != tc.allowOrigin {
+				t.Errorf("expected Access-Control-Allow-Origin %q, got %q", tc.allowOrigin, got)
+			}
+			if got := header.Get("Access-Control-Allow-Credentials"); got != "" {
+				t.Errorf("expected no Access-Control-Allow-Credentials, got %q", got)
+			}
+			if varies := strings.Join(header.Values("Vary"), ","); (tc.origin != "") != strings.Contains(varies, "Origin") {
+				t.Errorf("expected Vary: Origin only for cross-origin requests, got %q", varies)
+			}
+			if tc.preflight {
+				if header.Get("Access-Control-Allow-Methods") != "POST, OPTIONS" || header.Get("Access-Control-Allow-Headers") != "Content-Type, X-API-Key" || header.Get("Access-Control-Max-Age") != "600" {
+					t.Errorf("expected the preflight headers, got %v", header)
+				}
+			} else if header.Get("Access-Control-Allow-Methods") != "" {
+				t.Errorf("expected preflight headers only on preflights, got %v", header)
+			}
+		})
+	}
+}
+
+func TestConfigurationChangesRequireAdminKey(t *testing.T) {
+	server, _ := newTestServer(t)
+	server.ingestAuth = newAPIKeyAuth([]string{"ingest-key"}, 600, 100)
+	useAdminKey(server)
+
+	for _, route := range []struct{ method, target string }{
+		{http.MethodGet, "/export/events"},
+		{http.MethodPost, "/alerts/config"},
+		{http.MethodDelete, "/alerts/config?name=x"},
+		{http.MethodPost, "/dashboards"},
+		{http.MethodPut, "/dashboards?id=x"},
+		{http.MethodPost, "/goals"},
+		{http.MethodDelete, "/goals?name=x"},
+		{http.MethodPost, "/custom-metrics"},
+	} {
+		if code := routeStatus(server, route.method, route.target, "ingest-key"); code != http.StatusUnauthorized {
+			t.Errorf("%s %s: expected 401 for an ingest key, got %d", route.method, route.target, code)
+		}
+		if code := routeStatus(server, route.method, route.target, "admin-key"); code == http.StatusUnauthorized || code == http.StatusForbidden {
+			t.Errorf("%s %s: expected the admin key accepted, got %d", route.method, route.target, code)
+		}
+	}
+
+	// Reading configuration needs no key
+	for _, target := range []string{"/alerts/config", "/dashboards", "/goals", "/custom-metrics"} {
+		if code := routeStatus(server, http.MethodGet, target, ""); code != http.StatusOK {
+			t.Errorf("GET %s: expected 200 without a key, got %d", target, code)
+		}
+	}
+
+	server.adminAuth = newAdminAuth(nil, 600, 100)
+	if code := routeStatus(server, http.MethodPost, "/goals", "ingest-key"); code != http.StatusForbidden {
+		t.Errorf("expected 403 without ADMIN_API_KEYS, got %d", code)
+	}
+}
+
+func TestInternalAnalyticsShowsMetaEvents(t *testing.T) {
+	server, _ := newTestServer(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// A consumer drops a message whose handler fails under ErrorDrop
+	metaProducer := kafkatest.NewProducer(constants.MetaTopic)
+	emitter := meta.NewEmitter(metaProducer, "consumer")
+	go emitter.Run(ctx)
+	registry := kafka.NewHandlerRegistry(func(*kafka.Message) error { return errors.New("failed") }, kafka.WithErrorPolicy(kafka.ErrorDrop))
+	registry.SetOperationalHook(emitter.Emit)
+	registry.Dispatch(&kafka.Message{Event: &models.AnalyticsEvent{ID: "e1", Type: models.Click}})
+	deadline := time.Now().Add(time.Second)
+	for len(metaProducer.Sent()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	metaConsumer := kafkatest.NewConsumer(constants.MetaTopic)
+	for _, message := range metaProducer.Sent() {
+		event := message.Value.(models.AnalyticsEvent)
+		metaConsumer.Publish(&event)
+	}
+	metaConsumer.Shutdown(ctx)
+	server.consumeMetaEvents(ctx, metaConsumer)
+
+	recorder := httptest.NewRecorder()
+	server.routes().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/internal/analytics", nil))
+	var snapshot models.MetricsSnapshot
+	json.Unmarshal(recorder.Body.Bytes(), &snapshot)
+	operations, components := snapshot.CustomMetrics["operations"], snapshot.CustomMetrics["components"]
+	if snapshot.TotalEvents != 1 || operations.Values[models.OperationDLQRoute] != 1 || components.Values["consumer"] != 1 {
+		t.Errorf("expected the dropped message in the internal view, got %s", recorder.Body)
+	}
+}
+
+func TestErrorStatuses(t *testing.T) {
+	for _, tc := range []struct {
+		err    error
+		status int
+	}{
+		{errs.Errorf(errs.ErrInvalidEvent, "invalid schema version header"), http.StatusBadRequest},
+		{fmt.Errorf("lookup: %w", errs.ErrNotFound), http.StatusNotFound},
+		{errs.ErrConflict, http.StatusConflict},
+		{errs.ErrThrottled, http.StatusTooManyRequests},
+		{errs.Errorf(errs.ErrKafkaUnavailable, "write: %w", io.EOF), http.StatusServiceUnavailable},
+		{errs.StoreError(context.DeadlineExceeded), http.StatusGatewayTimeout},
+		{errors.New("boom"), http.StatusInternalServerError},
+	} {
+		if status := httpStatus(tc.err); status != tc.status {
+			t.Errorf("%v: expected %d, got %d", tc.err, tc.status, status)
+		}
+	}
+
+	if plain := ingestError(errors.New("user_id is required")); plain.Status != http.StatusBadRequest || plain.Code != "invalid_event" || plain.Message != "user_id is required" {
+		t.Errorf("expected a plain error reported as 400 invalid_event, got %+v", plain)
+	}
+	if custom := ingest.Errorf(http.StatusForbidden, "forbidden", "no"); ingestError(custom) != custom {
+		t.Error("expected an *ingest.Error returned as is")
+	}
+	if throttled := ingestError(errs.Errorf(errs.ErrThrottled, "quota exceeded")); throttled.Status != http.StatusTooManyRequests || throttled.Code != "throttled" {
+		t.Errorf("expected a classified error reported with its kind's status and code, got %+v", throttled)
+	}
+	if ingestError(nil) != nil {
+		t.Error("expected no error for nil")
+	}
+}
+
+func TestPublicStatsForOptedInSites(t *testing.T) {
+	server, _ := newTestServer(t)
+	server.publicSites = map[string]bool{"example.com": true}
+
+	postEvent(server, `{"id":"evt-1","type":"page_view","user_id":"u1","session_id":"s1","url":"https://www.example.com/"}`)
+	postEvent(server, `{"id":"evt-2","type":"page_view","user_id":"u2","session_id":"s2","url":"https://example.com/pricing"}`)
+	// Hostnames come from clients, so a flood of them must not grow the sites without bound
+	const hosts = 1100
+	for i := 0; i < hosts; i++ {
+		postEvent(server, fmt.Sprintf(`{"id":"host-%d","type":"page_view","user_id":"u1","session_id":"s1","url":"https://site-%d.test/"}`, i, i))
+	}
+
+	recorder := httptest.NewRecorder()
+	server.handlePublicStats(recorder, httptest.NewRequest(http.MethodGet, "/public/stats?site=example.com", nil))
+	if recorder.Code != http.StatusOK || recorder.Header().Get("Access-Control-Allow-Origin") != "*" {
+		t.Fatalf("expected public stats, got %d: %s", recorder.Code, recorder.Body)
+	}
+	var stats publicSiteStats
+	if err := json.NewDecoder(recorder.Body).Decode(&stats); err != nil {
+		t.Fatalf("failed to decode stats: %v", err)
+	}
+	if stats.Site != "example.com" || stats.PageViews != 2 || stats.UniqueVisitors != 2 {
+		t.Errorf("expected 2 views by 2 visitors of example.com, got %+v", stats)
+	}
+
+	recorder = httptest.NewRecorder()
+	server.handlePublicStats(recorder, httptest.NewRequest(http.MethodGet, "/public/stats?site=site-1.test", nil))
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a site that did not opt in, got %d", recorder.Code)
+	}
+
+	if sites := len(server.analyticsService.GetSnapshot().Sites); sites >= hosts {
+		t.Errorf("expected the tracked sites bounded, got %d", sites)
+	}
+}
+
+func TestSimulatedEventsTakeTheIngestPath(t *testing.T) {
+	server, producer := newTestServer(t)
+
+	server.ingestSimulated(context.Background(), models.AnalyticsEvent{
+		ID: "sim-1", Type: models.PageView, UserID: "u1", SessionID: "s1", URL: "https://example.com/", Path: "/",
+	})
+	server.ingestSimulated(context.Background(), models.AnalyticsEvent{ID: "sim-2", Type: models.UserErasure, UserID: "u1"})
+
+	if sent := producer.Sent(); len(sent) != 1 || sent[0].Key != "sim-1" {
+		t.Fatalf("expected only the page view sent, got %+v", sent)
+	}
+	if snapshot := server.analyticsService.GetSnapshot(); snapshot.TotalEvents != 1 {
+		t.Errorf("expected the page view in analytics, got %d events", snapshot.TotalEvents)
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/logging"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/ratelimit"
+	"github.com/google/uuid"
+)
+
+// apiKeyAuth authenticates ingestion requests and applies per-key rate limits
+type apiKeyAuth struct {
+	keys     []string
+	limiter  *ratelimit.Limiter
+	required bool // Reject every request while no keys are configured
+}
+
+// newAPIKeyAuth parses key entries of the form "key" or "key:requestsPerMinute".
+// With no keys configured, authentication is disabled and limits apply per client IP.
+func newAPIKeyAuth(entries []string, requestsPerMinute, burst int) *apiKeyAuth {
+	auth := &apiKeyAuth{
+		limiter: ratelimit.NewLimiter(requestsPerMinute, burst),
+	}
+
+	for _, entry := range entries {
+		key, rate, hasRate := strings.Cut(entry, ":")
+		if key == "" {
+			continue
+		}
+		auth.keys = append(auth.keys, key)
+
+		if hasRate {
+			perMinute, err := strconv.Atoi(rate)
+			if err != nil || perMinute <= 0 {
+				logging.Warn("Ignoring invalid rate limit for API key", "key_suffix", keySuffix(key))
+				continue
+			}
+			auth.limiter.SetKeyLimit(key, perMinute, burst)
+		}
+	}
+
+	return auth
+}
+
+// newAdminAuth authenticates requests to the admin endpoints with ADMIN_API_KEYS. Unlike
+// ingestion, it fails closed: with no keys configured every request is rejected.
+func newAdminAuth(entries []string, requestsPerMinute, burst int) *apiKeyAuth {
+	auth := newAPIKeyAuth(entries, requestsPerMinute, burst)
+	auth.required = true
+	return auth
+}
+
+// enabled reports whether API keys are required
+func (a *apiKeyAuth) enabled() bool {
+	return len(a.keys) > 0
+}
+
+// authenticate returns the matching configured key for the request, if any
+func (a *apiKeyAuth) authenticate(r *http.Request) (string, bool) {
+	presented := r.Header.Get("X-API-Key")
+	if presented == "" {
+		if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+			presented = token
+		}
+	}
+	if presented == "" {
+		return "", false
+	}
+
+	// Compare against every key in constant time to avoid leaking key material via timing
+	matched := ""
+	for _, key := range a.keys {
+		if subtle.ConstantTimeCompare([]byte(presented), []byte(key)) == 1 {
+			matched = key
+		}
+	}
+	return matched, matched != ""
+}
+
+// middleware wraps an ingestion handler with authentication (401) and rate limiting (429)
+func (a *apiKeyAuth) middleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limitKey := "ip:" + clientIP(r)
+
+		if a.required && !a.enabled() {
+			http.Error(w, "Admin API is disabled, set ADMIN_API_KEYS to enable it", http.StatusForbidden)
+			return
+		}
+		if a.enabled() {
+			key, ok := a.authenticate(r)
+			if !ok {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="analytics"`)
+				http.Error(w, "Invalid or missing API key", http.StatusUnauthorized)
+				return
+			}
+			limitKey = key
+		}
+
+		if !a.limiter.Allow(limitKey) {
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// splitAuth authenticates requests of the given method with admin, and all others with auth
+func splitAuth(method string, admin, auth *apiKeyAuth, next http.HandlerFunc) http.HandlerFunc {
+	adminNext, authNext := admin.middleware(next), auth.middleware(next)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == method {
+			adminNext(w, r)
+			return
+		}
+		authNext(w, r)
+	}
+}
+
+// keySuffix returns the last characters of a key for safe logging
+func keySuffix(key string) string {
+	if len(key) <= 4 {
+		return "****"
+	}
+	return key[len(key)-4:]
+}
+
+// corsPolicy lets browsers on other origins call the ingestion endpoints
+type corsPolicy struct {
+	origins   map[string]bool
+	anyOrigin bool
+	methods   string
+	headers   string
+	maxAge    string
+}
+
+// newCORSPolicy creates a policy for the given origins ("*" allows any).
+// With no origins configured no CORS headers are sent, so browsers only allow same-origin requests.
+func newCORSPolicy(origins, methods, headers []string, maxAgeSeconds int) *corsPolicy {
+	policy := &corsPolicy{
+		origins: make(map[string]bool),
+		methods: strings.Join(methods, ", "),
+		headers: strings.Join(headers, ", "),
+		maxAge:  strconv.Itoa(maxAgeSeconds),
+	}
+	for _, origin := range origins {
+		if origin == "*" {
+			policy.anyOrigin = true
+			continue
+		}
+		policy.origins[strings.ToLower(strings.TrimSuffix(origin, "/"))] = true
+	}
+	return policy
+}
+
+// allows reports whether requests from origin may read responses
+func (p *corsPolicy) allows(origin string) bool {
+	return p.anyOrigin || p.origins[strings.ToLower(origin)]
+}
+
+// middleware adds CORS headers for allowed origins and answers preflight requests.
+// It must wrap authentication, since browsers send preflights without credentials.
+func (p *corsPolicy) middleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+		if origin == "" {
+			next(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Origin")
+		if !p.allows(origin) {
+			if preflight {
+				http.Error(w, "Origin not allowed", http.StatusForbidden)
+				return
+			}
+			// Browsers block the response without CORS headers; other clients are unaffected
+			next(w, r)
+			return
+		}
+
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		if preflight {
+			w.Header().Add("Vary", "Access-Control-Request-Method")
+			w.Header().Add("Vary", "Access-Control-Request-Headers")
+			w.Header().Set("Access-Control-Allow-Methods", p.methods)
+			w.Header().Set("Access-Control-Allow-Headers", p.headers)
+			w.Header().Set("Access-Control-Max-Age", p.maxAge)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("Access-Control-Expose-Headers", "Retry-After, Accept-Encoding, X-Max-Body-Bytes, X-Max-Batch-Events, "+requestIDHeader)
+
+		next(w, r)
+	}
+}
+
+// requestIDHeader carries the ID that correlates a request's log records. A well-formed
+// ID sent by the client or a proxy is kept so records can be matched across services.
+const requestIDHeader = "X-Request-ID"
+
+// requestIDPattern limits accepted IDs to short tokens that are safe to log
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// withRequestID assigns every request an ID, returns it in the response and gives
+// handlers a logger tagged with it through the request context
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !requestIDPattern.MatchString(id) {
+			id = uuid.New().String()
+		}
+		w.Header().Set(requestIDHeader, id)
+
+		logger := logging.With("request_id", id, "method", r.Method, "path", r.URL.Path)
+		next.ServeHTTP(w, r.WithContext(logging.NewContext(r.Context(), logger)))
+	})
+}
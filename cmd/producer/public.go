@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/constants"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+// publicSiteStats is the minimal stats payload exposed to the public
+type publicSiteStats struct {
+	Site           string `json:"site"`
+	PageViews      int64  `json:"page_views"`
+	UniqueVisitors int64  `json:"unique_visitors"`
+}
+
+// lookupPublicSite validates the site query parameter and returns its cached metrics.
+// It writes an error response and returns false if the request cannot be served.
+func (s *Server) lookupPublicSite(w http.ResponseWriter, r *http.Request) (models.SiteMetric, bool) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return models.SiteMetric{}, false
+	}
+
+	if !s.publicLimiter.Allow(clientIP(r)) {
+		w.Header().Set("Retry-After", "60")
+		http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
+		return models.SiteMetric{}, false
+	}
+
+	site := strings.TrimPrefix(strings.ToLower(r.URL.Query().Get("site")), "www.")
+	if site == "" {
+		http.Error(w, "Missing site parameter", http.StatusBadRequest)
+		return models.SiteMetric{}, false
+	}
+
+	// Sites must opt in before their stats are exposed publicly
+	if !s.publicSites[site] {
+		http.Error(w, "Public stats are not enabled for this site", http.StatusNotFound)
+		return models.SiteMetric{}, false
+	}
+
+	snapshot := s.snapshotCache.Get()
+	metric, ok := snapshot.Sites[site]
+	if !ok {
+		metric = models.SiteMetric{Host: site}
+	}
+
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", constants.PublicStatsCacheSeconds))
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	return metric, true
+}
+
+// handlePublicStats serves a tiny JSON stats document for an opted-in site
+func (s *Server) handlePublicStats(w http.ResponseWriter, r *http.Request) {
+	metric, ok := s.lookupPublicSite(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(publicSiteStats{
+		Site:           metric.Host,
+		PageViews:      metric.PageViews,
+		UniqueVisitors: metric.UniqueVisitors,
+	})
+}
+
+// handleVisitorsBadge serves an embeddable SVG badge with the site's visitor count
+func (s *Server) handleVisitorsBadge(w http.ResponseWriter, r *http.Request) {
+	metric, ok := s.lookupPublicSite(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, renderBadge("visitors", formatCount(metric.UniqueVisitors)))
+}
+
+// renderBadge renders a two-part flat badge in the style of shields.io
+func renderBadge(label, value string) string {
+	// Approximate text width for an 11px sans-serif font
+	labelWidth := len(label)*7 + 10
+	valueWidth := len(value)*7 + 10
+	totalWidth := labelWidth + valueWidth
+
+	label = html.EscapeString(label)
+	value = html.EscapeString(value)
+
+	return fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%[1]d" height="20" role="img" aria-label="%[4]s: %[5]s">`+
+		`<rect width="%[2]d" height="20" fill="#555"/>`+
+		`<rect x="%[2]d" width="%[3]d" height="20" fill="#4c1"/>`+
+		`<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,sans-serif" font-size="11">`+
+		`<text x="%[6]d" y="14">%[4]s</text>`+
+		`<text x="%[7]d" y="14">%[5]s</text>`+
+		`</g></svg>`,
+		totalWidth, labelWidth, valueWidth, label, value, labelWidth/2, labelWidth+valueWidth/2)
+}
+
+// formatCount formats large counts compactly (e.g. 1.2k, 3.4M)
+func formatCount(n int64) string {
+	switch {
+	case n >= 1000000:
+		return fmt.Sprintf("%.1fM", float64(n)/1000000)
+	case n >= 1000:
+		return fmt.Sprintf("%.1fk", float64(n)/1000)
+	default:
+		return fmt.Sprintf("%d", n)
+	}
+}
+
+// clientIP returns the remote IP address of the request
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/constants"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/ingest"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/logging"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/quality"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/scaling"
+)
+
+// qualityConfig returns the data quality checks from the environment
+func qualityConfig() quality.Config {
+	config := quality.DefaultConfig()
+	config.RequiredFields = constants.QualityRequiredFields
+	config.MaxFutureSkew = time.Duration(constants.QualityMaxFutureSeconds) * time.Second
+	config.MaxAge = time.Duration(constants.QualityMaxAgeHours) * time.Hour
+	config.Window = time.Duration(constants.QualityWindowMinutes) * time.Minute
+	config.DuplicateIDs = constants.QualityDuplicateCacheSize
+	if err := quality.ValidateConfig(config); err != nil {
+		logging.Warn("Invalid QUALITY_REQUIRED_FIELDS, ignoring unknown fields", "error", err)
+	}
+	return config
+}
+
+// checkQuality records the data quality of every event as it was received, before any
+// defaults are filled in. Events of malformed types count as malformed payloads.
+func (s *Server) checkQuality(next ingest.Handler) ingest.Handler {
+	return func(r *http.Request, event *models.AnalyticsEvent) (string, error) {
+		if event.Type.Valid() {
+			s.quality.Observe(event, time.Now())
+		} else {
+			s.quality.Malformed(time.Now())
+		}
+		return next(r, event)
+	}
+}
+
+// handleQuality returns the data quality of received events
+func (s *Server) handleQuality(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.quality.Stats(time.Now()))
+}
+
+// handleMetrics exposes the data quality counters and scaling gauges in the Prometheus
+// text format
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	quality.WritePrometheus(w, "analytics_producer_quality", s.quality.Stats(time.Now()))
+	scaling.WritePrometheus(w, "analytics_producer_scaling", s.scalingMetrics())
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/analytics"
+)
+
+// serveQuery parses the list query parameters, runs the query and writes the page as JSON
+func serveQuery[T any](w http.ResponseWriter, r *http.Request, run func(analytics.Query) (analytics.Paged[T], error)) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query, err := analytics.ParseQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid query: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	page, err := run(query)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid query: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(page)
+}
+
+// handleQueryPages lists tracked pages with pagination, sorting and a path prefix filter
+func (s *Server) handleQueryPages(w http.ResponseWriter, r *http.Request) {
+	serveQuery(w, r, s.analyticsService.QueryPages)
+}
+
+// handleQuerySources lists traffic sources with pagination and sorting
+func (s *Server) handleQuerySources(w http.ResponseWriter, r *http.Request) {
+	serveQuery(w, r, s.analyticsService.QuerySources)
+}
+
+// handleQueryDevices lists one device, browser or OS breakdown with pagination and sorting
+func (s *Server) handleQueryDevices(w http.ResponseWriter, r *http.Request) {
+	serveQuery(w, r, s.analyticsService.QueryDevices)
+}
+
+// handleQueryEvents lists recent events filtered by type, path prefix and time range
+func (s *Server) handleQueryEvents(w http.ResponseWriter, r *http.Request) {
+	serveQuery(w, r, s.analyticsService.QueryEvents)
+}
+
+// handlePathFlow returns the most common page-to-page transitions of session journeys
+func (s *Server) handlePathFlow(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query, err := analytics.ParsePathFlowQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid query: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(s.analyticsService.GetPathFlow(query))
+}
+
+// handleHistoryPages returns the most viewed pages of a time range
+func (s *Server) handleHistoryPages(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	from, to, _, err := parseHistoryRange(r.URL.Query())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid query: %v", err), http.StatusBadRequest)
+		return
+	}
+	limit, err := analytics.ParseTopLimit(r.URL.Query())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid query: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	pages, source, err := s.history.TopPages(r.Context(), from, to, limit)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid query: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"from":   from,
+		"to":     to,
+		"source": source,
+		"pages":  pages,
+	})
+}
+
+// handleHistoryFunnel returns how many visitors of a time range completed each step of
+// a funnel
+func (s *Server) handleHistoryFunnel(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	from, to, _, err := parseHistoryRange(r.URL.Query())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid query: %v", err), http.StatusBadRequest)
+		return
+	}
+	query, err := analytics.ParseFunnelQuery(r.URL.Query(), from, to)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid query: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	funnel, source, err := s.history.Funnel(r.Context(), query)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid query: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"source": source,
+		"funnel": funnel,
+	})
+}
+
+// handleHeatmap returns the click heatmap of the page given by ?url=, or lists the pages
+// with heatmaps when no URL is given
+func (s *Server) handleHeatmap(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	pageURL := r.URL.Query().Get("url")
+	if pageURL == "" {
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"pages": s.analyticsService.GetHeatmapPages(),
+		})
+		return
+	}
+
+	heatmap, ok := s.analyticsService.GetHeatmap(pageURL)
+	if !ok {
+		http.Error(w, "No clicks recorded for this URL", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(heatmap)
+}
+
+// handleActiveVisitors returns the visitors active in the last one and five minutes,
+// with a breakdown by page
+func (s *Server) handleActiveVisitors(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(s.analyticsService.GetActiveVisitors())
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/constants"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/analytics"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/features"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/reload"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/sampling"
+)
+
+// retentionConfig returns the configured in-memory retention
+func retentionConfig() analytics.RetentionConfig {
+	return analytics.RetentionConfig{
+		RecentEvents:    constants.RecentEventsLimit,
+		EventTTL:        time.Duration(constants.EventTTLMinutes) * time.Minute,
+		HourlyWindow:    time.Duration(constants.HourlyRetentionHours) * time.Hour,
+		SessionTimeout:  time.Duration(constants.SessionTimeoutMinutes) * time.Minute,
+		CleanupInterval: time.Duration(constants.AnalyticsCleanupSeconds) * time.Second,
+	}
+}
+
+// newReloader registers the settings the producer applies without a restart: sampling
+// rules, feature flags, retention, broadcast intervals, the saved alert rules and
+// conversion goals
+func (s *Server) newReloader() *reload.Reloader {
+	r := reload.New(constants.ConfigFile, constants.Reload)
+	r.Register(reload.Step{
+		Name: "sampling",
+		Keys: []string{"SAMPLING_RULES"},
+		Apply: func(context.Context) error {
+			rules, err := sampling.ParseRules(constants.SamplingRules)
+			if err != nil {
+				return err
+			}
+			s.sampler.SetRules(rules)
+			return nil
+		},
+	})
+	r.Register(reload.Step{
+		Name: "features",
+		Keys: []string{"FEATURE_FLAGS"},
+		Apply: func(context.Context) error {
+			states, err := features.Parse(constants.FeatureFlags)
+			if err != nil {
+				return err
+			}
+			s.features.Set(states)
+			return nil
+		},
+	})
+	r.Register(reload.Step{
+		Name: "retention",
+		Keys: []string{"RECENT_EVENTS_LIMIT", "EVENT_TTL_MINUTES", "HOURLY_RETENTION_HOURS", "SESSION_TIMEOUT_MINUTES"},
+		Apply: func(context.Context) error {
+			s.analyticsService.SetRetention(retentionConfig())
+			return nil
+		},
+	})
+	r.Register(reload.Step{
+		Name: "broadcast_intervals",
+		Keys: []string{"WS_SNAPSHOT_INTERVAL_SECONDS", "WS_ACTIVE_VISITORS_INTERVAL_SECONDS"},
+		Apply: func(context.Context) error {
+			s.wsHub.SetBroadcastIntervals(
+				time.Duration(constants.WSSnapshotIntervalSeconds)*time.Second,
+				time.Duration(constants.WSActiveVisitorsSeconds)*time.Second,
+			)
+			return nil
+		},
+	})
+	r.Register(reload.Step{
+		Name: "alerts",
+		Apply: func(ctx context.Context) error {
+			return s.analyticsService.LoadAlerts(ctx, s.alertStore)
+		},
+	})
+	r.Register(reload.Step{
+		Name: "goals",
+		Keys: []string{"GOALS"},
+		Apply: func(ctx context.Context) error {
+			goals, err := analytics.ParseGoals(constants.Goals)
+			if err != nil {
+				return err
+			}
+			return s.analyticsService.LoadGoals(ctx, s.goalStore, goals)
+		},
+	})
+	return r
+}
+
+// handleAdminReload re-reads CONFIG_FILE and applies the settings that can change
+// without a restart, like SIGHUP
+func (s *Server) handleAdminReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	result := s.reloader.Reload(r.Context())
+	status := http.StatusOK
+	if len(result.Errors) > 0 {
+		status = http.StatusUnprocessableEntity
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"reload":   result,
+		"features": s.features.All(),
+	})
+}
+
+// handleFeatures lists the feature flags and whether each is enabled
+func (s *Server) handleFeatures(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(s.features.All())
+}
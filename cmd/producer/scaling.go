@@ -0,0 +1,26 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/scaling"
+)
+
+// scalingMetrics returns the producer's load for autoscalers: events received per second
+// and, with a spool, the events waiting in it for Kafka
+func (s *Server) scalingMetrics() scaling.Metrics {
+	metrics := scaling.NewMetrics("producer", s.ingestRate)
+	if s.spool != nil {
+		pending := s.spool.Pending()
+		metrics.QueueDepth = &pending
+	}
+	return metrics
+}
+
+// handleScaling returns the producer's load in the shape KEDA's metrics-api scaler reads
+func (s *Server) handleScaling(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(s.scalingMetrics())
+}
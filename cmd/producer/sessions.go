@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/kafka"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/logging"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/sessions"
+)
+
+// consumeSessionEvents appends the events of the event topics to their session in the
+// session store, and deletes the sessions of users erased by a tombstone
+func (s *Server) consumeSessionEvents(ctx context.Context, consumer kafka.EventConsumer) {
+	err := consumer.ConsumeMessages(ctx, func(message *kafka.Message) error {
+		return s.storeSessionEvent(ctx, message.Event)
+	})
+	if err != nil && ctx.Err() == nil {
+		logging.Error("Session consumer stopped", "error", err)
+	}
+}
+
+// storeSessionEvent adds an event to its session. Events without a valid session ID
+// and sessions at their size limit are skipped rather than retried.
+func (s *Server) storeSessionEvent(ctx context.Context, event *models.AnalyticsEvent) error {
+	switch event.Type {
+	case models.UserErasure:
+		deleted, err := s.sessionStore.DeleteUser(ctx, event.UserID)
+		if err == nil && len(deleted) > 0 {
+			logging.Info("Erased session timelines", "event_id", event.ID, "sessions", len(deleted))
+		}
+		return err
+	case models.MetaEvent, models.SessionReplay:
+		return nil
+	}
+	if !sessions.ValidID(event.SessionID) {
+		return nil
+	}
+	if err := s.sessionStore.Append(ctx, event); err != nil && !errors.Is(err, sessions.ErrSessionFull) {
+		return err
+	}
+	return nil
+}
+
+// expireSessions removes sessions idle for longer than ttl, checking every interval
+func (s *Server) expireSessions(ctx context.Context, ttl, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			removed, err := s.sessionStore.Expire(ctx, now.Add(-ttl))
+			if err != nil {
+				logging.Warn("Failed to expire session timelines", "error", err)
+			} else if removed > 0 {
+				logging.Debug("Expired session timelines", "sessions", removed)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// handleSessionTimeline returns the timeline of the session named by /sessions/{id}
+func (s *Server) handleSessionTimeline(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.sessionStore == nil {
+		http.Error(w, "Session timelines are disabled", http.StatusNotFound)
+		return
+	}
+	sessionID := strings.TrimPrefix(r.URL.Path, "/sessions/")
+	if !sessions.ValidID(sessionID) {
+		http.Error(w, "Invalid session ID", http.StatusBadRequest)
+		return
+	}
+
+	events, err := s.sessionStore.Events(r.Context(), sessionID)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to read session", "session_id", sessionID, "error", err)
+		http.Error(w, "Failed to read session", http.StatusInternalServerError)
+		return
+	}
+	if len(events) == 0 {
+		http.Error(w, "No events recorded for session", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sessions.BuildTimeline(sessionID, events))
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/logging"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/shadow"
+)
+
+// shadowStatsTimeout bounds the requests for the consumers' snapshots
+const shadowStatsTimeout = 5 * time.Second
+
+// shadowConsumers locates the /stats endpoints of the consumers compared by /admin/shadow
+type shadowConsumers struct {
+	primaryURL string
+	shadowURL  string
+	client     *http.Client
+}
+
+// newShadowConsumers compares the consumers serving the given /stats URLs
+func newShadowConsumers(primaryURL, shadowURL string) shadowConsumers {
+	return shadowConsumers{
+		primaryURL: primaryURL,
+		shadowURL:  shadowURL,
+		client:     &http.Client{Timeout: shadowStatsTimeout},
+	}
+}
+
+// mirrorEvent queues an event sent to the primary topics for the shadow topic when the
+// mirror selects it. Shadow writes happen in the background, are never spooled and are
+// dropped when the mirror's queue is full, so the shadow pipeline cannot slow down or
+// fail the primary one.
+func (s *Server) mirrorEvent(event *models.AnalyticsEvent) {
+	if !s.shadow.Selects(event) || s.simulator != nil {
+		return
+	}
+	if !s.shadow.Enqueue(event) {
+		logging.Debug("Shadow queue full, dropping mirrored event", "topic", s.shadow.Topic(), "event_id", event.ID)
+	}
+}
+
+// sendShadow writes a mirrored event to the shadow topic
+func (s *Server) sendShadow(ctx context.Context, event *models.AnalyticsEvent) error {
+	err := s.producer.SendToTopic(ctx, s.shadow.Topic(), s.producer.EventKey(event), event)
+	if err != nil {
+		logging.Debug("Failed to mirror event to shadow topic", "topic", s.shadow.Topic(), "event_id", event.ID, "error", err)
+	}
+	return err
+}
+
+// handleAdminShadow reports what has been mirrored to the shadow topic and, when both
+// consumers' stats URLs are configured, how the shadow consumer's counters compare with
+// the primary consumer's scaled to the mirrored share of traffic
+func (s *Server) handleAdminShadow(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.shadow == nil {
+		http.Error(w, "Shadow mirroring is not enabled", http.StatusNotFound)
+		return
+	}
+
+	response := map[string]interface{}{"mirror": s.shadow.Stats()}
+	if s.shadowConsumers.primaryURL != "" && s.shadowConsumers.shadowURL != "" {
+		if comparison, err := s.compareShadow(r.Context()); err != nil {
+			logging.FromContext(r.Context()).Warn("Failed to compare shadow consumer", "error", err)
+			response["error"] = err.Error()
+		} else {
+			response["comparison"] = comparison
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// compareShadow fetches both consumers' snapshots and compares them
+func (s *Server) compareShadow(ctx context.Context) (shadow.Comparison, error) {
+	ctx, cancel := context.WithTimeout(ctx, shadowStatsTimeout)
+	defer cancel()
+
+	primary, err := shadow.FetchSnapshot(ctx, s.shadowConsumers.client, s.shadowConsumers.primaryURL)
+	if err != nil {
+		return shadow.Comparison{}, err
+	}
+	mirrored, err := shadow.FetchSnapshot(ctx, s.shadowConsumers.client, s.shadowConsumers.shadowURL)
+	if err != nil {
+		return shadow.Comparison{}, err
+	}
+	return shadow.Compare(primary, mirrored, s.shadow.Fraction()), nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/logging"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/simulate"
+)
+
+// runSimulation loads the last backfill period of generated traffic straight into
+// analytics, then feeds live traffic through the event handler every second until
+// the context is cancelled
+func (s *Server) runSimulation(ctx context.Context, sim *simulate.Simulator, backfill time.Duration) {
+	last := time.Now()
+	if backfill > 0 {
+		events := sim.Events(last.Add(-backfill), last)
+		for i := range events {
+			s.analyticsService.ProcessEvent(&events[i])
+		}
+		logging.Info("Backfilled simulated traffic", "events", len(events), "hours", backfill.Hours())
+	}
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			for _, event := range sim.Events(last, now) {
+				s.ingestSimulated(ctx, event)
+			}
+			last = now
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// ingestSimulated passes an event through the ingestion chain with a synthetic /event
+// request, so it takes the same path as tracker requests: bot detection, privacy,
+// sampling, analytics and broadcast
+func (s *Server) ingestSimulated(ctx context.Context, event models.AnalyticsEvent) {
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, "/event", http.NoBody)
+	if err != nil {
+		logging.Error("Failed to create simulated request", "event_id", event.ID, "error", err)
+		return
+	}
+
+	if _, ingestErr := s.ingestEvent(r, &event); ingestErr != nil {
+		logging.Debug("Simulated event rejected", "event_id", event.ID, "status", ingestErr.Status, "code", ingestErr.Code)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/errs"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/logging"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/spool"
+)
+
+// sendEvent writes an event to a topic. With the spool enabled, events are spooled when
+// the write fails with an error worth retrying, and while earlier events are still
+// spooled so they keep their order. Events Kafka can never accept are not spooled.
+func (s *Server) sendEvent(ctx context.Context, topic string, event *models.AnalyticsEvent) error {
+	// Simulated traffic only feeds this producer's analytics
+	if s.simulator != nil {
+		return nil
+	}
+
+	key := s.producer.EventKey(event)
+	if s.spool == nil {
+		return s.producer.SendToTopic(ctx, topic, key, event)
+	}
+
+	record := spool.Record{Topic: topic, Key: key, Event: *event}
+	if s.spool.Pending() > 0 {
+		return s.spool.Append(record)
+	}
+
+	sendErr := s.producer.SendToTopic(ctx, topic, key, event)
+	if sendErr == nil || !errs.Retryable(sendErr) {
+		return sendErr
+	}
+	if err := s.spool.Append(record); err != nil {
+		return errors.Join(sendErr, err)
+	}
+	logging.Warn("Kafka write failed, spooled event", "topic", topic, "event_id", event.ID, "error", sendErr)
+	return nil
+}
+
+// runSpoolDrain periodically sends spooled events once Kafka is reachable again
+func (s *Server) runSpoolDrain(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if s.spool.Pending() == 0 {
+				continue
+			}
+			if err := s.health.ping(ctx); err != nil {
+				continue
+			}
+
+			s.drainSpool(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// drainSpool sends spooled events in order, stopping at the first failure worth
+// retrying. Events Kafka can never accept are dropped, so they don't hold back the
+// events behind them.
+func (s *Server) drainSpool(ctx context.Context) {
+	dropped := 0
+	sent, err := s.spool.Drain(ctx, func(record spool.Record) error {
+		err := s.producer.SendToTopic(ctx, record.Topic, record.Key, record.Event)
+		if err == nil || errs.Retryable(err) {
+			return err
+		}
+		logging.Error("Dropped spooled event Kafka cannot accept", "topic", record.Topic, "event_id", record.Event.ID, "error", err)
+		s.metaEmitter.Emit(models.OperationKafkaWriteError, map[string]interface{}{
+			"event_id": record.Event.ID,
+			"topic":    record.Topic,
+			"error":    err.Error(),
+		})
+		dropped++
+		return nil
+	})
+	if sent > 0 {
+		logging.Info("Sent spooled events to Kafka", "sent", sent-dropped, "dropped", dropped, "pending", s.spool.Pending())
+	}
+	if err != nil && ctx.Err() == nil {
+		logging.Warn("Spool drain stopped", "error", err)
+	}
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/constants"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/analytics"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/bots"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/errs"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/ingest"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/logging"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/replay"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/sampling"
+	"github.com/google/uuid"
+)
+
+// ingestEvent runs a decoded event through the ingestion chain and sends it, returning
+// the status it was acknowledged with: accepted, dropped_bot or sampled_out
+func (s *Server) ingestEvent(r *http.Request, event *models.AnalyticsEvent) (string, *ingest.Error) {
+	s.ingestRate.Add(1)
+	status, err := s.ingestChain.Then(s.sendIngested)(r, event)
+	return status, ingestError(err)
+}
+
+// useIngestStages sets up the ingestion chain: the built-in stages, then the registered
+// stages named in INGEST_MIDDLEWARE in order. Unknown names are skipped with a warning.
+func (s *Server) useIngestStages(names []string) {
+	s.ingestChain.Use(
+		s.checkQuality,
+		s.validateType,
+		s.checkDomain,
+		s.assignDefaults,
+		s.enrichGeo,
+		s.logIngested,
+		s.detectBots,
+		s.scrubEvent,
+		s.sampleEvent,
+		s.validateContent,
+	)
+	for _, name := range names {
+		stage, ok := ingest.Lookup(name)
+		if !ok {
+			logging.Warn("Ignoring unknown ingest middleware", "name", name, "registered", ingest.Registered())
+			continue
+		}
+		s.ingestChain.Use(stage)
+	}
+}
+
+// replayPaths are the endpoints trackers send session replay chunks to
+var replayPaths = map[string]bool{"/event": true, "/events/batch": true, "/collect": true}
+
+// validateType rejects events of malformed types and of the types the pipeline reserves
+// for itself: meta events and erasure tombstones are only written by the producer, the
+// latter by /privacy/erase, and replay chunks only come from trackers. Any other
+// well-formed type is accepted, so custom event types need no registration.
+func (s *Server) validateType(next ingest.Handler) ingest.Handler {
+	return func(r *http.Request, event *models.AnalyticsEvent) (string, error) {
+		if !event.Type.Valid() {
+			s.metaEmitter.Emit(models.OperationIngestError, map[string]interface{}{"error": "invalid event type", "type": string(event.Type)})
+			return "", ingest.Errorf(http.StatusBadRequest, "invalid_event_type", "Invalid event type %q", event.Type)
+		}
+		if event.Type == models.MetaEvent || event.Type == models.UserErasure || (event.Type == models.SessionReplay && !replayPaths[r.URL.Path]) {
+			s.metaEmitter.Emit(models.OperationIngestError, map[string]interface{}{"error": "reserved event type", "type": string(event.Type)})
+			return "", ingest.Errorf(http.StatusBadRequest, "reserved_event_type", "Event type %q is reserved for the pipeline", event.Type)
+		}
+		return next(r, event)
+	}
+}
+
+// checkDomain rejects events for hosts outside ALLOWED_DOMAINS when DOMAIN_POLICY is
+// reject; otherwise analytics counts them apart from the site's pages
+func (s *Server) checkDomain(next ingest.Handler) ingest.Handler {
+	return func(r *http.Request, event *models.AnalyticsEvent) (string, error) {
+		if s.rejectDomains && !s.allowedDomains.DomainAllowed(event.URL) {
+			host := analytics.SiteHost(event.URL)
+			s.metaEmitter.Emit(models.OperationIngestError, map[string]interface{}{"error": "domain not allowed", "host": host})
+			return "", ingest.Errorf(http.StatusForbidden, "domain_not_allowed", "Events for %q are not accepted", host)
+		}
+		return next(r, event)
+	}
+}
+
+// assignDefaults sets the ID and timestamp of events sent without them
+func (s *Server) assignDefaults(next ingest.Handler) ingest.Handler {
+	return func(r *http.Request, event *models.AnalyticsEvent) (string, error) {
+		if event.ID == "" {
+			event.ID = uuid.New().String()
+		}
+		if event.Timestamp.IsZero() {
+			event.Timestamp = time.Now()
+		}
+		return next(r, event)
+	}
+}
+
+// geoHeaders names the request headers a CDN sets with the visitor's location
+type geoHeaders struct {
+	country string
+	region  string
+}
+
+// enrichGeo sets the country and region of events sent without them from the configured
+// GeoIP headers
+func (s *Server) enrichGeo(next ingest.Handler) ingest.Handler {
+	return func(r *http.Request, event *models.AnalyticsEvent) (string, error) {
+		if s.geoHeaders.country == "" {
+			return next(r, event)
+		}
+		if _, ok := event.Metadata[models.MetadataCountry]; ok {
+			return next(r, event)
+		}
+
+		country := models.NormalizeCountry(r.Header.Get(s.geoHeaders.country))
+		if country == "" {
+			return next(r, event)
+		}
+		if event.Metadata == nil {
+			event.Metadata = make(map[string]interface{})
+		}
+		event.Metadata[models.MetadataCountry] = country
+		if s.geoHeaders.region != "" {
+			if region := r.Header.Get(s.geoHeaders.region); region != "" {
+				event.Metadata[models.MetadataRegion] = region
+			}
+		}
+		return next(r, event)
+	}
+}
+
+// logIngested logs the outcome of every event at debug level
+func (s *Server) logIngested(next ingest.Handler) ingest.Handler {
+	return func(r *http.Request, event *models.AnalyticsEvent) (string, error) {
+		status, err := next(r, event)
+		logger := logging.FromContext(r.Context()).With("event_id", event.ID, "event_type", event.Type)
+		if err != nil {
+			logger.Debug("Event rejected", "error", err)
+		} else {
+			logger.Debug("Event acknowledged", "status", status)
+		}
+		return status, err
+	}
+}
+
+// detectBots flags bot traffic so consumers see the same detection. Dropped bots are
+// only counted in the bot stats and acknowledged without being sent to Kafka.
+func (s *Server) detectBots(next ingest.Handler) ingest.Handler {
+	return func(r *http.Request, event *models.AnalyticsEvent) (string, error) {
+		if result := s.botDetector.Detect(event); result.Bot {
+			bots.Flag(event, result)
+			if s.botPolicy == bots.PolicyDrop {
+				s.analyticsService.ProcessEvent(event)
+				return ingest.StatusDroppedBot, nil
+			}
+		}
+		return next(r, event)
+	}
+}
+
+// scrubEvent anonymizes IPs, user IDs and metadata before the event reaches Kafka, the
+// spool or analytics
+func (s *Server) scrubEvent(next ingest.Handler) ingest.Handler {
+	return func(r *http.Request, event *models.AnalyticsEvent) (string, error) {
+		if s.scrubber != nil {
+			s.scrubber.Scrub(event)
+		}
+		return next(r, event)
+	}
+}
+
+// sampleEvent acknowledges sampled-out events so trackers don't retry them, and rejects
+// throttled events so trackers can back off
+func (s *Server) sampleEvent(next ingest.Handler) ingest.Handler {
+	return func(r *http.Request, event *models.AnalyticsEvent) (string, error) {
+		switch s.sampler.Apply(event) {
+		case sampling.SampledOut:
+			return ingest.StatusSampledOut, nil
+		case sampling.Throttled:
+			return "", ingest.Errorf(http.StatusTooManyRequests, "throttled", "Rate limit exceeded for %s events", event.Type)
+		}
+		return next(r, event)
+	}
+}
+
+// validateContent checks the payload of event types that carry structured details
+func (s *Server) validateContent(next ingest.Handler) ingest.Handler {
+	return func(r *http.Request, event *models.AnalyticsEvent) (string, error) {
+		// E-commerce events must carry well-formed order details
+		if event.Type.IsCommerce() {
+			if _, err := models.OrderFromEvent(event, constants.CommerceCurrency); err != nil {
+				s.metaEmitter.Emit(models.OperationIngestError, map[string]interface{}{"error": err.Error(), "type": string(event.Type)})
+				return "", ingest.Errorf(http.StatusBadRequest, "invalid_event", "Invalid %s event: %v", event.Type, err)
+			}
+		}
+
+		// Error events must carry a message so they can be grouped
+		if event.Type == models.Error {
+			if _, err := models.ErrorFromEvent(event); err != nil {
+				s.metaEmitter.Emit(models.OperationIngestError, map[string]interface{}{"error": err.Error(), "type": string(event.Type)})
+				return "", ingest.Errorf(http.StatusBadRequest, "invalid_event", "Invalid error event: %v", err)
+			}
+		}
+
+		// Replay chunks are validated up front since they are only stored, never aggregated
+		if event.Type == models.SessionReplay {
+			if _, err := replay.ChunkFromEvent(event); err != nil {
+				return "", ingest.Errorf(http.StatusBadRequest, "invalid_event", "Invalid session replay event: %v", err)
+			}
+		}
+		return next(r, event)
+	}
+}
+
+// sendIngested ends the chain: it sends the event to its topics, then counts it and
+// broadcasts it to WebSocket clients
+func (s *Server) sendIngested(r *http.Request, event *models.AnalyticsEvent) (string, error) {
+	logger := logging.FromContext(r.Context()).With("event_id", event.ID, "event_type", event.Type)
+
+	// Sends outlive the request, so a client disconnecting doesn't abandon a write
+	for _, topic := range s.router.Route(event) {
+		if err := s.sendEvent(context.Background(), topic, event); err != nil {
+			logger.Error("Failed to send event", "topic", topic, "error", err)
+			s.metaEmitter.Emit(models.OperationKafkaWriteError, map[string]interface{}{
+				"event_id": event.ID,
+				"topic":    topic,
+				"error":    err.Error(),
+			})
+			// Events Kafka can never accept, such as oversized ones, must not be resent
+			if !errs.Retryable(err) {
+				return "", ingest.Errorf(httpStatus(err), errs.Code(err), "Event cannot be sent: %v", err)
+			}
+			// 503 while Kafka is unavailable, 500 for other failures
+			return "", ingest.Errorf(httpStatus(err), "send_failed", "Failed to send event")
+		}
+	}
+
+	// Replay chunks are only stored by the replay writer
+	if event.Type != models.SessionReplay {
+		s.mirrorEvent(event)
+		if err := s.analyticsService.ProcessEvent(event); err != nil {
+			logger.Error("Failed to process analytics event", "error", err)
+		}
+		s.wsHub.BroadcastEvent(event)
+	}
+	return ingest.StatusAccepted, nil
+}
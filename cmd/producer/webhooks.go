@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/constants"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/ingest"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/logging"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/webhooks"
+)
+
+// newWebhookAdapters returns the webhook providers whose signing secret is configured,
+// by name
+func newWebhookAdapters() map[string]webhooks.Adapter {
+	adapters := make(map[string]webhooks.Adapter)
+	if constants.StripeWebhookSecret != "" {
+		adapters["stripe"] = webhooks.NewStripe(constants.StripeWebhookSecret)
+	}
+	if constants.GitHubWebhookSecret != "" {
+		adapters["github"] = webhooks.NewGitHub(constants.GitHubWebhookSecret)
+	}
+	if constants.GenericWebhookSecret != "" {
+		adapters["generic"] = webhooks.NewGeneric(constants.GenericWebhookSecret)
+	}
+	return adapters
+}
+
+// handleWebhook receives /webhooks/{provider}: it checks the provider's signature, then
+// ingests the events the delivery translates to like /events/batch. Deliveries are
+// acknowledged with 202 even when some events are rejected, since providers would
+// otherwise redeliver them unchanged; only send failures return 500 to be retried.
+func (s *Server) handleWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	adapter, ok := s.webhookAdapters[strings.TrimPrefix(r.URL.Path, "/webhooks/")]
+	if !ok {
+		http.Error(w, "Unknown webhook provider", http.StatusNotFound)
+		return
+	}
+
+	body, ingestErr := readIngestBody(w, r, int64(constants.MaxBatchBytes), constants.MaxJSONDepth)
+	if ingestErr != nil {
+		s.metaEmitter.Emit(models.OperationIngestError, map[string]interface{}{"error": ingestErr.Message, "provider": adapter.Name()})
+		writeIngestError(w, ingestErr)
+		return
+	}
+	logger := logging.FromContext(r.Context()).With("provider", adapter.Name())
+	if err := adapter.Verify(r.Header, body); err != nil {
+		logger.Warn("Rejected webhook", "error", err)
+		if !errors.Is(err, webhooks.ErrInvalidSignature) {
+			err = webhooks.ErrInvalidSignature
+		}
+		writeIngestError(w, ingest.Errorf(http.StatusUnauthorized, "invalid_signature", "%v", err))
+		return
+	}
+
+	events, err := adapter.Translate(r.Header, body)
+	if err != nil {
+		s.metaEmitter.Emit(models.OperationIngestError, map[string]interface{}{"error": err.Error(), "provider": adapter.Name()})
+		writeIngestError(w, ingest.Errorf(http.StatusBadRequest, "invalid_payload", "Invalid %s webhook: %v", adapter.Name(), err))
+		return
+	}
+	if len(events) > constants.MaxBatchEvents {
+		writeIngestError(w, &ingest.Error{Status: http.StatusRequestEntityTooLarge, Code: "too_many_events",
+			Message: "Webhook translates to too many events", Limit: int64(constants.MaxBatchEvents)})
+		return
+	}
+
+	results := make([]batchResult, len(events))
+	accepted, serverError := 0, false
+	for i := range events {
+		status, ingestErr := s.ingestEvent(r, &events[i])
+		results[i] = batchResult{Index: i, ID: events[i].ID}
+		if ingestErr != nil {
+			results[i].Status, results[i].Code, results[i].Error = "rejected", ingestErr.Code, ingestErr.Message
+			serverError = serverError || ingestErr.Status >= http.StatusInternalServerError
+			continue
+		}
+		results[i].Status = status
+		accepted++
+	}
+
+	code := http.StatusAccepted
+	if serverError {
+		code = http.StatusInternalServerError
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"accepted": accepted,
+		"rejected": len(events) - accepted,
+		"results":  results,
+	})
+}
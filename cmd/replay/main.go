@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/constants"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/analytics"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/bots"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/kafka"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/store"
+	"github.com/google/uuid"
+)
+
+// Events between history flushes while rebuilding, so hours are persisted
+// before the analytics service prunes them from memory
+const historyFlushEvery = 10000
+
+// options are the command line flags
+type options struct {
+	brokers     string
+	topic       string
+	partition   int
+	fromOffset  int64
+	toOffset    int64
+	fromTime    string
+	toTime      string
+	targetTopic string
+	newIDs      bool
+	historyDir  string
+}
+
+func parseFlags() options {
+	var opts options
+	flag.StringVar(&opts.brokers, "brokers", constants.KafkaBrokers, "Comma-separated Kafka brokers")
+	flag.StringVar(&opts.topic, "topic", constants.KafkaTopic, "Topic to read events from")
+	flag.IntVar(&opts.partition, "partition", -1, "Partition to read, or -1 for all partitions")
+	flag.Int64Var(&opts.fromOffset, "from-offset", 0, "First offset to read in each partition")
+	flag.Int64Var(&opts.toOffset, "to-offset", -1, "Offset to stop at (exclusive) in each partition, or -1 for the current end")
+	flag.StringVar(&opts.fromTime, "from", "", "Start at this RFC 3339 time instead of -from-offset")
+	flag.StringVar(&opts.toTime, "to", "", "Stop at this RFC 3339 time (exclusive)")
+	flag.StringVar(&opts.targetTopic, "target-topic", "", "Re-publish events to this topic instead of rebuilding analytics")
+	flag.BoolVar(&opts.newIDs, "new-ids", false, "Give re-published events new IDs so consumer deduplication does not drop them")
+	flag.StringVar(&opts.historyDir, "history-dir", "", "Persist rebuilt hourly rollups to this history store directory")
+	flag.Parse()
+	return opts
+}
+
+// rangeFromOptions converts the flags into a Kafka read range
+func rangeFromOptions(opts options) (kafka.Range, error) {
+	r := kafka.Range{
+		Partition:  opts.partition,
+		FromOffset: opts.fromOffset,
+		ToOffset:   opts.toOffset,
+	}
+
+	var err error
+	if opts.fromTime != "" {
+		if r.FromTime, err = time.Parse(time.RFC3339, opts.fromTime); err != nil {
+			return r, fmt.Errorf("invalid -from time: %w", err)
+		}
+	}
+	if opts.toTime != "" {
+		if r.ToTime, err = time.Parse(time.RFC3339, opts.toTime); err != nil {
+			return r, fmt.Errorf("invalid -to time: %w", err)
+		}
+	}
+	if !r.FromTime.IsZero() && !r.ToTime.IsZero() && !r.FromTime.Before(r.ToTime) {
+		return r, fmt.Errorf("-from must be before -to")
+	}
+	return r, nil
+}
+
+func main() {
+	opts := parseFlags()
+
+	readRange, err := rangeFromOptions(opts)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Stop reading on interrupt; partial results are still reported
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigChan
+		log.Println("Received shutdown signal, stopping replay...")
+		cancel()
+	}()
+
+	brokers := strings.Split(opts.brokers, ",")
+	if opts.targetTopic != "" {
+		republish(ctx, brokers, readRange, opts)
+		return
+	}
+	rebuild(ctx, brokers, readRange, opts)
+}
+
+// republish copies the selected events to the target topic
+func republish(ctx context.Context, brokers []string, readRange kafka.Range, opts options) {
+	if opts.targetTopic == opts.topic {
+		log.Fatal("-target-topic must differ from -topic")
+	}
+
+	producer := kafka.NewProducer(brokers, opts.targetTopic)
+	defer producer.Close()
+
+	// Key and partition like the producer service so per-key ordering is preserved
+	keyStrategy, err := kafka.ParseKeyStrategy(constants.KafkaPartitionKey)
+	if err != nil {
+		log.Fatalf("Invalid KAFKA_PARTITION_KEY: %v", err)
+	}
+	balancer, err := kafka.NewBalancer(constants.KafkaBalancer)
+	if err != nil {
+		log.Fatalf("Invalid KAFKA_BALANCER: %v", err)
+	}
+	producer.SetKeyStrategy(keyStrategy)
+	producer.SetBalancer(balancer)
+
+	compression, err := kafka.ParseCompression(constants.KafkaCompression)
+	if err != nil {
+		log.Fatalf("Invalid KAFKA_COMPRESSION: %v", err)
+	}
+	producer.SetCompression(compression)
+
+	log.Printf("Re-publishing events from %s to %s", opts.topic, opts.targetTopic)
+	stats, err := kafka.ReadRange(ctx, brokers, opts.topic, readRange, func(msg *kafka.Message) error {
+		if opts.newIDs {
+			msg.Event.ID = uuid.New().String()
+		}
+		return producer.SendToTopic(ctx, opts.targetTopic, producer.EventKey(msg.Event), msg.Event)
+	})
+	report(stats, err)
+}
+
+// rebuild feeds the selected events into a fresh analytics service and optionally
+// persists the resulting hourly rollups
+func rebuild(ctx context.Context, brokers []string, readRange kafka.Range, opts options) {
+	analyticsService := analytics.NewService()
+	botPolicy, err := bots.ResolvePolicy(constants.BotPolicy, constants.ExcludeBots)
+	if err != nil {
+		log.Fatalf("Invalid BOT_POLICY: %v", err)
+	}
+	botDetector, err := bots.NewDetector(bots.Config{UserAgentPatterns: constants.BotUserAgents, IPRanges: constants.BotIPRanges})
+	if err != nil {
+		log.Fatalf("Invalid BOT_IP_RANGES: %v", err)
+	}
+	analyticsService.SetBotPolicy(botPolicy, botDetector)
+	analyticsService.SetCampaignGoal(analytics.ParseGoal(constants.CampaignGoal))
+	channelRules, err := analytics.ParseChannelRules(constants.ChannelRules)
+	if err != nil {
+		log.Fatalf("Invalid CHANNEL_RULES: %v", err)
+	}
+	analyticsService.SetChannelRules(channelRules)
+	analyticsService.SetCommerceCurrency(constants.CommerceCurrency)
+	analyticsService.SetURLRules(analytics.URLRules{
+		Normalize:      constants.NormalizePageURLs,
+		KeepParams:     constants.PageURLKeepParams,
+		VariantParams:  constants.PageURLVariantParams,
+		AllowedDomains: constants.AllowedDomains,
+	})
+
+	customRules, err := analytics.ParseCustomMetricRules(constants.CustomMetrics)
+	if err != nil {
+		log.Fatalf("Invalid CUSTOM_METRICS: %v", err)
+	}
+	for _, rule := range customRules {
+		analyticsService.RegisterCustomMetric(rule)
+	}
+
+	var history *analytics.History
+	if opts.historyDir != "" {
+		historyStore, err := store.NewFileStore(opts.historyDir)
+		if err != nil {
+			log.Fatalf("Failed to open history store: %v", err)
+		}
+		defer historyStore.Close()
+		history = analytics.NewHistory(analyticsService, historyStore)
+	}
+
+	log.Printf("Rebuilding analytics from %s", opts.topic)
+	var processed int64
+	stats, err := kafka.ReadRange(ctx, brokers, opts.topic, readRange, func(msg *kafka.Message) error {
+		// Replay chunks are not analytics events
+		if msg.Event.Type == models.SessionReplay {
+			return nil
+		}
+		if err := analyticsService.ProcessEvent(msg.Event); err != nil {
+			return err
+		}
+
+		processed++
+		if history != nil && processed%historyFlushEvery == 0 {
+			if err := history.Flush(ctx); err != nil {
+				log.Printf("History flush failed: %v", err)
+			}
+		}
+		return nil
+	})
+
+	if history != nil {
+		// Use a fresh context so an interrupted replay still persists what it rebuilt
+		flushCtx, flushCancel := context.WithTimeout(context.Background(), 30*time.Second)
+		if err := history.Flush(flushCtx); err != nil {
+			log.Printf("Final history flush failed: %v", err)
+		}
+		flushCancel()
+	}
+
+	snapshot := analyticsService.GetSnapshot()
+	fmt.Println("\n=== Rebuilt Analytics ===")
+	fmt.Printf("Total Events: %d\n", snapshot.TotalEvents)
+	fmt.Printf("Unique Users: %d\n", snapshot.UniqueUsers)
+	for eventType, count := range snapshot.EventsByType {
+		fmt.Printf("  %s: %d\n", eventType, count)
+	}
+	report(stats, err)
+}
+
+// report prints the read statistics and exits non-zero if the read failed
+func report(stats kafka.RangeStats, err error) {
+	fmt.Printf("\nMessages read: %d, decode errors: %d, failed: %d\n",
+		stats.Messages, stats.DecodeErrors, stats.HandlerErrors)
+	if errors.Is(err, context.Canceled) {
+		log.Println("Replay interrupted")
+		return
+	}
+	if err != nil {
+		log.Fatalf("Replay failed: %v", err)
+	}
+}
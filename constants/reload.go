@@ -0,0 +1,32 @@
+package constants
+
+import "github.com/Hilina-t/go-kafka-analytics-pipeline/utils"
+
+// ReloadableKeys are the variables Reload re-reads; changes to any others take effect on
+// restart
+var ReloadableKeys = []string{
+	"SAMPLING_RULES",
+	"FEATURE_FLAGS",
+	"WS_SNAPSHOT_INTERVAL_SECONDS",
+	"WS_ACTIVE_VISITORS_INTERVAL_SECONDS",
+	"RECENT_EVENTS_LIMIT",
+	"EVENT_TTL_MINUTES",
+	"HOURLY_RETENTION_HOURS",
+	"SESSION_TIMEOUT_MINUTES",
+	"GOALS",
+}
+
+// Reload re-reads the settings that can change while the pipeline runs from the
+// environment, with the same defaults as at startup. It must not run concurrently with
+// readers of these settings.
+func Reload() {
+	SamplingRules = utils.GetEnv("SAMPLING_RULES", "")
+	FeatureFlags = utils.GetEnv("FEATURE_FLAGS", "")
+	WSSnapshotIntervalSeconds = utils.GetEnvInt("WS_SNAPSHOT_INTERVAL_SECONDS", 5)
+	WSActiveVisitorsSeconds = utils.GetEnvInt("WS_ACTIVE_VISITORS_INTERVAL_SECONDS", 1)
+	RecentEventsLimit = utils.GetEnvInt("RECENT_EVENTS_LIMIT", 100)
+	EventTTLMinutes = utils.GetEnvInt("EVENT_TTL_MINUTES", 0)
+	HourlyRetentionHours = utils.GetEnvInt("HOURLY_RETENTION_HOURS", 48)
+	SessionTimeoutMinutes = utils.GetEnvInt("SESSION_TIMEOUT_MINUTES", 30)
+	Goals = utils.GetEnv("GOALS", "")
+}
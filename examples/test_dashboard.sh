@@ -94,8 +94,10 @@ for i in {1..8}; do
     "element_id": "$element_id",
     "element_type": "button",
     "element_text": "$(echo $element_id | tr '-' ' ' | tr '[:lower:]' '[:upper:]')",
-    "x_position": $((RANDOM % 1000)),
-    "y_position": $((RANDOM % 800))
+    "x_position": $((RANDOM % 1280)),
+    "y_position": $((RANDOM % 720)),
+    "screen_width": 1280,
+    "screen_height": 720
   }
 }
 EOF
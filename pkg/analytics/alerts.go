@@ -0,0 +1,563 @@
+package analytics
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/errs"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/store"
+)
+
+const (
+	// Re-notify interval for active alerts whose config has no cooldown
+	defaultAlertCooldown = 15 * time.Minute
+
+	// Number of resolved alerts kept in history
+	maxAlertHistory = 100
+
+	// Number of recently fired alerts kept for new dashboard clients and /alerts/recent
+	maxRecentAlerts = 50
+)
+
+var (
+	// ErrAlertNotFound is returned when no alert configuration has the given name
+	ErrAlertNotFound = errs.Errorf(errs.ErrNotFound, "alert config not found")
+
+	// ErrAlertExists is returned when creating an alert configuration whose name is taken
+	ErrAlertExists = errs.Errorf(errs.ErrConflict, "alert config already exists")
+)
+
+// alertMetrics lists the snapshot metrics alerts can be configured on
+var alertMetrics = map[string]bool{
+	"total_events":      true,
+	"page_views":        true,
+	"unique_users":      true,
+	"active_sessions":   true,
+	"average_load_time": true,
+	"error_rate":        true,
+	"errors_per_minute": true,
+
+	// Data quality rates, when quality is tracked
+	"malformed_rate":         true,
+	"incomplete_rate":        true,
+	"timestamp_anomaly_rate": true,
+	"duplicate_rate":         true,
+
+	// Pipeline latency percentiles, when latency is tracked
+	"pipeline_latency_p95": true,
+	"broker_latency_p95":   true,
+}
+
+// alertState tracks an active alert between evaluations
+type alertState struct {
+	alert        models.Alert
+	lastNotified time.Time
+}
+
+// DefaultAlerts returns the built-in alert configurations
+func DefaultAlerts() []models.AlertConfig {
+	return []models.AlertConfig{
+		{
+			Name:          "High Load Time Alert",
+			Type:          "performance",
+			Metric:        "average_load_time",
+			Threshold:     5000, // 5 seconds
+			Operator:      "gt",
+			Enabled:       true,
+			WindowMinutes: 5,
+		},
+		{
+			Name:          "High Error Rate Alert",
+			Type:          "error",
+			Metric:        "error_rate",
+			Threshold:     0.05, // 5% of events
+			Operator:      "gt",
+			Enabled:       true,
+			WindowMinutes: 5,
+		},
+		{
+			Name:          "High Pipeline Latency Alert",
+			Type:          "performance",
+			Metric:        "pipeline_latency_p95",
+			Threshold:     60000, // 1 minute from event to processing
+			Operator:      "gt",
+			Enabled:       true,
+			WindowMinutes: 5,
+		},
+		{
+			Name:          "Traffic Surge Alert",
+			Type:          "traffic",
+			Metric:        "total_events",
+			Threshold:     1000, // 1000 events
+			Operator:      "gt",
+			Enabled:       true,
+			WindowMinutes: 5,
+		},
+	}
+}
+
+// AddAlert adds a new alert configuration
+func (s *Service) AddAlert(config models.AlertConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.alerts = append(s.alerts, config)
+	s.trackAlertWindows()
+}
+
+// ValidateAlertConfig checks that an alert configuration can be evaluated
+func ValidateAlertConfig(config models.AlertConfig) error {
+	if strings.TrimSpace(config.Name) == "" {
+		return errs.Errorf(errs.ErrInvalidRequest, "alert name is required")
+	}
+	if !alertMetrics[config.Metric] {
+		return errs.Errorf(errs.ErrInvalidRequest, "unsupported alert metric %q", config.Metric)
+	}
+	switch config.Operator {
+	case "gt", "lt", "eq", OperatorPctIncrease, OperatorPctDecrease:
+	default:
+		return errs.Errorf(errs.ErrInvalidRequest, "unsupported alert operator %q (use gt, lt, eq, pct_increase or pct_decrease)", config.Operator)
+	}
+	if config.WindowMinutes < 0 || config.CooldownMinutes < 0 {
+		return errs.Errorf(errs.ErrInvalidRequest, "alert window and cooldown must not be negative")
+	}
+	if config.Path != "" && !strings.HasPrefix(config.Path, "/") {
+		return errs.Errorf(errs.ErrInvalidRequest, "alert path must start with /")
+	}
+	if isWindowed(config) {
+		if !windowedAlertMetrics[config.Metric] {
+			return errs.Errorf(errs.ErrInvalidRequest, "metric %q cannot be evaluated per path or against the previous window", config.Metric)
+		}
+		if alertWindow(config) > maxAlertWindow {
+			return errs.Errorf(errs.ErrInvalidRequest, "alert window must be at most %d minutes", int(maxAlertWindow/time.Minute))
+		}
+	}
+	if isRelative(config) && config.Threshold <= 0 {
+		return errs.Errorf(errs.ErrInvalidRequest, "relative alert threshold must be a positive percentage")
+	}
+	return nil
+}
+
+// LoadAlerts replaces the alert configurations with those saved in the store,
+// falling back to DefaultAlerts when nothing has been saved yet
+func (s *Service) LoadAlerts(ctx context.Context, st store.AlertConfigStore) error {
+	configs, ok, err := st.LoadAlertConfigs(ctx)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		configs = DefaultAlerts()
+	}
+	return s.SetAlerts(configs)
+}
+
+// AlertConfigs returns the current alert configurations in evaluation order
+func (s *Service) AlertConfigs() []models.AlertConfig {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]models.AlertConfig{}, s.alerts...)
+}
+
+// AlertConfig returns the alert configuration with the given name
+func (s *Service) AlertConfig(name string) (models.AlertConfig, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if i := s.alertIndex(name); i >= 0 {
+		return s.alerts[i], true
+	}
+	return models.AlertConfig{}, false
+}
+
+// SetAlerts validates and replaces all alert configurations, clearing state of removed alerts
+func (s *Service) SetAlerts(configs []models.AlertConfig) error {
+	names := make(map[string]bool, len(configs))
+	for _, config := range configs {
+		if err := ValidateAlertConfig(config); err != nil {
+			return err
+		}
+		if names[config.Name] {
+			return errs.Errorf(errs.ErrInvalidRequest, "duplicate alert name %q", config.Name)
+		}
+		names[config.Name] = true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.alerts = append([]models.AlertConfig{}, configs...)
+	for name := range s.activeAlerts {
+		if !names[name] {
+			delete(s.activeAlerts, name)
+		}
+	}
+	s.trackAlertWindows()
+	return nil
+}
+
+// CreateAlert adds a validated alert configuration with a unique name
+func (s *Service) CreateAlert(config models.AlertConfig) error {
+	if err := ValidateAlertConfig(config); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.alertIndex(config.Name) >= 0 {
+		return ErrAlertExists
+	}
+	s.alerts = append(s.alerts, config)
+	s.trackAlertWindows()
+	return nil
+}
+
+// UpdateAlert replaces the named alert configuration. An active alert stays active
+// and is re-evaluated against the new condition on the next check.
+func (s *Service) UpdateAlert(name string, config models.AlertConfig) error {
+	if config.Name != name {
+		return errs.Errorf(errs.ErrInvalidRequest, "alert name cannot be changed")
+	}
+	if err := ValidateAlertConfig(config); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.alertIndex(name)
+	if i < 0 {
+		return ErrAlertNotFound
+	}
+	s.alerts[i] = config
+	s.trackAlertWindows()
+	return nil
+}
+
+// SetAlertEnabled enables or disables the named alert; a disabled active alert resolves on the next check
+func (s *Service) SetAlertEnabled(name string, enabled bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.alertIndex(name)
+	if i < 0 {
+		return ErrAlertNotFound
+	}
+	s.alerts[i].Enabled = enabled
+	return nil
+}
+
+// RemoveAlert deletes the named alert configuration and drops its active alert without notifying
+func (s *Service) RemoveAlert(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.alertIndex(name)
+	if i < 0 {
+		return ErrAlertNotFound
+	}
+	s.alerts = append(s.alerts[:i], s.alerts[i+1:]...)
+	delete(s.activeAlerts, name)
+	s.trackAlertWindows()
+	return nil
+}
+
+// alertIndex returns the position of the named configuration, or -1; the caller must hold s.mu
+func (s *Service) alertIndex(name string) int {
+	for i, config := range s.alerts {
+		if config.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// CheckAlerts evaluates all alert conditions and returns the notifications to deliver.
+// Alerts are deduplicated by config name: a condition that stays triggered produces one
+// notification when it fires and then at most one per cooldown interval, and a resolved
+// notification (Resolved set) once the metric no longer meets the condition.
+//
+// Rules are evaluated against a copy of the configuration without holding s.mu; a rule
+// changed or removed meanwhile is skipped and evaluated in its new form next time.
+func (s *Service) CheckAlerts() []models.Alert {
+	s.alertCheckMu.Lock()
+	defer s.alertCheckMu.Unlock()
+
+	configs := s.AlertConfigs()
+	snapshot := s.GetSnapshot()
+	now := time.Now()
+	evaluations := make([]alertEvaluation, len(configs))
+	for i, alertConfig := range configs {
+		evaluations[i] = s.evaluateAlert(alertConfig, snapshot, now)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var notifications []models.Alert
+	for i, alertConfig := range configs {
+		if j := s.alertIndex(alertConfig.Name); j < 0 || s.alerts[j] != alertConfig {
+			continue
+		}
+		state, active := s.activeAlerts[alertConfig.Name]
+
+		evaluation := evaluations[i]
+		currentValue := evaluation.current
+		triggered := alertConfig.Enabled && evaluation.triggered
+
+		switch {
+		case triggered && !active:
+			alert := models.Alert{
+				ID:            alertID(alertConfig.Name, now),
+				Name:          alertConfig.Name,
+				Type:          alertConfig.Type,
+				Message:       s.generateAlertMessage(alertConfig, evaluation),
+				Severity:      s.getAlertSeverity(alertConfig.Type),
+				Timestamp:     now,
+				FiredAt:       now,
+				Notifications: 1,
+				Threshold:     alertConfig.Threshold,
+				CurrentValue:  currentValue,
+			}
+			s.activeAlerts[alertConfig.Name] = &alertState{alert: alert, lastNotified: now}
+			s.recordRecentAlert(alert)
+			notifications = append(notifications, alert)
+
+		case triggered && active:
+			state.alert.CurrentValue = currentValue
+			state.alert.Message = s.generateAlertMessage(alertConfig, evaluation)
+
+			// Re-notify only once the cooldown has elapsed
+			if now.Sub(state.lastNotified) >= alertCooldown(alertConfig) {
+				state.lastNotified = now
+				state.alert.Timestamp = now
+				state.alert.Notifications++
+				s.recordRecentAlert(state.alert)
+				notifications = append(notifications, state.alert)
+			}
+
+		case !triggered && active:
+			resolved := state.alert
+			resolved.Resolved = true
+			resolved.ResolvedAt = &now
+			resolved.Timestamp = now
+			resolved.CurrentValue = currentValue
+			resolved.Message = fmt.Sprintf("Resolved: %s - %s", alertConfig.Name, describeAlert(alertConfig, evaluation))
+
+			delete(s.activeAlerts, alertConfig.Name)
+			s.recordAlertHistory(resolved)
+			s.recordRecentAlert(resolved)
+			notifications = append(notifications, resolved)
+		}
+	}
+
+	return notifications
+}
+
+// GetActiveAlerts returns the currently active alerts
+func (s *Service) GetActiveAlerts() []models.Alert {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]models.Alert, 0, len(s.activeAlerts))
+	for _, state := range s.activeAlerts {
+		result = append(result, state.alert)
+	}
+	return result
+}
+
+// GetAlertHistory returns resolved alerts, most recent first
+func (s *Service) GetAlertHistory() []models.Alert {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]models.Alert, len(s.alertHistory))
+	for i, alert := range s.alertHistory {
+		result[len(s.alertHistory)-1-i] = alert
+	}
+	return result
+}
+
+// recordAlertHistory appends a resolved alert, keeping the most recent entries
+func (s *Service) recordAlertHistory(alert models.Alert) {
+	s.alertHistory = append(s.alertHistory, alert)
+	if len(s.alertHistory) > maxAlertHistory {
+		s.alertHistory = s.alertHistory[1:]
+	}
+}
+
+// recordRecentAlert updates the recent entry of the alert's firing, or adds one and drops
+// the oldest beyond maxRecentAlerts. The caller must hold s.mu.
+func (s *Service) recordRecentAlert(alert models.Alert) {
+	for i := len(s.recentAlerts) - 1; i >= 0; i-- {
+		if s.recentAlerts[i].ID == alert.ID {
+			s.recentAlerts[i] = alert
+			return
+		}
+	}
+	s.recentAlerts = append(s.recentAlerts, alert)
+	if len(s.recentAlerts) > maxRecentAlerts {
+		s.recentAlerts = s.recentAlerts[len(s.recentAlerts)-maxRecentAlerts:]
+	}
+}
+
+// RecentAlerts returns the latest state of recently fired alerts, most recently fired
+// first, up to limit (all kept when limit is not positive). unresolved leaves out
+// alerts that have resolved.
+func (s *Service) RecentAlerts(limit int, unresolved bool) []models.Alert {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]models.Alert, 0, len(s.recentAlerts))
+	for i := len(s.recentAlerts) - 1; i >= 0; i-- {
+		if limit > 0 && len(result) == limit {
+			break
+		}
+		if alert := s.recentAlerts[i]; !unresolved || !alert.Resolved {
+			result = append(result, alert)
+		}
+	}
+	return result
+}
+
+// LoadRecentAlerts restores recent alerts saved by a previous run. Unresolved alerts
+// whose rule still exists become active again, so they are re-notified after their
+// cooldown or resolved on the next check, and resolved ones return to the history.
+func (s *Service) LoadRecentAlerts(ctx context.Context, st store.AlertLogStore) error {
+	alerts, err := st.LoadRecentAlerts(ctx)
+	if err != nil {
+		return err
+	}
+	if len(alerts) > maxRecentAlerts {
+		alerts = alerts[len(alerts)-maxRecentAlerts:]
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.recentAlerts = append([]models.Alert(nil), alerts...)
+	for _, alert := range alerts {
+		if alert.Resolved {
+			s.recordAlertHistory(alert)
+			continue
+		}
+		if _, active := s.activeAlerts[alert.Name]; !active && s.alertIndex(alert.Name) >= 0 {
+			s.activeAlerts[alert.Name] = &alertState{alert: alert, lastNotified: alert.Timestamp}
+		}
+	}
+	return nil
+}
+
+// alertCooldown returns the re-notify interval for an alert config
+func alertCooldown(config models.AlertConfig) time.Duration {
+	if config.CooldownMinutes > 0 {
+		return time.Duration(config.CooldownMinutes) * time.Minute
+	}
+	return defaultAlertCooldown
+}
+
+// alertID builds a stable identifier for one firing of an alert
+func alertID(name string, firedAt time.Time) string {
+	slug := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, strings.ToLower(name))
+	return "alert_" + slug + "_" + strconv.FormatInt(firedAt.Unix(), 10)
+}
+
+// evaluateAlert computes an alert's metric and whether its condition holds. Windowed
+// alerts are evaluated over their window, others on the snapshot.
+func (s *Service) evaluateAlert(config models.AlertConfig, snapshot *models.MetricsSnapshot, now time.Time) alertEvaluation {
+	if isWindowed(config) {
+		return s.evaluateWindowed(config, now)
+	}
+	current := s.getMetricValue(snapshot, config.Metric)
+	return alertEvaluation{current: current, triggered: s.evaluateAlertCondition(current, config.Threshold, config.Operator)}
+}
+
+// getMetricValue extracts a specific metric value from the snapshot
+func (s *Service) getMetricValue(snapshot *models.MetricsSnapshot, metric string) float64 {
+	switch metric {
+	case "total_events":
+		return float64(snapshot.TotalEvents)
+	case "page_views":
+		return float64(snapshot.EventsByType[models.PageView])
+	case "unique_users":
+		return float64(snapshot.UniqueUsers)
+	case "active_sessions":
+		return float64(snapshot.ActiveSessions)
+	case "average_load_time":
+		return snapshot.PerformanceMetrics.AverageLoadTime
+	case "error_rate":
+		return snapshot.Errors.ErrorRate
+	case "errors_per_minute":
+		return snapshot.Errors.ErrorsPerMinute
+	case "malformed_rate", "incomplete_rate", "timestamp_anomaly_rate", "duplicate_rate":
+		return qualityMetricValue(snapshot, metric)
+	case "pipeline_latency_p95", "broker_latency_p95":
+		return latencyMetricValue(snapshot, metric)
+	default:
+		return 0
+	}
+}
+
+// evaluateAlertCondition checks if an alert condition is met
+func (s *Service) evaluateAlertCondition(current, threshold float64, operator string) bool {
+	switch operator {
+	case "gt":
+		return current > threshold
+	case "lt":
+		return current < threshold
+	case "eq":
+		return current == threshold
+	default:
+		return false
+	}
+}
+
+// generateAlertMessage creates a human-readable alert message
+func (s *Service) generateAlertMessage(config models.AlertConfig, evaluation alertEvaluation) string {
+	return fmt.Sprintf("Alert: %s - %s", config.Name, describeAlert(config, evaluation))
+}
+
+// describeAlert states an alert's metric value against its threshold
+func describeAlert(config models.AlertConfig, evaluation alertEvaluation) string {
+	metric := config.Metric
+	if config.Path != "" {
+		metric += " for " + config.Path
+	}
+	window := int(alertWindow(config) / time.Minute)
+
+	switch {
+	case isRelative(config):
+		direction := "increase"
+		if config.Operator == OperatorPctDecrease {
+			direction = "decrease"
+		}
+		return fmt.Sprintf("%s changed from %.2f to %.2f over the last %d minutes, a %.1f%% %s (threshold: %.1f%%)",
+			metric, evaluation.baseline, evaluation.value, window, evaluation.current, direction, config.Threshold)
+	case isWindowed(config):
+		return fmt.Sprintf("%s is %.2f over the last %d minutes (threshold: %.2f)", metric, evaluation.current, window, config.Threshold)
+	default:
+		return fmt.Sprintf("%s is %.2f (threshold: %.2f)", metric, evaluation.current, config.Threshold)
+	}
+}
+
+// getAlertSeverity determines alert severity based on type
+func (s *Service) getAlertSeverity(alertType string) string {
+	switch alertType {
+	case "performance":
+		return "medium"
+	case "traffic":
+		return "low"
+	case "error":
+		return "high"
+	default:
+		return "medium"
+	}
+}
@@ -0,0 +1,222 @@
+package analytics
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/quality"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/store"
+)
+
+func TestAlertLifecycle(t *testing.T) {
+	service := NewService()
+	service.AddAlert(models.AlertConfig{
+		Name:      "No Users",
+		Type:      "traffic",
+		Metric:    "unique_users",
+		Threshold: 1,
+		Operator:  "lt",
+		Enabled:   true,
+	})
+
+	// Fires once while the condition holds
+	fired := service.CheckAlerts()
+	if len(fired) != 1 || fired[0].Resolved {
+		t.Fatalf("Expected one firing alert, got %+v", fired)
+	}
+	if fired[0].Name != "No Users" || fired[0].Notifications != 1 {
+		t.Errorf("Unexpected alert: %+v", fired[0])
+	}
+
+	// Deduplicated within the cooldown
+	if repeated := service.CheckAlerts(); len(repeated) != 0 {
+		t.Fatalf("Expected no notifications during cooldown, got %d", len(repeated))
+	}
+	if active := service.GetActiveAlerts(); len(active) != 1 || active[0].ID != fired[0].ID {
+		t.Fatalf("Expected the fired alert to be active, got %+v", active)
+	}
+
+	// Resolves automatically once the metric recovers
+	service.ProcessEvent(&models.AnalyticsEvent{Type: models.PageView, Timestamp: time.Now(), UserID: "user-1"})
+	resolved := service.CheckAlerts()
+	if len(resolved) != 1 || !resolved[0].Resolved || resolved[0].ResolvedAt == nil {
+		t.Fatalf("Expected one resolved notification, got %+v", resolved)
+	}
+	if len(service.GetActiveAlerts()) != 0 {
+		t.Error("Expected no active alerts after resolution")
+	}
+	if history := service.GetAlertHistory(); len(history) != 1 || history[0].ID != fired[0].ID {
+		t.Errorf("Expected resolved alert in history, got %+v", history)
+	}
+}
+
+func TestAlertRenotifyAfterCooldown(t *testing.T) {
+	service := NewService()
+	service.AddAlert(models.AlertConfig{
+		Name:      "No Users",
+		Metric:    "unique_users",
+		Threshold: 1,
+		Operator:  "lt",
+		Enabled:   true,
+	})
+
+	service.CheckAlerts()
+
+	// Pretend the last notification happened long ago
+	service.activeAlerts["No Users"].lastNotified = time.Now().Add(-time.Hour)
+
+	renotified := service.CheckAlerts()
+	if len(renotified) != 1 || renotified[0].Notifications != 2 {
+		t.Fatalf("Expected a second notification, got %+v", renotified)
+	}
+}
+
+func TestAlertConfigManagement(t *testing.T) {
+	service := NewService()
+	config := models.AlertConfig{
+		Name:      "No Users",
+		Metric:    "unique_users",
+		Threshold: 1,
+		Operator:  "lt",
+		Enabled:   true,
+	}
+
+	if err := service.CreateAlert(config); err != nil {
+		t.Fatalf("CreateAlert failed: %v", err)
+	}
+	if err := service.CreateAlert(config); !errors.Is(err, ErrAlertExists) {
+		t.Errorf("Expected ErrAlertExists, got %v", err)
+	}
+	if err := service.CreateAlert(models.AlertConfig{Name: "Bad", Metric: "nope", Operator: "gt"}); err == nil {
+		t.Error("Expected an unsupported metric to be rejected")
+	}
+
+	// Disabling an active alert resolves it on the next check
+	if fired := service.CheckAlerts(); len(fired) != 1 {
+		t.Fatalf("Expected the alert to fire, got %+v", fired)
+	}
+	if err := service.SetAlertEnabled("No Users", false); err != nil {
+		t.Fatalf("SetAlertEnabled failed: %v", err)
+	}
+	if resolved := service.CheckAlerts(); len(resolved) != 1 || !resolved[0].Resolved {
+		t.Fatalf("Expected the disabled alert to resolve, got %+v", resolved)
+	}
+
+	config.Threshold = 5
+	if err := service.UpdateAlert("No Users", config); err != nil {
+		t.Fatalf("UpdateAlert failed: %v", err)
+	}
+	if got, _ := service.AlertConfig("No Users"); got.Threshold != 5 {
+		t.Errorf("Expected updated threshold, got %+v", got)
+	}
+
+	if err := service.RemoveAlert("No Users"); err != nil {
+		t.Fatalf("RemoveAlert failed: %v", err)
+	}
+	if err := service.RemoveAlert("No Users"); !errors.Is(err, ErrAlertNotFound) {
+		t.Errorf("Expected ErrAlertNotFound, got %v", err)
+	}
+}
+
+func TestLoadAlertsFromStore(t *testing.T) {
+	st := store.NewMemoryStore()
+	service := NewService()
+
+	// Nothing saved yet: the defaults apply
+	if err := service.LoadAlerts(context.Background(), st); err != nil {
+		t.Fatalf("LoadAlerts failed: %v", err)
+	}
+	if got := len(service.AlertConfigs()); got != len(DefaultAlerts()) {
+		t.Fatalf("Expected %d default alerts, got %d", len(DefaultAlerts()), got)
+	}
+
+	// A saved empty list means every alert was deleted
+	st.SaveAlertConfigs(context.Background(), nil)
+	if err := service.LoadAlerts(context.Background(), st); err != nil {
+		t.Fatalf("LoadAlerts failed: %v", err)
+	}
+	if got := service.AlertConfigs(); len(got) != 0 {
+		t.Errorf("Expected no alerts, got %+v", got)
+	}
+}
+
+func TestDataQualityAlert(t *testing.T) {
+	service := NewService()
+	service.AddAlert(models.AlertConfig{
+		Name:      "Malformed Events",
+		Type:      "quality",
+		Metric:    "malformed_rate",
+		Threshold: 0.1,
+		Operator:  "gt",
+		Enabled:   true,
+	})
+
+	// Without a tracker the rate reads 0
+	if fired := service.CheckAlerts(); len(fired) != 0 {
+		t.Fatalf("Expected no alert without a quality tracker, got %+v", fired)
+	}
+
+	tracker := quality.NewTracker(quality.DefaultConfig())
+	service.SetQualityTracker(tracker)
+	tracker.Malformed(time.Now())
+	tracker.Observe(&models.AnalyticsEvent{Type: models.Click, UserID: "u1"}, time.Now())
+
+	fired := service.CheckAlerts()
+	if len(fired) != 1 || fired[0].CurrentValue != 0.5 {
+		t.Fatalf("Expected the malformed rate alert to fire at 0.5, got %+v", fired)
+	}
+}
+
+func TestRecentAlertsAreKeptAndRestored(t *testing.T) {
+	service := NewService()
+	rules := []models.AlertConfig{
+		{Name: "No Users", Type: "traffic", Metric: "unique_users", Threshold: 1, Operator: "lt", Enabled: true},
+		{Name: "No Events", Type: "traffic", Metric: "total_events", Threshold: 1, Operator: "lt", Enabled: true},
+	}
+	service.SetAlerts(rules)
+	if fired := service.CheckAlerts(); len(fired) != 2 {
+		t.Fatalf("Expected two firing alerts, got %+v", fired)
+	}
+
+	// An event without a user resolves only the event alert
+	service.ProcessEvent(&models.AnalyticsEvent{Type: models.Click, Timestamp: time.Now()})
+	service.CheckAlerts()
+
+	recent := service.RecentAlerts(0, false)
+	if len(recent) != 2 || recent[0].Name != "No Events" || !recent[0].Resolved || recent[1].Resolved {
+		t.Fatalf("Expected the resolved and the active alert, got %+v", recent)
+	}
+	unresolved := service.RecentAlerts(0, true)
+	if len(unresolved) != 1 || unresolved[0].Name != "No Users" {
+		t.Fatalf("Expected only the active alert, got %+v", unresolved)
+	}
+	if limited := service.RecentAlerts(1, false); len(limited) != 1 {
+		t.Errorf("Expected the limit applied, got %+v", limited)
+	}
+
+	// A new service restores the alerts saved by the old one
+	st := store.NewMemoryStore()
+	if err := st.SaveRecentAlerts(context.Background(), recent); err != nil {
+		t.Fatalf("SaveRecentAlerts failed: %v", err)
+	}
+	restored := NewService()
+	restored.SetAlerts(rules)
+	if err := restored.LoadRecentAlerts(context.Background(), st); err != nil {
+		t.Fatalf("LoadRecentAlerts failed: %v", err)
+	}
+	if active := restored.GetActiveAlerts(); len(active) != 1 || active[0].ID != unresolved[0].ID {
+		t.Errorf("Expected the unresolved alert active again, got %+v", active)
+	}
+	if history := restored.GetAlertHistory(); len(history) != 1 || history[0].Name != "No Events" {
+		t.Errorf("Expected the resolved alert in history, got %+v", history)
+	}
+
+	// The restored alert is not notified again within its cooldown
+	restored.ProcessEvent(&models.AnalyticsEvent{Type: models.Click, Timestamp: time.Now()})
+	if notifications := restored.CheckAlerts(); len(notifications) != 0 {
+		t.Errorf("Expected no notifications for the restored alert, got %+v", notifications)
+	}
+}
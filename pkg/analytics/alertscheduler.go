@@ -0,0 +1,81 @@
+package analytics
+
+import (
+	"context"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+// DefaultAlertInterval is how often RunAlerts evaluates alerts when given no interval
+const DefaultAlertInterval = 10 * time.Second
+
+// alertSubscribers holds the handlers notified of alerts evaluated by RunAlerts
+type alertSubscribers struct {
+	handlers map[int]func(models.Alert)
+	next     int
+}
+
+// SubscribeAlerts registers a handler called with every alert RunAlerts fires or
+// resolves, and returns a function that removes it. Handlers are called in turn from
+// the evaluating goroutine, so they should hand slow work off rather than block.
+func (s *Service) SubscribeAlerts(handler func(models.Alert)) func() {
+	s.alertSubsMu.Lock()
+	defer s.alertSubsMu.Unlock()
+
+	if s.alertSubs.handlers == nil {
+		s.alertSubs.handlers = make(map[int]func(models.Alert))
+	}
+	id := s.alertSubs.next
+	s.alertSubs.next++
+	s.alertSubs.handlers[id] = handler
+
+	return func() {
+		s.alertSubsMu.Lock()
+		defer s.alertSubsMu.Unlock()
+		delete(s.alertSubs.handlers, id)
+	}
+}
+
+// EvaluateAlerts checks every alert with CheckAlerts and passes the resulting
+// notifications to the subscribed handlers, returning them
+func (s *Service) EvaluateAlerts() []models.Alert {
+	alerts := s.CheckAlerts()
+	if len(alerts) == 0 {
+		return nil
+	}
+
+	s.alertSubsMu.Lock()
+	handlers := make([]func(models.Alert), 0, len(s.alertSubs.handlers))
+	for _, handler := range s.alertSubs.handlers {
+		handlers = append(handlers, handler)
+	}
+	s.alertSubsMu.Unlock()
+
+	for _, alert := range alerts {
+		for _, handler := range handlers {
+			handler(alert)
+		}
+	}
+	return alerts
+}
+
+// RunAlerts evaluates alerts every interval until ctx is done, independently of event
+// traffic, so alerts on quiet periods fire and resolve on time. A non-positive
+// interval uses DefaultAlertInterval.
+func (s *Service) RunAlerts(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultAlertInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.EvaluateAlerts()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package analytics
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+func TestRunAlertsEvaluatesWithoutEvents(t *testing.T) {
+	service := NewService()
+	service.SetAlerts([]models.AlertConfig{{
+		Name: "No Traffic", Type: "traffic", Metric: "total_events", Threshold: 1, Operator: "lt", Enabled: true,
+	}})
+
+	alerts := make(chan models.Alert, 4)
+	unsubscribe := service.SubscribeAlerts(func(alert models.Alert) { alerts <- alert })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		service.RunAlerts(ctx, 10*time.Millisecond)
+		close(done)
+	}()
+
+	// The alert fires on a schedule although no event was ever processed
+	select {
+	case alert := <-alerts:
+		if alert.Name != "No Traffic" || alert.Resolved {
+			t.Errorf("Expected No Traffic to fire, got %+v", alert)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the alert to fire without events")
+	}
+
+	cancel()
+	<-done
+	unsubscribe()
+	service.ProcessEvent(&models.AnalyticsEvent{ID: "e1", Type: models.PageView, UserID: "u1", SessionID: "s1", URL: "https://example.com/", Timestamp: time.Now()})
+	if resolved := service.EvaluateAlerts(); len(resolved) != 1 || !resolved[0].Resolved {
+		t.Fatalf("Expected the alert to resolve, got %+v", resolved)
+	}
+	select {
+	case alert := <-alerts:
+		t.Errorf("Expected no notification after unsubscribing, got %+v", alert)
+	default:
+	}
+}
@@ -0,0 +1,242 @@
+package analytics
+
+import (
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+const (
+	// Window of per-path and relative alerts whose config has no window
+	defaultAlertWindow = time.Hour
+
+	// Longest window alerts can be evaluated over
+	maxAlertWindow = 24 * time.Hour
+)
+
+// Relative-change operators compare a metric over the alert's window with the window
+// before it; the threshold is a percentage
+const (
+	OperatorPctIncrease = "pct_increase"
+	OperatorPctDecrease = "pct_decrease"
+)
+
+// windowedAlertMetrics lists the metrics that can be computed over a window and per path
+var windowedAlertMetrics = map[string]bool{
+	"total_events":      true,
+	"page_views":        true,
+	"average_load_time": true,
+	"error_rate":        true,
+	"errors_per_minute": true,
+}
+
+// alertBucket holds one minute of the counts windowed alerts are computed from
+type alertBucket struct {
+	events    int64
+	pageViews int64
+	errors    int64
+	loads     int64   // Page views that reported a load time
+	loadTime  float64 // Sum of reported load times in milliseconds
+}
+
+// alertWindowTracker keeps per-minute counts for all traffic and for each path an alert
+// is configured on, for as long as the longest windowed alert needs them
+type alertWindowTracker struct {
+	paths   map[string]bool                   // Paths referenced by alert configs
+	buckets map[string]map[int64]*alertBucket // Path, "" for all traffic -> Unix minute -> counts
+	minutes int64                             // Minutes kept: twice the longest window, 0 when no alert is windowed
+	pruned  int64                             // Unix minute of the wall clock when counts were last pruned
+}
+
+func newAlertWindowTracker() *alertWindowTracker {
+	return &alertWindowTracker{
+		paths:   make(map[string]bool),
+		buckets: make(map[string]map[int64]*alertBucket),
+	}
+}
+
+// isRelative reports whether an alert compares its metric with the previous window
+func isRelative(config models.AlertConfig) bool {
+	return config.Operator == OperatorPctIncrease || config.Operator == OperatorPctDecrease
+}
+
+// isWindowed reports whether an alert is evaluated from per-minute counts rather than
+// the snapshot: per-path and relative alerts, and alerts with a window on a metric that
+// can be computed over one. Other metrics, such as unique users, are read from the
+// snapshot whatever the window.
+func isWindowed(config models.AlertConfig) bool {
+	return config.Path != "" || isRelative(config) || (config.WindowMinutes > 0 && windowedAlertMetrics[config.Metric])
+}
+
+// alertWindow returns the window a windowed alert is evaluated over
+func alertWindow(config models.AlertConfig) time.Duration {
+	if config.WindowMinutes > 0 {
+		return time.Duration(config.WindowMinutes) * time.Minute
+	}
+	return defaultAlertWindow
+}
+
+// trackAlertWindows sets the paths and minutes tracked for the windowed alerts among the
+// configs, dropping counts no longer needed. The caller must hold s.mu.
+func (s *Service) trackAlertWindows() {
+	paths := make(map[string]bool)
+	var longest time.Duration
+	for _, config := range s.alerts {
+		if !isWindowed(config) {
+			continue
+		}
+		if config.Path != "" {
+			paths[config.Path] = true
+		}
+		longest = max(longest, alertWindow(config))
+	}
+
+	s.analytics.Mu.Lock()
+	defer s.analytics.Mu.Unlock()
+
+	w := s.alertWindows
+	w.paths = paths
+	w.minutes = 2 * int64(longest/time.Minute)
+	for path := range w.buckets {
+		if w.minutes == 0 || (path != "" && !paths[path]) {
+			delete(w.buckets, path)
+		}
+	}
+}
+
+// processAlertWindows counts an event towards windowed alerts. Timestamps ahead of the
+// clock count towards the current minute, so clients with skewed clocks can neither
+// fill future minutes nor push out the counts alerts are evaluated over. The caller
+// must hold the analytics lock.
+func (s *Service) processAlertWindows(event *models.AnalyticsEvent, weight int64) {
+	w := s.alertWindows
+	if w.minutes == 0 {
+		return
+	}
+
+	now := time.Now().Truncate(time.Minute).Unix()
+	if now != w.pruned {
+		w.pruned = now
+		w.prune(now)
+	}
+	minute := min(event.Timestamp.Truncate(time.Minute).Unix(), now)
+	if minute < now-w.minutes*60 {
+		return
+	}
+	w.bucket("", minute).add(event, weight)
+	if w.paths[event.Path] {
+		w.bucket(event.Path, minute).add(event, weight)
+	}
+}
+
+// bucket returns the counts of a path and minute, creating them if needed
+func (w *alertWindowTracker) bucket(path string, minute int64) *alertBucket {
+	minutes := w.buckets[path]
+	if minutes == nil {
+		minutes = make(map[int64]*alertBucket)
+		w.buckets[path] = minutes
+	}
+	bucket := minutes[minute]
+	if bucket == nil {
+		bucket = &alertBucket{}
+		minutes[minute] = bucket
+	}
+	return bucket
+}
+
+// add counts an event in the bucket
+func (b *alertBucket) add(event *models.AnalyticsEvent, weight int64) {
+	b.events += weight
+	switch event.Type {
+	case models.PageView:
+		b.pageViews += weight
+		if loadTime, ok := event.Metadata["load_time"].(float64); ok {
+			b.loads++
+			b.loadTime += loadTime
+		}
+	case models.Error:
+		b.errors += weight
+	}
+}
+
+// prune drops counts older than the kept minutes before the given minute
+func (w *alertWindowTracker) prune(minute int64) {
+	cutoff := minute - w.minutes*60
+	for _, minutes := range w.buckets {
+		for m := range minutes {
+			if m < cutoff {
+				delete(minutes, m)
+			}
+		}
+	}
+}
+
+// value computes a metric for a path ("" for all traffic) over the window ending at end,
+// excluding end's minute. ok is false when the metric is undefined, e.g. an average
+// load time without page loads.
+func (w *alertWindowTracker) value(path, metric string, end time.Time, window time.Duration) (value float64, ok bool) {
+	var sum alertBucket
+	minutes := int64(window / time.Minute)
+	last := end.Truncate(time.Minute)
+	for i := int64(1); i <= minutes; i++ {
+		if bucket := w.buckets[path][last.Add(-time.Duration(i)*time.Minute).Unix()]; bucket != nil {
+			sum.events += bucket.events
+			sum.pageViews += bucket.pageViews
+			sum.errors += bucket.errors
+			sum.loads += bucket.loads
+			sum.loadTime += bucket.loadTime
+		}
+	}
+
+	switch metric {
+	case "total_events":
+		return float64(sum.events), true
+	case "page_views":
+		return float64(sum.pageViews), true
+	case "average_load_time":
+		if sum.loads == 0 {
+			return 0, false
+		}
+		return sum.loadTime / float64(sum.loads), true
+	case "error_rate":
+		if sum.events == 0 {
+			return 0, false
+		}
+		return float64(sum.errors) / float64(sum.events), true
+	case "errors_per_minute":
+		return float64(sum.errors) / float64(minutes), true
+	default:
+		return 0, false
+	}
+}
+
+// alertEvaluation is the outcome of evaluating one alert config
+type alertEvaluation struct {
+	current   float64 // Metric value, or the percentage change for relative alerts
+	value     float64 // Relative alerts: metric over the current window
+	baseline  float64 // Relative alerts: metric over the previous window
+	triggered bool
+}
+
+// evaluateWindowed evaluates a per-path or relative alert from the per-minute counts.
+// Relative alerts don't fire without a baseline in the previous window.
+func (s *Service) evaluateWindowed(config models.AlertConfig, now time.Time) alertEvaluation {
+	s.analytics.Mu.RLock()
+	defer s.analytics.Mu.RUnlock()
+
+	window := alertWindow(config)
+	value, ok := s.alertWindows.value(config.Path, config.Metric, now, window)
+	if !isRelative(config) {
+		return alertEvaluation{current: value, triggered: ok && s.evaluateAlertCondition(value, config.Threshold, config.Operator)}
+	}
+
+	baseline, baselineOK := s.alertWindows.value(config.Path, config.Metric, now.Add(-window), window)
+	if !ok || !baselineOK || baseline == 0 {
+		return alertEvaluation{value: value, baseline: baseline}
+	}
+	change := (value - baseline) / baseline * 100
+	if config.Operator == OperatorPctDecrease {
+		change = -change
+	}
+	return alertEvaluation{current: change, value: value, baseline: baseline, triggered: change > config.Threshold}
+}
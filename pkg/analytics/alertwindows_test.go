@@ -0,0 +1,180 @@
+package analytics
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+// pageLoad returns a page view of path with a load time, at ts
+func pageLoad(path string, loadTime float64, ts time.Time) *models.AnalyticsEvent {
+	return &models.AnalyticsEvent{
+		Type:      models.PageView,
+		Timestamp: ts,
+		UserID:    "user-1",
+		Path:      path,
+		Metadata:  map[string]interface{}{"load_time": loadTime},
+	}
+}
+
+func TestPerPathAlert(t *testing.T) {
+	service := NewService()
+	if err := service.SetAlerts([]models.AlertConfig{{
+		Name:          "Slow Checkout",
+		Metric:        "average_load_time",
+		Path:          "/checkout",
+		Threshold:     2000,
+		Operator:      "gt",
+		WindowMinutes: 30,
+		Enabled:       true,
+	}}); err != nil {
+		t.Fatalf("SetAlerts failed: %v", err)
+	}
+
+	// Fast pages elsewhere don't dilute the checkout page's load time
+	ts := time.Now().Add(-10 * time.Minute)
+	service.ProcessEvent(pageLoad("/checkout", 3000, ts))
+	service.ProcessEvent(pageLoad("/", 100, ts))
+	service.ProcessEvent(pageLoad("/", 100, ts))
+
+	fired := service.CheckAlerts()
+	if len(fired) != 1 || fired[0].CurrentValue != 3000 {
+		t.Fatalf("Expected the checkout alert to fire at 3000, got %+v", fired)
+	}
+	if !strings.Contains(fired[0].Message, "/checkout") {
+		t.Errorf("Expected the message to name the path, got %q", fired[0].Message)
+	}
+
+	// Events outside the window no longer count
+	later := service.evaluateWindowed(service.alerts[0], time.Now().Add(time.Hour))
+	if later.triggered {
+		t.Errorf("Expected no trigger once the events left the window, got %+v", later)
+	}
+}
+
+func TestWindowedAlertIgnoresLifetimeTotals(t *testing.T) {
+	service := NewService()
+	if err := service.SetAlerts([]models.AlertConfig{
+		{Name: "Slow Pages", Metric: "average_load_time", Threshold: 2000, Operator: "gt", WindowMinutes: 5, Enabled: true},
+		{Name: "Traffic Surge", Metric: "total_events", Threshold: 2, Operator: "gt", WindowMinutes: 5, Enabled: true},
+	}); err != nil {
+		t.Fatalf("SetAlerts failed: %v", err)
+	}
+
+	// Slow, busy traffic an hour ago counts towards the lifetime values only
+	old := time.Now().Add(-time.Hour)
+	for i := 0; i < 5; i++ {
+		service.ProcessEvent(pageLoad("/", 4000, old))
+	}
+	recent := time.Now().Add(-2 * time.Minute)
+	service.ProcessEvent(pageLoad("/", 500, recent))
+	service.ProcessEvent(pageLoad("/", 700, recent))
+
+	if snapshot := service.GetSnapshot(); snapshot.TotalEvents != 7 {
+		t.Fatalf("expected 7 events in the snapshot, got %d", snapshot.TotalEvents)
+	}
+	if fired := service.CheckAlerts(); len(fired) != 0 {
+		t.Fatalf("expected no alerts from events outside the window, got %+v", fired)
+	}
+
+	for i := 0; i < 2; i++ {
+		service.ProcessEvent(pageLoad("/", 5000, recent))
+	}
+	fired := service.CheckAlerts()
+	if len(fired) != 2 || fired[0].CurrentValue != 2800 || fired[1].CurrentValue != 4 {
+		t.Fatalf("expected both alerts to fire on the last 5 minutes, got %+v", fired)
+	}
+	if !strings.Contains(fired[0].Message, "over the last 5 minutes") {
+		t.Errorf("expected the message to name the window, got %q", fired[0].Message)
+	}
+}
+
+func TestRelativeAlert(t *testing.T) {
+	service := NewService()
+	if err := service.SetAlerts([]models.AlertConfig{{
+		Name:      "Checkout Slowdown",
+		Metric:    "average_load_time",
+		Path:      "/checkout",
+		Threshold: 50,
+		Operator:  OperatorPctIncrease,
+		Enabled:   true,
+	}}); err != nil {
+		t.Fatalf("SetAlerts failed: %v", err)
+	}
+
+	// Without a previous window there is nothing to compare with
+	current := time.Now().Truncate(time.Minute).Add(-30 * time.Minute)
+	service.ProcessEvent(pageLoad("/checkout", 1800, current))
+	if fired := service.CheckAlerts(); len(fired) != 0 {
+		t.Fatalf("Expected no alert without a baseline, got %+v", fired)
+	}
+
+	// 1800ms against 1000ms in the previous hour is an 80% increase
+	service.ProcessEvent(pageLoad("/checkout", 1000, current.Add(-time.Hour)))
+	fired := service.CheckAlerts()
+	if len(fired) != 1 {
+		t.Fatalf("Expected the relative alert to fire, got %+v", fired)
+	}
+	if fired[0].CurrentValue != 80 {
+		t.Errorf("Expected an 80%% increase, got %v", fired[0].CurrentValue)
+	}
+	if !strings.Contains(fired[0].Message, "80.0% increase") {
+		t.Errorf("Expected the message to state the change, got %q", fired[0].Message)
+	}
+
+	// pct_decrease doesn't fire on an increase
+	evaluation := service.evaluateWindowed(models.AlertConfig{
+		Metric:    "average_load_time",
+		Path:      "/checkout",
+		Threshold: 10,
+		Operator:  OperatorPctDecrease,
+	}, time.Now())
+	if evaluation.triggered || evaluation.current != -80 {
+		t.Errorf("Expected a -80%% decrease not to trigger, got %+v", evaluation)
+	}
+}
+
+func TestAlertWindowsClampFutureTimestamps(t *testing.T) {
+	service := NewService()
+	if err := service.SetAlerts([]models.AlertConfig{
+		{Name: "Slow Pages", Metric: "average_load_time", Threshold: 2000, Operator: "gt", WindowMinutes: 30, Enabled: true},
+	}); err != nil {
+		t.Fatalf("SetAlerts failed: %v", err)
+	}
+
+	service.ProcessEvent(pageLoad("/", 3000, time.Now().Add(-10*time.Minute)))
+	// A client clock a year ahead must not prune the window or open a future minute
+	service.ProcessEvent(pageLoad("/", 100, time.Now().AddDate(1, 0, 0)))
+
+	if fired := service.CheckAlerts(); len(fired) != 1 || fired[0].CurrentValue != 3000 {
+		t.Fatalf("Expected the alert to fire on the counts before the skewed event, got %+v", fired)
+	}
+	for minute := range service.alertWindows.buckets[""] {
+		if minute > time.Now().Unix() {
+			t.Errorf("Expected no counts in the future, got minute %d", minute)
+		}
+	}
+}
+
+func TestValidateWindowedAlertConfig(t *testing.T) {
+	valid := models.AlertConfig{Name: "a", Metric: "error_rate", Operator: OperatorPctIncrease, Threshold: 25, Path: "/checkout"}
+	if err := ValidateAlertConfig(valid); err != nil {
+		t.Fatalf("Expected a valid config, got %v", err)
+	}
+
+	invalid := map[string]func(c *models.AlertConfig){
+		"unsupported metric": func(c *models.AlertConfig) { c.Metric = "unique_users" },
+		"relative threshold": func(c *models.AlertConfig) { c.Threshold = 0 },
+		"relative path":      func(c *models.AlertConfig) { c.Path = "checkout" },
+		"window too long":    func(c *models.AlertConfig) { c.WindowMinutes = 1441 },
+	}
+	for name, change := range invalid {
+		config := valid
+		change(&config)
+		if err := ValidateAlertConfig(config); err == nil {
+			t.Errorf("%s: expected a validation error", name)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package analytics
+
+import (
+	"net/url"
+	"sort"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/bots"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/useragent"
+)
+
+// maxBotKeys bounds the bot names and pages tracked, since both come from client input
+const maxBotKeys = 1000
+
+// botTracker detects bot traffic and counts it separately from the main metrics
+type botTracker struct {
+	policy   bots.Policy
+	detector *bots.Detector
+	events   int64
+	byReason map[string]int64
+	byType   map[models.EventType]int64
+	byName   map[string]int64
+	pages    map[string]int64
+}
+
+func newBotTracker() *botTracker {
+	// The default configuration always parses
+	detector, _ := bots.NewDetector(bots.Config{})
+	return &botTracker{
+		policy:   bots.PolicyFlag,
+		detector: detector,
+		byReason: make(map[string]int64),
+		byType:   make(map[models.EventType]int64),
+		byName:   make(map[string]int64),
+		pages:    make(map[string]int64),
+	}
+}
+
+// SetBotPolicy sets whether bot traffic is counted in the main metrics, and the detector
+// used to recognize it; a nil detector keeps the current one. Bot traffic is always
+// counted in BotEvents and BotStats.
+func (s *Service) SetBotPolicy(policy bots.Policy, detector *bots.Detector) {
+	s.analytics.Mu.Lock()
+	defer s.analytics.Mu.Unlock()
+	s.bots.policy = policy
+	if detector != nil {
+		s.bots.detector = detector
+	}
+}
+
+// processBot records the event in the bot stats if it is bot traffic, and reports whether
+// it must be left out of the main metrics. The caller must hold the analytics lock.
+func (s *Service) processBot(event *models.AnalyticsEvent, agent *useragent.Info, weight int64) bool {
+	result := s.bots.detector.DetectWithAgent(event, agent)
+	if !result.Bot {
+		return false
+	}
+
+	s.analytics.BotEvents++
+	s.bots.events += weight
+	s.bots.byReason[result.Reason] += weight
+	s.bots.byType[event.Type] += weight
+	addBounded(s.bots.byName, result.Name, weight)
+	if event.URL != "" {
+		path := event.Path
+		if u, err := url.Parse(event.URL); err == nil && path == "" {
+			path = u.Path
+		}
+		addBounded(s.bots.pages, path, weight)
+	}
+
+	return s.bots.policy != bots.PolicyFlag
+}
+
+// addBounded adds to a count, ignoring new keys once maxBotKeys are tracked
+func addBounded(counts map[string]int64, key string, weight int64) {
+	if _, ok := counts[key]; ok || len(counts) < maxBotKeys {
+		counts[key] += weight
+	}
+}
+
+// getBotStats summarizes bot traffic. The caller must hold the analytics lock.
+func (s *Service) getBotStats() models.BotStats {
+	stats := models.BotStats{
+		Policy:       string(s.bots.policy),
+		Events:       s.bots.events,
+		ByReason:     copyCounts(s.bots.byReason),
+		EventsByType: make(map[models.EventType]int64, len(s.bots.byType)),
+		TopBots:      s.topBotCounts(s.bots.byName),
+		TopPages:     s.topBotCounts(s.bots.pages),
+	}
+	for eventType, count := range s.bots.byType {
+		stats.EventsByType[eventType] = count
+	}
+	return stats
+}
+
+// topBotCounts returns the 10 largest counts with their share of bot events
+func (s *Service) topBotCounts(counts map[string]int64) []models.DimensionCount {
+	result := make([]models.DimensionCount, 0, len(counts))
+	for name, count := range counts {
+		result = append(result, models.DimensionCount{
+			Name:    name,
+			Count:   count,
+			Percent: float64(count) / float64(s.bots.events) * 100,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Name < result[j].Name
+	})
+	if len(result) > 10 {
+		result = result[:10]
+	}
+	return result
+}
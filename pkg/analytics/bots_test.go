@@ -0,0 +1,38 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/bots"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+func TestBotPolicies(t *testing.T) {
+	for _, tt := range []struct {
+		policy     bots.Policy
+		wantEvents int64
+	}{
+		{bots.PolicyFlag, 2},
+		{bots.PolicySegregate, 1},
+	} {
+		service := NewService()
+		service.SetBotPolicy(tt.policy, nil)
+
+		service.ProcessEvent(&models.AnalyticsEvent{ID: "1", Type: models.PageView, Timestamp: time.Now(), UserID: "bot", URL: "https://example.com/pricing", UserAgent: googlebotUserAgent})
+		service.ProcessEvent(&models.AnalyticsEvent{ID: "2", Type: models.PageView, Timestamp: time.Now(), UserID: "u1", URL: "https://example.com/", UserAgent: edgeUserAgent})
+
+		snapshot := service.GetSnapshot()
+		if snapshot.TotalEvents != tt.wantEvents {
+			t.Errorf("%s: expected %d events in the main metrics, got %d", tt.policy, tt.wantEvents, snapshot.TotalEvents)
+		}
+
+		stats := snapshot.BotStats
+		if stats.Policy != string(tt.policy) || stats.Events != 1 || stats.ByReason[bots.ReasonUserAgent] != 1 {
+			t.Errorf("%s: unexpected bot stats %+v", tt.policy, stats)
+		}
+		if len(stats.TopBots) != 1 || stats.TopBots[0].Name != "Googlebot" || len(stats.TopPages) != 1 || stats.TopPages[0].Name != "/pricing" {
+			t.Errorf("%s: expected Googlebot on /pricing, got %+v %+v", tt.policy, stats.TopBots, stats.TopPages)
+		}
+	}
+}
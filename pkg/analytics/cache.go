@@ -0,0 +1,99 @@
+package analytics
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+// SnapshotCache serves a recently computed snapshot to avoid recomputing it on every read.
+// Cached snapshots are shared between callers and must not be modified.
+type SnapshotCache struct {
+	service *Service
+	ttl     time.Duration
+
+	entries map[int]*cachedSnapshot // Top-N limit -> snapshot
+	mu      sync.Mutex
+}
+
+// cachedSnapshot is a snapshot computed for one top-N limit
+type cachedSnapshot struct {
+	snapshot  *models.MetricsSnapshot
+	version   SnapshotVersion
+	fetchedAt time.Time
+}
+
+// SnapshotVersion identifies the content of a cached snapshot, for conditional requests
+type SnapshotVersion struct {
+	Fingerprint uint64    // Hash of the content, ignoring the snapshot's timestamp
+	Modified    time.Time // When the content last changed
+	Expires     time.Time // When the snapshot will be recomputed
+}
+
+// NewSnapshotCache creates a snapshot cache that refreshes after ttl
+func NewSnapshotCache(service *Service, ttl time.Duration) *SnapshotCache {
+	return &SnapshotCache{
+		service: service,
+		ttl:     ttl,
+		entries: make(map[int]*cachedSnapshot),
+	}
+}
+
+// Get returns the cached snapshot, recomputing it if it has expired
+func (c *SnapshotCache) Get() *models.MetricsSnapshot {
+	snapshot, _ := c.GetTop(0)
+	return snapshot
+}
+
+// GetTop returns the cached snapshot listing up to limit top pages and sources, as
+// Service.GetSnapshotTop, with its version. A recomputed snapshot whose content is
+// unchanged keeps its fingerprint and modification time.
+func (c *SnapshotCache) GetTop(limit int) (*models.MetricsSnapshot, SnapshotVersion) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	entry := c.entries[limit]
+	if entry != nil && now.Sub(entry.fetchedAt) <= c.ttl {
+		return entry.snapshot, entry.version
+	}
+
+	snapshot := c.service.GetSnapshotTop(limit)
+	version := SnapshotVersion{Fingerprint: fingerprint(snapshot), Modified: now}
+	if entry != nil && entry.version.Fingerprint == version.Fingerprint {
+		version.Modified = entry.version.Modified
+	}
+	version.Expires = now.Add(c.ttl)
+
+	c.entries[limit] = &cachedSnapshot{snapshot: snapshot, version: version, fetchedAt: now}
+	return snapshot, version
+}
+
+// Invalidate drops the cached snapshots, so the next read sees changes made since, e.g.
+// after a reset
+func (c *SnapshotCache) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, entry := range c.entries {
+		// Keep the version so unchanged content keeps its modification time
+		entry.fetchedAt = time.Time{}
+	}
+}
+
+// TTL returns how long snapshots are cached
+func (c *SnapshotCache) TTL() time.Duration {
+	return c.ttl
+}
+
+// fingerprint hashes the snapshot's JSON form without its timestamp. Map keys are
+// encoded in sorted order, so equal content always hashes the same.
+func fingerprint(snapshot *models.MetricsSnapshot) uint64 {
+	content := *snapshot
+	content.Timestamp = time.Time{}
+	hash := fnv.New64a()
+	json.NewEncoder(hash).Encode(&content)
+	return hash.Sum64()
+}
@@ -0,0 +1,42 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+func TestSnapshotCacheVersions(t *testing.T) {
+	service := NewService()
+	service.ProcessEvent(&models.AnalyticsEvent{ID: "1", Type: models.PageView, Timestamp: time.Now(), UserID: "u1", URL: "https://example.com/"})
+
+	cache := NewSnapshotCache(service, time.Hour)
+	first, version := cache.GetTop(0)
+	if again, _ := cache.GetTop(0); again != first {
+		t.Fatal("expected the snapshot to be reused within the TTL")
+	}
+	if limited, _ := cache.GetTop(1); limited == first {
+		t.Error("expected each top-N limit to be cached separately")
+	}
+
+	// Recomputing unchanged content keeps the version
+	cache.Invalidate()
+	recomputed, unchanged := cache.GetTop(0)
+	if recomputed == first {
+		t.Fatal("expected Invalidate to recompute the snapshot")
+	}
+	if unchanged.Fingerprint != version.Fingerprint || !unchanged.Modified.Equal(version.Modified) {
+		t.Errorf("expected unchanged content to keep its version, got %+v then %+v", version, unchanged)
+	}
+
+	service.ProcessEvent(&models.AnalyticsEvent{ID: "2", Type: models.Click, Timestamp: time.Now(), UserID: "u2", URL: "https://example.com/"})
+	if stale, _ := cache.GetTop(0); stale.TotalEvents != 1 {
+		t.Errorf("expected the cached snapshot until it expires, got %d events", stale.TotalEvents)
+	}
+	cache.Invalidate()
+	changed, changedVersion := cache.GetTop(0)
+	if changed.TotalEvents != 2 || changedVersion.Fingerprint == version.Fingerprint || changedVersion.Modified.Before(version.Modified) {
+		t.Errorf("expected new content to get a new version, got %d events with %+v", changed.TotalEvents, changedVersion)
+	}
+}
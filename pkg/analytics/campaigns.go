@@ -0,0 +1,175 @@
+package analytics
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+const (
+	// Number of campaigns included in snapshots
+	maxCampaignStats = 20
+
+	// Placeholder for UTM fields missing from a tagged URL
+	utmNotSet = "(not set)"
+
+	// Campaigns tracked, and users attributed per campaign and overall, since all come
+	// from client input; later new ones are not tracked
+	maxCampaigns     = 1000
+	maxCampaignUsers = 100000
+)
+
+// UTMParams holds the campaign parameters of a URL
+type UTMParams struct {
+	Source   string
+	Medium   string
+	Campaign string
+	Term     string
+	Content  string
+}
+
+// ParseUTM extracts UTM parameters from a URL. It reports false when the URL
+// carries neither utm_source nor utm_campaign.
+func ParseUTM(rawURL string) (UTMParams, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return UTMParams{}, false
+	}
+
+	query := u.Query()
+	params := UTMParams{
+		Source:   strings.ToLower(strings.TrimSpace(query.Get("utm_source"))),
+		Medium:   strings.ToLower(strings.TrimSpace(query.Get("utm_medium"))),
+		Campaign: strings.TrimSpace(query.Get("utm_campaign")),
+		Term:     strings.TrimSpace(query.Get("utm_term")),
+		Content:  strings.TrimSpace(query.Get("utm_content")),
+	}
+	if params.Source == "" && params.Campaign == "" {
+		return UTMParams{}, false
+	}
+
+	for _, field := range []*string{&params.Source, &params.Medium, &params.Campaign} {
+		if *field == "" {
+			*field = utmNotSet
+		}
+	}
+	return params, true
+}
+
+// campaignKey identifies a campaign by name, source and medium
+func (p UTMParams) campaignKey() string {
+	return p.Campaign + "|" + p.Source + "|" + p.Medium
+}
+
+// campaign holds the aggregated performance of one campaign
+type campaign struct {
+	params    UTMParams
+	events    int64
+	users     map[string]bool
+	converted map[string]bool
+	terms     map[string]int64
+	contents  map[string]int64
+}
+
+// SetCampaignGoal sets the event that counts as a campaign conversion
+func (s *Service) SetCampaignGoal(goal models.Goal) {
+	s.analytics.Mu.Lock()
+	defer s.analytics.Mu.Unlock()
+	s.campaignGoal = goal
+}
+
+// processCampaign attributes the event's user to any campaign in its URL (last touch wins)
+// and records a conversion when an attributed user reaches the campaign goal.
+// The caller must hold the analytics lock.
+func (s *Service) processCampaign(event *models.AnalyticsEvent) {
+	participant := event.UserID
+	if participant == "" {
+		participant = event.SessionID
+	}
+
+	if params, ok := ParseUTM(event.URL); ok {
+		key := params.campaignKey()
+		c := s.campaigns[key]
+		if c == nil {
+			if len(s.campaigns) >= maxCampaigns {
+				return
+			}
+			c = &campaign{
+				params:    UTMParams{Source: params.Source, Medium: params.Medium, Campaign: params.Campaign},
+				users:     make(map[string]bool),
+				converted: make(map[string]bool),
+				terms:     make(map[string]int64),
+				contents:  make(map[string]int64),
+			}
+			s.campaigns[key] = c
+		}
+
+		c.events++
+		if params.Term != "" {
+			addBounded(c.terms, params.Term, 1)
+		}
+		if params.Content != "" {
+			addBounded(c.contents, params.Content, 1)
+		}
+		if participant != "" {
+			s.attribute(c, key, participant)
+		}
+	}
+
+	// Conversions count once per user and campaign
+	if participant == "" || !s.campaignGoal.Matches(event) {
+		return
+	}
+	if key, ok := s.userCampaigns[participant]; ok {
+		s.campaigns[key].converted[participant] = true
+	}
+}
+
+// attribute records the participant as a user of the campaign, last touch winning,
+// unless the campaign or the attributions are full. The caller must hold the analytics
+// lock.
+func (s *Service) attribute(c *campaign, key, participant string) {
+	if !c.users[participant] {
+		if len(c.users) >= maxCampaignUsers {
+			return
+		}
+		c.users[participant] = true
+	}
+	if _, ok := s.userCampaigns[participant]; ok || len(s.userCampaigns) < maxCampaignUsers {
+		s.userCampaigns[participant] = key
+	}
+}
+
+// getCampaignStats returns the campaigns with the most events
+func (s *Service) getCampaignStats() []models.CampaignMetric {
+	stats := make([]models.CampaignMetric, 0, len(s.campaigns))
+	for _, c := range s.campaigns {
+		metric := models.CampaignMetric{
+			Campaign:    c.params.Campaign,
+			Source:      c.params.Source,
+			Medium:      c.params.Medium,
+			Events:      c.events,
+			Users:       int64(len(c.users)),
+			Conversions: int64(len(c.converted)),
+			Terms:       copyCounts(c.terms),
+			Contents:    copyCounts(c.contents),
+		}
+		if metric.Users > 0 {
+			metric.ConversionRate = float64(metric.Conversions) / float64(metric.Users)
+		}
+		stats = append(stats, metric)
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Events != stats[j].Events {
+			return stats[i].Events > stats[j].Events
+		}
+		return stats[i].Campaign < stats[j].Campaign
+	})
+	if len(stats) > maxCampaignStats {
+		stats = stats[:maxCampaignStats]
+	}
+	return stats
+}
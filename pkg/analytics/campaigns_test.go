@@ -0,0 +1,75 @@
+package analytics
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+func TestParseUTM(t *testing.T) {
+	params, ok := ParseUTM("https://example.com/?utm_source=Newsletter&utm_campaign=spring_sale&utm_term=shoes")
+	if !ok {
+		t.Fatal("expected UTM parameters")
+	}
+	want := UTMParams{Source: "newsletter", Medium: utmNotSet, Campaign: "spring_sale", Term: "shoes"}
+	if params != want {
+		t.Errorf("ParseUTM() = %+v, want %+v", params, want)
+	}
+
+	if _, ok := ParseUTM("https://example.com/?utm_medium=email"); ok {
+		t.Error("expected a URL without source or campaign to be untagged")
+	}
+}
+
+func TestCampaignAttributionAndConversions(t *testing.T) {
+	service := NewService()
+	service.SetCampaignGoal(models.Goal{EventType: models.Click, PathPrefix: "/checkout"})
+
+	event := func(userID string, eventType models.EventType, rawURL, path string) {
+		service.ProcessEvent(&models.AnalyticsEvent{
+			ID:        userID + rawURL + path,
+			Type:      eventType,
+			Timestamp: time.Now(),
+			UserID:    userID,
+			URL:       rawURL,
+			Path:      path,
+		})
+	}
+
+	landing := "https://example.com/?utm_source=newsletter&utm_medium=email&utm_campaign=spring_sale"
+	event("u1", models.PageView, landing, "/")
+	event("u2", models.PageView, landing, "/")
+	event("u1", models.Click, "https://example.com/checkout", "/checkout")
+	event("u1", models.Click, "https://example.com/checkout", "/checkout")
+
+	stats := service.GetSnapshot().CampaignStats
+	if len(stats) != 1 {
+		t.Fatalf("expected 1 campaign, got %d", len(stats))
+	}
+	campaign := stats[0]
+	if campaign.Events != 2 || campaign.Users != 2 || campaign.Conversions != 1 || campaign.ConversionRate != 0.5 {
+		t.Errorf("unexpected campaign stats: %+v", campaign)
+	}
+}
+
+func TestCampaignsAreBounded(t *testing.T) {
+	service := NewService()
+	for i := 0; i < maxCampaigns+10; i++ {
+		service.ProcessEvent(&models.AnalyticsEvent{
+			ID:        fmt.Sprintf("evt-%d", i),
+			Type:      models.PageView,
+			Timestamp: time.Now(),
+			UserID:    "u1",
+			URL:       fmt.Sprintf("https://example.com/?utm_source=ads&utm_campaign=c%d", i),
+		})
+	}
+
+	if len(service.campaigns) != maxCampaigns {
+		t.Errorf("expected %d campaigns tracked, got %d", maxCampaigns, len(service.campaigns))
+	}
+	if len(service.userCampaigns) != 1 {
+		t.Errorf("expected one attributed user, got %d", len(service.userCampaigns))
+	}
+}
@@ -0,0 +1,202 @@
+package analytics
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+// Built-in channels. Custom rules may assign any other channel name.
+const (
+	ChannelDirect   = "direct"
+	ChannelSearch   = "organic_search"
+	ChannelSocial   = "social"
+	ChannelEmail    = "email"
+	ChannelPaid     = "paid"
+	ChannelReferral = "referral"
+)
+
+// ChannelRule assigns a channel to traffic by its UTM medium or its referrer's domain.
+// A domain matches itself and its subdomains; a domain ending in "." matches any
+// top-level domain, e.g. "google." matches google.com and www.google.co.uk.
+type ChannelRule struct {
+	Medium  string // utm_medium value, matched case-insensitively
+	Domain  string
+	Channel string
+}
+
+// defaultChannelRules classify common mediums and referrers. Email webmail domains come
+// before search so mail.google.com is not counted as Google search.
+var defaultChannelRules = []ChannelRule{
+	{Medium: "cpc", Channel: ChannelPaid},
+	{Medium: "ppc", Channel: ChannelPaid},
+	{Medium: "paid", Channel: ChannelPaid},
+	{Medium: "paidsearch", Channel: ChannelPaid},
+	{Medium: "paid_search", Channel: ChannelPaid},
+	{Medium: "paid_social", Channel: ChannelPaid},
+	{Medium: "cpm", Channel: ChannelPaid},
+	{Medium: "display", Channel: ChannelPaid},
+	{Medium: "banner", Channel: ChannelPaid},
+	{Medium: "affiliate", Channel: ChannelPaid},
+	{Medium: "email", Channel: ChannelEmail},
+	{Medium: "e-mail", Channel: ChannelEmail},
+	{Medium: "newsletter", Channel: ChannelEmail},
+	{Medium: "social", Channel: ChannelSocial},
+	{Medium: "organic", Channel: ChannelSearch},
+	{Medium: "referral", Channel: ChannelReferral},
+
+	{Domain: "mail.google.com", Channel: ChannelEmail},
+	{Domain: "mail.yahoo.com", Channel: ChannelEmail},
+	{Domain: "outlook.live.com", Channel: ChannelEmail},
+	{Domain: "outlook.office.com", Channel: ChannelEmail},
+	{Domain: "mail.proton.me", Channel: ChannelEmail},
+
+	{Domain: "google.", Channel: ChannelSearch},
+	{Domain: "bing.com", Channel: ChannelSearch},
+	{Domain: "yahoo.", Channel: ChannelSearch},
+	{Domain: "duckduckgo.com", Channel: ChannelSearch},
+	{Domain: "baidu.com", Channel: ChannelSearch},
+	{Domain: "yandex.", Channel: ChannelSearch},
+	{Domain: "ecosia.org", Channel: ChannelSearch},
+	{Domain: "search.brave.com", Channel: ChannelSearch},
+	{Domain: "startpage.com", Channel: ChannelSearch},
+	{Domain: "ask.com", Channel: ChannelSearch},
+
+	{Domain: "facebook.com", Channel: ChannelSocial},
+	{Domain: "fb.com", Channel: ChannelSocial},
+	{Domain: "instagram.com", Channel: ChannelSocial},
+	{Domain: "twitter.com", Channel: ChannelSocial},
+	{Domain: "x.com", Channel: ChannelSocial},
+	{Domain: "t.co", Channel: ChannelSocial},
+	{Domain: "linkedin.com", Channel: ChannelSocial},
+	{Domain: "lnkd.in", Channel: ChannelSocial},
+	{Domain: "reddit.com", Channel: ChannelSocial},
+	{Domain: "pinterest.", Channel: ChannelSocial},
+	{Domain: "tiktok.com", Channel: ChannelSocial},
+	{Domain: "youtube.com", Channel: ChannelSocial},
+	{Domain: "threads.net", Channel: ChannelSocial},
+	{Domain: "news.ycombinator.com", Channel: ChannelSocial},
+}
+
+// paidClickIDs are URL parameters ad platforms add to clicks on paid ads
+var paidClickIDs = []string{"gclid", "gbraid", "wbraid", "dclid", "msclkid"}
+
+// ParseChannelRules parses rules of the form "pattern=channel" separated by ";", where
+// the pattern is a referrer domain or "medium:<utm_medium>", e.g.
+// "news.example.com=referral;medium:partner=paid"
+func ParseChannelRules(spec string) ([]ChannelRule, error) {
+	var rules []ChannelRule
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		pattern, channel, ok := strings.Cut(entry, "=")
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		channel = strings.ToLower(strings.TrimSpace(channel))
+		if !ok || pattern == "" || channel == "" {
+			return nil, fmt.Errorf("invalid channel rule %q, expected domain=channel or medium:value=channel", entry)
+		}
+
+		if medium, isMedium := strings.CutPrefix(pattern, "medium:"); isMedium {
+			rules = append(rules, ChannelRule{Medium: strings.TrimSpace(medium), Channel: channel})
+			continue
+		}
+		rules = append(rules, ChannelRule{Domain: strings.TrimPrefix(pattern, "www."), Channel: channel})
+	}
+	return rules, nil
+}
+
+// SetChannelRules sets rules applied before the built-in channel classification
+func (s *Service) SetChannelRules(rules []ChannelRule) {
+	s.analytics.Mu.Lock()
+	defer s.analytics.Mu.Unlock()
+	s.channelRules = append(append([]ChannelRule(nil), rules...), defaultChannelRules...)
+}
+
+// classifyChannel returns the channel of traffic with the given UTM medium and referrer
+// domain: a matching medium rule, paid if the landing URL carries an ad click ID, direct
+// without a referrer, a matching domain rule, or referral. The caller must hold the
+// analytics lock.
+func (s *Service) classifyChannel(medium, domain string, paidClick bool) string {
+	if medium != "" {
+		for _, rule := range s.channelRules {
+			if rule.Medium != "" && rule.Medium == medium {
+				return rule.Channel
+			}
+		}
+	}
+	if paidClick {
+		return ChannelPaid
+	}
+	if domain == "" {
+		return ChannelDirect
+	}
+	for _, rule := range s.channelRules {
+		if rule.Domain != "" && matchesDomain(domain, rule.Domain) {
+			return rule.Channel
+		}
+	}
+	return ChannelReferral
+}
+
+// matchesDomain reports whether a hostname matches a rule's domain pattern
+func matchesDomain(host, pattern string) bool {
+	if strings.HasSuffix(pattern, ".") {
+		return strings.HasPrefix(host, pattern) || strings.Contains(host, "."+pattern)
+	}
+	return host == pattern || strings.HasSuffix(host, "."+pattern)
+}
+
+// processChannel counts the channel a visit arrived through, from the UTM medium and ad
+// click IDs of the landing URL and the referrer. Referrers on the same site count as
+// direct, since the visit did not come from elsewhere. The caller must hold the
+// analytics lock.
+func (s *Service) processChannel(event *models.AnalyticsEvent) {
+	var medium string
+	var paidClick bool
+	if u, err := url.Parse(event.URL); err == nil {
+		query := u.Query()
+		medium = strings.ToLower(strings.TrimSpace(query.Get("utm_medium")))
+		for _, param := range paidClickIDs {
+			if query.Has(param) {
+				paidClick = true
+				break
+			}
+		}
+	}
+
+	domain := SiteHost(event.Referrer)
+	if domain != "" && domain == SiteHost(event.URL) {
+		domain = ""
+	}
+	s.channelCounts[s.classifyChannel(medium, domain, paidClick)]++
+}
+
+// getTrafficChannels returns visits by channel with their share of all visits, largest first
+func (s *Service) getTrafficChannels() []models.DimensionCount {
+	var total int64
+	for _, count := range s.channelCounts {
+		total += count
+	}
+
+	result := make([]models.DimensionCount, 0, len(s.channelCounts))
+	for channel, count := range s.channelCounts {
+		result = append(result, models.DimensionCount{
+			Name:    channel,
+			Count:   count,
+			Percent: float64(count) / float64(total) * 100,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
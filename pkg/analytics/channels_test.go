@@ -0,0 +1,61 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+func TestTrafficChannels(t *testing.T) {
+	service := NewService()
+	rules, err := ParseChannelRules("partner.example.org=partners; medium:podcast=audio")
+	if err != nil {
+		t.Fatalf("ParseChannelRules failed: %v", err)
+	}
+	service.SetChannelRules(rules)
+
+	visits := []struct{ session, url, referrer string }{
+		{"s1", "https://shop.com/", "https://www.google.co.uk/search?q=shoes"},
+		{"s2", "https://shop.com/?utm_source=news&utm_medium=email", "https://mail.google.com/"},
+		{"s3", "https://shop.com/?gclid=abc", "https://www.google.com/"},
+		{"s4", "https://shop.com/", ""},
+		{"s5", "https://shop.com/", "https://t.co/xyz"},
+		{"s6", "https://shop.com/", "https://blog.partner.example.org/post"},
+		{"s7", "https://shop.com/?utm_medium=Podcast", ""},
+		{"s8", "https://shop.com/", "https://someblog.net/"},
+		{"s9", "https://shop.com/cart", "https://shop.com/"},
+		// Later page views of a visit are not counted again
+		{"s1", "https://shop.com/pricing", "https://shop.com/"},
+	}
+	for _, visit := range visits {
+		service.ProcessEvent(&models.AnalyticsEvent{Type: models.PageView, Timestamp: time.Now(), SessionID: visit.session, URL: visit.url, Referrer: visit.referrer})
+	}
+
+	got := make(map[string]int64)
+	for _, channel := range service.GetSnapshot().TrafficChannels {
+		got[channel.Name] = channel.Count
+	}
+	want := map[string]int64{
+		ChannelSearch: 1, ChannelEmail: 1, ChannelPaid: 1, ChannelDirect: 2,
+		ChannelSocial: 1, "partners": 1, "audio": 1, ChannelReferral: 1,
+	}
+	if len(got) != len(want) {
+		t.Errorf("expected channels %v, got %v", want, got)
+	}
+	for channel, count := range want {
+		if got[channel] != count {
+			t.Errorf("channel %s: expected %d visits, got %d", channel, count, got[channel])
+		}
+	}
+
+	for _, source := range service.GetSnapshot().TrafficSources {
+		if source.Source == "google.co.uk" && source.Channel != ChannelSearch {
+			t.Errorf("expected google.co.uk to be classified as search, got %q", source.Channel)
+		}
+	}
+
+	if _, err := ParseChannelRules("missing-channel"); err == nil {
+		t.Errorf("expected an error for a rule without a channel")
+	}
+}
@@ -0,0 +1,194 @@
+package analytics
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/idset"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+// Number of products included in snapshots
+const maxTopProducts = 10
+
+// Purchases are counted once per order ID: the IDs of up to maxRecentOrders orders are
+// remembered for orderWindow, so client retries and webhook redeliveries don't add
+// revenue twice
+const (
+	maxRecentOrders = 100000
+	orderWindow     = 24 * time.Hour
+)
+
+// DefaultCurrency is the reporting currency used unless SetCommerceCurrency is called
+const DefaultCurrency = "USD"
+
+// product holds the aggregated sales of one product
+type product struct {
+	name     string
+	units    int64
+	revenue  float64
+	cartAdds int64
+}
+
+// commerceTracker aggregates e-commerce events. Revenue is reported in a single
+// currency; orders in other currencies are totalled separately without conversion.
+type commerceTracker struct {
+	currency        string
+	revenue         float64
+	orders          int64
+	cartAdds        int64
+	checkouts       int64
+	viewers         map[string]bool // Users with a page view
+	purchasers      map[string]bool
+	orderIDs        *idset.Set // IDs of recent purchases
+	products        map[string]*product
+	otherCurrencies map[string]float64
+}
+
+// newCommerceTracker creates a tracker reporting in currency
+func newCommerceTracker(currency string) *commerceTracker {
+	return &commerceTracker{
+		currency:        currency,
+		viewers:         make(map[string]bool),
+		purchasers:      make(map[string]bool),
+		orderIDs:        idset.New(maxRecentOrders, orderWindow),
+		products:        make(map[string]*product),
+		otherCurrencies: make(map[string]float64),
+	}
+}
+
+// SetCommerceCurrency sets the currency revenue is reported in
+func (s *Service) SetCommerceCurrency(currency string) {
+	s.analytics.Mu.Lock()
+	defer s.analytics.Mu.Unlock()
+	s.commerce.currency = strings.ToUpper(currency)
+}
+
+// processCommerce records page viewers for the purchase conversion rate and aggregates
+// cart, checkout and purchase events. Events without valid order details, and repeated
+// purchases of an order, still count towards the event totals but not revenue. The caller must hold the analytics lock.
+func (s *Service) processCommerce(event *models.AnalyticsEvent, weight int64) {
+	c := s.commerce
+
+	if event.Type == models.PageView && event.UserID != "" {
+		c.viewers[event.UserID] = true
+		return
+	}
+	if !event.Type.IsCommerce() {
+		return
+	}
+
+	order, err := models.OrderFromEvent(event, c.currency)
+	if err != nil {
+		return
+	}
+
+	switch event.Type {
+	case models.AddToCart:
+		c.cartAdds += weight
+		for _, item := range order.Items {
+			c.product(item).cartAdds += item.Quantity * weight
+		}
+
+	case models.Checkout:
+		c.checkouts += weight
+
+	case models.Purchase:
+		if c.orderIDs.Add(order.OrderID) {
+			return
+		}
+		if order.Currency != c.currency {
+			c.otherCurrencies[order.Currency] += order.Revenue * float64(weight)
+		} else {
+			c.revenue += order.Revenue * float64(weight)
+			c.orders += weight
+		}
+		if event.UserID != "" {
+			c.purchasers[event.UserID] = true
+		}
+
+		for _, item := range order.Items {
+			p := c.product(item)
+			p.units += item.Quantity * weight
+			if order.Currency == c.currency {
+				p.revenue += item.Price * float64(item.Quantity*weight)
+			}
+		}
+	}
+}
+
+// product returns the aggregate for a line item's product, creating it if needed
+func (c *commerceTracker) product(item models.LineItem) *product {
+	p, ok := c.products[item.ProductID]
+	if !ok {
+		p = &product{}
+		c.products[item.ProductID] = p
+	}
+	if item.Name != "" {
+		p.name = item.Name
+	}
+	return p
+}
+
+// getCommerceMetrics returns revenue analytics with the top products by revenue.
+// The caller must hold the analytics lock.
+func (s *Service) getCommerceMetrics() models.CommerceMetrics {
+	c := s.commerce
+
+	metrics := models.CommerceMetrics{
+		Currency:     c.currency,
+		TotalRevenue: c.revenue,
+		Orders:       c.orders,
+		CartAdds:     c.cartAdds,
+		Checkouts:    c.checkouts,
+		Purchasers:   int64(len(c.purchasers)),
+		TopProducts:  make([]models.ProductMetric, 0, len(c.products)),
+	}
+	if c.orders > 0 {
+		metrics.AverageOrderValue = c.revenue / float64(c.orders)
+	}
+
+	// Page view to purchase conversion only counts purchasers who were seen viewing a page
+	if len(c.viewers) > 0 {
+		converted := 0
+		for userID := range c.purchasers {
+			if c.viewers[userID] {
+				converted++
+			}
+		}
+		metrics.ConversionRate = float64(converted) / float64(len(c.viewers))
+	}
+
+	if len(c.otherCurrencies) > 0 {
+		metrics.OtherCurrencies = make(map[string]float64, len(c.otherCurrencies))
+		for currency, revenue := range c.otherCurrencies {
+			metrics.OtherCurrencies[currency] = revenue
+		}
+	}
+
+	for productID, p := range c.products {
+		metrics.TopProducts = append(metrics.TopProducts, models.ProductMetric{
+			ProductID: productID,
+			Name:      p.name,
+			Units:     p.units,
+			Revenue:   p.revenue,
+			CartAdds:  p.cartAdds,
+		})
+	}
+	sort.Slice(metrics.TopProducts, func(i, j int) bool {
+		a, b := metrics.TopProducts[i], metrics.TopProducts[j]
+		if a.Revenue != b.Revenue {
+			return a.Revenue > b.Revenue
+		}
+		if a.Units != b.Units {
+			return a.Units > b.Units
+		}
+		return a.ProductID < b.ProductID
+	})
+	if len(metrics.TopProducts) > maxTopProducts {
+		metrics.TopProducts = metrics.TopProducts[:maxTopProducts]
+	}
+
+	return metrics
+}
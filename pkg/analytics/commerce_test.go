@@ -0,0 +1,96 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+func TestCommerceMetrics(t *testing.T) {
+	service := NewService()
+
+	event := func(userID string, eventType models.EventType, metadata map[string]interface{}) {
+		service.ProcessEvent(&models.AnalyticsEvent{
+			ID:        userID + string(eventType),
+			Type:      eventType,
+			Timestamp: time.Now(),
+			UserID:    userID,
+			Path:      "/shop",
+			Metadata:  metadata,
+		})
+	}
+	item := func(productID string, price float64, quantity float64) map[string]interface{} {
+		return map[string]interface{}{"product_id": productID, "price": price, "quantity": quantity}
+	}
+
+	event("u1", models.PageView, nil)
+	event("u2", models.PageView, nil)
+	event("u3", models.PageView, nil)
+	event("u4", models.PageView, nil)
+	event("u1", models.AddToCart, map[string]interface{}{"items": []interface{}{item("shoes", 80, 1)}})
+	event("u1", models.Checkout, nil)
+	event("u1", models.Purchase, map[string]interface{}{
+		"order_id": "o1",
+		"items":    []interface{}{item("shoes", 80, 1), item("socks", 5, 4)},
+	})
+	event("u2", models.Purchase, map[string]interface{}{
+		"order_id": "o2",
+		"revenue":  40.0,
+		"items":    []interface{}{item("socks", 5, 8)},
+	})
+	event("u5", models.Purchase, map[string]interface{}{
+		"order_id": "o3",
+		"currency": "eur",
+		"items":    []interface{}{item("shoes", 70, 1)},
+	})
+	event("u6", models.Purchase, map[string]interface{}{"items": []interface{}{item("hat", 20, 1)}}) // No order ID
+
+	commerce := service.GetSnapshot().Commerce
+	if commerce.TotalRevenue != 140 || commerce.Orders != 2 || commerce.AverageOrderValue != 70 {
+		t.Errorf("Unexpected revenue: %+v", commerce)
+	}
+	if commerce.CartAdds != 1 || commerce.Checkouts != 1 || commerce.Purchasers != 3 {
+		t.Errorf("Unexpected funnel counts: %+v", commerce)
+	}
+	// u1 and u2 of the four page viewers purchased; u5 never viewed a page
+	if commerce.ConversionRate != 0.5 {
+		t.Errorf("ConversionRate = %v, want 0.5", commerce.ConversionRate)
+	}
+	if commerce.OtherCurrencies["EUR"] != 70 {
+		t.Errorf("Expected EUR revenue to be reported separately, got %v", commerce.OtherCurrencies)
+	}
+
+	if len(commerce.TopProducts) != 2 {
+		t.Fatalf("Expected 2 products, got %+v", commerce.TopProducts)
+	}
+	shoes, socks := commerce.TopProducts[0], commerce.TopProducts[1]
+	if shoes.ProductID != "shoes" || shoes.Units != 2 || shoes.Revenue != 80 || shoes.CartAdds != 1 {
+		t.Errorf("Unexpected top product: %+v", shoes)
+	}
+	if socks.ProductID != "socks" || socks.Units != 12 || socks.Revenue != 60 {
+		t.Errorf("Unexpected second product: %+v", socks)
+	}
+}
+
+func TestPurchasesCountOncePerOrder(t *testing.T) {
+	service := NewService()
+	for i, eventID := range []string{"evt-1", "evt-2"} {
+		service.ProcessEvent(&models.AnalyticsEvent{
+			ID:        eventID,
+			Type:      models.Purchase,
+			Timestamp: time.Now(),
+			UserID:    "u1",
+			Metadata: map[string]interface{}{
+				"order_id": "o1",
+				"revenue":  float64(50 * (i + 1)), // A retry may differ; the first one counts
+				"items":    []interface{}{map[string]interface{}{"product_id": "shoes", "price": 50.0}},
+			},
+		})
+	}
+
+	commerce := service.GetSnapshot().Commerce
+	if commerce.TotalRevenue != 50 || commerce.Orders != 1 || commerce.TopProducts[0].Units != 1 {
+		t.Errorf("expected the order counted once, got %+v", commerce)
+	}
+}
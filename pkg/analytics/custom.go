@@ -0,0 +1,200 @@
+package analytics
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+const (
+	// Distinct values tracked per count_by metric before the rest are grouped
+	maxCustomMetricValues = 100
+
+	// Bucket for count_by values beyond the limit
+	customMetricOther = "(other)"
+)
+
+// customMetric is the running state of a registered custom metric
+type customMetric struct {
+	rule   models.CustomMetricRule
+	total  float64
+	values map[string]float64
+	users  map[string]bool
+}
+
+// RegisterCustomMetric adds an aggregation rule for a custom (or built-in) event type.
+// Registering an existing name replaces its rule and resets its value.
+func (s *Service) RegisterCustomMetric(rule models.CustomMetricRule) error {
+	if err := validateCustomMetricRule(rule); err != nil {
+		return err
+	}
+
+	s.analytics.Mu.Lock()
+	defer s.analytics.Mu.Unlock()
+
+	if old, ok := s.customMetrics[rule.Name]; ok {
+		s.removeCustomMetric(old)
+	}
+
+	metric := &customMetric{
+		rule:   rule,
+		values: make(map[string]float64),
+		users:  make(map[string]bool),
+	}
+	s.customMetrics[rule.Name] = metric
+	s.customByType[rule.EventType] = append(s.customByType[rule.EventType], metric)
+	return nil
+}
+
+// removeCustomMetric unregisters a metric; the caller must hold the analytics lock
+func (s *Service) removeCustomMetric(metric *customMetric) {
+	delete(s.customMetrics, metric.rule.Name)
+
+	metrics := s.customByType[metric.rule.EventType]
+	for i, m := range metrics {
+		if m == metric {
+			s.customByType[metric.rule.EventType] = append(metrics[:i], metrics[i+1:]...)
+			break
+		}
+	}
+}
+
+// validateCustomMetricRule checks that a rule is complete
+func validateCustomMetricRule(rule models.CustomMetricRule) error {
+	if rule.Name == "" {
+		return fmt.Errorf("custom metric name is required")
+	}
+	if !rule.EventType.Valid() {
+		return fmt.Errorf("invalid event type %q", rule.EventType)
+	}
+
+	switch rule.Kind {
+	case models.AggregateCountBy, models.AggregateSum:
+		if rule.Field == "" {
+			return fmt.Errorf("%s metric %q requires a metadata field", rule.Kind, rule.Name)
+		}
+	case models.AggregateUniqueUsers:
+	default:
+		return fmt.Errorf("unsupported aggregation %q", rule.Kind)
+	}
+	return nil
+}
+
+// processCustomMetrics applies the rules registered for the event's type.
+// The caller must hold the analytics lock.
+func (s *Service) processCustomMetrics(event *models.AnalyticsEvent) {
+	for _, metric := range s.customByType[event.Type] {
+		switch metric.rule.Kind {
+		case models.AggregateCountBy:
+			value, ok := event.Metadata[metric.rule.Field]
+			if !ok {
+				continue
+			}
+			key := fmt.Sprint(value)
+			if _, seen := metric.values[key]; !seen && len(metric.values) >= maxCustomMetricValues {
+				key = customMetricOther
+			}
+			metric.values[key]++
+			metric.total++
+
+		case models.AggregateSum:
+			if value, ok := numericValue(event.Metadata[metric.rule.Field]); ok {
+				metric.total += value
+			}
+
+		case models.AggregateUniqueUsers:
+			if event.UserID != "" && !metric.users[event.UserID] {
+				metric.users[event.UserID] = true
+				metric.total++
+			}
+		}
+	}
+}
+
+// numericValue converts a decoded JSON metadata value to a number
+func numericValue(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// GetCustomMetricRules returns the registered custom metric rules
+func (s *Service) GetCustomMetricRules() []models.CustomMetricRule {
+	s.analytics.Mu.RLock()
+	defer s.analytics.Mu.RUnlock()
+
+	rules := make([]models.CustomMetricRule, 0, len(s.customMetrics))
+	for _, metric := range s.customMetrics {
+		rules = append(rules, metric.rule)
+	}
+	return rules
+}
+
+// GetCustomMetrics returns the current custom metric values by name
+func (s *Service) GetCustomMetrics() map[string]models.CustomMetric {
+	s.analytics.Mu.RLock()
+	defer s.analytics.Mu.RUnlock()
+	return s.getCustomMetrics()
+}
+
+// getCustomMetrics returns the current custom metric values; the caller must hold the analytics lock
+func (s *Service) getCustomMetrics() map[string]models.CustomMetric {
+	result := make(map[string]models.CustomMetric, len(s.customMetrics))
+	for name, metric := range s.customMetrics {
+		custom := models.CustomMetric{
+			CustomMetricRule: metric.rule,
+			Total:            metric.total,
+		}
+		if metric.rule.Kind == models.AggregateCountBy {
+			custom.Values = make(map[string]float64, len(metric.values))
+			for value, count := range metric.values {
+				custom.Values[value] = count
+			}
+		}
+		result[name] = custom
+	}
+	return result
+}
+
+// ParseCustomMetricRules parses rules of the form
+// "plans=signup:count_by:plan;revenue=purchase:sum:amount;buyers=purchase:unique_users"
+func ParseCustomMetricRules(spec string) ([]models.CustomMetricRule, error) {
+	var rules []models.CustomMetricRule
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		name, definition, ok := strings.Cut(entry, "=")
+		parts := strings.Split(definition, ":")
+		if !ok || len(parts) < 2 || len(parts) > 3 {
+			return nil, fmt.Errorf("invalid custom metric %q, expected name=event_type:kind[:field]", entry)
+		}
+
+		rule := models.CustomMetricRule{
+			Name:      strings.TrimSpace(name),
+			EventType: models.EventType(strings.TrimSpace(parts[0])),
+			Kind:      models.AggregationKind(strings.TrimSpace(parts[1])),
+		}
+		if len(parts) == 3 {
+			rule.Field = strings.TrimSpace(parts[2])
+		}
+		if err := validateCustomMetricRule(rule); err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
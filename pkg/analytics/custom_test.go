@@ -0,0 +1,62 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+func TestCustomMetrics(t *testing.T) {
+	service := NewService()
+
+	rules, err := ParseCustomMetricRules("plans=signup:count_by:plan;revenue=purchase:sum:amount;buyers=purchase:unique_users")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, rule := range rules {
+		if err := service.RegisterCustomMetric(rule); err != nil {
+			t.Fatalf("failed to register %s: %v", rule.Name, err)
+		}
+	}
+
+	events := []models.AnalyticsEvent{
+		{Type: "signup", UserID: "u1", Metadata: map[string]interface{}{"plan": "pro"}},
+		{Type: "signup", UserID: "u2", Metadata: map[string]interface{}{"plan": "free"}},
+		{Type: "signup", UserID: "u3", Metadata: map[string]interface{}{"plan": "pro"}},
+		{Type: "purchase", UserID: "u1", Metadata: map[string]interface{}{"amount": 19.5}},
+		{Type: "purchase", UserID: "u1", Metadata: map[string]interface{}{"amount": "10.5"}},
+		{Type: "purchase", UserID: "u2", Metadata: map[string]interface{}{"amount": "n/a"}},
+	}
+	for i := range events {
+		events[i].Timestamp = time.Now()
+		service.ProcessEvent(&events[i])
+	}
+
+	metrics := service.GetSnapshot().CustomMetrics
+	if plans := metrics["plans"]; plans.Total != 3 || plans.Values["pro"] != 2 || plans.Values["free"] != 1 {
+		t.Errorf("unexpected plans metric: %+v", plans)
+	}
+	if revenue := metrics["revenue"]; revenue.Total != 30 {
+		t.Errorf("expected revenue 30, got %v", revenue.Total)
+	}
+	if buyers := metrics["buyers"]; buyers.Total != 2 {
+		t.Errorf("expected 2 buyers, got %v", buyers.Total)
+	}
+}
+
+func TestRegisterCustomMetricValidation(t *testing.T) {
+	service := NewService()
+
+	invalid := []models.CustomMetricRule{
+		{Name: "", EventType: "signup", Kind: models.AggregateUniqueUsers},
+		{Name: "bad_type", EventType: "Sign Up", Kind: models.AggregateUniqueUsers},
+		{Name: "no_field", EventType: "signup", Kind: models.AggregateSum},
+		{Name: "bad_kind", EventType: "signup", Kind: "median"},
+	}
+	for _, rule := range invalid {
+		if err := service.RegisterCustomMetric(rule); err == nil {
+			t.Errorf("expected %+v to be rejected", rule)
+		}
+	}
+}
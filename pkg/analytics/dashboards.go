@@ -0,0 +1,363 @@
+package analytics
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/errs"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/store"
+)
+
+const (
+	// Widgets allowed on one dashboard
+	maxDashboardWidgets = 50
+
+	// Rows returned by list widgets without a limit, and the most allowed
+	defaultWidgetLimit = 10
+	maxWidgetLimit     = 100
+
+	// Window of time series and recent event widgets without one
+	defaultWidgetWindow = 24 * time.Hour
+)
+
+var (
+	// ErrDashboardNotFound is returned when no dashboard has the given name
+	ErrDashboardNotFound = errs.Errorf(errs.ErrNotFound, "dashboard not found")
+
+	// ErrDashboardExists is returned when creating a dashboard whose name is taken
+	ErrDashboardExists = errs.Errorf(errs.ErrConflict, "dashboard already exists")
+)
+
+// dashboardNamePattern restricts dashboard names to URL- and subscription-safe identifiers
+var dashboardNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// widgetChartTypes are the chart types a widget may request; they do not change its data
+var widgetChartTypes = map[string]bool{"number": true, "line": true, "bar": true, "pie": true, "table": true, "list": true, "map": true}
+
+// widgetMetric describes what a widget metric accepts
+type widgetMetric struct {
+	window     bool // Uses the widget's time window
+	eventType  bool // Accepts an event type filter
+	pathPrefix bool // Accepts a path prefix filter
+}
+
+// widgetMetrics are the metrics dashboards can show
+var widgetMetrics = map[string]widgetMetric{
+	"total_events":        {},
+	"unique_users":        {},
+	"active_sessions":     {},
+	"bot_events":          {},
+	"events_by_type":      {},
+	"active_visitors":     {pathPrefix: true},
+	"top_pages":           {pathPrefix: true},
+	"entry_pages":         {pathPrefix: true},
+	"exit_pages":          {pathPrefix: true},
+	"traffic_sources":     {},
+	"traffic_channels":    {},
+	"devices":             {},
+	"browsers":            {},
+	"os":                  {},
+	"hourly_events":       {window: true, eventType: true},
+	"hourly_page_views":   {window: true},
+	"hourly_unique_users": {window: true},
+	"recent_events":       {window: true, eventType: true, pathPrefix: true},
+	"performance":         {},
+	"commerce":            {},
+	"errors":              {},
+	"campaigns":           {},
+	"geo":                 {},
+	"session_lengths":     {},
+	"goals":               {},
+}
+
+// ValidateDashboard checks that a dashboard's widgets can all be computed
+func ValidateDashboard(dashboard models.Dashboard) error {
+	if !dashboardNamePattern.MatchString(dashboard.Name) {
+		return errs.Errorf(errs.ErrInvalidRequest, "dashboard name must be 1-64 letters, digits, '-' or '_'")
+	}
+	if len(dashboard.Widgets) > maxDashboardWidgets {
+		return errs.Errorf(errs.ErrInvalidRequest, "a dashboard can have at most %d widgets", maxDashboardWidgets)
+	}
+
+	ids := make(map[string]bool, len(dashboard.Widgets))
+	for _, widget := range dashboard.Widgets {
+		if strings.TrimSpace(widget.ID) == "" {
+			return errs.Errorf(errs.ErrInvalidRequest, "widget id is required")
+		}
+		if ids[widget.ID] {
+			return errs.Errorf(errs.ErrInvalidRequest, "duplicate widget id %q", widget.ID)
+		}
+		ids[widget.ID] = true
+
+		if err := validateWidget(widget); err != nil {
+			return fmt.Errorf("widget %q: %w", widget.ID, err)
+		}
+	}
+	return nil
+}
+
+// validateWidget checks a widget's metric, chart type, window, limit and filters
+func validateWidget(widget models.Widget) error {
+	metric, ok := widgetMetrics[widget.Metric]
+	if !ok {
+		return errs.Errorf(errs.ErrInvalidRequest, "unsupported metric %q", widget.Metric)
+	}
+	if !widgetChartTypes[widget.Chart] {
+		return errs.Errorf(errs.ErrInvalidRequest, "unsupported chart %q (use number, line, bar, pie, table, list or map)", widget.Chart)
+	}
+	if widget.Window != "" {
+		if !metric.window {
+			return errs.Errorf(errs.ErrInvalidRequest, "metric %s has no time window", widget.Metric)
+		}
+		if window, err := time.ParseDuration(widget.Window); err != nil || window <= 0 {
+			return errs.Errorf(errs.ErrInvalidRequest, "invalid window %q, expected a positive duration such as 6h", widget.Window)
+		}
+	}
+	if widget.Limit < 0 || widget.Limit > maxWidgetLimit {
+		return errs.Errorf(errs.ErrInvalidRequest, "limit must be between 0 and %d", maxWidgetLimit)
+	}
+	if widget.Filters.EventType != "" && !metric.eventType {
+		return errs.Errorf(errs.ErrInvalidRequest, "metric %s cannot be filtered by event type", widget.Metric)
+	}
+	if widget.Filters.PathPrefix != "" && !metric.pathPrefix {
+		return errs.Errorf(errs.ErrInvalidRequest, "metric %s cannot be filtered by path", widget.Metric)
+	}
+	return nil
+}
+
+// LoadDashboards replaces the dashboards with those saved in the store
+func (s *Service) LoadDashboards(ctx context.Context, st store.DashboardStore) error {
+	dashboards, err := st.LoadDashboards(ctx)
+	if err != nil {
+		return err
+	}
+	for _, dashboard := range dashboards {
+		if err := ValidateDashboard(dashboard); err != nil {
+			return fmt.Errorf("saved dashboard %q: %w", dashboard.Name, err)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dashboards = dashboards
+	return nil
+}
+
+// Dashboards returns the dashboards in creation order
+func (s *Service) Dashboards() []models.Dashboard {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]models.Dashboard{}, s.dashboards...)
+}
+
+// Dashboard returns the dashboard with the given name
+func (s *Service) Dashboard(name string) (models.Dashboard, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if i := s.dashboardIndex(name); i >= 0 {
+		return s.dashboards[i], true
+	}
+	return models.Dashboard{}, false
+}
+
+// CreateDashboard validates and adds a dashboard
+func (s *Service) CreateDashboard(dashboard models.Dashboard) error {
+	if err := ValidateDashboard(dashboard); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.dashboardIndex(dashboard.Name) >= 0 {
+		return ErrDashboardExists
+	}
+	s.dashboards = append(s.dashboards, dashboard)
+	return nil
+}
+
+// UpdateDashboard replaces the named dashboard
+func (s *Service) UpdateDashboard(name string, dashboard models.Dashboard) error {
+	if dashboard.Name != name {
+		return errs.Errorf(errs.ErrInvalidRequest, "dashboard name cannot be changed")
+	}
+	if err := ValidateDashboard(dashboard); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.dashboardIndex(name)
+	if i < 0 {
+		return ErrDashboardNotFound
+	}
+	s.dashboards[i] = dashboard
+	return nil
+}
+
+// RemoveDashboard deletes the named dashboard
+func (s *Service) RemoveDashboard(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.dashboardIndex(name)
+	if i < 0 {
+		return ErrDashboardNotFound
+	}
+	s.dashboards = append(s.dashboards[:i:i], s.dashboards[i+1:]...)
+	return nil
+}
+
+// dashboardIndex returns the position of the named dashboard, or -1; the caller must hold s.mu
+func (s *Service) dashboardIndex(name string) int {
+	for i, dashboard := range s.dashboards {
+		if dashboard.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// DashboardData computes the current data of every widget of a dashboard from one
+// snapshot, so widgets showing related metrics are consistent with each other
+func (s *Service) DashboardData(dashboard models.Dashboard) models.DashboardData {
+	now := time.Now()
+	snapshot := s.GetSnapshot()
+	data := models.DashboardData{
+		Dashboard: dashboard.Name,
+		Timestamp: now,
+		Widgets:   make(map[string]interface{}, len(dashboard.Widgets)),
+	}
+	for _, widget := range dashboard.Widgets {
+		data.Widgets[widget.ID] = s.widgetData(widget, snapshot, now)
+	}
+	return data
+}
+
+// widgetData returns the data of one validated widget
+func (s *Service) widgetData(widget models.Widget, snapshot *models.MetricsSnapshot, now time.Time) interface{} {
+	limit := widget.Limit
+	if limit == 0 {
+		limit = defaultWidgetLimit
+	}
+	window := defaultWidgetWindow
+	if parsed, err := time.ParseDuration(widget.Window); err == nil && parsed > 0 {
+		window = parsed
+	}
+	prefix := widget.Filters.PathPrefix
+
+	switch widget.Metric {
+	case "total_events":
+		return snapshot.TotalEvents
+	case "unique_users":
+		return snapshot.UniqueUsers
+	case "active_sessions":
+		return snapshot.ActiveSessions
+	case "bot_events":
+		return snapshot.BotEvents
+	case "events_by_type":
+		return snapshot.EventsByType
+	case "active_visitors":
+		visitors := s.GetActiveVisitors()
+		visitors.Pages = limitList(filterList(visitors.Pages, func(page models.ActivePage) bool {
+			return strings.HasPrefix(page.Path, prefix)
+		}), limit)
+		return visitors
+	case "top_pages":
+		page, _ := s.QueryPages(Query{Limit: limit, PathPrefix: prefix})
+		return page.Items
+	case "entry_pages", "exit_pages":
+		pages := snapshot.EntryPages
+		if widget.Metric == "exit_pages" {
+			pages = snapshot.ExitPages
+		}
+		return limitList(filterList(pages, func(page models.DimensionCount) bool {
+			return strings.HasPrefix(page.Name, prefix)
+		}), limit)
+	case "traffic_sources":
+		page, _ := s.QuerySources(Query{Limit: limit})
+		return page.Items
+	case "traffic_channels":
+		return limitList(snapshot.TrafficChannels, limit)
+	case "devices", "browsers", "os":
+		dimension := map[string]string{"devices": "device", "browsers": "browser", "os": "os"}[widget.Metric]
+		page, _ := s.QueryDevices(Query{Limit: limit, Dimension: dimension})
+		return page.Items
+	case "hourly_events", "hourly_page_views", "hourly_unique_users":
+		return s.hourlySeries(widget, window, now)
+	case "recent_events":
+		page, _ := s.QueryEvents(Query{Limit: limit, EventType: widget.Filters.EventType, PathPrefix: prefix, From: now.Add(-window)})
+		return page.Items
+	case "performance":
+		return snapshot.PerformanceMetrics
+	case "commerce":
+		return snapshot.Commerce
+	case "errors":
+		return snapshot.Errors
+	case "campaigns":
+		return limitList(snapshot.CampaignStats, limit)
+	case "geo":
+		geo := snapshot.Geo
+		geo.Countries = limitList(geo.Countries, limit)
+		geo.Regions = limitList(geo.Regions, limit)
+		return geo
+	case "session_lengths":
+		return snapshot.Sessions
+	case "goals":
+		return limitList(snapshot.Goals, limit)
+	}
+	return nil
+}
+
+// hourlySeries returns one point per hour of the window, oldest first, from the hourly
+// rollups: events (optionally of one type), page views or unique users
+func (s *Service) hourlySeries(widget models.Widget, window time.Duration, now time.Time) []models.HourlyMetric {
+	start := now.Add(-window).Truncate(time.Hour)
+	rollups := make(map[int64]models.Rollup)
+	for _, rollup := range s.GetHourlyRollups() {
+		rollups[rollup.Start.Unix()] = rollup
+	}
+
+	var series []models.HourlyMetric
+	for hour := start; !hour.After(now); hour = hour.Add(time.Hour) {
+		rollup := rollups[hour.Unix()]
+		var value int64
+		switch {
+		case widget.Metric == "hourly_page_views":
+			value = rollup.PageViews
+		case widget.Metric == "hourly_unique_users":
+			value = rollup.UniqueUsers
+		case widget.Filters.EventType != "":
+			value = rollup.EventsByType[widget.Filters.EventType]
+		default:
+			value = rollup.Events
+		}
+		series = append(series, models.HourlyMetric{Hour: hour, Events: value})
+	}
+	return series
+}
+
+// filterList returns a copy of the items that pass the filter
+func filterList[T any](items []T, keep func(T) bool) []T {
+	result := make([]T, 0, len(items))
+	for _, item := range items {
+		if keep(item) {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// limitList returns at most limit items
+func limitList[T any](items []T, limit int) []T {
+	if len(items) > limit {
+		return items[:limit]
+	}
+	return items
+}
@@ -0,0 +1,91 @@
+package analytics
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/store"
+)
+
+func TestDashboardData(t *testing.T) {
+	service := NewService()
+	dashboard := models.Dashboard{
+		Name: "marketing",
+		Widgets: []models.Widget{
+			{ID: "total", Metric: "total_events", Chart: "number"},
+			{ID: "blog", Metric: "top_pages", Chart: "table", Filters: models.WidgetFilters{PathPrefix: "/blog"}},
+			{ID: "clicks", Metric: "hourly_events", Chart: "line", Window: "3h", Filters: models.WidgetFilters{EventType: models.Click}},
+		},
+	}
+	if err := service.CreateDashboard(dashboard); err != nil {
+		t.Fatalf("CreateDashboard failed: %v", err)
+	}
+	if err := service.CreateDashboard(dashboard); !errors.Is(err, ErrDashboardExists) {
+		t.Errorf("expected ErrDashboardExists, got %v", err)
+	}
+
+	now := time.Now()
+	service.ProcessEvent(&models.AnalyticsEvent{Type: models.PageView, Timestamp: now, UserID: "u1", URL: "https://example.com/blog/a", Path: "/blog/a"})
+	service.ProcessEvent(&models.AnalyticsEvent{Type: models.PageView, Timestamp: now, UserID: "u1", URL: "https://example.com/", Path: "/"})
+	service.ProcessEvent(&models.AnalyticsEvent{Type: models.Click, Timestamp: now, UserID: "u1", URL: "https://example.com/", Path: "/"})
+
+	data := service.DashboardData(dashboard)
+	if total, _ := data.Widgets["total"].(int64); total != 3 {
+		t.Errorf("expected 3 total events, got %v", data.Widgets["total"])
+	}
+	pages, _ := data.Widgets["blog"].([]models.PageMetric)
+	if len(pages) != 1 || pages[0].Path != "/blog/a" {
+		t.Errorf("expected only the blog page, got %+v", data.Widgets["blog"])
+	}
+	series, _ := data.Widgets["clicks"].([]models.HourlyMetric)
+	if len(series) != 4 || series[len(series)-1].Events != 1 {
+		t.Errorf("expected 4 hourly points ending with 1 click, got %+v", series)
+	}
+}
+
+func TestValidateDashboard(t *testing.T) {
+	widget := func(w models.Widget) models.Dashboard {
+		return models.Dashboard{Name: "d", Widgets: []models.Widget{w}}
+	}
+	invalid := map[string]models.Dashboard{
+		"name":           {Name: "has space"},
+		"missing id":     widget(models.Widget{Metric: "total_events", Chart: "number"}),
+		"unknown metric": widget(models.Widget{ID: "a", Metric: "nope", Chart: "number"}),
+		"unknown chart":  widget(models.Widget{ID: "a", Metric: "total_events", Chart: "radar"}),
+		"window":         widget(models.Widget{ID: "a", Metric: "total_events", Chart: "number", Window: "1h"}),
+		"bad window":     widget(models.Widget{ID: "a", Metric: "hourly_events", Chart: "line", Window: "soon"}),
+		"filter":         widget(models.Widget{ID: "a", Metric: "traffic_sources", Chart: "pie", Filters: models.WidgetFilters{PathPrefix: "/x"}}),
+		"duplicate id": {Name: "d", Widgets: []models.Widget{
+			{ID: "a", Metric: "total_events", Chart: "number"},
+			{ID: "a", Metric: "unique_users", Chart: "number"},
+		}},
+	}
+	for name, dashboard := range invalid {
+		if err := ValidateDashboard(dashboard); err == nil {
+			t.Errorf("%s: expected a validation error", name)
+		}
+	}
+}
+
+func TestLoadDashboards(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemoryStore()
+	saved := []models.Dashboard{{Name: "ops", Widgets: []models.Widget{{ID: "errors", Metric: "errors", Chart: "table"}}}}
+	if err := st.SaveDashboards(ctx, saved); err != nil {
+		t.Fatalf("SaveDashboards failed: %v", err)
+	}
+
+	service := NewService()
+	if err := service.LoadDashboards(ctx, st); err != nil {
+		t.Fatalf("LoadDashboards failed: %v", err)
+	}
+	if _, ok := service.Dashboard("ops"); !ok {
+		t.Errorf("expected the saved dashboard to be loaded")
+	}
+	if err := service.RemoveDashboard("ops"); err != nil || len(service.Dashboards()) != 0 {
+		t.Errorf("expected the dashboard to be removed, got %v", err)
+	}
+}
@@ -0,0 +1,116 @@
+package analytics
+
+import (
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+// Entry and exit pages reported in snapshots
+const entryExitPagesLimit = 10
+
+// sessionPages is the first and latest page viewed in a session
+type sessionPages struct {
+	entry string
+	exit  string
+}
+
+// entryExitTracker counts sessions by the page they started on and the page they last
+// viewed. A session's exit page moves with each page view, so the counts of active
+// sessions show where visitors are leaving from so far; expired sessions keep their last
+// page. Only page views count, by path.
+type entryExitTracker struct {
+	sessions map[string]*sessionPages // Session ID -> pages, until the session expires
+	entries  map[string]int64         // Path -> sessions that started there
+	exits    map[string]int64         // Path -> sessions whose latest page it is
+	total    int64                    // Sessions with a page view
+}
+
+func newEntryExitTracker() *entryExitTracker {
+	return &entryExitTracker{
+		sessions: make(map[string]*sessionPages),
+		entries:  make(map[string]int64),
+		exits:    make(map[string]int64),
+	}
+}
+
+// processEntryExit records a page view as its session's latest page, and as its entry
+// page if it is the first. It reports whether the page view starts a visit: the first of
+// its session, or any page view without a session. The caller must hold the analytics lock.
+func (s *Service) processEntryExit(event *models.AnalyticsEvent) bool {
+	if event.SessionID == "" {
+		return true
+	}
+	path := pagePath(event)
+	if path == "" {
+		return false
+	}
+
+	t := s.entryExit
+	session := t.sessions[event.SessionID]
+	if session == nil {
+		t.sessions[event.SessionID] = &sessionPages{entry: path, exit: path}
+		t.entries[path]++
+		t.exits[path]++
+		t.total++
+		return true
+	}
+	if session.exit == path {
+		return false
+	}
+	t.exits[session.exit]--
+	if t.exits[session.exit] <= 0 {
+		delete(t.exits, session.exit)
+	}
+	t.exits[path]++
+	session.exit = path
+	return false
+}
+
+// pagePath returns the event's path, parsing it from the URL if the event has none
+func pagePath(event *models.AnalyticsEvent) string {
+	if event.Path != "" {
+		return event.Path
+	}
+	u, err := url.Parse(event.URL)
+	if err != nil {
+		return ""
+	}
+	if u.Path == "" && u.Host != "" {
+		return "/"
+	}
+	return u.Path
+}
+
+// expire forgets the pages of sessions that are no longer active; their counts are kept
+func (t *entryExitTracker) expire(active map[string]time.Time) {
+	for sessionID := range t.sessions {
+		if _, ok := active[sessionID]; !ok {
+			delete(t.sessions, sessionID)
+		}
+	}
+}
+
+// topPages returns the paths with the most sessions and their share of all sessions
+func (t *entryExitTracker) topPages(counts map[string]int64) []models.DimensionCount {
+	result := make([]models.DimensionCount, 0, len(counts))
+	for path, count := range counts {
+		result = append(result, models.DimensionCount{
+			Name:    path,
+			Count:   count,
+			Percent: float64(count) / float64(t.total) * 100,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Name < result[j].Name
+	})
+	if len(result) > entryExitPagesLimit {
+		result = result[:entryExitPagesLimit]
+	}
+	return result
+}
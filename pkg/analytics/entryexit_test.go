@@ -0,0 +1,44 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+func TestEntryExitPages(t *testing.T) {
+	service := NewService()
+	now := time.Now()
+	views := []struct{ session, path string }{
+		{"s1", "/"}, {"s1", "/pricing"}, {"s1", "/signup"},
+		{"s2", "/blog"}, {"s2", "/pricing"},
+		{"s3", "/"},
+	}
+	for _, view := range views {
+		service.ProcessEvent(&models.AnalyticsEvent{Type: models.PageView, Timestamp: now, SessionID: view.session, Path: view.path})
+	}
+	// Clicks do not move the exit page
+	service.ProcessEvent(&models.AnalyticsEvent{Type: models.Click, Timestamp: now, SessionID: "s3", Path: "/about"})
+
+	snapshot := service.GetSnapshot()
+	wantEntries := []models.DimensionCount{{Name: "/", Count: 2}, {Name: "/blog", Count: 1}}
+	wantExits := []models.DimensionCount{{Name: "/", Count: 1}, {Name: "/pricing", Count: 1}, {Name: "/signup", Count: 1}}
+	checkPages(t, "entry", snapshot.EntryPages, wantEntries)
+	checkPages(t, "exit", snapshot.ExitPages, wantExits)
+	if got := snapshot.EntryPages[0].Percent; got < 66 || got > 67 {
+		t.Errorf("expected / to be the entry page of 2 in 3 sessions, got %.1f%%", got)
+	}
+}
+
+func checkPages(t *testing.T, kind string, got, want []models.DimensionCount) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("expected %d %s pages, got %+v", len(want), kind, got)
+	}
+	for i := range want {
+		if got[i].Name != want[i].Name || got[i].Count != want[i].Count {
+			t.Errorf("%s page %d: expected %s with %d sessions, got %s with %d", kind, i, want[i].Name, want[i].Count, got[i].Name, got[i].Count)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package analytics
+
+import (
+	"sort"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/hll"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+// EraseUser removes a user from the recent events buffer and from every per-user
+// aggregate, and ends their sessions: those of their recent events and those indexed
+// for the user over the hourly window. Counters that do not
+// identify users, such as page views, are left unchanged. Distinct counts that have
+// switched to estimates cannot forget the user; the result reports when that happened.
+// The user's sessions are removed from the shared counters too, whose distinct counts
+// are always estimates.
+func (s *Service) EraseUser(userID string) models.ErasureResult {
+	result, state := s.eraseLocal(userID)
+	if state != nil {
+		s.eraseShared(state, result.Sessions)
+		result.Estimated = true
+	}
+	return result
+}
+
+// eraseLocal removes a user from the in-memory analytics and returns the shared state,
+// if any
+func (s *Service) eraseLocal(userID string) (models.ErasureResult, SharedState) {
+	result := models.ErasureResult{UserID: userID, Sessions: []string{}}
+	if userID == "" {
+		return result, nil
+	}
+
+	s.analytics.Mu.Lock()
+	defer s.analytics.Mu.Unlock()
+
+	// Copy rather than filter in place, since snapshots may share the old backing array
+	sessions := make(map[string]bool)
+	kept := make([]models.AnalyticsEvent, 0, len(s.analytics.Events))
+	for _, event := range s.analytics.Events {
+		if event.UserID != userID {
+			kept = append(kept, event)
+			continue
+		}
+		if event.SessionID != "" {
+			sessions[event.SessionID] = true
+		}
+		result.RecentEvents++
+	}
+	s.analytics.Events = kept
+	for _, sessionID := range s.sessions.forgetUser(userID) {
+		sessions[sessionID] = true
+	}
+
+	delete(s.visitors.visitors, "user:"+userID)
+	s.identities.forget(userID)
+	for sessionID := range sessions {
+		delete(s.analytics.SessionsActive, sessionID)
+		delete(s.visitors.visitors, "session:"+sessionID)
+		delete(s.sessions.sessions, sessionID)
+		delete(s.entryExit.sessions, sessionID)
+		delete(s.paths.sessions, sessionID)
+		result.Sessions = append(result.Sessions, sessionID)
+	}
+	sort.Strings(result.Sessions)
+
+	// Distinct counters
+	removed := s.analytics.UniqueUsers.Remove(userID)
+	removed = removeFromAll(s.analytics.HourlyUsers, userID) && removed
+	removed = removeFromAll(s.analytics.PageVisitors, userID) && removed
+	removed = removeFromAll(s.analytics.SiteVisitors, userID) && removed
+	for sessionID := range sessions {
+		removed = removeFromAll(s.analytics.HourlySessions, sessionID) && removed
+	}
+	result.Estimated = !removed
+
+	// Campaigns and experiments fall back to the session ID for anonymous participants
+	participants := append([]string{userID}, result.Sessions...)
+	for _, participant := range participants {
+		s.eraseParticipant(participant)
+	}
+
+	delete(s.commerce.viewers, userID)
+	delete(s.commerce.purchasers, userID)
+	delete(s.errors.users, userID)
+	for _, group := range s.errors.groups {
+		delete(group.users, userID)
+	}
+	for _, metric := range s.customMetrics {
+		delete(metric.users, userID)
+	}
+	return result, s.shared
+}
+
+// eraseParticipant removes a campaign and experiment participant. The caller must hold
+// the analytics lock.
+func (s *Service) eraseParticipant(participant string) {
+	delete(s.userCampaigns, participant)
+	for _, c := range s.campaigns {
+		delete(c.users, participant)
+		delete(c.converted, participant)
+	}
+
+	s.experiments.mu.Lock()
+	defer s.experiments.mu.Unlock()
+	for _, exp := range s.experiments.experiments {
+		delete(exp.assignments, participant)
+		delete(exp.converted, participant)
+	}
+}
+
+// removeFromAll removes an item from every counter, reporting false if any could not forget it
+func removeFromAll[K comparable](counters map[K]*hll.Counter, item string) bool {
+	removed := true
+	for _, counter := range counters {
+		removed = counter.Remove(item) && removed
+	}
+	return removed
+}
@@ -0,0 +1,72 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+func TestEraseUser(t *testing.T) {
+	service := NewService()
+	now := time.Now()
+	service.ProcessEvent(&models.AnalyticsEvent{ID: "1", Type: models.PageView, Timestamp: now, UserID: "u1", SessionID: "s1", URL: "https://example.com/?utm_source=news&utm_campaign=launch"})
+	service.ProcessEvent(&models.AnalyticsEvent{ID: "2", Type: models.Click, Timestamp: now, UserID: "u1", SessionID: "s1", URL: "https://example.com/"})
+	service.ProcessEvent(errorEvent("3", "u1", "at render (app.js:10:5)", now))
+	service.ProcessEvent(&models.AnalyticsEvent{ID: "4", Type: models.PageView, Timestamp: now, UserID: "u2", SessionID: "s2", URL: "https://example.com/"})
+
+	// Tombstones erase like a direct call
+	service.ProcessEvent(models.NewUserErasure("5", "u1"))
+
+	snapshot := service.GetSnapshot()
+	if snapshot.UniqueUsers != 1 || snapshot.ActiveSessions != 1 {
+		t.Errorf("expected only u2 and s2 to remain, got %d users and %d sessions", snapshot.UniqueUsers, snapshot.ActiveSessions)
+	}
+	if snapshot.Errors.AffectedUsers != 0 {
+		t.Errorf("expected no affected users after erasure, got %d", snapshot.Errors.AffectedUsers)
+	}
+	if len(snapshot.RealTimeEvents) != 1 {
+		t.Errorf("expected u1's recent events to be removed, got %d events", len(snapshot.RealTimeEvents))
+	}
+	if snapshot.TotalEvents != 4 {
+		t.Errorf("expected aggregate counters to be kept, got %d total events", snapshot.TotalEvents)
+	}
+
+	result := service.EraseUser("u2")
+	if result.RecentEvents != 1 || len(result.Sessions) != 1 || result.Sessions[0] != "s2" || result.Estimated {
+		t.Errorf("unexpected erasure result %+v", result)
+	}
+}
+
+func TestEraseUserFindsSessionsOutsideRecentEvents(t *testing.T) {
+	service := NewService()
+	service.SetRetention(RetentionConfig{RecentEvents: 1})
+	now := time.Now()
+	service.ProcessEvent(&models.AnalyticsEvent{ID: "1", Type: models.PageView, Timestamp: now, UserID: "u1", SessionID: "s1", URL: "https://example.com/"})
+	service.ProcessEvent(&models.AnalyticsEvent{ID: "2", Type: models.PageView, Timestamp: now, UserID: "u1", SessionID: "s1", URL: "https://example.com/pricing"})
+	service.ProcessEvent(&models.AnalyticsEvent{ID: "3", Type: models.PageView, Timestamp: now, UserID: "u2", SessionID: "s2", URL: "https://example.com/"})
+
+	result := service.EraseUser("u1")
+	if result.RecentEvents != 0 || len(result.Sessions) != 1 || result.Sessions[0] != "s1" {
+		t.Fatalf("expected s1 found after its events left the buffer, got %+v", result)
+	}
+	if service.sessions.sessions["s1"] != nil || service.paths.sessions["s1"] != nil || service.entryExit.sessions["s1"] != nil {
+		t.Error("expected s1 removed from the session, path and entry/exit state")
+	}
+	if _, ok := service.analytics.SessionsActive["s1"]; ok {
+		t.Error("expected s1 ended")
+	}
+
+	// Ended sessions stay indexed for the hourly window
+	service.ProcessEvent(&models.AnalyticsEvent{ID: "4", Type: models.PageView, Timestamp: now, UserID: "u3", SessionID: "s3", URL: "https://example.com/"})
+	service.Cleanup(now.Add(time.Hour))
+	if result := service.EraseUser("u3"); len(result.Sessions) != 1 || result.Sessions[0] != "s3" {
+		t.Errorf("expected the expired session s3 still found, got %+v", result)
+	}
+	service.ProcessEvent(&models.AnalyticsEvent{ID: "5", Type: models.PageView, Timestamp: now, UserID: "u4", SessionID: "s4", URL: "https://example.com/"})
+	service.ProcessEvent(&models.AnalyticsEvent{ID: "6", Type: models.PageView, Timestamp: now, UserID: "u5", SessionID: "s5", URL: "https://example.com/"})
+	service.Cleanup(now.Add(service.Retention().HourlyWindow + time.Hour))
+	if result := service.EraseUser("u4"); len(result.Sessions) != 0 {
+		t.Errorf("expected s4 forgotten after the hourly window, got %+v", result)
+	}
+}
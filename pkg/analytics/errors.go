@@ -0,0 +1,204 @@
+package analytics
+
+import (
+	"sort"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+const (
+	// Number of error groups included in snapshots
+	maxTopErrors = 10
+
+	// Distinct errors tracked; further errors count towards the totals but are not grouped
+	maxErrorGroups = 1000
+
+	// Users tracked overall and per error group; further users are not counted as affected
+	maxErrorUsers = 100000
+
+	// Recent window errors per minute and the error rate are computed over
+	errorRateWindow = 5 * time.Minute
+
+	// Minutes of per-minute counts kept for the timeline
+	errorTimelineMinutes = 60
+)
+
+// errorGroup aggregates the occurrences of one error
+type errorGroup struct {
+	details   models.ErrorDetails
+	severity  string
+	count     int64
+	users     map[string]bool
+	firstSeen time.Time
+	lastSeen  time.Time
+}
+
+// errorTracker aggregates error events and counts all events per minute for the error rate
+type errorTracker struct {
+	total      int64
+	users      map[string]bool
+	bySeverity map[string]int64
+	groups     map[string]*errorGroup // Stack hash -> group
+	errors     map[int64]int64        // Unix minute -> errors
+	events     map[int64]int64        // Unix minute -> all events
+	pruned     int64                  // Unix minute of the last prune
+}
+
+func newErrorTracker() *errorTracker {
+	return &errorTracker{
+		users:      make(map[string]bool),
+		bySeverity: make(map[string]int64),
+		groups:     make(map[string]*errorGroup),
+		errors:     make(map[int64]int64),
+		events:     make(map[int64]int64),
+	}
+}
+
+// severityRank orders severities, most severe highest
+var severityRank = map[string]int{
+	models.SeverityInfo:    0,
+	models.SeverityWarning: 1,
+	models.SeverityError:   2,
+	models.SeverityFatal:   3,
+}
+
+// processErrors counts every event towards the error rate and aggregates error events.
+// Error events without a message still count as errors but are not grouped. Events are
+// counted in the minute they happened, but never later than now, and not at all in the
+// timeline once that minute has fallen out of it. The caller must hold the analytics
+// lock.
+func (s *Service) processErrors(event *models.AnalyticsEvent, weight int64) {
+	e := s.errors
+	now := time.Now()
+	current := now.Truncate(time.Minute).Unix()
+	if current != e.pruned {
+		e.pruned = current
+		e.prune(current)
+	}
+	seen := event.Timestamp
+	if seen.After(now) {
+		seen = now
+	}
+	minute := seen.Truncate(time.Minute).Unix()
+	inTimeline := minute >= current-errorTimelineMinutes*60
+	if inTimeline {
+		e.events[minute] += weight
+	}
+
+	if event.Type != models.Error {
+		return
+	}
+	if inTimeline {
+		e.errors[minute] += weight
+	}
+	e.total += weight
+	addUser(e.users, event.UserID)
+
+	details, err := models.ErrorFromEvent(event)
+	if err != nil {
+		return
+	}
+	e.bySeverity[details.Severity] += weight
+
+	group := e.groups[details.StackHash]
+	if group == nil {
+		if len(e.groups) >= maxErrorGroups {
+			return
+		}
+		group = &errorGroup{
+			details:   details,
+			severity:  details.Severity,
+			users:     make(map[string]bool),
+			firstSeen: seen,
+		}
+		e.groups[details.StackHash] = group
+	}
+	group.count += weight
+	addUser(group.users, event.UserID)
+	if severityRank[details.Severity] > severityRank[group.severity] {
+		group.severity = details.Severity
+	}
+	if seen.Before(group.firstSeen) {
+		group.firstSeen = seen
+	}
+	if seen.After(group.lastSeen) {
+		group.lastSeen = seen
+	}
+}
+
+// addUser records an affected user, ignoring new users once maxErrorUsers are tracked
+func addUser(users map[string]bool, userID string) {
+	if userID != "" && (users[userID] || len(users) < maxErrorUsers) {
+		users[userID] = true
+	}
+}
+
+// prune drops per-minute counts that have fallen out of the timeline, once per new minute
+func (e *errorTracker) prune(minute int64) {
+	cutoff := minute - errorTimelineMinutes*60
+	for m := range e.events {
+		if m < cutoff {
+			delete(e.events, m)
+			delete(e.errors, m)
+		}
+	}
+}
+
+// getErrorMetrics summarises errors as of now. The caller must hold the analytics lock.
+func (s *Service) getErrorMetrics(now time.Time) models.ErrorMetrics {
+	e := s.errors
+
+	metrics := models.ErrorMetrics{
+		TotalErrors:   e.total,
+		AffectedUsers: int64(len(e.users)),
+		BySeverity:    copyCounts(e.bySeverity),
+		TopErrors:     make([]models.ErrorGroup, 0, min(len(e.groups), maxTopErrors)),
+		Timeline:      make([]models.ErrorMinute, 0, errorTimelineMinutes),
+	}
+
+	// The current minute is incomplete, so the window covers the minutes before it
+	current := now.Truncate(time.Minute)
+	windowMinutes := int64(errorRateWindow / time.Minute)
+	var windowErrors, windowEvents int64
+	for i := int64(1); i <= windowMinutes; i++ {
+		minute := current.Add(-time.Duration(i) * time.Minute).Unix()
+		windowErrors += e.errors[minute]
+		windowEvents += e.events[minute]
+	}
+	metrics.ErrorsPerMinute = float64(windowErrors) / float64(windowMinutes)
+	if windowEvents > 0 {
+		metrics.ErrorRate = float64(windowErrors) / float64(windowEvents)
+	}
+
+	for i := errorTimelineMinutes - 1; i >= 0; i-- {
+		minute := current.Add(-time.Duration(i) * time.Minute)
+		metrics.Timeline = append(metrics.Timeline, models.ErrorMinute{Minute: minute, Errors: e.errors[minute.Unix()]})
+	}
+
+	groups := make([]models.ErrorGroup, 0, len(e.groups))
+	for hash, group := range e.groups {
+		groups = append(groups, models.ErrorGroup{
+			StackHash:     hash,
+			Message:       group.details.Message,
+			Source:        group.details.Source,
+			Line:          group.details.Line,
+			Severity:      group.severity,
+			Count:         group.count,
+			AffectedUsers: int64(len(group.users)),
+			FirstSeen:     group.firstSeen,
+			LastSeen:      group.lastSeen,
+		})
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Count != groups[j].Count {
+			return groups[i].Count > groups[j].Count
+		}
+		return groups[i].StackHash < groups[j].StackHash
+	})
+	if len(groups) > maxTopErrors {
+		groups = groups[:maxTopErrors]
+	}
+	metrics.TopErrors = append(metrics.TopErrors, groups...)
+	return metrics
+}
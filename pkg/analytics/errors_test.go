@@ -0,0 +1,89 @@
+package analytics
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+func errorEvent(id, userID, stack string, ts time.Time) *models.AnalyticsEvent {
+	return &models.AnalyticsEvent{
+		ID: id, Type: models.Error, Timestamp: ts, UserID: userID,
+		Metadata: map[string]interface{}{
+			"message": "TypeError: x is undefined",
+			"stack":   stack,
+			"source":  "app.js",
+		},
+	}
+}
+
+func TestErrorMetrics(t *testing.T) {
+	service := NewService()
+	ts := time.Now().Truncate(time.Minute).Add(-time.Minute)
+
+	// The same stack from different builds groups together despite changed line numbers
+	service.ProcessEvent(errorEvent("1", "u1", "at render (app.js:10:5)", ts))
+	service.ProcessEvent(errorEvent("2", "u2", "at render (app.js:12:7)", ts))
+	service.ProcessEvent(errorEvent("3", "u1", "at submit (form.js:3:1)", ts))
+	for i := 0; i < 7; i++ {
+		service.ProcessEvent(&models.AnalyticsEvent{ID: "pv", Type: models.PageView, Timestamp: ts, UserID: "u3"})
+	}
+
+	metrics := service.GetSnapshot().Errors
+	if metrics.TotalErrors != 3 || metrics.AffectedUsers != 2 {
+		t.Errorf("expected 3 errors from 2 users, got %d from %d", metrics.TotalErrors, metrics.AffectedUsers)
+	}
+	if len(metrics.TopErrors) != 2 || metrics.TopErrors[0].Count != 2 || metrics.TopErrors[0].AffectedUsers != 2 {
+		t.Errorf("expected the render error to group 2 occurrences, got %+v", metrics.TopErrors)
+	}
+	if metrics.ErrorRate != 0.3 {
+		t.Errorf("expected an error rate of 0.3, got %v", metrics.ErrorRate)
+	}
+
+	service.SetAlerts([]models.AlertConfig{{Name: "errors", Type: "error", Metric: "error_rate", Threshold: 0.1, Operator: "gt", Enabled: true}})
+	alerts := service.CheckAlerts()
+	if len(alerts) != 1 || alerts[0].Severity != "high" {
+		t.Errorf("expected a high severity error alert, got %+v", alerts)
+	}
+}
+
+func TestErrorFromEventRequiresMessage(t *testing.T) {
+	if _, err := models.ErrorFromEvent(&models.AnalyticsEvent{Type: models.Error}); err == nil {
+		t.Error("expected an error event without a message to be rejected")
+	}
+	if _, err := models.ErrorFromEvent(&models.AnalyticsEvent{Type: models.Error, Metadata: map[string]interface{}{"message": "boom", "severity": "catastrophic"}}); err == nil {
+		t.Error("expected an unknown severity to be rejected")
+	}
+}
+
+func TestErrorsClampFutureTimestamps(t *testing.T) {
+	service := NewService()
+	ts := time.Now().Truncate(time.Minute).Add(-time.Minute)
+	service.ProcessEvent(errorEvent("1", "u1", "at render (app.js:10:5)", ts))
+
+	// A client clock hours ahead must not prune the recent minutes or skew last seen
+	service.ProcessEvent(errorEvent("2", "u2", "at render (app.js:10:5)", time.Now().Add(3*time.Hour)))
+
+	metrics := service.GetSnapshot().Errors
+	if metrics.ErrorsPerMinute == 0 {
+		t.Error("expected the recent error kept in the window")
+	}
+	if lastSeen := metrics.TopErrors[0].LastSeen; lastSeen.After(time.Now()) {
+		t.Errorf("expected last seen clamped to now, got %v", lastSeen)
+	}
+}
+
+func TestErrorMessagesAreTruncatedOnCharacters(t *testing.T) {
+	details, err := models.ErrorFromEvent(&models.AnalyticsEvent{Type: models.Error, Metadata: map[string]interface{}{
+		"message": "x" + strings.Repeat("é", 1024),
+	}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(details.Message) > 1024 || !utf8.ValidString(details.Message) {
+		t.Errorf("expected at most 1024 bytes of valid UTF-8, got %d bytes", len(details.Message))
+	}
+}
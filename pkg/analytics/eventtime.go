@@ -0,0 +1,140 @@
+package analytics
+
+import (
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+// EventTimeConfig controls the watermark that tracks event-time progress and how events
+// behind it are handled. An hour is closed once the watermark passes its end.
+type EventTimeConfig struct {
+	MaxOutOfOrder   time.Duration // How far the watermark trails the newest event time
+	AllowedLateness time.Duration // Events further behind the watermark leave their hour unchanged
+}
+
+// DefaultEventTimeConfig returns the default event-time settings: a one minute
+// watermark delay and late events accepted for as long as hourly data is kept by default
+func DefaultEventTimeConfig() EventTimeConfig {
+	return EventTimeConfig{
+		MaxOutOfOrder:   time.Minute,
+		AllowedLateness: DefaultRetention().HourlyWindow,
+	}
+}
+
+// lateness classifies an event against the watermark
+type lateness int
+
+const (
+	onTime  lateness = iota
+	late             // Behind the watermark, within the allowed lateness
+	tooLate          // Beyond the allowed lateness
+)
+
+// eventClock tracks the watermark and late event counts, guarded by the analytics lock
+type eventClock struct {
+	config       EventTimeConfig
+	maxEventTime time.Time // Newest event time seen, capped at the wall clock
+	late         int64     // Events behind the watermark that were still bucketed
+	dropped      int64     // Events too late to change their hour
+	corrections  int64     // Late events added to an already closed hour
+}
+
+// watermark returns the event time up to which all events are expected to have
+// arrived, or the zero time before the first event
+func (c *eventClock) watermark() time.Time {
+	if c.maxEventTime.IsZero() {
+		return time.Time{}
+	}
+	return c.maxEventTime.Add(-c.config.MaxOutOfOrder)
+}
+
+// closed reports whether the watermark has passed the end of the hour starting at hour
+func (c *eventClock) closed(hour time.Time) bool {
+	watermark := c.watermark()
+	return !watermark.IsZero() && !watermark.Before(hour.Add(time.Hour))
+}
+
+// observe classifies an event time against the current watermark, then advances the
+// watermark. Event times ahead of now, from skewed client clocks, only advance it to now.
+func (c *eventClock) observe(ts, now time.Time) lateness {
+	watermark := c.watermark()
+	if ts.After(c.maxEventTime) {
+		if ts.After(now) {
+			ts = now
+		}
+		if ts.After(c.maxEventTime) {
+			c.maxEventTime = ts
+		}
+	}
+
+	switch {
+	case watermark.IsZero() || !ts.Before(watermark):
+		return onTime
+	case ts.Before(watermark.Add(-c.config.AllowedLateness)):
+		return tooLate
+	default:
+		return late
+	}
+}
+
+// SetEventTime sets the watermark delay and allowed lateness; a negative delay or a
+// non-positive lateness uses the default. The watermark and counters are kept.
+func (s *Service) SetEventTime(config EventTimeConfig) {
+	defaults := DefaultEventTimeConfig()
+	if config.MaxOutOfOrder < 0 {
+		config.MaxOutOfOrder = defaults.MaxOutOfOrder
+	}
+	if config.AllowedLateness <= 0 {
+		config.AllowedLateness = defaults.AllowedLateness
+	}
+
+	s.analytics.Mu.Lock()
+	defer s.analytics.Mu.Unlock()
+	s.eventTime.config = config
+}
+
+// Watermark returns the event time up to which the service expects to have seen every
+// event; it is zero before the first event
+func (s *Service) Watermark() time.Time {
+	s.analytics.Mu.RLock()
+	defer s.analytics.Mu.RUnlock()
+	return s.eventTime.watermark()
+}
+
+// processHourly adds an event to its hourly counts and rollup by event time. Late
+// events still within the allowed lateness correct the rollup of an hour that was
+// already closed, which the history then persists again; events beyond it are only
+// counted as dropped. The caller must hold the analytics lock.
+func (s *Service) processHourly(event *models.AnalyticsEvent, weight int64) {
+	start := event.Timestamp.Truncate(time.Hour)
+	closed := s.eventTime.closed(start)
+
+	switch s.eventTime.observe(event.Timestamp, time.Now()) {
+	case tooLate:
+		s.eventTime.dropped += weight
+		return
+	case late:
+		s.eventTime.late += weight
+	}
+
+	hour := start.Unix()
+	s.analytics.HourlyData[hour] += weight
+	s.processHourlyRollup(hour, event, weight)
+	if closed {
+		s.analytics.HourlyRollups[hour].LateEvents += weight
+		s.eventTime.corrections += weight
+	}
+}
+
+// getEventTimeStats returns the watermark and late event counts
+func (s *Service) getEventTimeStats() models.EventTimeStats {
+	return models.EventTimeStats{
+		Watermark:              s.eventTime.watermark(),
+		MaxOutOfOrderSeconds:   s.eventTime.config.MaxOutOfOrder.Seconds(),
+		AllowedLatenessSeconds: s.eventTime.config.AllowedLateness.Seconds(),
+		LateEvents:             s.eventTime.late,
+		DroppedLateEvents:      s.eventTime.dropped,
+		CorrectedEvents:        s.eventTime.corrections,
+	}
+}
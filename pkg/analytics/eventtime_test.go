@@ -0,0 +1,90 @@
+package analytics
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/store"
+)
+
+func TestWatermarkAndLateEvents(t *testing.T) {
+	service := NewService()
+	service.SetEventTime(EventTimeConfig{MaxOutOfOrder: time.Minute, AllowedLateness: 3 * time.Hour})
+
+	now := time.Now().UTC().Truncate(time.Hour).Add(-30 * time.Minute)
+	service.ProcessEvent(&models.AnalyticsEvent{ID: "1", Type: models.Click, Timestamp: now})
+	if got := service.Watermark(); !got.Equal(now.Add(-time.Minute)) {
+		t.Fatalf("expected the watermark a minute behind the newest event, got %v", got)
+	}
+
+	// Out of order but ahead of the watermark
+	service.ProcessEvent(&models.AnalyticsEvent{ID: "2", Type: models.Click, Timestamp: now.Add(-30 * time.Second)})
+	// Late, into the previous, closed hour
+	previous := now.Truncate(time.Hour).Add(-time.Hour)
+	service.ProcessEvent(&models.AnalyticsEvent{ID: "3", Type: models.Click, Timestamp: previous.Add(10 * time.Minute)})
+	// Beyond the allowed lateness
+	service.ProcessEvent(&models.AnalyticsEvent{ID: "4", Type: models.Click, Timestamp: now.Add(-5 * time.Hour)})
+
+	stats := service.GetSnapshot().EventTime
+	if stats.LateEvents != 1 || stats.CorrectedEvents != 1 || stats.DroppedLateEvents != 1 {
+		t.Errorf("expected 1 late, 1 corrected and 1 dropped event, got %+v", stats)
+	}
+
+	rollups := make(map[time.Time]models.Rollup)
+	for _, rollup := range service.GetHourlyRollups() {
+		rollups[rollup.Start] = rollup
+	}
+	if rollup := rollups[previous]; rollup.Events != 1 || rollup.LateEvents != 1 {
+		t.Errorf("expected the previous hour corrected by the late event, got %+v", rollup)
+	}
+	if _, ok := rollups[now.Add(-5*time.Hour).Truncate(time.Hour)]; ok {
+		t.Error("expected the dropped event's hour to stay empty")
+	}
+	if snapshot := service.GetSnapshot(); snapshot.TotalEvents != 4 {
+		t.Errorf("expected every event in the totals, got %d", snapshot.TotalEvents)
+	}
+}
+
+func TestWatermarkIgnoresFutureTimestamps(t *testing.T) {
+	service := NewService()
+	service.SetEventTime(EventTimeConfig{MaxOutOfOrder: 0, AllowedLateness: time.Hour})
+
+	service.ProcessEvent(&models.AnalyticsEvent{ID: "1", Type: models.Click, Timestamp: time.Now().Add(24 * time.Hour)})
+	if service.Watermark().After(time.Now()) {
+		t.Fatalf("expected a skewed timestamp not to move the watermark past now, got %v", service.Watermark())
+	}
+
+	service.ProcessEvent(&models.AnalyticsEvent{ID: "2", Type: models.Click, Timestamp: time.Now().Add(-time.Minute)})
+	if stats := service.GetSnapshot().EventTime; stats.DroppedLateEvents != 0 {
+		t.Errorf("expected a recent event to be kept, got %+v", stats)
+	}
+}
+
+func TestHistoryRepersistsCorrectedHours(t *testing.T) {
+	ctx := context.Background()
+	service := NewService()
+	st := store.NewMemoryStore()
+	history := NewHistory(service, st)
+
+	now := time.Now().UTC()
+	previous := now.Truncate(time.Hour).Add(-time.Hour)
+	service.ProcessEvent(&models.AnalyticsEvent{Type: models.Click, Timestamp: previous.Add(time.Minute)})
+	service.ProcessEvent(&models.AnalyticsEvent{Type: models.Click, Timestamp: now})
+	if err := history.Flush(ctx); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	service.ProcessEvent(&models.AnalyticsEvent{Type: models.Click, Timestamp: previous.Add(2 * time.Minute)})
+	if err := history.Flush(ctx); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	stored, err := st.QueryRollups(ctx, models.GranularityHour, previous, previous.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("QueryRollups failed: %v", err)
+	}
+	if len(stored) != 1 || stored[0].Events != 2 || stored[0].LateEvents != 1 {
+		t.Errorf("expected the corrected rollup to be persisted, got %+v", stored)
+	}
+}
@@ -0,0 +1,270 @@
+package analytics
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+const (
+	// DefaultGoalKey configures the goal used by experiments without their own goal
+	DefaultGoalKey = "*"
+
+	// Two-sided significance level for variant comparisons
+	significanceLevel = 0.05
+
+	// Variant name treated as the baseline when present
+	controlVariant = "control"
+
+	// Experiments, variants per experiment and participants per experiment tracked,
+	// since all come from client input; later new ones are not tracked
+	maxExperiments            = 100
+	maxExperimentVariants     = 20
+	maxExperimentParticipants = 100000
+)
+
+// experiment holds the assignments and conversions of one experiment
+type experiment struct {
+	assignments map[string]string // Participant -> variant
+	converted   map[string]bool   // Participants that reached the goal
+	variants    map[string]bool   // Variants assigned so far
+}
+
+// experimentTracker aggregates experiment participation and conversions.
+// It has its own lock so it can be updated while the analytics lock is held.
+type experimentTracker struct {
+	goals       map[string]models.Goal // Experiment ID or DefaultGoalKey -> goal
+	experiments map[string]*experiment
+	byGoal      map[models.Goal][]string // Goal in effect -> IDs of the experiments converting on it
+	mu          sync.RWMutex
+}
+
+// newExperimentTracker creates a tracker whose default goal is a click
+func newExperimentTracker() *experimentTracker {
+	return &experimentTracker{
+		goals: map[string]models.Goal{
+			DefaultGoalKey: {EventType: models.Click},
+		},
+		experiments: make(map[string]*experiment),
+		byGoal:      make(map[models.Goal][]string),
+	}
+}
+
+// SetExperimentGoal sets the conversion goal of an experiment, or of all experiments
+// without their own goal when experimentID is DefaultGoalKey
+func (s *Service) SetExperimentGoal(experimentID string, goal models.Goal) {
+	s.experiments.mu.Lock()
+	defer s.experiments.mu.Unlock()
+	s.experiments.goals[experimentID] = goal
+	s.experiments.index()
+}
+
+// index rebuilds the experiments' goal index; the caller must hold the lock
+func (t *experimentTracker) index() {
+	t.byGoal = make(map[models.Goal][]string)
+	for id := range t.experiments {
+		goal := t.goal(id)
+		t.byGoal[goal] = append(t.byGoal[goal], id)
+	}
+}
+
+// goal returns the goal for an experiment; the caller must hold the lock
+func (t *experimentTracker) goal(experimentID string) models.Goal {
+	if goal, ok := t.goals[experimentID]; ok {
+		return goal
+	}
+	return t.goals[DefaultGoalKey]
+}
+
+// process records variant assignments carried in the event metadata and
+// conversions of participants that trigger their experiment's goal
+func (t *experimentTracker) process(event *models.AnalyticsEvent) {
+	participant := event.UserID
+	if participant == "" {
+		participant = event.SessionID
+	}
+	if participant == "" {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// A user keeps the first variant they were assigned
+	experimentID, _ := event.Metadata[models.MetadataExperimentID].(string)
+	variant, _ := event.Metadata[models.MetadataVariant].(string)
+	if experimentID != "" && variant != "" {
+		exp := t.experiments[experimentID]
+		if exp == nil && len(t.experiments) < maxExperiments {
+			exp = &experiment{
+				assignments: make(map[string]string),
+				converted:   make(map[string]bool),
+				variants:    make(map[string]bool),
+			}
+			t.experiments[experimentID] = exp
+			goal := t.goal(experimentID)
+			t.byGoal[goal] = append(t.byGoal[goal], experimentID)
+		}
+		if exp != nil {
+			exp.assign(participant, variant)
+		}
+	}
+
+	// Conversions count once per participant. Each goal in use is checked once, and
+	// only the experiments of the goals the event meets are looked at.
+	for goal, ids := range t.byGoal {
+		if !goal.Matches(event) {
+			continue
+		}
+		for _, id := range ids {
+			exp := t.experiments[id]
+			if _, assigned := exp.assignments[participant]; assigned {
+				exp.converted[participant] = true
+			}
+		}
+	}
+}
+
+// assign records a participant's first variant, within the experiment's bounds
+func (exp *experiment) assign(participant, variant string) {
+	if _, assigned := exp.assignments[participant]; assigned || len(exp.assignments) >= maxExperimentParticipants {
+		return
+	}
+	if !exp.variants[variant] {
+		if len(exp.variants) >= maxExperimentVariants {
+			return
+		}
+		exp.variants[variant] = true
+	}
+	exp.assignments[participant] = variant
+}
+
+// GetExperimentResults returns per-variant conversion results for all experiments
+func (s *Service) GetExperimentResults() []models.ExperimentResult {
+	t := s.experiments
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	results := make([]models.ExperimentResult, 0, len(t.experiments))
+	for id, exp := range t.experiments {
+		results = append(results, experimentResult(id, t.goal(id), exp))
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].ID < results[j].ID
+	})
+	return results
+}
+
+// GetExperimentResult returns the results of a single experiment
+func (s *Service) GetExperimentResult(experimentID string) (models.ExperimentResult, bool) {
+	t := s.experiments
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	exp, ok := t.experiments[experimentID]
+	if !ok {
+		return models.ExperimentResult{}, false
+	}
+	return experimentResult(experimentID, t.goal(experimentID), exp), true
+}
+
+// experimentResult computes variant statistics, comparing each variant against the control
+func experimentResult(id string, goal models.Goal, exp *experiment) models.ExperimentResult {
+	counts := make(map[string]*models.VariantResult)
+	for participant, variant := range exp.assignments {
+		result := counts[variant]
+		if result == nil {
+			result = &models.VariantResult{Variant: variant}
+			counts[variant] = result
+		}
+		result.Participants++
+		if exp.converted[participant] {
+			result.Conversions++
+		}
+	}
+
+	variants := make([]models.VariantResult, 0, len(counts))
+	for _, result := range counts {
+		result.ConversionRate = float64(result.Conversions) / float64(result.Participants)
+		variants = append(variants, *result)
+	}
+	sort.Slice(variants, func(i, j int) bool {
+		return variants[i].Variant < variants[j].Variant
+	})
+
+	// Use the "control" variant as baseline, or the first variant alphabetically
+	control := variants[0].Variant
+	if _, ok := counts[controlVariant]; ok {
+		control = controlVariant
+	}
+	baseline := counts[control]
+
+	for i := range variants {
+		if variants[i].Variant == control {
+			continue
+		}
+		compareToControl(&variants[i], baseline)
+	}
+
+	return models.ExperimentResult{
+		ID:        id,
+		Goal:      goal,
+		Control:   control,
+		Variants:  variants,
+		UpdatedAt: time.Now(),
+	}
+}
+
+// compareToControl fills in lift and a two-proportion z-test of the variant against the control
+func compareToControl(variant *models.VariantResult, control *models.VariantResult) {
+	if control.ConversionRate > 0 {
+		variant.Lift = (variant.ConversionRate - control.ConversionRate) / control.ConversionRate
+	}
+
+	n1, n2 := float64(control.Participants), float64(variant.Participants)
+	pooled := float64(control.Conversions+variant.Conversions) / (n1 + n2)
+	standardError := math.Sqrt(pooled * (1 - pooled) * (1/n1 + 1/n2))
+	if standardError == 0 {
+		variant.PValue = 1
+		return
+	}
+
+	variant.ZScore = (variant.ConversionRate - control.ConversionRate) / standardError
+	variant.PValue = math.Erfc(math.Abs(variant.ZScore) / math.Sqrt2)
+	variant.Significant = variant.PValue < significanceLevel
+}
+
+// ParseExperimentGoals parses goal definitions of the form
+// "checkout-test=click:/checkout;*=page_view" into experiment IDs and goals
+func ParseExperimentGoals(spec string) (map[string]models.Goal, error) {
+	goals := make(map[string]models.Goal)
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		id, definition, ok := strings.Cut(entry, "=")
+		id, definition = strings.TrimSpace(id), strings.TrimSpace(definition)
+		if !ok || id == "" || definition == "" {
+			return nil, fmt.Errorf("invalid experiment goal %q, expected id=event_type[:path_prefix]", entry)
+		}
+
+		goals[id] = ParseGoal(definition)
+	}
+	return goals, nil
+}
+
+// ParseGoal parses a goal of the form "event_type[:path_prefix]"
+func ParseGoal(definition string) models.Goal {
+	eventType, pathPrefix, _ := strings.Cut(definition, ":")
+	return models.Goal{
+		EventType:  models.EventType(strings.TrimSpace(eventType)),
+		PathPrefix: strings.TrimSpace(pathPrefix),
+	}
+}
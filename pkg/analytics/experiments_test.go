@@ -0,0 +1,138 @@
+package analytics
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+func experimentEvent(userID string, eventType models.EventType, path string, metadata map[string]interface{}) *models.AnalyticsEvent {
+	return &models.AnalyticsEvent{
+		ID:        fmt.Sprintf("%s-%s-%s", userID, eventType, path),
+		Type:      eventType,
+		Timestamp: time.Now(),
+		UserID:    userID,
+		Path:      path,
+		Metadata:  metadata,
+	}
+}
+
+func TestExperimentConversions(t *testing.T) {
+	service := NewService()
+	service.SetExperimentGoal("checkout", models.Goal{EventType: models.Click, PathPrefix: "/checkout"})
+
+	assign := func(userID, variant string) {
+		service.ProcessEvent(experimentEvent(userID, models.PageView, "/", map[string]interface{}{
+			models.MetadataExperimentID: "checkout",
+			models.MetadataVariant:      variant,
+		}))
+	}
+
+	assign("u1", "control")
+	assign("u2", "control")
+	assign("u3", "green")
+	assign("u3", "control") // Reassignment is ignored
+
+	// Clicks outside the goal path and repeated conversions don't count
+	service.ProcessEvent(experimentEvent("u1", models.Click, "/home", nil))
+	service.ProcessEvent(experimentEvent("u3", models.Click, "/checkout/pay", nil))
+	service.ProcessEvent(experimentEvent("u3", models.Click, "/checkout/pay", nil))
+
+	result, ok := service.GetExperimentResult("checkout")
+	if !ok {
+		t.Fatal("expected experiment results")
+	}
+	if result.Control != "control" || len(result.Variants) != 2 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+
+	control, green := result.Variants[0], result.Variants[1]
+	if control.Participants != 2 || control.Conversions != 0 {
+		t.Errorf("control: expected 2 participants and 0 conversions, got %+v", control)
+	}
+	if green.Participants != 1 || green.Conversions != 1 {
+		t.Errorf("green: expected 1 participant and 1 conversion, got %+v", green)
+	}
+}
+
+func TestCompareToControlSignificance(t *testing.T) {
+	control := &models.VariantResult{Participants: 1000, Conversions: 100, ConversionRate: 0.1}
+	variant := &models.VariantResult{Participants: 1000, Conversions: 135, ConversionRate: 0.135}
+
+	compareToControl(variant, control)
+
+	if math.Abs(variant.Lift-0.35) > 1e-9 {
+		t.Errorf("expected lift 0.35, got %f", variant.Lift)
+	}
+	if !variant.Significant || variant.PValue > 0.05 {
+		t.Errorf("expected a significant result, got z=%.2f p=%.4f", variant.ZScore, variant.PValue)
+	}
+
+	small := &models.VariantResult{Participants: 10, Conversions: 2, ConversionRate: 0.2}
+	compareToControl(small, control)
+	if small.Significant {
+		t.Errorf("expected small sample not to be significant, got p=%.4f", small.PValue)
+	}
+}
+
+func TestParseExperimentGoals(t *testing.T) {
+	goals, err := ParseExperimentGoals("checkout=click:/checkout; *=page_view")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if goals["checkout"] != (models.Goal{EventType: models.Click, PathPrefix: "/checkout"}) {
+		t.Errorf("unexpected checkout goal: %+v", goals["checkout"])
+	}
+	if goals[DefaultGoalKey].EventType != models.PageView {
+		t.Errorf("unexpected default goal: %+v", goals[DefaultGoalKey])
+	}
+
+	if _, err := ParseExperimentGoals("missing-definition"); err == nil {
+		t.Error("expected an error for an entry without a goal")
+	}
+}
+
+func TestExperimentsAreBounded(t *testing.T) {
+	service := NewService()
+	assign := func(userID, experimentID, variant string) {
+		service.ProcessEvent(experimentEvent(userID, models.PageView, "/", map[string]interface{}{
+			models.MetadataExperimentID: experimentID,
+			models.MetadataVariant:      variant,
+		}))
+	}
+
+	for i := 0; i < maxExperiments+5; i++ {
+		assign("u1", fmt.Sprintf("exp-%d", i), "control")
+	}
+	for i := 0; i < maxExperimentVariants+5; i++ {
+		assign(fmt.Sprintf("user-%d", i), "exp-0", fmt.Sprintf("variant-%d", i))
+	}
+
+	if results := service.GetExperimentResults(); len(results) != maxExperiments {
+		t.Errorf("expected %d experiments tracked, got %d", maxExperiments, len(results))
+	}
+	result, _ := service.GetExperimentResult("exp-0")
+	if len(result.Variants) != maxExperimentVariants {
+		t.Errorf("expected %d variants tracked, got %d", maxExperimentVariants, len(result.Variants))
+	}
+}
+
+func TestExperimentGoalChangesApplyToRunningExperiments(t *testing.T) {
+	service := NewService()
+	service.ProcessEvent(experimentEvent("u1", models.PageView, "/", map[string]interface{}{
+		models.MetadataExperimentID: "signup",
+		models.MetadataVariant:      "control",
+	}))
+
+	service.SetExperimentGoal("signup", models.Goal{EventType: models.PageView, PathPrefix: "/welcome"})
+	service.ProcessEvent(experimentEvent("u1", models.Click, "/", nil))
+	service.ProcessEvent(experimentEvent("u1", models.PageView, "/welcome", nil))
+
+	result, _ := service.GetExperimentResult("signup")
+	if len(result.Variants) != 1 || result.Variants[0].Conversions != 1 || result.Goal.PathPrefix != "/welcome" {
+		t.Errorf("expected one conversion on the new goal, got %+v", result)
+	}
+}
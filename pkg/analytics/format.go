@@ -0,0 +1,232 @@
+package analytics
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+// Load time units supported in formatted snapshots
+const (
+	LoadTimeMilliseconds = "ms"
+	LoadTimeSeconds      = "s"
+)
+
+// localeSeparators maps a language code to its decimal and thousands separators
+var localeSeparators = map[string][2]string{
+	"en": {".", ","},
+	"de": {",", "."},
+	"es": {",", "."},
+	"it": {",", "."},
+	"nl": {",", "."},
+	"pt": {",", "."},
+	"fr": {",", " "},
+	"ru": {",", " "},
+}
+
+// FormatOptions controls how snapshot numbers are presented to clients
+type FormatOptions struct {
+	Precision    int    // Decimal places for fractional values; negative leaves values unrounded
+	LoadTimeUnit string // "ms" or "s"
+	Locale       string // Language code for display strings; empty disables them
+}
+
+// DefaultFormatOptions returns options that leave the snapshot unchanged
+func DefaultFormatOptions() FormatOptions {
+	return FormatOptions{
+		Precision:    -1,
+		LoadTimeUnit: LoadTimeMilliseconds,
+	}
+}
+
+// Validate checks that the options are supported
+func (o FormatOptions) Validate() error {
+	if o.Precision > 6 {
+		return fmt.Errorf("precision must be at most 6")
+	}
+	if o.LoadTimeUnit != LoadTimeMilliseconds && o.LoadTimeUnit != LoadTimeSeconds {
+		return fmt.Errorf("unsupported load time unit %q", o.LoadTimeUnit)
+	}
+	if o.Locale != "" {
+		if _, ok := localeSeparators[localeLanguage(o.Locale)]; !ok {
+			return fmt.Errorf("unsupported locale %q", o.Locale)
+		}
+	}
+	return nil
+}
+
+// ParseFormatOptions overrides defaults with the precision, load_time_unit and locale query parameters
+func ParseFormatOptions(query url.Values, defaults FormatOptions) (FormatOptions, error) {
+	opts := defaults
+
+	if v := query.Get("precision"); v != "" {
+		precision, err := strconv.Atoi(v)
+		if err != nil {
+			return opts, fmt.Errorf("invalid precision %q", v)
+		}
+		opts.Precision = precision
+	}
+	if v := query.Get("load_time_unit"); v != "" {
+		opts.LoadTimeUnit = v
+	}
+	if v := query.Get("locale"); v != "" {
+		opts.Locale = v
+	}
+
+	return opts, opts.Validate()
+}
+
+// FormatSnapshot returns a copy of the snapshot with rounding, units and display strings applied.
+// The input snapshot is not modified, so it is safe to pass a shared cached snapshot.
+func FormatSnapshot(snapshot *models.MetricsSnapshot, opts FormatOptions) *models.MetricsSnapshot {
+	formatted := *snapshot
+
+	// Round performance metrics. The *_ms fields always stay in milliseconds; seconds
+	// are added alongside them.
+	perf := snapshot.PerformanceMetrics
+	if opts.LoadTimeUnit == LoadTimeSeconds {
+		perf.Seconds = &models.LoadTimesSeconds{
+			Average: round(perf.AverageLoadTime/1000, opts.Precision),
+			Median:  round(perf.MedianLoadTime/1000, opts.Precision),
+			P75:     round(perf.P75LoadTime/1000, opts.Precision),
+			P90:     round(perf.P90LoadTime/1000, opts.Precision),
+			P95:     round(perf.P95LoadTime/1000, opts.Precision),
+			P99:     round(perf.P99LoadTime/1000, opts.Precision),
+		}
+	}
+	for _, loadTime := range []*float64{&perf.AverageLoadTime, &perf.MedianLoadTime, &perf.P75LoadTime, &perf.P90LoadTime, &perf.P95LoadTime, &perf.P99LoadTime} {
+		*loadTime = round(*loadTime, opts.Precision)
+	}
+	formatted.PerformanceMetrics = perf
+
+	formatted.TrafficSources = make([]models.TrafficSource, len(snapshot.TrafficSources))
+	for i, source := range snapshot.TrafficSources {
+		source.Percent = round(source.Percent, opts.Precision)
+		formatted.TrafficSources[i] = source
+	}
+
+	formatted.TrafficChannels = make([]models.DimensionCount, len(snapshot.TrafficChannels))
+	for i, channel := range snapshot.TrafficChannels {
+		channel.Percent = round(channel.Percent, opts.Precision)
+		formatted.TrafficChannels[i] = channel
+	}
+
+	formatted.TopPages = make([]models.PageMetric, len(snapshot.TopPages))
+	for i, page := range snapshot.TopPages {
+		page.AverageTime = round(page.AverageTime, opts.Precision)
+		page.BounceRate = round(page.BounceRate, opts.Precision)
+		formatted.TopPages[i] = page
+	}
+
+	formatted.CampaignStats = make([]models.CampaignMetric, len(snapshot.CampaignStats))
+	for i, campaign := range snapshot.CampaignStats {
+		campaign.ConversionRate = round(campaign.ConversionRate, opts.Precision)
+		formatted.CampaignStats[i] = campaign
+	}
+
+	commerce := snapshot.Commerce
+	commerce.TotalRevenue = round(commerce.TotalRevenue, opts.Precision)
+	commerce.AverageOrderValue = round(commerce.AverageOrderValue, opts.Precision)
+	commerce.ConversionRate = round(commerce.ConversionRate, opts.Precision)
+	commerce.TopProducts = make([]models.ProductMetric, len(snapshot.Commerce.TopProducts))
+	for i, product := range snapshot.Commerce.TopProducts {
+		product.Revenue = round(product.Revenue, opts.Precision)
+		commerce.TopProducts[i] = product
+	}
+	if snapshot.Commerce.OtherCurrencies != nil {
+		commerce.OtherCurrencies = make(map[string]float64, len(snapshot.Commerce.OtherCurrencies))
+		for currency, revenue := range snapshot.Commerce.OtherCurrencies {
+			commerce.OtherCurrencies[currency] = round(revenue, opts.Precision)
+		}
+	}
+	formatted.Commerce = commerce
+
+	errs := snapshot.Errors
+	errs.ErrorsPerMinute = round(errs.ErrorsPerMinute, opts.Precision)
+	errs.ErrorRate = round(errs.ErrorRate, opts.Precision)
+	formatted.Errors = errs
+
+	if opts.Locale != "" {
+		formatted.Display = displayStrings(&formatted, opts)
+	}
+
+	return &formatted
+}
+
+// displayStrings renders key fractional metrics as localized strings
+func displayStrings(snapshot *models.MetricsSnapshot, opts FormatOptions) map[string]string {
+	precision := opts.Precision
+	if precision < 0 {
+		precision = 2
+	}
+
+	perf := snapshot.PerformanceMetrics
+	average, median, p95, p99 := perf.AverageLoadTime, perf.MedianLoadTime, perf.P95LoadTime, perf.P99LoadTime
+	if perf.Seconds != nil {
+		average, median, p95, p99 = perf.Seconds.Average, perf.Seconds.Median, perf.Seconds.P95, perf.Seconds.P99
+	}
+
+	display := map[string]string{
+		"total_events":      formatLocalized(float64(snapshot.TotalEvents), 0, opts.Locale),
+		"unique_users":      formatLocalized(float64(snapshot.UniqueUsers), 0, opts.Locale),
+		"average_load_time": formatLocalized(average, precision, opts.Locale) + " " + opts.LoadTimeUnit,
+		"median_load_time":  formatLocalized(median, precision, opts.Locale) + " " + opts.LoadTimeUnit,
+		"p95_load_time":     formatLocalized(p95, precision, opts.Locale) + " " + opts.LoadTimeUnit,
+		"p99_load_time":     formatLocalized(p99, precision, opts.Locale) + " " + opts.LoadTimeUnit,
+	}
+	for _, source := range snapshot.TrafficSources {
+		display["traffic_sources."+source.Source] = formatLocalized(source.Percent, precision, opts.Locale) + " %"
+	}
+	return display
+}
+
+// formatLocalized formats a number with the locale's decimal and thousands separators
+func formatLocalized(value float64, precision int, locale string) string {
+	separators, ok := localeSeparators[localeLanguage(locale)]
+	if !ok {
+		separators = localeSeparators["en"]
+	}
+
+	text := strconv.FormatFloat(value, 'f', precision, 64)
+	sign := ""
+	if strings.HasPrefix(text, "-") {
+		sign, text = "-", text[1:]
+	}
+	integer, fraction, hasFraction := strings.Cut(text, ".")
+
+	// Group integer digits in thousands
+	var grouped strings.Builder
+	for i, digit := range integer {
+		if i > 0 && (len(integer)-i)%3 == 0 {
+			grouped.WriteString(separators[1])
+		}
+		grouped.WriteRune(digit)
+	}
+
+	if hasFraction {
+		return sign + grouped.String() + separators[0] + fraction
+	}
+	return sign + grouped.String()
+}
+
+// localeLanguage extracts the language code from a locale such as "de-DE" or "pt_BR"
+func localeLanguage(locale string) string {
+	locale = strings.ToLower(locale)
+	if i := strings.IndexAny(locale, "-_"); i >= 0 {
+		locale = locale[:i]
+	}
+	return locale
+}
+
+// round rounds value to the given number of decimal places; negative precision leaves it unchanged
+func round(value float64, precision int) float64 {
+	if precision < 0 {
+		return value
+	}
+	factor := math.Pow(10, float64(precision))
+	return math.Round(value*factor) / factor
+}
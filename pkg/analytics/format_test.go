@@ -0,0 +1,78 @@
+package analytics
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+func TestFormatSnapshot(t *testing.T) {
+	snapshot := &models.MetricsSnapshot{
+		TotalEvents: 1234567,
+		TrafficSources: []models.TrafficSource{
+			{Source: "google.com", Count: 1, Percent: 33.33333},
+		},
+		PerformanceMetrics: models.PerformanceMetrics{
+			AverageLoadTime: 1234.5678,
+			MedianLoadTime:  1000,
+		},
+	}
+
+	formatted := FormatSnapshot(snapshot, FormatOptions{
+		Precision:    1,
+		LoadTimeUnit: LoadTimeSeconds,
+		Locale:       "de-DE",
+	})
+
+	if got := formatted.PerformanceMetrics.Seconds; got == nil || got.Average != 1.2 {
+		t.Errorf("Seconds: got %+v, want an average of 1.2", got)
+	}
+	if got := formatted.PerformanceMetrics.AverageLoadTime; got != 1234.6 {
+		t.Errorf("AverageLoadTime: got %v, want 1234.6 ms", got)
+	}
+	if got := formatted.TrafficSources[0].Percent; got != 33.3 {
+		t.Errorf("Percent: got %v, want 33.3", got)
+	}
+	if got := formatted.Display["total_events"]; got != "1.234.567" {
+		t.Errorf("Display total_events: got %q, want %q", got, "1.234.567")
+	}
+	if got := formatted.Display["average_load_time"]; got != "1,2 s" {
+		t.Errorf("Display average_load_time: got %q, want %q", got, "1,2 s")
+	}
+
+	// The original snapshot must not be modified
+	if snapshot.TrafficSources[0].Percent != 33.33333 {
+		t.Error("FormatSnapshot modified the input snapshot")
+	}
+
+	// Milliseconds and the defaults leave the values as they are
+	formatted = FormatSnapshot(snapshot, DefaultFormatOptions())
+	if formatted.PerformanceMetrics.Seconds != nil || formatted.PerformanceMetrics.AverageLoadTime != 1234.5678 {
+		t.Errorf("expected unrounded milliseconds by default, got %+v", formatted.PerformanceMetrics)
+	}
+}
+
+func TestParseFormatOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		wantErr bool
+	}{
+		{"Defaults", "", false},
+		{"Valid", "precision=1&load_time_unit=s&locale=fr", false},
+		{"InvalidPrecision", "precision=abc", true},
+		{"InvalidUnit", "load_time_unit=minutes", true},
+		{"InvalidLocale", "locale=xx", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, _ := url.ParseQuery(tt.query)
+			_, err := ParseFormatOptions(query, DefaultFormatOptions())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseFormatOptions(%q) error = %v, wantErr %v", tt.query, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package analytics
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+// Bounds of funnel queries
+const (
+	minFunnelSteps      = 2
+	maxFunnelSteps      = 10
+	defaultFunnelWindow = 24 * time.Hour
+	maxFunnelWindow     = 30 * 24 * time.Hour
+)
+
+// FunnelQuery selects the steps and conversion window of a funnel
+type FunnelQuery struct {
+	Steps  []models.Goal // Ordered steps, in the goal format event_type[:path_prefix]
+	From   time.Time
+	To     time.Time
+	Window time.Duration // Time allowed from the first step to the last
+}
+
+// ParseFunnelQuery reads the steps and window query parameters; steps is a
+// comma-separated list of goals and window a number of seconds
+func ParseFunnelQuery(values url.Values, from, to time.Time) (FunnelQuery, error) {
+	q := FunnelQuery{From: from, To: to, Window: defaultFunnelWindow}
+	if !from.Before(to) {
+		return q, fmt.Errorf("from must be before to")
+	}
+
+	for _, definition := range strings.Split(values.Get("steps"), ",") {
+		if definition = strings.TrimSpace(definition); definition != "" {
+			q.Steps = append(q.Steps, ParseGoal(definition))
+		}
+	}
+	if len(q.Steps) < minFunnelSteps || len(q.Steps) > maxFunnelSteps {
+		return q, fmt.Errorf("steps must list %d to %d goals of the form event_type[:path_prefix]", minFunnelSteps, maxFunnelSteps)
+	}
+
+	if v := values.Get("window"); v != "" {
+		seconds, err := strconv.Atoi(v)
+		if err != nil || seconds < 1 || time.Duration(seconds)*time.Second > maxFunnelWindow {
+			return q, fmt.Errorf("window must be between 1 and %d seconds", int(maxFunnelWindow.Seconds()))
+		}
+		q.Window = time.Duration(seconds) * time.Second
+	}
+	return q, nil
+}
+
+// GetFunnel computes the funnel over the recent events buffer, so it only covers the
+// latest events; the ClickHouse history backend answers funnels over archived events
+func (s *Service) GetFunnel(q FunnelQuery) models.Funnel {
+	s.analytics.Mu.RLock()
+	byVisitor := make(map[string][]models.AnalyticsEvent)
+	for _, event := range s.analytics.Events {
+		if event.Timestamp.Before(q.From) || !event.Timestamp.Before(q.To) {
+			continue
+		}
+		if visitor := funnelVisitor(&event); visitor != "" {
+			byVisitor[visitor] = append(byVisitor[visitor], event)
+		}
+	}
+	s.analytics.Mu.RUnlock()
+
+	reached := make([]int64, len(q.Steps))
+	for _, events := range byVisitor {
+		sort.SliceStable(events, func(i, j int) bool { return events[i].Timestamp.Before(events[j].Timestamp) })
+		for level := funnelLevel(events, q.Steps, q.Window); level > 0; level-- {
+			reached[level-1]++
+		}
+	}
+	return NewFunnel(q, reached)
+}
+
+// NewFunnel builds the funnel result from the visitors reaching each step
+func NewFunnel(q FunnelQuery, reached []int64) models.Funnel {
+	funnel := models.Funnel{
+		From:          q.From,
+		To:            q.To,
+		WindowSeconds: q.Window.Seconds(),
+		Steps:         make([]models.FunnelStep, len(q.Steps)),
+	}
+	for i, goal := range q.Steps {
+		step := models.FunnelStep{Goal: goal}
+		if i < len(reached) {
+			step.Visitors = reached[i]
+		}
+		if len(reached) > 0 && reached[0] > 0 {
+			step.ConversionRate = float64(step.Visitors) / float64(reached[0])
+		}
+		funnel.Steps[i] = step
+	}
+	return funnel
+}
+
+// funnelVisitor identifies who an event counts for in a funnel: the user, or the
+// session for anonymous events
+func funnelVisitor(event *models.AnalyticsEvent) string {
+	if event.UserID != "" {
+		return "user:" + event.UserID
+	}
+	if event.SessionID != "" {
+		return "session:" + event.SessionID
+	}
+	return ""
+}
+
+// funnelLevel returns the number of steps a visitor completed in order, with every step
+// within the window of the first, given their events in time order
+func funnelLevel(events []models.AnalyticsEvent, steps []models.Goal, window time.Duration) int {
+	// starts[k] is the latest first-step time of a chain that reached step k+1; later
+	// starts leave the most time for the remaining steps
+	starts := make([]time.Time, len(steps))
+	reached := make([]bool, len(steps))
+	level := 0
+	for i := range events {
+		event := &events[i]
+		// Advance from the last step down, so one event completes at most one step
+		for k := len(steps) - 1; k > 0; k-- {
+			if !reached[k-1] || !steps[k].Matches(event) || event.Timestamp.Sub(starts[k-1]) > window {
+				continue
+			}
+			if !reached[k] || starts[k-1].After(starts[k]) {
+				starts[k] = starts[k-1]
+			}
+			reached[k] = true
+			level = max(level, k+1)
+		}
+		if steps[0].Matches(event) {
+			starts[0] = event.Timestamp
+			reached[0] = true
+			level = max(level, 1)
+		}
+	}
+	return level
+}
@@ -0,0 +1,113 @@
+package analytics
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/store"
+)
+
+func TestFunnelFromRecentEvents(t *testing.T) {
+	service := NewService()
+	start := time.Now().Add(-time.Hour)
+	events := []struct {
+		user      string
+		eventType models.EventType
+		path      string
+		after     time.Duration
+	}{
+		{"u1", models.PageView, "/pricing", 0},
+		{"u1", models.Click, "/signup", time.Minute},
+		{"u1", models.Purchase, "/checkout", 2 * time.Minute},
+		{"u2", models.PageView, "/pricing/teams", 0},
+		{"u2", models.Click, "/signup", 20 * time.Minute}, // Outside the window
+		{"u3", models.Click, "/signup", 0},                // Second step before the first
+		{"u3", models.PageView, "/pricing", time.Minute},
+	}
+	for _, e := range events {
+		service.ProcessEvent(&models.AnalyticsEvent{Type: e.eventType, Timestamp: start.Add(e.after), UserID: e.user, Path: e.path})
+	}
+
+	q, err := ParseFunnelQuery(url.Values{"steps": {"page_view:/pricing,click:/signup,purchase"}, "window": {"600"}}, start, time.Now())
+	if err != nil {
+		t.Fatalf("ParseFunnelQuery failed: %v", err)
+	}
+	funnel := service.GetFunnel(q)
+	want := []int64{3, 1, 1}
+	for i, step := range funnel.Steps {
+		if step.Visitors != want[i] {
+			t.Errorf("Step %d: expected %d visitors, got %d", i+1, want[i], step.Visitors)
+		}
+	}
+	if rate := funnel.Steps[1].ConversionRate; rate < 0.33 || rate > 0.34 {
+		t.Errorf("Expected a conversion rate of 1/3, got %f", rate)
+	}
+}
+
+func TestParseFunnelQueryValidation(t *testing.T) {
+	now := time.Now()
+	cases := []url.Values{
+		{"steps": {"page_view"}},
+		{"steps": {"page_view,click"}, "window": {"0"}},
+		{"steps": {"page_view,click"}, "window": {"abc"}},
+	}
+	for _, values := range cases {
+		if _, err := ParseFunnelQuery(values, now.Add(-time.Hour), now); err == nil {
+			t.Errorf("Expected %v to be rejected", values)
+		}
+	}
+	if _, err := ParseFunnelQuery(url.Values{"steps": {"page_view,click"}}, now, now); err == nil {
+		t.Error("Expected an empty range to be rejected")
+	}
+}
+
+// failingWarehouse is a warehouse that is unavailable
+type failingWarehouse struct{}
+
+func (failingWarehouse) QueryRollups(context.Context, models.Granularity, time.Time, time.Time) ([]models.Rollup, error) {
+	return nil, errors.New("connection refused")
+}
+
+func (failingWarehouse) TopPages(context.Context, time.Time, time.Time, int) ([]models.PageMetric, error) {
+	return nil, errors.New("connection refused")
+}
+
+func (failingWarehouse) Funnel(context.Context, []models.Goal, time.Time, time.Time, time.Duration) ([]int64, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestHistoryFallsBackWhenWarehouseFails(t *testing.T) {
+	ctx := context.Background()
+	service := NewService()
+	history := NewHistory(service, store.NewMemoryStore())
+	history.SetWarehouse(failingWarehouse{})
+
+	now := time.Now()
+	service.ProcessEvent(&models.AnalyticsEvent{Type: models.PageView, Timestamp: now, UserID: "u1", URL: "https://example.com/", Path: "/"})
+
+	rollups, source, err := history.Rollups(ctx, now.Add(-time.Hour), now.Add(time.Hour), models.GranularityHour)
+	if err != nil || source != models.HistorySourceMemory {
+		t.Fatalf("Expected in-memory rollups, got source %q (err %v)", source, err)
+	}
+	var events int64
+	for _, rollup := range rollups {
+		events += rollup.Events
+	}
+	if events != 1 {
+		t.Errorf("Expected the live event in the rollups, got %d events", events)
+	}
+
+	pages, source, err := history.TopPages(ctx, now.Add(-time.Hour), now.Add(time.Hour), 10)
+	if err != nil || source != models.HistorySourceMemory || len(pages) != 1 {
+		t.Errorf("Expected in-memory pages, got %+v from %q (err %v)", pages, source, err)
+	}
+
+	// Invalid ranges are rejected before the warehouse is asked
+	if _, _, err := history.Rollups(ctx, now, now.Add(-time.Hour), models.GranularityHour); err == nil {
+		t.Error("Expected an invalid range to be rejected")
+	}
+}
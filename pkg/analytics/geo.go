@@ -0,0 +1,96 @@
+package analytics
+
+import (
+	"sort"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+const (
+	// Countries and regions listed in geo stats
+	maxGeoCountries = 20
+	maxGeoRegions   = 20
+)
+
+// processGeo counts a page view towards its country and region, by the hour of the
+// event for the per-country time series
+func (s *Service) processGeo(event *models.AnalyticsEvent, weight int64) {
+	country, region := models.GeoFromEvent(event)
+	if country == "" {
+		s.analytics.UnknownGeoViews += weight
+		return
+	}
+
+	s.analytics.CountryViews[country] += weight
+	if region != "" {
+		s.analytics.RegionViews[country+"-"+region] += weight
+	}
+
+	hour := event.Timestamp.Truncate(time.Hour).Unix()
+	countries := s.analytics.HourlyCountries[hour]
+	if countries == nil {
+		countries = make(map[string]int64)
+		s.analytics.HourlyCountries[hour] = countries
+	}
+	countries[country] += weight
+}
+
+// GetGeoStats returns page views by country and region
+func (s *Service) GetGeoStats() models.GeoStats {
+	s.analytics.Mu.RLock()
+	defer s.analytics.Mu.RUnlock()
+	return s.getGeoStats(time.Now())
+}
+
+// getGeoStats returns the top countries and regions, and the top countries' page views
+// for each of the 24 hours up to now. The caller must hold the analytics lock.
+func (s *Service) getGeoStats(now time.Time) models.GeoStats {
+	stats := models.GeoStats{
+		Countries:        topGeoCounts(s.analytics.CountryViews, maxGeoCountries),
+		Regions:          topGeoCounts(s.analytics.RegionViews, maxGeoRegions),
+		Hourly:           make([]models.HourlyGeoMetric, 0, 24),
+		UnknownPageViews: s.analytics.UnknownGeoViews,
+	}
+
+	for i := 23; i >= 0; i-- {
+		hour := now.Add(-time.Duration(i) * time.Hour).Truncate(time.Hour)
+		counts := s.analytics.HourlyCountries[hour.Unix()]
+
+		metric := models.HourlyGeoMetric{Hour: hour, Countries: make(map[string]int64)}
+		for _, country := range stats.Countries {
+			if count := counts[country.Name]; count > 0 {
+				metric.Countries[country.Name] = count
+			}
+		}
+		stats.Hourly = append(stats.Hourly, metric)
+	}
+	return stats
+}
+
+// topGeoCounts returns the limit largest counts with their share of all counted page views
+func topGeoCounts(counts map[string]int64, limit int) []models.DimensionCount {
+	var total int64
+	for _, count := range counts {
+		total += count
+	}
+
+	result := make([]models.DimensionCount, 0, len(counts))
+	for name, count := range counts {
+		result = append(result, models.DimensionCount{
+			Name:    name,
+			Count:   count,
+			Percent: float64(count) / float64(total) * 100,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Name < result[j].Name
+	})
+	if len(result) > limit {
+		result = result[:limit]
+	}
+	return result
+}
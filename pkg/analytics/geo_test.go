@@ -0,0 +1,57 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+func TestGeoStats(t *testing.T) {
+	service := NewService()
+	now := time.Now()
+	views := []struct {
+		country, region string
+		age             time.Duration
+	}{
+		{"us", "CA", 0}, {"US", "US-NY", 0}, {"US", "", 2 * time.Hour},
+		{"de", "BE", 0},
+		{"XX", "", 0}, {"", "", 0},
+	}
+	for _, view := range views {
+		metadata := map[string]interface{}{}
+		if view.country != "" {
+			metadata["country"] = view.country
+		}
+		if view.region != "" {
+			metadata["region"] = view.region
+		}
+		service.ProcessEvent(&models.AnalyticsEvent{Type: models.PageView, Timestamp: now.Add(-view.age), UserID: "u1", Metadata: metadata})
+	}
+	// Only page views count towards locations
+	service.ProcessEvent(&models.AnalyticsEvent{Type: models.Click, Timestamp: now, Metadata: map[string]interface{}{"country": "FR"}})
+
+	geo := service.GetSnapshot().Geo
+	checkPages(t, "country", geo.Countries, []models.DimensionCount{{Name: "US", Count: 3}, {Name: "DE", Count: 1}})
+	checkPages(t, "region", geo.Regions, []models.DimensionCount{{Name: "DE-BE", Count: 1}, {Name: "US-CA", Count: 1}, {Name: "US-NY", Count: 1}})
+	if geo.Countries[0].Percent != 75 {
+		t.Errorf("expected US to have 75%% of located page views, got %.1f%%", geo.Countries[0].Percent)
+	}
+	if geo.UnknownPageViews != 2 {
+		t.Errorf("expected 2 page views without a country, got %d", geo.UnknownPageViews)
+	}
+
+	if len(geo.Hourly) != 24 {
+		t.Fatalf("expected 24 hours, got %d", len(geo.Hourly))
+	}
+	current, earlier := geo.Hourly[23].Countries, geo.Hourly[21].Countries
+	if current["US"] != 2 || current["DE"] != 1 || earlier["US"] != 1 || len(earlier) != 1 {
+		t.Errorf("expected US and DE this hour and US two hours ago, got %v and %v", current, earlier)
+	}
+
+	// Hourly counts expire with the other hourly data
+	service.Cleanup(now.Add(72 * time.Hour))
+	if geo := service.GetGeoStats(); len(geo.Countries) != 2 || len(service.analytics.HourlyCountries) != 0 {
+		t.Errorf("expected totals kept and hourly counts expired, got %+v", geo)
+	}
+}
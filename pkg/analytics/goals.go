@@ -0,0 +1,384 @@
+package analytics
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/errs"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/store"
+)
+
+// maxGoals is the most conversion goals tracked at once
+const maxGoals = 50
+
+var (
+	// ErrGoalNotFound is returned when no goal has the given name
+	ErrGoalNotFound = errs.Errorf(errs.ErrNotFound, "goal not found")
+
+	// ErrGoalExists is returned when creating a goal whose name is taken
+	ErrGoalExists = errs.Errorf(errs.ErrConflict, "goal already exists")
+)
+
+// goalNamePattern restricts goal names to URL-safe identifiers
+var goalNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// goalState is a goal with its completions so far
+type goalState struct {
+	goal        models.ConversionGoal
+	completions int64
+	sessions    int64
+	value       float64
+}
+
+// goalSubscribers holds the handlers notified of goal completions
+type goalSubscribers struct {
+	handlers map[int]func(models.GoalCompletion)
+	next     int
+}
+
+// ValidateGoal checks that a goal has a valid name and at least one condition
+func ValidateGoal(goal models.ConversionGoal) error {
+	if !goalNamePattern.MatchString(goal.Name) {
+		return errs.Errorf(errs.ErrInvalidRequest, "goal name must be 1-64 letters, digits, '-' or '_'")
+	}
+	if goal.EventType == "" && goal.Path == "" && len(goal.Metadata) == 0 {
+		return errs.Errorf(errs.ErrInvalidRequest, "goal %q needs an event_type, path or metadata condition", goal.Name)
+	}
+	if goal.EventType != "" && !goal.EventType.Valid() {
+		return errs.Errorf(errs.ErrInvalidRequest, "goal %q: invalid event type %q", goal.Name, goal.EventType)
+	}
+	if goal.Path != "" && !strings.HasPrefix(goal.Path, "/") {
+		return errs.Errorf(errs.ErrInvalidRequest, "goal %q: path must start with /", goal.Name)
+	}
+	if goal.Value < 0 || math.IsNaN(goal.Value) || math.IsInf(goal.Value, 0) {
+		return errs.Errorf(errs.ErrInvalidRequest, "goal %q: value must be a non-negative number", goal.Name)
+	}
+	return nil
+}
+
+// ParseGoals parses goal definitions of the form
+// "signup=event_type:signup;pricing=path:/pricing;pro=event_type:purchase,metadata.plan:pro,value_field:amount".
+// Conditions are event_type, path and metadata.<field>; value sets a fixed value per
+// completion and value_field the metadata field holding it.
+func ParseGoals(spec string) ([]models.ConversionGoal, error) {
+	var goals []models.ConversionGoal
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		name, definition, ok := strings.Cut(entry, "=")
+		if !ok || strings.TrimSpace(definition) == "" {
+			return nil, errs.Errorf(errs.ErrInvalidRequest, "invalid goal %q, expected name=condition[,condition...]", entry)
+		}
+		goal := models.ConversionGoal{Name: strings.TrimSpace(name)}
+		for _, part := range strings.Split(definition, ",") {
+			key, value, ok := strings.Cut(strings.TrimSpace(part), ":")
+			key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+			if !ok || value == "" {
+				return nil, errs.Errorf(errs.ErrInvalidRequest, "invalid goal condition %q in %q, expected key:value", part, entry)
+			}
+
+			switch {
+			case key == "event_type":
+				goal.EventType = models.EventType(value)
+			case key == "path":
+				goal.Path = value
+			case key == "value":
+				amount, err := strconv.ParseFloat(value, 64)
+				if err != nil {
+					return nil, errs.Errorf(errs.ErrInvalidRequest, "invalid value %q of goal %q", value, goal.Name)
+				}
+				goal.Value = amount
+			case key == "value_field":
+				goal.ValueField = value
+			case strings.HasPrefix(key, "metadata.") && len(key) > len("metadata."):
+				if goal.Metadata == nil {
+					goal.Metadata = make(map[string]string)
+				}
+				goal.Metadata[strings.TrimPrefix(key, "metadata.")] = value
+			default:
+				return nil, errs.Errorf(errs.ErrInvalidRequest, "unknown goal condition %q in %q", key, entry)
+			}
+		}
+		if err := ValidateGoal(goal); err != nil {
+			return nil, err
+		}
+		goals = append(goals, goal)
+	}
+	return goals, nil
+}
+
+// LoadGoals replaces the goals with those saved in the store, or with defaults when
+// none have been saved yet
+func (s *Service) LoadGoals(ctx context.Context, st store.GoalStore, defaults []models.ConversionGoal) error {
+	goals, ok, err := st.LoadGoals(ctx)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		goals = defaults
+	}
+	return s.SetGoals(goals)
+}
+
+// SetGoals validates and replaces all goals. Goals that keep their name keep their
+// completions; the completions of removed goals are discarded.
+func (s *Service) SetGoals(goals []models.ConversionGoal) error {
+	if len(goals) > maxGoals {
+		return errs.Errorf(errs.ErrInvalidRequest, "at most %d goals can be tracked", maxGoals)
+	}
+	names := make(map[string]bool, len(goals))
+	for _, goal := range goals {
+		if err := ValidateGoal(goal); err != nil {
+			return err
+		}
+		if names[goal.Name] {
+			return errs.Errorf(errs.ErrInvalidRequest, "duplicate goal %q", goal.Name)
+		}
+		names[goal.Name] = true
+	}
+
+	s.analytics.Mu.Lock()
+	defer s.analytics.Mu.Unlock()
+
+	states := make([]*goalState, len(goals))
+	for i, goal := range goals {
+		if j := s.goalIndex(goal.Name); j >= 0 {
+			states[i] = s.goals[j]
+			states[i].goal = goal
+		} else {
+			states[i] = &goalState{goal: goal}
+		}
+	}
+	s.goals = states
+	return nil
+}
+
+// Goals returns the goals in creation order
+func (s *Service) Goals() []models.ConversionGoal {
+	s.analytics.Mu.RLock()
+	defer s.analytics.Mu.RUnlock()
+
+	goals := make([]models.ConversionGoal, len(s.goals))
+	for i, state := range s.goals {
+		goals[i] = state.goal
+	}
+	return goals
+}
+
+// Goal returns the goal with the given name
+func (s *Service) Goal(name string) (models.ConversionGoal, bool) {
+	s.analytics.Mu.RLock()
+	defer s.analytics.Mu.RUnlock()
+
+	if i := s.goalIndex(name); i >= 0 {
+		return s.goals[i].goal, true
+	}
+	return models.ConversionGoal{}, false
+}
+
+// CreateGoal validates and adds a goal
+func (s *Service) CreateGoal(goal models.ConversionGoal) error {
+	if err := ValidateGoal(goal); err != nil {
+		return err
+	}
+
+	s.analytics.Mu.Lock()
+	defer s.analytics.Mu.Unlock()
+
+	if s.goalIndex(goal.Name) >= 0 {
+		return ErrGoalExists
+	}
+	if len(s.goals) >= maxGoals {
+		return errs.Errorf(errs.ErrInvalidRequest, "at most %d goals can be tracked", maxGoals)
+	}
+	s.goals = append(s.goals, &goalState{goal: goal})
+	return nil
+}
+
+// UpdateGoal replaces the named goal's conditions and value, keeping its completions
+func (s *Service) UpdateGoal(name string, goal models.ConversionGoal) error {
+	if goal.Name != name {
+		return errs.Errorf(errs.ErrInvalidRequest, "goal name cannot be changed")
+	}
+	if err := ValidateGoal(goal); err != nil {
+		return err
+	}
+
+	s.analytics.Mu.Lock()
+	defer s.analytics.Mu.Unlock()
+
+	i := s.goalIndex(name)
+	if i < 0 {
+		return ErrGoalNotFound
+	}
+	s.goals[i].goal = goal
+	return nil
+}
+
+// RemoveGoal deletes the named goal and its completions
+func (s *Service) RemoveGoal(name string) error {
+	s.analytics.Mu.Lock()
+	defer s.analytics.Mu.Unlock()
+
+	i := s.goalIndex(name)
+	if i < 0 {
+		return ErrGoalNotFound
+	}
+	s.goals = append(s.goals[:i:i], s.goals[i+1:]...)
+	return nil
+}
+
+// goalIndex returns the position of the named goal, or -1. The caller must hold the
+// analytics lock.
+func (s *Service) goalIndex(name string) int {
+	for i, state := range s.goals {
+		if state.goal.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// processGoals counts the goals the event completes and returns the completions. A goal
+// completes once per session. The caller must hold the analytics lock.
+func (s *Service) processGoals(event *models.AnalyticsEvent) []models.GoalCompletion {
+	var completions []models.GoalCompletion
+	session := s.sessions.sessions[event.SessionID]
+	for _, state := range s.goals {
+		if !goalMatches(state.goal, event) {
+			continue
+		}
+		if session != nil {
+			if session.goals[state.goal.Name] {
+				continue
+			}
+			if session.goals == nil {
+				session.goals = make(map[string]bool)
+			}
+			session.goals[state.goal.Name] = true
+			state.sessions++
+		}
+
+		value := goalValue(state.goal, event)
+		state.completions++
+		state.value += value
+		completions = append(completions, models.GoalCompletion{
+			Goal:      state.goal.Name,
+			Timestamp: event.Timestamp,
+			UserID:    event.UserID,
+			SessionID: event.SessionID,
+			Path:      pagePath(event),
+			Value:     value,
+		})
+	}
+	return completions
+}
+
+// goalMatches reports whether the event meets every condition of the goal
+func goalMatches(goal models.ConversionGoal, event *models.AnalyticsEvent) bool {
+	if goal.EventType != "" && event.Type != goal.EventType {
+		return false
+	}
+	if goal.Path != "" {
+		path := pagePath(event)
+		if prefix, ok := strings.CutSuffix(goal.Path, "*"); ok {
+			if !strings.HasPrefix(path, prefix) {
+				return false
+			}
+		} else if path != goal.Path {
+			return false
+		}
+	}
+	for field, want := range goal.Metadata {
+		value, ok := event.Metadata[field]
+		if !ok || fmt.Sprint(value) != want {
+			return false
+		}
+	}
+	return true
+}
+
+// goalValue returns the value of a completion: the goal's value field when the event
+// has it as a number, otherwise the goal's fixed value
+func goalValue(goal models.ConversionGoal, event *models.AnalyticsEvent) float64 {
+	if goal.ValueField != "" {
+		if value, ok := event.Metadata[goal.ValueField].(float64); ok && value >= 0 {
+			return value
+		}
+	}
+	return goal.Value
+}
+
+// GetGoalMetrics returns the completions of each goal
+func (s *Service) GetGoalMetrics() []models.GoalMetric {
+	s.analytics.Mu.RLock()
+	defer s.analytics.Mu.RUnlock()
+	return s.getGoalMetrics()
+}
+
+// getGoalMetrics returns the completions of each goal, with conversion rates against all
+// sessions seen. The caller must hold the analytics lock.
+func (s *Service) getGoalMetrics() []models.GoalMetric {
+	metrics := make([]models.GoalMetric, len(s.goals))
+	for i, state := range s.goals {
+		metrics[i] = models.GoalMetric{
+			Name:        state.goal.Name,
+			Completions: state.completions,
+			Sessions:    state.sessions,
+			Value:       state.value,
+		}
+		if s.sessions.started > 0 {
+			metrics[i].ConversionRate = float64(state.sessions) / float64(s.sessions.started) * 100
+		}
+	}
+	return metrics
+}
+
+// SubscribeGoals registers a handler called with every goal completion as events are
+// processed, and returns a function that removes it. Handlers are called from the
+// processing goroutine, so they should hand slow work off rather than block.
+func (s *Service) SubscribeGoals(handler func(models.GoalCompletion)) func() {
+	s.goalSubsMu.Lock()
+	defer s.goalSubsMu.Unlock()
+
+	if s.goalSubs.handlers == nil {
+		s.goalSubs.handlers = make(map[int]func(models.GoalCompletion))
+	}
+	id := s.goalSubs.next
+	s.goalSubs.next++
+	s.goalSubs.handlers[id] = handler
+
+	return func() {
+		s.goalSubsMu.Lock()
+		defer s.goalSubsMu.Unlock()
+		delete(s.goalSubs.handlers, id)
+	}
+}
+
+// notifyGoals passes completions to the subscribed handlers
+func (s *Service) notifyGoals(completions []models.GoalCompletion) {
+	if len(completions) == 0 {
+		return
+	}
+
+	s.goalSubsMu.Lock()
+	handlers := make([]func(models.GoalCompletion), 0, len(s.goalSubs.handlers))
+	for _, handler := range s.goalSubs.handlers {
+		handlers = append(handlers, handler)
+	}
+	s.goalSubsMu.Unlock()
+
+	for _, completion := range completions {
+		for _, handler := range handlers {
+			handler(completion)
+		}
+	}
+}
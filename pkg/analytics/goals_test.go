@@ -0,0 +1,90 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+func TestParseGoals(t *testing.T) {
+	goals, err := ParseGoals("signup=event_type:signup; docs=path:/docs/*;pro=event_type:purchase,metadata.plan:pro,value_field:amount,value:10")
+	if err != nil {
+		t.Fatalf("ParseGoals failed: %v", err)
+	}
+	if len(goals) != 3 {
+		t.Fatalf("expected 3 goals, got %+v", goals)
+	}
+	pro := goals[2]
+	if pro.Name != "pro" || pro.EventType != models.Purchase || pro.Metadata["plan"] != "pro" || pro.ValueField != "amount" || pro.Value != 10 {
+		t.Errorf("unexpected goal %+v", pro)
+	}
+
+	for _, spec := range []string{"signup", "signup=colour:red", "bad name=path:/x", "x=path:pricing", "x=value:5", "x=event_type:Not Valid"} {
+		if _, err := ParseGoals(spec); err == nil {
+			t.Errorf("expected %q to be rejected", spec)
+		}
+	}
+}
+
+func TestGoalCompletions(t *testing.T) {
+	service := NewService()
+	err := service.SetGoals([]models.ConversionGoal{
+		{Name: "docs", Path: "/docs/*"},
+		{Name: "pro", EventType: models.Purchase, Metadata: map[string]string{"plan": "pro"}, ValueField: "amount", Value: 5},
+	})
+	if err != nil {
+		t.Fatalf("SetGoals failed: %v", err)
+	}
+
+	var completed []models.GoalCompletion
+	unsubscribe := service.SubscribeGoals(func(completion models.GoalCompletion) {
+		completed = append(completed, completion)
+	})
+	defer unsubscribe()
+
+	now := time.Now()
+	events := []*models.AnalyticsEvent{
+		{Type: models.PageView, SessionID: "s1", Path: "/docs/start"},
+		{Type: models.PageView, SessionID: "s1", Path: "/docs/api"}, // Same session, not counted again
+		{Type: models.PageView, SessionID: "s2", Path: "/pricing"},
+		{Type: models.Purchase, SessionID: "s2", Metadata: map[string]interface{}{"plan": "pro", "amount": float64(49)}},
+		{Type: models.Purchase, SessionID: "s3", Metadata: map[string]interface{}{"plan": "basic", "amount": float64(9)}},
+		{Type: models.Purchase, SessionID: "s4", Metadata: map[string]interface{}{"plan": "pro"}},
+	}
+	for _, event := range events {
+		event.Timestamp = now
+		service.ProcessEvent(event)
+	}
+
+	metrics := service.GetGoalMetrics()
+	if len(metrics) != 2 {
+		t.Fatalf("expected 2 goals, got %+v", metrics)
+	}
+	if docs := metrics[0]; docs.Completions != 1 || docs.Sessions != 1 || docs.ConversionRate != 25 {
+		t.Errorf("unexpected docs goal %+v", docs)
+	}
+	if pro := metrics[1]; pro.Completions != 2 || pro.Sessions != 2 || pro.ConversionRate != 50 || pro.Value != 54 {
+		t.Errorf("unexpected pro goal %+v", pro)
+	}
+	if len(completed) != 3 || completed[0].Goal != "docs" || completed[0].Path != "/docs/start" {
+		t.Errorf("expected 3 broadcast completions, got %+v", completed)
+	}
+	if snapshot := service.GetSnapshot(); len(snapshot.Goals) != 2 {
+		t.Errorf("expected goals in the snapshot, got %+v", snapshot.Goals)
+	}
+
+	// Keeping a goal's name keeps its completions
+	if err := service.UpdateGoal("pro", models.ConversionGoal{Name: "pro", EventType: models.Purchase}); err != nil {
+		t.Fatalf("UpdateGoal failed: %v", err)
+	}
+	if err := service.RemoveGoal("docs"); err != nil {
+		t.Fatalf("RemoveGoal failed: %v", err)
+	}
+	if metrics := service.GetGoalMetrics(); len(metrics) != 1 || metrics[0].Completions != 2 {
+		t.Errorf("expected the updated goal to keep its completions, got %+v", metrics)
+	}
+	if err := service.RemoveGoal("docs"); err != ErrGoalNotFound {
+		t.Errorf("expected ErrGoalNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,146 @@
+package analytics
+
+import (
+	"sort"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+// Heatmap defaults, used unless SetHeatmapOptions is called
+const (
+	DefaultHeatmapGridSize = 20
+	DefaultHeatmapMaxPages = 1000
+)
+
+// heatmap holds the click grid of one page
+type heatmap struct {
+	path    string
+	cells   []int64 // Row-major, gridSize x gridSize
+	clicks  int64
+	updated time.Time
+}
+
+// heatmapTracker aggregates click positions per page URL. Pages beyond maxPages are
+// not tracked, which bounds memory when URLs carry unique query strings.
+type heatmapTracker struct {
+	gridSize int
+	maxPages int
+	pages    map[string]*heatmap
+}
+
+func newHeatmapTracker(gridSize, maxPages int) *heatmapTracker {
+	return &heatmapTracker{
+		gridSize: gridSize,
+		maxPages: maxPages,
+		pages:    make(map[string]*heatmap),
+	}
+}
+
+// SetHeatmapOptions sets the grid size and the number of pages tracked. Changing the
+// grid size discards existing heatmaps, since their cells no longer line up.
+func (s *Service) SetHeatmapOptions(gridSize, maxPages int) {
+	if gridSize <= 0 {
+		gridSize = DefaultHeatmapGridSize
+	}
+	if maxPages <= 0 {
+		maxPages = DefaultHeatmapMaxPages
+	}
+
+	s.analytics.Mu.Lock()
+	defer s.analytics.Mu.Unlock()
+	if gridSize != s.heatmaps.gridSize {
+		s.heatmaps = newHeatmapTracker(gridSize, maxPages)
+		return
+	}
+	s.heatmaps.maxPages = maxPages
+}
+
+// processClick adds the click's position to the heatmap of pageURL, the event's
+// normalized URL. Clicks without a position and screen size are ignored. The caller must
+// hold the analytics lock.
+func (s *Service) processClick(event *models.AnalyticsEvent, pageURL string, weight int64) {
+	x, y, ok := models.ClickPosition(event)
+	if !ok || pageURL == "" {
+		return
+	}
+
+	h := s.heatmaps
+	page := h.pages[pageURL]
+	if page == nil {
+		if len(h.pages) >= h.maxPages {
+			return
+		}
+		page = &heatmap{path: event.Path, cells: make([]int64, h.gridSize*h.gridSize)}
+		h.pages[pageURL] = page
+	}
+
+	row, col := int(y*float64(h.gridSize)), int(x*float64(h.gridSize))
+	page.cells[row*h.gridSize+col] += weight
+	page.clicks += weight
+	page.updated = time.Now()
+}
+
+// GetHeatmap returns the click heatmap of a page URL, normalized as counted pages are
+func (s *Service) GetHeatmap(url string) (models.Heatmap, bool) {
+	s.analytics.Mu.RLock()
+	defer s.analytics.Mu.RUnlock()
+
+	url, _ = s.urlRules.NormalizeURL(url)
+	page, ok := s.heatmaps.pages[url]
+	if !ok {
+		return models.Heatmap{}, false
+	}
+	return s.heatmaps.export(url, page), true
+}
+
+// GetHeatmapPages lists the pages with heatmaps, most clicked first
+func (s *Service) GetHeatmapPages() []models.HeatmapSummary {
+	s.analytics.Mu.RLock()
+	summaries := make([]models.HeatmapSummary, 0, len(s.heatmaps.pages))
+	for url, page := range s.heatmaps.pages {
+		summaries = append(summaries, models.HeatmapSummary{URL: url, Path: page.path, Clicks: page.clicks})
+	}
+	s.analytics.Mu.RUnlock()
+
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].Clicks != summaries[j].Clicks {
+			return summaries[i].Clicks > summaries[j].Clicks
+		}
+		return summaries[i].URL < summaries[j].URL
+	})
+	return summaries
+}
+
+// GetHeatmapsUpdatedSince returns the heatmaps of pages clicked after since
+func (s *Service) GetHeatmapsUpdatedSince(since time.Time) []models.Heatmap {
+	s.analytics.Mu.RLock()
+	defer s.analytics.Mu.RUnlock()
+
+	var heatmaps []models.Heatmap
+	for url, page := range s.heatmaps.pages {
+		if page.updated.After(since) {
+			heatmaps = append(heatmaps, s.heatmaps.export(url, page))
+		}
+	}
+	return heatmaps
+}
+
+// export copies a page's heatmap into its API form
+func (h *heatmapTracker) export(url string, page *heatmap) models.Heatmap {
+	result := models.Heatmap{
+		URL:      url,
+		Path:     page.path,
+		GridSize: h.gridSize,
+		Clicks:   page.clicks,
+		Cells:    make([][]int64, h.gridSize),
+		Updated:  page.updated,
+	}
+	for row := range result.Cells {
+		result.Cells[row] = append([]int64(nil), page.cells[row*h.gridSize:(row+1)*h.gridSize]...)
+		for _, count := range result.Cells[row] {
+			result.MaxCell = max(result.MaxCell, count)
+		}
+	}
+	return result
+}
@@ -0,0 +1,41 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+func clickAt(id string, x, y float64) *models.AnalyticsEvent {
+	return &models.AnalyticsEvent{
+		ID: id, Type: models.Click, Timestamp: time.Now(), URL: "https://example.com/pricing", Path: "/pricing",
+		Metadata: map[string]interface{}{"x_position": x, "y_position": y, "screen_width": 1000.0, "screen_height": 800.0},
+	}
+}
+
+func TestHeatmapAggregatesClicks(t *testing.T) {
+	service := NewService()
+	service.SetHeatmapOptions(4, 10)
+	start := time.Now()
+
+	service.ProcessEvent(clickAt("1", 10, 10))   // Top left
+	service.ProcessEvent(clickAt("2", 20, 30))   // Top left
+	service.ProcessEvent(clickAt("3", 999, 799)) // Bottom right
+	service.ProcessEvent(clickAt("4", 500, 900)) // Below the screen, ignored
+
+	heatmap, ok := service.GetHeatmap("https://example.com/pricing")
+	if !ok {
+		t.Fatal("expected a heatmap for the clicked page")
+	}
+	if heatmap.Clicks != 3 || heatmap.Cells[0][0] != 2 || heatmap.Cells[3][3] != 1 || heatmap.MaxCell != 2 {
+		t.Errorf("unexpected heatmap %+v", heatmap)
+	}
+
+	if updated := service.GetHeatmapsUpdatedSince(start); len(updated) != 1 {
+		t.Errorf("expected 1 updated heatmap, got %d", len(updated))
+	}
+	if updated := service.GetHeatmapsUpdatedSince(time.Now()); len(updated) != 0 {
+		t.Errorf("expected no heatmaps updated in the future, got %d", len(updated))
+	}
+}
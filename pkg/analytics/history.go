@@ -0,0 +1,287 @@
+package analytics
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/logging"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/store"
+)
+
+// Query range limits keep history responses reasonably sized
+var maxHistoryRange = map[models.Granularity]time.Duration{
+	models.GranularityHour:  31 * 24 * time.Hour,
+	models.GranularityDay:   366 * 24 * time.Hour,
+	models.GranularityWeek:  5 * 366 * 24 * time.Hour,
+	models.GranularityMonth: 20 * 366 * 24 * time.Hour,
+}
+
+var granularityAdjective = map[models.Granularity]string{
+	models.GranularityHour:  "hourly",
+	models.GranularityDay:   "daily",
+	models.GranularityWeek:  "weekly",
+	models.GranularityMonth: "monthly",
+}
+
+// History persists hourly rollups to a store and serves time-range queries
+type History struct {
+	service   *Service
+	store     store.Store
+	warehouse Warehouse // Optional; answers queries from archived events
+	retention RollupRetention
+
+	// State of the rollup job
+	rollups   RollupStatus
+	rolledUp  bool // Set once the job has covered every stored hour
+	rollupsMu sync.Mutex
+
+	// Event counts at the last flush, used to skip unchanged hours
+	persisted map[int64]int64
+	mu        sync.Mutex
+}
+
+// NewHistory creates a history recorder backed by the given store
+func NewHistory(service *Service, st store.Store) *History {
+	return &History{
+		service:   service,
+		store:     st,
+		persisted: make(map[int64]int64),
+	}
+}
+
+// Flush writes hourly rollups that changed since the last flush to the store
+func (h *History) Flush(ctx context.Context) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	// Hours partly lost to a reset would overwrite their complete persisted rollups
+	completeSince := h.service.CompleteSince()
+
+	var changed []models.Rollup
+	for _, rollup := range h.service.GetHourlyRollups() {
+		if rollup.Start.Before(completeSince) {
+			continue
+		}
+		if h.persisted[rollup.Start.Unix()] != rollup.Events {
+			changed = append(changed, rollup)
+		}
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+
+	if err := h.store.SaveRollups(ctx, changed); err != nil {
+		return fmt.Errorf("failed to persist hourly rollups: %w", err)
+	}
+	corrected := 0
+	watermark := h.service.Watermark()
+	for _, rollup := range changed {
+		// Hours the watermark had passed and that were flushed before changed through late events
+		if _, ok := h.persisted[rollup.Start.Unix()]; ok && !rollup.Start.Add(time.Hour).After(watermark) {
+			corrected++
+		}
+		h.persisted[rollup.Start.Unix()] = rollup.Events
+	}
+	if corrected > 0 {
+		logging.Info("Corrected persisted hourly rollups with late events", "hours", corrected)
+	}
+
+	// Forget hours that have aged out of the in-memory window
+	cutoff := time.Now().Add(-48 * time.Hour).Unix()
+	for hour := range h.persisted {
+		if hour < cutoff {
+			delete(h.persisted, hour)
+		}
+	}
+	return nil
+}
+
+// Run periodically flushes rollups until the context is cancelled, then flushes once more
+func (h *History) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := h.Flush(ctx); err != nil {
+				logging.Error("History flush failed", "error", err)
+			}
+		case <-ctx.Done():
+			// Use a fresh context so the final flush is not cancelled
+			flushCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			if err := h.Flush(flushCtx); err != nil {
+				logging.Error("Final history flush failed", "error", err)
+			}
+			cancel()
+			return
+		}
+	}
+}
+
+// Restore loads the persisted hourly rollups of the in-memory window back into the
+// service, e.g. after a reset, and returns how many hours were restored
+func (h *History) Restore(ctx context.Context) (int, error) {
+	to := time.Now().Add(time.Hour)
+	from := to.Add(-h.service.Retention().HourlyWindow - time.Hour)
+	rollups, err := h.store.QueryRollups(ctx, models.GranularityHour, from, to)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read persisted rollups: %w", err)
+	}
+	return h.service.RestoreRollups(rollups), nil
+}
+
+// Query returns rollups for [from, to) at the requested granularity.
+// Live in-memory hours take precedence over persisted ones, since they may not be flushed yet,
+// unless they started before the last reset. Coarser buckets are summed from finer ones
+// while those are kept, and read from their stored rollups once the finer ones expired.
+// Unique users and sessions are only reported for hourly buckets: users active in
+// several hours of a day would be counted once per hour, so summing overstates them.
+func (h *History) Query(ctx context.Context, from, to time.Time, granularity models.Granularity) ([]models.Rollup, error) {
+	if err := validateHistoryRange(from, to, granularity); err != nil {
+		return nil, err
+	}
+
+	// Widen the range to whole buckets so the first bucket is complete
+	return h.buckets(ctx, granularity.Start(from), to, granularity, time.Now())
+}
+
+// buckets returns consecutive buckets of the granularity from start until to
+func (h *History) buckets(ctx context.Context, start, to time.Time, granularity models.Granularity, now time.Time) ([]models.Rollup, error) {
+	if granularity == models.GranularityHour {
+		hours, err := h.hours(ctx, start, to)
+		if err != nil {
+			return nil, err
+		}
+		return bucketRollups(hours, models.GranularityHour, start, to, granularity), nil
+	}
+
+	// Buckets whose finer rollups have expired are read as stored
+	var result []models.Rollup
+	finer := finerGranularity(granularity)
+	if cutoff := h.retention.cutoff(finer, now); start.Before(cutoff) {
+		stored, err := h.store.QueryRollups(ctx, granularity, start, cutoff)
+		if err != nil {
+			return nil, err
+		}
+		byStart := make(map[int64]models.Rollup, len(stored))
+		for _, rollup := range stored {
+			byStart[rollup.Start.Unix()] = rollup
+		}
+		for ; start.Before(to) && start.Before(cutoff); start = granularity.Next(start) {
+			rollup, ok := byStart[start.Unix()]
+			if !ok {
+				rollup = models.Rollup{Start: start, Granularity: granularity, EventsByType: make(map[models.EventType]int64)}
+			}
+			// Summaries stored by earlier versions summed the hourly distinct counts
+			rollup.UniqueUsers, rollup.Sessions = 0, 0
+			result = append(result, rollup)
+		}
+	}
+	if !start.Before(to) {
+		return result, nil
+	}
+
+	// Later buckets are summed from the finer ones they contain
+	end := start
+	for end.Before(to) {
+		end = granularity.Next(end)
+	}
+	parts, err := h.buckets(ctx, start, end, finer, now)
+	if err != nil {
+		return nil, err
+	}
+	byStart := make(map[int64]models.Rollup, len(parts))
+	for _, rollup := range parts {
+		byStart[rollup.Start.Unix()] = rollup
+	}
+	return append(result, bucketRollups(byStart, finer, start, to, granularity)...), nil
+}
+
+// hours returns the persisted and live hourly rollups in [start, to) by start time
+func (h *History) hours(ctx context.Context, start, to time.Time) (map[int64]models.Rollup, error) {
+	stored, err := h.store.QueryRollups(ctx, models.GranularityHour, start, to)
+	if err != nil {
+		return nil, err
+	}
+
+	hours := make(map[int64]models.Rollup, len(stored))
+	for _, rollup := range stored {
+		hours[rollup.Start.Unix()] = rollup
+	}
+	completeSince := h.service.CompleteSince()
+	for _, rollup := range h.service.GetHourlyRollups() {
+		if rollup.Start.Before(start) || !rollup.Start.Before(to) {
+			continue
+		}
+		// Hours partly lost to a reset are only used when nothing was persisted for them
+		if _, persisted := hours[rollup.Start.Unix()]; persisted && rollup.Start.Before(completeSince) {
+			continue
+		}
+		hours[rollup.Start.Unix()] = rollup
+	}
+	return hours, nil
+}
+
+// finerGranularity returns the granularity whose rollups a coarser one is summed from
+func finerGranularity(granularity models.Granularity) models.Granularity {
+	switch granularity {
+	case models.GranularityWeek, models.GranularityMonth:
+		return models.GranularityDay
+	default:
+		return models.GranularityHour
+	}
+}
+
+// validateHistoryRange checks the granularity and the size of a history query's range
+func validateHistoryRange(from, to time.Time, granularity models.Granularity) error {
+	if !from.Before(to) {
+		return fmt.Errorf("from must be before to")
+	}
+	limit, ok := maxHistoryRange[granularity]
+	if !ok {
+		return fmt.Errorf("unsupported granularity %q", granularity)
+	}
+	if to.Sub(from) > limit {
+		return fmt.Errorf("%s queries are limited to %d days", granularityAdjective[granularity], int(limit.Hours()/24))
+	}
+	return nil
+}
+
+// bucketRollups sums rollups of the source granularity into consecutive buckets,
+// filling gaps with zeroes. Unique users and sessions are kept for hourly buckets and
+// left out of coarser ones.
+func bucketRollups(parts map[int64]models.Rollup, source models.Granularity, start, to time.Time, granularity models.Granularity) []models.Rollup {
+	var result []models.Rollup
+	for begin := start; begin.Before(to); begin = granularity.Next(begin) {
+		bucket := models.Rollup{
+			Start:        begin,
+			Granularity:  granularity,
+			EventsByType: make(map[models.EventType]int64),
+		}
+
+		end := granularity.Next(begin)
+		for part := begin; part.Before(end); part = source.Next(part) {
+			rollup, ok := parts[part.Unix()]
+			if !ok {
+				continue
+			}
+			bucket.Events += rollup.Events
+			bucket.PageViews += rollup.PageViews
+			if granularity == models.GranularityHour {
+				bucket.UniqueUsers += rollup.UniqueUsers
+				bucket.Sessions += rollup.Sessions
+			}
+			bucket.LateEvents += rollup.LateEvents
+			for eventType, count := range rollup.EventsByType {
+				bucket.EventsByType[eventType] += count
+			}
+		}
+
+		result = append(result, bucket)
+	}
+	return result
+}
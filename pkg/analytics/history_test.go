@@ -0,0 +1,89 @@
+package analytics
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/store"
+)
+
+func TestHistoryQueryMergesStoredAndLiveRollups(t *testing.T) {
+	ctx := context.Background()
+	service := NewService()
+	st := store.NewMemoryStore()
+	history := NewHistory(service, st)
+
+	now := time.Now().UTC().Truncate(time.Hour)
+	old := now.Add(-72 * time.Hour)
+
+	// Persisted rollup older than the in-memory window
+	if err := st.SaveRollups(ctx, []models.Rollup{{
+		Start:        old,
+		Granularity:  models.GranularityHour,
+		Events:       5,
+		EventsByType: map[models.EventType]int64{models.PageView: 5},
+	}}); err != nil {
+		t.Fatalf("Failed to save rollup: %v", err)
+	}
+
+	// Live events in the current hour
+	for i := 0; i < 3; i++ {
+		service.ProcessEvent(&models.AnalyticsEvent{
+			Type:      models.PageView,
+			Timestamp: now.Add(time.Minute),
+			UserID:    "user-1",
+			URL:       "https://example.com/",
+		})
+	}
+
+	rollups, err := history.Query(ctx, old, now.Add(time.Hour), models.GranularityHour)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if len(rollups) != 73 {
+		t.Fatalf("Expected 73 hourly buckets, got %d", len(rollups))
+	}
+	if rollups[0].Events != 5 {
+		t.Errorf("Stored bucket events: got %d, want 5", rollups[0].Events)
+	}
+	last := rollups[len(rollups)-1]
+	if last.Events != 3 || last.UniqueUsers != 1 {
+		t.Errorf("Live bucket: got events=%d users=%d, want 3 and 1", last.Events, last.UniqueUsers)
+	}
+}
+
+func TestHistoryFlushPersistsChangedHours(t *testing.T) {
+	ctx := context.Background()
+	service := NewService()
+	st := store.NewMemoryStore()
+	history := NewHistory(service, st)
+
+	now := time.Now().UTC().Truncate(time.Hour)
+	service.ProcessEvent(&models.AnalyticsEvent{Type: models.Click, Timestamp: now, UserID: "u"})
+
+	if err := history.Flush(ctx); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	stored, err := st.QueryRollups(ctx, models.GranularityHour, now, now.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("QueryRollups failed: %v", err)
+	}
+	if len(stored) != 1 || stored[0].Events != 1 {
+		t.Fatalf("Expected one persisted rollup with 1 event, got %+v", stored)
+	}
+}
+
+func TestHistoryQueryValidation(t *testing.T) {
+	history := NewHistory(NewService(), store.NewMemoryStore())
+	now := time.Now()
+
+	if _, err := history.Query(context.Background(), now, now.Add(-time.Hour), models.GranularityHour); err == nil {
+		t.Error("Expected error for inverted range")
+	}
+	if _, err := history.Query(context.Background(), now.Add(-time.Hour), now, "minute"); err == nil {
+		t.Error("Expected error for unsupported granularity")
+	}
+}
@@ -0,0 +1,216 @@
+package analytics
+
+import (
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/hll"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+// identityAlias maps an anonymous ID to the user it was identified as
+type identityAlias struct {
+	userID string
+	seen   time.Time // Last identify or event under the anonymous ID
+}
+
+// identityTracker remembers identified anonymous IDs so their later events count for
+// the user. Aliases unused for the hourly window are forgotten on Cleanup.
+type identityTracker struct {
+	aliases  map[string]*identityAlias // Anonymous ID -> identified user
+	stitched int64                     // Anonymous IDs merged into users
+}
+
+func newIdentityTracker() *identityTracker {
+	return &identityTracker{aliases: make(map[string]*identityAlias)}
+}
+
+// resolveIdentity returns the event with an identified anonymous ID replaced by the
+// user's ID, copying rather than changing the caller's event. The caller must hold the
+// analytics lock.
+func (s *Service) resolveIdentity(event *models.AnalyticsEvent) *models.AnalyticsEvent {
+	alias := s.identities.aliases[event.UserID]
+	if alias == nil {
+		return event
+	}
+	if event.Timestamp.After(alias.seen) {
+		alias.seen = event.Timestamp
+	}
+	resolved := *event
+	resolved.UserID = alias.userID
+	return &resolved
+}
+
+// processIdentify merges the anonymous visitor an identify event names, or by default
+// the other users of its session, into the identified user. The caller must hold the
+// analytics lock.
+func (s *Service) processIdentify(event *models.AnalyticsEvent) {
+	if !models.IsIdentify(event) {
+		return
+	}
+
+	var anonymous []string
+	if id, _ := event.Metadata[models.MetadataAnonymousID].(string); id != "" {
+		anonymous = append(anonymous, id)
+	} else if event.SessionID != "" {
+		seen := make(map[string]bool)
+		for _, recent := range s.analytics.Events {
+			if recent.SessionID == event.SessionID && recent.UserID != "" && recent.UserID != event.UserID && !seen[recent.UserID] {
+				seen[recent.UserID] = true
+				anonymous = append(anonymous, recent.UserID)
+			}
+		}
+	}
+
+	for _, anonymousID := range anonymous {
+		s.mergeUser(anonymousID, event.UserID, event.Timestamp)
+	}
+	// Campaigns and experiments track anonymous visitors without a user ID by session
+	if event.SessionID != "" {
+		s.mergeParticipant(event.SessionID, event.UserID)
+		s.mergeVisitor("session:"+event.SessionID, "user:"+event.UserID)
+	}
+}
+
+// mergeUser moves everything recorded for the anonymous ID to the user and aliases the
+// ID to the user for later events. Distinct counts that have switched to estimates keep
+// counting both. The caller must hold the analytics lock.
+func (s *Service) mergeUser(anonymousID, userID string, at time.Time) {
+	if anonymousID == "" || anonymousID == userID {
+		return
+	}
+	aliases := s.identities.aliases
+	if alias := aliases[userID]; alias != nil && alias.userID == anonymousID {
+		// The user was itself identified as the anonymous ID; drop the cycle
+		delete(aliases, userID)
+	}
+	if alias := aliases[anonymousID]; alias == nil {
+		s.identities.stitched++
+	}
+	aliases[anonymousID] = &identityAlias{userID: userID, seen: at}
+	for _, alias := range aliases {
+		if alias.userID == anonymousID {
+			alias.userID = userID
+		}
+	}
+
+	// Copy rather than rewrite in place, since snapshots may share the old backing array
+	events := make([]models.AnalyticsEvent, len(s.analytics.Events))
+	copy(events, s.analytics.Events)
+	for i := range events {
+		if events[i].UserID == anonymousID {
+			events[i].UserID = userID
+		}
+	}
+	s.analytics.Events = events
+
+	moveDistinct(s.analytics.UniqueUsers, anonymousID, userID)
+	moveInAll(s.analytics.HourlyUsers, anonymousID, userID)
+	moveInAll(s.analytics.PageVisitors, anonymousID, userID)
+	moveInAll(s.analytics.SiteVisitors, anonymousID, userID)
+
+	s.mergeVisitor("user:"+anonymousID, "user:"+userID)
+	s.mergeParticipant(anonymousID, userID)
+	s.sessions.mergeUser(anonymousID, userID)
+
+	moveMember(s.commerce.viewers, anonymousID, userID)
+	moveMember(s.commerce.purchasers, anonymousID, userID)
+	moveMember(s.errors.users, anonymousID, userID)
+	for _, group := range s.errors.groups {
+		moveMember(group.users, anonymousID, userID)
+	}
+	for _, metric := range s.customMetrics {
+		moveMember(metric.users, anonymousID, userID)
+	}
+}
+
+// mergeVisitor combines an active visitor's activity into another's. The caller must
+// hold the analytics lock.
+func (s *Service) mergeVisitor(from, to string) {
+	visitor := s.visitors.visitors[from]
+	if visitor == nil {
+		return
+	}
+	delete(s.visitors.visitors, from)
+	if existing := s.visitors.visitors[to]; existing == nil || visitor.lastSeen.After(existing.lastSeen) {
+		s.visitors.visitors[to] = visitor
+	}
+}
+
+// mergeParticipant moves a campaign and experiment participant to the user. The user
+// keeps their own campaign attribution and variants where they have them. The caller
+// must hold the analytics lock.
+func (s *Service) mergeParticipant(from, userID string) {
+	if key, ok := s.userCampaigns[from]; ok {
+		if _, attributed := s.userCampaigns[userID]; !attributed {
+			s.userCampaigns[userID] = key
+		}
+		delete(s.userCampaigns, from)
+	}
+	for _, c := range s.campaigns {
+		moveMember(c.users, from, userID)
+		moveMember(c.converted, from, userID)
+	}
+
+	s.experiments.mu.Lock()
+	defer s.experiments.mu.Unlock()
+	for _, exp := range s.experiments.experiments {
+		if variant, ok := exp.assignments[from]; ok {
+			if _, assigned := exp.assignments[userID]; !assigned {
+				exp.assignments[userID] = variant
+			}
+			delete(exp.assignments, from)
+		}
+		moveMember(exp.converted, from, userID)
+	}
+}
+
+// moveDistinct replaces an item of an exact counter with another
+func moveDistinct(counter *hll.Counter, from, to string) {
+	if counter.Contains(from) {
+		counter.Remove(from)
+		counter.Add(to)
+	}
+}
+
+// moveInAll replaces an item of every exact counter with another
+func moveInAll[K comparable](counters map[K]*hll.Counter, from, to string) {
+	for _, counter := range counters {
+		moveDistinct(counter, from, to)
+	}
+}
+
+// moveMember replaces a member of a set with another
+func moveMember(set map[string]bool, from, to string) {
+	if set[from] {
+		delete(set, from)
+		set[to] = true
+	}
+}
+
+// expire forgets aliases unused since the cutoff
+func (t *identityTracker) expire(cutoff time.Time) {
+	for anonymousID, alias := range t.aliases {
+		if alias.seen.Before(cutoff) {
+			delete(t.aliases, anonymousID)
+		}
+	}
+}
+
+// forget removes the aliases to and from a user, for erasure
+func (t *identityTracker) forget(userID string) {
+	delete(t.aliases, userID)
+	for anonymousID, alias := range t.aliases {
+		if alias.userID == userID {
+			delete(t.aliases, anonymousID)
+		}
+	}
+}
+
+// identityStats returns the identity resolution counts. The caller must hold the
+// analytics lock.
+func (s *Service) identityStats() models.IdentityStats {
+	return models.IdentityStats{
+		StitchedUsers: s.identities.stitched,
+		Aliases:       int64(len(s.identities.aliases)),
+	}
+}
@@ -0,0 +1,88 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+// identify returns an identify event for the user, naming the anonymous ID if not empty
+func identify(userID, anonymousID, sessionID string) *models.AnalyticsEvent {
+	metadata := map[string]interface{}{models.MetadataAction: models.ActionIdentify}
+	if anonymousID != "" {
+		metadata[models.MetadataAnonymousID] = anonymousID
+	}
+	return &models.AnalyticsEvent{Type: models.UserEvent, Timestamp: time.Now(), UserID: userID, SessionID: sessionID, Metadata: metadata}
+}
+
+func TestIdentifyMergesAnonymousUser(t *testing.T) {
+	service := NewService()
+	now := time.Now()
+	service.ProcessEvent(&models.AnalyticsEvent{Type: models.PageView, Timestamp: now, UserID: "anon-1", SessionID: "s1", URL: "https://example.com/pricing", Path: "/pricing"})
+	service.ProcessEvent(&models.AnalyticsEvent{Type: models.PageView, Timestamp: now, UserID: "user-42", SessionID: "s0", URL: "https://example.com/", Path: "/"})
+
+	service.ProcessEvent(identify("user-42", "anon-1", "s1"))
+
+	snapshot := service.GetSnapshot()
+	if snapshot.UniqueUsers != 1 {
+		t.Errorf("Expected the anonymous visitor merged into one user, got %d users", snapshot.UniqueUsers)
+	}
+	if snapshot.Identities.StitchedUsers != 1 || snapshot.Identities.Aliases != 1 {
+		t.Errorf("Unexpected identity stats %+v", snapshot.Identities)
+	}
+	for _, event := range snapshot.RealTimeEvents {
+		if event.UserID == "anon-1" {
+			t.Errorf("Expected recent events rewritten to the user, got %+v", event)
+		}
+	}
+	if active := service.GetActiveVisitors(); active.LastFiveMinutes != 1 {
+		t.Errorf("Expected one active visitor, got %d", active.LastFiveMinutes)
+	}
+
+	// Later events under the anonymous ID count for the user
+	service.ProcessEvent(&models.AnalyticsEvent{Type: models.PageView, Timestamp: now, UserID: "anon-1", SessionID: "s1", URL: "https://example.com/signup", Path: "/signup"})
+	if users := service.GetSnapshot().UniqueUsers; users != 1 {
+		t.Errorf("Expected events of the identified anonymous ID counted for the user, got %d users", users)
+	}
+}
+
+func TestIdentifyBySession(t *testing.T) {
+	service := NewService()
+	service.SetExperimentGoal("checkout", models.Goal{EventType: models.Purchase})
+	service.ProcessEvent(&models.AnalyticsEvent{
+		Type: models.PageView, Timestamp: time.Now(), UserID: "anon-7", SessionID: "s7", Path: "/",
+		Metadata: map[string]interface{}{models.MetadataExperimentID: "checkout", models.MetadataVariant: "b"},
+	})
+
+	// Without an anonymous ID the other users of the session are merged
+	service.ProcessEvent(identify("user-7", "", "s7"))
+	service.ProcessEvent(&models.AnalyticsEvent{Type: models.Purchase, Timestamp: time.Now(), UserID: "user-7", SessionID: "s8"})
+
+	if users := service.GetSnapshot().UniqueUsers; users != 1 {
+		t.Errorf("Expected one user, got %d", users)
+	}
+	results := service.GetExperimentResults()
+	if len(results) != 1 || len(results[0].Variants) != 1 {
+		t.Fatalf("Unexpected experiment results %+v", results)
+	}
+	if variant := results[0].Variants[0]; variant.Variant != "b" || variant.Participants != 1 || variant.Conversions != 1 {
+		t.Errorf("Expected the identified user to keep variant b and convert, got %+v", variant)
+	}
+}
+
+func TestEraseForgetsAliases(t *testing.T) {
+	service := NewService()
+	service.ProcessEvent(identify("user-1", "anon-1", ""))
+	service.EraseUser("user-1")
+
+	service.ProcessEvent(&models.AnalyticsEvent{Type: models.PageView, Timestamp: time.Now(), UserID: "anon-1", Path: "/"})
+	if aliases := service.GetSnapshot().Identities.Aliases; aliases != 0 {
+		t.Errorf("Expected erasure to forget the user's aliases, got %d", aliases)
+	}
+	for _, event := range service.GetSnapshot().RealTimeEvents {
+		if event.UserID == "user-1" {
+			t.Errorf("Expected later anonymous events not attributed to the erased user, got %+v", event)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package analytics
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+// latencyAlertMetrics are the alert metrics read from the latency window percentiles
+var latencyAlertMetrics = map[string]func(*models.LatencyStats) float64{
+	"pipeline_latency_p95": func(l *models.LatencyStats) float64 { return l.EndToEnd.P95Ms },
+	"broker_latency_p95":   func(l *models.LatencyStats) float64 { return l.Broker.P95Ms },
+}
+
+// latencyStage records the latency of one pipeline stage
+type latencyStage struct {
+	count   int64
+	sum     float64                   // Milliseconds
+	minutes map[int64]*QuantileSketch // Unix minute -> latencies, for the window percentiles
+}
+
+// observe records a latency at now, dropping minutes that left the window
+func (l *latencyStage) observe(latency time.Duration, now time.Time, cutoff int64) {
+	ms := float64(max(latency, 0)) / float64(time.Millisecond)
+	l.count++
+	l.sum += ms
+
+	key := now.Truncate(time.Minute).Unix()
+	minute := l.minutes[key]
+	if minute == nil {
+		for start := range l.minutes {
+			if start < cutoff {
+				delete(l.minutes, start)
+			}
+		}
+		minute = NewQuantileSketch()
+		l.minutes[key] = minute
+	}
+	minute.Add(ms)
+}
+
+// stats returns the stage's totals and its percentiles over the minutes from cutoff
+func (l *latencyStage) stats(cutoff int64) models.LatencyPercents {
+	window := NewQuantileSketch()
+	for start, minute := range l.minutes {
+		if start >= cutoff {
+			window.Merge(minute)
+		}
+	}
+	return models.LatencyPercents{
+		Count:       l.count,
+		SumMs:       l.sum,
+		WindowCount: window.Count(),
+		AverageMs:   window.Mean(),
+		P50Ms:       window.Quantile(0.50),
+		P90Ms:       window.Quantile(0.90),
+		P95Ms:       window.Quantile(0.95),
+		P99Ms:       window.Quantile(0.99),
+		MaxMs:       window.Quantile(1),
+	}
+}
+
+// LatencyTracker measures how long events take to move through the pipeline, from their
+// timestamp to the producer's write and on to processing. It is safe for concurrent use.
+type LatencyTracker struct {
+	window time.Duration
+
+	mu       sync.Mutex
+	ingest   latencyStage
+	broker   latencyStage
+	endToEnd latencyStage
+}
+
+// NewLatencyTracker creates a tracker whose percentiles cover the given window
+func NewLatencyTracker(window time.Duration) *LatencyTracker {
+	window = max(window, time.Minute)
+	return &LatencyTracker{
+		window:   window,
+		ingest:   latencyStage{minutes: make(map[int64]*QuantileSketch)},
+		broker:   latencyStage{minutes: make(map[int64]*QuantileSketch)},
+		endToEnd: latencyStage{minutes: make(map[int64]*QuantileSketch)},
+	}
+}
+
+// Observe records the latencies of an event with the given timestamp, written to Kafka
+// at produced and processed at now. Zero times leave the stages that need them out.
+// Latencies below zero, from clock skew between hosts, count as zero.
+func (t *LatencyTracker) Observe(eventTime, produced, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	cutoff := t.windowStart(now)
+	if !eventTime.IsZero() && !produced.IsZero() {
+		t.ingest.observe(produced.Sub(eventTime), now, cutoff)
+	}
+	if !produced.IsZero() {
+		t.broker.observe(now.Sub(produced), now, cutoff)
+	}
+	if !eventTime.IsZero() {
+		t.endToEnd.observe(now.Sub(eventTime), now, cutoff)
+	}
+}
+
+// windowStart returns the first minute counted towards the percentiles at now
+func (t *LatencyTracker) windowStart(now time.Time) int64 {
+	return now.Add(-t.window).Truncate(time.Minute).Unix()
+}
+
+// Stats returns the latency of each stage, with percentiles over the window ending at now
+func (t *LatencyTracker) Stats(now time.Time) models.LatencyStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	cutoff := t.windowStart(now)
+	return models.LatencyStats{
+		WindowMinutes: int(t.window / time.Minute),
+		Ingest:        t.ingest.stats(cutoff),
+		Broker:        t.broker.stats(cutoff),
+		EndToEnd:      t.endToEnd.stats(cutoff),
+	}
+}
+
+// SetLatencyTracker reports the pipeline latency recorded by tracker in snapshots, so
+// alerts can be set on it. Latency is measured by whoever consumes events, not by the
+// service; nil stops reporting.
+func (s *Service) SetLatencyTracker(tracker *LatencyTracker) {
+	s.analytics.Mu.Lock()
+	defer s.analytics.Mu.Unlock()
+	s.latency = tracker
+}
+
+// getLatencyStats returns the tracked latency, or nil when none is tracked. The caller
+// must hold the analytics lock.
+func (s *Service) getLatencyStats(now time.Time) *models.LatencyStats {
+	if s.latency == nil {
+		return nil
+	}
+	stats := s.latency.Stats(now)
+	return &stats
+}
+
+// latencyMetricValue returns a latency percentile of the snapshot, 0 when not tracked
+func latencyMetricValue(snapshot *models.MetricsSnapshot, metric string) float64 {
+	if snapshot.Latency == nil {
+		return 0
+	}
+	return latencyAlertMetrics[metric](snapshot.Latency)
+}
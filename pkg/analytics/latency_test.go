@@ -0,0 +1,93 @@
+package analytics
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+func TestQuantileSketchMerge(t *testing.T) {
+	merged, whole := NewQuantileSketch(), NewQuantileSketch()
+	for part := 0; part < 4; part++ {
+		sketch := NewQuantileSketch()
+		for i := 1; i <= 250; i++ {
+			value := float64(part*250 + i)
+			sketch.Add(value)
+			whole.Add(value)
+		}
+		merged.Merge(sketch)
+	}
+
+	if merged.Count() != whole.Count() || merged.Mean() != whole.Mean() {
+		t.Fatalf("expected %d values averaging %v, got %d averaging %v", whole.Count(), whole.Mean(), merged.Count(), merged.Mean())
+	}
+	for _, p := range []float64{0, 0.5, 0.95, 1} {
+		if got, want := merged.Quantile(p), whole.Quantile(p); math.Abs(got-want) > 1e-9 {
+			t.Errorf("quantile %v: expected %v, got %v", p, want, got)
+		}
+	}
+}
+
+func TestLatencyTracker(t *testing.T) {
+	tracker := NewLatencyTracker(5 * time.Minute)
+	now := time.Now()
+
+	// Ten minutes ago, outside the window
+	tracker.Observe(now.Add(-11*time.Minute), now.Add(-10*time.Minute), now.Add(-10*time.Minute))
+	for i := 0; i < 100; i++ {
+		eventTime := now.Add(-2 * time.Second)
+		tracker.Observe(eventTime, eventTime.Add(500*time.Millisecond), now)
+	}
+	// A client clock running ahead counts as no latency
+	tracker.Observe(now.Add(time.Minute), now, now)
+	// Messages without a timestamp only measure the broker stage
+	tracker.Observe(time.Time{}, now.Add(-time.Second), now)
+
+	stats := tracker.Stats(now)
+	if stats.WindowMinutes != 5 {
+		t.Errorf("expected a 5 minute window, got %d", stats.WindowMinutes)
+	}
+	if stats.EndToEnd.Count != 102 || stats.EndToEnd.WindowCount != 101 {
+		t.Errorf("expected 102 end-to-end measurements, 101 in the window, got %+v", stats.EndToEnd)
+	}
+	if stats.Broker.Count != 103 || stats.Ingest.Count != 102 {
+		t.Errorf("expected 103 broker and 102 ingest measurements, got %d and %d", stats.Broker.Count, stats.Ingest.Count)
+	}
+	if p50 := stats.EndToEnd.P50Ms; math.Abs(p50-2000) > 20 {
+		t.Errorf("expected a median end-to-end latency of about 2000ms, got %v", p50)
+	}
+	if p95 := stats.Broker.P95Ms; math.Abs(p95-1500) > 15 {
+		t.Errorf("expected a p95 broker latency of about 1500ms, got %v", p95)
+	}
+	if stats.Ingest.MaxMs != 500 {
+		t.Errorf("expected a maximum ingest latency of 500ms, got %v", stats.Ingest.MaxMs)
+	}
+}
+
+func TestPipelineLatencyAlert(t *testing.T) {
+	service := NewService()
+	tracker := NewLatencyTracker(5 * time.Minute)
+	service.SetLatencyTracker(tracker)
+	service.SetAlerts([]models.AlertConfig{
+		{Name: "Slow Pipeline", Type: "performance", Metric: "pipeline_latency_p95", Threshold: 60000, Operator: "gt", Enabled: true},
+	})
+
+	now := time.Now()
+	tracker.Observe(now.Add(-time.Second), now.Add(-time.Second), now)
+	if alerts := service.CheckAlerts(); len(alerts) != 0 {
+		t.Fatalf("expected no alert at 1s latency, got %+v", alerts)
+	}
+
+	for i := 0; i < 20; i++ {
+		tracker.Observe(now.Add(-2*time.Minute), now.Add(-time.Minute), now)
+	}
+	alerts := service.CheckAlerts()
+	if len(alerts) != 1 || alerts[0].CurrentValue < 110000 {
+		t.Fatalf("expected the latency alert with a p95 of about 2 minutes, got %+v", alerts)
+	}
+	if snapshot := service.GetSnapshot(); snapshot.Latency == nil || snapshot.Latency.EndToEnd.Count != 21 {
+		t.Errorf("expected latency in the snapshot, got %+v", snapshot.Latency)
+	}
+}
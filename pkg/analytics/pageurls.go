@@ -0,0 +1,124 @@
+package analytics
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+// maxPageVariants bounds the variants tracked per page, since they come from client input
+const maxPageVariants = 50
+
+// URLRules decide which page URLs are counted and the form they are counted under, so
+// one page isn't split across many URLs in page metrics
+type URLRules struct {
+	// Normalize lowercases hosts, collapses trailing and repeated slashes and strips
+	// fragments and query parameters other than KeepParams
+	Normalize bool
+
+	KeepParams    []string // Query parameters that identify distinct pages, e.g. "id"
+	VariantParams []string // Query parameters stripped and counted as variants of the page, e.g. "lang"
+
+	// Hosts pages are counted for, each with its subdomains; empty counts every host
+	AllowedDomains []string
+}
+
+// DomainAllowed reports whether events for the URL's host are counted. URLs without a
+// host are always allowed.
+func (r URLRules) DomainAllowed(rawURL string) bool {
+	if len(r.AllowedDomains) == 0 {
+		return true
+	}
+	host := SiteHost(rawURL)
+	if host == "" {
+		return true
+	}
+	for _, domain := range r.AllowedDomains {
+		domain = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(domain)), "www.")
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// NormalizeURL returns the URL a page is counted under and the variant its variant
+// parameters select, e.g. "lang=fr". URLs that don't parse are returned unchanged.
+func (r URLRules) NormalizeURL(rawURL string) (page, variant string) {
+	if !r.Normalize || rawURL == "" {
+		return rawURL, ""
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL, ""
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	u.Path = collapseSlashes(u.Path)
+	u.RawPath = ""
+	u.Fragment, u.RawFragment = "", ""
+
+	query := u.Query()
+	kept, variants := url.Values{}, url.Values{}
+	for _, name := range r.KeepParams {
+		if values, ok := query[name]; ok {
+			kept[name] = values
+		}
+	}
+	for _, name := range r.VariantParams {
+		if values, ok := query[name]; ok {
+			variants[name] = values
+		}
+	}
+	u.RawQuery = kept.Encode()
+	u.ForceQuery = false
+	return u.String(), variants.Encode()
+}
+
+// collapseSlashes removes repeated and trailing slashes from a URL path; the root path
+// stays "/"
+func collapseSlashes(path string) string {
+	var segments []string
+	for _, segment := range strings.Split(path, "/") {
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	return "/" + strings.Join(segments, "/")
+}
+
+// SetURLRules sets how page URLs are normalized and which hosts are counted
+func (s *Service) SetURLRules(rules URLRules) {
+	s.analytics.Mu.Lock()
+	defer s.analytics.Mu.Unlock()
+	s.urlRules = rules
+}
+
+// processForeignDomain counts events for hosts outside the allowed domains in their own
+// bucket and reports whether they were, so they can be left out of every other
+// aggregate. The caller must hold the analytics lock.
+func (s *Service) processForeignDomain(event *models.AnalyticsEvent, weight int64) bool {
+	if event.URL == "" || s.urlRules.DomainAllowed(event.URL) {
+		return false
+	}
+	addBounded(s.foreignHosts, SiteHost(event.URL), weight)
+	return true
+}
+
+// processPageVariant counts a page view towards the variant it was for. The caller must
+// hold the analytics lock.
+func (s *Service) processPageVariant(page, variant string, weight int64) {
+	if variant == "" {
+		return
+	}
+	variants := s.pageVariants[page]
+	if variants == nil {
+		variants = make(map[string]int64)
+		s.pageVariants[page] = variants
+	}
+	if _, ok := variants[variant]; ok || len(variants) < maxPageVariants {
+		variants[variant] += weight
+	}
+}
@@ -0,0 +1,75 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+func TestNormalizeURL(t *testing.T) {
+	rules := URLRules{Normalize: true, KeepParams: []string{"id"}, VariantParams: []string{"lang"}}
+	tests := []struct{ url, page, variant string }{
+		{"https://Example.COM/Docs//Intro/?utm_source=x#top", "https://example.com/Docs/Intro", ""},
+		{"https://example.com", "https://example.com/", ""},
+		{"https://example.com/product?ref=mail&id=7&lang=fr", "https://example.com/product?id=7", "lang=fr"},
+		{"/pricing/", "/pricing", ""},
+	}
+	for _, tt := range tests {
+		page, variant := rules.NormalizeURL(tt.url)
+		if page != tt.page || variant != tt.variant {
+			t.Errorf("NormalizeURL(%q) = %q, %q, want %q, %q", tt.url, page, variant, tt.page, tt.variant)
+		}
+	}
+
+	if page, _ := (URLRules{}).NormalizeURL("https://Example.com/a/?b=1"); page != "https://Example.com/a/?b=1" {
+		t.Errorf("expected URLs to be kept without Normalize, got %q", page)
+	}
+}
+
+func TestDomainAllowed(t *testing.T) {
+	rules := URLRules{AllowedDomains: []string{"example.com", "www.shop.io"}}
+	tests := map[string]bool{
+		"https://example.com/":           true,
+		"https://www.example.com/":       true,
+		"https://blog.example.com:8080/": true,
+		"https://shop.io/cart":           true,
+		"https://notexample.com/":        false,
+		"https://example.com.evil.net/":  false,
+		"/relative":                      true,
+	}
+	for url, want := range tests {
+		if got := rules.DomainAllowed(url); got != want {
+			t.Errorf("DomainAllowed(%q) = %v, want %v", url, got, want)
+		}
+	}
+}
+
+func TestURLRulesApplyToPageMetrics(t *testing.T) {
+	service := NewService()
+	service.SetURLRules(URLRules{Normalize: true, VariantParams: []string{"lang"}, AllowedDomains: []string{"example.com"}})
+	now := time.Now()
+	for i, url := range []string{
+		"https://example.com/docs/",
+		"https://EXAMPLE.com/docs?lang=fr",
+		"https://example.com/docs?utm_source=news&utm_campaign=launch",
+		"https://staging.example.net/docs",
+	} {
+		service.ProcessEvent(&models.AnalyticsEvent{ID: string(rune('a' + i)), Type: models.PageView, Timestamp: now, UserID: "u1", URL: url})
+	}
+
+	snapshot := service.GetSnapshot()
+	if len(snapshot.TopPages) != 1 {
+		t.Fatalf("expected one page, got %+v", snapshot.TopPages)
+	}
+	page := snapshot.TopPages[0]
+	if page.URL != "https://example.com/docs" || page.Views != 3 || page.Variants["lang=fr"] != 1 {
+		t.Errorf("unexpected page %+v", page)
+	}
+	if snapshot.ForeignDomains["staging.example.net"] != 1 || snapshot.TotalEvents != 3 {
+		t.Errorf("expected the foreign event counted apart, got %v and %d events", snapshot.ForeignDomains, snapshot.TotalEvents)
+	}
+	if len(snapshot.CampaignStats) != 1 || snapshot.CampaignStats[0].Campaign != "launch" {
+		t.Errorf("expected campaigns to read the URL as sent, got %+v", snapshot.CampaignStats)
+	}
+}
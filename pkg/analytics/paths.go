@@ -0,0 +1,166 @@
+package analytics
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+const (
+	// MaxPathDepth is the number of transitions tracked per session
+	MaxPathDepth = 10
+
+	// Defaults and bounds of path flow queries
+	defaultPathDepth     = 3
+	defaultPathStepLimit = 20
+	maxPathStepLimit     = 100
+
+	// Distinct transitions tracked; later new transitions are not counted
+	maxPathTransitions = 50000
+)
+
+// pathTransition identifies a transition at a step of the session journey
+type pathTransition struct {
+	step     int
+	from, to string
+}
+
+// sessionPath is a session's position in its journey
+type sessionPath struct {
+	step int // Transitions made so far
+	last string
+}
+
+// pathTracker counts page-to-page transitions by their step in the session, for path
+// flow analysis. Repeated views of the same page are not transitions, and only the
+// first MaxPathDepth transitions of a session count.
+type pathTracker struct {
+	sessions    map[string]*sessionPath // Session ID -> journey, until the session expires
+	transitions map[pathTransition]int64
+	truncated   bool
+}
+
+func newPathTracker() *pathTracker {
+	return &pathTracker{
+		sessions:    make(map[string]*sessionPath),
+		transitions: make(map[pathTransition]int64),
+	}
+}
+
+// processPath records a page view as the next page of its session's journey. The caller
+// must hold the analytics lock.
+func (s *Service) processPath(event *models.AnalyticsEvent) {
+	path := pagePath(event)
+	if event.SessionID == "" || path == "" {
+		return
+	}
+
+	t := s.paths
+	session := t.sessions[event.SessionID]
+	if session == nil {
+		t.sessions[event.SessionID] = &sessionPath{last: path}
+		return
+	}
+	if session.last == path {
+		return
+	}
+	if session.step < MaxPathDepth {
+		key := pathTransition{step: session.step + 1, from: session.last, to: path}
+		if _, ok := t.transitions[key]; ok || len(t.transitions) < maxPathTransitions {
+			t.transitions[key]++
+		} else {
+			t.truncated = true
+		}
+	}
+	session.step++
+	session.last = path
+}
+
+// expire forgets the journeys of sessions that are no longer active; their counts are kept
+func (t *pathTracker) expire(active map[string]time.Time) {
+	for sessionID := range t.sessions {
+		if _, ok := active[sessionID]; !ok {
+			delete(t.sessions, sessionID)
+		}
+	}
+}
+
+// PathFlowQuery selects the transitions returned by GetPathFlow
+type PathFlowQuery struct {
+	Depth    int   // Steps from the entry page, 1 to MaxPathDepth
+	MinCount int64 // Transitions made by fewer sessions are left out
+	Limit    int   // Most common transitions returned per step
+}
+
+// ParsePathFlowQuery reads the depth, min_count and limit query parameters
+func ParsePathFlowQuery(values url.Values) (PathFlowQuery, error) {
+	q := PathFlowQuery{Depth: defaultPathDepth, MinCount: 1, Limit: defaultPathStepLimit}
+
+	if v := values.Get("depth"); v != "" {
+		depth, err := strconv.Atoi(v)
+		if err != nil || depth < 1 || depth > MaxPathDepth {
+			return q, fmt.Errorf("depth must be between 1 and %d", MaxPathDepth)
+		}
+		q.Depth = depth
+	}
+	if v := values.Get("min_count"); v != "" {
+		minCount, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || minCount < 1 {
+			return q, fmt.Errorf("min_count must be a positive integer")
+		}
+		q.MinCount = minCount
+	}
+	if v := values.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxPathStepLimit {
+			return q, fmt.Errorf("limit must be between 1 and %d", maxPathStepLimit)
+		}
+		q.Limit = limit
+	}
+	return q, nil
+}
+
+// GetPathFlow returns the most common transitions of each step up to the query's depth,
+// ordered by step and then by count
+func (s *Service) GetPathFlow(q PathFlowQuery) models.PathFlow {
+	s.analytics.Mu.RLock()
+	defer s.analytics.Mu.RUnlock()
+
+	flow := models.PathFlow{
+		Depth:       q.Depth,
+		MinCount:    q.MinCount,
+		Sessions:    s.entryExit.total,
+		Transitions: []models.PathTransition{},
+		Truncated:   s.paths.truncated,
+	}
+	steps := make(map[int][]models.PathTransition)
+	for key, count := range s.paths.transitions {
+		if key.step > q.Depth || count < q.MinCount {
+			continue
+		}
+		steps[key.step] = append(steps[key.step], models.PathTransition{Step: key.step, From: key.from, To: key.to, Count: count})
+	}
+
+	for step := 1; step <= q.Depth; step++ {
+		transitions := steps[step]
+		sort.Slice(transitions, func(i, j int) bool {
+			a, b := transitions[i], transitions[j]
+			if a.Count != b.Count {
+				return a.Count > b.Count
+			}
+			if a.From != b.From {
+				return a.From < b.From
+			}
+			return a.To < b.To
+		})
+		if len(transitions) > q.Limit {
+			transitions = transitions[:q.Limit]
+		}
+		flow.Transitions = append(flow.Transitions, transitions...)
+	}
+	return flow
+}
@@ -0,0 +1,60 @@
+package analytics
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+func TestPathFlow(t *testing.T) {
+	service := NewService()
+	now := time.Now()
+	views := []struct{ session, path string }{
+		{"s1", "/"}, {"s1", "/pricing"}, {"s1", "/pricing"}, {"s1", "/signup"},
+		{"s2", "/"}, {"s2", "/pricing"}, {"s2", "/docs"},
+		{"s3", "/blog"}, {"s3", "/pricing"},
+		{"", "/"}, // Without a session there is no journey
+	}
+	for _, view := range views {
+		service.ProcessEvent(&models.AnalyticsEvent{Type: models.PageView, Timestamp: now, SessionID: view.session, Path: view.path})
+	}
+
+	flow := service.GetPathFlow(PathFlowQuery{Depth: 2, MinCount: 1, Limit: 10})
+	want := []models.PathTransition{
+		{Step: 1, From: "/", To: "/pricing", Count: 2},
+		{Step: 1, From: "/blog", To: "/pricing", Count: 1},
+		{Step: 2, From: "/pricing", To: "/docs", Count: 1},
+		{Step: 2, From: "/pricing", To: "/signup", Count: 1},
+	}
+	if len(flow.Transitions) != len(want) {
+		t.Fatalf("expected %d transitions, got %+v", len(want), flow.Transitions)
+	}
+	for i := range want {
+		if flow.Transitions[i] != want[i] {
+			t.Errorf("transition %d: expected %+v, got %+v", i, want[i], flow.Transitions[i])
+		}
+	}
+	if flow.Sessions != 3 {
+		t.Errorf("expected 3 sessions, got %d", flow.Sessions)
+	}
+
+	// Depth and minimum count narrow the flow
+	flow = service.GetPathFlow(PathFlowQuery{Depth: 1, MinCount: 2, Limit: 10})
+	if len(flow.Transitions) != 1 || flow.Transitions[0].From != "/" {
+		t.Errorf("expected only / -> /pricing, got %+v", flow.Transitions)
+	}
+}
+
+func TestParsePathFlowQuery(t *testing.T) {
+	q, err := ParsePathFlowQuery(url.Values{"depth": {"5"}, "min_count": {"3"}})
+	if err != nil || q.Depth != 5 || q.MinCount != 3 || q.Limit != defaultPathStepLimit {
+		t.Errorf("unexpected query %+v (%v)", q, err)
+	}
+	for _, values := range []url.Values{{"depth": {"0"}}, {"depth": {"11"}}, {"min_count": {"0"}}, {"limit": {"x"}}} {
+		if _, err := ParsePathFlowQuery(values); err == nil {
+			t.Errorf("expected %v to be rejected", values)
+		}
+	}
+}
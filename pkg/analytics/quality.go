@@ -0,0 +1,43 @@
+package analytics
+
+import (
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/quality"
+)
+
+// qualityAlertMetrics are the alert metrics read from the data quality window rates
+var qualityAlertMetrics = map[string]func(models.QualityWindow) float64{
+	"malformed_rate":         func(w models.QualityWindow) float64 { return w.MalformedRate },
+	"incomplete_rate":        func(w models.QualityWindow) float64 { return w.IncompleteRate },
+	"timestamp_anomaly_rate": func(w models.QualityWindow) float64 { return w.TimestampAnomalyRate },
+	"duplicate_rate":         func(w models.QualityWindow) float64 { return w.DuplicateRate },
+}
+
+// SetQualityTracker reports the data quality recorded by tracker in snapshots, so alerts
+// can be set on its rates. Events are checked by whoever receives them, not by the
+// service; nil stops reporting.
+func (s *Service) SetQualityTracker(tracker *quality.Tracker) {
+	s.analytics.Mu.Lock()
+	defer s.analytics.Mu.Unlock()
+	s.quality = tracker
+}
+
+// getQualityStats returns the tracked data quality, or nil when none is tracked. The caller
+// must hold the analytics lock.
+func (s *Service) getQualityStats(now time.Time) *models.QualityStats {
+	if s.quality == nil {
+		return nil
+	}
+	stats := s.quality.Stats(now)
+	return &stats
+}
+
+// qualityMetricValue returns a data quality rate of the snapshot, 0 when not tracked
+func qualityMetricValue(snapshot *models.MetricsSnapshot, metric string) float64 {
+	if snapshot.Quality == nil {
+		return 0
+	}
+	return qualityAlertMetrics[metric](snapshot.Quality.Window)
+}
@@ -0,0 +1,349 @@
+package analytics
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+// Pagination limits for list queries; exports use MaxQueryLimit when no limit is given
+const (
+	defaultQueryLimit = 10
+	MaxQueryLimit     = 1000
+)
+
+// Query selects, filters and pages the items of a list endpoint
+type Query struct {
+	Limit      int
+	Offset     int
+	Sort       string // Field to sort by; empty uses the endpoint's default
+	Ascending  bool
+	PathPrefix string           // Pages and events: only paths with this prefix
+	EventType  models.EventType // Events: only this type
+	From, To   time.Time        // Events: only timestamps in [From, To); zero means unbounded
+	Dimension  string           // Devices: which breakdown to return
+	Browser    string           // Pages, devices and events: only this browser, case-insensitive
+	OS         string           // Pages, devices and events: only this operating system, case-insensitive
+}
+
+// agentFiltered reports whether the query is limited to a browser or OS
+func (q Query) agentFiltered() bool {
+	return q.Browser != "" || q.OS != ""
+}
+
+// matchesAgent reports whether a browser and OS pass the query's filters
+func (q Query) matchesAgent(browser, os string) bool {
+	return (q.Browser == "" || strings.EqualFold(browser, q.Browser)) && (q.OS == "" || strings.EqualFold(os, q.OS))
+}
+
+// Paged is one page of a sorted list together with the total number of matches
+type Paged[T any] struct {
+	Items  []T    `json:"items"`
+	Total  int    `json:"total"`
+	Limit  int    `json:"limit"`
+	Offset int    `json:"offset"`
+	Sort   string `json:"sort"`
+	Order  string `json:"order"`
+}
+
+// ParseQuery reads limit, offset, sort, order, path, type, from, to, dimension, browser and
+// os query parameters
+func ParseQuery(values url.Values) (Query, error) {
+	q := Query{Limit: defaultQueryLimit}
+
+	if v := values.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > MaxQueryLimit {
+			return q, fmt.Errorf("limit must be between 1 and %d", MaxQueryLimit)
+		}
+		q.Limit = limit
+	}
+	if v := values.Get("offset"); v != "" {
+		offset, err := strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			return q, fmt.Errorf("offset must be a non-negative integer")
+		}
+		q.Offset = offset
+	}
+
+	q.Sort = values.Get("sort")
+	switch order := values.Get("order"); order {
+	case "", "desc":
+	case "asc":
+		q.Ascending = true
+	default:
+		return q, fmt.Errorf("order must be asc or desc")
+	}
+
+	q.PathPrefix = values.Get("path")
+	q.EventType = models.EventType(values.Get("type"))
+	q.Dimension = values.Get("dimension")
+	q.Browser = values.Get("browser")
+	q.OS = values.Get("os")
+
+	for name, target := range map[string]*time.Time{"from": &q.From, "to": &q.To} {
+		if v := values.Get(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return q, fmt.Errorf("invalid %s: must be RFC3339", name)
+			}
+			*target = t
+		}
+	}
+	if !q.From.IsZero() && !q.To.IsZero() && !q.From.Before(q.To) {
+		return q, fmt.Errorf("from must be before to")
+	}
+
+	return q, nil
+}
+
+// sortField compares two items on one field; ties are broken by the item's name so pages are stable
+type sortField[T any] struct {
+	less func(a, b T) bool
+	name func(T) string
+}
+
+// paginate sorts items by the query's field and returns the requested page. Only the
+// items up to the end of the page are selected and sorted.
+func paginate[T any](items []T, q Query, fields map[string]sortField[T], defaultSort string) (Paged[T], error) {
+	sortName := q.Sort
+	if sortName == "" {
+		sortName = defaultSort
+	}
+	field, ok := fields[sortName]
+	if !ok {
+		names := make([]string, 0, len(fields))
+		for name := range fields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return Paged[T]{}, fmt.Errorf("cannot sort by %q (use %s)", sortName, strings.Join(names, ", "))
+	}
+
+	top := topN(items, q.Offset+q.Limit, func(x, y T) bool {
+		a, b := x, y
+		if q.Ascending {
+			a, b = b, a
+		}
+		if field.less(b, a) {
+			return true
+		}
+		if field.less(a, b) {
+			return false
+		}
+		return field.name(x) < field.name(y)
+	})
+
+	page := Paged[T]{
+		Items:  []T{},
+		Total:  len(items),
+		Limit:  q.Limit,
+		Offset: q.Offset,
+		Sort:   sortName,
+		Order:  "desc",
+	}
+	if q.Ascending {
+		page.Order = "asc"
+	}
+	if q.Offset < len(top) {
+		page.Items = top[q.Offset:]
+	}
+	return page, nil
+}
+
+// QueryPages returns a page of all tracked pages, by default sorted by views. Filtered by
+// browser or OS, pages count only the views made with them.
+func (s *Service) QueryPages(q Query) (Paged[models.PageMetric], error) {
+	s.analytics.Mu.RLock()
+	var pages []models.PageMetric
+	if q.agentFiltered() {
+		pages = s.agentPageMetrics(q)
+	} else {
+		pages = s.pageMetrics()
+	}
+	s.analytics.Mu.RUnlock()
+
+	if q.PathPrefix != "" {
+		filtered := pages[:0]
+		for _, page := range pages {
+			if strings.HasPrefix(page.Path, q.PathPrefix) {
+				filtered = append(filtered, page)
+			}
+		}
+		pages = filtered
+	}
+
+	byURL := func(p models.PageMetric) string { return p.URL }
+	return paginate(pages, q, map[string]sortField[models.PageMetric]{
+		"views":           {func(a, b models.PageMetric) bool { return a.Views < b.Views }, byURL},
+		"unique_visitors": {func(a, b models.PageMetric) bool { return a.UniqueVisitors < b.UniqueVisitors }, byURL},
+		"path":            {func(a, b models.PageMetric) bool { return a.Path < b.Path }, byURL},
+	}, "views")
+}
+
+// agentPageMetrics returns metrics for the pages viewed with the query's browser and OS.
+// Unique visitors and variants are not tracked by browser and OS, so they are left empty.
+// The caller must hold the analytics lock.
+func (s *Service) agentPageMetrics(q Query) []models.PageMetric {
+	views := make(map[string]int64)
+	for agent, pages := range s.analytics.AgentPageViews {
+		if !q.matchesAgent(agent.Browser, agent.OS) {
+			continue
+		}
+		for page, count := range pages {
+			views[page] += count
+		}
+	}
+
+	result := make([]models.PageMetric, 0, len(views))
+	for pageURL, count := range views {
+		metric := s.pageMetric(pageURL, count)
+		metric.UniqueVisitors, metric.Variants = 0, nil
+		result = append(result, metric)
+	}
+	return result
+}
+
+// QuerySources returns a page of all traffic sources, by default sorted by count
+func (s *Service) QuerySources(q Query) (Paged[models.TrafficSource], error) {
+	s.analytics.Mu.RLock()
+	sources := s.trafficSources()
+	s.analytics.Mu.RUnlock()
+
+	bySource := func(t models.TrafficSource) string { return t.Source }
+	return paginate(sources, q, map[string]sortField[models.TrafficSource]{
+		"count":  {func(a, b models.TrafficSource) bool { return a.Count < b.Count }, bySource},
+		"source": {func(a, b models.TrafficSource) bool { return a.Source < b.Source }, bySource},
+	}, "count")
+}
+
+// QueryDevices returns a page of one device breakdown: device (the default), browser,
+// browser_version, os or os_version. Filtered by browser or OS, the breakdown counts only
+// their events, e.g. the browser versions used on iOS.
+func (s *Service) QueryDevices(q Query) (Paged[models.DimensionCount], error) {
+	s.analytics.Mu.RLock()
+	var counts map[string]int64
+	switch {
+	case q.agentFiltered():
+		counts = s.agentCounts(q)
+	case q.Dimension == "" || q.Dimension == "device":
+		counts = copyCounts(s.analytics.DeviceTypes)
+	case q.Dimension == "browser":
+		counts = copyCounts(s.analytics.BrowserTypes)
+	case q.Dimension == "browser_version":
+		counts = copyCounts(s.analytics.BrowserVersions)
+	case q.Dimension == "os":
+		counts = copyCounts(s.analytics.OSTypes)
+	case q.Dimension == "os_version":
+		counts = copyCounts(s.analytics.OSVersions)
+	}
+	s.analytics.Mu.RUnlock()
+
+	if counts == nil {
+		return Paged[models.DimensionCount]{}, fmt.Errorf("unknown dimension %q (use device, browser, browser_version, os or os_version)", q.Dimension)
+	}
+
+	var total int64
+	for _, count := range counts {
+		total += count
+	}
+	items := make([]models.DimensionCount, 0, len(counts))
+	for name, count := range counts {
+		items = append(items, models.DimensionCount{
+			Name:    name,
+			Count:   count,
+			Percent: float64(count) / float64(total) * 100,
+		})
+	}
+
+	byName := func(d models.DimensionCount) string { return d.Name }
+	return paginate(items, q, map[string]sortField[models.DimensionCount]{
+		"count": {func(a, b models.DimensionCount) bool { return a.Count < b.Count }, byName},
+		"name":  {func(a, b models.DimensionCount) bool { return a.Name < b.Name }, byName},
+	}, "count")
+}
+
+// agentCounts returns the query's device breakdown counting only the user agents that
+// pass its browser and OS filters, or nil for an unknown dimension. The caller must hold
+// the analytics lock.
+func (s *Service) agentCounts(q Query) map[string]int64 {
+	var name func(models.Agent) string
+	switch q.Dimension {
+	case "", "device":
+		name = func(a models.Agent) string { return a.Device }
+	case "browser":
+		name = func(a models.Agent) string { return a.Browser }
+	case "browser_version":
+		name = func(a models.Agent) string { return versioned(a.Browser, a.BrowserVersion) }
+	case "os":
+		name = func(a models.Agent) string { return a.OS }
+	case "os_version":
+		name = func(a models.Agent) string { return versioned(a.OS, a.OSVersion) }
+	default:
+		return nil
+	}
+
+	counts := make(map[string]int64)
+	for agent, count := range s.analytics.AgentCounts {
+		if q.matchesAgent(agent.Browser, agent.OS) {
+			if key := name(agent); key != "" {
+				counts[key] += count
+			}
+		}
+	}
+	return counts
+}
+
+// versioned names a browser or OS version as the breakdowns do, e.g. "Chrome 120", or
+// returns "" without a version
+func versioned(name, version string) string {
+	if version == "" {
+		return ""
+	}
+	return name + " " + version
+}
+
+// QueryEvents returns a page of the recent events buffer, by default newest first.
+// Filtered by browser or OS, events without a user agent are left out.
+func (s *Service) QueryEvents(q Query) (Paged[models.RecentEvent], error) {
+	s.analytics.Mu.RLock()
+	var events []models.RecentEvent
+	for _, event := range s.analytics.Events {
+		if q.EventType != "" && event.Type != q.EventType {
+			continue
+		}
+		if q.agentFiltered() {
+			if event.UserAgent == "" {
+				continue
+			}
+			if agent := s.uaParser.Parse(event.UserAgent); !q.matchesAgent(agent.Browser, agent.OS) {
+				continue
+			}
+		}
+		if q.PathPrefix != "" && !strings.HasPrefix(event.Path, q.PathPrefix) {
+			continue
+		}
+		if (!q.From.IsZero() && event.Timestamp.Before(q.From)) || (!q.To.IsZero() && !event.Timestamp.Before(q.To)) {
+			continue
+		}
+		events = append(events, models.RecentEvent{
+			Timestamp: event.Timestamp,
+			Type:      event.Type,
+			URL:       event.URL,
+			UserID:    event.UserID,
+			Location:  EventLocation(&event),
+		})
+	}
+	s.analytics.Mu.RUnlock()
+
+	byURL := func(e models.RecentEvent) string { return e.URL }
+	return paginate(events, q, map[string]sortField[models.RecentEvent]{
+		"timestamp": {func(a, b models.RecentEvent) bool { return a.Timestamp.Before(b.Timestamp) }, byURL},
+		"type":      {func(a, b models.RecentEvent) bool { return a.Type < b.Type }, byURL},
+	}, "timestamp")
+}
@@ -0,0 +1,113 @@
+package analytics
+
+import (
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+func TestParseQuery(t *testing.T) {
+	q, err := ParseQuery(url.Values{"limit": {"5"}, "offset": {"10"}, "order": {"asc"}, "from": {"2024-01-01T00:00:00Z"}, "os": {"iOS"}})
+	if err != nil {
+		t.Fatalf("ParseQuery failed: %v", err)
+	}
+	if q.Limit != 5 || q.Offset != 10 || !q.Ascending || q.From.IsZero() || q.OS != "iOS" {
+		t.Errorf("unexpected query %+v", q)
+	}
+
+	for _, values := range []url.Values{
+		{"limit": {"0"}},
+		{"offset": {"-1"}},
+		{"order": {"sideways"}},
+		{"from": {"yesterday"}},
+		{"from": {"2024-01-02T00:00:00Z"}, "to": {"2024-01-01T00:00:00Z"}},
+	} {
+		if _, err := ParseQuery(values); err == nil {
+			t.Errorf("expected %v to be rejected", values)
+		}
+	}
+}
+
+func TestQueryPagesAndEvents(t *testing.T) {
+	service := NewService()
+	now := time.Now()
+	views := map[string]int{"/blog/a": 3, "/blog/b": 1, "/pricing": 2}
+	id := 0
+	for path, n := range views {
+		for i := 0; i < n; i++ {
+			id++
+			service.ProcessEvent(&models.AnalyticsEvent{
+				ID: strconv.Itoa(id), Type: models.PageView, Timestamp: now.Add(time.Duration(id) * time.Second),
+				URL: "https://example.com" + path, Path: path, UserID: "u1",
+			})
+		}
+	}
+
+	page, err := service.QueryPages(Query{Limit: 1, Offset: 1, PathPrefix: "/blog"})
+	if err != nil {
+		t.Fatalf("QueryPages failed: %v", err)
+	}
+	if page.Total != 2 || len(page.Items) != 1 || page.Items[0].Path != "/blog/b" {
+		t.Errorf("expected the second blog page to be /blog/b of 2, got %+v", page)
+	}
+
+	if _, err := service.QueryPages(Query{Limit: 10, Sort: "bogus"}); err == nil {
+		t.Error("expected an unknown sort field to be rejected")
+	}
+
+	events, err := service.QueryEvents(Query{Limit: 2, PathPrefix: "/pricing"})
+	if err != nil {
+		t.Fatalf("QueryEvents failed: %v", err)
+	}
+	if events.Total != 2 || !events.Items[0].Timestamp.After(events.Items[1].Timestamp) {
+		t.Errorf("expected 2 pricing events newest first, got %+v", events)
+	}
+
+	events, _ = service.QueryEvents(Query{Limit: 10, From: now.Add(100 * time.Second)})
+	if events.Total != 0 {
+		t.Errorf("expected no events after the from bound, got %d", events.Total)
+	}
+}
+
+func TestQueryFilteredByBrowserAndOS(t *testing.T) {
+	const iPhoneSafari = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.1 Mobile/15E148 Safari/604.1"
+	service := NewService()
+	now := time.Now()
+	for i, event := range []struct {
+		path, userAgent string
+	}{
+		{"/", edgeUserAgent},
+		{"/pricing", edgeUserAgent},
+		{"/pricing", iPhoneSafari},
+		{"/pricing", ""},
+	} {
+		service.ProcessEvent(&models.AnalyticsEvent{
+			ID: strconv.Itoa(i), Type: models.PageView, Timestamp: now, UserID: "u1",
+			URL: "https://example.com" + event.path, Path: event.path, UserAgent: event.userAgent,
+		})
+	}
+
+	pages, err := service.QueryPages(Query{Limit: 10, OS: "windows"})
+	if err != nil {
+		t.Fatalf("QueryPages failed: %v", err)
+	}
+	if pages.Total != 2 || pages.Items[0].Views != 1 || pages.Items[0].UniqueVisitors != 0 {
+		t.Errorf("expected 2 pages with one Windows view each, got %+v", pages)
+	}
+
+	versions, _ := service.QueryDevices(Query{Limit: 10, Dimension: "browser_version", OS: "iOS"})
+	if versions.Total != 1 || versions.Items[0].Name != "Safari 17" || versions.Items[0].Percent != 100 {
+		t.Errorf("expected only Safari 17 on iOS, got %+v", versions)
+	}
+	if _, err := service.QueryDevices(Query{Limit: 10, Dimension: "bogus", Browser: "Edge"}); err == nil {
+		t.Error("expected an unknown dimension to be rejected when filtered")
+	}
+
+	events, _ := service.QueryEvents(Query{Limit: 10, Browser: "Safari", PathPrefix: "/pricing"})
+	if events.Total != 1 {
+		t.Errorf("expected one Safari event, got %d", events.Total)
+	}
+}
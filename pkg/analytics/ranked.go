@@ -0,0 +1,143 @@
+package analytics
+
+// rankedCounts keeps counts ranked as they change, so the largest can be listed without
+// looking at every key. It is a max-heap ordered by rankedBefore with an index of each
+// key's position: updating a count is O(log keys) and listing the top n is O(n log n).
+type rankedCounts struct {
+	heap  []countEntry
+	index map[string]int // Key -> position in heap
+	total int64          // Sum of all counts
+}
+
+// countEntry is one key of a count map with its count
+type countEntry struct {
+	key   string
+	count int64
+}
+
+func newRankedCounts() *rankedCounts {
+	return &rankedCounts{index: make(map[string]int)}
+}
+
+// rankedBefore orders entries largest count first, with ties by key
+func rankedBefore(a, b countEntry) bool {
+	if a.count != b.count {
+		return a.count > b.count
+	}
+	return a.key < b.key
+}
+
+// add changes the count of key by delta. Keys whose count drops to zero are removed.
+func (r *rankedCounts) add(key string, delta int64) {
+	i, ok := r.index[key]
+	if !ok {
+		if delta <= 0 {
+			return
+		}
+		r.total += delta
+		r.heap = append(r.heap, countEntry{key, delta})
+		r.index[key] = len(r.heap) - 1
+		r.up(len(r.heap) - 1)
+		return
+	}
+
+	r.total += delta
+	r.heap[i].count += delta
+	if r.heap[i].count <= 0 {
+		r.total -= r.heap[i].count
+		r.remove(i)
+		return
+	}
+	if delta > 0 {
+		r.up(i)
+	} else {
+		r.down(i)
+	}
+}
+
+// top returns the n largest counts, largest first with ties by key. It walks the heap
+// from the root, keeping the children of the entries taken so far as candidates.
+func (r *rankedCounts) top(n int) []countEntry {
+	n = min(n, len(r.heap))
+	if n <= 0 {
+		return []countEntry{}
+	}
+
+	result := make([]countEntry, 0, n)
+	candidates := []int{0} // Heap positions, as a heap ordered by their entries
+	before := func(a, b int) bool { return rankedBefore(r.heap[a], r.heap[b]) }
+	for len(result) < n {
+		i := candidates[0]
+		result = append(result, r.heap[i])
+
+		last := len(candidates) - 1
+		candidates[0] = candidates[last]
+		candidates = candidates[:last]
+		if len(candidates) > 0 {
+			siftDown(candidates, 0, after(before))
+		}
+		for _, child := range []int{2*i + 1, 2*i + 2} {
+			if child < len(r.heap) {
+				candidates = append(candidates, child)
+				siftUp(candidates, len(candidates)-1, after(before))
+			}
+		}
+	}
+	return result
+}
+
+// after reverses an order, so the generic heap helpers, which keep the item that sorts
+// last at the root, keep the one that sorts first there instead
+func after[T any](before func(a, b T) bool) func(a, b T) bool {
+	return func(a, b T) bool { return before(b, a) }
+}
+
+// remove deletes the entry at position i
+func (r *rankedCounts) remove(i int) {
+	delete(r.index, r.heap[i].key)
+	last := len(r.heap) - 1
+	if i != last {
+		r.heap[i] = r.heap[last]
+		r.index[r.heap[i].key] = i
+	}
+	r.heap = r.heap[:last]
+	if i < len(r.heap) {
+		r.up(i)
+		r.down(i)
+	}
+}
+
+// up moves the entry at i towards the root while it ranks above its parent
+func (r *rankedCounts) up(i int) {
+	for i > 0 {
+		parent := (i - 1) / 2
+		if !rankedBefore(r.heap[i], r.heap[parent]) {
+			return
+		}
+		r.swap(i, parent)
+		i = parent
+	}
+}
+
+// down moves the entry at i away from the root while a child ranks above it
+func (r *rankedCounts) down(i int) {
+	for {
+		first := i
+		for _, child := range []int{2*i + 1, 2*i + 2} {
+			if child < len(r.heap) && rankedBefore(r.heap[child], r.heap[first]) {
+				first = child
+			}
+		}
+		if first == i {
+			return
+		}
+		r.swap(i, first)
+		i = first
+	}
+}
+
+func (r *rankedCounts) swap(i, j int) {
+	r.heap[i], r.heap[j] = r.heap[j], r.heap[i]
+	r.index[r.heap[i].key] = i
+	r.index[r.heap[j].key] = j
+}
@@ -0,0 +1,45 @@
+package analytics
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestRankedCountsMatchesSort(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	ranked := newRankedCounts()
+	counts := make(map[string]int64)
+	for i := 0; i < 5000; i++ {
+		key := fmt.Sprintf("k%d", rng.Intn(300))
+		delta := int64(rng.Intn(5)) - 1 // Mostly increments, with some decrements
+		ranked.add(key, delta)
+		if counts[key]+delta <= 0 {
+			delete(counts, key)
+		} else {
+			counts[key] += delta
+		}
+	}
+
+	var want []countEntry
+	var total int64
+	for key, count := range counts {
+		want = append(want, countEntry{key, count})
+		total += count
+	}
+	sort.Slice(want, func(i, j int) bool { return rankedBefore(want[i], want[j]) })
+
+	for _, n := range []int{0, 1, 10, len(want), len(want) + 10} {
+		top := ranked.top(n)
+		if fmt.Sprint(top) != fmt.Sprint(want[:min(n, len(want))]) {
+			t.Errorf("top(%d) = %v, want %v", n, top, want[:min(n, len(want))])
+		}
+	}
+	if ranked.total != total {
+		t.Errorf("expected a total of %d, got %d", total, ranked.total)
+	}
+	if len(ranked.index) != len(counts) {
+		t.Errorf("expected %d ranked keys, got %d", len(counts), len(ranked.index))
+	}
+}
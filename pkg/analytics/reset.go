@@ -0,0 +1,118 @@
+package analytics
+
+import (
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/hll"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+// Reset discards all collected analytics while keeping the configuration: retention,
+// alert rules, goals, custom metric rules, the bot policy and counting settings. Active
+// alerts are kept and resolve on the next check if their condition no longer holds.
+// Hours that started before the reset are no longer complete; see CompleteSince. The
+// shared counters are cleared as well, for every replica.
+func (s *Service) Reset() {
+	// Clear the shared state outside the analytics lock
+	if state := s.resetLocal(); state != nil {
+		s.resetShared(state)
+	}
+}
+
+// resetLocal discards the in-memory analytics and returns the shared state, if any
+func (s *Service) resetLocal() SharedState {
+	s.analytics.Mu.Lock()
+	defer s.analytics.Mu.Unlock()
+
+	s.analytics.Reset()
+	s.analytics.UniqueUsers = hll.New(s.uniques)
+	s.completeSince = time.Now()
+	s.eventTime = eventClock{config: s.eventTime.config, maxEventTime: s.eventTime.maxEventTime}
+
+	s.campaigns = make(map[string]*campaign)
+	s.userCampaigns = make(map[string]string)
+	s.loadTimes = NewQuantileSketch()
+	s.slowPages = 0
+	s.fastPages = 0
+	s.commerce = newCommerceTracker(s.commerce.currency)
+	s.heatmaps = newHeatmapTracker(s.heatmaps.gridSize, s.heatmaps.maxPages)
+	s.errors = newErrorTracker()
+	s.visitors = newVisitorTracker()
+	// Erasure still has to find the sessions whose replays outlive the reset
+	sessions := newSessionTracker()
+	sessions.byUser = s.sessions.byUser
+	s.sessions = sessions
+	for _, state := range s.goals {
+		*state = goalState{goal: state.goal}
+	}
+	s.entryExit = newEntryExitTracker()
+	s.pageRanks = newRankedCounts()
+	s.sourceRanks = newRankedCounts()
+	s.foreignHosts = make(map[string]int64)
+	s.pageVariants = make(map[string]map[string]int64)
+	s.paths = newPathTracker()
+	s.identities = newIdentityTracker()
+	s.channelCounts = make(map[string]int64)
+	s.alertWindows.buckets = make(map[string]map[int64]*alertBucket)
+
+	bots := newBotTracker()
+	bots.policy, bots.detector = s.bots.policy, s.bots.detector
+	s.bots = bots
+
+	for _, metric := range s.customMetrics {
+		metric.total = 0
+		metric.values = make(map[string]float64)
+		metric.users = make(map[string]bool)
+	}
+
+	s.experiments.mu.Lock()
+	defer s.experiments.mu.Unlock()
+	s.experiments.experiments = make(map[string]*experiment)
+	s.experiments.byGoal = make(map[models.Goal][]string)
+	return s.shared
+}
+
+// CompleteSince returns the time from which the service has seen every event: the last
+// reset, or the start of a rebuild's replay. It is zero if the service was never reset.
+// Rollups of hours starting earlier are partial and must not replace persisted ones.
+func (s *Service) CompleteSince() time.Time {
+	s.analytics.Mu.RLock()
+	defer s.analytics.Mu.RUnlock()
+	return s.completeSince
+}
+
+// SetCompleteSince records that the service holds every event since t, e.g. once a
+// rebuild has replayed all events from t
+func (s *Service) SetCompleteSince(t time.Time) {
+	s.analytics.Mu.Lock()
+	defer s.analytics.Mu.Unlock()
+	s.completeSince = t
+}
+
+// RestoreRollups loads persisted hourly rollups into the hourly metrics for hours within
+// the retention window that hold no data yet. Their unique user and session counts are
+// reported as persisted until new events arrive for the hour, after which only the
+// distinct users and sessions seen since the restore are counted.
+func (s *Service) RestoreRollups(rollups []models.Rollup) int {
+	s.analytics.Mu.Lock()
+	defer s.analytics.Mu.Unlock()
+
+	cutoff := time.Now().Add(-s.retention.HourlyWindow).Truncate(time.Hour).Unix()
+	restored := 0
+	for _, rollup := range rollups {
+		hour := rollup.Start.Truncate(time.Hour).Unix()
+		if rollup.Granularity != models.GranularityHour || hour < cutoff || s.analytics.HourlyRollups[hour] != nil {
+			continue
+		}
+
+		copied := rollup
+		copied.EventsByType = make(map[models.EventType]int64, len(rollup.EventsByType))
+		for eventType, count := range rollup.EventsByType {
+			copied.EventsByType[eventType] = count
+		}
+		s.analytics.HourlyRollups[hour] = &copied
+		s.analytics.HourlyData[hour] = rollup.Events
+		restored++
+	}
+	return restored
+}
@@ -0,0 +1,77 @@
+package analytics
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/store"
+)
+
+func TestResetKeepsConfiguration(t *testing.T) {
+	service := NewService()
+	service.AddAlert(models.AlertConfig{Name: "traffic", Metric: "events_per_minute", Threshold: 100, Operator: "gt", Enabled: true})
+	service.ProcessEvent(&models.AnalyticsEvent{ID: "1", Type: models.PageView, Timestamp: time.Now(), UserID: "u1", SessionID: "s1", URL: "https://example.com/"})
+
+	service.Reset()
+
+	snapshot := service.GetSnapshot()
+	if snapshot.TotalEvents != 0 || snapshot.UniqueUsers != 0 || snapshot.ActiveSessions != 0 || len(snapshot.RealTimeEvents) != 0 {
+		t.Errorf("expected empty analytics after reset, got %d events, %d users, %d sessions", snapshot.TotalEvents, snapshot.UniqueUsers, snapshot.ActiveSessions)
+	}
+	if _, ok := service.AlertConfig("traffic"); !ok {
+		t.Errorf("expected alert rules to survive the reset")
+	}
+	if service.CompleteSince().IsZero() {
+		t.Errorf("expected the reset to record when counting restarted")
+	}
+
+	service.ProcessEvent(&models.AnalyticsEvent{ID: "2", Type: models.PageView, Timestamp: time.Now(), UserID: "u2", URL: "https://example.com/"})
+	if snapshot := service.GetSnapshot(); snapshot.TotalEvents != 1 || snapshot.UniqueUsers != 1 {
+		t.Errorf("expected counting to resume after reset, got %d events and %d users", snapshot.TotalEvents, snapshot.UniqueUsers)
+	}
+}
+
+func TestHistoryAfterReset(t *testing.T) {
+	ctx := context.Background()
+	service := NewService()
+	st := store.NewMemoryStore()
+	history := NewHistory(service, st)
+
+	now := time.Now().UTC()
+	earlier := now.Truncate(time.Hour).Add(-2 * time.Hour)
+	service.ProcessEvent(&models.AnalyticsEvent{Type: models.Click, Timestamp: earlier, UserID: "u1"})
+	service.ProcessEvent(&models.AnalyticsEvent{Type: models.Click, Timestamp: now, UserID: "u1"})
+	if err := history.Flush(ctx); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	// The current hour is partial after the reset and must not overwrite its rollup
+	service.Reset()
+	service.ProcessEvent(&models.AnalyticsEvent{Type: models.Click, Timestamp: now, UserID: "u2"})
+	if err := history.Flush(ctx); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	stored, err := st.QueryRollups(ctx, models.GranularityHour, now.Truncate(time.Hour), now.Truncate(time.Hour).Add(time.Hour))
+	if err != nil {
+		t.Fatalf("QueryRollups failed: %v", err)
+	}
+	if len(stored) != 1 || stored[0].Events != 1 || stored[0].UniqueUsers != 1 {
+		t.Fatalf("Expected the persisted rollup to be kept, got %+v", stored)
+	}
+
+	// Restoring fills only the hours with no live data
+	restored, err := history.Restore(ctx)
+	if err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if restored != 1 {
+		t.Fatalf("Expected one restored hour, got %d", restored)
+	}
+	for _, rollup := range service.GetHourlyRollups() {
+		if rollup.Start.Equal(earlier) && (rollup.Events != 1 || rollup.UniqueUsers != 1) {
+			t.Errorf("Restored hour: got events=%d users=%d, want 1 and 1", rollup.Events, rollup.UniqueUsers)
+		}
+	}
+}
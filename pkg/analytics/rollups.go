@@ -0,0 +1,208 @@
+package analytics
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/logging"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+// rollupLookback is how far back each run of the rollup job recomputes daily rollups,
+// so hours corrected by late events or flushed late are included
+const rollupLookback = 3 * 24 * time.Hour
+
+// RollupRetention is how long persisted rollups of each granularity are kept; zero
+// keeps them forever. Expiry waits for whole coarser buckets, so hours are removed a
+// day at a time and days a month (and week) at a time, once their summaries are stored.
+type RollupRetention struct {
+	Hour  time.Duration
+	Day   time.Duration
+	Week  time.Duration
+	Month time.Duration
+}
+
+// of returns the retention of the granularity
+func (r RollupRetention) of(granularity models.Granularity) time.Duration {
+	switch granularity {
+	case models.GranularityHour:
+		return r.Hour
+	case models.GranularityDay:
+		return r.Day
+	case models.GranularityWeek:
+		return r.Week
+	case models.GranularityMonth:
+		return r.Month
+	}
+	return 0
+}
+
+// cutoff returns the time before which rollups of the granularity have expired, or the
+// zero time when they are kept forever. Hours expire by whole days, and days by whole
+// months and the weeks containing them, so coarser buckets are never partly expired.
+func (r RollupRetention) cutoff(granularity models.Granularity, now time.Time) time.Time {
+	retention := r.of(granularity)
+	if retention <= 0 {
+		return time.Time{}
+	}
+	expired := now.Add(-retention)
+	switch granularity {
+	case models.GranularityHour:
+		return models.GranularityDay.Start(expired)
+	case models.GranularityDay:
+		month := models.GranularityMonth.Start(expired)
+		if week := models.GranularityWeek.Start(month); week.Before(month) {
+			return week
+		}
+		return month
+	default:
+		return granularity.Start(expired)
+	}
+}
+
+// RollupStatus reports the rollup job's last run
+type RollupStatus struct {
+	LastRun   time.Time                    `json:"last_run,omitempty"`
+	LastError string                       `json:"last_error,omitempty"`
+	Saved     map[models.Granularity]int   `json:"saved"`   // Rollups written by the last run
+	Expired   map[models.Granularity]int   `json:"expired"` // Rollups deleted by the last run
+	Retention map[models.Granularity]int64 `json:"retention_days"`
+}
+
+// SetRetention sets how long persisted rollups are kept by the rollup job. It must be
+// called before the history is used.
+func (h *History) SetRetention(retention RollupRetention) {
+	h.retention = retention
+}
+
+// RollUp stores daily, weekly and monthly summaries of the persisted hourly rollups and
+// deletes rollups past their retention. Summaries of the last few days, and of the
+// weeks and months containing them, are recomputed on every run; the first run covers
+// every stored hour. Summaries of buckets still in progress are replaced as they grow.
+func (h *History) RollUp(ctx context.Context, now time.Time) error {
+	h.rollupsMu.Lock()
+	defer h.rollupsMu.Unlock()
+
+	status := RollupStatus{
+		LastRun:   now,
+		Saved:     make(map[models.Granularity]int),
+		Expired:   make(map[models.Granularity]int),
+		Retention: make(map[models.Granularity]int64),
+	}
+	err := h.rollUp(ctx, now, &status)
+	if err != nil {
+		status.LastError = err.Error()
+	}
+	h.rollups = status
+	return err
+}
+
+func (h *History) rollUp(ctx context.Context, now time.Time, status *RollupStatus) error {
+	from := now.Add(-rollupLookback)
+	if !h.rolledUp {
+		stored, err := h.store.QueryRollups(ctx, models.GranularityHour, time.Time{}, from)
+		if err != nil {
+			return fmt.Errorf("failed to read hourly rollups: %w", err)
+		}
+		if len(stored) > 0 {
+			from = stored[0].Start
+		}
+	}
+
+	// Days first, since weeks and months are summed from them
+	for _, granularity := range []models.Granularity{models.GranularityDay, models.GranularityWeek, models.GranularityMonth} {
+		changed, err := h.summarize(ctx, granularity, granularity.Start(from), now)
+		if err != nil {
+			return fmt.Errorf("failed to compute %s rollups: %w", granularity, err)
+		}
+		if err := h.store.SaveRollups(ctx, changed); err != nil {
+			return fmt.Errorf("failed to persist %s rollups: %w", granularity, err)
+		}
+		status.Saved[granularity] = len(changed)
+	}
+	h.rolledUp = true
+
+	for _, granularity := range []models.Granularity{models.GranularityHour, models.GranularityDay, models.GranularityWeek, models.GranularityMonth} {
+		status.Retention[granularity] = int64(h.retention.of(granularity) / (24 * time.Hour))
+		cutoff := h.retention.cutoff(granularity, now)
+		if cutoff.IsZero() {
+			continue
+		}
+		deleted, err := h.store.DeleteRollups(ctx, granularity, cutoff)
+		if err != nil {
+			return fmt.Errorf("failed to expire %s rollups: %w", granularity, err)
+		}
+		status.Expired[granularity] = deleted
+		if deleted > 0 {
+			logging.Info("Expired rollups", "granularity", granularity, "rollups", deleted, "before", cutoff)
+		}
+	}
+	return nil
+}
+
+// summarize sums the finer rollups into buckets of the granularity from start until now.
+// Stored buckets whose finer rollups have started to expire are final and left alone.
+func (h *History) summarize(ctx context.Context, granularity models.Granularity, start, now time.Time) ([]models.Rollup, error) {
+	finer := finerGranularity(granularity)
+	final := make(map[int64]bool)
+	if cutoff := h.retention.cutoff(finer, now); start.Before(cutoff) {
+		stored, err := h.store.QueryRollups(ctx, granularity, start, cutoff)
+		if err != nil {
+			return nil, err
+		}
+		for _, rollup := range stored {
+			final[rollup.Start.Unix()] = true
+		}
+	}
+
+	var parts map[int64]models.Rollup
+	if finer == models.GranularityHour {
+		hours, err := h.hours(ctx, start, now)
+		if err != nil {
+			return nil, err
+		}
+		parts = hours
+	} else {
+		days, err := h.buckets(ctx, start, now, finer, now)
+		if err != nil {
+			return nil, err
+		}
+		parts = make(map[int64]models.Rollup, len(days))
+		for _, rollup := range days {
+			parts[rollup.Start.Unix()] = rollup
+		}
+	}
+
+	var changed []models.Rollup
+	for _, rollup := range bucketRollups(parts, finer, start, now, granularity) {
+		if rollup.Events > 0 && !final[rollup.Start.Unix()] {
+			changed = append(changed, rollup)
+		}
+	}
+	return changed, nil
+}
+
+// RollupStatus returns the result of the rollup job's last run
+func (h *History) RollupStatus() RollupStatus {
+	h.rollupsMu.Lock()
+	defer h.rollupsMu.Unlock()
+	return h.rollups
+}
+
+// RunRollups runs the rollup job every interval until the context is cancelled
+func (h *History) RunRollups(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := h.RollUp(ctx, time.Now()); err != nil && ctx.Err() == nil {
+			logging.Error("Rollup job failed", "error", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
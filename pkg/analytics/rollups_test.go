@@ -0,0 +1,125 @@
+package analytics
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/store"
+)
+
+func TestGranularityBuckets(t *testing.T) {
+	at := time.Date(2024, 3, 15, 13, 45, 0, 0, time.UTC) // A Friday
+	for _, tc := range []struct {
+		granularity models.Granularity
+		start       time.Time
+		next        time.Time
+	}{
+		{models.GranularityHour, time.Date(2024, 3, 15, 13, 0, 0, 0, time.UTC), time.Date(2024, 3, 15, 14, 0, 0, 0, time.UTC)},
+		{models.GranularityDay, time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 16, 0, 0, 0, 0, time.UTC)},
+		{models.GranularityWeek, time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 18, 0, 0, 0, 0, time.UTC)},
+		{models.GranularityMonth, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)},
+	} {
+		start := tc.granularity.Start(at)
+		if !start.Equal(tc.start) || !tc.granularity.Next(start).Equal(tc.next) {
+			t.Errorf("%s: expected [%s, %s), got [%s, %s)", tc.granularity, tc.start, tc.next, start, tc.granularity.Next(start))
+		}
+	}
+	if sunday := time.Date(2024, 3, 17, 23, 0, 0, 0, time.UTC); !models.GranularityWeek.Start(sunday).Equal(time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC)) {
+		t.Error("expected Sunday in the week starting the Monday before")
+	}
+}
+
+func TestRollupRetentionCutoff(t *testing.T) {
+	now := time.Date(2024, 3, 15, 13, 0, 0, 0, time.UTC)
+	retention := RollupRetention{Hour: 7 * 24 * time.Hour, Day: 40 * 24 * time.Hour, Month: 365 * 24 * time.Hour}
+
+	if cutoff := retention.cutoff(models.GranularityHour, now); !cutoff.Equal(time.Date(2024, 3, 8, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected hours expiring by whole days, got %s", cutoff)
+	}
+	// Forty days back is in February, which starts on a Thursday
+	if cutoff := retention.cutoff(models.GranularityDay, now); !cutoff.Equal(time.Date(2024, 1, 29, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected days expiring by the week containing the month's start, got %s", cutoff)
+	}
+	if cutoff := retention.cutoff(models.GranularityWeek, now); !cutoff.IsZero() {
+		t.Errorf("expected weeks kept forever, got %s", cutoff)
+	}
+	if cutoff := retention.cutoff(models.GranularityMonth, now); !cutoff.Equal(time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected months expiring by whole months, got %s", cutoff)
+	}
+}
+
+func TestRollUpSummarizesAndExpires(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemoryStore()
+	history := NewHistory(NewService(), st)
+	history.SetRetention(RollupRetention{Hour: 10 * 24 * time.Hour})
+
+	// One event an hour after midnight on each of the last 60 days
+	now := time.Now().UTC()
+	today := models.GranularityDay.Start(now)
+	var hours []models.Rollup
+	for days := 1; days <= 60; days++ {
+		hours = append(hours, models.Rollup{
+			Start:        today.AddDate(0, 0, -days).Add(time.Hour),
+			Granularity:  models.GranularityHour,
+			Events:       1,
+			PageViews:    1,
+			UniqueUsers:  1,
+			Sessions:     1,
+			EventsByType: map[models.EventType]int64{models.PageView: 1},
+		})
+	}
+	if err := st.SaveRollups(ctx, hours); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := history.RollUp(ctx, now); err != nil {
+		t.Fatalf("RollUp failed: %v", err)
+	}
+	status := history.RollupStatus()
+	if status.Saved[models.GranularityDay] != 60 || status.Saved[models.GranularityMonth] < 2 || status.Expired[models.GranularityHour] == 0 {
+		t.Errorf("expected 60 days stored and old hours expired, got %+v", status)
+	}
+	remaining, _ := st.QueryRollups(ctx, models.GranularityHour, time.Time{}, now)
+	if cutoff := models.GranularityDay.Start(now.Add(-10 * 24 * time.Hour)); len(remaining) == 0 || remaining[0].Start.Before(cutoff) {
+		t.Errorf("expected hours before %s expired, got %d hours left", cutoff, len(remaining))
+	}
+
+	// Expired hours are answered from the stored summaries, before and after another run
+	for run := 0; run < 2; run++ {
+		for _, granularity := range []models.Granularity{models.GranularityDay, models.GranularityWeek, models.GranularityMonth} {
+			rollups, err := history.Query(ctx, now.AddDate(0, 0, -61), now, granularity)
+			if err != nil {
+				t.Fatalf("%s query failed: %v", granularity, err)
+			}
+			var events, pageViews int64
+			for _, rollup := range rollups {
+				events += rollup.Events
+				pageViews += rollup.EventsByType[models.PageView]
+				if rollup.UniqueUsers != 0 || rollup.Sessions != 0 {
+					t.Errorf("run %d, %s: expected no distinct counts in coarse buckets, got %+v", run, granularity, rollup)
+				}
+			}
+			if events != 60 || pageViews != 60 {
+				t.Errorf("run %d, %s: expected 60 events, got %d (%d page views)", run, granularity, events, pageViews)
+			}
+		}
+		if err := history.RollUp(ctx, now); err != nil {
+			t.Fatalf("RollUp failed: %v", err)
+		}
+	}
+}
+
+func TestHistoryQueryRangeLimits(t *testing.T) {
+	history := NewHistory(NewService(), store.NewMemoryStore())
+	now := time.Now()
+
+	if _, err := history.Query(context.Background(), now.AddDate(-2, 0, 0), now, models.GranularityDay); err == nil {
+		t.Error("expected daily queries over two years rejected")
+	}
+	if _, err := history.Query(context.Background(), now.AddDate(-2, 0, 0), now, models.GranularityWeek); err != nil {
+		t.Errorf("expected two years of weeks accepted, got %v", err)
+	}
+}
@@ -1,120 +1,524 @@
 package analytics
 
 import (
-	"fmt"
+	"context"
+	"math"
 	"net/url"
 	"sort"
-	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/bots"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/errs"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/features"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/hll"
 	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/quality"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/useragent"
 )
 
-// Service handles real-time analytics processing and aggregation
+// Page loads slower than this many milliseconds count as slow
+const slowPageThreshold = 3000
+
+// RetentionConfig controls how long the service keeps in-memory data. Load time
+// percentiles use a fixed-size sketch and need no retention.
+type RetentionConfig struct {
+	RecentEvents    int           // Events kept for the real-time feed
+	EventTTL        time.Duration // Recent events older than this are dropped; 0 keeps them until pushed out
+	HourlyWindow    time.Duration // Hourly counts and rollups older than this are dropped
+	SessionTimeout  time.Duration // Sessions inactive this long stop counting as active
+	CleanupInterval time.Duration // How often RunCleanup expires data
+}
+
+// DefaultRetention returns the default retention settings
+func DefaultRetention() RetentionConfig {
+	return RetentionConfig{
+		RecentEvents:    100,
+		HourlyWindow:    48 * time.Hour,
+		SessionTimeout:  30 * time.Minute,
+		CleanupInterval: 5 * time.Minute,
+	}
+}
+
+// withDefaults replaces unset or invalid values with the defaults
+func (c RetentionConfig) withDefaults() RetentionConfig {
+	defaults := DefaultRetention()
+	if c.RecentEvents <= 0 {
+		c.RecentEvents = defaults.RecentEvents
+	}
+	if c.EventTTL < 0 {
+		c.EventTTL = 0
+	}
+	if c.HourlyWindow <= 0 {
+		c.HourlyWindow = defaults.HourlyWindow
+	}
+	if c.SessionTimeout <= 0 {
+		c.SessionTimeout = defaults.SessionTimeout
+	}
+	if c.CleanupInterval <= 0 {
+		c.CleanupInterval = defaults.CleanupInterval
+	}
+	return c
+}
+
+// Service handles real-time analytics processing and aggregation.
+//
+// Collected data is guarded by the analytics lock (analytics.Mu), and configuration
+// (alert rules and state, dashboards) by mu. Code needing both takes mu first and never
+// takes mu while holding the analytics lock. Snapshots and other results are copied out
+// under the lock, so callers use them without holding it. Alert evaluation is serialized
+// by alertCheckMu, taken before either, and reads the data without holding mu so slow
+// snapshots (e.g. shared state reads) do not block configuration reads.
 type Service struct {
-	analytics *models.RealTimeAnalytics
-	alerts    []models.AlertConfig
-	mu        sync.RWMutex
+	analytics    *models.RealTimeAnalytics
+	retention    RetentionConfig
+	alerts       []models.AlertConfig
+	activeAlerts map[string]*alertState // Alert config name -> active alert
+	alertHistory []models.Alert         // Resolved alerts, oldest first
+	recentAlerts []models.Alert         // Latest state of recently fired alerts, oldest first
+	alertWindows *alertWindowTracker    // Per-minute counts for per-path and relative alerts, guarded by the analytics lock
+
+	// Handlers notified of alerts evaluated by RunAlerts, guarded by alertSubsMu
+	alertSubs    alertSubscribers
+	alertSubsMu  sync.Mutex
+	experiments  *experimentTracker
+	uaParser     useragent.Parser // Guarded by the analytics lock
+	campaignGoal models.Goal      // Guarded by the analytics lock
+	uniques      hll.Config       // Settings for distinct user and session counters, guarded by the analytics lock
+	features     *features.Flags  // Gates experimental processing paths, guarded by the analytics lock
+
+	// User-defined dashboards, guarded by s.mu
+	dashboards []models.Dashboard
+
+	// Time from which every event has been seen, guarded by the analytics lock
+	completeSince time.Time
+
+	// Event-time watermark and late events, guarded by the analytics lock
+	eventTime eventClock
+
+	// Fraction of sample weight not yet counted, guarded by the analytics lock
+	sampleRemainder float64
+
+	// Campaign attribution, guarded by the analytics lock
+	campaigns     map[string]*campaign // Campaign key -> aggregated performance
+	userCampaigns map[string]string    // User ID -> last campaign key
+
+	// Conversion goals and their completions, guarded by the analytics lock
+	goals []*goalState
+
+	// Handlers notified of goal completions, guarded by goalSubsMu
+	goalSubs   goalSubscribers
+	goalSubsMu sync.Mutex
+
+	// Page load times, guarded by the analytics lock
+	loadTimes *QuantileSketch
+	slowPages int64 // Page views slower than slowPageThreshold
+	fastPages int64
+
+	// E-commerce revenue, guarded by the analytics lock
+	commerce *commerceTracker
+
+	// Click heatmaps, guarded by the analytics lock
+	heatmaps *heatmapTracker
+
+	// Error analytics, guarded by the analytics lock
+	errors *errorTracker
+
+	// Bot detection and bot traffic counts, guarded by the analytics lock
+	bots *botTracker
+
+	// Data quality of received events reported in snapshots, guarded by the analytics lock;
+	// nil when not tracked
+	quality *quality.Tracker
+
+	// Pipeline latency reported in snapshots, guarded by the analytics lock; nil when not
+	// tracked
+	latency *LatencyTracker
+
+	// Recently active visitors, guarded by the analytics lock
+	visitors *visitorTracker
+
+	// Session lengths, guarded by the analytics lock
+	sessions *sessionTracker
+
+	// Session entry and exit pages, guarded by the analytics lock
+	entryExit *entryExitTracker
+
+	// Page views and referrals ranked as they are counted, so snapshots can list the
+	// top entries without sorting every page and source; guarded by the analytics lock
+	pageRanks   *rankedCounts
+	sourceRanks *rankedCounts
+
+	// Page URL normalization and allowed hosts, with the events of other hosts by host
+	// and the views of each page by variant; guarded by the analytics lock
+	urlRules     URLRules
+	foreignHosts map[string]int64
+	pageVariants map[string]map[string]int64
+
+	// Page-to-page transitions of session journeys, guarded by the analytics lock
+	paths *pathTracker
+
+	// Anonymous visitors identified as users, guarded by the analytics lock
+	identities *identityTracker
+
+	// Traffic channel classification and visits per channel, guarded by the analytics lock
+	channelRules  []ChannelRule
+	channelCounts map[string]int64
+
+	// Top pages and sources in snapshots, guarded by the analytics lock; 0 uses defaultTopN
+	topPages   int
+	topSources int
+
+	// Counters shared with other replicas, guarded by the analytics lock
+	shared           SharedState // nil keeps every counter local
+	sharedContribute bool
+	sharedWarned     time.Time // Last shared state failure logged, guarded by sharedWarnMu
+	sharedWarnMu     sync.Mutex
+
+	// Custom metrics, guarded by the analytics lock
+	customMetrics map[string]*customMetric             // Metric name -> state
+	customByType  map[models.EventType][]*customMetric // Event type -> metrics to update
+
+	// Serializes CheckAlerts so a condition fires or resolves once
+	alertCheckMu sync.Mutex
+
+	mu sync.RWMutex
 }
 
-// NewService creates a new analytics service
+// NewService creates a new analytics service with the default retention
 func NewService() *Service {
+	return NewServiceWithRetention(DefaultRetention())
+}
+
+// NewServiceWithRetention creates a new analytics service that keeps data as configured.
+// Expired data is only removed while RunCleanup is running.
+func NewServiceWithRetention(retention RetentionConfig) *Service {
 	return &Service{
-		analytics: models.NewRealTimeAnalytics(),
-		alerts:    make([]models.AlertConfig, 0),
+		analytics:     models.NewRealTimeAnalytics(),
+		retention:     retention.withDefaults(),
+		alerts:        make([]models.AlertConfig, 0),
+		activeAlerts:  make(map[string]*alertState),
+		alertWindows:  newAlertWindowTracker(),
+		experiments:   newExperimentTracker(),
+		uaParser:      useragent.NewParser(),
+		campaignGoal:  models.Goal{EventType: models.Click},
+		uniques:       hll.DefaultConfig(),
+		eventTime:     eventClock{config: DefaultEventTimeConfig()},
+		campaigns:     make(map[string]*campaign),
+		userCampaigns: make(map[string]string),
+		loadTimes:     NewQuantileSketch(),
+		commerce:      newCommerceTracker(DefaultCurrency),
+		heatmaps:      newHeatmapTracker(DefaultHeatmapGridSize, DefaultHeatmapMaxPages),
+		errors:        newErrorTracker(),
+		bots:          newBotTracker(),
+		visitors:      newVisitorTracker(),
+		sessions:      newSessionTracker(),
+		entryExit:     newEntryExitTracker(),
+		pageRanks:     newRankedCounts(),
+		sourceRanks:   newRankedCounts(),
+		foreignHosts:  make(map[string]int64),
+		pageVariants:  make(map[string]map[string]int64),
+		paths:         newPathTracker(),
+		identities:    newIdentityTracker(),
+		channelRules:  defaultChannelRules,
+		channelCounts: make(map[string]int64),
+		customMetrics: make(map[string]*customMetric),
+		customByType:  make(map[models.EventType][]*customMetric),
+		features:      features.New(),
+	}
+}
+
+// SetFeatures replaces the flags gating experimental processing paths, so they can be
+// shared with the code that reloads them
+func (s *Service) SetFeatures(flags *features.Flags) {
+	s.analytics.Mu.Lock()
+	defer s.analytics.Mu.Unlock()
+	s.features = flags
+}
+
+// Retention returns the current retention settings
+func (s *Service) Retention() RetentionConfig {
+	s.analytics.Mu.RLock()
+	defer s.analytics.Mu.RUnlock()
+	return s.retention
+}
+
+// SetRetention changes the retention settings while the service runs. A smaller recent
+// events limit trims the buffer at once; other data is expired on the next Cleanup. The
+// cleanup interval only changes when RunCleanup is restarted.
+func (s *Service) SetRetention(retention RetentionConfig) {
+	s.analytics.Mu.Lock()
+	defer s.analytics.Mu.Unlock()
+	retention = retention.withDefaults()
+	retention.CleanupInterval = s.retention.CleanupInterval
+	s.retention = retention
+	if len(s.analytics.Events) > retention.RecentEvents {
+		s.analytics.Events = s.analytics.Events[len(s.analytics.Events)-retention.RecentEvents:]
 	}
 }
 
-// ProcessEvent processes a single analytics event
+// SetUserAgentParser replaces the parser used for browser, OS and device detection
+func (s *Service) SetUserAgentParser(parser useragent.Parser) {
+	s.analytics.Mu.Lock()
+	defer s.analytics.Mu.Unlock()
+	s.uaParser = parser
+}
+
+// SetUniqueCounting sets when distinct user and session counts switch from exact sets to
+// HyperLogLog estimates, and the size of the estimating sketches. Counters that already
+// hold items keep their previous settings.
+func (s *Service) SetUniqueCounting(config hll.Config) {
+	s.analytics.Mu.Lock()
+	defer s.analytics.Mu.Unlock()
+	s.uniques = config
+	if s.analytics.UniqueUsers.Count() == 0 {
+		s.analytics.UniqueUsers = hll.New(config)
+	}
+}
+
+// addDistinct adds an item to the counter for key, creating the counter if needed.
+// The caller must hold the analytics lock.
+func addDistinct[K comparable](counters map[K]*hll.Counter, key K, item string, config hll.Config) {
+	counter := counters[key]
+	if counter == nil {
+		counter = hll.New(config)
+		counters[key] = counter
+	}
+	if item != "" {
+		counter.Add(item)
+	}
+}
+
+// distinctCount returns the count of a possibly missing counter
+func distinctCount(counter *hll.Counter) int64 {
+	if counter == nil {
+		return 0
+	}
+	return counter.Count()
+}
+
+// SetExcludeBots controls whether events from bots are left out of all aggregates, as
+// with the segregate bot policy. Bot events are always counted in BotEvents.
+func (s *Service) SetExcludeBots(exclude bool) {
+	policy := bots.PolicyFlag
+	if exclude {
+		policy = bots.PolicySegregate
+	}
+	s.SetBotPolicy(policy, nil)
+}
+
+// ProcessEvent processes a single analytics event. Events of malformed types are
+// rejected with errs.ErrInvalidEvent, as the producer would have.
 func (s *Service) ProcessEvent(event *models.AnalyticsEvent) error {
+	if !event.Type.Valid() {
+		return errs.Errorf(errs.ErrInvalidEvent, "invalid event type %q", event.Type)
+	}
+
+	// Tombstones remove the user's data instead of being counted
+	if event.Type == models.UserErasure {
+		s.EraseUser(event.UserID)
+		return nil
+	}
+
+	// Contribute to the shared counters and report goal completions outside the analytics lock
+	state, update, completions := s.aggregate(event)
+	if state != nil {
+		s.recordShared(state, update)
+	}
+	s.notifyGoals(completions)
+	return nil
+}
+
+// RecoverEvent counts an event the service had counted before a restart, e.g. from a
+// write-ahead log. Unlike ProcessEvent it doesn't add to the shared counters, which
+// already hold the event, or report goal completions again.
+func (s *Service) RecoverEvent(event *models.AnalyticsEvent) {
+	s.aggregate(event)
+}
+
+// aggregate adds an event to the in-memory analytics and returns the goals it completes.
+// When the event is counted and the service contributes to shared state, it also returns
+// the state and the update to record.
+func (s *Service) aggregate(event *models.AnalyticsEvent) (SharedState, SharedUpdate, []models.GoalCompletion) {
 	s.analytics.Mu.Lock()
 	defer s.analytics.Mu.Unlock()
 
-	// Add to recent events buffer (keep last 100)
+	// Event counters are upweighted for events kept by ingestion sampling
+	weight := s.sampleWeight(event)
+
+	// Detect bots first so bot traffic can be excluded from every aggregate
+	var agent *useragent.Info
+	if event.UserAgent != "" {
+		info := s.uaParser.Parse(event.UserAgent)
+		agent = &info
+	}
+	if s.processBot(event, agent, weight) {
+		return nil, SharedUpdate{}, nil
+	}
+
+	// Keep events for unexpected hosts out of the site's metrics
+	if s.processForeignDomain(event, weight) {
+		return nil, SharedUpdate{}, nil
+	}
+
+	// Count pages under their normalized URL; campaigns still read the URL as sent
+	page, variant := s.urlRules.NormalizeURL(event.URL)
+
+	// Merge a visitor's anonymous activity into the user they identify as, and count
+	// later events of identified anonymous IDs for the user
+	if s.features.Enabled(features.IdentityStitching) {
+		s.processIdentify(event)
+		event = s.resolveIdentity(event)
+	}
+
+	// Add to recent events buffer
 	s.analytics.Events = append(s.analytics.Events, *event)
-	if len(s.analytics.Events) > 100 {
-		s.analytics.Events = s.analytics.Events[1:]
+	if len(s.analytics.Events) > s.retention.RecentEvents {
+		s.analytics.Events = s.analytics.Events[len(s.analytics.Events)-s.retention.RecentEvents:]
 	}
 
 	// Update total events counter
-	s.analytics.TotalEvents++
+	s.analytics.TotalEvents += weight
 
 	// Track event by type
-	s.analytics.EventsByType[event.Type]++
+	s.analytics.EventsByType[event.Type] += weight
 
 	// Track unique users
 	if event.UserID != "" {
-		s.analytics.UniqueUsers[event.UserID] = true
+		s.analytics.UniqueUsers.Add(event.UserID)
 	}
 
 	// Update session activity
 	if event.SessionID != "" {
 		s.analytics.SessionsActive[event.SessionID] = event.Timestamp
 	}
+	s.processSessionActivity(event)
+
+	// Track visitors active right now
+	s.processActiveVisitor(event)
 
-	// Track hourly data
-	hour := event.Timestamp.Truncate(time.Hour).Unix()
-	s.analytics.HourlyData[hour]++
+	// Track hourly data by event time, unless the event is too late for its hour
+	s.processHourly(event, weight)
 
 	// Process specific event types
 	switch event.Type {
 	case models.PageView:
-		s.processPageView(event)
+		s.processPageView(event, page, variant, weight)
+		if agent != nil {
+			s.processAgentPageView(agent, page, weight)
+		}
+		s.processGeo(event, weight)
+		if s.processEntryExit(event) {
+			s.processChannel(event)
+		}
+		if s.features.Enabled(features.PathFlow) {
+			s.processPath(event)
+		}
 	case models.Click:
-		s.processClick(event)
+		s.processClick(event, page, weight)
 	case models.Session:
 		s.processSession(event)
 	}
 
+	// Track per-site stats from the event URL host
+	if event.URL != "" {
+		s.processSite(event, weight)
+	}
+
 	// Extract traffic source from referrer
 	if event.Referrer != "" {
-		s.processReferrer(event.Referrer)
+		s.processReferrer(event.Referrer, weight)
 	}
 
-	// Extract device and browser info from user agent
-	if event.UserAgent != "" {
-		s.processUserAgent(event.UserAgent)
+	// Track device, browser and OS from the parsed user agent
+	if agent != nil {
+		s.processUserAgent(agent, weight)
 	}
 
-	// Periodic cleanup (every 5 minutes)
-	if time.Since(s.analytics.LastCleanup) > 5*time.Minute {
-		s.cleanup()
-		s.analytics.LastCleanup = time.Now()
+	// Track revenue, carts and purchase conversion
+	s.processCommerce(event, weight)
+
+	// Track the error rate and group error events
+	s.processErrors(event, weight)
+
+	// Count the event towards per-path and relative alerts
+	if s.features.Enabled(features.AlertWindows) {
+		s.processAlertWindows(event, weight)
 	}
 
-	return nil
+	// Apply user-defined aggregation rules
+	s.processCustomMetrics(event)
+
+	// Attribute users to UTM campaigns and track their conversions
+	s.processCampaign(event)
+
+	// Track experiment assignments and goal conversions
+	s.experiments.process(event)
+
+	// Count completions of user-defined conversion goals
+	completions := s.processGoals(event)
+
+	if s.shared == nil || !s.sharedContribute {
+		return nil, SharedUpdate{}, completions
+	}
+	return s.shared, newSharedUpdate(event, page, agent, weight), completions
 }
 
-// processPageView handles page view specific processing
-func (s *Service) processPageView(event *models.AnalyticsEvent) {
-	s.analytics.PageViews[event.URL]++
+// sampleWeight returns the whole number of events a sampled event is counted as. The
+// fractions of non-integer weights are carried over to later events, so a rate of
+// 0.4 counts events as 2 and 3 alternately and totals stay unbiased. The caller must
+// hold the analytics lock.
+func (s *Service) sampleWeight(event *models.AnalyticsEvent) int64 {
+	s.sampleRemainder += models.SampleWeight(event)
+	// Allow for rates whose inverse isn't exact in floating point, such as 1/7
+	weight := math.Floor(s.sampleRemainder + 1e-9)
+	s.sampleRemainder -= weight
+	return int64(weight)
+}
 
-	// Track unique visitors per page
-	if s.analytics.PageVisitors[event.URL] == nil {
-		s.analytics.PageVisitors[event.URL] = make(map[string]bool)
+// processHourlyRollup updates the aggregated metrics for the event's hour
+func (s *Service) processHourlyRollup(hour int64, event *models.AnalyticsEvent, weight int64) {
+	rollup := s.analytics.HourlyRollups[hour]
+	if rollup == nil {
+		rollup = &models.Rollup{
+			Start:        time.Unix(hour, 0).UTC(),
+			Granularity:  models.GranularityHour,
+			EventsByType: make(map[models.EventType]int64),
+		}
+		s.analytics.HourlyRollups[hour] = rollup
 	}
-	if event.UserID != "" {
-		s.analytics.PageVisitors[event.URL][event.UserID] = true
+
+	rollup.Events += weight
+	rollup.EventsByType[event.Type] += weight
+	if event.Type == models.PageView {
+		rollup.PageViews += weight
 	}
+	addDistinct(s.analytics.HourlyUsers, hour, event.UserID, s.uniques)
+	addDistinct(s.analytics.HourlySessions, hour, event.SessionID, s.uniques)
+}
+
+// processPageView handles page view specific processing. The view is counted for page,
+// the event's normalized URL.
+func (s *Service) processPageView(event *models.AnalyticsEvent, page, variant string, weight int64) {
+	s.analytics.PageViews[page] += weight
+	s.pageRanks.add(page, weight)
+	s.processPageVariant(page, variant, weight)
+
+	// Track unique visitors per page
+	addDistinct(s.analytics.PageVisitors, page, event.UserID, s.uniques)
 
 	// Extract load time from metadata
-	if metadata, ok := event.Metadata["load_time"].(float64); ok {
-		s.analytics.LoadTimes = append(s.analytics.LoadTimes, metadata)
-		// Keep only last 1000 load times
-		if len(s.analytics.LoadTimes) > 1000 {
-			s.analytics.LoadTimes = s.analytics.LoadTimes[1:]
+	if loadTime, ok := event.Metadata["load_time"].(float64); ok {
+		s.loadTimes.Add(loadTime)
+		if loadTime > slowPageThreshold {
+			s.slowPages++
+		} else {
+			s.fastPages++
 		}
 	}
 }
 
-// processClick handles click event processing
-func (s *Service) processClick(_ *models.AnalyticsEvent) {
-	// Click events can be used for interaction tracking
-	// Add specific click processing logic here if needed
-}
-
 // processSession handles session event processing
 func (s *Service) processSession(event *models.AnalyticsEvent) {
 	// Extract device info from metadata
@@ -126,82 +530,243 @@ func (s *Service) processSession(event *models.AnalyticsEvent) {
 	}
 }
 
+// maxSites bounds the sites tracked, since their hostnames come from client input
+const maxSites = 1000
+
+// processSite tracks page views and unique visitors per site hostname. Sites beyond
+// the first maxSites are not tracked.
+func (s *Service) processSite(event *models.AnalyticsEvent, weight int64) {
+	host := SiteHost(event.URL)
+	if host == "" {
+		return
+	}
+	if _, ok := s.analytics.SiteVisitors[host]; !ok && len(s.analytics.SiteVisitors) >= maxSites {
+		return
+	}
+
+	if event.Type == models.PageView {
+		s.analytics.SiteViews[host] += weight
+	}
+
+	addDistinct(s.analytics.SiteVisitors, host, event.UserID, s.uniques)
+}
+
+// SiteHost returns the normalized site hostname for a URL, without port or "www." prefix
+func SiteHost(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
+
 // processReferrer extracts domain from referrer URL
-func (s *Service) processReferrer(referrer string) {
-	if u, err := url.Parse(referrer); err == nil && u.Host != "" {
-		domain := u.Host
-		if strings.HasPrefix(domain, "www.") {
-			domain = domain[4:]
-		}
-		s.analytics.TrafficSources[domain]++
+func (s *Service) processReferrer(referrer string, weight int64) {
+	if domain := referrerDomain(referrer); domain != "" {
+		s.analytics.TrafficSources[domain] += weight
+		s.sourceRanks.add(domain, weight)
+	}
+}
+
+// referrerDomain returns the host of a referrer URL without "www.", or "" if it has none
+func referrerDomain(referrer string) string {
+	u, err := url.Parse(referrer)
+	if err != nil || u.Host == "" {
+		return ""
 	}
+	return strings.TrimPrefix(u.Host, "www.")
 }
 
-// processUserAgent extracts browser and device info from user agent
-func (s *Service) processUserAgent(userAgent string) {
-	userAgent = strings.ToLower(userAgent)
+// processUserAgent tracks browser, OS and device stats from a parsed user agent
+func (s *Service) processUserAgent(agent *useragent.Info, weight int64) {
+	s.analytics.BrowserTypes[agent.Browser] += weight
+	s.analytics.OSTypes[agent.OS] += weight
+	s.analytics.DeviceTypes[agent.Device] += weight
 
-	// Simple browser detection
-	if strings.Contains(userAgent, "chrome") {
-		s.analytics.BrowserTypes["Chrome"]++
-	} else if strings.Contains(userAgent, "firefox") {
-		s.analytics.BrowserTypes["Firefox"]++
-	} else if strings.Contains(userAgent, "safari") {
-		s.analytics.BrowserTypes["Safari"]++
-	} else if strings.Contains(userAgent, "edge") {
-		s.analytics.BrowserTypes["Edge"]++
-	} else {
-		s.analytics.BrowserTypes["Other"]++
+	// Versions are keyed with their name, e.g. "Chrome 120" or "iOS 17"
+	if agent.BrowserVersion != "" {
+		s.analytics.BrowserVersions[agent.Browser+" "+agent.BrowserVersion] += weight
 	}
+	if agent.OSVersion != "" {
+		s.analytics.OSVersions[agent.OS+" "+agent.OSVersion] += weight
+	}
+
+	// The combination lets breakdowns be filtered, e.g. browsers on iOS
+	s.analytics.AgentCounts[models.Agent{
+		Device:         agent.Device,
+		Browser:        agent.Browser,
+		BrowserVersion: agent.BrowserVersion,
+		OS:             agent.OS,
+		OSVersion:      agent.OSVersion,
+	}] += weight
+}
 
-	// Simple device detection
-	if strings.Contains(userAgent, "mobile") || strings.Contains(userAgent, "iphone") || strings.Contains(userAgent, "android") {
-		s.analytics.DeviceTypes["Mobile"]++
-	} else if strings.Contains(userAgent, "tablet") || strings.Contains(userAgent, "ipad") {
-		s.analytics.DeviceTypes["Tablet"]++
-	} else {
-		s.analytics.DeviceTypes["Desktop"]++
+// processAgentPageView counts a page view under the browser and OS that made it, so
+// pages can be filtered by either
+func (s *Service) processAgentPageView(agent *useragent.Info, page string, weight int64) {
+	key := models.Agent{Browser: agent.Browser, OS: agent.OS}
+	views, ok := s.analytics.AgentPageViews[key]
+	if !ok {
+		views = make(map[string]int64)
+		s.analytics.AgentPageViews[key] = views
 	}
+	views[page] += weight
 }
 
-// cleanup removes old sessions and data
-func (s *Service) cleanup() {
-	now := time.Now()
+// RunCleanup expires old sessions, hourly data and recent events every cleanup
+// interval until ctx is cancelled
+func (s *Service) RunCleanup(ctx context.Context) {
+	ticker := time.NewTicker(s.retention.CleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.Cleanup(time.Now())
+		case <-ctx.Done():
+			return
+		}
+	}
+}
 
-	// Remove inactive sessions (older than 30 minutes)
+// Cleanup removes data that has outlived the retention settings as of now
+func (s *Service) Cleanup(now time.Time) {
+	s.analytics.Mu.Lock()
+	defer s.analytics.Mu.Unlock()
+
+	// Remove inactive sessions
 	for sessionID, lastActivity := range s.analytics.SessionsActive {
-		if now.Sub(lastActivity) > 30*time.Minute {
+		if now.Sub(lastActivity) > s.retention.SessionTimeout {
 			delete(s.analytics.SessionsActive, sessionID)
 		}
 	}
 
-	// Clean up old hourly data (keep last 48 hours)
-	cutoff := now.Add(-48 * time.Hour).Truncate(time.Hour).Unix()
+	s.sessions.expire(s.analytics.SessionsActive)
+	s.entryExit.expire(s.analytics.SessionsActive)
+	s.paths.expire(s.analytics.SessionsActive)
+
+	// Remove visitors no longer active
+	s.visitors.expire(now)
+
+	// Forget identified anonymous IDs no longer in use
+	s.identities.expire(now.Add(-s.retention.HourlyWindow))
+	s.sessions.expireUsers(now.Add(-s.retention.HourlyWindow))
+
+	// Clean up old hourly data
+	cutoff := now.Add(-s.retention.HourlyWindow).Truncate(time.Hour).Unix()
 	for hour := range s.analytics.HourlyData {
 		if hour < cutoff {
 			delete(s.analytics.HourlyData, hour)
 		}
 	}
+	for hour := range s.analytics.HourlyRollups {
+		if hour < cutoff {
+			delete(s.analytics.HourlyRollups, hour)
+			delete(s.analytics.HourlyUsers, hour)
+			delete(s.analytics.HourlySessions, hour)
+		}
+	}
+	for hour := range s.analytics.HourlyCountries {
+		if hour < cutoff {
+			delete(s.analytics.HourlyCountries, hour)
+		}
+	}
+
+	// Drop expired recent events; the buffer is in arrival order, so stop at the first fresh one
+	if s.retention.EventTTL > 0 {
+		expired := 0
+		for expired < len(s.analytics.Events) && now.Sub(s.analytics.Events[expired].Timestamp) > s.retention.EventTTL {
+			expired++
+		}
+		s.analytics.Events = s.analytics.Events[expired:]
+	}
+
+	s.analytics.LastCleanup = now
+}
+
+// GetHourlyRollups returns copies of the hourly rollups currently held in memory
+func (s *Service) GetHourlyRollups() []models.Rollup {
+	s.analytics.Mu.RLock()
+	defer s.analytics.Mu.RUnlock()
+
+	result := make([]models.Rollup, 0, len(s.analytics.HourlyRollups))
+	for hour, rollup := range s.analytics.HourlyRollups {
+		copied := *rollup
+		// Restored hours keep their persisted counts until they receive new events
+		if users, ok := s.analytics.HourlyUsers[hour]; ok {
+			copied.UniqueUsers = users.Count()
+		}
+		if sessions, ok := s.analytics.HourlySessions[hour]; ok {
+			copied.Sessions = sessions.Count()
+		}
+		copied.EventsByType = make(map[models.EventType]int64, len(rollup.EventsByType))
+		for eventType, count := range rollup.EventsByType {
+			copied.EventsByType[eventType] = count
+		}
+		result = append(result, copied)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Start.Before(result[j].Start)
+	})
+	return result
 }
 
-// GetSnapshot returns a complete analytics snapshot
+// GetSnapshot returns a complete analytics snapshot, with the core counters read from
+// shared state when one is set
 func (s *Service) GetSnapshot() *models.MetricsSnapshot {
+	return s.GetSnapshotTop(0)
+}
+
+// GetSnapshotTop returns a snapshot listing up to limit top pages and traffic sources;
+// a non-positive limit uses the configured sizes
+func (s *Service) GetSnapshotTop(limit int) *models.MetricsSnapshot {
+	snapshot := s.localSnapshot(limit)
+	s.applyShared(snapshot, limit)
+	return snapshot
+}
+
+// localSnapshot returns a snapshot of this replica's in-memory analytics
+func (s *Service) localSnapshot(limit int) *models.MetricsSnapshot {
 	s.analytics.Mu.RLock()
 	defer s.analytics.Mu.RUnlock()
 
+	topPages, topSources := s.topLimits(limit)
+
 	snapshot := &models.MetricsSnapshot{
 		Timestamp:          time.Now(),
 		TotalEvents:        s.analytics.TotalEvents,
-		UniqueUsers:        int64(len(s.analytics.UniqueUsers)),
+		UniqueUsers:        s.analytics.UniqueUsers.Count(),
 		ActiveSessions:     int64(len(s.analytics.SessionsActive)),
 		EventsByType:       make(map[models.EventType]int64),
-		TopPages:           s.getTopPages(),
-		TrafficSources:     s.getTrafficSources(),
+		TopPages:           s.getTopPages(topPages),
+		TrafficSources:     s.getTrafficSources(topSources),
+		TrafficChannels:    s.getTrafficChannels(),
 		DeviceStats:        make(map[string]int64),
 		BrowserStats:       make(map[string]int64),
+		BrowserVersions:    copyCounts(s.analytics.BrowserVersions),
+		OSStats:            copyCounts(s.analytics.OSTypes),
+		OSVersions:         copyCounts(s.analytics.OSVersions),
+		BotEvents:          s.analytics.BotEvents,
 		HourlyPageViews:    s.getHourlyPageViews(),
 		RealTimeEvents:     s.getRecentEvents(),
 		PerformanceMetrics: s.getPerformanceMetrics(),
+		Sites:              s.getSiteMetrics(),
+		CampaignStats:      s.getCampaignStats(),
+		CustomMetrics:      s.getCustomMetrics(),
+		Commerce:           s.getCommerceMetrics(),
+		Errors:             s.getErrorMetrics(time.Now()),
+		BotStats:           s.getBotStats(),
+		ForeignDomains:     copyCounts(s.foreignHosts),
+		EventTime:          s.getEventTimeStats(),
+		EntryPages:         s.entryExit.topPages(s.entryExit.entries),
+		ExitPages:          s.entryExit.topPages(s.entryExit.exits),
+		Sessions:           s.sessions.stats(),
+		Goals:              s.getGoalMetrics(),
+		Identities:         s.identityStats(),
+		Geo:                s.getGeoStats(time.Now()),
+		Quality:            s.getQualityStats(time.Now()),
+		Latency:            s.getLatencyStats(time.Now()),
 	}
 
 	// Copy event type stats
@@ -222,93 +787,104 @@ func (s *Service) GetSnapshot() *models.MetricsSnapshot {
 	return snapshot
 }
 
-// getTopPages returns top pages sorted by views
-func (s *Service) getTopPages() []models.PageMetric {
-	type pageData struct {
-		url      string
-		views    int64
-		visitors int64
+// copyCounts returns a copy of a count map
+func copyCounts(counts map[string]int64) map[string]int64 {
+	result := make(map[string]int64, len(counts))
+	for key, count := range counts {
+		result[key] = count
+	}
+	return result
+}
+
+// getTopPages returns the n most viewed pages. Pages are taken from the view count
+// ranking before their other metrics are computed.
+func (s *Service) getTopPages(n int) []models.PageMetric {
+	top := s.pageRanks.top(n)
+	result := make([]models.PageMetric, len(top))
+	for i, entry := range top {
+		result[i] = s.pageMetric(entry.key, entry.count)
 	}
+	return result
+}
 
-	pages := make([]pageData, 0, len(s.analytics.PageViews))
+// pageMetrics returns metrics for every page, unsorted
+func (s *Service) pageMetrics() []models.PageMetric {
+	result := make([]models.PageMetric, 0, len(s.analytics.PageViews))
 	for pageURL, views := range s.analytics.PageViews {
-		visitors := int64(0)
-		if s.analytics.PageVisitors[pageURL] != nil {
-			visitors = int64(len(s.analytics.PageVisitors[pageURL]))
-		}
-		pages = append(pages, pageData{url: pageURL, views: views, visitors: visitors})
+		result = append(result, s.pageMetric(pageURL, views))
 	}
+	return result
+}
 
-	// Sort by views descending
-	sort.Slice(pages, func(i, j int) bool {
-		return pages[i].views > pages[j].views
-	})
+// pageMetric returns the metrics of one page
+func (s *Service) pageMetric(pageURL string, views int64) models.PageMetric {
+	// Extract path from URL
+	path := pageURL
+	if u, err := url.Parse(pageURL); err == nil {
+		path = u.Path
+	}
 
-	// Convert to PageMetric (top 10)
-	result := make([]models.PageMetric, 0, 10)
-	for i, page := range pages {
-		if i >= 10 {
-			break
-		}
+	return models.PageMetric{
+		URL:            pageURL,
+		Path:           path,
+		Views:          views,
+		UniqueVisitors: distinctCount(s.analytics.PageVisitors[pageURL]),
+		Variants:       copyCounts(s.pageVariants[pageURL]),
+		BounceRate:     0, // TODO: Calculate bounce rate
+	}
+}
 
-		// Extract path from URL
-		path := page.url
-		if u, err := url.Parse(page.url); err == nil {
-			path = u.Path
+// getSiteMetrics returns per-site statistics keyed by hostname
+func (s *Service) getSiteMetrics() map[string]models.SiteMetric {
+	result := make(map[string]models.SiteMetric, len(s.analytics.SiteVisitors))
+	for host, visitors := range s.analytics.SiteVisitors {
+		result[host] = models.SiteMetric{
+			Host:           host,
+			PageViews:      s.analytics.SiteViews[host],
+			UniqueVisitors: visitors.Count(),
 		}
-
-		result = append(result, models.PageMetric{
-			URL:            page.url,
-			Path:           path,
-			Views:          page.views,
-			UniqueVisitors: page.visitors,
-			BounceRate:     0, // TODO: Calculate bounce rate
-		})
 	}
-
 	return result
 }
 
-// getTrafficSources returns top traffic sources
-func (s *Service) getTrafficSources() []models.TrafficSource {
-	type sourceData struct {
-		source string
-		count  int64
+// getTrafficSources returns the n traffic sources with the most referrals
+func (s *Service) getTrafficSources(n int) []models.TrafficSource {
+	total := s.totalReferrals()
+	top := s.sourceRanks.top(n)
+	result := make([]models.TrafficSource, len(top))
+	for i, entry := range top {
+		result[i] = s.trafficSource(entry.key, entry.count, total)
 	}
+	return result
+}
 
-	sources := make([]sourceData, 0, len(s.analytics.TrafficSources))
-	totalTraffic := int64(0)
-
+// trafficSources returns every traffic source with its share of referred traffic, unsorted
+func (s *Service) trafficSources() []models.TrafficSource {
+	total := s.totalReferrals()
+	result := make([]models.TrafficSource, 0, len(s.analytics.TrafficSources))
 	for source, count := range s.analytics.TrafficSources {
-		sources = append(sources, sourceData{source: source, count: count})
-		totalTraffic += count
+		result = append(result, s.trafficSource(source, count, total))
 	}
+	return result
+}
 
-	// Sort by count descending
-	sort.Slice(sources, func(i, j int) bool {
-		return sources[i].count > sources[j].count
-	})
-
-	// Convert to TrafficSource (top 10)
-	result := make([]models.TrafficSource, 0, 10)
-	for i, source := range sources {
-		if i >= 10 {
-			break
-		}
-
-		percent := float64(0)
-		if totalTraffic > 0 {
-			percent = float64(source.count) / float64(totalTraffic) * 100
-		}
+// totalReferrals returns the number of referred events across all sources
+func (s *Service) totalReferrals() int64 {
+	return s.sourceRanks.total
+}
 
-		result = append(result, models.TrafficSource{
-			Source:  source.source,
-			Count:   source.count,
-			Percent: percent,
-		})
+// trafficSource returns a source with its share of total referrals
+func (s *Service) trafficSource(source string, count, total int64) models.TrafficSource {
+	percent := float64(0)
+	if total > 0 {
+		percent = float64(count) / float64(total) * 100
+	}
+	return models.TrafficSource{
+		Source:  source,
+		Count:   count,
+		Percent: percent,
+		Channel: s.classifyChannel("", source, false),
 	}
-
-	return result
 }
 
 // getHourlyPageViews returns hourly page view data for the last 24 hours
@@ -351,7 +927,7 @@ func (s *Service) getRecentEvents() []models.RecentEvent {
 			Type:      event.Type,
 			URL:       event.URL,
 			UserID:    event.UserID,
-			Location:  s.extractLocation(event.IPAddress),
+			Location:  EventLocation(&event),
 		})
 	}
 
@@ -360,46 +936,32 @@ func (s *Service) getRecentEvents() []models.RecentEvent {
 
 // getPerformanceMetrics calculates performance metrics from load times
 func (s *Service) getPerformanceMetrics() models.PerformanceMetrics {
-	if len(s.analytics.LoadTimes) == 0 {
+	if s.loadTimes.Count() == 0 {
 		return models.PerformanceMetrics{}
 	}
 
-	// Calculate average load time
-	sum := float64(0)
-	for _, loadTime := range s.analytics.LoadTimes {
-		sum += loadTime
-	}
-	avg := sum / float64(len(s.analytics.LoadTimes))
-
-	// Calculate median (simple approach)
-	sorted := make([]float64, len(s.analytics.LoadTimes))
-	copy(sorted, s.analytics.LoadTimes)
-	sort.Float64s(sorted)
-	median := sorted[len(sorted)/2]
-
-	// Count fast vs slow pages (threshold: 3 seconds = 3000ms)
-	slowCount := int64(0)
-	fastCount := int64(0)
-	for _, loadTime := range s.analytics.LoadTimes {
-		if loadTime > 3000 {
-			slowCount++
-		} else {
-			fastCount++
-		}
-	}
-
+	// Quantiles come from the streaming sketch and are within 1% of the exact values
 	return models.PerformanceMetrics{
-		AverageLoadTime: avg,
-		MedianLoadTime:  median,
-		SlowPagesCount:  slowCount,
-		FastPagesCount:  fastCount,
+		AverageLoadTime: s.loadTimes.Mean(),
+		MedianLoadTime:  s.loadTimes.Quantile(0.5),
+		P75LoadTime:     s.loadTimes.Quantile(0.75),
+		P90LoadTime:     s.loadTimes.Quantile(0.90),
+		P95LoadTime:     s.loadTimes.Quantile(0.95),
+		P99LoadTime:     s.loadTimes.Quantile(0.99),
+		SlowPagesCount:  s.slowPages,
+		FastPagesCount:  s.fastPages,
+		Samples:         s.loadTimes.Count(),
 	}
 }
 
-// extractLocation extracts location from IP address (simplified)
-func (s *Service) extractLocation(ipAddress string) string {
-	// This is a simplified implementation
-	// In production, you'd use a GeoIP service
+// EventLocation returns the event's country code when known, and otherwise whether its IP
+// address is local or external
+func EventLocation(event *models.AnalyticsEvent) string {
+	if country, _ := models.GeoFromEvent(event); country != "" {
+		return country
+	}
+
+	ipAddress := event.IPAddress
 	if ipAddress == "" {
 		return "Unknown"
 	}
@@ -413,94 +975,3 @@ func (s *Service) extractLocation(ipAddress string) string {
 
 	return "External"
 }
-
-// AddAlert adds a new alert configuration
-func (s *Service) AddAlert(config models.AlertConfig) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	s.alerts = append(s.alerts, config)
-}
-
-// CheckAlerts evaluates all alert conditions and returns triggered alerts
-func (s *Service) CheckAlerts() []models.Alert {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-
-	var triggeredAlerts []models.Alert
-	snapshot := s.GetSnapshot()
-
-	for _, alertConfig := range s.alerts {
-		if !alertConfig.Enabled {
-			continue
-		}
-
-		currentValue := s.getMetricValue(snapshot, alertConfig.Metric)
-		triggered := s.evaluateAlertCondition(currentValue, alertConfig.Threshold, alertConfig.Operator)
-
-		if triggered {
-			alert := models.Alert{
-				ID:           "alert_" + strconv.FormatInt(time.Now().Unix(), 10),
-				Type:         alertConfig.Type,
-				Message:      s.generateAlertMessage(alertConfig, currentValue),
-				Severity:     s.getAlertSeverity(alertConfig.Type),
-				Timestamp:    time.Now(),
-				Resolved:     false,
-				Threshold:    alertConfig.Threshold,
-				CurrentValue: currentValue,
-			}
-			triggeredAlerts = append(triggeredAlerts, alert)
-		}
-	}
-
-	return triggeredAlerts
-}
-
-// getMetricValue extracts a specific metric value from the snapshot
-func (s *Service) getMetricValue(snapshot *models.MetricsSnapshot, metric string) float64 {
-	switch metric {
-	case "total_events":
-		return float64(snapshot.TotalEvents)
-	case "unique_users":
-		return float64(snapshot.UniqueUsers)
-	case "active_sessions":
-		return float64(snapshot.ActiveSessions)
-	case "average_load_time":
-		return snapshot.PerformanceMetrics.AverageLoadTime
-	default:
-		return 0
-	}
-}
-
-// evaluateAlertCondition checks if an alert condition is met
-func (s *Service) evaluateAlertCondition(current, threshold float64, operator string) bool {
-	switch operator {
-	case "gt":
-		return current > threshold
-	case "lt":
-		return current < threshold
-	case "eq":
-		return current == threshold
-	default:
-		return false
-	}
-}
-
-// generateAlertMessage creates a human-readable alert message
-func (s *Service) generateAlertMessage(config models.AlertConfig, currentValue float64) string {
-	return fmt.Sprintf("Alert: %s - %s is %.2f (threshold: %.2f)",
-		config.Name, config.Metric, currentValue, config.Threshold)
-}
-
-// getAlertSeverity determines alert severity based on type
-func (s *Service) getAlertSeverity(alertType string) string {
-	switch alertType {
-	case "performance":
-		return "medium"
-	case "traffic":
-		return "low"
-	case "error":
-		return "high"
-	default:
-		return "medium"
-	}
-}
@@ -0,0 +1,280 @@
+package analytics
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/features"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+const (
+	edgeUserAgent      = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 Edg/120.0.2210.91"
+	googlebotUserAgent = "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)"
+)
+
+func TestProcessEventUserAgent(t *testing.T) {
+	service := NewService()
+	service.ProcessEvent(&models.AnalyticsEvent{ID: "1", Type: models.PageView, Timestamp: time.Now(), UserID: "u1", UserAgent: edgeUserAgent})
+
+	snapshot := service.GetSnapshot()
+	if snapshot.BrowserStats["Edge"] != 1 || snapshot.BrowserVersions["Edge 120"] != 1 {
+		t.Errorf("expected Edge 120, got %v %v", snapshot.BrowserStats, snapshot.BrowserVersions)
+	}
+	if snapshot.OSStats["Windows"] != 1 || snapshot.DeviceStats["Desktop"] != 1 {
+		t.Errorf("expected Windows desktop, got %v %v", snapshot.OSStats, snapshot.DeviceStats)
+	}
+}
+
+func TestProcessEventWeighsSampledEvents(t *testing.T) {
+	service := NewService()
+	for i := 0; i < 10; i++ {
+		service.ProcessEvent(&models.AnalyticsEvent{
+			ID:        fmt.Sprint(i),
+			Type:      models.PageView,
+			Timestamp: time.Now(),
+			UserID:    "u1",
+			URL:       "https://example.com/pricing",
+			Referrer:  "https://news.example.org/post",
+			UserAgent: edgeUserAgent,
+			Metadata:  map[string]interface{}{models.MetadataSampleRate: 0.4},
+		})
+	}
+
+	// Ten events kept at 40% stand for 25, which rounding each weight to 3 would overstate
+	snapshot := service.GetSnapshot()
+	if snapshot.TotalEvents != 25 || len(snapshot.TopPages) != 1 || snapshot.TopPages[0].Views != 25 {
+		t.Errorf("expected 25 events and page views, got %d and %+v", snapshot.TotalEvents, snapshot.TopPages)
+	}
+	if len(snapshot.TrafficSources) != 1 || snapshot.TrafficSources[0].Count != 25 {
+		t.Errorf("expected 25 referred visits, got %+v", snapshot.TrafficSources)
+	}
+	if snapshot.BrowserStats["Edge"] != 25 || snapshot.DeviceStats["Desktop"] != 25 || snapshot.Sites["example.com"].PageViews != 25 {
+		t.Errorf("expected weighted device stats, got %v %v %+v", snapshot.BrowserStats, snapshot.DeviceStats, snapshot.Sites)
+	}
+}
+
+func TestPerformanceMetricsReportTheirSamples(t *testing.T) {
+	service := NewService()
+	if perf := service.GetSnapshot().PerformanceMetrics; perf.Samples != 0 || perf.P95LoadTime != 0 {
+		t.Errorf("expected empty load times before any page view, got %+v", perf)
+	}
+
+	for i, loadTime := range []float64{800, 1200, 4000} {
+		service.ProcessEvent(&models.AnalyticsEvent{
+			ID:        fmt.Sprint(i),
+			Type:      models.PageView,
+			Timestamp: time.Now(),
+			Metadata:  map[string]interface{}{"load_time": loadTime},
+		})
+	}
+	if perf := service.GetSnapshot().PerformanceMetrics; perf.Samples != 3 || perf.SlowPagesCount != 1 {
+		t.Errorf("expected 3 samples with 1 slow page, got %+v", perf)
+	}
+}
+
+func TestProcessEventExcludesBots(t *testing.T) {
+	service := NewService()
+	service.SetExcludeBots(true)
+
+	service.ProcessEvent(&models.AnalyticsEvent{ID: "1", Type: models.PageView, Timestamp: time.Now(), UserID: "bot", UserAgent: googlebotUserAgent})
+	service.ProcessEvent(&models.AnalyticsEvent{ID: "2", Type: models.PageView, Timestamp: time.Now(), UserID: "u1", UserAgent: edgeUserAgent})
+
+	snapshot := service.GetSnapshot()
+	if snapshot.TotalEvents != 1 || snapshot.UniqueUsers != 1 {
+		t.Errorf("expected the bot event to be excluded, got %d events from %d users", snapshot.TotalEvents, snapshot.UniqueUsers)
+	}
+	if snapshot.BotEvents != 1 {
+		t.Errorf("expected 1 bot event, got %d", snapshot.BotEvents)
+	}
+}
+
+func TestRetentionCleanup(t *testing.T) {
+	service := NewServiceWithRetention(RetentionConfig{
+		RecentEvents:   3,
+		EventTTL:       10 * time.Minute,
+		HourlyWindow:   2 * time.Hour,
+		SessionTimeout: 5 * time.Minute,
+	})
+
+	now := time.Now()
+	old := now.Add(-3 * time.Hour)
+	service.ProcessEvent(&models.AnalyticsEvent{ID: "1", Type: models.PageView, Timestamp: old, SessionID: "s-old"})
+	for i := 0; i < 3; i++ {
+		service.ProcessEvent(&models.AnalyticsEvent{ID: "new", Type: models.Click, Timestamp: now, SessionID: "s-new"})
+	}
+
+	// The buffer keeps only the configured number of recent events
+	if got := len(service.GetSnapshot().RealTimeEvents); got != 3 {
+		t.Fatalf("Expected 3 recent events, got %d", got)
+	}
+
+	service.Cleanup(now)
+	snapshot := service.GetSnapshot()
+	if snapshot.ActiveSessions != 1 {
+		t.Errorf("Expected the stale session to expire, got %d active", snapshot.ActiveSessions)
+	}
+	if rollups := service.GetHourlyRollups(); len(rollups) != 1 {
+		t.Errorf("Expected only the current hour to remain, got %d rollups", len(rollups))
+	}
+
+	// Recent events expire once older than the TTL
+	service.Cleanup(now.Add(11 * time.Minute))
+	if got := len(service.GetSnapshot().RealTimeEvents); got != 0 {
+		t.Errorf("Expected expired recent events to be dropped, got %d", got)
+	}
+}
+
+func TestSetRetentionTrimsRecentEvents(t *testing.T) {
+	service := NewService()
+	for i := 0; i < 10; i++ {
+		service.ProcessEvent(&models.AnalyticsEvent{Type: models.Click, Timestamp: time.Now()})
+	}
+
+	service.SetRetention(RetentionConfig{RecentEvents: 4, CleanupInterval: time.Hour})
+	if got := len(service.GetSnapshot().RealTimeEvents); got != 4 {
+		t.Errorf("Expected the buffer trimmed to 4 events, got %d", got)
+	}
+	if retention := service.Retention(); retention.HourlyWindow != DefaultRetention().HourlyWindow || retention.CleanupInterval != DefaultRetention().CleanupInterval {
+		t.Errorf("Expected unset values defaulted and the cleanup interval kept, got %+v", retention)
+	}
+}
+
+func TestFeatureFlagsGateProcessing(t *testing.T) {
+	service := NewService()
+	flags := features.New()
+	flags.Set(map[features.Flag]bool{features.PathFlow: false})
+	service.SetFeatures(flags)
+
+	now := time.Now()
+	service.ProcessEvent(&models.AnalyticsEvent{Type: models.PageView, Timestamp: now, SessionID: "s1", Path: "/"})
+	service.ProcessEvent(&models.AnalyticsEvent{Type: models.PageView, Timestamp: now, SessionID: "s1", Path: "/pricing"})
+	if flow := service.GetPathFlow(PathFlowQuery{Depth: 1, MinCount: 1, Limit: 10}); len(flow.Transitions) != 0 {
+		t.Errorf("Expected no transitions with path flow disabled, got %+v", flow.Transitions)
+	}
+
+	// Flags changed later apply to the next events
+	flags.Set(nil)
+	service.ProcessEvent(&models.AnalyticsEvent{Type: models.PageView, Timestamp: now, SessionID: "s2", Path: "/"})
+	service.ProcessEvent(&models.AnalyticsEvent{Type: models.PageView, Timestamp: now, SessionID: "s2", Path: "/docs"})
+	if flow := service.GetPathFlow(PathFlowQuery{Depth: 1, MinCount: 1, Limit: 10}); len(flow.Transitions) != 1 {
+		t.Errorf("Expected one transition once re-enabled, got %+v", flow.Transitions)
+	}
+}
+
+// blockingState is a SharedState whose reads wait until release is closed
+type blockingState struct {
+	reading chan struct{}
+	release chan struct{}
+	once    sync.Once
+}
+
+func (b *blockingState) Record(ctx context.Context, update SharedUpdate) error { return nil }
+
+func (b *blockingState) Reset(ctx context.Context) error { return nil }
+
+func (b *blockingState) EraseSessions(ctx context.Context, sessionIDs []string) error { return nil }
+
+func (b *blockingState) Read(ctx context.Context, sessionTimeout time.Duration, top int) (*SharedCounters, error) {
+	b.once.Do(func() { close(b.reading) })
+	select {
+	case <-b.release:
+	case <-ctx.Done():
+	}
+	return nil, fmt.Errorf("unavailable")
+}
+
+func TestCheckAlertsDoesNotBlockConfigReads(t *testing.T) {
+	service := NewService()
+	service.SetAlerts(DefaultAlerts())
+	state := &blockingState{reading: make(chan struct{}), release: make(chan struct{})}
+	service.SetSharedState(state, false)
+
+	checked := make(chan struct{})
+	go func() {
+		service.CheckAlerts()
+		close(checked)
+	}()
+	<-state.reading
+
+	// Configuration stays readable while the snapshot waits on shared state
+	read := make(chan struct{})
+	go func() {
+		service.AlertConfigs()
+		service.Dashboards()
+		service.GetActiveAlerts()
+		close(read)
+	}()
+	select {
+	case <-read:
+	case <-time.After(time.Second):
+		t.Error("Expected configuration reads not to wait for the alert check")
+	}
+
+	close(state.release)
+	<-checked
+}
+
+// TestServiceConcurrentUse exercises processing, snapshots, alert evaluation and
+// configuration changes at once; run with -race
+func TestServiceConcurrentUse(t *testing.T) {
+	service := NewService()
+	service.SetAlerts(append(DefaultAlerts(), models.AlertConfig{
+		Name: "Checkout Slowdown", Type: "performance", Metric: "average_load_time", Path: "/checkout",
+		Threshold: 50, Operator: OperatorPctIncrease, Enabled: true, WindowMinutes: 5,
+	}))
+	dashboard := models.Dashboard{Name: "ops", Widgets: []models.Widget{{ID: "events", Metric: "total_events", Chart: "number"}}}
+	if err := service.CreateDashboard(dashboard); err != nil {
+		t.Fatalf("CreateDashboard failed: %v", err)
+	}
+
+	const iterations = 200
+	var wg sync.WaitGroup
+	run := func(work func(i int)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				work(i)
+			}
+		}()
+	}
+
+	for worker := 0; worker < 4; worker++ {
+		run(func(i int) {
+			service.ProcessEvent(&models.AnalyticsEvent{
+				ID:        fmt.Sprintf("w%d-%d", worker, i),
+				Type:      models.PageView,
+				UserID:    fmt.Sprintf("user%d", i%10),
+				SessionID: fmt.Sprintf("session%d", i%5),
+				URL:       "https://example.com/checkout",
+				Path:      "/checkout",
+				Timestamp: time.Now(),
+				Metadata:  map[string]interface{}{"load_time": float64(i)},
+			})
+		})
+	}
+	run(func(int) { service.GetSnapshot() })
+	run(func(int) { service.DashboardData(dashboard) })
+	run(func(int) { service.CheckAlerts() })
+	run(func(i int) { service.SetAlertEnabled("Traffic Surge Alert", i%2 == 0) })
+	run(func(int) {
+		service.GetActiveAlerts()
+		service.GetAlertHistory()
+		service.Dashboards()
+	})
+	run(func(i int) {
+		if i%50 == 0 {
+			service.Reset()
+		}
+		service.Cleanup(time.Now())
+	})
+	wg.Wait()
+
+	if _, ok := service.AlertConfig("Traffic Surge Alert"); !ok {
+		t.Error("Expected the alert config to survive concurrent changes")
+	}
+}
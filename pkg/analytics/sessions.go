@@ -0,0 +1,204 @@
+package analytics
+
+import (
+	"math"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+// sessionLengthBuckets are the ranges of the session duration histogram, by lower bound
+// in seconds; each ends where the next one starts
+var sessionLengthBuckets = []struct {
+	label string
+	min   float64
+}{
+	{"<10s", 0},
+	{"10-60s", 10},
+	{"1-5m", 60},
+	{"5-30m", 300},
+	{">30m", 1800},
+}
+
+// sessionActivity is what has been seen of a session that has not ended yet
+type sessionActivity struct {
+	start, last time.Time
+	pages       int64           // Page views in the session
+	ended       bool            // A session event reported its length; later events are ignored
+	goals       map[string]bool // Conversion goals completed in the session
+}
+
+// sessionTracker measures the length of sessions. A session ends when a session event
+// reports its duration in the "duration" metadata (seconds), with its "page_count" when
+// set, or when it times out, with the time between its first and last event and its page
+// views. Only ended sessions are counted, so active sessions are not cut short.
+// It also indexes sessions by user for erasure, for the hourly window after they were
+// last active, so a user's sessions are found once their events leave the recent buffer.
+type sessionTracker struct {
+	sessions  map[string]*sessionActivity     // Session ID -> activity, until the session expires
+	byUser    map[string]map[string]time.Time // User ID -> session ID -> last active
+	durations *QuantileSketch                 // Seconds
+	buckets   []int64                         // Sessions per sessionLengthBuckets range
+	pages     int64                           // Page views of ended sessions
+	started   int64                           // Sessions seen, ended or not
+}
+
+func newSessionTracker() *sessionTracker {
+	return &sessionTracker{
+		sessions:  make(map[string]*sessionActivity),
+		byUser:    make(map[string]map[string]time.Time),
+		durations: NewQuantileSketch(),
+		buckets:   make([]int64, len(sessionLengthBuckets)),
+	}
+}
+
+// processSessionActivity records an event as activity of its session, ending the session
+// if it is a session event reporting a duration. The caller must hold the analytics lock.
+func (s *Service) processSessionActivity(event *models.AnalyticsEvent) {
+	if event.SessionID == "" {
+		return
+	}
+
+	t := s.sessions
+	if event.UserID != "" {
+		t.recordUser(event.UserID, event.SessionID, event.Timestamp)
+	}
+	session := t.sessions[event.SessionID]
+	if session == nil {
+		session = &sessionActivity{start: event.Timestamp, last: event.Timestamp}
+		t.sessions[event.SessionID] = session
+		t.started++
+	}
+	if session.ended {
+		return
+	}
+	if event.Timestamp.Before(session.start) {
+		session.start = event.Timestamp
+	}
+	if event.Timestamp.After(session.last) {
+		session.last = event.Timestamp
+	}
+	if event.Type == models.PageView {
+		session.pages++
+	}
+
+	if event.Type != models.Session {
+		return
+	}
+	duration, ok := event.Metadata["duration"].(float64)
+	if !ok || duration < 0 {
+		return
+	}
+	pages := session.pages
+	if count, ok := event.Metadata["page_count"].(float64); ok && count >= 0 {
+		pages = int64(count)
+	}
+	t.end(duration, pages)
+	session.ended = true
+}
+
+// end counts a session of the given length in seconds and page views
+func (t *sessionTracker) end(duration float64, pages int64) {
+	t.durations.Add(duration)
+	t.pages += pages
+	bucket := 0
+	for i, b := range sessionLengthBuckets {
+		if duration >= b.min {
+			bucket = i
+		}
+	}
+	t.buckets[bucket]++
+}
+
+// expire ends sessions that are no longer active and forgets them
+func (t *sessionTracker) expire(active map[string]time.Time) {
+	for sessionID, session := range t.sessions {
+		if _, ok := active[sessionID]; ok {
+			continue
+		}
+		if !session.ended {
+			t.end(session.last.Sub(session.start).Seconds(), session.pages)
+		}
+		delete(t.sessions, sessionID)
+	}
+}
+
+// recordUser indexes a session under its user as last active at seen, but never later
+// than now
+func (t *sessionTracker) recordUser(userID, sessionID string, seen time.Time) {
+	if now := time.Now(); seen.After(now) {
+		seen = now
+	}
+	sessions := t.byUser[userID]
+	if sessions == nil {
+		sessions = make(map[string]time.Time)
+		t.byUser[userID] = sessions
+	}
+	if seen.After(sessions[sessionID]) {
+		sessions[sessionID] = seen
+	}
+}
+
+// mergeUser moves the sessions indexed under one user to another
+func (t *sessionTracker) mergeUser(from, to string) {
+	for sessionID, seen := range t.byUser[from] {
+		t.recordUser(to, sessionID, seen)
+	}
+	delete(t.byUser, from)
+}
+
+// forgetUser removes a user from the index and returns their sessions
+func (t *sessionTracker) forgetUser(userID string) []string {
+	var sessions []string
+	for sessionID := range t.byUser[userID] {
+		sessions = append(sessions, sessionID)
+	}
+	delete(t.byUser, userID)
+	return sessions
+}
+
+// expireUsers forgets the indexed sessions last active before the cutoff
+func (t *sessionTracker) expireUsers(cutoff time.Time) {
+	for userID, sessions := range t.byUser {
+		for sessionID, seen := range sessions {
+			if seen.Before(cutoff) {
+				delete(sessions, sessionID)
+			}
+		}
+		if len(sessions) == 0 {
+			delete(t.byUser, userID)
+		}
+	}
+}
+
+// stats returns the length distribution of ended sessions
+func (t *sessionTracker) stats() models.SessionStats {
+	count := t.durations.Count()
+	stats := models.SessionStats{
+		Sessions:               count,
+		AverageDurationSeconds: t.durations.Mean(),
+		MedianDurationSeconds:  t.durations.Quantile(0.5),
+		DurationHistogram:      make([]models.SessionLengthBucket, len(sessionLengthBuckets)),
+	}
+	if count > 0 {
+		stats.PagesPerSession = math.Round(float64(t.pages)/float64(count)*100) / 100
+	}
+	for i, b := range sessionLengthBuckets {
+		bucket := models.SessionLengthBucket{Label: b.label, MinSeconds: b.min, Count: t.buckets[i]}
+		if i+1 < len(sessionLengthBuckets) {
+			bucket.MaxSeconds = sessionLengthBuckets[i+1].min
+		}
+		if count > 0 {
+			bucket.Percent = float64(t.buckets[i]) / float64(count) * 100
+		}
+		stats.DurationHistogram[i] = bucket
+	}
+	return stats
+}
+
+// GetSessionStats returns the length distribution of sessions that have ended
+func (s *Service) GetSessionStats() models.SessionStats {
+	s.analytics.Mu.RLock()
+	defer s.analytics.Mu.RUnlock()
+	return s.sessions.stats()
+}
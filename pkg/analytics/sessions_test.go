@@ -0,0 +1,54 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+func TestSessionLengths(t *testing.T) {
+	service := NewService()
+	start := time.Now().Add(-2 * time.Hour)
+
+	// s1 is derived from its events: 3 page views over 2 minutes
+	for _, offset := range []time.Duration{0, time.Minute, 2 * time.Minute} {
+		service.ProcessEvent(&models.AnalyticsEvent{Type: models.PageView, Timestamp: start.Add(offset), SessionID: "s1", Path: "/"})
+	}
+	service.ProcessEvent(&models.AnalyticsEvent{Type: models.Click, Timestamp: start.Add(90 * time.Second), SessionID: "s1"})
+
+	// s2 reports its length; later events don't change it
+	service.ProcessEvent(&models.AnalyticsEvent{Type: models.PageView, Timestamp: start, SessionID: "s2", Path: "/"})
+	service.ProcessEvent(&models.AnalyticsEvent{Type: models.Session, Timestamp: start, SessionID: "s2",
+		Metadata: map[string]interface{}{"duration": float64(2400), "page_count": float64(7)}})
+	service.ProcessEvent(&models.AnalyticsEvent{Type: models.PageView, Timestamp: start.Add(time.Hour), SessionID: "s2", Path: "/"})
+
+	// s3 is a single page view, still active
+	service.ProcessEvent(&models.AnalyticsEvent{Type: models.PageView, Timestamp: time.Now(), SessionID: "s3", Path: "/"})
+
+	if stats := service.GetSessionStats(); stats.Sessions != 1 || stats.PagesPerSession != 7 {
+		t.Fatalf("expected only the reported session to have ended, got %+v", stats)
+	}
+
+	service.Cleanup(time.Now())
+	stats := service.GetSessionStats()
+	if stats.Sessions != 2 || stats.PagesPerSession != 5 {
+		t.Fatalf("expected the timed-out session to end, got %+v", stats)
+	}
+	if stats.AverageDurationSeconds != 1260 {
+		t.Errorf("expected an average of 1260s, got %v", stats.AverageDurationSeconds)
+	}
+
+	wantCounts := map[string]int64{"<10s": 0, "10-60s": 0, "1-5m": 1, "5-30m": 0, ">30m": 1}
+	if len(stats.DurationHistogram) != len(wantCounts) {
+		t.Fatalf("expected %d buckets, got %+v", len(wantCounts), stats.DurationHistogram)
+	}
+	for _, bucket := range stats.DurationHistogram {
+		if bucket.Count != wantCounts[bucket.Label] {
+			t.Errorf("expected %d sessions in %s, got %d", wantCounts[bucket.Label], bucket.Label, bucket.Count)
+		}
+	}
+	if last := stats.DurationHistogram[4]; last.MinSeconds != 1800 || last.MaxSeconds != 0 || last.Percent != 50 {
+		t.Errorf("unexpected open-ended bucket %+v", last)
+	}
+}
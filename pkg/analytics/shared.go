@@ -0,0 +1,238 @@
+package analytics
+
+import (
+	"context"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/logging"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/useragent"
+)
+
+// SharedState aggregates the core counters of several replicas in one place, so
+// every replica reports the same totals
+type SharedState interface {
+	// Record adds one counted event to the shared counters
+	Record(ctx context.Context, update SharedUpdate) error
+
+	// Read returns the shared counters with up to top pages and sources; sessions
+	// seen within sessionTimeout are active
+	Read(ctx context.Context, sessionTimeout time.Duration, top int) (*SharedCounters, error)
+
+	// Reset clears the shared counters
+	Reset(ctx context.Context) error
+
+	// EraseSessions removes sessions from the active sessions
+	EraseSessions(ctx context.Context, sessionIDs []string) error
+}
+
+// SharedUpdate is what one counted event contributes to the shared counters
+type SharedUpdate struct {
+	Timestamp time.Time
+	Weight    int64 // Sampling weight applied to event counters
+	Type      models.EventType
+	UserID    string
+	SessionID string
+	Path      string // Normalized page path, set for page views
+	Source    string // Referrer domain, without "www."
+	Device    string // Set when the user agent was parsed
+	Browser   string
+	OS        string
+}
+
+// SharedCounters are the totals read from the shared state. Pages and sources hold
+// the top entries only; SourceTotal counts every referral.
+type SharedCounters struct {
+	TotalEvents    int64
+	UniqueUsers    int64
+	ActiveSessions int64
+	EventsByType   map[models.EventType]int64
+	Pages          []SharedPage
+	Sources        map[string]int64
+	SourceTotal    int64
+	Devices        map[string]int64
+	Browsers       map[string]int64
+	OS             map[string]int64
+}
+
+// SharedPage is the shared view and visitor count of a page path
+type SharedPage struct {
+	Path           string
+	Views          int64
+	UniqueVisitors int64
+}
+
+// sharedStateTimeout bounds each shared state call so an unreachable store cannot
+// stall event processing or snapshots
+const sharedStateTimeout = 2 * time.Second
+
+// sharedWarnInterval limits how often shared state failures are logged
+const sharedWarnInterval = time.Minute
+
+// SetSharedState makes the service report the core counters (events, users, sessions,
+// event types, top pages, traffic sources, devices, browsers and OS) from state shared
+// with other replicas. With contribute set, counted events are also recorded there;
+// replicas that see the same events as a contributing one, like the producer next to
+// the consumers, should only read. Other metrics stay per replica.
+func (s *Service) SetSharedState(state SharedState, contribute bool) {
+	s.analytics.Mu.Lock()
+	defer s.analytics.Mu.Unlock()
+	s.shared = state
+	s.sharedContribute = contribute
+}
+
+// newSharedUpdate describes a counted event, viewing pageURL if it's a page view, for
+// the shared counters
+func newSharedUpdate(event *models.AnalyticsEvent, pageURL string, agent *useragent.Info, weight int64) SharedUpdate {
+	update := SharedUpdate{
+		Timestamp: event.Timestamp,
+		Weight:    weight,
+		Type:      event.Type,
+		UserID:    event.UserID,
+		SessionID: event.SessionID,
+		Source:    referrerDomain(event.Referrer),
+	}
+	if event.Type == models.PageView {
+		update.Path = sharedPath(pageURL)
+	}
+	if agent != nil {
+		update.Device, update.Browser, update.OS = agent.Device, agent.Browser, agent.OS
+	}
+	return update
+}
+
+// sharedPath returns the path pages are shared under: their URL's path with repeated
+// slashes collapsed, so query strings and hosts don't multiply the shared keys
+func sharedPath(pageURL string) string {
+	if u, err := url.Parse(pageURL); err == nil {
+		return collapseSlashes(u.Path)
+	}
+	return collapseSlashes(pageURL)
+}
+
+// recordShared adds a counted event to the shared state, logging failures
+func (s *Service) recordShared(state SharedState, update SharedUpdate) {
+	ctx, cancel := context.WithTimeout(context.Background(), sharedStateTimeout)
+	defer cancel()
+	if err := state.Record(ctx, update); err != nil {
+		s.warnShared("Failed to record event in shared state", err)
+	}
+}
+
+// resetShared clears the shared counters, logging failures
+func (s *Service) resetShared(state SharedState) {
+	ctx, cancel := context.WithTimeout(context.Background(), sharedStateTimeout)
+	defer cancel()
+	if err := state.Reset(ctx); err != nil {
+		s.warnShared("Failed to reset shared state", err)
+	}
+}
+
+// eraseShared removes an erased user's sessions from the shared counters, logging
+// failures
+func (s *Service) eraseShared(state SharedState, sessionIDs []string) {
+	ctx, cancel := context.WithTimeout(context.Background(), sharedStateTimeout)
+	defer cancel()
+	if err := state.EraseSessions(ctx, sessionIDs); err != nil {
+		s.warnShared("Failed to erase sessions from shared state", err)
+	}
+}
+
+// applyShared replaces the core counters of a snapshot with the shared ones. The
+// local values are kept when the shared state cannot be read.
+func (s *Service) applyShared(snapshot *models.MetricsSnapshot, limit int) {
+	s.analytics.Mu.RLock()
+	state, timeout := s.shared, s.retention.SessionTimeout
+	topPages, topSources := s.topLimits(limit)
+	s.analytics.Mu.RUnlock()
+	if state == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), sharedStateTimeout)
+	defer cancel()
+	counters, err := state.Read(ctx, timeout, max(topPages, topSources))
+	if err != nil {
+		s.warnShared("Failed to read shared state, reporting local counters", err)
+		return
+	}
+
+	snapshot.TotalEvents = counters.TotalEvents
+	snapshot.UniqueUsers = counters.UniqueUsers
+	snapshot.ActiveSessions = counters.ActiveSessions
+	snapshot.EventsByType = counters.EventsByType
+	snapshot.DeviceStats = counters.Devices
+	snapshot.BrowserStats = counters.Browsers
+	snapshot.OSStats = counters.OS
+	snapshot.TopPages = limitList(sharedPages(counters.Pages, snapshot.TopPages), topPages)
+
+	s.analytics.Mu.RLock()
+	snapshot.TrafficSources = limitList(s.sharedSources(counters), topSources)
+	s.analytics.Mu.RUnlock()
+}
+
+// sharedPages builds the top pages from shared counts, keeping the URL and timing
+// metrics of the most viewed local page with the same path
+func sharedPages(pages []SharedPage, local []models.PageMetric) []models.PageMetric {
+	localByPath := make(map[string]models.PageMetric, len(local))
+	for _, page := range local {
+		path := sharedPath(page.URL)
+		if _, ok := localByPath[path]; !ok {
+			localByPath[path] = page
+		}
+	}
+
+	result := make([]models.PageMetric, 0, len(pages))
+	for _, page := range pages {
+		metric, ok := localByPath[page.Path]
+		if !ok {
+			metric.URL = page.Path
+		}
+		metric.Path = page.Path
+		metric.Views = page.Views
+		metric.UniqueVisitors = page.UniqueVisitors
+		result = append(result, metric)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Views > result[j].Views
+	})
+	return result
+}
+
+// sharedSources builds the top traffic sources from shared counts. The caller must
+// hold the analytics lock for channel classification.
+func (s *Service) sharedSources(counters *SharedCounters) []models.TrafficSource {
+	result := make([]models.TrafficSource, 0, len(counters.Sources))
+	for source, count := range counters.Sources {
+		percent := float64(0)
+		if counters.SourceTotal > 0 {
+			percent = float64(count) / float64(counters.SourceTotal) * 100
+		}
+		result = append(result, models.TrafficSource{
+			Source:  source,
+			Count:   count,
+			Percent: percent,
+			Channel: s.classifyChannel("", source, false),
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Source < result[j].Source
+	})
+	return result
+}
+
+// warnShared logs a shared state failure at most once per sharedWarnInterval
+func (s *Service) warnShared(msg string, err error) {
+	s.sharedWarnMu.Lock()
+	defer s.sharedWarnMu.Unlock()
+	if time.Since(s.sharedWarned) < sharedWarnInterval {
+		return
+	}
+	s.sharedWarned = time.Now()
+	logging.Warn(msg, "error", err)
+}
@@ -0,0 +1,165 @@
+package analytics
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/bots"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+// memoryState is a SharedState holding exact counters in memory
+type memoryState struct {
+	updates []SharedUpdate
+	err     error
+	mu      sync.Mutex
+}
+
+func (m *memoryState) Record(ctx context.Context, update SharedUpdate) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.updates = append(m.updates, update)
+	return m.err
+}
+
+func (m *memoryState) Reset(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.updates = nil
+	return m.err
+}
+
+func (m *memoryState) EraseSessions(ctx context.Context, sessionIDs []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.updates {
+		for _, sessionID := range sessionIDs {
+			if m.updates[i].SessionID == sessionID {
+				m.updates[i].SessionID = ""
+			}
+		}
+	}
+	return m.err
+}
+
+func (m *memoryState) Read(ctx context.Context, sessionTimeout time.Duration, top int) (*SharedCounters, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	counters := &SharedCounters{
+		EventsByType: make(map[models.EventType]int64),
+		Sources:      make(map[string]int64),
+		Devices:      make(map[string]int64),
+		Browsers:     make(map[string]int64),
+		OS:           make(map[string]int64),
+	}
+	users := make(map[string]bool)
+	views := make(map[string]int64)
+	for _, u := range m.updates {
+		counters.TotalEvents += u.Weight
+		counters.EventsByType[u.Type] += u.Weight
+		if u.UserID != "" && !users[u.UserID] {
+			users[u.UserID] = true
+			counters.UniqueUsers++
+		}
+		if u.SessionID != "" {
+			counters.ActiveSessions++
+		}
+		if u.Path != "" {
+			views[u.Path] += u.Weight
+		}
+		if u.Source != "" {
+			counters.Sources[u.Source] += u.Weight
+			counters.SourceTotal += u.Weight
+		}
+	}
+	for path, count := range views {
+		counters.Pages = append(counters.Pages, SharedPage{Path: path, Views: count, UniqueVisitors: 1})
+	}
+	return counters, nil
+}
+
+func TestSharedStateReplicas(t *testing.T) {
+	state := &memoryState{}
+
+	// Two replicas each see half the events
+	replicaA, replicaB := NewService(), NewService()
+	replicaA.SetSharedState(state, true)
+	replicaB.SetSharedState(state, true)
+	replicaA.SetBotPolicy(bots.PolicySegregate, nil)
+
+	replicaA.ProcessEvent(&models.AnalyticsEvent{ID: "1", Type: models.PageView, UserID: "u1", URL: "https://example.com/", Referrer: "https://www.google.com/", Timestamp: time.Now()})
+	replicaA.ProcessEvent(&models.AnalyticsEvent{ID: "2", Type: models.PageView, UserID: "bot", URL: "https://example.com/", UserAgent: googlebotUserAgent, Timestamp: time.Now()})
+	replicaB.ProcessEvent(&models.AnalyticsEvent{ID: "3", Type: models.PageView, UserID: "u2", URL: "https://example.com/", Timestamp: time.Now()})
+	replicaB.ProcessEvent(&models.AnalyticsEvent{ID: "4", Type: models.Click, UserID: "u1", URL: "https://example.com/", Timestamp: time.Now()})
+
+	if len(state.updates) != 3 {
+		t.Fatalf("recorded %d updates, want 3 (bot traffic is not counted)", len(state.updates))
+	}
+
+	for name, service := range map[string]*Service{"A": replicaA, "B": replicaB} {
+		snapshot := service.GetSnapshot()
+		if snapshot.TotalEvents != 3 || snapshot.UniqueUsers != 2 || snapshot.EventsByType[models.PageView] != 2 {
+			t.Errorf("replica %s: events %d, users %d, page views %d; want 3, 2, 2",
+				name, snapshot.TotalEvents, snapshot.UniqueUsers, snapshot.EventsByType[models.PageView])
+		}
+		if len(snapshot.TopPages) != 1 || snapshot.TopPages[0].Path != "/" || snapshot.TopPages[0].Views != 2 {
+			t.Errorf("replica %s: unexpected top pages %+v", name, snapshot.TopPages)
+		}
+		if len(snapshot.TrafficSources) != 1 || snapshot.TrafficSources[0].Source != "google.com" ||
+			snapshot.TrafficSources[0].Percent != 100 || snapshot.TrafficSources[0].Channel != ChannelSearch {
+			t.Errorf("replica %s: unexpected traffic sources %+v", name, snapshot.TrafficSources)
+		}
+	}
+}
+
+func TestSharedStateReadOnlyAndFailures(t *testing.T) {
+	state := &memoryState{}
+	reader := NewService()
+	reader.SetSharedState(state, false)
+
+	reader.ProcessEvent(&models.AnalyticsEvent{ID: "1", Type: models.PageView, UserID: "u1", URL: "https://example.com/", Timestamp: time.Now()})
+	if len(state.updates) != 0 {
+		t.Fatal("a read-only replica should not record events")
+	}
+	if snapshot := reader.GetSnapshot(); snapshot.TotalEvents != 0 {
+		t.Errorf("TotalEvents = %d, want the shared 0", snapshot.TotalEvents)
+	}
+
+	// The local counters are reported while the shared state is unavailable
+	state.err = errors.New("connection refused")
+	if snapshot := reader.GetSnapshot(); snapshot.TotalEvents != 1 {
+		t.Errorf("TotalEvents = %d, want the local 1", snapshot.TotalEvents)
+	}
+}
+
+func TestSharedStateResetAndErasure(t *testing.T) {
+	state := &memoryState{}
+	service := NewService()
+	service.SetSharedState(state, true)
+
+	service.ProcessEvent(&models.AnalyticsEvent{ID: "1", Type: models.PageView, UserID: "u1", SessionID: "s1", URL: "https://example.com/pricing?ref=ad", Timestamp: time.Now()})
+	service.ProcessEvent(&models.AnalyticsEvent{ID: "2", Type: models.PageView, UserID: "u2", SessionID: "s2", URL: "https://example.com//pricing/", Timestamp: time.Now()})
+	if paths := []string{state.updates[0].Path, state.updates[1].Path}; paths[0] != "/pricing" || paths[1] != "/pricing" {
+		t.Errorf("expected pages shared under their normalized path, got %v", paths)
+	}
+
+	// Erasure ends the user's shared sessions; shared distinct counts can't forget them
+	if result := service.EraseUser("u1"); !result.Estimated {
+		t.Error("expected the erasure reported as estimated with shared state")
+	}
+	if snapshot := service.GetSnapshot(); snapshot.ActiveSessions != 1 {
+		t.Errorf("ActiveSessions = %d, want 1 after erasure", snapshot.ActiveSessions)
+	}
+
+	service.Reset()
+	if snapshot := service.GetSnapshot(); snapshot.TotalEvents != 0 || len(state.updates) != 0 {
+		t.Errorf("expected the shared counters cleared by a reset, got %d events", snapshot.TotalEvents)
+	}
+}
@@ -0,0 +1,156 @@
+package analytics
+
+import (
+	"math"
+	"sort"
+)
+
+const (
+	// Relative accuracy of quantile estimates
+	sketchRelativeAccuracy = 0.01
+
+	// Maximum number of buckets; the lowest buckets are merged beyond this
+	sketchMaxBuckets = 2048
+
+	// Values at or below this are counted in the zero bucket
+	sketchMinValue = 1e-9
+)
+
+// QuantileSketch is a streaming quantile estimator with bounded memory. Values are
+// counted in logarithmically sized buckets so every quantile estimate is within 1%
+// of the true value, in the style of DDSketch. It is not safe for concurrent use.
+type QuantileSketch struct {
+	gamma     float64
+	logGamma  float64
+	buckets   map[int]int64 // Bucket index -> count
+	indexes   []int         // Bucket indexes in ascending order
+	zeroCount int64
+	count     int64
+	sum       float64
+	min, max  float64
+}
+
+// NewQuantileSketch creates an empty sketch
+func NewQuantileSketch() *QuantileSketch {
+	gamma := (1 + sketchRelativeAccuracy) / (1 - sketchRelativeAccuracy)
+	return &QuantileSketch{
+		gamma:    gamma,
+		logGamma: math.Log(gamma),
+		buckets:  make(map[int]int64),
+	}
+}
+
+// Add records a value
+func (q *QuantileSketch) Add(value float64) {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return
+	}
+
+	if q.count == 0 || value < q.min {
+		q.min = value
+	}
+	if q.count == 0 || value > q.max {
+		q.max = value
+	}
+	q.count++
+	q.sum += value
+
+	if value <= sketchMinValue {
+		q.zeroCount++
+		return
+	}
+
+	index := int(math.Ceil(math.Log(value) / q.logGamma))
+	if _, ok := q.buckets[index]; !ok {
+		// Keep indexes sorted as new buckets appear
+		i := sort.SearchInts(q.indexes, index)
+		q.indexes = append(q.indexes, 0)
+		copy(q.indexes[i+1:], q.indexes[i:])
+		q.indexes[i] = index
+	}
+	q.buckets[index]++
+
+	if len(q.indexes) > sketchMaxBuckets {
+		q.collapse()
+	}
+}
+
+// Merge adds the values recorded by other to the sketch
+func (q *QuantileSketch) Merge(other *QuantileSketch) {
+	if other == nil || other.count == 0 {
+		return
+	}
+	if q.count == 0 || other.min < q.min {
+		q.min = other.min
+	}
+	if q.count == 0 || other.max > q.max {
+		q.max = other.max
+	}
+	q.count += other.count
+	q.sum += other.sum
+	q.zeroCount += other.zeroCount
+
+	for _, index := range other.indexes {
+		if _, ok := q.buckets[index]; !ok {
+			i := sort.SearchInts(q.indexes, index)
+			q.indexes = append(q.indexes, 0)
+			copy(q.indexes[i+1:], q.indexes[i:])
+			q.indexes[i] = index
+		}
+		q.buckets[index] += other.buckets[index]
+	}
+	for len(q.indexes) > sketchMaxBuckets {
+		q.collapse()
+	}
+}
+
+// collapse merges the two lowest buckets, sacrificing accuracy only for the smallest values
+func (q *QuantileSketch) collapse() {
+	lowest, next := q.indexes[0], q.indexes[1]
+	q.buckets[next] += q.buckets[lowest]
+	delete(q.buckets, lowest)
+	q.indexes = q.indexes[1:]
+}
+
+// Count returns the number of recorded values
+func (q *QuantileSketch) Count() int64 {
+	return q.count
+}
+
+// Mean returns the exact mean of the recorded values
+func (q *QuantileSketch) Mean() float64 {
+	if q.count == 0 {
+		return 0
+	}
+	return q.sum / float64(q.count)
+}
+
+// Quantile returns an estimate of the value at quantile p (0 to 1)
+func (q *QuantileSketch) Quantile(p float64) float64 {
+	if q.count == 0 {
+		return 0
+	}
+	if p <= 0 {
+		return q.min
+	}
+	if p >= 1 {
+		return q.max
+	}
+
+	// Rank of the requested value among all values, zero-based
+	rank := int64(p * float64(q.count-1))
+	if rank < q.zeroCount {
+		return math.Max(q.min, 0)
+	}
+
+	seen := q.zeroCount
+	for _, index := range q.indexes {
+		seen += q.buckets[index]
+		if seen > rank {
+			// The bucket midpoint is within the relative accuracy of every value in it
+			estimate := 2 * math.Pow(q.gamma, float64(index)) / (1 + q.gamma)
+			return math.Min(math.Max(estimate, q.min), q.max)
+		}
+	}
+	return q.max
+}
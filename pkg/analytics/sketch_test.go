@@ -0,0 +1,60 @@
+package analytics
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestQuantileSketchAccuracy(t *testing.T) {
+	sketch := NewQuantileSketch()
+	rng := rand.New(rand.NewSource(1))
+
+	// Load times are roughly log-normal with a long tail
+	values := make([]float64, 100000)
+	for i := range values {
+		values[i] = math.Exp(rng.NormFloat64()*0.8 + 7)
+		sketch.Add(values[i])
+	}
+	sort.Float64s(values)
+
+	for _, p := range []float64{0.5, 0.75, 0.9, 0.95, 0.99} {
+		exact := values[int(p*float64(len(values)-1))]
+		estimate := sketch.Quantile(p)
+		if math.Abs(estimate-exact)/exact > sketchRelativeAccuracy {
+			t.Errorf("p%.0f: estimate %.2f is not within 1%% of %.2f", p*100, estimate, exact)
+		}
+	}
+
+	if sketch.Count() != int64(len(values)) {
+		t.Errorf("expected count %d, got %d", len(values), sketch.Count())
+	}
+}
+
+func TestQuantileSketchBoundedMemory(t *testing.T) {
+	sketch := NewQuantileSketch()
+	for i := 0; i < 10000; i++ {
+		sketch.Add(math.Pow(1.1, float64(i%5000)))
+	}
+	if len(sketch.buckets) > sketchMaxBuckets {
+		t.Errorf("expected at most %d buckets, got %d", sketchMaxBuckets, len(sketch.buckets))
+	}
+}
+
+func TestQuantileSketchEmptyAndZero(t *testing.T) {
+	sketch := NewQuantileSketch()
+	if sketch.Quantile(0.99) != 0 || sketch.Mean() != 0 {
+		t.Error("expected an empty sketch to report zero")
+	}
+
+	sketch.Add(0)
+	sketch.Add(0)
+	sketch.Add(100)
+	if got := sketch.Quantile(0.5); got != 0 {
+		t.Errorf("expected median 0, got %f", got)
+	}
+	if got := sketch.Quantile(1); got != 100 {
+		t.Errorf("expected max 100, got %f", got)
+	}
+}
@@ -0,0 +1,106 @@
+package analytics
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+)
+
+// defaultTopN is how many top pages and traffic sources snapshots include by default
+const defaultTopN = 10
+
+// topN returns the first n items in the order given by before, selecting them with
+// a bounded heap: O(len(items) log n) instead of sorting every item. items is not
+// modified.
+func topN[T any](items []T, n int, before func(a, b T) bool) []T {
+	if n <= 0 {
+		return []T{}
+	}
+
+	// h is a heap whose root is the kept item that sorts last, so it is the one
+	// replaced when a better item arrives
+	h := make([]T, 0, min(n, len(items)))
+	for _, item := range items {
+		if len(h) < n {
+			h = append(h, item)
+			siftUp(h, len(h)-1, before)
+			continue
+		}
+		if before(item, h[0]) {
+			h[0] = item
+			siftDown(h, 0, before)
+		}
+	}
+
+	sort.Slice(h, func(i, j int) bool { return before(h[i], h[j]) })
+	return h
+}
+
+// siftUp restores the heap after the item at i was appended
+func siftUp[T any](h []T, i int, before func(a, b T) bool) {
+	for i > 0 {
+		parent := (i - 1) / 2
+		if !before(h[parent], h[i]) {
+			return
+		}
+		h[parent], h[i] = h[i], h[parent]
+		i = parent
+	}
+}
+
+// siftDown restores the heap after the item at i was replaced
+func siftDown[T any](h []T, i int, before func(a, b T) bool) {
+	for {
+		last := i
+		for _, child := range []int{2*i + 1, 2*i + 2} {
+			if child < len(h) && before(h[last], h[child]) {
+				last = child
+			}
+		}
+		if last == i {
+			return
+		}
+		h[i], h[last] = h[last], h[i]
+		i = last
+	}
+}
+
+// SetTopLimits sets how many top pages and traffic sources snapshots include; a
+// non-positive value uses the default of 10
+func (s *Service) SetTopLimits(pages, sources int) {
+	s.analytics.Mu.Lock()
+	defer s.analytics.Mu.Unlock()
+	s.topPages = pages
+	s.topSources = sources
+}
+
+// ParseTopLimit reads the limit query parameter of a snapshot request; 0 means the
+// parameter was not given
+func ParseTopLimit(values url.Values) (int, error) {
+	v := values.Get("limit")
+	if v == "" {
+		return 0, nil
+	}
+	limit, err := strconv.Atoi(v)
+	if err != nil || limit < 1 || limit > MaxQueryLimit {
+		return 0, fmt.Errorf("limit must be between 1 and %d", MaxQueryLimit)
+	}
+	return limit, nil
+}
+
+// topLimits returns the snapshot limits for a request: limit when positive,
+// otherwise the configured sizes. The caller must hold the analytics lock.
+func (s *Service) topLimits(limit int) (pages, sources int) {
+	if limit > 0 {
+		return limit, limit
+	}
+	pages, sources = s.topPages, s.topSources
+	if pages <= 0 {
+		pages = defaultTopN
+	}
+	if sources <= 0 {
+		sources = defaultTopN
+	}
+	return pages, sources
+}
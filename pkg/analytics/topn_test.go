@@ -0,0 +1,80 @@
+package analytics
+
+import (
+	"fmt"
+	"math/rand"
+	"net/url"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+func TestTopNMatchesSort(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	items := make([]int, 500)
+	for i := range items {
+		items[i] = rng.Intn(100)
+	}
+	sorted := append([]int(nil), items...)
+	sort.Sort(sort.Reverse(sort.IntSlice(sorted)))
+
+	for _, n := range []int{0, 1, 7, 500, 1000} {
+		top := topN(items, n, func(a, b int) bool { return a > b })
+		want := sorted[:min(n, len(sorted))]
+		if fmt.Sprint(top) != fmt.Sprint(want) {
+			t.Errorf("topN(%d) = %v, want %v", n, top, want)
+		}
+	}
+}
+
+func TestSnapshotTopLimits(t *testing.T) {
+	service := NewService()
+	now := time.Now()
+	for i := 0; i < 30; i++ {
+		// Page i gets i+1 views, each referred by its own source
+		for j := 0; j <= i; j++ {
+			service.ProcessEvent(&models.AnalyticsEvent{
+				ID: fmt.Sprintf("%d-%d", i, j), Type: models.PageView, Timestamp: now, UserID: "u1",
+				URL: fmt.Sprintf("https://example.com/p%d", i), Referrer: fmt.Sprintf("https://s%d.example.org/", i),
+			})
+		}
+	}
+
+	snapshot := service.GetSnapshot()
+	if len(snapshot.TopPages) != 10 || len(snapshot.TrafficSources) != 10 {
+		t.Fatalf("expected 10 pages and sources by default, got %d and %d", len(snapshot.TopPages), len(snapshot.TrafficSources))
+	}
+	if snapshot.TopPages[0].Path != "/p29" || snapshot.TopPages[0].Views != 30 {
+		t.Errorf("expected /p29 with 30 views first, got %+v", snapshot.TopPages[0])
+	}
+
+	service.SetTopLimits(3, 25)
+	snapshot = service.GetSnapshot()
+	if len(snapshot.TopPages) != 3 || len(snapshot.TrafficSources) != 25 {
+		t.Errorf("expected 3 pages and 25 sources, got %d and %d", len(snapshot.TopPages), len(snapshot.TrafficSources))
+	}
+
+	snapshot = service.GetSnapshotTop(50)
+	if len(snapshot.TopPages) != 30 || len(snapshot.TrafficSources) != 30 {
+		t.Errorf("expected every page and source with limit 50, got %d and %d", len(snapshot.TopPages), len(snapshot.TrafficSources))
+	}
+	if last := snapshot.TrafficSources[29]; last.Count != 1 {
+		t.Errorf("expected the least referred source last, got %+v", last)
+	}
+}
+
+func TestParseTopLimit(t *testing.T) {
+	if limit, err := ParseTopLimit(url.Values{}); err != nil || limit != 0 {
+		t.Errorf("expected no limit, got %d, %v", limit, err)
+	}
+	if limit, err := ParseTopLimit(url.Values{"limit": {"25"}}); err != nil || limit != 25 {
+		t.Errorf("expected limit 25, got %d, %v", limit, err)
+	}
+	for _, v := range []string{"0", "-1", "abc", "1001"} {
+		if _, err := ParseTopLimit(url.Values{"limit": {v}}); err == nil {
+			t.Errorf("expected limit %q to be rejected", v)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package analytics
+
+import (
+	"sort"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+// Active visitor windows and the number of pages reported
+const (
+	activeShortWindow = time.Minute
+	activeLongWindow  = 5 * time.Minute
+	activePagesLimit  = 20
+)
+
+// activeVisitor is a visitor's latest activity
+type activeVisitor struct {
+	lastSeen time.Time
+	path     string // Page of the latest event with one
+}
+
+// visitorTracker records when each visitor was last active. Visitors idle for longer
+// than activeLongWindow drop out of the counts, and out of memory on the next Cleanup.
+type visitorTracker struct {
+	visitors map[string]*activeVisitor // User ID, or session ID of anonymous visitors
+}
+
+func newVisitorTracker() *visitorTracker {
+	return &visitorTracker{visitors: make(map[string]*activeVisitor)}
+}
+
+// visitorKey identifies the visitor of an event, or returns "" if the event has neither
+// a user nor a session
+func visitorKey(event *models.AnalyticsEvent) string {
+	if event.UserID != "" {
+		return "user:" + event.UserID
+	}
+	if event.SessionID != "" {
+		return "session:" + event.SessionID
+	}
+	return ""
+}
+
+// processActiveVisitor records the event's visitor as active. Timestamps in the future
+// count as now, so clock skew cannot keep a visitor active, and replayed events older
+// than the long window are ignored. The caller must hold the analytics lock.
+func (s *Service) processActiveVisitor(event *models.AnalyticsEvent) {
+	key := visitorKey(event)
+	if key == "" {
+		return
+	}
+	now := time.Now()
+	seen := event.Timestamp
+	if seen.After(now) {
+		seen = now
+	}
+	if now.Sub(seen) > activeLongWindow {
+		return
+	}
+
+	visitor := s.visitors.visitors[key]
+	if visitor == nil {
+		visitor = &activeVisitor{}
+		s.visitors.visitors[key] = visitor
+	}
+	if seen.Before(visitor.lastSeen) {
+		return
+	}
+	visitor.lastSeen = seen
+	if event.Path != "" {
+		visitor.path = event.Path
+	}
+}
+
+// expire removes visitors idle for longer than the long window
+func (t *visitorTracker) expire(now time.Time) {
+	for key, visitor := range t.visitors {
+		if now.Sub(visitor.lastSeen) > activeLongWindow {
+			delete(t.visitors, key)
+		}
+	}
+}
+
+// GetActiveVisitors returns the visitors active in the last one and five minutes, in
+// total and by the page of their latest event
+func (s *Service) GetActiveVisitors() models.ActiveVisitors {
+	now := time.Now()
+	result := models.ActiveVisitors{Timestamp: now, Pages: []models.ActivePage{}}
+	pages := make(map[string]*models.ActivePage)
+
+	s.analytics.Mu.RLock()
+	for _, visitor := range s.visitors.visitors {
+		idle := now.Sub(visitor.lastSeen)
+		if idle > activeLongWindow {
+			continue
+		}
+		recent := idle <= activeShortWindow
+
+		result.LastFiveMinutes++
+		if recent {
+			result.LastMinute++
+		}
+		if visitor.path == "" {
+			continue
+		}
+		page := pages[visitor.path]
+		if page == nil {
+			page = &models.ActivePage{Path: visitor.path}
+			pages[visitor.path] = page
+		}
+		page.LastFiveMinutes++
+		if recent {
+			page.LastMinute++
+		}
+	}
+	s.analytics.Mu.RUnlock()
+
+	for _, page := range pages {
+		result.Pages = append(result.Pages, *page)
+	}
+	sort.Slice(result.Pages, func(i, j int) bool {
+		a, b := result.Pages[i], result.Pages[j]
+		if a.LastMinute != b.LastMinute {
+			return a.LastMinute > b.LastMinute
+		}
+		if a.LastFiveMinutes != b.LastFiveMinutes {
+			return a.LastFiveMinutes > b.LastFiveMinutes
+		}
+		return a.Path < b.Path
+	})
+	if len(result.Pages) > activePagesLimit {
+		result.Pages = result.Pages[:activePagesLimit]
+	}
+	return result
+}
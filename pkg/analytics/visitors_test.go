@@ -0,0 +1,35 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+func TestActiveVisitors(t *testing.T) {
+	service := NewService()
+	now := time.Now()
+	service.ProcessEvent(&models.AnalyticsEvent{Type: models.PageView, Timestamp: now.Add(-3 * time.Minute), UserID: "u1", Path: "/pricing"})
+	service.ProcessEvent(&models.AnalyticsEvent{Type: models.PageView, Timestamp: now.Add(-10 * time.Second), UserID: "u1", Path: "/checkout"})
+	service.ProcessEvent(&models.AnalyticsEvent{Type: models.Click, Timestamp: now.Add(-2 * time.Minute), SessionID: "anon", Path: "/pricing"})
+	service.ProcessEvent(&models.AnalyticsEvent{Type: models.PageView, Timestamp: now.Add(-10 * time.Minute), UserID: "u2", Path: "/"})
+
+	active := service.GetActiveVisitors()
+	if active.LastMinute != 1 || active.LastFiveMinutes != 2 {
+		t.Fatalf("expected 1 visitor in the last minute and 2 in five, got %d and %d", active.LastMinute, active.LastFiveMinutes)
+	}
+	want := []models.ActivePage{
+		{Path: "/checkout", LastMinute: 1, LastFiveMinutes: 1},
+		{Path: "/pricing", LastMinute: 0, LastFiveMinutes: 1},
+	}
+	if len(active.Pages) != len(want) || active.Pages[0] != want[0] || active.Pages[1] != want[1] {
+		t.Errorf("expected pages %+v, got %+v", want, active.Pages)
+	}
+
+	// Visitors drop out of memory once idle for longer than the long window
+	service.Cleanup(now.Add(4 * time.Minute))
+	if len(service.visitors.visitors) != 1 {
+		t.Errorf("expected only u1 to be kept after cleanup, got %d visitors", len(service.visitors.visitors))
+	}
+}
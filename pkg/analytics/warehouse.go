@@ -0,0 +1,77 @@
+package analytics
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/logging"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+// Warehouse answers historical queries from archived raw events, such as the ClickHouse
+// events table the consumer writes
+type Warehouse interface {
+	// QueryRollups returns consecutive buckets of the granularity starting in [from, to)
+	QueryRollups(ctx context.Context, granularity models.Granularity, from, to time.Time) ([]models.Rollup, error)
+
+	// TopPages returns the most viewed pages in [from, to)
+	TopPages(ctx context.Context, from, to time.Time, limit int) ([]models.PageMetric, error)
+
+	// Funnel returns the number of visitors in [from, to) that completed each step in
+	// order within the window of the first
+	Funnel(ctx context.Context, steps []models.Goal, from, to time.Time, window time.Duration) ([]int64, error)
+}
+
+// SetWarehouse makes history queries read archived events from the warehouse, falling
+// back to in-memory data and the rollup store when it fails
+func (h *History) SetWarehouse(warehouse Warehouse) {
+	h.warehouse = warehouse
+}
+
+// Rollups answers a history query from the warehouse when one is set, and otherwise or
+// when it fails from Query
+func (h *History) Rollups(ctx context.Context, from, to time.Time, granularity models.Granularity) ([]models.Rollup, models.HistorySource, error) {
+	if err := validateHistoryRange(from, to, granularity); err != nil {
+		return nil, models.HistorySourceMemory, err
+	}
+	if h.warehouse != nil {
+		rollups, err := h.warehouse.QueryRollups(ctx, granularity, granularity.Start(from), to)
+		if err == nil {
+			return rollups, models.HistorySourceClickHouse, nil
+		}
+		logging.Warn("Warehouse history query failed, using in-memory history", "error", err)
+	}
+	rollups, err := h.Query(ctx, from, to, granularity)
+	return rollups, models.HistorySourceMemory, err
+}
+
+// TopPages returns the most viewed pages in [from, to) from the warehouse. Without one,
+// or when it fails, it returns the in-memory page metrics, which cover everything since
+// the last reset regardless of the range.
+func (h *History) TopPages(ctx context.Context, from, to time.Time, limit int) ([]models.PageMetric, models.HistorySource, error) {
+	if !from.Before(to) {
+		return nil, models.HistorySourceMemory, fmt.Errorf("from must be before to")
+	}
+	if h.warehouse != nil {
+		pages, err := h.warehouse.TopPages(ctx, from, to, limit)
+		if err == nil {
+			return pages, models.HistorySourceClickHouse, nil
+		}
+		logging.Warn("Warehouse top pages query failed, using in-memory pages", "error", err)
+	}
+	return h.service.GetSnapshotTop(limit).TopPages, models.HistorySourceMemory, nil
+}
+
+// Funnel computes the funnel from the warehouse, or from the recent events buffer when
+// there is none or it fails
+func (h *History) Funnel(ctx context.Context, q FunnelQuery) (models.Funnel, models.HistorySource, error) {
+	if h.warehouse != nil {
+		reached, err := h.warehouse.Funnel(ctx, q.Steps, q.From, q.To, q.Window)
+		if err == nil {
+			return NewFunnel(q, reached), models.HistorySourceClickHouse, nil
+		}
+		logging.Warn("Warehouse funnel query failed, using recent events", "error", err)
+	}
+	return h.service.GetFunnel(q), models.HistorySourceMemory, nil
+}
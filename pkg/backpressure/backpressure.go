@@ -0,0 +1,227 @@
+// Package backpressure lets consumers tell producers to slow ingestion down. Each
+// consumer instance publishes a Signal to a shared Store when its lag or processing
+// queue crosses a threshold, and producers reject events with 429 while any recent
+// signal asks them to.
+package backpressure
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/logging"
+)
+
+// Signal is one consumer instance's throttle state
+type Signal struct {
+	Instance          string    `json:"instance"`
+	Throttled         bool      `json:"throttled"`
+	Reason            string    `json:"reason,omitempty"`
+	Lag               int64     `json:"lag"`
+	QueueDepth        int       `json:"queue_depth"`
+	RetryAfterSeconds int       `json:"retry_after_seconds,omitempty"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+// Store shares the consumers' signals with the producers
+type Store interface {
+	// Publish replaces the signal of the signal's instance
+	Publish(ctx context.Context, signal Signal) error
+
+	// Signals returns the latest signal of every instance
+	Signals(ctx context.Context) ([]Signal, error)
+
+	// Remove deletes an instance's signal, e.g. when it shuts down
+	Remove(ctx context.Context, instance string) error
+
+	// Close releases any resources held by the store
+	Close() error
+}
+
+// Thresholds decide when a consumer asks for throttling. A zero limit is not checked.
+// Once throttled, the consumer keeps asking until both values fall to half their limit,
+// so the signal doesn't flap around the threshold.
+type Thresholds struct {
+	MaxLag        int64
+	MaxQueueDepth int
+	RetryAfter    time.Duration // Suggested to clients while throttled
+}
+
+// Evaluate returns the throttle state for lag and queueDepth, given whether the
+// consumer was throttled before
+func (t Thresholds) Evaluate(lag int64, queueDepth int, throttled bool) (bool, string) {
+	lagLimit, queueLimit := t.MaxLag, t.MaxQueueDepth
+	if throttled {
+		lagLimit, queueLimit = lagLimit/2, queueLimit/2
+	}
+	switch {
+	case t.MaxLag > 0 && lag > lagLimit:
+		return true, fmt.Sprintf("consumer lag %d exceeds %d", lag, lagLimit)
+	case t.MaxQueueDepth > 0 && queueDepth > queueLimit:
+		return true, fmt.Sprintf("processing queue %d exceeds %d", queueDepth, queueLimit)
+	}
+	return false, ""
+}
+
+// Monitor periodically measures a consumer and publishes its signal
+type Monitor struct {
+	store      Store
+	instance   string
+	thresholds Thresholds
+	lag        func(ctx context.Context) (int64, error) // nil when lag isn't measured
+	queueDepth func() int                               // nil when the queue isn't measured
+
+	current Signal
+	mu      sync.Mutex
+}
+
+// NewMonitor creates a monitor publishing the signal of instance. lag and queueDepth
+// measure the consumer; either may be nil.
+func NewMonitor(store Store, instance string, thresholds Thresholds, lag func(ctx context.Context) (int64, error), queueDepth func() int) *Monitor {
+	return &Monitor{store: store, instance: instance, thresholds: thresholds, lag: lag, queueDepth: queueDepth}
+}
+
+// Check measures the consumer and publishes its signal. A lag that can't be measured
+// is left out rather than failing the check.
+func (m *Monitor) Check(ctx context.Context) (Signal, error) {
+	signal := Signal{Instance: m.instance, UpdatedAt: time.Now()}
+	if m.lag != nil {
+		lag, err := m.lag(ctx)
+		if err != nil {
+			logging.Debug("Failed to measure consumer lag for back-pressure", "error", err)
+		}
+		signal.Lag = lag
+	}
+	if m.queueDepth != nil {
+		signal.QueueDepth = m.queueDepth()
+	}
+
+	m.mu.Lock()
+	wasThrottled := m.current.Throttled
+	signal.Throttled, signal.Reason = m.thresholds.Evaluate(signal.Lag, signal.QueueDepth, wasThrottled)
+	if signal.Throttled {
+		signal.RetryAfterSeconds = max(int(m.thresholds.RetryAfter/time.Second), 1)
+	}
+	m.current = signal
+	m.mu.Unlock()
+
+	switch {
+	case signal.Throttled && !wasThrottled:
+		logging.Warn("Asking producers to throttle ingestion", "reason", signal.Reason, "lag", signal.Lag, "queue_depth", signal.QueueDepth)
+	case !signal.Throttled && wasThrottled:
+		logging.Info("Consumer caught up, ingestion no longer throttled", "lag", signal.Lag, "queue_depth", signal.QueueDepth)
+	}
+	return signal, m.store.Publish(ctx, signal)
+}
+
+// Current returns the last published signal
+func (m *Monitor) Current() Signal {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.current
+}
+
+// Run checks every interval until ctx is cancelled, then removes the instance's signal
+// so a stopped consumer doesn't keep producers throttled
+func (m *Monitor) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := m.Check(ctx); err != nil && ctx.Err() == nil {
+			logging.Warn("Failed to publish back-pressure signal", "error", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			removeCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			if err := m.store.Remove(removeCtx, m.instance); err != nil {
+				logging.Warn("Failed to remove back-pressure signal", "error", err)
+			}
+			return
+		}
+	}
+}
+
+// State is the combined throttle state of all consumers, as seen by a producer
+type State struct {
+	Throttled  bool          `json:"throttled"`
+	Reason     string        `json:"reason,omitempty"`
+	Instances  []string      `json:"instances,omitempty"` // Consumers asking for throttling
+	RetryAfter time.Duration `json:"-"`
+	CheckedAt  time.Time     `json:"checked_at"`
+}
+
+// Gate caches the consumers' combined state for the producer's ingestion endpoints
+type Gate struct {
+	store  Store
+	maxAge time.Duration
+
+	state State
+	mu    sync.RWMutex
+}
+
+// NewGate creates a gate reading signals from store. Signals older than maxAge are
+// ignored, so a consumer that stopped without removing its signal stops throttling.
+func NewGate(store Store, maxAge time.Duration) *Gate {
+	return &Gate{store: store, maxAge: maxAge}
+}
+
+// Refresh reads the signals and updates the state. Ingestion is throttled while any
+// recent signal asks for it; the longest suggested delay is used. When the store can't
+// be read the gate fails open, so an outage of the store doesn't stop ingestion.
+func (g *Gate) Refresh(ctx context.Context) error {
+	signals, err := g.store.Signals(ctx)
+	state := State{CheckedAt: time.Now()}
+	for _, signal := range signals {
+		if !signal.Throttled || state.CheckedAt.Sub(signal.UpdatedAt) > g.maxAge {
+			continue
+		}
+		if !state.Throttled {
+			state.Reason = signal.Reason
+		}
+		state.Throttled = true
+		state.Instances = append(state.Instances, signal.Instance)
+		state.RetryAfter = max(state.RetryAfter, time.Duration(signal.RetryAfterSeconds)*time.Second)
+	}
+
+	g.mu.Lock()
+	wasThrottled := g.state.Throttled
+	g.state = state
+	g.mu.Unlock()
+
+	switch {
+	case state.Throttled && !wasThrottled:
+		logging.Warn("Throttling ingestion on consumer back-pressure", "reason", state.Reason, "instances", len(state.Instances))
+	case !state.Throttled && wasThrottled:
+		logging.Info("Consumer back-pressure cleared, accepting events")
+	}
+	return err
+}
+
+// State returns the last refreshed state; a nil Gate is never throttled
+func (g *Gate) State() State {
+	if g == nil {
+		return State{}
+	}
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.state
+}
+
+// Run refreshes the state every interval until ctx is cancelled
+func (g *Gate) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := g.Refresh(ctx); err != nil && ctx.Err() == nil {
+			logging.Warn("Failed to read back-pressure signals, accepting events", "error", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
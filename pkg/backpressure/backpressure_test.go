@@ -0,0 +1,127 @@
+package backpressure
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestThresholdsHysteresis(t *testing.T) {
+	thresholds := Thresholds{MaxLag: 1000, MaxQueueDepth: 100}
+
+	if throttled, _ := thresholds.Evaluate(900, 50, false); throttled {
+		t.Error("expected no throttling below the limits")
+	}
+	if throttled, reason := thresholds.Evaluate(1500, 0, false); !throttled || reason == "" {
+		t.Error("expected throttling above the lag limit, with a reason")
+	}
+	if throttled, _ := thresholds.Evaluate(0, 101, false); !throttled {
+		t.Error("expected throttling above the queue limit")
+	}
+	// Once throttled, both values must fall to half their limit
+	if throttled, _ := thresholds.Evaluate(900, 10, true); !throttled {
+		t.Error("expected throttling to continue above half the lag limit")
+	}
+	if throttled, _ := thresholds.Evaluate(400, 40, true); throttled {
+		t.Error("expected throttling to stop below half the limits")
+	}
+	if throttled, _ := (Thresholds{}).Evaluate(1<<40, 1<<20, false); throttled {
+		t.Error("expected zero limits not checked")
+	}
+}
+
+func TestMonitorAndGate(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	lag, depth := int64(0), 0
+	monitor := NewMonitor(store, "consumer-1", Thresholds{MaxLag: 100, RetryAfter: 15 * time.Second},
+		func(ctx context.Context) (int64, error) { return lag, nil }, func() int { return depth })
+	gate := NewGate(store, time.Minute)
+
+	monitor.Check(ctx)
+	gate.Refresh(ctx)
+	if gate.State().Throttled {
+		t.Fatal("expected no throttling while caught up")
+	}
+
+	lag, depth = 500, 7
+	signal, err := monitor.Check(ctx)
+	if err != nil || !signal.Throttled || signal.QueueDepth != 7 || signal.RetryAfterSeconds != 15 {
+		t.Fatalf("expected a throttled signal, got %+v, %v", signal, err)
+	}
+	// A second, caught up instance doesn't lift the throttling
+	store.Publish(ctx, Signal{Instance: "consumer-2", UpdatedAt: time.Now()})
+	gate.Refresh(ctx)
+	state := gate.State()
+	if !state.Throttled || state.RetryAfter != 15*time.Second || len(state.Instances) != 1 || state.Instances[0] != "consumer-1" {
+		t.Errorf("expected throttling by consumer-1, got %+v", state)
+	}
+
+	lag = 10
+	monitor.Check(ctx)
+	gate.Refresh(ctx)
+	if gate.State().Throttled || monitor.Current().Throttled {
+		t.Error("expected throttling lifted once the consumer caught up")
+	}
+}
+
+func TestGateIgnoresStaleSignalsAndFailsOpen(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	gate := NewGate(store, time.Minute)
+
+	store.Publish(ctx, Signal{Instance: "gone", Throttled: true, UpdatedAt: time.Now().Add(-2 * time.Minute)})
+	gate.Refresh(ctx)
+	if gate.State().Throttled {
+		t.Error("expected stale signals ignored")
+	}
+
+	store.Publish(ctx, Signal{Instance: "gone", Throttled: true, UpdatedAt: time.Now()})
+	gate.Refresh(ctx)
+	if !gate.State().Throttled {
+		t.Fatal("expected a recent signal to throttle")
+	}
+	failing := NewGate(failingStore{store}, time.Minute)
+	if err := failing.Refresh(ctx); err == nil || failing.State().Throttled {
+		t.Error("expected an unreadable store reported and not throttling")
+	}
+
+	var unset *Gate
+	if unset.State().Throttled {
+		t.Error("expected a nil gate never throttled")
+	}
+}
+
+func TestMonitorRunRemovesSignal(t *testing.T) {
+	store := NewMemoryStore()
+	monitor := NewMonitor(store, "consumer-1", Thresholds{MaxLag: 1}, func(ctx context.Context) (int64, error) {
+		return 0, errors.New("lag unavailable")
+	}, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		monitor.Run(ctx, time.Hour)
+		close(done)
+	}()
+	for {
+		if signals, _ := store.Signals(context.Background()); len(signals) == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	<-done
+	if signals, _ := store.Signals(context.Background()); len(signals) != 0 {
+		t.Errorf("expected the signal removed on stop, got %+v", signals)
+	}
+}
+
+type failingStore struct {
+	*MemoryStore
+}
+
+func (failingStore) Signals(ctx context.Context) ([]Signal, error) {
+	return nil, errors.New("store unavailable")
+}
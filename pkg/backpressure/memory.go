@@ -0,0 +1,50 @@
+package backpressure
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryStore keeps signals in the process, for tests and when the consumer and
+// producer share a process
+type MemoryStore struct {
+	signals map[string]Signal
+	mu      sync.Mutex
+}
+
+// NewMemoryStore creates an empty store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{signals: make(map[string]Signal)}
+}
+
+// Publish replaces the instance's signal
+func (m *MemoryStore) Publish(ctx context.Context, signal Signal) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.signals[signal.Instance] = signal
+	return nil
+}
+
+// Signals returns every instance's signal
+func (m *MemoryStore) Signals(ctx context.Context) ([]Signal, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	signals := make([]Signal, 0, len(m.signals))
+	for _, signal := range m.signals {
+		signals = append(signals, signal)
+	}
+	return signals, nil
+}
+
+// Remove deletes the instance's signal
+func (m *MemoryStore) Remove(ctx context.Context, instance string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.signals, instance)
+	return nil
+}
+
+// Close does nothing
+func (m *MemoryStore) Close() error {
+	return nil
+}
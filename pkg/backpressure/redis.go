@@ -0,0 +1,80 @@
+package backpressure
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisStore shares signals through a Redis hash with a field per consumer instance.
+// Fields not updated within the stale age are deleted when the signals are read.
+type RedisStore struct {
+	client   *redis.Client
+	key      string
+	staleAge time.Duration
+}
+
+// NewRedisStore connects to the Redis server at redisURL (e.g. redis://localhost:6379/0)
+func NewRedisStore(ctx context.Context, redisURL string, staleAge time.Duration) (*RedisStore, error) {
+	options, err := redis.ParseURL(redisURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis URL: %w", err)
+	}
+
+	client := redis.NewClient(options)
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to redis: %w", err)
+	}
+
+	return &RedisStore{
+		client:   client,
+		key:      "analytics:backpressure",
+		staleAge: staleAge,
+	}, nil
+}
+
+// Publish stores the signal in the instance's field
+func (r *RedisStore) Publish(ctx context.Context, signal Signal) error {
+	data, err := json.Marshal(signal)
+	if err != nil {
+		return err
+	}
+	return r.client.HSet(ctx, r.key, signal.Instance, data).Err()
+}
+
+// Signals returns the stored signals, deleting stale and unreadable ones
+func (r *RedisStore) Signals(ctx context.Context) ([]Signal, error) {
+	fields, err := r.client.HGetAll(ctx, r.key).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var signals []Signal
+	var stale []string
+	for instance, data := range fields {
+		var signal Signal
+		if err := json.Unmarshal([]byte(data), &signal); err != nil || time.Since(signal.UpdatedAt) > r.staleAge {
+			stale = append(stale, instance)
+			continue
+		}
+		signals = append(signals, signal)
+	}
+	if len(stale) > 0 {
+		r.client.HDel(ctx, r.key, stale...)
+	}
+	return signals, nil
+}
+
+// Remove deletes the instance's field
+func (r *RedisStore) Remove(ctx context.Context, instance string) error {
+	return r.client.HDel(ctx, r.key, instance).Err()
+}
+
+// Close closes the Redis client
+func (r *RedisStore) Close() error {
+	return r.client.Close()
+}
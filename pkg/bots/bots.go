@@ -0,0 +1,175 @@
+package bots
+
+import (
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/useragent"
+)
+
+// Policy is what happens to events detected as bot traffic
+type Policy string
+
+const (
+	PolicyFlag      Policy = "flag"      // Counted in every metric and in the bot stats
+	PolicySegregate Policy = "segregate" // Counted only in the bot stats
+	PolicyDrop      Policy = "drop"      // Counted only in the bot stats and not forwarded to Kafka
+)
+
+// ResolvePolicy parses a policy name. Without one, excludeBots (the older on/off
+// setting) selects segregate and the default is flag; invalid names also fall back.
+func ResolvePolicy(name string, excludeBots bool) (Policy, error) {
+	fallback := PolicyFlag
+	if excludeBots {
+		fallback = PolicySegregate
+	}
+
+	switch policy := Policy(strings.ToLower(strings.TrimSpace(name))); policy {
+	case PolicyFlag, PolicySegregate, PolicyDrop:
+		return policy, nil
+	case "":
+		return fallback, nil
+	default:
+		return fallback, fmt.Errorf("unknown bot policy %q (use flag, segregate or drop)", name)
+	}
+}
+
+// Reasons an event is detected as a bot
+const (
+	ReasonUserAgent = "user_agent" // Known crawler or automation tool user agent
+	ReasonHeadless  = "headless"   // Headless browser user agent
+	ReasonWebDriver = "webdriver"  // Client reported navigator.webdriver
+	ReasonIP        = "ip"         // Address in a known crawler range
+)
+
+// Metadata keys. Trackers may send webdriver=true from navigator.webdriver; the bot keys
+// are set on detected events so downstream consumers do not need to detect again.
+const (
+	MetadataWebDriver = "webdriver"
+	MetadataBot       = "bot"
+	MetadataBotName   = "bot_name"
+	MetadataBotReason = "bot_reason"
+)
+
+// DefaultUserAgentPatterns match crawler and scripted client user agents the parser does
+// not recognize; matching is case-insensitive
+var DefaultUserAgentPatterns = []string{
+	"bot", "crawler", "spider", "slurp", "curl/", "wget/", "python-requests", "python-urllib",
+	"go-http-client", "okhttp", "java/", "libwww-perl", "httpclient", "scrapy", "axios/",
+}
+
+// headlessPatterns identify headless and automated browsers
+var headlessPatterns = []string{"headlesschrome", "phantomjs", "puppeteer", "playwright", "selenium", "electron/"}
+
+// DefaultIPRanges are published Googlebot and Bingbot crawler ranges
+var DefaultIPRanges = []string{
+	"66.249.64.0/19",
+	"157.55.39.0/24",
+	"207.46.13.0/24",
+	"40.77.167.0/24",
+}
+
+// Config adds user agents and addresses treated as bots to the defaults
+type Config struct {
+	UserAgentPatterns []string // Case-insensitive substrings, in addition to DefaultUserAgentPatterns
+	IPRanges          []string // CIDR ranges, in addition to DefaultIPRanges
+}
+
+// Result describes a detected bot
+type Result struct {
+	Bot    bool
+	Name   string // Crawler name or matched pattern
+	Reason string
+}
+
+// Detector recognizes bot traffic from user agents, client hints and source addresses
+type Detector struct {
+	parser   useragent.Parser
+	patterns []string
+	networks []*net.IPNet
+}
+
+// NewDetector creates a detector, returning an error for malformed IP ranges
+func NewDetector(config Config) (*Detector, error) {
+	d := &Detector{parser: useragent.NewParser()}
+	for _, pattern := range slices.Concat(DefaultUserAgentPatterns, config.UserAgentPatterns) {
+		d.patterns = append(d.patterns, strings.ToLower(pattern))
+	}
+	for _, cidr := range slices.Concat(DefaultIPRanges, config.IPRanges) {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bot IP range %q: %w", cidr, err)
+		}
+		d.networks = append(d.networks, network)
+	}
+	return d, nil
+}
+
+// Detect checks an event, parsing its user agent
+func (d *Detector) Detect(event *models.AnalyticsEvent) Result {
+	var agent *useragent.Info
+	if event.UserAgent != "" {
+		info := d.parser.Parse(event.UserAgent)
+		agent = &info
+	}
+	return d.DetectWithAgent(event, agent)
+}
+
+// DetectWithAgent checks an event whose user agent has already been parsed. Events
+// flagged by an earlier stage keep that result.
+func (d *Detector) DetectWithAgent(event *models.AnalyticsEvent, agent *useragent.Info) Result {
+	if result, ok := Flagged(event); ok {
+		return result
+	}
+
+	if webdriver, _ := event.Metadata[MetadataWebDriver].(bool); webdriver {
+		return Result{Bot: true, Name: "WebDriver", Reason: ReasonWebDriver}
+	}
+
+	userAgent := strings.ToLower(event.UserAgent)
+	for _, pattern := range headlessPatterns {
+		if strings.Contains(userAgent, pattern) {
+			return Result{Bot: true, Name: pattern, Reason: ReasonHeadless}
+		}
+	}
+	if agent != nil && agent.Bot {
+		return Result{Bot: true, Name: agent.Browser, Reason: ReasonUserAgent}
+	}
+	for _, pattern := range d.patterns {
+		if userAgent != "" && strings.Contains(userAgent, pattern) {
+			return Result{Bot: true, Name: pattern, Reason: ReasonUserAgent}
+		}
+	}
+
+	if ip := net.ParseIP(event.IPAddress); ip != nil {
+		for _, network := range d.networks {
+			if network.Contains(ip) {
+				return Result{Bot: true, Name: network.String(), Reason: ReasonIP}
+			}
+		}
+	}
+	return Result{}
+}
+
+// Flag records a detection in the event's metadata
+func Flag(event *models.AnalyticsEvent, result Result) {
+	if event.Metadata == nil {
+		event.Metadata = make(map[string]interface{})
+	}
+	event.Metadata[MetadataBot] = true
+	event.Metadata[MetadataBotName] = result.Name
+	event.Metadata[MetadataBotReason] = result.Reason
+}
+
+// Flagged returns the detection recorded in the event's metadata, if any
+func Flagged(event *models.AnalyticsEvent) (Result, bool) {
+	if bot, _ := event.Metadata[MetadataBot].(bool); !bot {
+		return Result{}, false
+	}
+	name, _ := event.Metadata[MetadataBotName].(string)
+	reason, _ := event.Metadata[MetadataBotReason].(string)
+	return Result{Bot: true, Name: name, Reason: reason}, true
+}
@@ -0,0 +1,60 @@
+package bots
+
+import (
+	"testing"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+func TestDetect(t *testing.T) {
+	detector, err := NewDetector(Config{UserAgentPatterns: []string{"MonitorAgent"}, IPRanges: []string{"203.0.113.0/24"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		event      models.AnalyticsEvent
+		wantReason string
+	}{
+		{"Browser", models.AnalyticsEvent{UserAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36", IPAddress: "198.51.100.7"}, ""},
+		{"Googlebot", models.AnalyticsEvent{UserAgent: "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)"}, ReasonUserAgent},
+		{"Script", models.AnalyticsEvent{UserAgent: "python-requests/2.31.0"}, ReasonUserAgent},
+		{"ConfiguredPattern", models.AnalyticsEvent{UserAgent: "monitoragent/1.0"}, ReasonUserAgent},
+		{"Headless", models.AnalyticsEvent{UserAgent: "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) HeadlessChrome/120.0.0.0 Safari/537.36"}, ReasonHeadless},
+		{"WebDriver", models.AnalyticsEvent{Metadata: map[string]interface{}{"webdriver": true}}, ReasonWebDriver},
+		{"CrawlerIP", models.AnalyticsEvent{IPAddress: "66.249.66.1"}, ReasonIP},
+		{"ConfiguredIP", models.AnalyticsEvent{IPAddress: "203.0.113.9"}, ReasonIP},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := detector.Detect(&tt.event)
+			if result.Bot != (tt.wantReason != "") || result.Reason != tt.wantReason {
+				t.Errorf("Got %+v, want reason %q", result, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestFlagRoundTrip(t *testing.T) {
+	event := &models.AnalyticsEvent{}
+	Flag(event, Result{Bot: true, Name: "Googlebot", Reason: ReasonUserAgent})
+
+	result, ok := Flagged(event)
+	if !ok || result.Name != "Googlebot" || result.Reason != ReasonUserAgent {
+		t.Errorf("Expected the flag to round-trip, got %+v %v", result, ok)
+	}
+}
+
+func TestResolvePolicy(t *testing.T) {
+	if policy, _ := ResolvePolicy("", true); policy != PolicySegregate {
+		t.Errorf("EXCLUDE_BOTS without a policy should segregate, got %s", policy)
+	}
+	if policy, _ := ResolvePolicy("DROP", true); policy != PolicyDrop {
+		t.Errorf("An explicit policy should win, got %s", policy)
+	}
+	if policy, err := ResolvePolicy("block", false); err == nil || policy != PolicyFlag {
+		t.Errorf("Expected an error and the flag fallback, got %s %v", policy, err)
+	}
+}
@@ -0,0 +1,227 @@
+package bus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/errs"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/kafka"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/logging"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+	kafkago "github.com/segmentio/kafka-go"
+)
+
+// maxHandlerAttempts is how often Consumer passes a message to the handler, while its
+// errors are retryable, before moving on, like kafka.Consumer does
+const maxHandlerAttempts = 3
+
+// ErrLagUnsupported is returned by Consumer.Lag; only Kafka reports consumer lag
+var ErrLagUnsupported = errors.New("consumer lag is only reported for Kafka")
+
+var (
+	_ kafka.EventProducer = (*Producer)(nil)
+	_ kafka.EventConsumer = (*Consumer)(nil)
+)
+
+// Producer publishes events to a bus the way kafka.Producer writes them to Kafka, with
+// the same JSON payload, keys and headers
+type Producer struct {
+	bus         MessageBus
+	topic       string
+	keyStrategy kafka.KeyStrategy
+	headers     *kafka.HeaderCodec
+
+	status   kafka.WriteStatus
+	statusMu sync.RWMutex
+}
+
+// NewProducer publishes to topic by default, keying events by keyStrategy
+func NewProducer(bus MessageBus, topic string, keyStrategy kafka.KeyStrategy) *Producer {
+	return &Producer{bus: bus, topic: topic, keyStrategy: keyStrategy, headers: kafka.DefaultHeaderCodec()}
+}
+
+// EventKey returns the message key for an event under the producer's key strategy
+func (p *Producer) EventKey(event *models.AnalyticsEvent) string {
+	return p.keyStrategy.Key(event)
+}
+
+// SendEvent sends an event to the producer's default topic
+func (p *Producer) SendEvent(ctx context.Context, key string, value interface{}) error {
+	return p.SendToTopic(ctx, p.topic, key, value)
+}
+
+// SendToTopic sends an event to the given topic
+func (p *Producer) SendToTopic(ctx context.Context, topic, key string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	msg := &Message{Topic: topic, Key: key, Value: data}
+	var event *models.AnalyticsEvent
+	switch v := value.(type) {
+	case models.AnalyticsEvent:
+		event = &v
+	case *models.AnalyticsEvent:
+		event = v
+	}
+	if event != nil {
+		msg.Headers = kafka.ParseHeaders(p.headers.Encode(event))
+		msg.Headers[kafka.HeaderIdempotencyKey] = event.ID
+	}
+
+	err = p.bus.Publish(ctx, msg)
+	p.statusMu.Lock()
+	if err != nil {
+		p.status.LastFailure = time.Now()
+		p.status.LastError = err.Error()
+	} else {
+		p.status.LastSuccess = time.Now()
+	}
+	p.statusMu.Unlock()
+	return err
+}
+
+// WriteStatus reports the outcome of recent publishes
+func (p *Producer) WriteStatus() kafka.WriteStatus {
+	p.statusMu.RLock()
+	defer p.statusMu.RUnlock()
+	return p.status
+}
+
+// Ping checks the broker when the bus supports it
+func (p *Producer) Ping(ctx context.Context) error {
+	if pinger, ok := p.bus.(Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+// Close does nothing; the bus is shared and closed by its owner
+func (p *Producer) Close() error {
+	return nil
+}
+
+// Consumer reads events from a bus in a consumer group, decoding them like
+// kafka.Consumer so handlers work unchanged. Messages carry no partition or offset.
+type Consumer struct {
+	bus    MessageBus
+	topics []string
+	group  string
+	filter func(kafka.Headers) bool // nil accepts every message
+
+	// Shutdown state, like kafka.Consumer's
+	cancel  context.CancelFunc
+	done    chan struct{}
+	stopped bool
+	mu      sync.Mutex
+}
+
+// NewConsumer consumes the topics in group
+func NewConsumer(bus MessageBus, topics []string, group string) *Consumer {
+	return &Consumer{bus: bus, topics: topics, group: group}
+}
+
+// SetHeaderFilter skips messages whose headers the filter rejects without decoding
+// them. It must be called before consuming.
+func (c *Consumer) SetHeaderFilter(filter func(kafka.Headers) bool) {
+	c.filter = filter
+}
+
+// Topics returns the topics being consumed
+func (c *Consumer) Topics() []string {
+	return append([]string(nil), c.topics...)
+}
+
+// ConsumeEvents passes each decoded event to handler
+func (c *Consumer) ConsumeEvents(ctx context.Context, handler func(*models.AnalyticsEvent) error) error {
+	return c.ConsumeMessages(ctx, func(message *kafka.Message) error {
+		return handler(message.Event)
+	})
+}
+
+// ConsumeMessages passes each message to handler until ctx is canceled or the consumer
+// is shut down. Failing messages are retried and then acknowledged, so one bad message
+// doesn't block the group; messages that can't be decoded are skipped.
+func (c *Consumer) ConsumeMessages(ctx context.Context, handler func(*kafka.Message) error) error {
+	c.mu.Lock()
+	if c.stopped {
+		c.mu.Unlock()
+		return context.Canceled
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	c.cancel, c.done = cancel, make(chan struct{})
+	done := c.done
+	c.mu.Unlock()
+	defer close(done)
+	defer cancel()
+
+	return c.bus.Subscribe(ctx, c.topics, c.group, func(ctx context.Context, msg *Message) error {
+		if c.filter != nil && !c.filter(msg.Headers) {
+			return nil
+		}
+
+		raw := kafkago.Message{Topic: msg.Topic, Key: []byte(msg.Key), Value: msg.Value, Time: msg.Time}
+		for name, value := range msg.Headers {
+			raw.Headers = append(raw.Headers, kafkago.Header{Key: name, Value: []byte(value)})
+		}
+		message, err := kafka.DecodeMessage(raw)
+		if err != nil {
+			logging.Warn("Skipping undecodable message", "topic", msg.Topic, "error", err)
+			return nil
+		}
+
+		logger := logging.With("topic", msg.Topic, "event_id", message.Event.ID, "event_type", message.Event.Type)
+		for attempt := 1; attempt <= maxHandlerAttempts; attempt++ {
+			if err = handler(message); err == nil {
+				return nil
+			}
+			logger.Warn("Failed to process event", "attempt", attempt, "max_attempts", maxHandlerAttempts, "error", err)
+			if !errs.Retryable(err) {
+				logger.Error("Error is not retryable, moving to next message", "code", errs.Code(err))
+				return nil
+			}
+		}
+		logger.Error("Max retries reached, moving to next message")
+		return nil
+	})
+}
+
+// Shutdown stops consuming once the message being handled is done
+func (c *Consumer) Shutdown(ctx context.Context) error {
+	c.mu.Lock()
+	c.stopped = true
+	cancel, done := c.cancel, c.done
+	c.mu.Unlock()
+	if cancel == nil {
+		return nil
+	}
+
+	cancel()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Lag returns ErrLagUnsupported
+func (c *Consumer) Lag(ctx context.Context) ([]kafka.PartitionLag, error) {
+	return nil, ErrLagUnsupported
+}
+
+// Close stops consuming without waiting; the bus is closed by its owner
+func (c *Consumer) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stopped = true
+	if c.cancel != nil {
+		c.cancel()
+	}
+	return nil
+}
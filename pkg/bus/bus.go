@@ -0,0 +1,81 @@
+// Package bus abstracts the message broker events travel through. Kafka, NATS
+// JetStream and RabbitMQ implement MessageBus, and Producer and Consumer adapt any of
+// them to the kafka.EventProducer and kafka.EventConsumer interfaces the services are
+// built on, so smaller deployments can run the pipeline without operating Kafka.
+// Messages carry the same JSON payload and headers on every broker.
+package bus
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Message is a message published to or received from a topic
+type Message struct {
+	Topic   string
+	Key     string
+	Value   []byte
+	Headers map[string]string
+	Time    time.Time // When the broker stored it; set on received messages
+}
+
+// Handler processes a received message. A message is acknowledged once its handler
+// returns nil.
+type Handler func(ctx context.Context, msg *Message) error
+
+// MessageBus publishes and delivers messages by topic
+type MessageBus interface {
+	// Publish sends a message to its topic, returning once the broker has stored it
+	Publish(ctx context.Context, msg *Message) error
+
+	// Subscribe passes messages of the topics to handler, one at a time, until ctx is
+	// done. Subscribers with the same group share the messages; each group receives
+	// every message. Messages whose handler fails are redelivered by NATS and RabbitMQ,
+	// while Kafka moves past them.
+	Subscribe(ctx context.Context, topics []string, group string, handler Handler) error
+
+	Close() error
+}
+
+// Pinger is implemented by buses that can check their broker is reachable
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// Broker kinds accepted by Open
+const (
+	KindKafka    = "kafka"
+	KindNATS     = "nats"
+	KindRabbitMQ = "rabbitmq"
+)
+
+// Config selects and configures a broker
+type Config struct {
+	Kind string // kafka, nats or rabbitmq
+
+	KafkaBrokers []string
+
+	NATSURL           string
+	NATSStream        string // JetStream stream storing every topic
+	NATSSubjectPrefix string // Topics are published to <prefix>.<topic>
+
+	RabbitMQURL      string
+	RabbitMQExchange string // Topic exchange messages are routed through by topic
+	RabbitMQPrefetch int    // Unacknowledged messages delivered to a subscriber at once
+}
+
+// Open connects to the configured broker
+func Open(ctx context.Context, config Config) (MessageBus, error) {
+	switch strings.ToLower(strings.TrimSpace(config.Kind)) {
+	case KindKafka:
+		return NewKafkaBus(config.KafkaBrokers), nil
+	case KindNATS:
+		return NewNATSBus(ctx, config.NATSURL, config.NATSStream, config.NATSSubjectPrefix)
+	case KindRabbitMQ:
+		return NewRabbitMQBus(config.RabbitMQURL, config.RabbitMQExchange, config.RabbitMQPrefetch)
+	default:
+		return nil, fmt.Errorf("unknown message bus %q (want kafka, nats or rabbitmq)", config.Kind)
+	}
+}
@@ -0,0 +1,173 @@
+package bus
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/kafka"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+// collect consumes from consumer in the background, sending each message on the channel
+func collect(t *testing.T, consumer *Consumer, handler func(*kafka.Message) error) (<-chan *kafka.Message, func()) {
+	t.Helper()
+	received := make(chan *kafka.Message, 16)
+	done := make(chan error, 1)
+	go func() {
+		done <- consumer.ConsumeMessages(context.Background(), func(message *kafka.Message) error {
+			if handler != nil {
+				if err := handler(message); err != nil {
+					return err
+				}
+			}
+			received <- message
+			return nil
+		})
+	}()
+	return received, func() {
+		if err := consumer.Shutdown(context.Background()); err != nil {
+			t.Errorf("shutdown failed: %v", err)
+		}
+		if err := <-done; !errors.Is(err, context.Canceled) {
+			t.Errorf("expected consuming to stop with context.Canceled, got %v", err)
+		}
+	}
+}
+
+func receive(t *testing.T, received <-chan *kafka.Message) *kafka.Message {
+	t.Helper()
+	select {
+	case message := <-received:
+		return message
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for a message")
+		return nil
+	}
+}
+
+// subscribed waits until the group's subscription exists, so publishes reach it
+func subscribed(b *MemoryBus, group string) {
+	for {
+		b.mu.Lock()
+		_, ok := b.groups[group]
+		b.mu.Unlock()
+		if ok {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestProducerAndConsumerRoundTrip(t *testing.T) {
+	b := NewMemoryBus()
+	defer b.Close()
+
+	received, stop := collect(t, NewConsumer(b, []string{"events"}, "analytics"), nil)
+	defer stop()
+	subscribed(b, "analytics")
+
+	producer := NewProducer(b, "events", kafka.KeyBySession)
+	event := &models.AnalyticsEvent{ID: "evt-1", Type: models.PageView, SessionID: "s1", Path: "/pricing"}
+	if err := producer.SendEvent(context.Background(), producer.EventKey(event), event); err != nil {
+		t.Fatal(err)
+	}
+
+	message := receive(t, received)
+	if message.Topic != "events" || string(message.Key) != "s1" || message.Event.ID != "evt-1" || message.Event.Path != "/pricing" {
+		t.Errorf("expected the event keyed by session, got %+v", message)
+	}
+	if message.Headers.EventType() != models.PageView || message.Headers[kafka.HeaderIdempotencyKey] != "evt-1" {
+		t.Errorf("expected the Kafka headers carried over, got %v", message.Headers)
+	}
+	if status := producer.WriteStatus(); status.LastSuccess.IsZero() || status.Failing() {
+		t.Errorf("expected a successful write recorded, got %+v", status)
+	}
+}
+
+func TestConsumerFiltersAndRetries(t *testing.T) {
+	b := NewMemoryBus()
+	defer b.Close()
+
+	consumer := NewConsumer(b, []string{"events"}, "analytics")
+	consumer.SetHeaderFilter(kafka.EventTypeFilter([]string{"click"}))
+	var mu sync.Mutex
+	attempts := 0
+	received, stop := collect(t, consumer, func(message *kafka.Message) error {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts++
+		if attempts == 1 {
+			return errors.New("temporary failure")
+		}
+		return nil
+	})
+	defer stop()
+	subscribed(b, "analytics")
+
+	producer := NewProducer(b, "events", kafka.KeyByEventID)
+	ctx := context.Background()
+	producer.SendEvent(ctx, "a", &models.AnalyticsEvent{ID: "a", Type: models.PageView})
+	producer.SendEvent(ctx, "b", &models.AnalyticsEvent{ID: "b", Type: models.Click})
+	b.Publish(ctx, &Message{Topic: "events", Key: "c", Value: []byte("not json")})
+	producer.SendEvent(ctx, "d", &models.AnalyticsEvent{ID: "d", Type: models.Click})
+
+	// The page view is filtered out, the bad message skipped and the click retried
+	if first, second := receive(t, received), receive(t, received); first.Event.ID != "b" || second.Event.ID != "d" {
+		t.Errorf("expected the clicks in order, got %s and %s", first.Event.ID, second.Event.ID)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if attempts != 3 {
+		t.Errorf("expected the first click handled twice, got %d attempts", attempts)
+	}
+}
+
+func TestGroupsReceiveEveryMessage(t *testing.T) {
+	b := NewMemoryBus()
+	defer b.Close()
+
+	first, stopFirst := collect(t, NewConsumer(b, []string{"events"}, "analytics"), nil)
+	defer stopFirst()
+	second, stopSecond := collect(t, NewConsumer(b, []string{"events", "meta"}, "archive"), nil)
+	defer stopSecond()
+	subscribed(b, "analytics")
+	subscribed(b, "archive")
+
+	producer := NewProducer(b, "events", kafka.KeyByEventID)
+	producer.SendToTopic(context.Background(), "meta", "m", &models.AnalyticsEvent{ID: "m", Type: models.MetaEvent})
+	producer.SendEvent(context.Background(), "e", &models.AnalyticsEvent{ID: "e", Type: models.Click})
+
+	if message := receive(t, first); message.Event.ID != "e" {
+		t.Errorf("expected only the events topic in the first group, got %s", message.Event.ID)
+	}
+	if a, b := receive(t, second), receive(t, second); a.Event.ID != "m" || b.Event.ID != "e" {
+		t.Errorf("expected both topics in the second group, got %s and %s", a.Event.ID, b.Event.ID)
+	}
+}
+
+func TestConsumerLagUnsupported(t *testing.T) {
+	consumer := NewConsumer(NewMemoryBus(), []string{"events"}, "analytics")
+	if _, err := consumer.Lag(context.Background()); !errors.Is(err, ErrLagUnsupported) {
+		t.Errorf("expected ErrLagUnsupported, got %v", err)
+	}
+}
+
+func TestOpenRejectsUnknownBus(t *testing.T) {
+	if _, err := Open(context.Background(), Config{Kind: "carrier-pigeon"}); err == nil {
+		t.Error("expected an unknown bus rejected")
+	}
+	if b, err := Open(context.Background(), Config{Kind: "Kafka", KafkaBrokers: []string{"localhost:9092"}}); err != nil {
+		t.Errorf("expected a Kafka bus, got %v", err)
+	} else {
+		b.Close()
+	}
+}
+
+func TestDurableNames(t *testing.T) {
+	if name := invalidDurableChars.ReplaceAllString("analytics.consumer group>1", "_"); name != "analytics_consumer_group_1" {
+		t.Errorf("expected invalid characters replaced, got %q", name)
+	}
+}
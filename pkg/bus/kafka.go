@@ -0,0 +1,100 @@
+package bus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// KafkaBus publishes to and subscribes through Kafka consumer groups. The services use
+// package kafka directly for Kafka, which adds partition-aware workers, lag and
+// rebalance tracking; KafkaBus serves programs written against MessageBus.
+type KafkaBus struct {
+	brokers []string
+	writer  *kafka.Writer
+
+	readers []*kafka.Reader
+	mu      sync.Mutex
+}
+
+// NewKafkaBus creates a bus on the brokers; it doesn't connect until first used
+func NewKafkaBus(brokers []string) *KafkaBus {
+	return &KafkaBus{
+		brokers: brokers,
+		writer:  &kafka.Writer{Addr: kafka.TCP(brokers...), Balancer: &kafka.Hash{}},
+	}
+}
+
+// Publish writes the message to its topic, keyed so messages with the same key keep
+// their order
+func (b *KafkaBus) Publish(ctx context.Context, msg *Message) error {
+	message := kafka.Message{Topic: msg.Topic, Key: []byte(msg.Key), Value: msg.Value}
+	for name, value := range msg.Headers {
+		message.Headers = append(message.Headers, kafka.Header{Key: name, Value: []byte(value)})
+	}
+	if err := b.writer.WriteMessages(ctx, message); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+	return nil
+}
+
+// Subscribe reads the topics in the consumer group, committing each message after its
+// handler returns. Kafka can't skip a message within a partition, so a failed message
+// is committed by the next one.
+func (b *KafkaBus) Subscribe(ctx context.Context, topics []string, group string, handler Handler) error {
+	reader := kafka.NewReader(kafka.ReaderConfig{Brokers: b.brokers, GroupID: group, GroupTopics: topics})
+	b.mu.Lock()
+	b.readers = append(b.readers, reader)
+	b.mu.Unlock()
+	defer reader.Close()
+
+	for {
+		msg, err := reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("failed to fetch message: %w", err)
+		}
+
+		message := &Message{Topic: msg.Topic, Key: string(msg.Key), Value: msg.Value, Time: msg.Time, Headers: make(map[string]string, len(msg.Headers))}
+		for _, header := range msg.Headers {
+			message.Headers[header.Key] = string(header.Value)
+		}
+		if err := handler(ctx, message); err != nil {
+			continue
+		}
+		if err := reader.CommitMessages(context.WithoutCancel(ctx), msg); err != nil {
+			return fmt.Errorf("failed to commit message: %w", err)
+		}
+	}
+}
+
+// Ping checks that a broker is reachable
+func (b *KafkaBus) Ping(ctx context.Context) error {
+	var lastErr error = errors.New("no brokers configured")
+	for _, broker := range b.brokers {
+		conn, err := kafka.DialContext(ctx, "tcp", broker)
+		if err == nil {
+			conn.Close()
+			return nil
+		}
+		lastErr = err
+	}
+	return fmt.Errorf("no Kafka broker reachable: %w", lastErr)
+}
+
+// Close closes the writer and any readers still open
+func (b *KafkaBus) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	errs := []error{b.writer.Close()}
+	for _, reader := range b.readers {
+		errs = append(errs, reader.Close())
+	}
+	b.readers = nil
+	return errors.Join(errs...)
+}
@@ -0,0 +1,111 @@
+package bus
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// MemoryBus delivers messages within the process, for tests and single-process
+// deployments. Messages are kept per group from the group's first subscription, and
+// ones whose handler fails are delivered again.
+type MemoryBus struct {
+	mu     sync.Mutex
+	groups map[string]*memoryGroup
+	closed bool
+}
+
+type memoryGroup struct {
+	topics  map[string]bool
+	pending []*Message
+	signal  chan struct{} // Closed and replaced when messages arrive
+}
+
+// NewMemoryBus creates an empty in-memory bus
+func NewMemoryBus() *MemoryBus {
+	return &MemoryBus{groups: make(map[string]*memoryGroup)}
+}
+
+// Publish queues a copy of the message for every group subscribed to its topic
+func (b *MemoryBus) Publish(ctx context.Context, msg *Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return errors.New("message bus is closed")
+	}
+
+	copied := *msg
+	copied.Time = time.Now()
+	for _, group := range b.groups {
+		if group.topics[msg.Topic] {
+			group.pending = append(group.pending, &copied)
+			close(group.signal)
+			group.signal = make(chan struct{})
+		}
+	}
+	return nil
+}
+
+// Subscribe delivers the group's messages until ctx is done or the bus is closed
+func (b *MemoryBus) Subscribe(ctx context.Context, topics []string, group string, handler Handler) error {
+	b.mu.Lock()
+	g, ok := b.groups[group]
+	if !ok {
+		g = &memoryGroup{topics: make(map[string]bool), signal: make(chan struct{})}
+		b.groups[group] = g
+	}
+	for _, topic := range topics {
+		g.topics[topic] = true
+	}
+	b.mu.Unlock()
+
+	for {
+		b.mu.Lock()
+		if b.closed {
+			b.mu.Unlock()
+			return nil
+		}
+		var msg *Message
+		if len(g.pending) > 0 {
+			msg, g.pending = g.pending[0], g.pending[1:]
+		}
+		signal := g.signal
+		b.mu.Unlock()
+
+		if msg == nil {
+			select {
+			case <-signal:
+				continue
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		if err := handler(ctx, msg); err != nil {
+			// Requeue at the back, so other messages aren't held up
+			b.mu.Lock()
+			g.pending = append(g.pending, msg)
+			b.mu.Unlock()
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+}
+
+// Close stops subscriptions and rejects further messages
+func (b *MemoryBus) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.closed {
+		b.closed = true
+		for _, group := range b.groups {
+			close(group.signal)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package bus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/kafka"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// natsKeyHeader carries the message key, which NATS has no field for
+const natsKeyHeader = "message-key"
+
+// invalidDurableChars are not allowed in JetStream consumer names
+var invalidDurableChars = regexp.MustCompile(`[\s.*>/\\]`)
+
+// NATSBus stores every topic in one JetStream stream, under the subject
+// <prefix>.<topic>. Groups are durable consumers, so their position survives restarts.
+type NATSBus struct {
+	conn   *nats.Conn
+	js     jetstream.JetStream
+	stream string
+	prefix string
+}
+
+// NewNATSBus connects to the NATS server at url and creates or updates the stream
+func NewNATSBus(ctx context.Context, url, stream, prefix string) (*NATSBus, error) {
+	conn, err := nats.Connect(url, nats.Name("go-kafka-analytics-pipeline"), nats.MaxReconnects(-1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS: %w", err)
+	}
+	js, err := jetstream.New(conn)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to open JetStream: %w", err)
+	}
+
+	_, err = js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+		Name:       stream,
+		Subjects:   []string{prefix + ".>"},
+		Storage:    jetstream.FileStorage,
+		Duplicates: 2 * time.Minute,
+	})
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to create stream %s: %w", stream, err)
+	}
+	return &NATSBus{conn: conn, js: js, stream: stream, prefix: prefix}, nil
+}
+
+func (b *NATSBus) subject(topic string) string {
+	return b.prefix + "." + topic
+}
+
+// Publish stores the message in the stream, waiting for JetStream's acknowledgement
+func (b *NATSBus) Publish(ctx context.Context, msg *Message) error {
+	m := nats.NewMsg(b.subject(msg.Topic))
+	m.Data = msg.Value
+	for name, value := range msg.Headers {
+		m.Header.Set(name, value)
+	}
+	m.Header.Set(natsKeyHeader, msg.Key)
+
+	// JetStream drops messages repeating an ID within the stream's duplicate window
+	var opts []jetstream.PublishOpt
+	if id := msg.Headers[kafka.HeaderIdempotencyKey]; id != "" {
+		opts = append(opts, jetstream.WithMsgID(id))
+	}
+	if _, err := b.js.PublishMsg(ctx, m, opts...); err != nil {
+		return fmt.Errorf("failed to publish to %s: %w", m.Subject, err)
+	}
+	return nil
+}
+
+// Subscribe consumes the topics' subjects through the group's durable consumer,
+// acknowledging messages whose handler succeeds and asking JetStream to redeliver the
+// others
+func (b *NATSBus) Subscribe(ctx context.Context, topics []string, group string, handler Handler) error {
+	subjects := make([]string, len(topics))
+	for i, topic := range topics {
+		subjects[i] = b.subject(topic)
+	}
+	consumer, err := b.js.CreateOrUpdateConsumer(ctx, b.stream, jetstream.ConsumerConfig{
+		Durable:        invalidDurableChars.ReplaceAllString(group, "_"),
+		FilterSubjects: subjects,
+		AckPolicy:      jetstream.AckExplicitPolicy,
+		DeliverPolicy:  jetstream.DeliverAllPolicy,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create consumer %s: %w", group, err)
+	}
+
+	messages, err := consumer.Messages()
+	if err != nil {
+		return fmt.Errorf("failed to consume %s: %w", group, err)
+	}
+	stop := context.AfterFunc(ctx, messages.Stop)
+	defer stop()
+	defer messages.Stop()
+
+	for {
+		msg, err := messages.Next()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if errors.Is(err, jetstream.ErrMsgIteratorClosed) {
+				return nil
+			}
+			return fmt.Errorf("failed to receive message: %w", err)
+		}
+
+		message := &Message{
+			Topic:   strings.TrimPrefix(msg.Subject(), b.prefix+"."),
+			Value:   msg.Data(),
+			Headers: make(map[string]string, len(msg.Headers())),
+		}
+		for name := range msg.Headers() {
+			if name == natsKeyHeader {
+				message.Key = msg.Headers().Get(name)
+				continue
+			}
+			message.Headers[name] = msg.Headers().Get(name)
+		}
+		if metadata, err := msg.Metadata(); err == nil {
+			message.Time = metadata.Timestamp
+		}
+
+		if err := handler(ctx, message); err != nil {
+			msg.Nak()
+			continue
+		}
+		msg.Ack()
+	}
+}
+
+// Ping reports whether the connection to NATS is up
+func (b *NATSBus) Ping(ctx context.Context) error {
+	if status := b.conn.Status(); status != nats.CONNECTED {
+		return fmt.Errorf("NATS connection is %s", status)
+	}
+	return nil
+}
+
+// Close closes the connection
+func (b *NATSBus) Close() error {
+	b.conn.Close()
+	return nil
+}
@@ -0,0 +1,148 @@
+package bus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// RabbitMQBus routes messages through a durable topic exchange with the topic as
+// routing key. Each group is a durable queue bound to its topics, so subscribers of a
+// group compete for its messages.
+type RabbitMQBus struct {
+	conn     *amqp.Connection
+	exchange string
+	prefetch int
+
+	// Publishing uses one channel in confirm mode, so a publish returns once the broker
+	// has taken responsibility for the message
+	publisher *amqp.Channel
+	publishMu sync.Mutex
+}
+
+// NewRabbitMQBus connects to the broker at url and declares the exchange
+func NewRabbitMQBus(url, exchange string, prefetch int) (*RabbitMQBus, error) {
+	conn, err := amqp.Dial(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to RabbitMQ: %w", err)
+	}
+	publisher, err := conn.Channel()
+	if err == nil {
+		err = publisher.ExchangeDeclare(exchange, amqp.ExchangeTopic, true, false, false, false, nil)
+	}
+	if err == nil {
+		err = publisher.Confirm(false)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to set up exchange %s: %w", exchange, err)
+	}
+	return &RabbitMQBus{conn: conn, exchange: exchange, prefetch: max(prefetch, 1), publisher: publisher}, nil
+}
+
+// Publish sends a persistent message and waits for the broker's confirmation
+func (b *RabbitMQBus) Publish(ctx context.Context, msg *Message) error {
+	headers := make(amqp.Table, len(msg.Headers))
+	for name, value := range msg.Headers {
+		headers[name] = value
+	}
+
+	b.publishMu.Lock()
+	confirmation, err := b.publisher.PublishWithDeferredConfirmWithContext(ctx, b.exchange, msg.Topic, false, false, amqp.Publishing{
+		Headers:       headers,
+		ContentType:   "application/json",
+		DeliveryMode:  amqp.Persistent,
+		CorrelationId: msg.Key,
+		Timestamp:     time.Now(),
+		Body:          msg.Value,
+	})
+	b.publishMu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to publish to %s: %w", msg.Topic, err)
+	}
+
+	acked, err := confirmation.WaitContext(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to confirm publish to %s: %w", msg.Topic, err)
+	}
+	if !acked {
+		return fmt.Errorf("RabbitMQ refused message for %s", msg.Topic)
+	}
+	return nil
+}
+
+// Subscribe consumes the group's queue, binding it to the topics first. Messages whose
+// handler fails are requeued.
+func (b *RabbitMQBus) Subscribe(ctx context.Context, topics []string, group string, handler Handler) error {
+	ch, err := b.conn.Channel()
+	if err != nil {
+		return fmt.Errorf("failed to open channel: %w", err)
+	}
+	defer ch.Close()
+
+	if _, err := ch.QueueDeclare(group, true, false, false, false, nil); err != nil {
+		return fmt.Errorf("failed to declare queue %s: %w", group, err)
+	}
+	for _, topic := range topics {
+		if err := ch.QueueBind(group, topic, b.exchange, false, nil); err != nil {
+			return fmt.Errorf("failed to bind queue %s to %s: %w", group, topic, err)
+		}
+	}
+	if err := ch.Qos(b.prefetch, 0, false); err != nil {
+		return fmt.Errorf("failed to set prefetch: %w", err)
+	}
+	deliveries, err := ch.ConsumeWithContext(ctx, group, "", false, false, false, false, nil)
+	if err != nil {
+		return fmt.Errorf("failed to consume queue %s: %w", group, err)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case delivery, ok := <-deliveries:
+			if !ok {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				return errors.New("RabbitMQ closed the delivery channel")
+			}
+
+			message := &Message{
+				Topic:   delivery.RoutingKey,
+				Key:     delivery.CorrelationId,
+				Value:   delivery.Body,
+				Time:    delivery.Timestamp,
+				Headers: make(map[string]string, len(delivery.Headers)),
+			}
+			for name, value := range delivery.Headers {
+				if s, ok := value.(string); ok {
+					message.Headers[name] = s
+				}
+			}
+
+			if err := handler(ctx, message); err != nil {
+				delivery.Nack(false, true)
+				continue
+			}
+			delivery.Ack(false)
+		}
+	}
+}
+
+// Ping reports whether the connection to RabbitMQ is open
+func (b *RabbitMQBus) Ping(ctx context.Context) error {
+	if b.conn.IsClosed() {
+		return errors.New("RabbitMQ connection is closed")
+	}
+	return nil
+}
+
+// Close closes the connection and its channels
+func (b *RabbitMQBus) Close() error {
+	return b.conn.Close()
+}
@@ -0,0 +1,337 @@
+// Package client lets Go services emit analytics events. A Client buffers events and
+// sends them in batches from a background goroutine, either to the producer's HTTP API
+// or directly to Kafka, retrying transient failures with exponential backoff:
+//
+//	c := client.New(client.NewHTTPTransport("http://analytics:8080", apiKey, nil), client.Config{})
+//	defer c.Close(context.Background())
+//
+//	ctx = client.WithUser(ctx, userID)
+//	err := c.EmitPageView(ctx, "https://example.com/pricing", nil)
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+	"github.com/google/uuid"
+)
+
+// ErrBufferFull is returned by Emit when the buffer holds Config.BufferSize events
+// waiting to be sent. Emit never blocks the caller.
+var ErrBufferFull = errors.New("analytics client buffer is full")
+
+// ErrClosed is returned by Emit and Flush after Close
+var ErrClosed = errors.New("analytics client is closed")
+
+// Properties are stored in an event's metadata
+type Properties map[string]interface{}
+
+// Config tunes buffering and retries. Zero values use the defaults.
+type Config struct {
+	BatchSize      int           // Events sent per request; default 100
+	FlushInterval  time.Duration // Longest time an event waits for its batch to fill; default 1s
+	BufferSize     int           // Events waiting to be sent before Emit fails; default 10000
+	MaxAttempts    int           // Sends per event, including the first; default 5
+	InitialBackoff time.Duration // Delay before the first retry, doubling per attempt; default 500ms
+	MaxBackoff     time.Duration // Upper bound of the delay; default 30s
+
+	// OnDrop is called from the sending goroutine for each event the pipeline rejected
+	// or that still failed after MaxAttempts
+	OnDrop func(event models.AnalyticsEvent, err error)
+}
+
+func (c Config) withDefaults() Config {
+	if c.BatchSize <= 0 {
+		c.BatchSize = 100
+	}
+	if c.FlushInterval <= 0 {
+		c.FlushInterval = time.Second
+	}
+	if c.BufferSize <= 0 {
+		c.BufferSize = 10000
+	}
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = 5
+	}
+	if c.InitialBackoff <= 0 {
+		c.InitialBackoff = 500 * time.Millisecond
+	}
+	if c.MaxBackoff <= 0 {
+		c.MaxBackoff = 30 * time.Second
+	}
+	return c
+}
+
+// Stats counts the events a client handled
+type Stats struct {
+	Emitted int64 `json:"emitted"` // Accepted by Emit
+	Sent    int64 `json:"sent"`    // Delivered to the pipeline
+	Retried int64 `json:"retried"` // Sends retried after a transient failure
+	Dropped int64 `json:"dropped"` // Rejected by the pipeline or out of attempts
+}
+
+// Client emits events asynchronously. It is safe for concurrent use.
+type Client struct {
+	transport Transport
+	config    Config
+
+	events  chan models.AnalyticsEvent
+	flushes chan chan struct{}
+	closing chan struct{}
+	done    chan struct{}
+
+	// ctx is canceled when Close gives up waiting, aborting sends and backoffs
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// closed is set under mu before closing, so no event is queued once draining starts
+	mu     sync.RWMutex
+	closed bool
+
+	emitted atomic.Int64
+	sent    atomic.Int64
+	retried atomic.Int64
+	dropped atomic.Int64
+}
+
+// New starts a client sending through transport; Close it to send buffered events
+// and stop
+func New(transport Transport, config Config) *Client {
+	config = config.withDefaults()
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &Client{
+		transport: transport,
+		config:    config,
+		events:    make(chan models.AnalyticsEvent, config.BufferSize),
+		flushes:   make(chan chan struct{}),
+		closing:   make(chan struct{}),
+		done:      make(chan struct{}),
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+	go c.run()
+	return c
+}
+
+// Emit queues an event. A missing ID and timestamp are filled in, and a missing user
+// and session taken from ctx (see WithUser and WithSession). Only the context's values
+// are used; the event is sent after Emit returns, even if ctx is canceled.
+func (c *Client) Emit(ctx context.Context, event models.AnalyticsEvent) error {
+	if !event.Type.Valid() {
+		return fmt.Errorf("invalid event type %q", event.Type)
+	}
+	if event.ID == "" {
+		event.ID = uuid.New().String()
+	}
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now().UTC()
+	}
+	if event.UserID == "" {
+		event.UserID, _ = ctx.Value(userKey).(string)
+	}
+	if event.SessionID == "" {
+		event.SessionID, _ = ctx.Value(sessionKey).(string)
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.closed {
+		return ErrClosed
+	}
+	select {
+	case c.events <- event:
+		c.emitted.Add(1)
+		return nil
+	default:
+		return ErrBufferFull
+	}
+}
+
+// EmitPageView queues a page view of pageURL, whose path is derived from it
+func (c *Client) EmitPageView(ctx context.Context, pageURL string, props Properties) error {
+	event := models.AnalyticsEvent{Type: models.PageView, URL: pageURL, Metadata: props}
+	if parsed, err := url.Parse(pageURL); err == nil {
+		event.Path = parsed.Path
+	}
+	return c.Emit(ctx, event)
+}
+
+// EmitClick queues a click on the element with elementID on pageURL. Heatmaps need the
+// x_position, y_position, screen_width and screen_height properties.
+func (c *Client) EmitClick(ctx context.Context, pageURL, elementID string, props Properties) error {
+	metadata := make(Properties, len(props)+1)
+	for key, value := range props {
+		metadata[key] = value
+	}
+	metadata["element_id"] = elementID
+
+	event := models.AnalyticsEvent{Type: models.Click, URL: pageURL, Metadata: metadata}
+	if parsed, err := url.Parse(pageURL); err == nil {
+		event.Path = parsed.Path
+	}
+	return c.Emit(ctx, event)
+}
+
+// EmitCustom queues an event of a custom type such as "signup" or "video_play"
+func (c *Client) EmitCustom(ctx context.Context, eventType string, props Properties) error {
+	return c.Emit(ctx, models.AnalyticsEvent{Type: models.EventType(eventType), Metadata: props})
+}
+
+// Flush sends every event emitted before it was called, waiting until they are
+// delivered or dropped, or until ctx is done
+func (c *Client) Flush(ctx context.Context) error {
+	done := make(chan struct{})
+	select {
+	case c.flushes <- done:
+	case <-c.closing:
+		return ErrClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops accepting events and sends the buffered ones. If ctx is done first,
+// sending is aborted, the remaining events are lost and ctx's error is returned.
+func (c *Client) Close(ctx context.Context) error {
+	c.mu.Lock()
+	if !c.closed {
+		c.closed = true
+		close(c.closing)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-c.done:
+		return nil
+	case <-ctx.Done():
+		c.cancel()
+		<-c.done
+		return ctx.Err()
+	}
+}
+
+// Stats returns the client's counters
+func (c *Client) Stats() Stats {
+	return Stats{
+		Emitted: c.emitted.Load(),
+		Sent:    c.sent.Load(),
+		Retried: c.retried.Load(),
+		Dropped: c.dropped.Load(),
+	}
+}
+
+// run batches events and sends them until the client is closed
+func (c *Client) run() {
+	defer close(c.done)
+	defer c.cancel()
+
+	ticker := time.NewTicker(c.config.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]models.AnalyticsEvent, 0, c.config.BatchSize)
+	send := func() {
+		if len(batch) > 0 {
+			c.send(batch)
+			batch = make([]models.AnalyticsEvent, 0, c.config.BatchSize)
+		}
+	}
+	// drain sends everything buffered so far
+	drain := func() {
+		for {
+			select {
+			case event := <-c.events:
+				if batch = append(batch, event); len(batch) >= c.config.BatchSize {
+					send()
+				}
+			default:
+				send()
+				return
+			}
+		}
+	}
+
+	for {
+		select {
+		case event := <-c.events:
+			if batch = append(batch, event); len(batch) >= c.config.BatchSize {
+				send()
+			}
+		case <-ticker.C:
+			send()
+		case done := <-c.flushes:
+			drain()
+			close(done)
+		case <-c.closing:
+			drain()
+			return
+		}
+	}
+}
+
+// send delivers a batch, resending events that failed transiently until they run out
+// of attempts
+func (c *Client) send(events []models.AnalyticsEvent) {
+	backoff := c.config.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		result := c.transport.Send(c.ctx, events)
+		c.sent.Add(int64(len(events) - len(result.Retry) - len(result.Rejected)))
+		for _, rejection := range result.Rejected {
+			c.drop(rejection.Event, rejection.Err)
+		}
+		if len(result.Retry) == 0 {
+			return
+		}
+
+		events = result.Retry
+		if attempt == c.config.MaxAttempts || c.ctx.Err() != nil {
+			for _, event := range events {
+				c.drop(event, fmt.Errorf("giving up after %d attempts: %w", attempt, result.Err))
+			}
+			return
+		}
+
+		c.retried.Add(int64(len(events)))
+		select {
+		case <-time.After(backoff):
+		case <-c.ctx.Done():
+		}
+		backoff = min(2*backoff, c.config.MaxBackoff)
+	}
+}
+
+func (c *Client) drop(event models.AnalyticsEvent, err error) {
+	c.dropped.Add(1)
+	if c.config.OnDrop != nil {
+		c.config.OnDrop(event, err)
+	}
+}
+
+type contextKey int
+
+const (
+	userKey contextKey = iota
+	sessionKey
+)
+
+// WithUser returns a context whose events are attributed to userID, so handlers
+// emitting events needn't pass it along
+func WithUser(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, userKey, userID)
+}
+
+// WithSession returns a context whose events belong to sessionID
+func WithSession(ctx context.Context, sessionID string) context.Context {
+	return context.WithValue(ctx, sessionKey, sessionID)
+}
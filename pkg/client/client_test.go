@@ -0,0 +1,248 @@
+package client
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/kafka/kafkatest"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+// fakeTransport records batches and answers with results popped from a queue
+type fakeTransport struct {
+	mu      sync.Mutex
+	batches [][]models.AnalyticsEvent
+	results []func(events []models.AnalyticsEvent) Result
+}
+
+func (t *fakeTransport) Send(ctx context.Context, events []models.AnalyticsEvent) Result {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.batches = append(t.batches, events)
+	if len(t.results) == 0 {
+		return Result{}
+	}
+	result := t.results[0]
+	t.results = t.results[1:]
+	return result(events)
+}
+
+func (t *fakeTransport) sent() [][]models.AnalyticsEvent {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.batches
+}
+
+func TestEmitPopulatesEvents(t *testing.T) {
+	transport := &fakeTransport{}
+	c := New(transport, Config{FlushInterval: time.Hour})
+
+	ctx := WithSession(WithUser(context.Background(), "u1"), "s1")
+	if err := c.EmitPageView(ctx, "https://example.com/pricing?plan=pro", Properties{"page_title": "Pricing"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.EmitClick(ctx, "https://example.com/pricing", "buy", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.EmitCustom(context.Background(), "signup", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.EmitCustom(ctx, "Not A Type", nil); err == nil {
+		t.Error("expected an invalid event type rejected")
+	}
+	if err := c.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	batches := transport.sent()
+	if len(batches) != 1 || len(batches[0]) != 3 {
+		t.Fatalf("expected one batch of 3 events on close, got %v", batches)
+	}
+	view, click, custom := batches[0][0], batches[0][1], batches[0][2]
+	if view.ID == "" || view.Timestamp.IsZero() || view.UserID != "u1" || view.SessionID != "s1" || view.Path != "/pricing" {
+		t.Errorf("expected the page view populated from the context and URL, got %+v", view)
+	}
+	if click.Type != models.Click || click.Metadata["element_id"] != "buy" {
+		t.Errorf("expected the click's element in metadata, got %+v", click)
+	}
+	if custom.Type != "signup" || custom.UserID != "" {
+		t.Errorf("expected an anonymous custom event, got %+v", custom)
+	}
+	if err := c.EmitCustom(ctx, "signup", nil); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed after Close, got %v", err)
+	}
+}
+
+func TestClientBatchesAndRetries(t *testing.T) {
+	transport := &fakeTransport{results: []func([]models.AnalyticsEvent) Result{
+		// The first batch has one event throttled and one rejected
+		func(events []models.AnalyticsEvent) Result {
+			return Result{
+				Retry:    events[:1],
+				Rejected: []Rejection{{Event: events[1], Err: errors.New("invalid_event")}},
+				Err:      errors.New("throttled"),
+			}
+		},
+	}}
+	var dropped []models.AnalyticsEvent
+	c := New(transport, Config{
+		BatchSize:      3,
+		FlushInterval:  time.Hour,
+		InitialBackoff: time.Millisecond,
+		OnDrop:         func(event models.AnalyticsEvent, err error) { dropped = append(dropped, event) },
+	})
+
+	for i := 0; i < 4; i++ {
+		if err := c.EmitCustom(context.Background(), "signup", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	batches := transport.sent()
+	if len(batches) != 3 || len(batches[0]) != 3 || len(batches[1]) != 1 || len(batches[2]) != 1 {
+		t.Fatalf("expected a full batch, its retry and the flushed remainder, got %d batches", len(batches))
+	}
+	if batches[1][0].ID != batches[0][0].ID {
+		t.Error("expected the throttled event retried")
+	}
+	if len(dropped) != 1 || dropped[0].ID != batches[0][1].ID {
+		t.Errorf("expected the rejected event dropped, got %v", dropped)
+	}
+	if stats := c.Stats(); stats.Emitted != 4 || stats.Sent != 3 || stats.Retried != 1 || stats.Dropped != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	c.Close(context.Background())
+}
+
+func TestClientGivesUpAfterMaxAttempts(t *testing.T) {
+	unavailable := func(events []models.AnalyticsEvent) Result {
+		return Result{Retry: events, Err: errors.New("unavailable")}
+	}
+	transport := &fakeTransport{results: []func([]models.AnalyticsEvent) Result{unavailable, unavailable, unavailable}}
+	c := New(transport, Config{MaxAttempts: 2, InitialBackoff: time.Millisecond})
+
+	c.EmitCustom(context.Background(), "signup", nil)
+	c.Close(context.Background())
+	if len(transport.sent()) != 2 {
+		t.Errorf("expected 2 attempts, got %d", len(transport.sent()))
+	}
+	if stats := c.Stats(); stats.Dropped != 1 || stats.Sent != 0 {
+		t.Errorf("expected the event dropped, got %+v", stats)
+	}
+}
+
+func TestEmitFailsWhenBufferFull(t *testing.T) {
+	block := make(chan struct{})
+	transport := &fakeTransport{results: []func([]models.AnalyticsEvent) Result{
+		func(events []models.AnalyticsEvent) Result { <-block; return Result{} },
+	}}
+	c := New(transport, Config{BatchSize: 1, BufferSize: 1})
+	defer c.Close(context.Background())
+	defer close(block)
+
+	var err error
+	for i := 0; i < 10 && err == nil; i++ {
+		err = c.EmitCustom(context.Background(), "signup", nil)
+	}
+	if !errors.Is(err, ErrBufferFull) {
+		t.Errorf("expected ErrBufferFull while the transport is blocked, got %v", err)
+	}
+}
+
+func TestHTTPTransport(t *testing.T) {
+	var apiKey string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apiKey = r.Header.Get("X-API-Key")
+		var events []models.AnalyticsEvent
+		if r.URL.Path != "/events/batch" || json.NewDecoder(r.Body).Decode(&events) != nil || len(events) != 3 {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"results": []map[string]interface{}{
+				{"index": 0, "status": "accepted"},
+				{"index": 1, "status": "rejected", "code": "throttled", "error": "Rate limit exceeded"},
+				{"index": 2, "status": "rejected", "code": "invalid_event", "error": "Invalid purchase event"},
+			},
+		})
+	}))
+	defer server.Close()
+
+	events := []models.AnalyticsEvent{{ID: "a", Type: "signup"}, {ID: "b", Type: "signup"}, {ID: "c", Type: "purchase"}}
+	result := NewHTTPTransport(server.URL+"/", "secret", nil).Send(context.Background(), events)
+	if apiKey != "secret" {
+		t.Errorf("expected the API key sent, got %q", apiKey)
+	}
+	if len(result.Retry) != 1 || result.Retry[0].ID != "b" {
+		t.Errorf("expected the throttled event retried, got %+v", result.Retry)
+	}
+	if len(result.Rejected) != 1 || result.Rejected[0].Event.ID != "c" {
+		t.Errorf("expected the invalid event rejected, got %+v", result.Rejected)
+	}
+
+	// Whole-request failures retry or reject every event by status
+	for status, retry := range map[int]bool{http.StatusServiceUnavailable: true, http.StatusUnauthorized: false} {
+		failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+		}))
+		result := NewHTTPTransport(failing.URL, "", nil).Send(context.Background(), events)
+		failing.Close()
+		if retry && len(result.Retry) != 3 || !retry && len(result.Rejected) != 3 {
+			t.Errorf("status %d: unexpected result %+v", status, result)
+		}
+	}
+}
+
+func TestHTTPTransportGzip(t *testing.T) {
+	var received []models.AnalyticsEvent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Encoding") != "gzip" {
+			t.Errorf("expected a gzip body, got Content-Encoding %q", r.Header.Get("Content-Encoding"))
+		}
+		reader, err := gzip.NewReader(r.Body)
+		if err != nil {
+			t.Fatalf("expected a gzip body: %v", err)
+		}
+		json.NewDecoder(reader).Decode(&received)
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"results": []map[string]interface{}{{"index": 0, "status": "accepted"}},
+		})
+	}))
+	defer server.Close()
+
+	transport := NewHTTPTransport(server.URL, "", nil)
+	transport.SetGzip(true)
+	result := transport.Send(context.Background(), []models.AnalyticsEvent{{ID: "a", Type: "signup"}})
+	if len(result.Retry) != 0 || len(result.Rejected) != 0 || len(received) != 1 || received[0].ID != "a" {
+		t.Errorf("expected the event delivered compressed, got %+v and %+v", result, received)
+	}
+}
+
+func TestKafkaTransport(t *testing.T) {
+	producer := kafkatest.NewProducer("analytics-events")
+	events := []models.AnalyticsEvent{{ID: "a", Type: "signup"}}
+
+	if result := NewKafkaTransport(producer).Send(context.Background(), events); len(result.Retry) != 0 {
+		t.Fatalf("expected the event written, got %+v", result)
+	}
+	if sent := producer.Sent(); len(sent) != 1 || sent[0].Key != "a" {
+		t.Errorf("expected the event keyed by ID, got %+v", sent)
+	}
+
+	producer.FailSends(errors.New("broker unavailable"))
+	if result := NewKafkaTransport(producer).Send(context.Background(), events); len(result.Retry) != 1 {
+		t.Errorf("expected a failed write retried, got %+v", result)
+	}
+}
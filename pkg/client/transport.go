@@ -0,0 +1,183 @@
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+// Transport delivers batches of events to the pipeline
+type Transport interface {
+	// Send delivers events, reporting those that should be sent again and those the
+	// pipeline refused
+	Send(ctx context.Context, events []models.AnalyticsEvent) Result
+}
+
+// Result is the outcome of sending a batch; events in neither list were delivered
+type Result struct {
+	Retry    []models.AnalyticsEvent // Failed transiently, e.g. throttled or Kafka unavailable
+	Rejected []Rejection             // Refused as invalid; never sent again
+	Err      error                   // Why events are retried
+}
+
+// Rejection is an event the pipeline refused
+type Rejection struct {
+	Event models.AnalyticsEvent
+	Err   error
+}
+
+// retryCodes are the /events/batch error codes of events worth sending again
+var retryCodes = map[string]bool{"throttled": true, "send_failed": true}
+
+// HTTPTransport posts batches to the producer's /events/batch endpoint, which applies
+// bot detection, sampling and privacy settings like it does to browser events
+type HTTPTransport struct {
+	endpoint string
+	apiKey   string
+	client   *http.Client
+	gzip     bool
+}
+
+// NewHTTPTransport sends to the producer at baseURL, authenticating with apiKey when
+// it isn't empty. A nil client uses one with a 10 second timeout.
+func NewHTTPTransport(baseURL, apiKey string, client *http.Client) *HTTPTransport {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &HTTPTransport{
+		endpoint: strings.TrimSuffix(baseURL, "/") + "/events/batch",
+		apiKey:   apiKey,
+		client:   client,
+	}
+}
+
+// SetGzip compresses batch bodies with gzip, which producers accept unless gzip was
+// removed from their INGEST_ENCODINGS
+func (t *HTTPTransport) SetGzip(enabled bool) {
+	t.gzip = enabled
+}
+
+// Send posts events as one batch. Network errors, 429 and 5xx responses retry the
+// whole batch, except that events the producer reports as accepted are not resent.
+func (t *HTTPTransport) Send(ctx context.Context, events []models.AnalyticsEvent) Result {
+	body, err := json.Marshal(events)
+	if err != nil {
+		return rejectAll(events, fmt.Errorf("failed to marshal events: %w", err))
+	}
+	if t.gzip {
+		var compressed bytes.Buffer
+		writer := gzip.NewWriter(&compressed)
+		writer.Write(body)
+		writer.Close()
+		body = compressed.Bytes()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return rejectAll(events, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if t.gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	req.Header.Set("User-Agent", "go-kafka-analytics-pipeline-client")
+	if t.apiKey != "" {
+		req.Header.Set("X-API-Key", t.apiKey)
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return Result{Retry: events, Err: err}
+	}
+	defer resp.Body.Close()
+
+	var response struct {
+		Results []struct {
+			Index  int    `json:"index"`
+			Status string `json:"status"`
+			Code   string `json:"code"`
+			Error  string `json:"error"`
+		} `json:"results"`
+		Code  string `json:"code"`
+		Error string `json:"error"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	decodeErr := json.Unmarshal(data, &response)
+
+	status := fmt.Errorf("analytics API responded %s", resp.Status)
+	if response.Error != "" {
+		status = fmt.Errorf("analytics API responded %s: %s", resp.Status, response.Error)
+	}
+
+	// Batch responses report each event, also when some failed to reach Kafka
+	if decodeErr == nil && len(response.Results) == len(events) {
+		var result Result
+		for _, r := range response.Results {
+			if r.Status != "rejected" || r.Index < 0 || r.Index >= len(events) {
+				continue
+			}
+			err := fmt.Errorf("%s: %s", r.Code, r.Error)
+			if retryCodes[r.Code] {
+				result.Retry = append(result.Retry, events[r.Index])
+				result.Err = err
+				continue
+			}
+			result.Rejected = append(result.Rejected, Rejection{Event: events[r.Index], Err: err})
+		}
+		return result
+	}
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return Result{}
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return Result{Retry: events, Err: status}
+	default:
+		return rejectAll(events, status)
+	}
+}
+
+// KafkaProducer writes events to Kafka; *kafka.Producer implements it
+type KafkaProducer interface {
+	EventKey(event *models.AnalyticsEvent) string
+	SendEvent(ctx context.Context, key string, value interface{}) error
+}
+
+// KafkaTransport writes events straight to the producer's topic. It skips the HTTP
+// producer's bot detection, sampling and privacy settings, so it suits trusted
+// server-side events.
+type KafkaTransport struct {
+	producer KafkaProducer
+}
+
+// NewKafkaTransport sends through producer, keyed by its key strategy
+func NewKafkaTransport(producer KafkaProducer) *KafkaTransport {
+	return &KafkaTransport{producer: producer}
+}
+
+// Send writes each event, retrying the ones whose write failed
+func (t *KafkaTransport) Send(ctx context.Context, events []models.AnalyticsEvent) Result {
+	var result Result
+	for i := range events {
+		if err := t.producer.SendEvent(ctx, t.producer.EventKey(&events[i]), &events[i]); err != nil {
+			result.Retry = append(result.Retry, events[i])
+			result.Err = err
+		}
+	}
+	return result
+}
+
+func rejectAll(events []models.AnalyticsEvent, err error) Result {
+	result := Result{Rejected: make([]Rejection, len(events))}
+	for i, event := range events {
+		result.Rejected[i] = Rejection{Event: event, Err: err}
+	}
+	return result
+}
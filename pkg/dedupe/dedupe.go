@@ -0,0 +1,86 @@
+package dedupe
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/logging"
+)
+
+// Store remembers event IDs for a bounded time
+type Store interface {
+	// MarkSeen records the ID and reports whether it had already been seen
+	MarkSeen(ctx context.Context, id string) (bool, error)
+
+	// Forget removes the ID so a later delivery is processed again
+	Forget(ctx context.Context, id string) error
+
+	// Close releases any resources held by the store
+	Close() error
+}
+
+// Stats reports deduplication counters
+type Stats struct {
+	Checked    int64 `json:"checked"`
+	Duplicates int64 `json:"duplicates"`
+	Errors     int64 `json:"errors"`
+}
+
+// Deduplicator drops repeated deliveries of the same event ID
+type Deduplicator struct {
+	store Store
+
+	checked    atomic.Int64
+	duplicates atomic.Int64
+	errors     atomic.Int64
+}
+
+// NewDeduplicator creates a deduplicator backed by the given store
+func NewDeduplicator(store Store) *Deduplicator {
+	return &Deduplicator{store: store}
+}
+
+// IsDuplicate marks the event ID as seen and reports whether it was already processed.
+// Events without an ID are never duplicates, and store errors fail open so events are
+// not lost when the backing store is unavailable.
+func (d *Deduplicator) IsDuplicate(ctx context.Context, id string) bool {
+	if id == "" {
+		return false
+	}
+	d.checked.Add(1)
+
+	seen, err := d.store.MarkSeen(ctx, id)
+	if err != nil {
+		d.errors.Add(1)
+		logging.Warn("Dedupe check failed, processing anyway", "event_id", id, "error", err)
+		return false
+	}
+	if seen {
+		d.duplicates.Add(1)
+	}
+	return seen
+}
+
+// Release forgets an event ID, typically after processing failed so a redelivery is not dropped
+func (d *Deduplicator) Release(ctx context.Context, id string) {
+	if id == "" {
+		return
+	}
+	if err := d.store.Forget(ctx, id); err != nil {
+		logging.Error("Failed to release event from dedupe store", "event_id", id, "error", err)
+	}
+}
+
+// Stats returns the current counters
+func (d *Deduplicator) Stats() Stats {
+	return Stats{
+		Checked:    d.checked.Load(),
+		Duplicates: d.duplicates.Load(),
+		Errors:     d.errors.Load(),
+	}
+}
+
+// Close closes the backing store
+func (d *Deduplicator) Close() error {
+	return d.store.Close()
+}
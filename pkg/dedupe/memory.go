@@ -0,0 +1,34 @@
+package dedupe
+
+import (
+	"context"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/idset"
+)
+
+// MemoryStore is an in-memory LRU of event IDs with a TTL
+type MemoryStore struct {
+	ids *idset.Set
+}
+
+// NewMemoryStore creates an LRU store holding at most capacity IDs for ttl each
+func NewMemoryStore(capacity int, ttl time.Duration) *MemoryStore {
+	return &MemoryStore{ids: idset.New(capacity, ttl)}
+}
+
+// MarkSeen records the ID and reports whether an unexpired entry already existed
+func (m *MemoryStore) MarkSeen(_ context.Context, id string) (bool, error) {
+	return m.ids.Add(id), nil
+}
+
+// Forget removes the ID from the store
+func (m *MemoryStore) Forget(_ context.Context, id string) error {
+	m.ids.Remove(id)
+	return nil
+}
+
+// Close is a no-op for the memory store
+func (m *MemoryStore) Close() error {
+	return nil
+}
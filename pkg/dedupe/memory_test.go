@@ -0,0 +1,32 @@
+package dedupe
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestDeduplicatorDropsRepeats(t *testing.T) {
+	ctx := context.Background()
+	d := NewDeduplicator(NewMemoryStore(10, time.Minute))
+
+	if d.IsDuplicate(ctx, "evt-1") {
+		t.Fatal("First delivery should not be a duplicate")
+	}
+	if !d.IsDuplicate(ctx, "evt-1") {
+		t.Fatal("Second delivery should be a duplicate")
+	}
+	if d.IsDuplicate(ctx, "") {
+		t.Fatal("Events without an ID should never be duplicates")
+	}
+
+	d.Release(ctx, "evt-1")
+	if d.IsDuplicate(ctx, "evt-1") {
+		t.Fatal("Released ID should be processed again")
+	}
+
+	stats := d.Stats()
+	if stats.Checked != 3 || stats.Duplicates != 1 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
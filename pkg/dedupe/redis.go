@@ -0,0 +1,57 @@
+package dedupe
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/errs"
+)
+
+// RedisStore shares seen event IDs across consumer replicas using Redis keys with a TTL
+type RedisStore struct {
+	client *redis.Client
+	ttl    time.Duration
+	prefix string
+}
+
+// NewRedisStore connects to the Redis server at redisURL (e.g. redis://localhost:6379/0)
+func NewRedisStore(ctx context.Context, redisURL string, ttl time.Duration) (*RedisStore, error) {
+	options, err := redis.ParseURL(redisURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis URL: %w", err)
+	}
+
+	client := redis.NewClient(options)
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to redis: %w", err)
+	}
+
+	return &RedisStore{
+		client: client,
+		ttl:    ttl,
+		prefix: "analytics:dedupe:",
+	}, nil
+}
+
+// MarkSeen atomically sets the ID key if absent and reports whether it already existed
+func (r *RedisStore) MarkSeen(ctx context.Context, id string) (bool, error) {
+	created, err := r.client.SetNX(ctx, r.prefix+id, 1, r.ttl).Result()
+	if err != nil {
+		return false, errs.StoreError(err)
+	}
+	return !created, nil
+}
+
+// Forget deletes the ID key
+func (r *RedisStore) Forget(ctx context.Context, id string) error {
+	return errs.StoreError(r.client.Del(ctx, r.prefix+id).Err())
+}
+
+// Close closes the Redis client
+func (r *RedisStore) Close() error {
+	return r.client.Close()
+}
@@ -0,0 +1,126 @@
+// Package errs classifies the pipeline's errors by kind, so every layer reacts to a
+// failure the same way: each kind has a stable code for clients to branch on, and the
+// producer and consumers decide from it whether retrying can help. Mapping kinds to
+// transport statuses, such as HTTP's, is left to the servers. Packages
+// describe failures with Errorf, adding a kind to the usual wrapping, and callers test
+// for the kind with errors.Is however deeply the error was wrapped since.
+package errs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+)
+
+// Kinds of errors. Return them wrapped by Errorf, or on their own when there is
+// nothing to add.
+var (
+	ErrInvalidEvent     = errors.New("invalid event")     // An event that can never be processed
+	ErrInvalidRequest   = errors.New("invalid request")   // A malformed request or configuration
+	ErrNotFound         = errors.New("not found")         // The named resource doesn't exist
+	ErrConflict         = errors.New("already exists")    // The named resource already exists
+	ErrThrottled        = errors.New("throttled")         // A rate limit or back-pressure rejected the call
+	ErrKafkaUnavailable = errors.New("kafka unavailable") // The brokers could not be reached or written to
+	ErrStoreTimeout     = errors.New("store timed out")   // A state store such as Redis failed to answer in time
+)
+
+// policy is how a kind of error is reported and whether it is retried
+type policy struct {
+	kind  error
+	code  string
+	retry bool
+}
+
+// policies lists the kinds in the order KindOf checks them
+var policies = []policy{
+	{ErrInvalidEvent, "invalid_event", false},
+	{ErrInvalidRequest, "invalid_request", false},
+	{ErrNotFound, "not_found", false},
+	{ErrConflict, "conflict", false},
+	{ErrThrottled, "throttled", true},
+	{ErrKafkaUnavailable, "kafka_unavailable", true},
+	{ErrStoreTimeout, "store_timeout", true},
+}
+
+// Unclassified errors are reported as internal, and retried as they were before kinds
+// existed
+var unclassified = policy{code: "internal", retry: true}
+
+// Error is an error of a kind. errors.Is matches both its kind and the errors it wraps,
+// and errors.As finds it to read the kind.
+type Error struct {
+	Kind error // One of the Err variables
+	Err  error // The failure as described, wrapping its cause if any
+}
+
+// Errorf returns an error of the given kind formatted like fmt.Errorf, so %w wraps a
+// cause. A nil kind leaves the error unclassified.
+func Errorf(kind error, format string, args ...interface{}) error {
+	err := fmt.Errorf(format, args...)
+	if kind == nil {
+		return err
+	}
+	return &Error{Kind: kind, Err: err}
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the kind and the described failure
+func (e *Error) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
+
+// StoreError classifies an error of a state store such as Redis: expired deadlines and
+// network timeouts become ErrStoreTimeout, and other errors are returned unchanged
+func StoreError(err error) error {
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return &Error{Kind: ErrStoreTimeout, Err: err}
+	}
+	return err
+}
+
+// KindOf returns the kind of err: that of the outermost *Error it wraps, or the kind
+// it wraps directly. It returns nil for unclassified errors.
+func KindOf(err error) error {
+	if err == nil {
+		return nil
+	}
+	var kindErr *Error
+	if errors.As(err, &kindErr) {
+		return kindErr.Kind
+	}
+	for _, p := range policies {
+		if errors.Is(err, p.kind) {
+			return p.kind
+		}
+	}
+	return nil
+}
+
+// policyOf returns the policy of err's kind
+func policyOf(err error) policy {
+	kind := KindOf(err)
+	for _, p := range policies {
+		if p.kind == kind {
+			return p
+		}
+	}
+	return unclassified
+}
+
+// Code returns the stable code clients can branch on, such as kafka_unavailable;
+// unclassified errors are internal
+func Code(err error) string {
+	return policyOf(err).code
+}
+
+// Retryable reports whether retrying the failed call may succeed. Invalid input and
+// missing or conflicting resources fail the same way every time; unclassified errors
+// are assumed transient.
+func Retryable(err error) bool {
+	return policyOf(err).retry
+}
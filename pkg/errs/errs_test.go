@@ -0,0 +1,51 @@
+package errs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+)
+
+func TestKindSurvivesWrapping(t *testing.T) {
+	err := fmt.Errorf("failed to send event: %w", Errorf(ErrKafkaUnavailable, "failed to write message: %w", io.ErrUnexpectedEOF))
+
+	if !errors.Is(err, ErrKafkaUnavailable) || !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("expected both the kind and the cause to match, got %v", err)
+	}
+	if err.Error() != "failed to send event: failed to write message: unexpected EOF" {
+		t.Errorf("expected the kind left out of the message, got %q", err.Error())
+	}
+	var kindErr *Error
+	if !errors.As(err, &kindErr) || kindErr.Kind != ErrKafkaUnavailable {
+		t.Errorf("expected errors.As to find the kind, got %+v", kindErr)
+	}
+}
+
+func TestPolicies(t *testing.T) {
+	for _, tc := range []struct {
+		err   error
+		code  string
+		retry bool
+	}{
+		{Errorf(ErrInvalidEvent, "invalid schema version header"), "invalid_event", false},
+		{fmt.Errorf("lookup: %w", ErrNotFound), "not_found", false},
+		{Errorf(ErrStoreTimeout, "dedupe: %w", ErrNotFound), "store_timeout", true},
+		{ErrThrottled, "throttled", true},
+		{errors.New("boom"), "internal", true},
+	} {
+		if code, retry := Code(tc.err), Retryable(tc.err); code != tc.code || retry != tc.retry {
+			t.Errorf("%v: expected %s retry=%v, got %s retry=%v", tc.err, tc.code, tc.retry, code, retry)
+		}
+	}
+	if err := StoreError(context.DeadlineExceeded); !errors.Is(err, ErrStoreTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected an expired deadline to be a store timeout, got %v", err)
+	}
+	if err := StoreError(io.EOF); err != io.EOF || StoreError(nil) != nil {
+		t.Errorf("expected other store errors unchanged, got %v", err)
+	}
+	if KindOf(nil) != nil {
+		t.Error("expected nil to have no kind")
+	}
+}
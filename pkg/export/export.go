@@ -0,0 +1,98 @@
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Format is a spreadsheet file format
+type Format string
+
+const (
+	CSV  Format = "csv"
+	XLSX Format = "xlsx"
+)
+
+// ParseFormat parses a format name; an empty name selects CSV
+func ParseFormat(name string) (Format, error) {
+	switch Format(strings.ToLower(name)) {
+	case "", CSV:
+		return CSV, nil
+	case XLSX:
+		return XLSX, nil
+	default:
+		return "", fmt.Errorf("unknown export format %q (use csv or xlsx)", name)
+	}
+}
+
+// ContentType returns the MIME type of files in this format
+func (f Format) ContentType() string {
+	if f == XLSX {
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// RowWriter writes a table one row at a time. Close must be called to finish the file.
+type RowWriter interface {
+	WriteRow(cells []string) error
+	Close() error
+}
+
+// NewWriter returns a RowWriter producing the given format. CSV rows are streamed to w
+// as they are written; XLSX rows are buffered until Close, since the workbook is a zip archive.
+func NewWriter(w io.Writer, format Format) RowWriter {
+	if format == XLSX {
+		return &xlsxWriter{w: w}
+	}
+	return &csvWriter{w: csv.NewWriter(w)}
+}
+
+// csvWriter streams rows as RFC 4180 CSV
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func (c *csvWriter) WriteRow(cells []string) error {
+	escaped := make([]string, len(cells))
+	for i, cell := range cells {
+		escaped[i] = escapeFormula(cell)
+	}
+	return c.w.Write(escaped)
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// escapeFormula stops spreadsheet applications from evaluating text such as URLs or user
+// IDs as formulas, by prefixing values that start with a formula character with a quote.
+// Numbers are left alone so negative values still import as numbers.
+func escapeFormula(cell string) string {
+	if cell == "" || !strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+		return cell
+	}
+	if isNumber(cell) {
+		return cell
+	}
+	return "'" + cell
+}
+
+// isNumber reports whether a cell is a plain decimal number. Values such as "Inf", hex
+// floats and zero-padded IDs parse as floats but are kept as text.
+func isNumber(cell string) bool {
+	if _, err := strconv.ParseFloat(cell, 64); err != nil {
+		return false
+	}
+	digits := strings.TrimLeft(cell, "+-")
+	if len(digits) > 1 && digits[0] == '0' && digits[1] != '.' {
+		return false
+	}
+	return strings.IndexFunc(cell, func(r rune) bool {
+		return (r < '0' || r > '9') && !strings.ContainsRune("+-.eE", r)
+	}) == -1
+}
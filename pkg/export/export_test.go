@@ -0,0 +1,65 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+func TestCSVEscapesFormulas(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf, CSV)
+	if err := WriteSources(w, []models.TrafficSource{{Source: "=HYPERLINK(\"x\")", Count: 3, Percent: 75, Channel: "referral"}, {Source: "-5", Count: 1, Percent: 25}}); err != nil {
+		t.Fatalf("WriteSources failed: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	want := "source,count,percent,channel\n\"'=HYPERLINK(\"\"x\"\")\",3,75,referral\n-5,1,25,\n"
+	if buf.String() != want {
+		t.Errorf("unexpected CSV:\n%s", buf.String())
+	}
+}
+
+func TestXLSXWorkbook(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf, XLSX)
+	w.WriteRow([]string{"path", "views", "id"})
+	w.WriteRow([]string{"/a&b", "12", "007"})
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("workbook is not a zip archive: %v", err)
+	}
+	var sheet string
+	for _, f := range zr.File {
+		if f.Name == "xl/worksheets/sheet1.xml" {
+			rc, _ := f.Open()
+			data, _ := io.ReadAll(rc)
+			rc.Close()
+			sheet = string(data)
+		}
+	}
+
+	for _, want := range []string{`<c r="B2"><v>12</v></c>`, `/a&amp;b`, `<c r="C2" t="inlineStr"><is><t xml:space="preserve">007</t>`} {
+		if !strings.Contains(sheet, want) {
+			t.Errorf("sheet missing %s:\n%s", want, sheet)
+		}
+	}
+}
+
+func TestColumnName(t *testing.T) {
+	for i, want := range map[int]string{0: "A", 25: "Z", 26: "AA", 701: "ZZ", 702: "AAA"} {
+		if got := columnName(i); got != want {
+			t.Errorf("columnName(%d) = %s, want %s", i, got, want)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package export
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+// EventHeader is the header row of a raw event export; the columns match the warehouse sinks
+var EventHeader = []string{"id", "type", "timestamp", "user_id", "session_id", "url", "path", "referrer", "user_agent", "ip_address", "metadata"}
+
+// EventRow flattens an event into the columns of EventHeader, encoding metadata as JSON
+func EventRow(event *models.AnalyticsEvent) []string {
+	metadata := "{}"
+	if len(event.Metadata) > 0 {
+		if data, err := json.Marshal(event.Metadata); err == nil {
+			metadata = string(data)
+		}
+	}
+	return []string{
+		event.ID,
+		string(event.Type),
+		formatTime(event.Timestamp),
+		event.UserID,
+		event.SessionID,
+		event.URL,
+		event.Path,
+		event.Referrer,
+		event.UserAgent,
+		event.IPAddress,
+		metadata,
+	}
+}
+
+// WritePages writes a header row and one row per page
+func WritePages(w RowWriter, pages []models.PageMetric) error {
+	if err := w.WriteRow([]string{"url", "path", "views", "unique_visitors", "average_time_seconds", "bounce_rate"}); err != nil {
+		return err
+	}
+	for _, page := range pages {
+		err := w.WriteRow([]string{
+			page.URL,
+			page.Path,
+			formatInt(page.Views),
+			formatInt(page.UniqueVisitors),
+			formatFloat(page.AverageTime),
+			formatFloat(page.BounceRate),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteSources writes a header row and one row per traffic source
+func WriteSources(w RowWriter, sources []models.TrafficSource) error {
+	if err := w.WriteRow([]string{"source", "count", "percent", "channel"}); err != nil {
+		return err
+	}
+	for _, source := range sources {
+		if err := w.WriteRow([]string{source.Source, formatInt(source.Count), formatFloat(source.Percent), source.Channel}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteRollups writes a header row and one row per rollup. Every event type seen in any
+// rollup gets a count column, so all rows have the same columns.
+func WriteRollups(w RowWriter, rollups []models.Rollup) error {
+	seen := make(map[models.EventType]bool)
+	var types []models.EventType
+	for _, rollup := range rollups {
+		for eventType := range rollup.EventsByType {
+			if !seen[eventType] {
+				seen[eventType] = true
+				types = append(types, eventType)
+			}
+		}
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+
+	header := []string{"start", "granularity", "events", "page_views", "unique_users", "sessions"}
+	for _, eventType := range types {
+		header = append(header, string(eventType))
+	}
+	if err := w.WriteRow(header); err != nil {
+		return err
+	}
+
+	for _, rollup := range rollups {
+		row := []string{
+			formatTime(rollup.Start),
+			string(rollup.Granularity),
+			formatInt(rollup.Events),
+			formatInt(rollup.PageViews),
+			formatInt(rollup.UniqueUsers),
+			formatInt(rollup.Sessions),
+		}
+		for _, eventType := range types {
+			row = append(row, formatInt(rollup.EventsByType[eventType]))
+		}
+		if err := w.WriteRow(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func formatInt(v int64) string {
+	return strconv.FormatInt(v, 10)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
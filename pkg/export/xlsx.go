@@ -0,0 +1,114 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Static parts of a single-sheet workbook
+const (
+	xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>
+<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>
+</Types>`
+
+	xlsxRootRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>
+</Relationships>`
+
+	xlsxWorkbook = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="Export" sheetId="1" r:id="rId1"/></sheets>
+</workbook>`
+
+	xlsxWorkbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+</Relationships>`
+)
+
+// xlsxWriter builds a minimal Office Open XML workbook with one sheet. Cells that parse as
+// numbers are stored as numbers; everything else is an inline string, so no shared string
+// table or styles are needed.
+type xlsxWriter struct {
+	w    io.Writer
+	rows bytes.Buffer
+	n    int
+}
+
+func (x *xlsxWriter) WriteRow(cells []string) error {
+	x.n++
+	fmt.Fprintf(&x.rows, `<row r="%d">`, x.n)
+	for i, cell := range cells {
+		ref := columnName(i) + strconv.Itoa(x.n)
+		if isNumber(cell) {
+			fmt.Fprintf(&x.rows, `<c r="%s"><v>%s</v></c>`, ref, cell)
+			continue
+		}
+		fmt.Fprintf(&x.rows, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">`, ref)
+		if err := xml.EscapeText(&x.rows, []byte(cell)); err != nil {
+			return err
+		}
+		x.rows.WriteString(`</t></is></c>`)
+	}
+	x.rows.WriteString(`</row>`)
+	return nil
+}
+
+func (x *xlsxWriter) Close() error {
+	zw := zip.NewWriter(x.w)
+
+	parts := []struct {
+		name string
+		body []byte
+	}{
+		{"[Content_Types].xml", []byte(xlsxContentTypes)},
+		{"_rels/.rels", []byte(xlsxRootRels)},
+		{"xl/workbook.xml", []byte(xlsxWorkbook)},
+		{"xl/_rels/workbook.xml.rels", []byte(xlsxWorkbookRels)},
+	}
+	for _, part := range parts {
+		if err := writeZipPart(zw, part.name, part.body); err != nil {
+			return err
+		}
+	}
+
+	var sheet bytes.Buffer
+	sheet.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n")
+	sheet.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	sheet.Write(x.rows.Bytes())
+	sheet.WriteString(`</sheetData></worksheet>`)
+	if err := writeZipPart(zw, "xl/worksheets/sheet1.xml", sheet.Bytes()); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+func writeZipPart(zw *zip.Writer, name string, body []byte) error {
+	part, err := zw.Create(name)
+	if err != nil {
+		return fmt.Errorf("failed to create workbook part %s: %w", name, err)
+	}
+	if _, err := part.Write(body); err != nil {
+		return fmt.Errorf("failed to write workbook part %s: %w", name, err)
+	}
+	return nil
+}
+
+// columnName converts a zero-based column index to its spreadsheet letters (0 -> A, 26 -> AA)
+func columnName(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
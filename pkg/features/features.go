@@ -0,0 +1,106 @@
+// Package features gates experimental processing paths behind flags that can be changed
+// while the pipeline runs.
+package features
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// Flag names a feature that can be switched on or off
+type Flag string
+
+const (
+	IdentityStitching Flag = "identity_stitching" // Merge anonymous visitors into users on identify events
+	PathFlow          Flag = "path_flow"          // Track page-to-page transitions of sessions
+	AlertWindows      Flag = "alert_windows"      // Per-minute buckets for per-path and relative alerts
+)
+
+// defaults are the states of flags that are not configured
+var defaults = map[Flag]bool{
+	IdentityStitching: true,
+	PathFlow:          true,
+	AlertWindows:      true,
+}
+
+// Known returns the names of every flag, sorted
+func Known() []Flag {
+	flags := make([]Flag, 0, len(defaults))
+	for flag := range defaults {
+		flags = append(flags, flag)
+	}
+	sort.Slice(flags, func(i, j int) bool { return flags[i] < flags[j] })
+	return flags
+}
+
+// Parse parses a comma-separated list of flags such as "path_flow=false,identity_stitching";
+// a flag without a value is enabled
+func Parse(spec string) (map[Flag]bool, error) {
+	states := make(map[Flag]bool)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, value, hasValue := strings.Cut(entry, "=")
+		flag := Flag(strings.TrimSpace(name))
+		if _, ok := defaults[flag]; !ok {
+			return nil, fmt.Errorf("unknown feature flag %q", flag)
+		}
+		enabled := true
+		if hasValue {
+			parsed, err := strconv.ParseBool(strings.TrimSpace(value))
+			if err != nil {
+				return nil, fmt.Errorf("invalid value for feature flag %q: %q", flag, value)
+			}
+			enabled = parsed
+		}
+		states[flag] = enabled
+	}
+	return states, nil
+}
+
+// Flags holds the current state of every flag. It is safe for concurrent use, and a nil
+// *Flags reports the defaults.
+type Flags struct {
+	states atomic.Pointer[map[Flag]bool]
+}
+
+// New returns flags in their default states
+func New() *Flags {
+	f := &Flags{}
+	f.Set(nil)
+	return f
+}
+
+// Enabled reports whether a flag is on
+func (f *Flags) Enabled(flag Flag) bool {
+	if f == nil {
+		return defaults[flag]
+	}
+	return (*f.states.Load())[flag]
+}
+
+// Set replaces the configured states; flags left out return to their defaults
+func (f *Flags) Set(configured map[Flag]bool) {
+	states := make(map[Flag]bool, len(defaults))
+	for flag, enabled := range defaults {
+		states[flag] = enabled
+	}
+	for flag, enabled := range configured {
+		states[flag] = enabled
+	}
+	f.states.Store(&states)
+}
+
+// All returns the state of every flag
+func (f *Flags) All() map[Flag]bool {
+	all := make(map[Flag]bool, len(defaults))
+	for _, flag := range Known() {
+		all[flag] = f.Enabled(flag)
+	}
+	return all
+}
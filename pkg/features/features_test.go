@@ -0,0 +1,38 @@
+package features
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	states, err := Parse("path_flow=false, identity_stitching")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if states[PathFlow] || !states[IdentityStitching] || len(states) != 2 {
+		t.Errorf("Unexpected states %v", states)
+	}
+
+	for _, spec := range []string{"unknown_flag", "path_flow=maybe"} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("Expected %q to be rejected", spec)
+		}
+	}
+}
+
+func TestSetRestoresDefaults(t *testing.T) {
+	flags := New()
+	flags.Set(map[Flag]bool{PathFlow: false})
+	if flags.Enabled(PathFlow) || !flags.Enabled(IdentityStitching) {
+		t.Errorf("Unexpected states %v", flags.All())
+	}
+
+	// Flags left out of a later configuration return to their defaults
+	flags.Set(map[Flag]bool{AlertWindows: false})
+	if !flags.Enabled(PathFlow) || flags.Enabled(AlertWindows) {
+		t.Errorf("Unexpected states %v", flags.All())
+	}
+
+	var unset *Flags
+	if !unset.Enabled(PathFlow) {
+		t.Error("Expected nil flags to report the defaults")
+	}
+}
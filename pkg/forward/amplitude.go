@@ -0,0 +1,111 @@
+package forward
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+// DefaultAmplitudeURL is Amplitude's Batch Event Upload endpoint
+const DefaultAmplitudeURL = "https://api2.amplitude.com/batch"
+
+// amplitudeMaxEvents is the most events Amplitude accepts per batch request
+const amplitudeMaxEvents = 1000
+
+// AmplitudeConfig selects an Amplitude project
+type AmplitudeConfig struct {
+	Endpoint string // Batch URL; empty uses DefaultAmplitudeURL
+	APIKey   string // API key of the project
+}
+
+// Amplitude sends events to an Amplitude project through its batch API, keeping their
+// event types as names. Events without a user, session or anonymous ID are left out.
+type Amplitude struct {
+	url    string
+	apiKey string
+}
+
+// NewAmplitude creates an Amplitude destination
+func NewAmplitude(config AmplitudeConfig) (*Amplitude, error) {
+	if config.APIKey == "" {
+		return nil, fmt.Errorf("an API key is required")
+	}
+	endpoint := config.Endpoint
+	if endpoint == "" {
+		endpoint = DefaultAmplitudeURL
+	}
+	return &Amplitude{url: endpoint, apiKey: config.APIKey}, nil
+}
+
+// Name identifies the destination in logs
+func (a *Amplitude) Name() string {
+	return "amplitude"
+}
+
+// amplitudeEvent is one event of an Amplitude batch
+type amplitudeEvent struct {
+	UserID          string                 `json:"user_id,omitempty"`
+	DeviceID        string                 `json:"device_id,omitempty"`
+	EventType       string                 `json:"event_type"`
+	Time            int64                  `json:"time,omitempty"` // Milliseconds since the epoch
+	InsertID        string                 `json:"insert_id,omitempty"`
+	IP              string                 `json:"ip,omitempty"`
+	EventProperties map[string]interface{} `json:"event_properties,omitempty"`
+	UserProperties  map[string]interface{} `json:"user_properties,omitempty"`
+	Revenue         *float64               `json:"revenue,omitempty"`
+	ProductID       string                 `json:"productId,omitempty"`
+}
+
+// Encode maps events to Amplitude's form, in requests of at most 1000 events
+func (a *Amplitude) Encode(events []models.AnalyticsEvent) ([]Request, error) {
+	converted := make([]amplitudeEvent, 0, len(events))
+	for i := range events {
+		event := &events[i]
+		if event.UserID == "" && anonymousID(event) == "" {
+			continue
+		}
+		converted = append(converted, a.event(event))
+	}
+
+	var requests []Request
+	for _, batch := range chunks(converted, amplitudeMaxEvents) {
+		body, err := json.Marshal(map[string]interface{}{"api_key": a.apiKey, "events": batch})
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, Request{URL: a.url, Body: body})
+	}
+	return requests, nil
+}
+
+// event maps an event to Amplitude's form. The event ID is the insert ID, so events
+// forwarded twice are deduplicated by Amplitude, and purchases carry their revenue.
+func (a *Amplitude) event(event *models.AnalyticsEvent) amplitudeEvent {
+	result := amplitudeEvent{
+		UserID:          event.UserID,
+		DeviceID:        anonymousID(event),
+		EventType:       string(event.Type),
+		InsertID:        event.ID,
+		IP:              event.IPAddress,
+		EventProperties: properties(event),
+	}
+	if !event.Timestamp.IsZero() {
+		result.Time = event.Timestamp.UnixMilli()
+	}
+
+	if models.IsIdentify(event) {
+		result.EventType = "$identify"
+		result.UserProperties = map[string]interface{}{"$set": traits(event)}
+		result.EventProperties = nil
+	}
+	if event.Type.IsCommerce() {
+		if order, err := models.OrderFromEvent(event, ""); err == nil {
+			addOrder(result.EventProperties, order)
+			if event.Type == models.Purchase {
+				result.Revenue = &order.Revenue
+			}
+		}
+	}
+	return result
+}
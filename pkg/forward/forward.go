@@ -0,0 +1,338 @@
+// Package forward sends events on to third-party analytics services such as Google
+// Analytics 4, Segment and Amplitude, so teams moving to this pipeline can keep their
+// existing tools fed while they migrate.
+package forward
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/bots"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/logging"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+// maxBackoff caps the wait between delivery attempts
+const maxBackoff = 30 * time.Second
+
+// closeTimeout bounds how long Close waits for queued batches to be delivered
+const closeTimeout = 30 * time.Second
+
+// Destination maps events to the HTTP API of one service
+type Destination interface {
+	// Name identifies the destination in logs
+	Name() string
+
+	// Encode returns the requests that deliver events, each within the service's batch
+	// limits. Events the service can't accept, e.g. without any user identifier, are
+	// left out.
+	Encode(events []models.AnalyticsEvent) ([]Request, error)
+}
+
+// Request is one HTTP POST to a destination
+type Request struct {
+	URL    string
+	Header http.Header
+	Body   []byte
+}
+
+// Config selects the events forwarded and how they are delivered
+type Config struct {
+	EventTypes     []models.EventType // Types forwarded; empty forwards every type except the pipeline's own
+	MaxAttempts    int                // Deliveries of a request before it is dropped
+	InitialBackoff time.Duration      // Wait before the first retry, doubling after each
+	Timeout        time.Duration      // Per-request timeout
+	QueueSize      int                // Batches waiting to be forwarded; more are dropped
+	MaxRetryTime   time.Duration      // Time spent delivering a batch, retries included
+}
+
+// DefaultConfig returns the delivery defaults, forwarding every event type
+func DefaultConfig() Config {
+	return Config{
+		MaxAttempts:    5,
+		InitialBackoff: time.Second,
+		Timeout:        10 * time.Second,
+		QueueSize:      100,
+		MaxRetryTime:   2 * time.Minute,
+	}
+}
+
+// Forwarder delivers batches of events to a destination. It implements sinks.Sink, so
+// it receives the consumer's processed events like the warehouse sinks, but queues them
+// for a goroutine of its own: a slow or failing service never holds up consumption or
+// the other sinks, and batches are dropped while the queue is full.
+type Forwarder struct {
+	destination Destination
+	config      Config
+	eventTypes  map[models.EventType]bool // nil forwards every type
+	client      *http.Client
+
+	queue   chan []models.AnalyticsEvent
+	dropped atomic.Int64 // Events dropped because the queue was full
+	closed  bool         // Set by Close, guarded by mu
+	mu      sync.Mutex
+	ctx     context.Context // Cancels deliveries still running when Close times out
+	cancel  context.CancelFunc
+	done    chan struct{} // Closed once the queue is drained
+}
+
+// New creates a forwarder to destination
+func New(destination Destination, config Config) *Forwarder {
+	defaults := DefaultConfig()
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = defaults.MaxAttempts
+	}
+	if config.InitialBackoff <= 0 {
+		config.InitialBackoff = defaults.InitialBackoff
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaults.Timeout
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = defaults.QueueSize
+	}
+	if config.MaxRetryTime <= 0 {
+		config.MaxRetryTime = defaults.MaxRetryTime
+	}
+
+	f := &Forwarder{
+		destination: destination,
+		config:      config,
+		client:      &http.Client{Timeout: config.Timeout},
+		queue:       make(chan []models.AnalyticsEvent, config.QueueSize),
+		done:        make(chan struct{}),
+	}
+	f.ctx, f.cancel = context.WithCancel(context.Background())
+	if len(config.EventTypes) > 0 {
+		f.eventTypes = make(map[models.EventType]bool, len(config.EventTypes))
+		for _, eventType := range config.EventTypes {
+			f.eventTypes[eventType] = true
+		}
+	}
+	go f.run()
+	return f
+}
+
+// Name identifies the forwarder in logs
+func (f *Forwarder) Name() string {
+	return f.destination.Name()
+}
+
+// WriteEvents queues the selected events of a batch for delivery. When the queue is full
+// the batch is dropped and logged rather than waiting for the service.
+func (f *Forwarder) WriteEvents(ctx context.Context, events []models.AnalyticsEvent) error {
+	selected := make([]models.AnalyticsEvent, 0, len(events))
+	for i := range events {
+		if f.forwards(&events[i]) {
+			selected = append(selected, events[i])
+		}
+	}
+	if len(selected) == 0 {
+		return nil
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return fmt.Errorf("forwarder to %s is closed", f.Name())
+	}
+	select {
+	case f.queue <- selected:
+	default:
+		dropped := f.dropped.Add(int64(len(selected)))
+		logging.Warn("Forwarding queue is full, dropping events", "destination", f.Name(), "events", len(selected), "dropped_total", dropped)
+	}
+	return nil
+}
+
+// run delivers queued batches until the queue is closed
+func (f *Forwarder) run() {
+	defer close(f.done)
+	for events := range f.queue {
+		ctx, cancel := context.WithTimeout(f.ctx, f.config.MaxRetryTime)
+		if err := f.deliver(ctx, events); err != nil {
+			logging.Error("Failed to forward events", "destination", f.Name(), "events", len(events), "error", err)
+		}
+		cancel()
+	}
+}
+
+// deliver sends a batch. Every request is attempted even when an earlier one fails; the
+// first failure is returned.
+func (f *Forwarder) deliver(ctx context.Context, selected []models.AnalyticsEvent) error {
+	requests, err := f.destination.Encode(selected)
+	if err != nil {
+		return fmt.Errorf("failed to encode events: %w", err)
+	}
+	var firstErr error
+	for _, request := range requests {
+		if err := f.send(ctx, request); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// forwards reports whether an event is sent on. Events the pipeline creates for itself
+// and detected bots are never forwarded, since the services would count them as visitors.
+func (f *Forwarder) forwards(event *models.AnalyticsEvent) bool {
+	switch event.Type {
+	case models.MetaEvent, models.SessionReplay, models.UserErasure:
+		return false
+	}
+	if _, bot := bots.Flagged(event); bot {
+		return false
+	}
+	return f.eventTypes == nil || f.eventTypes[event.Type]
+}
+
+// WriteSnapshot does nothing; only events are forwarded
+func (f *Forwarder) WriteSnapshot(ctx context.Context, snapshot *models.MetricsSnapshot) error {
+	return nil
+}
+
+// Flush does nothing, as queued events are forwarded as soon as possible
+func (f *Forwarder) Flush(ctx context.Context) error {
+	return nil
+}
+
+// Close delivers the queued batches, giving up on those left after closeTimeout, and
+// releases idle connections
+func (f *Forwarder) Close() error {
+	f.mu.Lock()
+	if !f.closed {
+		f.closed = true
+		close(f.queue)
+	}
+	f.mu.Unlock()
+
+	select {
+	case <-f.done:
+	case <-time.After(closeTimeout):
+		f.cancel()
+		<-f.done
+	}
+	f.cancel()
+	f.client.CloseIdleConnections()
+	return nil
+}
+
+// send posts a request, retrying network errors, 429 and 5xx responses with exponential
+// backoff, or after the Retry-After the service asks for
+func (f *Forwarder) send(ctx context.Context, request Request) error {
+	backoff := f.config.InitialBackoff
+	var err error
+	for attempt := 1; attempt <= f.config.MaxAttempts; attempt++ {
+		var retry bool
+		var retryAfter time.Duration
+		if retry, retryAfter, err = f.attempt(ctx, request); err == nil || !retry {
+			return err
+		}
+		if attempt == f.config.MaxAttempts {
+			break
+		}
+
+		wait := backoff
+		if retryAfter > 0 {
+			wait = min(retryAfter, maxBackoff)
+		}
+		logging.Warn("Forwarding failed, retrying", "destination", f.Name(), "attempt", attempt, "backoff", wait, "error", err)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff = min(2*backoff, maxBackoff)
+	}
+	return fmt.Errorf("giving up after %d attempts: %w", f.config.MaxAttempts, err)
+}
+
+// attempt makes one delivery, reporting whether a failure is worth retrying and how long
+// the service asked to wait
+func (f *Forwarder) attempt(ctx context.Context, request Request) (retry bool, retryAfter time.Duration, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, request.URL, bytes.NewReader(request.Body))
+	if err != nil {
+		return false, 0, err
+	}
+	for name, values := range request.Header {
+		req.Header[name] = values
+	}
+	if req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, 0, err
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, 0, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		seconds, _ := strconv.Atoi(resp.Header.Get("Retry-After"))
+		return true, time.Duration(seconds) * time.Second, fmt.Errorf("%s responded %s", f.Name(), resp.Status)
+	default:
+		return false, 0, fmt.Errorf("%s responded %s", f.Name(), resp.Status)
+	}
+}
+
+// anonymousID returns the identifier of the event's device or browser: the anonymous ID
+// of an identify event, otherwise the session ID
+func anonymousID(event *models.AnalyticsEvent) string {
+	if id, ok := event.Metadata[models.MetadataAnonymousID].(string); ok && id != "" {
+		return id
+	}
+	return event.SessionID
+}
+
+// properties returns the event's metadata with its page and session details
+func properties(event *models.AnalyticsEvent) map[string]interface{} {
+	result := make(map[string]interface{}, len(event.Metadata)+4)
+	for key, value := range event.Metadata {
+		result[key] = value
+	}
+	for key, value := range map[string]string{
+		"url":        event.URL,
+		"path":       event.Path,
+		"referrer":   event.Referrer,
+		"session_id": event.SessionID,
+	} {
+		if _, ok := result[key]; !ok && value != "" {
+			result[key] = value
+		}
+	}
+	return result
+}
+
+// traits returns the user attributes an identify event sets: its metadata other than the
+// identify fields
+func traits(event *models.AnalyticsEvent) map[string]interface{} {
+	result := make(map[string]interface{}, len(event.Metadata))
+	for key, value := range event.Metadata {
+		if key != models.MetadataAction && key != models.MetadataAnonymousID {
+			result[key] = value
+		}
+	}
+	return result
+}
+
+// chunks splits events into batches of at most size
+func chunks[T any](items []T, size int) [][]T {
+	var result [][]T
+	for len(items) > size {
+		result = append(result, items[:size])
+		items = items[size:]
+	}
+	if len(items) > 0 {
+		result = append(result, items)
+	}
+	return result
+}
@@ -0,0 +1,221 @@
+package forward
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/bots"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+// recorder is a destination server that fails the first failures requests with 503
+type recorder struct {
+	failures int
+	mu       sync.Mutex
+	bodies   []map[string]interface{}
+}
+
+func (rec *recorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if rec.failures > 0 {
+		rec.failures--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	data, _ := io.ReadAll(r.Body)
+	var body map[string]interface{}
+	json.Unmarshal(data, &body)
+	rec.bodies = append(rec.bodies, body)
+}
+
+func testEvents() []models.AnalyticsEvent {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	return []models.AnalyticsEvent{
+		{ID: "e1", Type: models.PageView, Timestamp: now, UserID: "user-1", SessionID: "s1", URL: "https://example.com/pricing", Path: "/pricing",
+			Metadata: map[string]interface{}{"page_title": "Pricing"}},
+		{ID: "e2", Type: models.Purchase, Timestamp: now, UserID: "user-1", SessionID: "s1",
+			Metadata: map[string]interface{}{"order_id": "o-1", "revenue": 42.5, "currency": "EUR", "items": []interface{}{
+				map[string]interface{}{"product_id": "p1", "price": 42.5},
+			}}},
+		{ID: "e3", Type: models.UserEvent, Timestamp: now, UserID: "user-2",
+			Metadata: map[string]interface{}{"action": "identify", "anonymous_id": "anon-2", "plan": "pro"}},
+		{ID: "e4", Type: models.Click, Timestamp: now}, // No identifier
+		{ID: "e5", Type: models.MetaEvent, Timestamp: now, SessionID: "s1"},
+	}
+}
+
+func TestForwarderRetriesAndFilters(t *testing.T) {
+	rec := &recorder{failures: 2}
+	server := httptest.NewServer(rec)
+	defer server.Close()
+
+	destination, _ := NewSegment(SegmentConfig{Endpoint: server.URL, WriteKey: "key"})
+	forwarder := New(destination, Config{InitialBackoff: time.Millisecond, EventTypes: []models.EventType{models.PageView, models.Click}})
+
+	events := testEvents()
+	bot := models.AnalyticsEvent{ID: "e6", Type: models.PageView, SessionID: "s9"}
+	bots.Flag(&bot, bots.Result{Bot: true, Name: "Googlebot", Reason: "user_agent"})
+	events = append(events, bot)
+
+	if err := forwarder.WriteEvents(context.Background(), events); err != nil {
+		t.Fatalf("WriteEvents failed: %v", err)
+	}
+	forwarder.Close() // Waits for the queued batch
+	if len(rec.bodies) != 1 {
+		t.Fatalf("expected one delivered request after retries, got %d", len(rec.bodies))
+	}
+	batch := rec.bodies[0]["batch"].([]interface{})
+	if len(batch) != 1 || batch[0].(map[string]interface{})["messageId"] != "e1" {
+		t.Errorf("expected only the page view to be forwarded, got %v", batch)
+	}
+
+	rec.failures = 10
+	forwarder = New(destination, Config{InitialBackoff: time.Millisecond, MaxAttempts: 2})
+	defer forwarder.Close()
+	if err := forwarder.deliver(context.Background(), events[:1]); err == nil || !strings.Contains(err.Error(), "giving up after 2 attempts") {
+		t.Errorf("expected delivery to give up, got %v", err)
+	}
+}
+
+func TestForwarderDropsWhenQueueIsFull(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+
+	destination, _ := NewSegment(SegmentConfig{Endpoint: server.URL, WriteKey: "key"})
+	forwarder := New(destination, Config{QueueSize: 1})
+	events := testEvents()[:1]
+
+	// One batch is being delivered and one waits in the queue; the rest are dropped
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		if err := forwarder.WriteEvents(context.Background(), events); err != nil {
+			t.Fatalf("WriteEvents failed: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected writes not to wait for the service, took %v", elapsed)
+	}
+	if dropped := forwarder.dropped.Load(); dropped != 3 {
+		t.Errorf("expected 3 events dropped, got %d", dropped)
+	}
+	close(release)
+	forwarder.Close()
+}
+
+func TestForwarderCapsRetryTime(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	destination, _ := NewSegment(SegmentConfig{Endpoint: server.URL, WriteKey: "key"})
+	forwarder := New(destination, Config{InitialBackoff: time.Second, MaxAttempts: 10, MaxRetryTime: 50 * time.Millisecond})
+	start := time.Now()
+	forwarder.WriteEvents(context.Background(), testEvents()[:1])
+	forwarder.Close()
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected retries cut off after MaxRetryTime, took %v", elapsed)
+	}
+}
+
+func TestSegmentEncoding(t *testing.T) {
+	destination, _ := NewSegment(SegmentConfig{WriteKey: "key"})
+	requests, err := destination.Encode(testEvents()[:4])
+	if err != nil || len(requests) != 1 {
+		t.Fatalf("expected one request, got %d, %v", len(requests), err)
+	}
+	if auth := requests[0].Header.Get("Authorization"); auth != "Basic a2V5Og==" {
+		t.Errorf("unexpected authorization %q", auth)
+	}
+
+	var body struct {
+		Batch []segmentMessage `json:"batch"`
+	}
+	json.Unmarshal(requests[0].Body, &body)
+	if len(body.Batch) != 3 {
+		t.Fatalf("expected events without identifiers to be left out, got %d messages", len(body.Batch))
+	}
+	page, order, identify := body.Batch[0], body.Batch[1], body.Batch[2]
+	if page.Type != "page" || page.Name != "Pricing" || page.AnonymousID != "s1" || page.Context.Page["path"] != "/pricing" {
+		t.Errorf("unexpected page call %+v", page)
+	}
+	if order.Type != "track" || order.Event != "Order Completed" || order.Properties["order_id"] != "o-1" || order.Properties["revenue"] != 42.5 {
+		t.Errorf("unexpected order call %+v", order)
+	}
+	if identify.Type != "identify" || identify.AnonymousID != "anon-2" || identify.Traits["plan"] != "pro" || identify.Traits["action"] != nil {
+		t.Errorf("unexpected identify call %+v", identify)
+	}
+}
+
+func TestGA4Encoding(t *testing.T) {
+	if _, err := NewGA4(GA4Config{MeasurementID: "G-1"}); err == nil {
+		t.Error("expected an API secret to be required")
+	}
+	destination, _ := NewGA4(GA4Config{MeasurementID: "G-1", APISecret: "secret"})
+
+	events := testEvents()[:2]
+	for i := 0; i < 30; i++ {
+		events = append(events, models.AnalyticsEvent{ID: fmt.Sprint(i), Type: "video.play-started", SessionID: "s2"})
+	}
+	requests, err := destination.Encode(events)
+	if err != nil || len(requests) != 3 {
+		t.Fatalf("expected requests for s1 and two for s2, got %d, %v", len(requests), err)
+	}
+	if !strings.Contains(requests[0].URL, "measurement_id=G-1") || !strings.Contains(requests[0].URL, "api_secret=secret") {
+		t.Errorf("unexpected URL %s", requests[0].URL)
+	}
+
+	var payload ga4Payload
+	json.Unmarshal(requests[0].Body, &payload)
+	if payload.ClientID != "s1" || payload.UserID != "user-1" || len(payload.Events) != 2 {
+		t.Fatalf("unexpected payload %+v", payload)
+	}
+	if page := payload.Events[0]; page.Name != "page_view" || page.Params["page_location"] != "https://example.com/pricing" || page.TimestampMicros == 0 {
+		t.Errorf("unexpected page view %+v", page)
+	}
+	if purchase := payload.Events[1]; purchase.Name != "purchase" || purchase.Params["transaction_id"] != "o-1" || purchase.Params["currency"] != "EUR" {
+		t.Errorf("unexpected purchase %+v", purchase)
+	}
+
+	json.Unmarshal(requests[1].Body, &payload)
+	if len(payload.Events) != ga4MaxEvents || payload.Events[0].Name != "video_play_started" {
+		t.Errorf("expected a full batch of video_play_started events, got %d %q", len(payload.Events), payload.Events[0].Name)
+	}
+}
+
+func TestAmplitudeEncoding(t *testing.T) {
+	destination, _ := NewAmplitude(AmplitudeConfig{APIKey: "key"})
+	requests, err := destination.Encode(testEvents()[:4])
+	if err != nil || len(requests) != 1 {
+		t.Fatalf("expected one request, got %d, %v", len(requests), err)
+	}
+
+	var body struct {
+		APIKey string           `json:"api_key"`
+		Events []amplitudeEvent `json:"events"`
+	}
+	json.Unmarshal(requests[0].Body, &body)
+	if body.APIKey != "key" || len(body.Events) != 3 {
+		t.Fatalf("unexpected body %+v", body)
+	}
+	purchase, identify := body.Events[1], body.Events[2]
+	if purchase.InsertID != "e2" || purchase.Revenue == nil || *purchase.Revenue != 42.5 || purchase.DeviceID != "s1" {
+		t.Errorf("unexpected purchase %+v", purchase)
+	}
+	if identify.EventType != "$identify" || identify.UserProperties["$set"].(map[string]interface{})["plan"] != "pro" {
+		t.Errorf("unexpected identify %+v", identify)
+	}
+}
@@ -0,0 +1,223 @@
+package forward
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+// DefaultGA4URL is the Measurement Protocol collect endpoint
+const DefaultGA4URL = "https://www.google-analytics.com/mp/collect"
+
+// GA4 Measurement Protocol limits
+const (
+	ga4MaxEvents   = 25  // Events per request
+	ga4MaxParams   = 25  // Parameters per event
+	ga4MaxNameLen  = 40  // Characters of event and parameter names
+	ga4MaxValueLen = 100 // Characters of string parameter values
+)
+
+// ga4NameInvalid matches characters GA4 doesn't allow in event and parameter names
+var ga4NameInvalid = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// ga4EventNames maps built-in event types to GA4's recommended event names
+var ga4EventNames = map[models.EventType]string{
+	models.AddToCart: "add_to_cart",
+	models.Checkout:  "begin_checkout",
+	models.Purchase:  "purchase",
+	models.Error:     "exception",
+}
+
+// GA4Config selects a Google Analytics 4 property
+type GA4Config struct {
+	Endpoint      string // Measurement Protocol collect URL; empty uses DefaultGA4URL
+	MeasurementID string // G-XXXXXXX
+	APISecret     string // Measurement Protocol API secret of the data stream
+}
+
+// GA4 sends events to Google Analytics 4 through the Measurement Protocol. Events are
+// grouped by client, since each request describes a single client, and events without a
+// session or anonymous ID are left out.
+type GA4 struct {
+	url string
+}
+
+// NewGA4 creates a GA4 destination
+func NewGA4(config GA4Config) (*GA4, error) {
+	if config.MeasurementID == "" || config.APISecret == "" {
+		return nil, fmt.Errorf("a measurement ID and API secret are required")
+	}
+	endpoint := config.Endpoint
+	if endpoint == "" {
+		endpoint = DefaultGA4URL
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid endpoint: %w", err)
+	}
+	query := u.Query()
+	query.Set("measurement_id", config.MeasurementID)
+	query.Set("api_secret", config.APISecret)
+	u.RawQuery = query.Encode()
+	return &GA4{url: u.String()}, nil
+}
+
+// Name identifies the destination in logs
+func (g *GA4) Name() string {
+	return "ga4"
+}
+
+// ga4Payload is the body of a Measurement Protocol request
+type ga4Payload struct {
+	ClientID string     `json:"client_id"`
+	UserID   string     `json:"user_id,omitempty"`
+	Events   []ga4Event `json:"events"`
+}
+
+type ga4Event struct {
+	Name            string                 `json:"name"`
+	TimestampMicros int64                  `json:"timestamp_micros,omitempty"`
+	Params          map[string]interface{} `json:"params"`
+}
+
+// Encode groups events by client and user, in order of their first event, into
+// requests of at most 25 events
+func (g *GA4) Encode(events []models.AnalyticsEvent) ([]Request, error) {
+	type client struct{ clientID, userID string }
+	var order []client
+	grouped := make(map[client][]ga4Event)
+	for i := range events {
+		event := &events[i]
+		key := client{anonymousID(event), event.UserID}
+		if key.clientID == "" {
+			continue
+		}
+		if _, ok := grouped[key]; !ok {
+			order = append(order, key)
+		}
+		grouped[key] = append(grouped[key], g.event(event))
+	}
+
+	var requests []Request
+	for _, key := range order {
+		for _, batch := range chunks(grouped[key], ga4MaxEvents) {
+			body, err := json.Marshal(ga4Payload{ClientID: key.clientID, UserID: key.userID, Events: batch})
+			if err != nil {
+				return nil, err
+			}
+			requests = append(requests, Request{URL: g.url, Body: body})
+		}
+	}
+	return requests, nil
+}
+
+// event maps an event to GA4's form: page details as page_location and page_referrer,
+// orders as GA4 e-commerce parameters and other scalar metadata as parameters
+func (g *GA4) event(event *models.AnalyticsEvent) ga4Event {
+	params := make(map[string]interface{})
+	if event.URL != "" {
+		params["page_location"] = event.URL
+	}
+	if event.Referrer != "" {
+		params["page_referrer"] = event.Referrer
+	}
+	if event.SessionID != "" {
+		params["session_id"] = event.SessionID
+	}
+
+	if event.Type.IsCommerce() {
+		if order, err := models.OrderFromEvent(event, ""); err == nil {
+			if order.OrderID != "" {
+				params["transaction_id"] = order.OrderID
+			}
+			params["value"] = order.Revenue
+			if order.Currency != "" {
+				params["currency"] = order.Currency
+			}
+			items := make([]map[string]interface{}, len(order.Items))
+			for i, item := range order.Items {
+				items[i] = map[string]interface{}{
+					"item_id":       item.ProductID,
+					"item_name":     item.Name,
+					"item_category": item.Category,
+					"price":         item.Price,
+					"quantity":      item.Quantity,
+				}
+			}
+			params["items"] = items
+		}
+	}
+	if event.Type == models.Error {
+		if details, err := models.ErrorFromEvent(event); err == nil {
+			params["description"] = truncate(details.Message, ga4MaxValueLen)
+			params["fatal"] = details.Severity == models.SeverityFatal
+		}
+	}
+
+	// Metadata keys are taken in sorted order, so the same ones are kept past the limit
+	keys := make([]string, 0, len(event.Metadata))
+	for key := range event.Metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if len(params) >= ga4MaxParams {
+			break
+		}
+		name := ga4Name(key)
+		if _, ok := params[name]; ok {
+			continue
+		}
+		switch v := event.Metadata[key].(type) {
+		case string:
+			params[name] = truncate(v, ga4MaxValueLen)
+		case float64, bool:
+			params[name] = v
+		}
+	}
+
+	result := ga4Event{Name: ga4EventName(event), Params: params}
+	if !event.Timestamp.IsZero() {
+		result.TimestampMicros = event.Timestamp.UnixMicro()
+	}
+	return result
+}
+
+// ga4EventName returns the GA4 name of an event's type
+func ga4EventName(event *models.AnalyticsEvent) string {
+	if models.IsIdentify(event) {
+		return "login"
+	}
+	if name, ok := ga4EventNames[event.Type]; ok {
+		return name
+	}
+	return ga4Name(string(event.Type))
+}
+
+// ga4Name makes a name valid for GA4: letters, digits and underscores, starting with a
+// letter, of at most 40 characters
+func ga4Name(name string) string {
+	name = ga4NameInvalid.ReplaceAllString(name, "_")
+	name = strings.TrimLeft(name, "_0123456789")
+	if name == "" {
+		return "event"
+	}
+	return truncate(name, ga4MaxNameLen)
+}
+
+// truncate shortens s to at most n bytes, without splitting a character
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
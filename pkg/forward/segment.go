@@ -0,0 +1,166 @@
+package forward
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+// DefaultSegmentURL is Segment's batch endpoint
+const DefaultSegmentURL = "https://api.segment.io/v1/batch"
+
+// segmentMaxEvents keeps batches well below Segment's 500 KB request limit
+const segmentMaxEvents = 100
+
+// segmentEventNames maps e-commerce event types to Segment's e-commerce spec
+var segmentEventNames = map[models.EventType]string{
+	models.AddToCart: "Product Added",
+	models.Checkout:  "Checkout Started",
+	models.Purchase:  "Order Completed",
+}
+
+// SegmentConfig selects a Segment source
+type SegmentConfig struct {
+	Endpoint string // Batch URL; empty uses DefaultSegmentURL
+	WriteKey string // Write key of the source
+}
+
+// Segment sends events to a Segment source through its batch API: page views as page
+// calls, identify events as identify calls and everything else as track calls. Events
+// without a user, session or anonymous ID are left out.
+type Segment struct {
+	url  string
+	auth string
+}
+
+// NewSegment creates a Segment destination
+func NewSegment(config SegmentConfig) (*Segment, error) {
+	if config.WriteKey == "" {
+		return nil, fmt.Errorf("a write key is required")
+	}
+	endpoint := config.Endpoint
+	if endpoint == "" {
+		endpoint = DefaultSegmentURL
+	}
+	return &Segment{
+		url:  endpoint,
+		auth: "Basic " + base64.StdEncoding.EncodeToString([]byte(config.WriteKey+":")),
+	}, nil
+}
+
+// Name identifies the destination in logs
+func (s *Segment) Name() string {
+	return "segment"
+}
+
+// segmentMessage is one call of a Segment batch
+type segmentMessage struct {
+	Type        string                 `json:"type"`
+	MessageID   string                 `json:"messageId,omitempty"`
+	UserID      string                 `json:"userId,omitempty"`
+	AnonymousID string                 `json:"anonymousId,omitempty"`
+	Event       string                 `json:"event,omitempty"`
+	Name        string                 `json:"name,omitempty"`
+	Properties  map[string]interface{} `json:"properties,omitempty"`
+	Traits      map[string]interface{} `json:"traits,omitempty"`
+	Context     segmentContext         `json:"context"`
+	Timestamp   string                 `json:"timestamp,omitempty"`
+}
+
+type segmentContext struct {
+	IP        string                 `json:"ip,omitempty"`
+	UserAgent string                 `json:"userAgent,omitempty"`
+	Page      map[string]interface{} `json:"page,omitempty"`
+}
+
+// Encode maps events to Segment calls, in requests of at most 100 calls
+func (s *Segment) Encode(events []models.AnalyticsEvent) ([]Request, error) {
+	messages := make([]segmentMessage, 0, len(events))
+	for i := range events {
+		event := &events[i]
+		if event.UserID == "" && anonymousID(event) == "" {
+			continue
+		}
+		messages = append(messages, s.message(event))
+	}
+
+	header := http.Header{"Authorization": {s.auth}}
+	var requests []Request
+	for _, batch := range chunks(messages, segmentMaxEvents) {
+		body, err := json.Marshal(map[string]interface{}{"batch": batch})
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, Request{URL: s.url, Header: header, Body: body})
+	}
+	return requests, nil
+}
+
+// message maps an event to a Segment call. The event ID is the message ID, so events
+// forwarded twice are deduplicated by Segment.
+func (s *Segment) message(event *models.AnalyticsEvent) segmentMessage {
+	message := segmentMessage{
+		MessageID:   event.ID,
+		UserID:      event.UserID,
+		AnonymousID: anonymousID(event),
+		Context:     segmentContext{IP: event.IPAddress, UserAgent: event.UserAgent},
+	}
+	if !event.Timestamp.IsZero() {
+		message.Timestamp = event.Timestamp.UTC().Format(time.RFC3339Nano)
+	}
+	if event.URL != "" {
+		message.Context.Page = map[string]interface{}{"url": event.URL, "path": event.Path, "referrer": event.Referrer}
+	}
+
+	switch {
+	case event.Type == models.PageView:
+		message.Type = "page"
+		if title, ok := event.Metadata["page_title"].(string); ok {
+			message.Name = title
+		}
+		message.Properties = properties(event)
+	case models.IsIdentify(event):
+		message.Type = "identify"
+		message.Traits = traits(event)
+	default:
+		message.Type = "track"
+		message.Event = string(event.Type)
+		if name, ok := segmentEventNames[event.Type]; ok {
+			message.Event = name
+		}
+		message.Properties = properties(event)
+		if event.Type.IsCommerce() {
+			if order, err := models.OrderFromEvent(event, ""); err == nil {
+				addOrder(message.Properties, order)
+			}
+		}
+	}
+	return message
+}
+
+// addOrder sets the order properties of Segment's e-commerce spec, which Amplitude's
+// e-commerce charts also read
+func addOrder(properties map[string]interface{}, order models.Order) {
+	if order.OrderID != "" {
+		properties["order_id"] = order.OrderID
+	}
+	properties["revenue"] = order.Revenue
+	if order.Currency != "" {
+		properties["currency"] = order.Currency
+	}
+	products := make([]map[string]interface{}, len(order.Items))
+	for i, item := range order.Items {
+		products[i] = map[string]interface{}{
+			"product_id": item.ProductID,
+			"name":       item.Name,
+			"category":   item.Category,
+			"price":      item.Price,
+			"quantity":   item.Quantity,
+		}
+	}
+	properties["products"] = products
+}
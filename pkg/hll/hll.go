@@ -0,0 +1,179 @@
+package hll
+
+import (
+	"hash/fnv"
+	"math"
+	"math/bits"
+)
+
+// Default settings: counts are exact up to 1000 distinct items, after which a sketch
+// of 2^14 registers (16 KiB, about 0.8% standard error) takes over
+const (
+	DefaultThreshold = 1000
+	DefaultPrecision = 14
+
+	minPrecision = 4
+	maxPrecision = 16
+)
+
+// Config selects when counters switch from exact sets to sketches, and the sketch size
+type Config struct {
+	Threshold int   // Distinct items counted exactly; 0 uses DefaultThreshold, negative always estimates
+	Precision uint8 // Sketches have 2^Precision one-byte registers, between 4 and 16
+}
+
+// DefaultConfig returns the settings used when none are configured
+func DefaultConfig() Config {
+	return Config{Threshold: DefaultThreshold, Precision: DefaultPrecision}
+}
+
+// withDefaults replaces unset or invalid values with the defaults
+func (c Config) withDefaults() Config {
+	if c.Threshold == 0 {
+		c.Threshold = DefaultThreshold
+	}
+	if c.Precision == 0 {
+		c.Precision = DefaultPrecision
+	}
+	c.Precision = min(max(c.Precision, minPrecision), maxPrecision)
+	return c
+}
+
+// Counter counts distinct strings. It keeps the exact set until it holds more than the
+// configured threshold, then converts to a HyperLogLog sketch whose memory is fixed
+// regardless of how many items are added. Add must not be called concurrently with
+// other methods.
+type Counter struct {
+	config    Config
+	exact     map[string]struct{}
+	registers []uint8 // Non-nil once the counter is estimating
+
+	// Running sum of 2^-register and number of zero registers, so Count does not scan
+	sum   float64
+	zeros int
+}
+
+// New creates an empty counter
+func New(config Config) *Counter {
+	config = config.withDefaults()
+	c := &Counter{config: config}
+	if config.Threshold < 0 {
+		c.initRegisters()
+	} else {
+		c.exact = make(map[string]struct{})
+	}
+	return c
+}
+
+// Add records an item
+func (c *Counter) Add(item string) {
+	if c.registers == nil {
+		c.exact[item] = struct{}{}
+		if len(c.exact) > c.config.Threshold {
+			c.convert()
+		}
+		return
+	}
+	c.addHash(hash(item))
+}
+
+// Remove deletes an item from an exact counter. Sketches cannot forget items, so it
+// reports false once the counter is estimating.
+func (c *Counter) Remove(item string) bool {
+	if c.registers != nil {
+		return false
+	}
+	delete(c.exact, item)
+	return true
+}
+
+// Contains reports whether an exact counter holds an item. It reports false once the
+// counter is estimating.
+func (c *Counter) Contains(item string) bool {
+	if c.registers != nil {
+		return false
+	}
+	_, ok := c.exact[item]
+	return ok
+}
+
+// Count returns the number of distinct items added, estimated once the counter holds a sketch
+func (c *Counter) Count() int64 {
+	if c.registers == nil {
+		return int64(len(c.exact))
+	}
+
+	m := float64(len(c.registers))
+	estimate := alpha(len(c.registers)) * m * m / c.sum
+
+	// Linear counting is more accurate while many registers are still empty
+	if estimate <= 2.5*m && c.zeros > 0 {
+		estimate = m * math.Log(m/float64(c.zeros))
+	}
+	return int64(math.Round(estimate))
+}
+
+// Exact reports whether Count is exact rather than estimated
+func (c *Counter) Exact() bool {
+	return c.registers == nil
+}
+
+// convert replaces the exact set with a sketch of its items
+func (c *Counter) convert() {
+	c.initRegisters()
+	for item := range c.exact {
+		c.addHash(hash(item))
+	}
+	c.exact = nil
+}
+
+// initRegisters allocates an empty sketch
+func (c *Counter) initRegisters() {
+	c.registers = make([]uint8, 1<<c.config.Precision)
+	c.sum = float64(len(c.registers))
+	c.zeros = len(c.registers)
+}
+
+// addHash updates the register selected by the hash's top bits with the position of
+// the first set bit in the rest
+func (c *Counter) addHash(h uint64) {
+	p := c.config.Precision
+	index := h >> (64 - p)
+	rank := uint8(bits.LeadingZeros64(h<<p|1<<(p-1))) + 1
+	if old := c.registers[index]; rank > old {
+		c.registers[index] = rank
+		c.sum += math.Ldexp(1, -int(rank)) - math.Ldexp(1, -int(old))
+		if old == 0 {
+			c.zeros--
+		}
+	}
+}
+
+// hash returns a well-mixed 64-bit hash of the item
+func hash(item string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(item))
+	// FNV's high bits are poorly distributed for short keys, so finish with the
+	// SplitMix64 mixer
+	x := h.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// alpha is the HyperLogLog bias correction constant for m registers
+func alpha(m int) float64 {
+	switch m {
+	case 16:
+		return 0.673
+	case 32:
+		return 0.697
+	case 64:
+		return 0.709
+	default:
+		return 0.7213 / (1 + 1.079/float64(m))
+	}
+}
@@ -0,0 +1,50 @@
+package hll
+
+import (
+	"math"
+	"strconv"
+	"testing"
+)
+
+func TestCounterExactBelowThreshold(t *testing.T) {
+	c := New(Config{Threshold: 100})
+	for i := 0; i < 250; i++ {
+		c.Add(strconv.Itoa(i % 100)) // Repeats do not count
+	}
+	if !c.Exact() || c.Count() != 100 {
+		t.Errorf("Expected an exact count of 100, got %d (exact=%v)", c.Count(), c.Exact())
+	}
+
+	c.Add("one more")
+	if c.Exact() {
+		t.Error("Expected the counter to switch to a sketch above the threshold")
+	}
+}
+
+func TestCounterEstimate(t *testing.T) {
+	for _, n := range []int{500, 20000, 200000} {
+		c := New(Config{Threshold: -1})
+		for i := 0; i < n; i++ {
+			c.Add("user-" + strconv.Itoa(i))
+			c.Add("user-" + strconv.Itoa(i/2)) // Duplicates do not count
+		}
+		// Standard error at precision 14 is about 0.8%; allow four of them
+		if err := math.Abs(float64(c.Count()-int64(n))) / float64(n); err > 0.033 {
+			t.Errorf("n=%d: estimate %d is off by %.1f%%", n, c.Count(), err*100)
+		}
+	}
+}
+
+func TestCounterContains(t *testing.T) {
+	c := New(Config{Threshold: 2})
+	c.Add("a")
+	if !c.Contains("a") || c.Contains("b") {
+		t.Error("Expected an exact counter to report its items")
+	}
+
+	c.Add("b")
+	c.Add("c")
+	if c.Contains("a") {
+		t.Error("Expected an estimating counter to report no items")
+	}
+}
@@ -0,0 +1,76 @@
+// Package idset remembers recently seen IDs, such as event IDs, in memory: a bounded
+// LRU whose entries expire after a fixed time. It has no dependencies, so packages that
+// only need to spot repeats locally don't pull in a shared store's client.
+package idset
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// entry is an ID in the LRU list
+type entry struct {
+	id      string
+	expires time.Time
+}
+
+// Set is an in-memory LRU of IDs with a TTL. It is safe for concurrent use.
+type Set struct {
+	ttl      time.Duration
+	capacity int
+
+	order   *list.List // Most recently seen at the front
+	entries map[string]*list.Element
+	mu      sync.Mutex
+}
+
+// New creates a set holding at most capacity IDs for ttl each
+func New(capacity int, ttl time.Duration) *Set {
+	return &Set{
+		ttl:      ttl,
+		capacity: capacity,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+// Add records the ID and reports whether an unexpired entry already existed
+func (s *Set) Add(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if element, ok := s.entries[id]; ok {
+		e := element.Value.(*entry)
+		if now.Before(e.expires) {
+			s.order.MoveToFront(element)
+			return true
+		}
+		// Expired, treat as new
+		e.expires = now.Add(s.ttl)
+		s.order.MoveToFront(element)
+		return false
+	}
+
+	s.entries[id] = s.order.PushFront(&entry{id: id, expires: now.Add(s.ttl)})
+
+	// Evict least recently seen entries beyond capacity
+	for s.order.Len() > s.capacity {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.entries, oldest.Value.(*entry).id)
+	}
+	return false
+}
+
+// Remove deletes the ID from the set
+func (s *Set) Remove(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if element, ok := s.entries[id]; ok {
+		s.order.Remove(element)
+		delete(s.entries, id)
+	}
+}
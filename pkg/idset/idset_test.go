@@ -0,0 +1,32 @@
+package idset
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSetEvictionAndExpiry(t *testing.T) {
+	set := New(2, time.Minute)
+
+	set.Add("a")
+	set.Add("b")
+	set.Add("c") // Evicts "a"
+
+	if set.Add("a") {
+		t.Error("Evicted ID should not be reported as seen")
+	}
+	if !set.Add("c") {
+		t.Error("Recent ID should be reported as seen")
+	}
+	set.Remove("c")
+	if set.Add("c") {
+		t.Error("Removed ID should not be reported as seen")
+	}
+
+	expiring := New(10, time.Millisecond)
+	expiring.Add("x")
+	time.Sleep(5 * time.Millisecond)
+	if expiring.Add("x") {
+		t.Error("Expired ID should not be reported as seen")
+	}
+}
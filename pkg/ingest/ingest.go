@@ -0,0 +1,140 @@
+// Package ingest is the chain of interceptors an event passes through when the producer
+// receives it over HTTP. Each stage is a Middleware wrapping the next Handler: it can
+// inspect the request (headers, API key, client IP), change the event, reject it with
+// an *Error, acknowledge it without passing it on, as sampling does, or observe the
+// outcome, as logging does. The producer's own logging, validation, enrichment, bot
+// detection and sampling are stages of the chain; further stages are added with
+// Register and enabled by name, or with Chain.Use when embedding.
+package ingest
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+// Statuses an ingested event is acknowledged with
+const (
+	StatusAccepted   = "accepted"
+	StatusSampledOut = "sampled_out"
+	StatusDroppedBot = "dropped_bot"
+)
+
+// Handler ingests one decoded event received in r, returning the status it is
+// acknowledged with or an error rejecting it. Events of a batch share the request.
+type Handler func(r *http.Request, event *models.AnalyticsEvent) (string, error)
+
+// Middleware wraps the next stage of the chain. A stage that returns without calling
+// next stops the event there.
+type Middleware func(next Handler) Handler
+
+// Chain is an ordered list of stages. It is safe for concurrent use.
+type Chain struct {
+	stages []Middleware
+	mu     sync.RWMutex
+}
+
+// Use appends stages to the chain; the first stage added sees the event first
+func (c *Chain) Use(stages ...Middleware) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stages = append(c.stages, stages...)
+}
+
+// Then returns a handler running the event through the stages and then final
+func (c *Chain) Then(final Handler) Handler {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	handler := final
+	for i := len(c.stages) - 1; i >= 0; i-- {
+		handler = c.stages[i](handler)
+	}
+	return handler
+}
+
+// Validate returns a stage rejecting events for which check returns an error. Errors
+// other than *Error are reported as 400 invalid_event.
+func Validate(check func(r *http.Request, event *models.AnalyticsEvent) error) Middleware {
+	return func(next Handler) Handler {
+		return func(r *http.Request, event *models.AnalyticsEvent) (string, error) {
+			if err := check(r, event); err != nil {
+				return "", err
+			}
+			return next(r, event)
+		}
+	}
+}
+
+// Enrich returns a stage changing events, e.g. adding metadata, before passing them on
+func Enrich(enrich func(r *http.Request, event *models.AnalyticsEvent)) Middleware {
+	return func(next Handler) Handler {
+		return func(r *http.Request, event *models.AnalyticsEvent) (string, error) {
+			enrich(r, event)
+			return next(r, event)
+		}
+	}
+}
+
+// Error rejects an event with an HTTP status and a stable code clients can branch on.
+// It is also the JSON body of the producer's ingestion error responses.
+type Error struct {
+	Status  int    `json:"-"`
+	Code    string `json:"code"`
+	Message string `json:"error"`
+	Limit   int64  `json:"limit,omitempty"` // The exceeded limit, for size and depth errors
+
+	RetryAfter time.Duration `json:"-"` // Sent as Retry-After with 429 and 503; one second when unset
+}
+
+// Errorf returns an Error with a formatted message
+func Errorf(status int, code, format string, args ...interface{}) *Error {
+	return &Error{Status: status, Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+var (
+	registered   = make(map[string]Middleware)
+	registeredMu sync.RWMutex
+)
+
+// Register makes a stage available by name, typically from an init function, so it
+// can be enabled through configuration. It panics if the name is taken or the stage nil.
+func Register(name string, stage Middleware) {
+	registeredMu.Lock()
+	defer registeredMu.Unlock()
+	if stage == nil {
+		panic("ingest: Register stage is nil")
+	}
+	if _, taken := registered[name]; taken {
+		panic("ingest: Register called twice for stage " + name)
+	}
+	registered[name] = stage
+}
+
+// Lookup returns the stage registered under name
+func Lookup(name string) (Middleware, bool) {
+	registeredMu.RLock()
+	defer registeredMu.RUnlock()
+	stage, ok := registered[name]
+	return stage, ok
+}
+
+// Registered returns the names of the registered stages, sorted
+func Registered() []string {
+	registeredMu.RLock()
+	defer registeredMu.RUnlock()
+	names := make([]string, 0, len(registered))
+	for name := range registered {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,87 @@
+package ingest
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+func TestChainRunsStagesInOrder(t *testing.T) {
+	var calls []string
+	stage := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(r *http.Request, event *models.AnalyticsEvent) (string, error) {
+				calls = append(calls, name)
+				return next(r, event)
+			}
+		}
+	}
+
+	var chain Chain
+	chain.Use(stage("first"), stage("second"))
+	chain.Use(Enrich(func(r *http.Request, event *models.AnalyticsEvent) { event.UserID = "enriched" }))
+	handler := chain.Then(func(r *http.Request, event *models.AnalyticsEvent) (string, error) {
+		calls = append(calls, "final")
+		return StatusAccepted, nil
+	})
+
+	event := &models.AnalyticsEvent{Type: models.Click}
+	status, err := handler(httptest.NewRequest(http.MethodPost, "/event", nil), event)
+	if status != StatusAccepted || err != nil {
+		t.Fatalf("expected the event accepted, got %q %v", status, err)
+	}
+	if want := []string{"first", "second", "final"}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("expected stages %v, got %v", want, calls)
+	}
+	if event.UserID != "enriched" {
+		t.Errorf("expected the event enriched, got %+v", event)
+	}
+}
+
+func TestValidateStopsRejectedEvents(t *testing.T) {
+	var chain Chain
+	chain.Use(Validate(func(r *http.Request, event *models.AnalyticsEvent) error {
+		if event.UserID == "" {
+			return errors.New("user_id is required")
+		}
+		return nil
+	}))
+	reached := false
+	handler := chain.Then(func(r *http.Request, event *models.AnalyticsEvent) (string, error) {
+		reached = true
+		return StatusAccepted, nil
+	})
+
+	_, err := handler(httptest.NewRequest(http.MethodPost, "/event", nil), &models.AnalyticsEvent{Type: models.Click})
+	if reached {
+		t.Error("expected a rejected event not to reach the final handler")
+	}
+	if err == nil || err.Error() != "user_id is required" {
+		t.Errorf("expected the check's error, got %v", err)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	stage := Enrich(func(r *http.Request, event *models.AnalyticsEvent) {})
+	Register("test_stage", stage)
+	if _, ok := Lookup("test_stage"); !ok {
+		t.Fatal("expected the registered stage to be found")
+	}
+	if _, ok := Lookup("missing"); ok {
+		t.Error("expected unknown names not to be found")
+	}
+	if names := Registered(); !reflect.DeepEqual(names, []string{"test_stage"}) {
+		t.Errorf("expected [test_stage], got %v", names)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected registering a name twice to panic")
+		}
+	}()
+	Register("test_stage", stage)
+}
@@ -0,0 +1,114 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/logging"
+)
+
+// RetryPolicy controls how the consumer retries failed fetches and commits
+type RetryPolicy struct {
+	InitialBackoff time.Duration // Delay after the first failure
+	MaxBackoff     time.Duration // Upper bound of the doubling delay
+	MaxRetries     int           // Consecutive fetch failures before giving up; 0 retries forever
+	FailFast       bool          // Return on the first fetch error instead of retrying
+}
+
+// DefaultRetryPolicy retries forever with backoff from 500ms up to 30s
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		InitialBackoff: 500 * time.Millisecond,
+		MaxBackoff:     30 * time.Second,
+	}
+}
+
+// maxCommitAttempts bounds commit retries, since commits run on the worker goroutines
+const maxCommitAttempts = 5
+
+// breakerLogInterval is how often an ongoing outage is logged again
+const breakerLogInterval = 30 * time.Second
+
+// delay returns the backoff before retry number attempt (1-based)
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	return backoffDelay(p.InitialBackoff, p.MaxBackoff, attempt)
+}
+
+// backoffDelay returns the initial delay doubled per attempt, capped at limit, with up
+// to half of it as jitter. A non-positive initial delay uses the default of 500ms.
+func backoffDelay(initial, limit time.Duration, attempt int) time.Duration {
+	d := initial
+	if d <= 0 {
+		d = DefaultRetryPolicy().InitialBackoff
+	}
+	if limit < d {
+		limit = d
+	}
+	for i := 1; i < attempt && d < limit; i++ {
+		d *= 2
+	}
+	if d > limit {
+		d = limit
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// exhausted reports whether failures consecutive errors end the consume loop
+func (p RetryPolicy) exhausted(failures int) bool {
+	return p.FailFast || (p.MaxRetries > 0 && failures > p.MaxRetries)
+}
+
+// isPermanent reports errors that retrying cannot fix, such as a closed reader
+func isPermanent(err error) bool {
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrClosedPipe)
+}
+
+// sleep waits for d or until ctx is done, reporting whether the full delay passed
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// fetchBreaker tracks consecutive fetch failures so an outage is logged when it
+// starts, periodically while it lasts and once when it ends, not on every retry
+type fetchBreaker struct {
+	failures int
+	since    time.Time // First failure of the current outage
+	lastLog  time.Time
+}
+
+// failure records a failed fetch and reports whether the outage just started
+func (b *fetchBreaker) failure(err error, now time.Time) bool {
+	b.failures++
+	if b.failures == 1 {
+		b.since = now
+		b.lastLog = now
+		logging.Warn("Kafka fetch failed, retrying with backoff", "error", err)
+		return true
+	}
+	if now.Sub(b.lastLog) >= breakerLogInterval {
+		b.lastLog = now
+		logging.Warn("Kafka still unavailable", "failures", b.failures, "down_for", now.Sub(b.since).Round(time.Second).String(), "error", err)
+	}
+	return false
+}
+
+// success records a successful fetch and reports whether it ended an outage
+func (b *fetchBreaker) success(now time.Time) bool {
+	if b.failures == 0 {
+		return false
+	}
+	logging.Info("Kafka fetch recovered", "failures", b.failures, "down_for", now.Sub(b.since).Round(time.Millisecond).String())
+	b.failures = 0
+	return true
+}
@@ -0,0 +1,64 @@
+package kafka
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+
+	tests := []struct {
+		attempt int
+		base    time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{4, 800 * time.Millisecond},
+		{5, time.Second},
+		{50, time.Second},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 20; i++ {
+			d := policy.delay(tt.attempt)
+			if d < tt.base/2 || d > tt.base {
+				t.Fatalf("delay(%d) = %v, want between %v and %v", tt.attempt, d, tt.base/2, tt.base)
+			}
+		}
+	}
+}
+
+func TestRetryPolicyExhausted(t *testing.T) {
+	if (RetryPolicy{}).exhausted(1000) {
+		t.Error("policy without MaxRetries should retry forever")
+	}
+	limited := RetryPolicy{MaxRetries: 3}
+	if limited.exhausted(3) || !limited.exhausted(4) {
+		t.Error("MaxRetries 3 should allow three retries after the first failure")
+	}
+	if !(RetryPolicy{FailFast: true}).exhausted(1) {
+		t.Error("FailFast should give up on the first failure")
+	}
+}
+
+func TestFetchBreaker(t *testing.T) {
+	var b fetchBreaker
+	now := time.Now()
+	if b.success(now) {
+		t.Error("success without failures should not report a recovery")
+	}
+	if !b.failure(errors.New("connection refused"), now) {
+		t.Error("first failure should start an outage")
+	}
+	if b.failure(errors.New("connection refused"), now.Add(time.Second)) {
+		t.Error("second failure should not start another outage")
+	}
+	if !b.success(now.Add(2*time.Second)) || b.failures != 0 {
+		t.Error("success after failures should end the outage")
+	}
+	if !isPermanent(io.EOF) || isPermanent(errors.New("broker not available")) {
+		t.Error("only a closed reader should be permanent")
+	}
+}
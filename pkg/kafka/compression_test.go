@@ -0,0 +1,68 @@
+package kafka
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+// samplePayload returns a JSON batch of typical page view events
+func samplePayload(events int) []byte {
+	var buf bytes.Buffer
+	for i := 0; i < events; i++ {
+		event := models.AnalyticsEvent{
+			ID:        fmt.Sprintf("evt-%06d", i),
+			Type:      models.PageView,
+			UserID:    fmt.Sprintf("user-%d", i%500),
+			SessionID: fmt.Sprintf("session-%d", i%800),
+			URL:       fmt.Sprintf("https://example.com/products/%d?utm_source=newsletter", i%40),
+			Path:      fmt.Sprintf("/products/%d", i%40),
+			Referrer:  "https://www.google.com/",
+			UserAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+			Timestamp: time.Date(2024, 1, 1, 12, 0, i%60, 0, time.UTC),
+			Metadata:  map[string]interface{}{"load_time": 800 + i%1200, "page_title": "Product"},
+		}
+		data, _ := json.Marshal(event)
+		buf.Write(data)
+	}
+	return buf.Bytes()
+}
+
+func TestParseCompression(t *testing.T) {
+	for _, name := range []string{"", "none", "gzip", "Snappy", "lz4", "zstd"} {
+		if _, err := ParseCompression(name); err != nil {
+			t.Errorf("ParseCompression(%q) failed: %v", name, err)
+		}
+	}
+	if _, err := ParseCompression("brotli"); err == nil {
+		t.Error("Expected unknown codec to be rejected")
+	}
+}
+
+// BenchmarkCompression compresses a 100-event batch with each codec and reports the ratio
+func BenchmarkCompression(b *testing.B) {
+	payload := samplePayload(100)
+
+	for _, name := range []string{"gzip", "snappy", "lz4", "zstd"} {
+		compression, _ := ParseCompression(name)
+		codec := compression.Codec()
+
+		b.Run(name, func(b *testing.B) {
+			var out bytes.Buffer
+			b.SetBytes(int64(len(payload)))
+			b.ReportAllocs()
+
+			for i := 0; i < b.N; i++ {
+				out.Reset()
+				w := codec.NewWriter(&out)
+				w.Write(payload)
+				w.Close()
+			}
+			b.ReportMetric(float64(len(payload))/float64(out.Len()), "ratio")
+		})
+	}
+}
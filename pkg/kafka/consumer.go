@@ -4,89 +4,565 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/errs"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/logging"
 	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
 	"github.com/segmentio/kafka-go"
 )
 
+// Message is a decoded event together with its Kafka source
+type Message struct {
+	Topic     string
+	Partition int
+	Offset    int64
+	Key       []byte
+	Time      time.Time
+	Headers   Headers
+	Event     *models.AnalyticsEvent
+}
+
+// DecodeMessage parses a message's headers and event payload. Other brokers carry
+// messages in the same format, see package bus.
+func DecodeMessage(msg kafka.Message) (*Message, error) {
+	headers := ParseHeaders(msg.Headers)
+
+	version, err := headers.SchemaVersion()
+	if err != nil {
+		return nil, errs.Errorf(errs.ErrInvalidEvent, "invalid schema version header: %w", err)
+	}
+	if version > EventSchemaVersion {
+		return nil, errs.Errorf(errs.ErrInvalidEvent, "unsupported schema version %d (newest supported is %d)", version, EventSchemaVersion)
+	}
+	if encoding := headers[HeaderContentEncoding]; encoding != "" && encoding != "identity" {
+		return nil, errs.Errorf(errs.ErrInvalidEvent, "unsupported content encoding %q", encoding)
+	}
+
+	var event models.AnalyticsEvent
+	if err := json.Unmarshal(msg.Value, &event); err != nil {
+		return nil, errs.Errorf(errs.ErrInvalidEvent, "failed to decode event: %w", err)
+	}
+
+	return &Message{
+		Topic:     msg.Topic,
+		Partition: msg.Partition,
+		Offset:    msg.Offset,
+		Key:       msg.Key,
+		Time:      msg.Time,
+		Headers:   headers,
+		Event:     &event,
+	}, nil
+}
+
 // Consumer represents a Kafka consumer
 type Consumer struct {
-	reader  *kafka.Reader
-	topic   string
+	brokers []string
+	topics  []string
 	groupID string
+	hook    models.OperationalHook
+	workers int
+	filter  func(Headers) bool // nil accepts every message
+	retry   RetryPolicy
+
+	// Set to consume through group generations with assignment callbacks
+	listener RebalanceListener
+
+	// Pattern subscription, nil when consuming a fixed topic list
+	pattern         *regexp.Regexp
+	refreshInterval time.Duration
+
+	// The reader is replaced when pattern discovery finds a different topic set.
+	// stopRead interrupts the consume loop's fetch so it moves to the new reader.
+	reader   *kafka.Reader
+	stopRead context.CancelFunc
+	readerMu sync.RWMutex
+
+	// Worker pool of the running consume loop, nil while not consuming
+	pool atomic.Pointer[workerPool]
+
+	// Shutdown state: stopFetch halts the running consume loop and done closes when it returns
+	stopFetch context.CancelFunc
+	done      chan struct{}
+	stopped   bool
+	runMu     sync.Mutex
 }
 
 // NewConsumer creates a new Kafka consumer
 func NewConsumer(brokers []string, topic, groupID string) *Consumer {
-	reader := kafka.NewReader(kafka.ReaderConfig{
-		Brokers:  brokers,
-		Topic:    topic,
-		GroupID:  groupID,
+	return NewMultiTopicConsumer(brokers, []string{topic}, groupID)
+}
+
+// NewMultiTopicConsumer creates a consumer that reads several topics in one consumer group
+func NewMultiTopicConsumer(brokers []string, topics []string, groupID string) *Consumer {
+	c := &Consumer{
+		brokers: brokers,
+		topics:  topics,
+		groupID: groupID,
+		retry:   DefaultRetryPolicy(),
+	}
+	c.reader = c.newReader(topics)
+	return c
+}
+
+// NewPatternConsumer creates a consumer that subscribes to all topics matching pattern,
+// rediscovering topics every refreshInterval while consuming
+func NewPatternConsumer(ctx context.Context, brokers []string, pattern *regexp.Regexp, groupID string, refreshInterval time.Duration) (*Consumer, error) {
+	c := &Consumer{
+		brokers:         brokers,
+		groupID:         groupID,
+		pattern:         pattern,
+		refreshInterval: refreshInterval,
+		retry:           DefaultRetryPolicy(),
+	}
+
+	topics, err := c.discoverTopics(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(topics) == 0 {
+		return nil, fmt.Errorf("no topics match pattern %q", pattern.String())
+	}
+
+	c.topics = topics
+	c.reader = c.newReader(topics)
+	return c, nil
+}
+
+// newReader creates a reader for the given topics
+func (c *Consumer) newReader(topics []string) *kafka.Reader {
+	config := kafka.ReaderConfig{
+		Brokers:  c.brokers,
+		GroupID:  c.groupID,
 		MinBytes: 10e3, // 10KB
 		MaxBytes: 10e6, // 10MB
-	})
+	}
+	if len(topics) == 1 {
+		config.Topic = topics[0]
+	} else {
+		config.GroupTopics = topics
+	}
+	return kafka.NewReader(config)
+}
 
-	return &Consumer{
-		reader:  reader,
-		topic:   topic,
-		groupID: groupID,
+// currentReader returns the active reader
+func (c *Consumer) currentReader() *kafka.Reader {
+	c.readerMu.RLock()
+	defer c.readerMu.RUnlock()
+	return c.reader
+}
+
+// startReading returns the active reader, and a context for fetching from it that is
+// cancelled when topic discovery replaces it
+func (c *Consumer) startReading(ctx context.Context) (*kafka.Reader, context.Context) {
+	readCtx, stopRead := context.WithCancel(ctx)
+
+	c.readerMu.Lock()
+	defer c.readerMu.Unlock()
+	c.stopRead = stopRead
+	return c.reader, readCtx
+}
+
+// retireReader waits until the messages fetched through a replaced reader are handled
+// and committed, since their commits go through it, then closes it
+func (c *Consumer) retireReader(reader *kafka.Reader, inflight *sync.WaitGroup) {
+	inflight.Wait()
+	if err := reader.Close(); err != nil {
+		logging.Error("Failed to close previous reader", "error", err)
+	}
+}
+
+// Topics returns the topics currently being consumed
+func (c *Consumer) Topics() []string {
+	c.readerMu.RLock()
+	defer c.readerMu.RUnlock()
+	return append([]string(nil), c.topics...)
+}
+
+// discoverTopics lists non-internal topics on the cluster that match the pattern
+func (c *Consumer) discoverTopics(ctx context.Context) ([]string, error) {
+	var lastErr error
+	for _, broker := range c.brokers {
+		conn, err := kafka.DialContext(ctx, "tcp", broker)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		partitions, err := conn.ReadPartitions()
+		conn.Close()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		seen := make(map[string]bool)
+		var topics []string
+		for _, partition := range partitions {
+			if seen[partition.Topic] || strings.HasPrefix(partition.Topic, "__") || !c.pattern.MatchString(partition.Topic) {
+				continue
+			}
+			seen[partition.Topic] = true
+			topics = append(topics, partition.Topic)
+		}
+		sort.Strings(topics)
+		return topics, nil
+	}
+	return nil, errs.Errorf(errs.ErrKafkaUnavailable, "failed to list topics: %w", lastErr)
+}
+
+// watchTopics periodically rediscovers pattern topics and swaps the reader when they change
+func (c *Consumer) watchTopics(ctx context.Context) {
+	ticker := time.NewTicker(c.refreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			topics, err := c.discoverTopics(ctx)
+			if err != nil {
+				logging.Error("Topic discovery failed", "error", err)
+				continue
+			}
+			if len(topics) == 0 || strings.Join(topics, ",") == strings.Join(c.Topics(), ",") {
+				continue
+			}
+
+			logging.Info("Topic set changed", "topics", strings.Join(topics, ","))
+			c.readerMu.Lock()
+			c.topics = topics
+			c.reader = c.newReader(topics)
+			stopRead := c.stopRead
+			c.readerMu.Unlock()
+
+			// The consume loop stops fetching from the old reader, and closes it once
+			// the messages it fetched are committed
+			if stopRead != nil {
+				stopRead()
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// SetOperationalHook registers a callback for decode failures, processing failures,
+// dropped messages and rebalances
+func (c *Consumer) SetOperationalHook(hook models.OperationalHook) {
+	c.hook = hook
+}
+
+// SetWorkers sets how many messages are handled concurrently. Events for the same
+// user (or session, or message key) always go to the same worker and stay in order.
+func (c *Consumer) SetWorkers(workers int) {
+	c.workers = workers
+}
+
+// QueueDepth returns how many fetched messages are waiting for or being handled by a
+// worker; it is 0 while not consuming
+func (c *Consumer) QueueDepth() int {
+	if pool := c.pool.Load(); pool != nil {
+		return pool.depth()
+	}
+	return 0
+}
+
+// SetRetryPolicy sets how failed fetches and commits are retried
+func (c *Consumer) SetRetryPolicy(policy RetryPolicy) {
+	c.retry = policy
+}
+
+// SetHeaderFilter skips messages whose headers the filter rejects, before their payload
+// is decoded. Skipped messages are committed.
+func (c *Consumer) SetHeaderFilter(filter func(Headers) bool) {
+	c.filter = filter
+}
+
+// report forwards an operational event to the hook, if one is set
+func (c *Consumer) report(operation string, details map[string]interface{}) {
+	if c.hook != nil {
+		c.hook(operation, details)
+	}
+}
+
+// watchRebalances periodically reports consumer group rebalances from the reader stats
+func (c *Consumer) watchRebalances(ctx context.Context) {
+	ticker := time.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if rebalances := c.currentReader().Stats().Rebalances; rebalances > 0 {
+				logging.Info("Consumer group rebalanced", "group", c.groupID, "rebalances", rebalances)
+				c.report(models.OperationConsumerRebalance, map[string]interface{}{
+					"topics":     strings.Join(c.Topics(), ","),
+					"group":      c.groupID,
+					"rebalances": rebalances,
+				})
+			}
+		case <-ctx.Done():
+			return
+		}
 	}
 }
 
 // ConsumeEvents consumes and processes events from Kafka
 func (c *Consumer) ConsumeEvents(ctx context.Context, handler func(*models.AnalyticsEvent) error) error {
-	log.Printf("Starting consumer for topic: %s, group: %s", c.topic, c.groupID)
+	return c.ConsumeMessages(ctx, func(msg *Message) error {
+		return handler(msg.Event)
+	})
+}
+
+// ConsumeMessages consumes events from Kafka, passing each with its source topic and position.
+// With more than one worker the handler is called concurrently and must be safe for concurrent use.
+func (c *Consumer) ConsumeMessages(ctx context.Context, handler func(*Message) error) error {
+	logging.Info("Starting consumer", "topics", strings.Join(c.Topics(), ","), "group", c.groupID)
 
 	const maxRetries = 3
 
+	// Shutdown cancels only fetching, so the message being handled finishes and is committed
+	fetchCtx, stopFetch := context.WithCancel(ctx)
+	defer stopFetch()
+	done := c.startRun(stopFetch)
+	defer close(done)
+
+	// Offsets are committed in order per partition as workers finish
+	tracker := newOffsetTracker(func(j *job) {
+		c.commit(j)
+	})
+	pool := newWorkerPool(c.workers, func(j *job) {
+		c.handle(j.message, handler, maxRetries)
+		finish(tracker, j)
+	})
+	// Let queued messages finish before returning so Shutdown drains them
+	defer pool.stop()
+	c.pool.Store(pool)
+	defer c.pool.Store(nil)
+
+	if c.listener != nil {
+		return c.consumeGenerations(ctx, fetchCtx, tracker, pool)
+	}
+
+	if c.hook != nil {
+		go c.watchRebalances(fetchCtx)
+	}
+	if c.pattern != nil {
+		go c.watchTopics(fetchCtx)
+	}
+
+	var breaker fetchBreaker
+
+	reader, readCtx := c.startReading(fetchCtx)
+	var inflight sync.WaitGroup
 	for {
 		select {
-		case <-ctx.Done():
-			log.Println("Consumer context cancelled, shutting down")
-			return ctx.Err()
+		case <-fetchCtx.Done():
+			return c.stopReason(ctx)
 		default:
-			msg, err := c.reader.FetchMessage(ctx)
+			msg, err := reader.FetchMessage(readCtx)
 			if err != nil {
-				return fmt.Errorf("failed to fetch message: %w", err)
-			}
-
-			var event models.AnalyticsEvent
-			if err := json.Unmarshal(msg.Value, &event); err != nil {
-				log.Printf("Failed to unmarshal event: %v", err)
-				// Commit message even if unmarshal fails to avoid reprocessing
-				if err := c.reader.CommitMessages(ctx, msg); err != nil {
-					log.Printf("Failed to commit message: %v", err)
+				if fetchCtx.Err() != nil {
+					return c.stopReason(ctx)
+				}
+				// Topic discovery replaced the reader: drain the old one before moving on
+				if reader != c.currentReader() {
+					c.retireReader(reader, &inflight)
+					reader, readCtx = c.startReading(fetchCtx)
+					continue
+				}
+				if isPermanent(err) {
+					return fmt.Errorf("failed to fetch message: %w", err)
+				}
+				if breaker.failure(err, time.Now()) {
+					c.report(models.OperationConsumerOutage, map[string]interface{}{
+						"topics": strings.Join(c.Topics(), ","),
+						"group":  c.groupID,
+						"error":  err.Error(),
+					})
+				}
+				if c.retry.exhausted(breaker.failures) {
+					return errs.Errorf(errs.ErrKafkaUnavailable, "failed to fetch message after %d attempts: %w", breaker.failures, err)
+				}
+				if !sleep(fetchCtx, c.retry.delay(breaker.failures)) {
+					return c.stopReason(ctx)
 				}
 				continue
 			}
+			if breaker.success(time.Now()) {
+				c.report(models.OperationConsumerRecovered, map[string]interface{}{
+					"topics": strings.Join(c.Topics(), ","),
+					"group":  c.groupID,
+				})
+			}
 
-			log.Printf("Processing event - Type: %s, ID: %s, User: %s", event.Type, event.ID, event.UserID)
+			inflight.Add(1)
+			c.dispatch(&job{reader: reader, msg: msg, inflight: &inflight}, tracker, pool)
+		}
+	}
+}
 
-			// Process with retries
-			for attempt := 1; attempt <= maxRetries; attempt++ {
-				if err := handler(&event); err != nil {
-					log.Printf("Failed to process event (attempt %d/%d): %v", attempt, maxRetries, err)
-					if attempt == maxRetries {
-						log.Printf("Max retries reached for event %s, moving to next message", event.ID)
-						// Consider sending to dead letter queue here in production
-					}
-					continue
-				}
-				// Successfully processed, exit retry loop
-				break
-			}
+// dispatch decodes a fetched message and queues it for a worker. Filtered and
+// undecodable messages are completed, and so committed, straight away.
+func (c *Consumer) dispatch(j *job, tracker *offsetTracker, pool *workerPool) {
+	msg := j.msg
+	tracker.track(j)
 
-			// Commit message after processing or max retries
-			// Always commit to avoid blocking the consumer
-			if err := c.reader.CommitMessages(ctx, msg); err != nil {
-				log.Printf("Failed to commit message: %v", err)
-			}
+	// Skip unwanted messages without decoding them
+	if c.filter != nil && !c.filter(ParseHeaders(msg.Headers)) {
+		finish(tracker, j)
+		return
+	}
+
+	message, err := DecodeMessage(msg)
+	if err != nil {
+		logging.Error("Failed to decode event", "topic", msg.Topic, "partition", msg.Partition, "offset", msg.Offset, "error", err)
+		c.report(models.OperationDecodeError, map[string]interface{}{
+			"topic":     msg.Topic,
+			"partition": msg.Partition,
+			"offset":    msg.Offset,
+			"error":     err.Error(),
+		})
+		// Commit message even if unmarshal fails to avoid reprocessing
+		finish(tracker, j)
+		return
+	}
+
+	logging.Debug("Fetched event", "topic", msg.Topic, "partition", msg.Partition, "offset", msg.Offset,
+		"event_id", message.Event.ID, "event_type", message.Event.Type)
+	j.message = message
+
+	// Blocks while the owning worker's queue is full, applying backpressure to fetching
+	pool.submit(j)
+}
+
+// finish completes a job, committing its offset when every earlier one is done
+func finish(tracker *offsetTracker, j *job) {
+	tracker.complete(j)
+	if j.inflight != nil {
+		j.inflight.Done()
+	}
+}
+
+// handle passes a message to the handler, retrying errors that retrying may fix. The
+// message is committed afterwards even if every attempt fails, to avoid blocking the
+// consumer.
+func (c *Consumer) handle(message *Message, handler func(*Message) error, maxRetries int) {
+	event := message.Event
+	logger := logging.With("topic", message.Topic, "event_id", event.ID, "event_type", event.Type)
+	for attempt := 1; attempt <= maxRetries; attempt++ {
+		err := handler(message)
+		if err == nil {
+			return
+		}
+		logger.Warn("Failed to process event", "attempt", attempt, "max_attempts", maxRetries, "error", err)
+		if attempt < maxRetries && errs.Retryable(err) {
+			continue
+		}
+
+		if attempt < maxRetries {
+			logger.Error("Error is not retryable, moving to next message", "code", errs.Code(err))
+		} else {
+			logger.Error("Max retries reached, moving to next message")
+		}
+		c.report(models.OperationProcessingFailed, map[string]interface{}{
+			"topic":      message.Topic,
+			"event_id":   event.ID,
+			"event_type": string(event.Type),
+			"error":      err.Error(),
+		})
+		c.report(models.OperationDLQRoute, map[string]interface{}{
+			"topic":      message.Topic,
+			"event_id":   event.ID,
+			"event_type": string(event.Type),
+			"attempts":   attempt,
+		})
+		return
+	}
+}
+
+// commit commits a processed message, retrying transient failures with backoff. It
+// deliberately ignores consume cancellation so the offset of the last handled message
+// is still stored during shutdown. A commit that still fails is left to a later
+// offset of the same partition, which covers it.
+func (c *Consumer) commit(j *job) {
+	msg := j.msg
+	for attempt := 1; ; attempt++ {
+		var err error
+		if j.gen != nil {
+			// Generations commit the offset of the next message to read
+			err = j.gen.CommitOffsets(map[string]map[int]int64{msg.Topic: {msg.Partition: msg.Offset + 1}})
+		} else {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			err = j.reader.CommitMessages(ctx, msg)
+			cancel()
+		}
+		if err == nil {
+			return
+		}
+		if isPermanent(err) || attempt == maxCommitAttempts {
+			logging.Error("Failed to commit message", "topic", msg.Topic, "partition", msg.Partition, "offset", msg.Offset,
+				"attempts", attempt, "error", err)
+			return
 		}
+		logging.Debug("Retrying commit", "topic", msg.Topic, "partition", msg.Partition, "offset", msg.Offset, "attempt", attempt, "error", err)
+		time.Sleep(c.retry.delay(attempt))
+	}
+}
+
+// startRun records the running consume loop so Shutdown can stop and wait for it
+func (c *Consumer) startRun(stopFetch context.CancelFunc) chan struct{} {
+	c.runMu.Lock()
+	defer c.runMu.Unlock()
+
+	c.stopFetch = stopFetch
+	c.done = make(chan struct{})
+	if c.stopped {
+		stopFetch()
+	}
+	return c.done
+}
+
+// stopReason reports why the consume loop ended: nil after Shutdown, otherwise the context error
+func (c *Consumer) stopReason(ctx context.Context) error {
+	if ctx.Err() != nil {
+		logging.Info("Consumer context cancelled, shutting down")
+		return ctx.Err()
+	}
+	logging.Info("Consumer shut down after draining in-flight messages")
+	return nil
+}
+
+// Shutdown stops fetching new messages, waits for in-flight messages to be handled and
+// committed, then closes the reader. If ctx expires first the reader is closed anyway
+// and the uncommitted messages will be redelivered to the group.
+func (c *Consumer) Shutdown(ctx context.Context) error {
+	c.runMu.Lock()
+	c.stopped = true
+	stopFetch, done := c.stopFetch, c.done
+	c.runMu.Unlock()
+
+	var err error
+	if stopFetch != nil {
+		stopFetch()
+		select {
+		case <-done:
+		case <-ctx.Done():
+			err = fmt.Errorf("timed out draining in-flight messages: %w", ctx.Err())
+		}
+	}
+
+	if closeErr := c.Close(); closeErr != nil && err == nil {
+		err = closeErr
 	}
+	return err
 }
 
 // Close closes the consumer
 func (c *Consumer) Close() error {
-	return c.reader.Close()
+	return c.currentReader().Close()
 }
@@ -0,0 +1,188 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/errs"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/logging"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+// TopicPartition identifies a partition assigned to the consumer
+type TopicPartition struct {
+	Topic     string
+	Partition int
+	Offset    int64 // Committed offset consumption resumes at when assigned, or kafka.FirstOffset
+}
+
+// RebalanceListener is told when the consumer group assigns partitions to this
+// consumer or takes them away, so per-partition state can be restored and checkpointed
+type RebalanceListener interface {
+	// PartitionsAssigned is called before any message of the partitions is handled
+	PartitionsAssigned(ctx context.Context, partitions []TopicPartition)
+
+	// PartitionsRevoked is called once every fetched message of the partitions has
+	// been handled and committed, before another group member can receive them
+	PartitionsRevoked(ctx context.Context, partitions []TopicPartition)
+}
+
+// SetRebalanceListener registers callbacks for partition assignment and revocation.
+// The consumer then joins the group through group generations, reading each assigned
+// partition with its own reader; topic pattern refreshes are not applied in this mode.
+// It must be called before consuming.
+func (c *Consumer) SetRebalanceListener(listener RebalanceListener) {
+	c.listener = listener
+}
+
+// consumeGenerations consumes as a member of the consumer group, one generation at a
+// time. Each generation's partitions are drained and committed before the listener is
+// told they are revoked and the next generation is joined.
+func (c *Consumer) consumeGenerations(ctx, fetchCtx context.Context, tracker *offsetTracker, pool *workerPool) error {
+	if c.pattern != nil {
+		logging.Warn("Topic pattern refresh is disabled while a rebalance listener is set", "pattern", c.pattern.String())
+	}
+
+	group, err := kafka.NewConsumerGroup(kafka.ConsumerGroupConfig{
+		ID:          c.groupID,
+		Brokers:     c.brokers,
+		Topics:      c.Topics(),
+		StartOffset: kafka.FirstOffset,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to join consumer group: %w", err)
+	}
+	defer group.Close()
+
+	var breaker fetchBreaker
+	for {
+		gen, err := group.Next(fetchCtx)
+		if err != nil {
+			if fetchCtx.Err() != nil {
+				return c.stopReason(ctx)
+			}
+			if errors.Is(err, kafka.ErrGroupClosed) {
+				return fmt.Errorf("consumer group closed: %w", err)
+			}
+			breaker.failure(err, time.Now())
+			if c.retry.exhausted(breaker.failures) {
+				return errs.Errorf(errs.ErrKafkaUnavailable, "failed to join consumer group after %d attempts: %w", breaker.failures, err)
+			}
+			if !sleep(fetchCtx, c.retry.delay(breaker.failures)) {
+				return c.stopReason(ctx)
+			}
+			continue
+		}
+		breaker.success(time.Now())
+
+		assigned := generationPartitions(gen)
+		logging.Info("Partitions assigned", "group", c.groupID, "generation", gen.ID, "partitions", formatPartitions(assigned))
+		c.report(models.OperationConsumerRebalance, map[string]interface{}{
+			"topics":     strings.Join(c.Topics(), ","),
+			"group":      c.groupID,
+			"generation": gen.ID,
+			"partitions": formatPartitions(assigned),
+		})
+		if len(assigned) == 0 {
+			// More members than partitions; wait idle for the next generation
+			continue
+		}
+		c.listener.PartitionsAssigned(fetchCtx, assigned)
+
+		var partitions sync.WaitGroup
+		for _, tp := range assigned {
+			partitions.Add(1)
+			gen.Start(func(genCtx context.Context) {
+				defer partitions.Done()
+				c.consumePartition(genCtx, fetchCtx, gen, tp, tracker, pool)
+			})
+		}
+		partitions.Wait()
+
+		logging.Info("Partitions revoked", "group", c.groupID, "generation", gen.ID, "partitions", formatPartitions(assigned))
+		c.listener.PartitionsRevoked(context.Background(), assigned)
+
+		if fetchCtx.Err() != nil {
+			return c.stopReason(ctx)
+		}
+	}
+}
+
+// consumePartition reads one assigned partition until the generation ends or fetching
+// stops, then waits until its fetched messages are handled and committed
+func (c *Consumer) consumePartition(genCtx, fetchCtx context.Context, gen *kafka.Generation, tp TopicPartition, tracker *offsetTracker, pool *workerPool) {
+	readCtx, cancel := context.WithCancel(genCtx)
+	defer cancel()
+	stop := context.AfterFunc(fetchCtx, cancel)
+	defer stop()
+
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:   c.brokers,
+		Topic:     tp.Topic,
+		Partition: tp.Partition,
+		MinBytes:  10e3, // 10KB
+		MaxBytes:  10e6, // 10MB
+	})
+	defer reader.Close()
+
+	var inflight sync.WaitGroup
+	// Commit what was fetched before giving the partition up
+	defer inflight.Wait()
+
+	if err := reader.SetOffset(tp.Offset); err != nil {
+		logging.Error("Failed to seek partition", "topic", tp.Topic, "partition", tp.Partition, "offset", tp.Offset, "error", err)
+		return
+	}
+
+	var breaker fetchBreaker
+	for {
+		msg, err := reader.FetchMessage(readCtx)
+		if err != nil {
+			if readCtx.Err() != nil || isPermanent(err) {
+				return
+			}
+			breaker.failure(err, time.Now())
+			if !sleep(readCtx, c.retry.delay(breaker.failures)) {
+				return
+			}
+			continue
+		}
+		breaker.success(time.Now())
+
+		inflight.Add(1)
+		c.dispatch(&job{reader: reader, gen: gen, msg: msg, inflight: &inflight}, tracker, pool)
+	}
+}
+
+// generationPartitions lists a generation's assignments sorted by topic and partition
+func generationPartitions(gen *kafka.Generation) []TopicPartition {
+	var partitions []TopicPartition
+	for topic, assignments := range gen.Assignments {
+		for _, assignment := range assignments {
+			partitions = append(partitions, TopicPartition{Topic: topic, Partition: assignment.ID, Offset: assignment.Offset})
+		}
+	}
+	sort.Slice(partitions, func(i, j int) bool {
+		if partitions[i].Topic != partitions[j].Topic {
+			return partitions[i].Topic < partitions[j].Topic
+		}
+		return partitions[i].Partition < partitions[j].Partition
+	})
+	return partitions
+}
+
+// formatPartitions renders partitions as "topic/partition" for logs
+func formatPartitions(partitions []TopicPartition) string {
+	names := make([]string, len(partitions))
+	for i, tp := range partitions {
+		names[i] = fmt.Sprintf("%s/%d", tp.Topic, tp.Partition)
+	}
+	return strings.Join(names, ",")
+}
@@ -0,0 +1,32 @@
+package kafka
+
+import (
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func TestGenerationPartitions(t *testing.T) {
+	gen := &kafka.Generation{Assignments: map[string][]kafka.PartitionAssignment{
+		"orders": {{ID: 1, Offset: 40}},
+		"events": {{ID: 2, Offset: kafka.FirstOffset}, {ID: 0, Offset: 7}},
+	}}
+
+	partitions := generationPartitions(gen)
+	want := []TopicPartition{
+		{Topic: "events", Partition: 0, Offset: 7},
+		{Topic: "events", Partition: 2, Offset: kafka.FirstOffset},
+		{Topic: "orders", Partition: 1, Offset: 40},
+	}
+	if len(partitions) != len(want) {
+		t.Fatalf("got %d partitions, want %d", len(partitions), len(want))
+	}
+	for i := range want {
+		if partitions[i] != want[i] {
+			t.Errorf("partition %d = %+v, want %+v", i, partitions[i], want[i])
+		}
+	}
+	if got := formatPartitions(partitions); got != "events/0,events/2,orders/1" {
+		t.Errorf("formatPartitions = %q", got)
+	}
+}
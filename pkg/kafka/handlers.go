@@ -0,0 +1,171 @@
+package kafka
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/logging"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+// MessageHandler processes one consumed message
+type MessageHandler func(*Message) error
+
+// HandlerMiddleware wraps a handler, e.g. to time, filter or enrich messages
+type HandlerMiddleware func(next MessageHandler) MessageHandler
+
+// ErrorPolicy decides what happens to a message whose handler fails
+type ErrorPolicy int
+
+const (
+	// ErrorRetry returns the error to the consumer, which retries the message and then
+	// reports it as failed. It is the default.
+	ErrorRetry ErrorPolicy = iota
+
+	// ErrorDrop logs the error and acknowledges the message without retrying, for events
+	// that are cheap to lose or that would fail the same way again
+	ErrorDrop
+)
+
+// HandlerOption configures a registered handler
+type HandlerOption func(*handlerRoute)
+
+// WithErrorPolicy sets what happens when the handler fails
+func WithErrorPolicy(policy ErrorPolicy) HandlerOption {
+	return func(r *handlerRoute) { r.policy = policy }
+}
+
+// WithMiddleware wraps the handler; the first middleware sees the message first
+func WithMiddleware(middleware ...HandlerMiddleware) HandlerOption {
+	return func(r *handlerRoute) { r.middleware = append(r.middleware, middleware...) }
+}
+
+// handlerRoute is a registered handler with its middleware composed
+type handlerRoute struct {
+	handler    MessageHandler
+	middleware []HandlerMiddleware
+	policy     ErrorPolicy
+	composed   MessageHandler
+}
+
+// HandlerRegistry dispatches messages to handlers by event type, passing types without
+// a handler of their own to the fallback. Its Dispatch method is the handler given to
+// ConsumeMessages. It is safe for concurrent use, so handlers can be registered while
+// consuming.
+type HandlerRegistry struct {
+	routes   map[models.EventType]*handlerRoute
+	fallback *handlerRoute
+	shared   []HandlerMiddleware // Wrap every handler, outside its own middleware
+	hook     models.OperationalHook
+	mu       sync.RWMutex
+}
+
+// NewHandlerRegistry creates a registry passing every message to fallback until other
+// handlers are registered
+func NewHandlerRegistry(fallback MessageHandler, options ...HandlerOption) *HandlerRegistry {
+	r := &HandlerRegistry{routes: make(map[models.EventType]*handlerRoute)}
+	r.fallback = r.newRoute(fallback, options)
+	return r
+}
+
+// Register sets the handler of an event type, replacing any registered before
+func (r *HandlerRegistry) Register(eventType models.EventType, handler MessageHandler, options ...HandlerOption) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.routes[eventType] = r.newRoute(handler, options)
+}
+
+// SetFallback replaces the handler of event types without a handler of their own
+func (r *HandlerRegistry) SetFallback(handler MessageHandler, options ...HandlerOption) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fallback = r.newRoute(handler, options)
+}
+
+// Unregister removes the handler of an event type, so its messages go to the fallback
+func (r *HandlerRegistry) Unregister(eventType models.EventType) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.routes, eventType)
+}
+
+// Use adds middleware wrapping every handler, including those already registered
+func (r *HandlerRegistry) Use(middleware ...HandlerMiddleware) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.shared = append(r.shared, middleware...)
+	r.fallback.composed = r.compose(r.fallback)
+	for _, route := range r.routes {
+		route.composed = r.compose(route)
+	}
+}
+
+// SetOperationalHook registers a callback for messages dropped by ErrorDrop
+func (r *HandlerRegistry) SetOperationalHook(hook models.OperationalHook) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.hook = hook
+}
+
+// Registered returns the event types with a handler of their own, sorted
+func (r *HandlerRegistry) Registered() []models.EventType {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	types := make([]models.EventType, 0, len(r.routes))
+	for eventType := range r.routes {
+		types = append(types, eventType)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	return types
+}
+
+// Dispatch passes a message to the handler of its event type, applying the handler's
+// error policy to failures
+func (r *HandlerRegistry) Dispatch(message *Message) error {
+	r.mu.RLock()
+	route, ok := r.routes[message.Event.Type]
+	if !ok {
+		route = r.fallback
+	}
+	handler, policy, hook := route.composed, route.policy, r.hook
+	r.mu.RUnlock()
+
+	err := handler(message)
+	if err != nil && policy == ErrorDrop {
+		logging.Warn("Dropping event after handler error", "topic", message.Topic, "event_id", message.Event.ID,
+			"event_type", message.Event.Type, "error", err)
+		if hook != nil {
+			hook(models.OperationDLQRoute, map[string]interface{}{
+				"topic":      message.Topic,
+				"event_id":   message.Event.ID,
+				"event_type": string(message.Event.Type),
+				"error":      err.Error(),
+			})
+		}
+		return nil
+	}
+	return err
+}
+
+// newRoute applies options to a handler and composes its middleware; callers hold mu
+// or own the registry
+func (r *HandlerRegistry) newRoute(handler MessageHandler, options []HandlerOption) *handlerRoute {
+	route := &handlerRoute{handler: handler}
+	for _, option := range options {
+		option(route)
+	}
+	route.composed = r.compose(route)
+	return route
+}
+
+// compose wraps a route's handler in its own middleware, then the shared middleware
+func (r *HandlerRegistry) compose(route *handlerRoute) MessageHandler {
+	handler := route.handler
+	for i := len(route.middleware) - 1; i >= 0; i-- {
+		handler = route.middleware[i](handler)
+	}
+	for i := len(r.shared) - 1; i >= 0; i-- {
+		handler = r.shared[i](handler)
+	}
+	return handler
+}
@@ -0,0 +1,85 @@
+package kafka
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+func TestHandlerRegistryDispatch(t *testing.T) {
+	var calls []string
+	record := func(name string, err error) MessageHandler {
+		return func(message *Message) error {
+			calls = append(calls, name+":"+string(message.Event.Type))
+			return err
+		}
+	}
+	tag := func(name string) HandlerMiddleware {
+		return func(next MessageHandler) MessageHandler {
+			return func(message *Message) error {
+				calls = append(calls, name)
+				return next(message)
+			}
+		}
+	}
+	message := func(eventType models.EventType) *Message {
+		return &Message{Event: &models.AnalyticsEvent{Type: eventType}}
+	}
+	failure := errors.New("failed")
+
+	registry := NewHandlerRegistry(record("fallback", nil))
+	registry.Register(models.Click, record("clicks", failure), WithMiddleware(tag("outer"), tag("inner")))
+	registry.Register("signup", record("signups", failure), WithErrorPolicy(ErrorDrop))
+	registry.Use(tag("shared"))
+
+	if err := registry.Dispatch(message(models.Click)); err != failure {
+		t.Errorf("expected the retry policy to return the error, got %v", err)
+	}
+	if err := registry.Dispatch(message("signup")); err != nil {
+		t.Errorf("expected the drop policy to swallow the error, got %v", err)
+	}
+	registry.Dispatch(message(models.PageView))
+
+	want := []string{"shared", "outer", "inner", "clicks:click", "shared", "signups:signup", "shared", "fallback:page_view"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("expected calls %v, got %v", want, calls)
+	}
+	if got := registry.Registered(); !reflect.DeepEqual(got, []models.EventType{models.Click, "signup"}) {
+		t.Errorf("unexpected registered types %v", got)
+	}
+
+	registry.Unregister(models.Click)
+	calls = nil
+	registry.Dispatch(message(models.Click))
+	if strings.Join(calls, ",") != "shared,fallback:click" {
+		t.Errorf("expected unregistered types to reach the fallback, got %v", calls)
+	}
+}
+
+func TestDroppedMessagesAreReported(t *testing.T) {
+	var reported []string
+	hook := func(operation string, details map[string]interface{}) {
+		reported = append(reported, operation+":"+details["event_id"].(string))
+	}
+	failure := errors.New("failed")
+	failing := func(*Message) error { return failure }
+
+	registry := NewHandlerRegistry(failing)
+	registry.Register("signup", failing, WithErrorPolicy(ErrorDrop))
+	registry.SetOperationalHook(hook)
+	registry.Dispatch(&Message{Event: &models.AnalyticsEvent{ID: "e1", Type: "signup"}})
+	registry.Dispatch(&Message{Event: &models.AnalyticsEvent{ID: "e2", Type: models.Click}})
+
+	// Messages the consumer gives up on after retrying are dropped as well
+	consumer := &Consumer{}
+	consumer.SetOperationalHook(hook)
+	consumer.handle(&Message{Event: &models.AnalyticsEvent{ID: "e3", Type: models.Click}}, registry.Dispatch, 2)
+
+	want := []string{"dlq_route:e1", "processing_failed:e3", "dlq_route:e3"}
+	if !reflect.DeepEqual(reported, want) {
+		t.Errorf("expected %v, got %v", want, reported)
+	}
+}
@@ -0,0 +1,154 @@
+package kafka
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+	"github.com/segmentio/kafka-go"
+)
+
+// Header names set on produced event messages
+const (
+	HeaderEventType       = "event-type"
+	HeaderSchemaVersion   = "schema-version"
+	HeaderContentType     = "content-type"
+	HeaderContentEncoding = "content-encoding"
+	HeaderTraceParent     = "traceparent" // W3C trace context
+	HeaderTraceState      = "tracestate"
+	HeaderTenantID        = "tenant-id"
+	HeaderIdempotencyKey  = "idempotency-key" // Set by idempotent producers, see Producer.SetIdempotent
+)
+
+// Event metadata keys copied into headers by the default codec
+const (
+	MetadataTraceParent = "traceparent"
+	MetadataTraceState  = "tracestate"
+	MetadataTenantID    = "tenant_id"
+)
+
+// EventSchemaVersion is the version of the AnalyticsEvent JSON payload written by this
+// build. Consumers skip messages with a newer version instead of misreading them.
+const EventSchemaVersion = 1
+
+// Headers holds message headers by name; repeated names keep the last value
+type Headers map[string]string
+
+// ParseHeaders reads Kafka message headers
+func ParseHeaders(headers []kafka.Header) Headers {
+	parsed := make(Headers, len(headers))
+	for _, header := range headers {
+		parsed[header.Key] = string(header.Value)
+	}
+	return parsed
+}
+
+// EventType returns the event type header, empty if the producer did not set it
+func (h Headers) EventType() models.EventType {
+	return models.EventType(h[HeaderEventType])
+}
+
+// SchemaVersion returns the payload schema version; messages without the header are version 1
+func (h Headers) SchemaVersion() (int, error) {
+	version, ok := h[HeaderSchemaVersion]
+	if !ok {
+		return 1, nil
+	}
+	return strconv.Atoi(version)
+}
+
+// TenantID returns the tenant header, empty if unset
+func (h Headers) TenantID() string {
+	return h[HeaderTenantID]
+}
+
+// HeaderEncoder adds headers describing an event to its outgoing message
+type HeaderEncoder interface {
+	EncodeHeaders(event *models.AnalyticsEvent, headers Headers)
+}
+
+// HeaderEncoderFunc adapts a function to a HeaderEncoder
+type HeaderEncoderFunc func(event *models.AnalyticsEvent, headers Headers)
+
+// EncodeHeaders calls f
+func (f HeaderEncoderFunc) EncodeHeaders(event *models.AnalyticsEvent, headers Headers) {
+	f(event, headers)
+}
+
+// HeaderCodec builds message headers for events from a chain of encoders; later
+// encoders can overwrite headers set by earlier ones
+type HeaderCodec struct {
+	encoders []HeaderEncoder
+}
+
+// NewHeaderCodec creates a codec running the given encoders in order
+func NewHeaderCodec(encoders ...HeaderEncoder) *HeaderCodec {
+	return &HeaderCodec{encoders: encoders}
+}
+
+// DefaultHeaderCodec sets the event type, schema version and content headers, and copies
+// trace context and tenant ID from event metadata when present
+func DefaultHeaderCodec() *HeaderCodec {
+	return NewHeaderCodec(HeaderEncoderFunc(encodeStandardHeaders), HeaderEncoderFunc(encodeMetadataHeaders))
+}
+
+// Register appends an encoder. It must be called before the codec is used.
+func (c *HeaderCodec) Register(encoder HeaderEncoder) {
+	c.encoders = append(c.encoders, encoder)
+}
+
+// Encode returns the headers for an event, sorted by name
+func (c *HeaderCodec) Encode(event *models.AnalyticsEvent) []kafka.Header {
+	headers := make(Headers)
+	for _, encoder := range c.encoders {
+		encoder.EncodeHeaders(event, headers)
+	}
+
+	names := make([]string, 0, len(headers))
+	for name, value := range headers {
+		if value != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	result := make([]kafka.Header, len(names))
+	for i, name := range names {
+		result[i] = kafka.Header{Key: name, Value: []byte(headers[name])}
+	}
+	return result
+}
+
+// encodeStandardHeaders describes the payload
+func encodeStandardHeaders(event *models.AnalyticsEvent, headers Headers) {
+	headers[HeaderEventType] = string(event.Type)
+	headers[HeaderSchemaVersion] = strconv.Itoa(EventSchemaVersion)
+	headers[HeaderContentType] = "application/json"
+	headers[HeaderContentEncoding] = "identity"
+}
+
+// encodeMetadataHeaders copies trace context and tenant from event metadata
+func encodeMetadataHeaders(event *models.AnalyticsEvent, headers Headers) {
+	for key, header := range map[string]string{
+		MetadataTraceParent: HeaderTraceParent,
+		MetadataTraceState:  HeaderTraceState,
+		MetadataTenantID:    HeaderTenantID,
+	} {
+		if value, ok := event.Metadata[key].(string); ok {
+			headers[header] = value
+		}
+	}
+}
+
+// EventTypeFilter returns a header filter accepting only the given event types.
+// Messages without an event-type header are accepted so they can be decoded.
+func EventTypeFilter(types []string) func(Headers) bool {
+	allowed := make(map[models.EventType]bool, len(types))
+	for _, eventType := range types {
+		allowed[models.EventType(eventType)] = true
+	}
+	return func(headers Headers) bool {
+		eventType := headers.EventType()
+		return eventType == "" || allowed[eventType]
+	}
+}
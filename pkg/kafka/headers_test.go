@@ -0,0 +1,63 @@
+package kafka
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/errs"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+	"github.com/segmentio/kafka-go"
+)
+
+func TestHeaderCodecRoundTrip(t *testing.T) {
+	codec := DefaultHeaderCodec()
+	codec.Register(HeaderEncoderFunc(func(event *models.AnalyticsEvent, headers Headers) {
+		headers["region"] = "eu"
+	}))
+
+	event := &models.AnalyticsEvent{
+		ID:   "evt-1",
+		Type: models.Click,
+		Metadata: map[string]interface{}{
+			MetadataTraceParent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			MetadataTenantID:    "acme",
+		},
+	}
+	headers := ParseHeaders(codec.Encode(event))
+
+	if headers.EventType() != models.Click {
+		t.Errorf("Expected click event type, got %q", headers.EventType())
+	}
+	if version, err := headers.SchemaVersion(); err != nil || version != EventSchemaVersion {
+		t.Errorf("Expected schema version %d, got %d (%v)", EventSchemaVersion, version, err)
+	}
+	if headers.TenantID() != "acme" || headers[HeaderTraceParent] == "" || headers["region"] != "eu" {
+		t.Errorf("Unexpected headers: %v", headers)
+	}
+	if _, ok := headers[HeaderTraceState]; ok {
+		t.Error("Expected unset metadata to produce no header")
+	}
+}
+
+func TestDecodeMessageHeaders(t *testing.T) {
+	value := []byte(`{"id":"evt-1","type":"page_view"}`)
+
+	// Messages from older producers carry no headers
+	message, err := DecodeMessage(kafka.Message{Value: value})
+	if err != nil || message.Event.ID != "evt-1" {
+		t.Fatalf("Expected headerless message to decode, got %+v (%v)", message, err)
+	}
+
+	newer := kafka.Message{Value: value, Headers: []kafka.Header{{Key: HeaderSchemaVersion, Value: []byte("2")}}}
+	if _, err := DecodeMessage(newer); !errors.Is(err, errs.ErrInvalidEvent) {
+		t.Errorf("Expected a newer schema version to be rejected as invalid, got %v", err)
+	}
+
+	filter := EventTypeFilter([]string{"click"})
+	if filter(Headers{HeaderEventType: "page_view"}) {
+		t.Error("Expected page_view to be filtered out")
+	}
+	if !filter(Headers{HeaderEventType: "click"}) || !filter(Headers{}) {
+		t.Error("Expected click and headerless messages to pass the filter")
+	}
+}
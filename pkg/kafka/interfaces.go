@@ -0,0 +1,52 @@
+package kafka
+
+import (
+	"context"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+// EventProducer publishes events to Kafka. It is implemented by Producer, and by the
+// mocks in package kafkatest so services can be tested without a broker.
+type EventProducer interface {
+	// EventKey returns the message key for an event
+	EventKey(event *models.AnalyticsEvent) string
+
+	// SendEvent sends an event to the producer's default topic
+	SendEvent(ctx context.Context, key string, value interface{}) error
+
+	// SendToTopic sends an event to the given topic
+	SendToTopic(ctx context.Context, topic, key string, value interface{}) error
+
+	// WriteStatus reports the outcome of recent writes
+	WriteStatus() WriteStatus
+
+	// Ping checks that a broker is reachable
+	Ping(ctx context.Context) error
+
+	Close() error
+}
+
+// EventConsumer reads events from Kafka. It is implemented by Consumer, and by the
+// mocks in package kafkatest.
+type EventConsumer interface {
+	// Topics returns the topics being consumed
+	Topics() []string
+
+	// ConsumeMessages passes each message to handler until ctx is cancelled or the
+	// consumer is shut down
+	ConsumeMessages(ctx context.Context, handler func(*Message) error) error
+
+	// Shutdown stops consuming once in-flight messages are handled, then closes the consumer
+	Shutdown(ctx context.Context) error
+
+	// Lag reports how far the consumer group is behind on each partition
+	Lag(ctx context.Context) ([]PartitionLag, error)
+
+	Close() error
+}
+
+var (
+	_ EventProducer = (*Producer)(nil)
+	_ EventConsumer = (*Consumer)(nil)
+)
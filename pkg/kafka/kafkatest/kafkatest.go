@@ -0,0 +1,284 @@
+// Package kafkatest provides in-memory implementations of kafka.EventProducer and
+// kafka.EventConsumer for testing services without a broker
+package kafkatest
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/kafka"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+// Sent is a message written to a Producer
+type Sent struct {
+	Topic string
+	Key   string
+	Value interface{}
+}
+
+// Producer records sent messages in memory
+type Producer struct {
+	Topic       string            // Default topic of SendEvent
+	KeyStrategy kafka.KeyStrategy // Defaults to keying by event ID
+
+	mu      sync.Mutex
+	sent    []Sent
+	sendErr error
+	pingErr error
+	status  kafka.WriteStatus
+	closed  bool
+}
+
+// NewProducer creates a mock producer whose default topic is topic
+func NewProducer(topic string) *Producer {
+	return &Producer{Topic: topic, KeyStrategy: kafka.KeyByEventID}
+}
+
+// FailSends makes subsequent sends fail with err; nil makes them succeed again
+func (p *Producer) FailSends(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sendErr = err
+}
+
+// FailPings makes subsequent pings fail with err; nil makes them succeed again
+func (p *Producer) FailPings(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pingErr = err
+}
+
+// Sent returns the messages written so far, oldest first
+func (p *Producer) Sent() []Sent {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Sent(nil), p.sent...)
+}
+
+// Closed reports whether Close was called
+func (p *Producer) Closed() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.closed
+}
+
+// EventKey returns the message key for an event under the producer's key strategy
+func (p *Producer) EventKey(event *models.AnalyticsEvent) string {
+	return p.KeyStrategy.Key(event)
+}
+
+// SendEvent records a message to the default topic
+func (p *Producer) SendEvent(ctx context.Context, key string, value interface{}) error {
+	return p.SendToTopic(ctx, p.Topic, key, value)
+}
+
+// SendToTopic records a message, or fails as set by FailSends
+func (p *Producer) SendToTopic(ctx context.Context, topic, key string, value interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return errors.New("producer is closed")
+	}
+	if p.sendErr != nil {
+		p.status.LastFailure = time.Now()
+		p.status.LastError = p.sendErr.Error()
+		return p.sendErr
+	}
+	p.status.LastSuccess = time.Now()
+	p.sent = append(p.sent, Sent{Topic: topic, Key: key, Value: value})
+	return nil
+}
+
+// WriteStatus returns the outcome of recent sends
+func (p *Producer) WriteStatus() kafka.WriteStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.status
+}
+
+// Ping succeeds unless set to fail by FailPings
+func (p *Producer) Ping(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.pingErr
+}
+
+// Close marks the producer closed; later sends fail
+func (p *Producer) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	return nil
+}
+
+// Result is the outcome of handling a consumed message
+type Result struct {
+	Message *kafka.Message
+	Err     error
+}
+
+// Consumer delivers published messages to the handler in order, one at a time
+type Consumer struct {
+	topics []string
+
+	mu       sync.Mutex
+	queue    []*kafka.Message
+	offsets  map[string]int64
+	results  []Result
+	running  bool
+	stopped  bool
+	wake     chan struct{} // Signalled when messages are queued or the consumer stops
+	finished chan struct{} // Closed when a stopped consume loop returns
+}
+
+// NewConsumer creates a mock consumer of the given topics; messages published without
+// a topic are assigned the first
+func NewConsumer(topics ...string) *Consumer {
+	return &Consumer{
+		topics:   topics,
+		offsets:  make(map[string]int64),
+		wake:     make(chan struct{}, 1),
+		finished: make(chan struct{}),
+	}
+}
+
+// Publish queues events for delivery, as messages on partition 0 with increasing offsets
+func (c *Consumer) Publish(events ...*models.AnalyticsEvent) {
+	for _, event := range events {
+		c.PublishMessage(&kafka.Message{Key: []byte(event.ID), Event: event})
+	}
+}
+
+// PublishMessage queues a message for delivery, filling in its topic and offset when unset
+func (c *Consumer) PublishMessage(msg *kafka.Message) {
+	c.mu.Lock()
+	if msg.Topic == "" && len(c.topics) > 0 {
+		msg.Topic = c.topics[0]
+	}
+	if msg.Offset == 0 {
+		msg.Offset = c.offsets[msg.Topic]
+	}
+	c.offsets[msg.Topic] = msg.Offset + 1
+	if msg.Time.IsZero() {
+		msg.Time = time.Now()
+	}
+	c.queue = append(c.queue, msg)
+	c.mu.Unlock()
+	c.signal()
+}
+
+// Results returns the outcome of every handled message, in delivery order
+func (c *Consumer) Results() []Result {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Result(nil), c.results...)
+}
+
+// Topics returns the consumed topics
+func (c *Consumer) Topics() []string {
+	return append([]string(nil), c.topics...)
+}
+
+// Lag reports the messages still queued as lag on partition 0 of each topic
+func (c *Consumer) Lag(ctx context.Context) ([]kafka.PartitionLag, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	queued := make(map[string]int64)
+	for _, msg := range c.queue {
+		queued[msg.Topic]++
+	}
+	lags := make([]kafka.PartitionLag, 0, len(c.topics))
+	for _, topic := range c.topics {
+		end := c.offsets[topic]
+		lags = append(lags, kafka.PartitionLag{Topic: topic, Committed: end - queued[topic], End: end, Lag: queued[topic]})
+	}
+	return lags, nil
+}
+
+// ConsumeMessages hands queued messages to handler until ctx is cancelled, or until the
+// consumer is shut down and every queued message has been handled. A consumer can only
+// be consumed once.
+func (c *Consumer) ConsumeMessages(ctx context.Context, handler func(*kafka.Message) error) error {
+	c.mu.Lock()
+	if c.running {
+		c.mu.Unlock()
+		return errors.New("consumer is already consuming")
+	}
+	c.running = true
+	c.mu.Unlock()
+	defer close(c.finished)
+	for {
+		c.mu.Lock()
+		if len(c.queue) == 0 {
+			stopped := c.stopped
+			c.mu.Unlock()
+			if stopped {
+				return nil
+			}
+			select {
+			case <-c.wake:
+				continue
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		msg := c.queue[0]
+		c.queue = c.queue[1:]
+		c.mu.Unlock()
+
+		err := handler(msg)
+		c.mu.Lock()
+		c.results = append(c.results, Result{Message: msg, Err: err})
+		c.mu.Unlock()
+	}
+}
+
+// Shutdown stops consuming once the queued messages are handled and, if consuming,
+// waits for the consume loop to return or for ctx to expire
+func (c *Consumer) Shutdown(ctx context.Context) error {
+	c.mu.Lock()
+	c.stopped = true
+	running := c.running
+	c.mu.Unlock()
+	c.signal()
+	if !running {
+		return nil
+	}
+
+	select {
+	case <-c.finished:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops consuming without waiting
+func (c *Consumer) Close() error {
+	c.mu.Lock()
+	c.stopped = true
+	c.mu.Unlock()
+	c.signal()
+	return nil
+}
+
+// signal wakes the consume loop without blocking
+func (c *Consumer) signal() {
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+var (
+	_ kafka.EventProducer = (*Producer)(nil)
+	_ kafka.EventConsumer = (*Consumer)(nil)
+)
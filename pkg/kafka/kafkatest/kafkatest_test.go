@@ -0,0 +1,68 @@
+package kafkatest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/kafka"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+func TestProducerRecordsSends(t *testing.T) {
+	producer := NewProducer("events")
+	ctx := context.Background()
+	event := &models.AnalyticsEvent{ID: "e1"}
+
+	if err := producer.SendEvent(ctx, producer.EventKey(event), event); err != nil {
+		t.Fatalf("SendEvent failed: %v", err)
+	}
+	producer.FailSends(errors.New("down"))
+	if err := producer.SendToTopic(ctx, "other", "k", event); err == nil {
+		t.Error("expected the send to fail")
+	}
+
+	sent := producer.Sent()
+	if len(sent) != 1 || sent[0].Topic != "events" || sent[0].Key != "e1" {
+		t.Errorf("expected one send to the default topic, got %+v", sent)
+	}
+	if status := producer.WriteStatus(); !status.Failing() || status.LastError != "down" {
+		t.Errorf("expected the failed send in the write status, got %+v", status)
+	}
+}
+
+func TestConsumerDeliversInOrder(t *testing.T) {
+	consumer := NewConsumer("events")
+	consumer.Publish(&models.AnalyticsEvent{ID: "e1"}, &models.AnalyticsEvent{ID: "e2"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var handled []string
+	done := make(chan error, 1)
+	go func() {
+		done <- consumer.ConsumeMessages(ctx, func(msg *kafka.Message) error {
+			handled = append(handled, msg.Event.ID)
+			if msg.Event.ID == "e3" {
+				return errors.New("rejected")
+			}
+			return nil
+		})
+	}()
+
+	consumer.Publish(&models.AnalyticsEvent{ID: "e3"})
+	if err := consumer.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("ConsumeMessages failed: %v", err)
+	}
+
+	results := consumer.Results()
+	if len(handled) != 3 || handled[0] != "e1" || handled[2] != "e3" {
+		t.Fatalf("expected every message handled in order, got %v", handled)
+	}
+	if results[2].Err == nil || results[2].Message.Offset != 2 || results[0].Message.Topic != "events" {
+		t.Errorf("unexpected results %+v", results)
+	}
+}
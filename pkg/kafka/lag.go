@@ -0,0 +1,95 @@
+package kafka
+
+import (
+	"context"
+	"sort"
+
+	"github.com/segmentio/kafka-go"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/errs"
+)
+
+// PartitionLag is how far a consumer group is behind the end of a partition
+type PartitionLag struct {
+	Topic     string `json:"topic"`
+	Partition int    `json:"partition"`
+	Committed int64  `json:"committed_offset"` // -1 if the group has not committed to the partition
+	End       int64  `json:"end_offset"`       // Offset the next message will be written at
+	Lag       int64  `json:"lag"`              // Messages written but not yet committed by the group
+}
+
+// GroupLag returns the lag of a consumer group on every partition of the given topics,
+// ordered by topic and partition
+func GroupLag(ctx context.Context, brokers []string, groupID string, topics []string) ([]PartitionLag, error) {
+	partitions := make(map[string][]int, len(topics))
+	ends := make(map[string][]kafka.OffsetRequest, len(topics))
+	for _, topic := range topics {
+		ids, err := topicPartitions(ctx, brokers, topic)
+		if err != nil {
+			return nil, err
+		}
+		partitions[topic] = ids
+		for _, id := range ids {
+			ends[topic] = append(ends[topic], kafka.FirstOffsetOf(id), kafka.LastOffsetOf(id))
+		}
+	}
+
+	client := &kafka.Client{Addr: kafka.TCP(brokers...)}
+	committed, err := client.OffsetFetch(ctx, &kafka.OffsetFetchRequest{GroupID: groupID, Topics: partitions})
+	if err != nil {
+		return nil, errs.Errorf(errs.ErrKafkaUnavailable, "failed to fetch offsets of group %s: %w", groupID, err)
+	}
+	if committed.Error != nil {
+		return nil, errs.Errorf(errs.ErrKafkaUnavailable, "failed to fetch offsets of group %s: %w", groupID, committed.Error)
+	}
+	offsets, err := client.ListOffsets(ctx, &kafka.ListOffsetsRequest{Topics: ends})
+	if err != nil {
+		return nil, errs.Errorf(errs.ErrKafkaUnavailable, "failed to list partition offsets: %w", err)
+	}
+
+	var lags []PartitionLag
+	for topic, fetched := range committed.Topics {
+		bounds := make(map[int]kafka.PartitionOffsets)
+		for _, partition := range offsets.Topics[topic] {
+			if partition.Error != nil {
+				return nil, errs.Errorf(errs.ErrKafkaUnavailable, "failed to list offsets of %s/%d: %w", topic, partition.Partition, partition.Error)
+			}
+			bounds[partition.Partition] = partition
+		}
+		for _, partition := range fetched {
+			if partition.Error != nil {
+				return nil, errs.Errorf(errs.ErrKafkaUnavailable, "failed to fetch offset of %s/%d: %w", topic, partition.Partition, partition.Error)
+			}
+			bound := bounds[partition.Partition]
+			lags = append(lags, PartitionLag{
+				Topic:     topic,
+				Partition: partition.Partition,
+				Committed: partition.CommittedOffset,
+				End:       bound.LastOffset,
+				Lag:       partitionLag(partition.CommittedOffset, bound.FirstOffset, bound.LastOffset),
+			})
+		}
+	}
+
+	sort.Slice(lags, func(i, j int) bool {
+		if lags[i].Topic != lags[j].Topic {
+			return lags[i].Topic < lags[j].Topic
+		}
+		return lags[i].Partition < lags[j].Partition
+	})
+	return lags, nil
+}
+
+// partitionLag counts the messages after the committed offset; without a commit every
+// retained message is outstanding
+func partitionLag(committed, first, end int64) int64 {
+	if committed < 0 {
+		committed = first
+	}
+	return max(0, end-max(committed, first))
+}
+
+// Lag returns the consumer group's lag on the consumed topics
+func (c *Consumer) Lag(ctx context.Context) ([]PartitionLag, error) {
+	return GroupLag(ctx, c.brokers, c.groupID, c.Topics())
+}
@@ -0,0 +1,22 @@
+package kafka
+
+import "testing"
+
+func TestPartitionLag(t *testing.T) {
+	tests := []struct {
+		name                  string
+		committed, first, end int64
+		want                  int64
+	}{
+		{"caught up", 100, 0, 100, 0},
+		{"behind", 40, 0, 100, 60},
+		{"no commit", -1, 20, 100, 80},
+		{"commit before retention", 10, 20, 100, 80},
+		{"empty partition", -1, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		if got := partitionLag(tt.committed, tt.first, tt.end); got != tt.want {
+			t.Errorf("%s: expected lag %d, got %d", tt.name, tt.want, got)
+		}
+	}
+}
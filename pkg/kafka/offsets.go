@@ -0,0 +1,179 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/errs"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/logging"
+)
+
+// Range selects the messages of a topic to read. Offsets apply to every selected
+// partition; times take precedence over offsets when set.
+type Range struct {
+	Partition  int       // Partition to read, or -1 for all partitions
+	FromOffset int64     // First offset to read (inclusive); clamped to the oldest retained offset
+	ToOffset   int64     // Offset to stop at (exclusive), or -1 for the end of the partition
+	FromTime   time.Time // Start at the first message at or after this time
+	ToTime     time.Time // Stop at the first message at or after this time
+}
+
+// RangeStats summarises a ReadRange call
+type RangeStats struct {
+	Messages      int64 // Messages read in range
+	DecodeErrors  int64 // Messages that were not valid events
+	HandlerErrors int64 // Events the handler failed to process
+}
+
+// ReadRange reads the selected messages of topic outside any consumer group, so no
+// offsets are committed, and passes each decoded event to handler. Partitions are
+// read one after another, each in offset order, up to the end offset at the time
+// ReadRange was called.
+func ReadRange(ctx context.Context, brokers []string, topic string, r Range, handler func(*Message) error) (RangeStats, error) {
+	var stats RangeStats
+
+	partitions, err := lookupPartitions(ctx, brokers, topic)
+	if err != nil {
+		return stats, err
+	}
+	if r.Partition >= 0 {
+		i := sort.Search(len(partitions), func(i int) bool { return partitions[i].ID >= r.Partition })
+		if i == len(partitions) || partitions[i].ID != r.Partition {
+			return stats, errs.Errorf(errs.ErrNotFound, "partition %d of %s", r.Partition, topic)
+		}
+		partitions = partitions[i : i+1]
+	}
+
+	for _, partition := range partitions {
+		if err := readPartitionRange(ctx, brokers, topic, partition, r, handler, &stats); err != nil {
+			return stats, err
+		}
+	}
+	return stats, nil
+}
+
+// topicPartitions lists the partition IDs of a topic
+func topicPartitions(ctx context.Context, brokers []string, topic string) ([]int, error) {
+	partitions, err := lookupPartitions(ctx, brokers, topic)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int, len(partitions))
+	for i, partition := range partitions {
+		ids[i] = partition.ID
+	}
+	return ids, nil
+}
+
+// lookupPartitions describes the partitions of a topic, with their leaders, sorted by
+// ID. Each broker is asked in turn until one answers.
+func lookupPartitions(ctx context.Context, brokers []string, topic string) ([]kafka.Partition, error) {
+	var lastErr error
+	for _, broker := range brokers {
+		conn, err := kafka.DialContext(ctx, "tcp", broker)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		partitions, err := conn.ReadPartitions(topic)
+		conn.Close()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		sort.Slice(partitions, func(i, j int) bool { return partitions[i].ID < partitions[j].ID })
+		return partitions, nil
+	}
+	return nil, errs.Errorf(errs.ErrKafkaUnavailable, "failed to list partitions of %s: %w", topic, lastErr)
+}
+
+// partitionBounds resolves the range to concrete start and end offsets of a partition,
+// asking the partition's leader
+func partitionBounds(ctx context.Context, partition kafka.Partition, r Range) (start, end int64, err error) {
+	conn, err := kafka.DialPartition(ctx, "tcp", "", partition)
+	if err != nil {
+		return 0, 0, errs.Errorf(errs.ErrKafkaUnavailable, "failed to connect to partition %d leader: %w", partition.ID, err)
+	}
+	defer conn.Close()
+
+	first, last, err := conn.ReadOffsets()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read offsets of partition %d: %w", partition.ID, err)
+	}
+
+	start = max(r.FromOffset, first)
+	if !r.FromTime.IsZero() {
+		if start, err = conn.ReadOffset(r.FromTime); err != nil {
+			return 0, 0, fmt.Errorf("failed to find offset for %s in partition %d: %w", r.FromTime.Format(time.RFC3339), partition.ID, err)
+		}
+		// No message at or after FromTime: the broker answers -1, which a reader
+		// would take as "wait for new messages"
+		if start < 0 {
+			start = last
+		}
+		start = max(start, first)
+	}
+
+	end = last
+	if r.ToOffset >= 0 && r.ToOffset < end {
+		end = r.ToOffset
+	}
+	return start, end, nil
+}
+
+// readPartitionRange reads one partition between its resolved bounds
+func readPartitionRange(ctx context.Context, brokers []string, topic string, p kafka.Partition, r Range, handler func(*Message) error, stats *RangeStats) error {
+	partition := p.ID
+	start, end, err := partitionBounds(ctx, p, r)
+	if err != nil {
+		return err
+	}
+	if start >= end {
+		logging.Info("Nothing to read", "topic", topic, "partition", partition)
+		return nil
+	}
+	logging.Info("Reading partition", "topic", topic, "partition", partition, "from_offset", start, "to_offset", end-1)
+
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:   brokers,
+		Topic:     topic,
+		Partition: partition,
+		MinBytes:  10e3, // 10KB
+		MaxBytes:  10e6, // 10MB
+	})
+	defer reader.Close()
+
+	if err := reader.SetOffset(start); err != nil {
+		return fmt.Errorf("failed to seek partition %d: %w", partition, err)
+	}
+
+	for {
+		msg, err := reader.FetchMessage(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to read partition %d: %w", partition, err)
+		}
+		if msg.Offset >= end || (!r.ToTime.IsZero() && !msg.Time.Before(r.ToTime)) {
+			return nil
+		}
+		stats.Messages++
+
+		message, err := DecodeMessage(msg)
+		if err != nil {
+			stats.DecodeErrors++
+			logging.Warn("Skipping undecodable message", "topic", topic, "partition", partition, "offset", msg.Offset, "error", err)
+		} else if err := handler(message); err != nil {
+			stats.HandlerErrors++
+			logging.Error("Failed to replay event", "event_id", message.Event.ID, "error", err)
+		}
+
+		// The end offset is exclusive, so the last message of the range ends the read
+		if msg.Offset+1 >= end {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package kafka
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+	"github.com/segmentio/kafka-go"
+)
+
+// KeyStrategy selects the event field used as the Kafka message key. Events with
+// the same key land on the same partition and are consumed in order.
+type KeyStrategy string
+
+const (
+	// KeyByEventID spreads events evenly but gives no ordering across events
+	KeyByEventID KeyStrategy = "event_id"
+
+	// KeyByUser keeps each user's events in order
+	KeyByUser KeyStrategy = "user_id"
+
+	// KeyBySession keeps each session's events in order
+	KeyBySession KeyStrategy = "session_id"
+
+	// KeyBySite keeps each site's events in order, using the site_id metadata or the URL hostname
+	KeyBySite KeyStrategy = "site_id"
+)
+
+// Balancer names accepted by NewBalancer
+const (
+	BalancerLeastBytes = "least_bytes"
+	BalancerHash       = "hash"
+	BalancerRoundRobin = "round_robin"
+	BalancerMurmur2    = "murmur2"
+)
+
+// ParseKeyStrategy validates a key strategy name; an empty name means KeyByEventID
+func ParseKeyStrategy(name string) (KeyStrategy, error) {
+	switch strategy := KeyStrategy(strings.ToLower(strings.TrimSpace(name))); strategy {
+	case "":
+		return KeyByEventID, nil
+	case KeyByEventID, KeyByUser, KeyBySession, KeyBySite:
+		return strategy, nil
+	default:
+		return "", fmt.Errorf("unknown partition key strategy %q", name)
+	}
+}
+
+// Key returns the message key for an event, falling back to the event ID when the
+// selected field is empty so keyless events still spread across partitions
+func (k KeyStrategy) Key(event *models.AnalyticsEvent) string {
+	var key string
+	switch k {
+	case KeyByUser:
+		key = event.UserID
+	case KeyBySession:
+		key = event.SessionID
+	case KeyBySite:
+		key = eventSite(event)
+	}
+
+	if key == "" {
+		return event.ID
+	}
+	return key
+}
+
+// eventSite returns the site_id metadata or the normalized URL hostname
+func eventSite(event *models.AnalyticsEvent) string {
+	if siteID, ok := event.Metadata["site_id"].(string); ok && siteID != "" {
+		return siteID
+	}
+
+	u, err := url.Parse(event.URL)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
+
+// NewBalancer returns the partition balancer with the given name; an empty name means least_bytes.
+// least_bytes and round_robin ignore message keys, so only hash and murmur2 preserve per-key ordering.
+// murmur2 matches the default partitioner of the Java client and librdkafka.
+func NewBalancer(name string) (kafka.Balancer, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", BalancerLeastBytes:
+		return &kafka.LeastBytes{}, nil
+	case BalancerHash:
+		return &kafka.Hash{}, nil
+	case BalancerRoundRobin:
+		return &kafka.RoundRobin{}, nil
+	case BalancerMurmur2:
+		return kafka.Murmur2Balancer{}, nil
+	default:
+		return nil, fmt.Errorf("unknown partition balancer %q", name)
+	}
+}
@@ -0,0 +1,64 @@
+package kafka
+
+import (
+	"testing"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+	"github.com/segmentio/kafka-go"
+)
+
+func TestKeyStrategyKey(t *testing.T) {
+	event := &models.AnalyticsEvent{
+		ID:        "evt-1",
+		UserID:    "user-1",
+		SessionID: "session-1",
+		URL:       "https://www.Example.com:8443/pricing",
+	}
+
+	tests := []struct {
+		strategy KeyStrategy
+		event    *models.AnalyticsEvent
+		want     string
+	}{
+		{KeyByEventID, event, "evt-1"},
+		{KeyByUser, event, "user-1"},
+		{KeyBySession, event, "session-1"},
+		{KeyBySite, event, "example.com"},
+		{KeyBySite, &models.AnalyticsEvent{ID: "evt-2", Metadata: map[string]interface{}{"site_id": "site-9"}}, "site-9"},
+		{KeyByUser, &models.AnalyticsEvent{ID: "evt-3"}, "evt-3"}, // Falls back to the event ID
+	}
+
+	for _, tt := range tests {
+		if got := tt.strategy.Key(tt.event); got != tt.want {
+			t.Errorf("%s: got key %q, want %q", tt.strategy, got, tt.want)
+		}
+	}
+}
+
+func TestParsePartitioning(t *testing.T) {
+	if strategy, err := ParseKeyStrategy(" User_ID "); err != nil || strategy != KeyByUser {
+		t.Errorf("Expected user_id strategy, got %q (%v)", strategy, err)
+	}
+	if _, err := ParseKeyStrategy("tenant"); err == nil {
+		t.Error("Expected unknown key strategy to be rejected")
+	}
+	if _, err := NewBalancer("sticky"); err == nil {
+		t.Error("Expected unknown balancer to be rejected")
+	}
+
+	// Keyed balancers send the same key to the same partition every time
+	partitions := []int{0, 1, 2, 3, 4, 5, 6, 7}
+	for _, name := range []string{BalancerHash, BalancerMurmur2} {
+		balancer, err := NewBalancer(name)
+		if err != nil {
+			t.Fatalf("NewBalancer(%s) failed: %v", name, err)
+		}
+		msg := kafka.Message{Key: []byte("user-1")}
+		first := balancer.Balance(msg, partitions...)
+		for i := 0; i < 10; i++ {
+			if got := balancer.Balance(msg, partitions...); got != first {
+				t.Fatalf("%s: key moved from partition %d to %d", name, first, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package kafka
+
+import (
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// workerQueueSize bounds each worker's backlog; a full queue blocks fetching
+const workerQueueSize = 64
+
+// job is a fetched message waiting to be handled and committed
+type job struct {
+	reader  *kafka.Reader
+	gen     *kafka.Generation // set when consuming through group generations
+	msg     kafka.Message
+	message *Message // nil when the message could not be decoded
+
+	// inflight, when set, is released once the job is handled and committed
+	inflight *sync.WaitGroup
+}
+
+// orderingKey returns the key whose events must be handled in order
+func (j *job) orderingKey() string {
+	if j.message != nil && j.message.Event != nil {
+		if j.message.Event.UserID != "" {
+			return j.message.Event.UserID
+		}
+		if j.message.Event.SessionID != "" {
+			return j.message.Event.SessionID
+		}
+	}
+	return string(j.msg.Key)
+}
+
+// workerPool handles jobs concurrently, sending all jobs with the same ordering key
+// to the same worker so they are processed in fetch order
+type workerPool struct {
+	queues  []chan *job
+	wg      sync.WaitGroup
+	pending atomic.Int64 // Submitted jobs not yet processed
+}
+
+// newWorkerPool starts workers that pass each job to process
+func newWorkerPool(workers int, process func(*job)) *workerPool {
+	if workers < 1 {
+		workers = 1
+	}
+
+	p := &workerPool{queues: make([]chan *job, workers)}
+	for i := range p.queues {
+		queue := make(chan *job, workerQueueSize)
+		p.queues[i] = queue
+
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			for j := range queue {
+				process(j)
+				p.pending.Add(-1)
+			}
+		}()
+	}
+	return p
+}
+
+// submit queues a job on the worker owning its key, blocking while that worker is full
+func (p *workerPool) submit(j *job) {
+	p.pending.Add(1)
+	h := fnv.New32a()
+	h.Write([]byte(j.orderingKey()))
+	p.queues[h.Sum32()%uint32(len(p.queues))] <- j
+}
+
+// depth returns how many submitted jobs are queued or being processed
+func (p *workerPool) depth() int {
+	return int(p.pending.Load())
+}
+
+// stop waits for all queued jobs to be processed and stops the workers
+func (p *workerPool) stop() {
+	for _, queue := range p.queues {
+		close(queue)
+	}
+	p.wg.Wait()
+}
+
+// partitionKey identifies a partition as fetched through a specific reader
+type partitionKey struct {
+	reader    *kafka.Reader
+	topic     string
+	partition int
+}
+
+// partitionOffsets holds the in-flight offsets of one partition
+type partitionOffsets struct {
+	pending []int64        // fetched offsets in fetch order
+	done    map[int64]*job // completed jobs not yet committed
+	mu      sync.Mutex
+}
+
+// offsetTracker commits offsets in order even though jobs complete out of order:
+// a partition's offset only advances once every earlier message has been handled
+type offsetTracker struct {
+	partitions map[partitionKey]*partitionOffsets
+	commit     func(*job)
+	mu         sync.Mutex
+}
+
+// newOffsetTracker creates a tracker that calls commit with the latest committable job
+func newOffsetTracker(commit func(*job)) *offsetTracker {
+	return &offsetTracker{
+		partitions: make(map[partitionKey]*partitionOffsets),
+		commit:     commit,
+	}
+}
+
+// partition returns the offsets for the job's partition, creating them if needed
+func (t *offsetTracker) partition(j *job) *partitionOffsets {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := partitionKey{reader: j.reader, topic: j.msg.Topic, partition: j.msg.Partition}
+	p, ok := t.partitions[key]
+	if !ok {
+		p = &partitionOffsets{done: make(map[int64]*job)}
+		t.partitions[key] = p
+	}
+	return p
+}
+
+// track records a fetched job; it must be called in fetch order
+func (t *offsetTracker) track(j *job) {
+	p := t.partition(j)
+	p.mu.Lock()
+	p.pending = append(p.pending, j.msg.Offset)
+	p.mu.Unlock()
+}
+
+// complete marks a job as handled and commits the highest contiguous completed offset
+func (t *offsetTracker) complete(j *job) {
+	p := t.partition(j)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.done[j.msg.Offset] = j
+
+	var last *job
+	for len(p.pending) > 0 {
+		next, ok := p.done[p.pending[0]]
+		if !ok {
+			break
+		}
+		delete(p.done, p.pending[0])
+		p.pending = p.pending[1:]
+		last = next
+	}
+
+	// Committing under the partition lock keeps commits for a partition in order
+	if last != nil {
+		t.commit(last)
+	}
+}
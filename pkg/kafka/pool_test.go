@@ -0,0 +1,109 @@
+package kafka
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+	"github.com/segmentio/kafka-go"
+)
+
+func newTestJob(partition int, offset int64, userID string) *job {
+	return &job{
+		msg: kafka.Message{Topic: "events", Partition: partition, Offset: offset},
+		message: &Message{
+			Event: &models.AnalyticsEvent{UserID: userID},
+		},
+	}
+}
+
+func TestOffsetTrackerCommitsContiguousOffsets(t *testing.T) {
+	var committed []int64
+	tracker := newOffsetTracker(func(j *job) {
+		committed = append(committed, j.msg.Offset)
+	})
+
+	jobs := []*job{newTestJob(0, 10, "a"), newTestJob(0, 11, "b"), newTestJob(0, 12, "c")}
+	for _, j := range jobs {
+		tracker.track(j)
+	}
+
+	// Later offsets finishing first must not be committed past the unfinished one
+	tracker.complete(jobs[2])
+	tracker.complete(jobs[1])
+	if len(committed) != 0 {
+		t.Fatalf("committed %v before offset 10 completed", committed)
+	}
+
+	tracker.complete(jobs[0])
+	if len(committed) != 1 || committed[0] != 12 {
+		t.Fatalf("expected a single commit of offset 12, got %v", committed)
+	}
+}
+
+func TestOffsetTrackerPartitionsAreIndependent(t *testing.T) {
+	committed := make(map[int]int64)
+	tracker := newOffsetTracker(func(j *job) {
+		committed[j.msg.Partition] = j.msg.Offset
+	})
+
+	first, second := newTestJob(0, 5, "a"), newTestJob(1, 7, "b")
+	tracker.track(first)
+	tracker.track(second)
+	tracker.complete(second)
+
+	if committed[1] != 7 {
+		t.Errorf("expected partition 1 committed at 7, got %d", committed[1])
+	}
+	if _, ok := committed[0]; ok {
+		t.Errorf("partition 0 should not be committed yet")
+	}
+}
+
+func TestWorkerPoolPreservesPerKeyOrder(t *testing.T) {
+	var mu sync.Mutex
+	seen := make(map[string][]int64)
+
+	pool := newWorkerPool(4, func(j *job) {
+		mu.Lock()
+		defer mu.Unlock()
+		seen[j.message.Event.UserID] = append(seen[j.message.Event.UserID], j.msg.Offset)
+	})
+
+	users := []string{"alice", "bob", "carol", "dave", "erin"}
+	for offset := int64(0); offset < 500; offset++ {
+		pool.submit(newTestJob(0, offset, users[offset%int64(len(users))]))
+	}
+	pool.stop()
+
+	for user, offsets := range seen {
+		if len(offsets) != 100 {
+			t.Errorf("%s: expected 100 events, got %d", user, len(offsets))
+		}
+		for i := 1; i < len(offsets); i++ {
+			if offsets[i] < offsets[i-1] {
+				t.Fatalf("%s: events handled out of order: %v", user, offsets)
+			}
+		}
+	}
+}
+
+func TestWorkerPoolDepth(t *testing.T) {
+	release := make(chan struct{})
+	pool := newWorkerPool(1, func(j *job) {
+		<-release
+	})
+
+	for offset := int64(0); offset < 3; offset++ {
+		pool.submit(newTestJob(0, offset, "alice"))
+	}
+	if depth := pool.depth(); depth != 3 {
+		t.Errorf("expected 3 jobs pending, got %d", depth)
+	}
+
+	close(release)
+	pool.stop()
+	if depth := pool.depth(); depth != 0 {
+		t.Errorf("expected no jobs pending after stopping, got %d", depth)
+	}
+}
@@ -3,53 +3,306 @@ package kafka
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/errs"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/logging"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
 	"github.com/segmentio/kafka-go"
 )
 
 // Producer represents a Kafka producer
 type Producer struct {
-	writer *kafka.Writer
-	topic  string
+	writer      *kafka.Writer
+	brokers     []string
+	topic       string
+	keyStrategy KeyStrategy
+	headers     *HeaderCodec // nil disables headers
+	retry       WriteRetryPolicy
+	idempotent  bool
+
+	// write sends messages; replaced in tests
+	write func(ctx context.Context, msgs ...kafka.Message) error
+
+	// Outcome of recent writes, for health checks
+	status   WriteStatus
+	statusMu sync.RWMutex
+}
+
+// WriteStatus reports the most recent successful and failed writes
+type WriteStatus struct {
+	LastSuccess time.Time `json:"last_success"` // Zero if no write has succeeded
+	LastFailure time.Time `json:"last_failure"`
+	LastError   string    `json:"last_error,omitempty"`
+}
+
+// Failing reports whether the latest write failed
+func (s WriteStatus) Failing() bool {
+	return s.LastFailure.After(s.LastSuccess)
+}
+
+// WriteRetryPolicy controls how SendEvent retries writes that fail with transient errors
+type WriteRetryPolicy struct {
+	MaxAttempts    int           // Writes per message, including the first; 1 or less disables retries
+	InitialBackoff time.Duration // Delay before the first retry, doubling per attempt with jitter
+	MaxBackoff     time.Duration // Upper bound of the delay
+	MaxElapsed     time.Duration // Stop retrying once this much time has passed since the first attempt; 0 means no limit
 }
 
-// NewProducer creates a new Kafka producer
+// NewProducer creates a new Kafka producer that writes to topic by default
 func NewProducer(brokers []string, topic string) *Producer {
+	// The topic is set per message so the same writer can serve SendToTopic
 	writer := &kafka.Writer{
 		Addr:     kafka.TCP(brokers...),
-		Topic:    topic,
 		Balancer: &kafka.LeastBytes{},
 	}
 
 	return &Producer{
-		writer: writer,
-		topic:  topic,
+		writer:      writer,
+		brokers:     brokers,
+		topic:       topic,
+		keyStrategy: KeyByEventID,
+		headers:     DefaultHeaderCodec(),
+		write:       writer.WriteMessages,
+	}
+}
+
+// SetCompression sets the codec message batches are compressed with. It must be called before sending.
+func (p *Producer) SetCompression(compression kafka.Compression) {
+	p.writer.Compression = compression
+}
+
+// SetBatchTimeout sets how long a partial batch waits for more messages before it is
+// written. It must be called before sending.
+func (p *Producer) SetBatchTimeout(timeout time.Duration) {
+	p.writer.BatchTimeout = timeout
+}
+
+// SetRetryPolicy makes SendEvent retry transient write failures under policy. The
+// writer's own retries are disabled so the policy alone decides how long a send may
+// take. It must be called before sending.
+func (p *Producer) SetRetryPolicy(policy WriteRetryPolicy) {
+	p.retry = policy
+	p.writer.MaxAttempts = 1
+}
+
+// SetIdempotent makes writes wait for all in-sync replicas and tags every message with
+// an idempotency-key header, the event ID, so a message written twice by a retry whose
+// acknowledgement was lost is recognised as a repeat downstream. kafka-go has no broker
+// side idempotent producer, so duplicates are dropped by the consumer's event ID
+// deduplication. It must be called before sending.
+func (p *Producer) SetIdempotent(enabled bool) {
+	p.idempotent = enabled
+	if enabled {
+		p.writer.RequiredAcks = kafka.RequireAll
+	}
+}
+
+// ParseCompression returns the compression codec with the given name: none, gzip, snappy, lz4 or zstd.
+// An empty name means none.
+func ParseCompression(name string) (kafka.Compression, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "none":
+		return 0, nil
+	case "gzip":
+		return kafka.Gzip, nil
+	case "snappy":
+		return kafka.Snappy, nil
+	case "lz4":
+		return kafka.Lz4, nil
+	case "zstd":
+		return kafka.Zstd, nil
+	default:
+		return 0, fmt.Errorf("unknown compression codec %q", name)
 	}
 }
 
-// SendEvent sends an event to Kafka
+// SetHeaderCodec changes the headers attached to event messages; nil sends none.
+// It must be called before sending.
+func (p *Producer) SetHeaderCodec(codec *HeaderCodec) {
+	p.headers = codec
+}
+
+// SetBalancer changes how messages are assigned to partitions. It must be called before sending.
+func (p *Producer) SetBalancer(balancer kafka.Balancer) {
+	p.writer.Balancer = balancer
+}
+
+// SetKeyStrategy changes which event field EventKey uses as the message key
+func (p *Producer) SetKeyStrategy(strategy KeyStrategy) {
+	p.keyStrategy = strategy
+}
+
+// EventKey returns the message key for an event under the producer's key strategy
+func (p *Producer) EventKey(event *models.AnalyticsEvent) string {
+	return p.keyStrategy.Key(event)
+}
+
+// SendEvent sends an event to the producer's default topic
 func (p *Producer) SendEvent(ctx context.Context, key string, value interface{}) error {
+	return p.SendToTopic(ctx, p.topic, key, value)
+}
+
+// SendToTopic sends an event to the given topic
+func (p *Producer) SendToTopic(ctx context.Context, topic, key string, value interface{}) error {
 	jsonValue, err := json.Marshal(value)
 	if err != nil {
-		return fmt.Errorf("failed to marshal event: %w", err)
+		return errs.Errorf(errs.ErrInvalidEvent, "failed to marshal event: %w", err)
 	}
 
+	// The message timestamp records when the producer wrote the event, so consumers can
+	// measure how long it waited in Kafka
 	msg := kafka.Message{
+		Topic: topic,
 		Key:   []byte(key),
 		Value: jsonValue,
+		Time:  time.Now(),
 	}
 
-	err = p.writer.WriteMessages(ctx, msg)
+	var event *models.AnalyticsEvent
+	switch v := value.(type) {
+	case models.AnalyticsEvent:
+		event = &v
+	case *models.AnalyticsEvent:
+		event = v
+	}
+
+	// Describe events in headers so consumers can route and filter without decoding
+	if p.headers != nil && event != nil {
+		msg.Headers = p.headers.Encode(event)
+	}
+	if p.idempotent {
+		idempotencyKey := key
+		if event != nil && event.ID != "" {
+			idempotencyKey = event.ID
+		}
+		msg.Headers = append(msg.Headers, kafka.Header{Key: HeaderIdempotencyKey, Value: []byte(idempotencyKey)})
+	}
+
+	attempts, err := p.writeWithRetry(ctx, msg)
+	p.recordWrite(err)
 	if err != nil {
-		return fmt.Errorf("failed to write message: %w", err)
+		kind := writeErrorKind(err)
+		if attempts > 1 {
+			return errs.Errorf(kind, "failed to write message after %d attempts: %w", attempts, err)
+		}
+		return errs.Errorf(kind, "failed to write message: %w", err)
+	}
+
+	logging.Debug("Event sent to Kafka", "topic", topic, "key", key, "attempts", attempts)
+	return nil
+}
+
+// writeWithRetry writes msg, retrying transient failures under the retry policy, and
+// returns the number of attempts made
+func (p *Producer) writeWithRetry(ctx context.Context, msg kafka.Message) (int, error) {
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		err := p.write(ctx, msg)
+		if err == nil || attempt >= p.retry.MaxAttempts || !isTransientWriteError(err) {
+			return attempt, err
+		}
+
+		delay := backoffDelay(p.retry.InitialBackoff, p.retry.MaxBackoff, attempt)
+		if p.retry.MaxElapsed > 0 && time.Since(start)+delay > p.retry.MaxElapsed {
+			return attempt, err
+		}
+		logging.Debug("Retrying Kafka write", "topic", msg.Topic, "attempt", attempt, "delay", delay.String(), "error", err)
+		if !sleep(ctx, delay) {
+			return attempt, err
+		}
+	}
+}
+
+// isTransientWriteError reports whether a failed write may succeed when retried:
+// broker errors Kafka marks as temporary and network failures, but not cancellation
+// or errors such as an oversized message
+func isTransientWriteError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var writeErrors kafka.WriteErrors
+	if errors.As(err, &writeErrors) {
+		for _, e := range writeErrors {
+			if e != nil && !isTransientWriteError(e) {
+				return false
+			}
+		}
+		return true
 	}
+	var kafkaErr kafka.Error
+	if errors.As(err, &kafkaErr) {
+		return kafkaErr.Temporary()
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	return errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF)
+}
 
-	log.Printf("Event sent to Kafka - Topic: %s, Key: %s", p.topic, key)
+// writeErrorKind classifies a failed write: transient failures and timeouts mean the
+// brokers are unavailable, while a message the brokers refuse as too large can never be
+// written. Other failures, such as missing authorization, are left unclassified.
+func writeErrorKind(err error) error {
+	if errors.Is(err, kafka.MessageSizeTooLarge) {
+		return errs.ErrInvalidEvent
+	}
+	if isTransientWriteError(err) || errors.Is(err, context.DeadlineExceeded) {
+		return errs.ErrKafkaUnavailable
+	}
 	return nil
 }
 
+// recordWrite updates the write status with the outcome of a write
+func (p *Producer) recordWrite(err error) {
+	p.statusMu.Lock()
+	defer p.statusMu.Unlock()
+
+	if err != nil {
+		p.status.LastFailure = time.Now()
+		p.status.LastError = err.Error()
+		return
+	}
+	p.status.LastSuccess = time.Now()
+}
+
+// WriteStatus returns the outcome of recent writes
+func (p *Producer) WriteStatus() WriteStatus {
+	p.statusMu.RLock()
+	defer p.statusMu.RUnlock()
+	return p.status
+}
+
+// Ping checks that a broker is reachable by requesting cluster metadata
+func (p *Producer) Ping(ctx context.Context) error {
+	var lastErr error
+	for _, broker := range p.brokers {
+		conn, err := kafka.DialContext(ctx, "tcp", broker)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if deadline, ok := ctx.Deadline(); ok {
+			conn.SetDeadline(deadline)
+		}
+		_, err = conn.Brokers()
+		conn.Close()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		return nil
+	}
+	return fmt.Errorf("no Kafka broker reachable: %w", lastErr)
+}
+
 // Close closes the producer
 func (p *Producer) Close() error {
 	return p.writer.Close()
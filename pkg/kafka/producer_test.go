@@ -0,0 +1,99 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/errs"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+	"github.com/segmentio/kafka-go"
+)
+
+// newTestProducer returns a producer whose writes are answered by errs in order,
+// recording the messages it was asked to write
+func newTestProducer(errs ...error) (*Producer, *[]kafka.Message) {
+	p := NewProducer([]string{"localhost:9092"}, "events")
+	var written []kafka.Message
+	p.write = func(ctx context.Context, msgs ...kafka.Message) error {
+		written = append(written, msgs...)
+		if len(errs) == 0 {
+			return nil
+		}
+		err := errs[0]
+		errs = errs[1:]
+		return err
+	}
+	return p, &written
+}
+
+func TestSendEventRetriesTransientErrors(t *testing.T) {
+	p, written := newTestProducer(kafka.LeaderNotAvailable, kafka.NotEnoughReplicas)
+	p.SetRetryPolicy(WriteRetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond})
+
+	if err := p.SendEvent(context.Background(), "k", &models.AnalyticsEvent{ID: "e1"}); err != nil {
+		t.Fatalf("SendEvent failed: %v", err)
+	}
+	if len(*written) != 3 {
+		t.Errorf("writes = %d, want 3", len(*written))
+	}
+	if p.WriteStatus().Failing() {
+		t.Error("write status should not be failing after a successful retry")
+	}
+}
+
+func TestSendEventStopsRetrying(t *testing.T) {
+	// Permanent errors are not retried
+	p, written := newTestProducer(kafka.MessageSizeTooLarge)
+	p.SetRetryPolicy(WriteRetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond})
+	if err := p.SendEvent(context.Background(), "k", &models.AnalyticsEvent{ID: "e1"}); !errors.Is(err, errs.ErrInvalidEvent) {
+		t.Fatalf("err = %v, want an invalid event", err)
+	}
+	if len(*written) != 1 {
+		t.Errorf("writes = %d, want 1 for a permanent error", len(*written))
+	}
+
+	// Transient errors are retried up to MaxAttempts
+	p, written = newTestProducer(kafka.LeaderNotAvailable, kafka.LeaderNotAvailable, kafka.LeaderNotAvailable)
+	p.SetRetryPolicy(WriteRetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond})
+	err := p.SendEvent(context.Background(), "k", &models.AnalyticsEvent{ID: "e1"})
+	if !errors.Is(err, kafka.LeaderNotAvailable) || !errors.Is(err, errs.ErrKafkaUnavailable) {
+		t.Fatalf("err = %v, want LeaderNotAvailable as Kafka unavailable", err)
+	}
+	if len(*written) != 2 {
+		t.Errorf("writes = %d, want 2", len(*written))
+	}
+	if !p.WriteStatus().Failing() {
+		t.Error("write status should be failing")
+	}
+
+	// Without a retry policy a write is attempted once
+	p, written = newTestProducer(kafka.LeaderNotAvailable)
+	p.SendEvent(context.Background(), "k", &models.AnalyticsEvent{ID: "e1"})
+	if len(*written) != 1 {
+		t.Errorf("writes = %d, want 1 without a retry policy", len(*written))
+	}
+}
+
+func TestSendEventIdempotencyKey(t *testing.T) {
+	p, written := newTestProducer()
+	p.SetIdempotent(true)
+
+	if err := p.SendEvent(context.Background(), "user-1", models.AnalyticsEvent{ID: "e1", Type: models.PageView}); err != nil {
+		t.Fatalf("SendEvent failed: %v", err)
+	}
+	if err := p.SendEvent(context.Background(), "raw-key", map[string]string{"a": "b"}); err != nil {
+		t.Fatalf("SendEvent failed: %v", err)
+	}
+
+	if got := ParseHeaders((*written)[0].Headers)[HeaderIdempotencyKey]; got != "e1" {
+		t.Errorf("event idempotency key = %q, want the event ID", got)
+	}
+	if got := ParseHeaders((*written)[1].Headers)[HeaderIdempotencyKey]; got != "raw-key" {
+		t.Errorf("non-event idempotency key = %q, want the message key", got)
+	}
+	if p.writer.RequiredAcks != kafka.RequireAll {
+		t.Error("idempotent producer should require all acknowledgements")
+	}
+}
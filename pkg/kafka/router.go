@@ -0,0 +1,120 @@
+package kafka
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+// RouteRule sends events matching all of its non-empty criteria to Topic
+type RouteRule struct {
+	EventType   models.EventType
+	PathPrefix  string
+	MetadataKey string
+	Topic       string
+}
+
+// matches reports whether the event satisfies every criterion of the rule
+func (r RouteRule) matches(event *models.AnalyticsEvent) bool {
+	if r.EventType != "" && event.Type != r.EventType {
+		return false
+	}
+	if r.PathPrefix != "" && !strings.HasPrefix(event.Path, r.PathPrefix) {
+		return false
+	}
+	if r.MetadataKey != "" {
+		if _, ok := event.Metadata[r.MetadataKey]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Router selects destination topics for events
+type Router struct {
+	defaultTopic string
+	rules        []RouteRule
+}
+
+// NewRouter creates a router that falls back to defaultTopic when no rule matches
+func NewRouter(defaultTopic string, rules []RouteRule) *Router {
+	return &Router{
+		defaultTopic: defaultTopic,
+		rules:        rules,
+	}
+}
+
+// Route returns the topics an event should be written to. Events fan out to the
+// topic of every matching rule, or go to the default topic if none match.
+func (r *Router) Route(event *models.AnalyticsEvent) []string {
+	var topics []string
+	seen := make(map[string]bool)
+	for _, rule := range r.rules {
+		if rule.matches(event) && !seen[rule.Topic] {
+			seen[rule.Topic] = true
+			topics = append(topics, rule.Topic)
+		}
+	}
+
+	if len(topics) == 0 {
+		return []string{r.defaultTopic}
+	}
+	return topics
+}
+
+// Topics returns every topic the router can send to, starting with the default topic
+func (r *Router) Topics() []string {
+	topics := []string{r.defaultTopic}
+	seen := map[string]bool{r.defaultTopic: true}
+	for _, rule := range r.rules {
+		if !seen[rule.Topic] {
+			seen[rule.Topic] = true
+			topics = append(topics, rule.Topic)
+		}
+	}
+	return topics
+}
+
+// ParseRouteRules parses rules of the form "criteria=topic" separated by semicolons,
+// where criteria are comma-separated "type:<event type>", "path:<prefix>" or "meta:<key>".
+// For example: "type:click=analytics-clicks;type:page_view,path:/checkout=analytics-checkout"
+func ParseRouteRules(spec string) ([]RouteRule, error) {
+	var rules []RouteRule
+
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		criteria, topic, ok := strings.Cut(entry, "=")
+		topic = strings.TrimSpace(topic)
+		if !ok || topic == "" {
+			return nil, fmt.Errorf("route %q: missing topic", entry)
+		}
+
+		rule := RouteRule{Topic: topic}
+		for _, criterion := range strings.Split(criteria, ",") {
+			kind, value, ok := strings.Cut(strings.TrimSpace(criterion), ":")
+			if !ok || value == "" {
+				return nil, fmt.Errorf("route %q: invalid criterion %q", entry, criterion)
+			}
+
+			switch kind {
+			case "type":
+				rule.EventType = models.EventType(value)
+			case "path":
+				rule.PathPrefix = value
+			case "meta":
+				rule.MetadataKey = value
+			default:
+				return nil, fmt.Errorf("route %q: unknown criterion %q", entry, kind)
+			}
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
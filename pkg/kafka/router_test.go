@@ -0,0 +1,48 @@
+package kafka
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+func TestRouterRoute(t *testing.T) {
+	rules, err := ParseRouteRules("type:click=clicks; type:page_view,path:/checkout=checkout; meta:experiment_id=experiments")
+	if err != nil {
+		t.Fatalf("ParseRouteRules failed: %v", err)
+	}
+	router := NewRouter("events", rules)
+
+	tests := []struct {
+		name  string
+		event models.AnalyticsEvent
+		want  []string
+	}{
+		{"Default", models.AnalyticsEvent{Type: models.Session, Path: "/"}, []string{"events"}},
+		{"ByType", models.AnalyticsEvent{Type: models.Click, Path: "/"}, []string{"clicks"}},
+		{"TypeAndPath", models.AnalyticsEvent{Type: models.PageView, Path: "/checkout/pay"}, []string{"checkout"}},
+		{"PathWrongType", models.AnalyticsEvent{Type: models.Session, Path: "/checkout"}, []string{"events"}},
+		{"FanOut", models.AnalyticsEvent{
+			Type:     models.Click,
+			Path:     "/",
+			Metadata: map[string]interface{}{"experiment_id": "exp-1"},
+		}, []string{"clicks", "experiments"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := router.Route(&tt.event); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Route() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRouteRulesErrors(t *testing.T) {
+	for _, spec := range []string{"type:click", "type:click=", "color:red=topic", "type=topic"} {
+		if _, err := ParseRouteRules(spec); err == nil {
+			t.Errorf("ParseRouteRules(%q) expected error", spec)
+		}
+	}
+}
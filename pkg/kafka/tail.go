@@ -0,0 +1,99 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/logging"
+)
+
+// Tail follows every partition of topic outside any consumer group, so no offsets are
+// committed, and passes each decoded event to handler until ctx is done. It starts at
+// the end of each partition, or at the first message at or after since when set.
+// Handler calls are serialized; messages of different partitions arrive interleaved.
+func Tail(ctx context.Context, brokers []string, topic string, since time.Time, handler func(*Message)) error {
+	partitions, err := topicPartitions(ctx, brokers, topic)
+	if err != nil {
+		return err
+	}
+
+	tailCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var mu sync.Mutex
+	serialized := func(message *Message) {
+		mu.Lock()
+		defer mu.Unlock()
+		handler(message)
+	}
+
+	errs := make(chan error, len(partitions))
+	for _, partition := range partitions {
+		go func() {
+			errs <- tailPartition(tailCtx, brokers, topic, partition, since, serialized)
+		}()
+	}
+
+	// A partition that fails stops the others
+	var firstErr error
+	for range partitions {
+		if err := <-errs; err != nil && firstErr == nil {
+			firstErr = err
+			cancel()
+		}
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+	return firstErr
+}
+
+// tailPartition follows one partition until ctx is done
+func tailPartition(ctx context.Context, brokers []string, topic string, partition int, since time.Time, handler func(*Message)) error {
+	start := kafka.LastOffset
+	if !since.IsZero() {
+		conn, err := kafka.DialLeader(ctx, "tcp", brokers[0], topic, partition)
+		if err != nil {
+			return fmt.Errorf("failed to connect to partition %d leader: %w", partition, err)
+		}
+		start, err = conn.ReadOffset(since)
+		conn.Close()
+		if err != nil {
+			return fmt.Errorf("failed to find offset for %s in partition %d: %w", since.Format(time.RFC3339), partition, err)
+		}
+	}
+
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:   brokers,
+		Topic:     topic,
+		Partition: partition,
+		MaxBytes:  10e6, // 10MB
+		MaxWait:   500 * time.Millisecond,
+	})
+	defer reader.Close()
+
+	if err := reader.SetOffset(start); err != nil {
+		return fmt.Errorf("failed to seek partition %d: %w", partition, err)
+	}
+
+	for {
+		msg, err := reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("failed to read partition %d: %w", partition, err)
+		}
+
+		message, err := DecodeMessage(msg)
+		if err != nil {
+			logging.Warn("Skipping undecodable message", "topic", topic, "partition", partition, "offset", msg.Offset, "error", err)
+			continue
+		}
+		handler(message)
+	}
+}
@@ -0,0 +1,142 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// Logger writes leveled, structured records. The arguments after a message are
+// alternating keys and values, as with log/slog.
+type Logger interface {
+	Debug(msg string, args ...any)
+	Info(msg string, args ...any)
+	Warn(msg string, args ...any)
+	Error(msg string, args ...any)
+
+	// With returns a logger that adds the key-value pairs to every record
+	With(args ...any) Logger
+}
+
+// Output formats
+const (
+	FormatText = "text" // key=value pairs
+	FormatJSON = "json" // One JSON object per line
+)
+
+// Config selects the minimum level and the output format
+type Config struct {
+	Level  string // debug, info, warn or error; empty is info
+	Format string // FormatText or FormatJSON; empty is text
+}
+
+// ParseLevel parses a level name
+func ParseLevel(name string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return slog.LevelInfo, fmt.Errorf("unknown log level %q (use debug, info, warn or error)", name)
+	}
+}
+
+// New creates a logger writing to w
+func New(w io.Writer, config Config) (Logger, error) {
+	level, err := ParseLevel(config.Level)
+	if err != nil {
+		return nil, err
+	}
+
+	options := &slog.HandlerOptions{Level: level}
+	switch strings.ToLower(strings.TrimSpace(config.Format)) {
+	case "", FormatText:
+		return slogLogger{slog.New(slog.NewTextHandler(w, options))}, nil
+	case FormatJSON:
+		return slogLogger{slog.New(slog.NewJSONHandler(w, options))}, nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q (use text or json)", config.Format)
+	}
+}
+
+// slogLogger adapts a slog.Logger to Logger
+type slogLogger struct {
+	logger *slog.Logger
+}
+
+func (l slogLogger) Debug(msg string, args ...any) { l.logger.Debug(msg, args...) }
+func (l slogLogger) Info(msg string, args ...any)  { l.logger.Info(msg, args...) }
+func (l slogLogger) Warn(msg string, args ...any)  { l.logger.Warn(msg, args...) }
+func (l slogLogger) Error(msg string, args ...any) { l.logger.Error(msg, args...) }
+
+func (l slogLogger) With(args ...any) Logger {
+	return slogLogger{l.logger.With(args...)}
+}
+
+// defaultLogger is used by the package-level functions; text at info level until replaced
+var defaultLogger atomic.Pointer[Logger]
+
+func init() {
+	var logger Logger = slogLogger{slog.New(slog.NewTextHandler(os.Stderr, nil))}
+	defaultLogger.Store(&logger)
+}
+
+// Default returns the process-wide logger
+func Default() Logger {
+	return *defaultLogger.Load()
+}
+
+// SetDefault replaces the process-wide logger. Output of the standard log package is
+// also sent through it, at info level, when it is backed by slog.
+func SetDefault(logger Logger) {
+	defaultLogger.Store(&logger)
+	if l, ok := logger.(slogLogger); ok {
+		slog.SetDefault(l.logger)
+	}
+}
+
+// Debug logs with the default logger
+func Debug(msg string, args ...any) { Default().Debug(msg, args...) }
+
+// Info logs with the default logger
+func Info(msg string, args ...any) { Default().Info(msg, args...) }
+
+// Warn logs with the default logger
+func Warn(msg string, args ...any) { Default().Warn(msg, args...) }
+
+// Error logs with the default logger
+func Error(msg string, args ...any) { Default().Error(msg, args...) }
+
+// With returns the default logger with the key-value pairs added
+func With(args ...any) Logger { return Default().With(args...) }
+
+// Fatal logs an error with the default logger and exits
+func Fatal(msg string, args ...any) {
+	Default().Error(msg, args...)
+	os.Exit(1)
+}
+
+// contextKey is the context key of the request-scoped logger
+type contextKey struct{}
+
+// NewContext returns a context carrying the logger, e.g. one tagged with a request ID
+func NewContext(ctx context.Context, logger Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext returns the logger carried by the context, or the default logger
+func FromContext(ctx context.Context) Logger {
+	if logger, ok := ctx.Value(contextKey{}).(Logger); ok {
+		return logger
+	}
+	return Default()
+}
@@ -0,0 +1,55 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestJSONLoggerLevelsAndFields(t *testing.T) {
+	var out bytes.Buffer
+	logger, err := New(&out, Config{Level: "warn", Format: "json"})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	logger.Info("dropped")
+	logger.With("request_id", "req-1").Warn("kept", "event_id", "evt-1")
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("expected only the warning to be written, got %q", out.String())
+	}
+	var record map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatalf("invalid JSON record %q: %v", lines[0], err)
+	}
+	if record["msg"] != "kept" || record["level"] != "WARN" || record["request_id"] != "req-1" || record["event_id"] != "evt-1" {
+		t.Errorf("unexpected record %v", record)
+	}
+}
+
+func TestConfigErrors(t *testing.T) {
+	if _, err := New(&bytes.Buffer{}, Config{Level: "loud"}); err == nil {
+		t.Error("expected an error for an unknown level")
+	}
+	if _, err := New(&bytes.Buffer{}, Config{Format: "xml"}); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	if FromContext(context.Background()) != Default() {
+		t.Error("expected the default logger without a request logger")
+	}
+
+	var out bytes.Buffer
+	logger, _ := New(&out, Config{})
+	ctx := NewContext(context.Background(), logger.With("request_id", "req-2"))
+	FromContext(ctx).Info("handled")
+	if !strings.Contains(out.String(), "request_id=req-2") {
+		t.Errorf("expected the request ID in %q", out.String())
+	}
+}
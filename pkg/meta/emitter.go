@@ -0,0 +1,86 @@
+package meta
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/kafka"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/logging"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+	"github.com/google/uuid"
+)
+
+// Emitter publishes the pipeline's own operational events to the meta topic.
+// Events reuse the AnalyticsEvent shape so they can be aggregated by the regular
+// analytics machinery: the operation is the page path, the component instance is
+// the user and the process is the session.
+type Emitter struct {
+	producer  kafka.EventProducer
+	component string
+	host      string
+	processID string
+	events    chan models.AnalyticsEvent
+}
+
+// NewEmitter creates an emitter for the named component (e.g. "producer", "consumer")
+func NewEmitter(producer kafka.EventProducer, component string) *Emitter {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "unknown"
+	}
+
+	return &Emitter{
+		producer:  producer,
+		component: component,
+		host:      host,
+		processID: uuid.New().String(),
+		events:    make(chan models.AnalyticsEvent, 1000),
+	}
+}
+
+// Emit queues an operational event without blocking; it is a no-op on a nil Emitter
+func (e *Emitter) Emit(operation string, details map[string]interface{}) {
+	if e == nil {
+		return
+	}
+
+	metadata := map[string]interface{}{
+		"component": e.component,
+		"operation": operation,
+	}
+	for key, value := range details {
+		metadata[key] = value
+	}
+
+	event := models.AnalyticsEvent{
+		ID:        uuid.New().String(),
+		Type:      models.MetaEvent,
+		Timestamp: time.Now(),
+		UserID:    e.component + "@" + e.host,
+		SessionID: e.processID,
+		URL:       "pipeline://" + e.component + "/" + operation,
+		Path:      "/" + e.component + "/" + operation,
+		Metadata:  metadata,
+	}
+
+	select {
+	case e.events <- event:
+	default:
+		// Queue is full, drop rather than stall the pipeline
+	}
+}
+
+// Run publishes queued events until the context is cancelled
+func (e *Emitter) Run(ctx context.Context) {
+	for {
+		select {
+		case event := <-e.events:
+			if err := e.producer.SendEvent(ctx, event.ID, event); err != nil {
+				logging.Error("Failed to publish meta event", "operation", event.Path, "error", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package meta
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/kafka/kafkatest"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+func TestEmitterPublishesOperations(t *testing.T) {
+	producer := kafkatest.NewProducer("pipeline-meta")
+	emitter := NewEmitter(producer, "consumer")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go emitter.Run(ctx)
+
+	emitter.Emit(models.OperationDLQRoute, map[string]interface{}{"event_id": "e1"})
+	deadline := time.Now().Add(time.Second)
+	for len(producer.Sent()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	sent := producer.Sent()
+	if len(sent) != 1 {
+		t.Fatalf("expected one meta event, got %d", len(sent))
+	}
+	event := sent[0].Value.(models.AnalyticsEvent)
+	if event.Type != models.MetaEvent || event.Path != "/consumer/dlq_route" || event.SessionID == "" {
+		t.Errorf("expected a meta event for the operation, got %+v", event)
+	}
+	if event.Metadata["component"] != "consumer" || event.Metadata["operation"] != "dlq_route" || event.Metadata["event_id"] != "e1" {
+		t.Errorf("expected the operation and details in the metadata, got %v", event.Metadata)
+	}
+
+	// Components without meta events enabled have no emitter
+	var disabled *Emitter
+	disabled.Emit(models.OperationDLQRoute, nil)
+}
@@ -3,22 +3,131 @@ package models
 import (
 	"sync"
 	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/hll"
 )
 
 // MetricsSnapshot represents a point-in-time analytics snapshot
 type MetricsSnapshot struct {
-	Timestamp          time.Time           `json:"timestamp"`
-	TotalEvents        int64               `json:"total_events"`
-	UniqueUsers        int64               `json:"unique_users"`
-	ActiveSessions     int64               `json:"active_sessions"`
-	EventsByType       map[EventType]int64 `json:"events_by_type"`
-	TopPages           []PageMetric        `json:"top_pages"`
-	TrafficSources     []TrafficSource     `json:"traffic_sources"`
-	DeviceStats        map[string]int64    `json:"device_stats"`
-	BrowserStats       map[string]int64    `json:"browser_stats"`
-	HourlyPageViews    []HourlyMetric      `json:"hourly_page_views"`
-	RealTimeEvents     []RecentEvent       `json:"real_time_events"`
-	PerformanceMetrics PerformanceMetrics  `json:"performance_metrics"`
+	Timestamp          time.Time               `json:"timestamp"`
+	TotalEvents        int64                   `json:"total_events"`
+	UniqueUsers        int64                   `json:"unique_users"`
+	ActiveSessions     int64                   `json:"active_sessions"`
+	EventsByType       map[EventType]int64     `json:"events_by_type"`
+	TopPages           []PageMetric            `json:"top_pages"`
+	TrafficSources     []TrafficSource         `json:"traffic_sources"`
+	TrafficChannels    []DimensionCount        `json:"traffic_channels"` // Visits by channel
+	DeviceStats        map[string]int64        `json:"device_stats"`
+	BrowserStats       map[string]int64        `json:"browser_stats"`
+	BrowserVersions    map[string]int64        `json:"browser_versions"` // "Chrome 120" -> count
+	OSStats            map[string]int64        `json:"os_stats"`
+	OSVersions         map[string]int64        `json:"os_versions"` // "iOS 17" -> count
+	BotEvents          int64                   `json:"bot_events"`
+	HourlyPageViews    []HourlyMetric          `json:"hourly_page_views"`
+	RealTimeEvents     []RecentEvent           `json:"real_time_events"`
+	PerformanceMetrics PerformanceMetrics      `json:"performance_metrics"`
+	Sites              map[string]SiteMetric   `json:"sites"`
+	CampaignStats      []CampaignMetric        `json:"campaign_stats"`
+	CustomMetrics      map[string]CustomMetric `json:"custom_metrics"`
+	Commerce           CommerceMetrics         `json:"commerce"`
+	Errors             ErrorMetrics            `json:"errors"`
+	BotStats           BotStats                `json:"bot_stats"`
+	ForeignDomains     map[string]int64        `json:"foreign_domains,omitempty"` // Events for hosts outside ALLOWED_DOMAINS, by host
+	EventTime          EventTimeStats          `json:"event_time"`
+	Identities         IdentityStats           `json:"identities"`
+	EntryPages         []DimensionCount        `json:"entry_pages"` // Paths sessions started on
+	ExitPages          []DimensionCount        `json:"exit_pages"`  // Paths sessions last viewed
+	Sessions           SessionStats            `json:"sessions"`    // Lengths of ended sessions
+	Goals              []GoalMetric            `json:"goals"`       // Completions of conversion goals
+	Geo                GeoStats                `json:"geo"`
+	Quality            *QualityStats           `json:"quality,omitempty"` // Data quality of received events, when tracked
+	Latency            *LatencyStats           `json:"latency,omitempty"` // Pipeline latency of consumed events, when tracked
+	Display            map[string]string       `json:"display,omitempty"` // Localized display strings, when requested
+}
+
+// SiteMetric represents per-site (hostname) statistics
+type SiteMetric struct {
+	Host           string `json:"host"`
+	PageViews      int64  `json:"page_views"`
+	UniqueVisitors int64  `json:"unique_visitors"`
+}
+
+// CampaignMetric represents the performance of a UTM campaign
+type CampaignMetric struct {
+	Campaign       string           `json:"campaign"`
+	Source         string           `json:"source"`
+	Medium         string           `json:"medium"`
+	Events         int64            `json:"events"`      // Events whose URL carried the campaign parameters
+	Users          int64            `json:"users"`       // Users who arrived through the campaign
+	Conversions    int64            `json:"conversions"` // Attributed users who reached the campaign goal
+	ConversionRate float64          `json:"conversion_rate"`
+	Terms          map[string]int64 `json:"terms,omitempty"`    // utm_term -> events
+	Contents       map[string]int64 `json:"contents,omitempty"` // utm_content -> events
+}
+
+// Granularity represents the time bucket size of a rollup
+type Granularity string
+
+const (
+	GranularityHour  Granularity = "hour"
+	GranularityDay   Granularity = "day"
+	GranularityWeek  Granularity = "week" // Weeks start on Monday
+	GranularityMonth Granularity = "month"
+)
+
+// Start returns the start of the UTC bucket containing t
+func (g Granularity) Start(t time.Time) time.Time {
+	t = t.UTC()
+	switch g {
+	case GranularityDay:
+		return t.Truncate(24 * time.Hour)
+	case GranularityWeek:
+		day := t.Truncate(24 * time.Hour)
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	case GranularityMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return t.Truncate(time.Hour)
+	}
+}
+
+// Next returns the start of the bucket following the one starting at start
+func (g Granularity) Next(start time.Time) time.Time {
+	switch g {
+	case GranularityDay:
+		return start.AddDate(0, 0, 1)
+	case GranularityWeek:
+		return start.AddDate(0, 0, 7)
+	case GranularityMonth:
+		return start.AddDate(0, 1, 0)
+	default:
+		return start.Add(time.Hour)
+	}
+}
+
+// Rollup represents metrics aggregated over a single time bucket
+type Rollup struct {
+	Start        time.Time           `json:"start"`
+	Granularity  Granularity         `json:"granularity"`
+	Events       int64               `json:"events"`
+	PageViews    int64               `json:"page_views"`
+	UniqueUsers  int64               `json:"unique_users,omitempty"` // Left out of buckets summed from hours, as distinct counts can't be summed
+	Sessions     int64               `json:"sessions,omitempty"`     // Like UniqueUsers
+	EventsByType map[EventType]int64 `json:"events_by_type"`
+	LateEvents   int64               `json:"late_events,omitempty"` // Events added after the watermark passed the bucket
+}
+
+// EventTimeStats reports event-time progress and late events. Late events arrived
+// behind the watermark but within the allowed lateness and were still counted in their
+// hour; corrected events are those that changed an hour the watermark had already
+// passed. Dropped late events were counted everywhere except the hourly metrics.
+type EventTimeStats struct {
+	Watermark              time.Time `json:"watermark"`
+	MaxOutOfOrderSeconds   float64   `json:"max_out_of_order_seconds"`
+	AllowedLatenessSeconds float64   `json:"allowed_lateness_seconds"`
+	LateEvents             int64     `json:"late_events"`
+	CorrectedEvents        int64     `json:"corrected_events"`
+	DroppedLateEvents      int64     `json:"dropped_late_events"`
 }
 
 // PageMetric represents page visit statistics
@@ -29,6 +138,9 @@ type PageMetric struct {
 	UniqueVisitors int64   `json:"unique_visitors"`
 	AverageTime    float64 `json:"average_time_seconds"`
 	BounceRate     float64 `json:"bounce_rate"`
+
+	// Views by variant, e.g. "lang=fr", when PAGE_URL_VARIANT_PARAMS are configured
+	Variants map[string]int64 `json:"variants,omitempty"`
 }
 
 // TrafficSource represents referrer statistics
@@ -36,6 +148,25 @@ type TrafficSource struct {
 	Source  string  `json:"source"`
 	Count   int64   `json:"count"`
 	Percent float64 `json:"percent"`
+	Channel string  `json:"channel"` // Channel the referrer domain is classified as
+}
+
+// DimensionCount represents one value of a breakdown such as device type or browser
+type DimensionCount struct {
+	Name    string  `json:"name"`
+	Count   int64   `json:"count"`
+	Percent float64 `json:"percent"`
+}
+
+// BotStats summarizes traffic detected as bots. Under the flag policy these events are
+// also counted in every other metric; otherwise they are counted only here.
+type BotStats struct {
+	Policy       string              `json:"policy"`
+	Events       int64               `json:"events"`
+	ByReason     map[string]int64    `json:"by_reason"`
+	EventsByType map[EventType]int64 `json:"events_by_type"`
+	TopBots      []DimensionCount    `json:"top_bots"`
+	TopPages     []DimensionCount    `json:"top_pages"` // Paths most requested by bots
 }
 
 // HourlyMetric represents hourly aggregated data
@@ -57,74 +188,182 @@ type RecentEvent struct {
 type PerformanceMetrics struct {
 	AverageLoadTime float64 `json:"average_load_time_ms"`
 	MedianLoadTime  float64 `json:"median_load_time_ms"`
+	P75LoadTime     float64 `json:"p75_load_time_ms"`
+	P90LoadTime     float64 `json:"p90_load_time_ms"`
+	P95LoadTime     float64 `json:"p95_load_time_ms"`
+	P99LoadTime     float64 `json:"p99_load_time_ms"`
 	SlowPagesCount  int64   `json:"slow_pages_count"`
 	FastPagesCount  int64   `json:"fast_pages_count"`
+	Samples         int64   `json:"load_time_samples"` // Page views the load times cover, all since startup; 0 leaves them empty
+
+	Seconds *LoadTimesSeconds `json:"load_times_s,omitempty"` // The load times in seconds, only when formatted in seconds
+}
+
+// LoadTimesSeconds holds the load time metrics converted to seconds
+type LoadTimesSeconds struct {
+	Average float64 `json:"average_load_time_s"`
+	Median  float64 `json:"median_load_time_s"`
+	P75     float64 `json:"p75_load_time_s"`
+	P90     float64 `json:"p90_load_time_s"`
+	P95     float64 `json:"p95_load_time_s"`
+	P99     float64 `json:"p99_load_time_s"`
 }
 
 // Alert represents a system alert
 type Alert struct {
-	ID           string    `json:"id"`
-	Type         string    `json:"type"`
-	Message      string    `json:"message"`
-	Severity     string    `json:"severity"`
-	Timestamp    time.Time `json:"timestamp"`
-	Resolved     bool      `json:"resolved"`
-	Threshold    float64   `json:"threshold"`
-	CurrentValue float64   `json:"current_value"`
+	ID            string     `json:"id"`
+	Name          string     `json:"name"` // Name of the AlertConfig that fired
+	Type          string     `json:"type"`
+	Message       string     `json:"message"`
+	Severity      string     `json:"severity"`
+	Timestamp     time.Time  `json:"timestamp"` // Time of the latest notification
+	FiredAt       time.Time  `json:"fired_at"`
+	ResolvedAt    *time.Time `json:"resolved_at,omitempty"`
+	Resolved      bool       `json:"resolved"`
+	Notifications int        `json:"notifications"` // Number of times this alert has been notified
+	Threshold     float64    `json:"threshold"`
+	CurrentValue  float64    `json:"current_value"`
 }
 
 // AlertConfig represents alert configuration
 type AlertConfig struct {
-	Name          string  `json:"name"`
-	Type          string  `json:"type"`
-	Metric        string  `json:"metric"`
-	Threshold     float64 `json:"threshold"`
-	Operator      string  `json:"operator"` // "gt", "lt", "eq"
-	Enabled       bool    `json:"enabled"`
-	WindowMinutes int     `json:"window_minutes"`
+	Name            string  `json:"name"`
+	Type            string  `json:"type"`
+	Metric          string  `json:"metric"`
+	Threshold       float64 `json:"threshold"`
+	Operator        string  `json:"operator"`       // "gt", "lt", "eq", or "pct_increase"/"pct_decrease" over the previous window
+	Path            string  `json:"path,omitempty"` // Evaluate the metric for this URL path only, over the window
+	Enabled         bool    `json:"enabled"`
+	WindowMinutes   int     `json:"window_minutes"`
+	CooldownMinutes int     `json:"cooldown_minutes"` // Re-notify interval while active; 0 uses the default
 }
 
 // WebSocketMessage represents a message sent to WebSocket clients
 type WebSocketMessage struct {
-	Type      string      `json:"type"`
-	Timestamp time.Time   `json:"timestamp"`
-	Data      interface{} `json:"data"`
+	Type        string      `json:"type"`
+	Timestamp   time.Time   `json:"timestamp"`
+	Data        interface{} `json:"data"`
+	Version     uint64      `json:"version,omitempty"`      // Snapshot version, set on snapshot messages
+	BaseVersion uint64      `json:"base_version,omitempty"` // Version a delta applies to, set on delta messages
+	ResumeToken string      `json:"resume_token,omitempty"` // Token to present when reconnecting
+}
+
+// Subscription represents a WebSocket client's message filter; empty lists match everything
+type Subscription struct {
+	MessageTypes []string    `json:"message_types,omitempty"`
+	EventTypes   []EventType `json:"event_types,omitempty"`
+	Paths        []string    `json:"paths,omitempty"`     // URL path prefixes for real-time events
+	Dashboard    string      `json:"dashboard,omitempty"` // Receive dashboard_update messages for this dashboard
+}
+
+// ClientRequest represents a control message sent by a WebSocket client
+type ClientRequest struct {
+	Action string `json:"action"` // "subscribe", "unsubscribe" or "resync"
+	Subscription
+}
+
+// Agent is a combination of device, browser and OS seen in parsed user agents
+type Agent struct {
+	Device         string
+	Browser        string
+	BrowserVersion string
+	OS             string
+	OSVersion      string
 }
 
 // RealTimeAnalytics handles real-time analytics aggregation with time windows
 type RealTimeAnalytics struct {
-	Mu             sync.RWMutex
-	Events         []AnalyticsEvent     // Recent events buffer
-	PageViews      map[string]int64     // URL -> count
-	UniqueUsers    map[string]bool      // UserID -> exists
-	SessionsActive map[string]time.Time // SessionID -> last activity
-	EventsByType   map[EventType]int64
-	HourlyData     map[int64]int64            // Unix hour -> event count
-	LoadTimes      []float64                  // Page load times
-	TrafficSources map[string]int64           // Referrer domain -> count
-	DeviceTypes    map[string]int64           // Device type -> count
-	BrowserTypes   map[string]int64           // Browser -> count
-	PageVisitors   map[string]map[string]bool // URL -> set of user IDs
-	LastCleanup    time.Time
-	StartTime      time.Time
-	TotalEvents    int64
+	Mu              sync.RWMutex
+	Events          []AnalyticsEvent     // Recent events buffer
+	PageViews       map[string]int64     // URL -> count
+	UniqueUsers     *hll.Counter         // Distinct user IDs
+	SessionsActive  map[string]time.Time // SessionID -> last activity
+	EventsByType    map[EventType]int64
+	HourlyData      map[int64]int64            // Unix hour -> event count
+	HourlyRollups   map[int64]*Rollup          // Unix hour -> aggregated metrics
+	HourlyUsers     map[int64]*hll.Counter     // Unix hour -> distinct user IDs
+	HourlySessions  map[int64]*hll.Counter     // Unix hour -> distinct session IDs
+	TrafficSources  map[string]int64           // Referrer domain -> count
+	DeviceTypes     map[string]int64           // Device type -> count
+	BrowserTypes    map[string]int64           // Browser -> count
+	BrowserVersions map[string]int64           // "Browser major" -> count
+	OSTypes         map[string]int64           // Operating system -> count
+	OSVersions      map[string]int64           // "OS major" -> count
+	AgentCounts     map[Agent]int64            // Parsed user agent -> count, for breakdowns filtered by browser or OS
+	AgentPageViews  map[Agent]map[string]int64 // Browser and OS, without versions -> URL -> page views
+	PageVisitors    map[string]*hll.Counter    // URL -> distinct user IDs
+	SiteViews       map[string]int64           // Host -> page view count
+	SiteVisitors    map[string]*hll.Counter    // Host -> distinct user IDs
+	CountryViews    map[string]int64           // Country code -> page views
+	RegionViews     map[string]int64           // "Country-region" -> page views
+	HourlyCountries map[int64]map[string]int64 // Unix hour -> country code -> page views
+	UnknownGeoViews int64                      // Page views without a known country
+	LastCleanup     time.Time
+	StartTime       time.Time
+	TotalEvents     int64
+	BotEvents       int64 // Events from bot user agents, counted even when bots are excluded
 }
 
 // NewRealTimeAnalytics creates a new real-time analytics instance
 func NewRealTimeAnalytics() *RealTimeAnalytics {
 	return &RealTimeAnalytics{
-		Events:         make([]AnalyticsEvent, 0, 1000),
-		PageViews:      make(map[string]int64),
-		UniqueUsers:    make(map[string]bool),
-		SessionsActive: make(map[string]time.Time),
-		EventsByType:   make(map[EventType]int64),
-		HourlyData:     make(map[int64]int64),
-		LoadTimes:      make([]float64, 0, 1000),
-		TrafficSources: make(map[string]int64),
-		DeviceTypes:    make(map[string]int64),
-		BrowserTypes:   make(map[string]int64),
-		PageVisitors:   make(map[string]map[string]bool),
-		LastCleanup:    time.Now(),
-		StartTime:      time.Now(),
+		Events:          make([]AnalyticsEvent, 0, 1000),
+		PageViews:       make(map[string]int64),
+		UniqueUsers:     hll.New(hll.DefaultConfig()),
+		SessionsActive:  make(map[string]time.Time),
+		EventsByType:    make(map[EventType]int64),
+		HourlyData:      make(map[int64]int64),
+		HourlyRollups:   make(map[int64]*Rollup),
+		HourlyUsers:     make(map[int64]*hll.Counter),
+		HourlySessions:  make(map[int64]*hll.Counter),
+		TrafficSources:  make(map[string]int64),
+		DeviceTypes:     make(map[string]int64),
+		BrowserTypes:    make(map[string]int64),
+		BrowserVersions: make(map[string]int64),
+		OSTypes:         make(map[string]int64),
+		OSVersions:      make(map[string]int64),
+		AgentCounts:     make(map[Agent]int64),
+		AgentPageViews:  make(map[Agent]map[string]int64),
+		PageVisitors:    make(map[string]*hll.Counter),
+		SiteViews:       make(map[string]int64),
+		SiteVisitors:    make(map[string]*hll.Counter),
+		CountryViews:    make(map[string]int64),
+		RegionViews:     make(map[string]int64),
+		HourlyCountries: make(map[int64]map[string]int64),
+		LastCleanup:     time.Now(),
+		StartTime:       time.Now(),
 	}
 }
+
+// Reset discards every collected value, as if newly created. The caller must hold Mu.
+func (r *RealTimeAnalytics) Reset() {
+	fresh := NewRealTimeAnalytics()
+	r.Events = fresh.Events
+	r.PageViews = fresh.PageViews
+	r.UniqueUsers = fresh.UniqueUsers
+	r.SessionsActive = fresh.SessionsActive
+	r.EventsByType = fresh.EventsByType
+	r.HourlyData = fresh.HourlyData
+	r.HourlyRollups = fresh.HourlyRollups
+	r.HourlyUsers = fresh.HourlyUsers
+	r.HourlySessions = fresh.HourlySessions
+	r.TrafficSources = fresh.TrafficSources
+	r.DeviceTypes = fresh.DeviceTypes
+	r.BrowserTypes = fresh.BrowserTypes
+	r.BrowserVersions = fresh.BrowserVersions
+	r.OSTypes = fresh.OSTypes
+	r.OSVersions = fresh.OSVersions
+	r.AgentCounts = fresh.AgentCounts
+	r.AgentPageViews = fresh.AgentPageViews
+	r.PageVisitors = fresh.PageVisitors
+	r.SiteViews = fresh.SiteViews
+	r.SiteVisitors = fresh.SiteVisitors
+	r.CountryViews = fresh.CountryViews
+	r.RegionViews = fresh.RegionViews
+	r.HourlyCountries = fresh.HourlyCountries
+	r.UnknownGeoViews = 0
+	r.LastCleanup = fresh.LastCleanup
+	r.StartTime = fresh.StartTime
+	r.TotalEvents = 0
+	r.BotEvents = 0
+}
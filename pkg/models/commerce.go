@@ -0,0 +1,127 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// E-commerce event types, whose order details are carried in the event metadata
+const (
+	AddToCart EventType = "add_to_cart"
+	Checkout  EventType = "checkout"
+	Purchase  EventType = "purchase"
+)
+
+// Event metadata keys of e-commerce events
+const (
+	MetadataOrderID  = "order_id"
+	MetadataItems    = "items"    // Array of line item objects
+	MetadataRevenue  = "revenue"  // Order total; defaults to the sum of the line items
+	MetadataCurrency = "currency" // ISO 4217 code
+)
+
+// IsCommerce reports whether the event type is an e-commerce event
+func (t EventType) IsCommerce() bool {
+	return t == AddToCart || t == Checkout || t == Purchase
+}
+
+// LineItem is a product in a cart or order
+type LineItem struct {
+	ProductID string  `json:"product_id"`
+	Name      string  `json:"name,omitempty"`
+	Category  string  `json:"category,omitempty"`
+	Price     float64 `json:"price"`
+	Quantity  int64   `json:"quantity"`
+}
+
+// Order is the structured form of an e-commerce event
+type Order struct {
+	OrderID  string     `json:"order_id,omitempty"` // Required for purchases
+	Items    []LineItem `json:"items"`
+	Revenue  float64    `json:"revenue"`
+	Currency string     `json:"currency"`
+}
+
+// OrderFromEvent reads the order details from an e-commerce event's metadata. Items
+// default to a quantity of 1, the revenue defaults to the item total and the currency
+// to defaultCurrency.
+func OrderFromEvent(event *AnalyticsEvent, defaultCurrency string) (Order, error) {
+	order := Order{Currency: defaultCurrency}
+
+	if orderID, ok := event.Metadata[MetadataOrderID].(string); ok {
+		order.OrderID = orderID
+	}
+	if event.Type == Purchase && order.OrderID == "" {
+		return order, errors.New("purchase events require an order_id")
+	}
+
+	if currency, ok := event.Metadata[MetadataCurrency].(string); ok && currency != "" {
+		if len(currency) != 3 {
+			return order, fmt.Errorf("invalid currency %q", currency)
+		}
+		order.Currency = strings.ToUpper(currency)
+	}
+
+	rawItems, _ := event.Metadata[MetadataItems].([]interface{})
+	var itemTotal float64
+	for i, raw := range rawItems {
+		fields, ok := raw.(map[string]interface{})
+		if !ok {
+			return order, fmt.Errorf("item %d is not an object", i)
+		}
+
+		item := LineItem{Quantity: 1}
+		item.ProductID, _ = fields["product_id"].(string)
+		item.Name, _ = fields["name"].(string)
+		item.Category, _ = fields["category"].(string)
+		if item.ProductID == "" {
+			return order, fmt.Errorf("item %d has no product_id", i)
+		}
+		if price, ok := fields["price"].(float64); ok {
+			item.Price = price
+		}
+		if quantity, ok := fields["quantity"].(float64); ok {
+			item.Quantity = int64(quantity)
+		}
+		if item.Price < 0 || item.Quantity < 1 {
+			return order, fmt.Errorf("item %d has a negative price or a quantity below 1", i)
+		}
+
+		itemTotal += item.Price * float64(item.Quantity)
+		order.Items = append(order.Items, item)
+	}
+
+	order.Revenue = itemTotal
+	if revenue, ok := event.Metadata[MetadataRevenue].(float64); ok {
+		if revenue < 0 {
+			return order, errors.New("revenue must not be negative")
+		}
+		order.Revenue = revenue
+	}
+
+	return order, nil
+}
+
+// ProductMetric represents the sales of one product
+type ProductMetric struct {
+	ProductID string  `json:"product_id"`
+	Name      string  `json:"name,omitempty"`
+	Units     int64   `json:"units"`
+	Revenue   float64 `json:"revenue"`
+	CartAdds  int64   `json:"cart_adds"`
+}
+
+// CommerceMetrics represents revenue analytics in the reporting currency
+type CommerceMetrics struct {
+	Currency          string             `json:"currency"`
+	TotalRevenue      float64            `json:"total_revenue"`
+	Orders            int64              `json:"orders"`
+	AverageOrderValue float64            `json:"average_order_value"`
+	CartAdds          int64              `json:"cart_adds"`
+	Checkouts         int64              `json:"checkouts"`
+	Purchasers        int64              `json:"purchasers"`
+	ConversionRate    float64            `json:"conversion_rate"` // Share of users with a page view who purchased
+	TopProducts       []ProductMetric    `json:"top_products"`
+	OtherCurrencies   map[string]float64 `json:"other_currencies,omitempty"` // Revenue of orders in other currencies, not converted
+}
@@ -0,0 +1,45 @@
+package models
+
+import "regexp"
+
+// eventTypePattern restricts event type names to lowercase identifiers
+var eventTypePattern = regexp.MustCompile(`^[a-z][a-z0-9_.-]{0,63}$`)
+
+// IsBuiltin reports whether the event type has built-in processing
+func (t EventType) IsBuiltin() bool {
+	switch t {
+	case PageView, Click, Session, UserEvent, MetaEvent, SessionReplay, AddToCart, Checkout, Purchase, Error, UserErasure:
+		return true
+	}
+	return false
+}
+
+// Valid reports whether the event type is a well-formed name; any such name
+// may be used as a custom event type
+func (t EventType) Valid() bool {
+	return eventTypePattern.MatchString(string(t))
+}
+
+// AggregationKind is how a custom metric aggregates matching events
+type AggregationKind string
+
+const (
+	AggregateCountBy     AggregationKind = "count_by"     // Count events per value of a metadata field
+	AggregateSum         AggregationKind = "sum"          // Sum a numeric metadata field
+	AggregateUniqueUsers AggregationKind = "unique_users" // Count distinct users
+)
+
+// CustomMetricRule declares how events of a type are aggregated into a named metric
+type CustomMetricRule struct {
+	Name      string          `json:"name"`
+	EventType EventType       `json:"event_type"`
+	Kind      AggregationKind `json:"kind"`
+	Field     string          `json:"field,omitempty"` // Metadata field for count_by and sum
+}
+
+// CustomMetric is the current value of a custom metric
+type CustomMetric struct {
+	CustomMetricRule
+	Total  float64            `json:"total"`            // Event count, sum or distinct users
+	Values map[string]float64 `json:"values,omitempty"` // Per-value counts for count_by
+}
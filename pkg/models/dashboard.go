@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// Dashboard is a named set of widgets defined by users and stored server-side
+type Dashboard struct {
+	Name    string   `json:"name"`
+	Title   string   `json:"title,omitempty"`
+	Widgets []Widget `json:"widgets"`
+}
+
+// Widget selects one metric of a dashboard and how it is displayed
+type Widget struct {
+	ID     string `json:"id"`
+	Metric string `json:"metric"`
+	Chart  string `json:"chart"` // number, line, bar, pie, table or list
+
+	// Time window of time series and recent event metrics, as a duration such as "6h"
+	Window string `json:"window,omitempty"`
+
+	// Rows returned by list metrics; 0 uses the default of 10
+	Limit int `json:"limit,omitempty"`
+
+	Filters WidgetFilters `json:"filters,omitempty"`
+}
+
+// WidgetFilters narrow a widget's metric. Event types apply to time series and recent
+// events, path prefixes to page lists and recent events.
+type WidgetFilters struct {
+	EventType  EventType `json:"event_type,omitempty"`
+	PathPrefix string    `json:"path_prefix,omitempty"`
+}
+
+// DashboardData is the current data of each widget of a dashboard
+type DashboardData struct {
+	Dashboard string                 `json:"dashboard"`
+	Timestamp time.Time              `json:"timestamp"`
+	Widgets   map[string]interface{} `json:"widgets"` // Widget ID -> data
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// UserErasure is the event type of tombstones requesting that everything stored for the
+// event's user ID be deleted. They are keyed by the user ID and carry no analytics data.
+const UserErasure EventType = "user_erasure"
+
+// NewUserErasure creates the tombstone for a user
+func NewUserErasure(id, userID string) *AnalyticsEvent {
+	return &AnalyticsEvent{
+		ID:        id,
+		Type:      UserErasure,
+		Timestamp: time.Now(),
+		UserID:    userID,
+	}
+}
+
+// ErasureResult summarizes the data removed for a user
+type ErasureResult struct {
+	UserID       string   `json:"user_id"`
+	RecentEvents int      `json:"recent_events"` // Events removed from the recent events buffer
+	Sessions     []string `json:"sessions"`      // Sessions of the user that were ended
+	Estimated    bool     `json:"estimated"`     // Some unique counts are estimates the user could not be removed from
+}
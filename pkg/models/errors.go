@@ -0,0 +1,142 @@
+package models
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Error is the event type of application errors and exceptions reported by clients
+const Error EventType = "error"
+
+// Event metadata keys of error events
+const (
+	MetadataErrorMessage = "message"
+	MetadataErrorStack   = "stack"
+	MetadataStackHash    = "stack_hash" // Groups occurrences of the same error; derived from the stack when absent
+	MetadataErrorSource  = "source"     // File or script URL the error was raised in
+	MetadataErrorLine    = "line"
+	MetadataErrorColumn  = "column"
+	MetadataSeverity     = "severity"
+)
+
+// Error severities, most severe first
+const (
+	SeverityFatal   = "fatal"
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+	SeverityInfo    = "info"
+)
+
+// Longest error message kept; longer messages are truncated
+const maxErrorMessageLength = 1024
+
+// lineNumberPattern matches line and column numbers in stack frames, which change between builds
+var lineNumberPattern = regexp.MustCompile(`:\d+(:\d+)?`)
+
+// ErrorDetails is the structured form of an error event
+type ErrorDetails struct {
+	Message   string `json:"message"`
+	Stack     string `json:"stack,omitempty"`
+	StackHash string `json:"stack_hash"`
+	Source    string `json:"source,omitempty"`
+	Line      int    `json:"line,omitempty"`
+	Column    int    `json:"column,omitempty"`
+	Severity  string `json:"severity"`
+}
+
+// ErrorEvent represents an application error or exception
+type ErrorEvent struct {
+	AnalyticsEvent
+	ErrorDetails
+}
+
+// ErrorFromEvent reads the error details from an error event's metadata. The severity
+// defaults to error, and the stack hash is derived from the stack with line numbers
+// removed, or from the message and source when there is no stack.
+func ErrorFromEvent(event *AnalyticsEvent) (ErrorDetails, error) {
+	details := ErrorDetails{Severity: SeverityError}
+
+	details.Message, _ = event.Metadata[MetadataErrorMessage].(string)
+	details.Message = strings.TrimSpace(details.Message)
+	if details.Message == "" {
+		return details, errors.New("error events require a message")
+	}
+	if len(details.Message) > maxErrorMessageLength {
+		// Cut at a character boundary so the message stays valid UTF-8
+		n := maxErrorMessageLength
+		for n > 0 && !utf8.RuneStart(details.Message[n]) {
+			n--
+		}
+		details.Message = details.Message[:n]
+	}
+
+	details.Stack, _ = event.Metadata[MetadataErrorStack].(string)
+	details.Source, _ = event.Metadata[MetadataErrorSource].(string)
+	if line, ok := event.Metadata[MetadataErrorLine].(float64); ok {
+		details.Line = int(line)
+	}
+	if column, ok := event.Metadata[MetadataErrorColumn].(float64); ok {
+		details.Column = int(column)
+	}
+
+	if severity, ok := event.Metadata[MetadataSeverity].(string); ok && severity != "" {
+		switch severity = strings.ToLower(severity); severity {
+		case SeverityFatal, SeverityError, SeverityWarning, SeverityInfo:
+			details.Severity = severity
+		default:
+			return details, fmt.Errorf("invalid severity %q", severity)
+		}
+	}
+
+	details.StackHash, _ = event.Metadata[MetadataStackHash].(string)
+	if details.StackHash == "" {
+		details.StackHash = stackHash(details)
+	}
+	return details, nil
+}
+
+// stackHash fingerprints an error so repeated occurrences group together across releases
+func stackHash(details ErrorDetails) string {
+	key := details.Message + "\n" + details.Source
+	if details.Stack != "" {
+		key = lineNumberPattern.ReplaceAllString(details.Stack, "")
+	}
+	sum := sha1.Sum([]byte(key))
+	return hex.EncodeToString(sum[:8])
+}
+
+// ErrorGroup represents all occurrences of one error, identified by its stack hash
+type ErrorGroup struct {
+	StackHash     string    `json:"stack_hash"`
+	Message       string    `json:"message"`
+	Source        string    `json:"source,omitempty"`
+	Line          int       `json:"line,omitempty"`
+	Severity      string    `json:"severity"` // Most severe level seen
+	Count         int64     `json:"count"`
+	AffectedUsers int64     `json:"affected_users"`
+	FirstSeen     time.Time `json:"first_seen"`
+	LastSeen      time.Time `json:"last_seen"`
+}
+
+// ErrorMinute represents the errors in one minute
+type ErrorMinute struct {
+	Minute time.Time `json:"minute"`
+	Errors int64     `json:"errors"`
+}
+
+// ErrorMetrics represents error analytics
+type ErrorMetrics struct {
+	TotalErrors     int64            `json:"total_errors"`
+	ErrorsPerMinute float64          `json:"errors_per_minute"` // Average over the recent window
+	ErrorRate       float64          `json:"error_rate"`        // Share of events in the recent window that were errors
+	AffectedUsers   int64            `json:"affected_users"`
+	BySeverity      map[string]int64 `json:"by_severity"`
+	TopErrors       []ErrorGroup     `json:"top_errors"`
+	Timeline        []ErrorMinute    `json:"timeline"` // Errors per minute, oldest first
+}
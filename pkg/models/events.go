@@ -6,10 +6,12 @@ import "time"
 type EventType string
 
 const (
-	PageView  EventType = "page_view"
-	Click     EventType = "click"
-	Session   EventType = "session"
-	UserEvent EventType = "user_event"
+	PageView      EventType = "page_view"
+	Click         EventType = "click"
+	Session       EventType = "session"
+	UserEvent     EventType = "user_event"
+	MetaEvent     EventType = "pipeline_meta"  // Operational events emitted by the pipeline itself
+	SessionReplay EventType = "session_replay" // Compressed DOM events for replaying a session
 )
 
 // AnalyticsEvent represents a website analytics event
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// Event metadata keys that assign a user to an experiment variant
+const (
+	MetadataExperimentID = "experiment_id"
+	MetadataVariant      = "variant"
+)
+
+// VariantResult represents the conversion statistics of one experiment variant
+type VariantResult struct {
+	Variant        string  `json:"variant"`
+	Participants   int64   `json:"participants"`
+	Conversions    int64   `json:"conversions"`
+	ConversionRate float64 `json:"conversion_rate"`
+	Lift           float64 `json:"lift"`        // Relative change in conversion rate versus control
+	ZScore         float64 `json:"z_score"`     // Two-proportion z-test against control
+	PValue         float64 `json:"p_value"`     // Two-sided p-value of the z-test
+	Significant    bool    `json:"significant"` // PValue below the significance level
+}
+
+// ExperimentResult represents the live results of an experiment
+type ExperimentResult struct {
+	ID        string          `json:"id"`
+	Goal      Goal            `json:"goal"`
+	Control   string          `json:"control"`
+	Variants  []VariantResult `json:"variants"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// FunnelStep counts visitors that reached a step of a funnel after completing the
+// steps before it
+type FunnelStep struct {
+	Goal           Goal    `json:"goal"`
+	Visitors       int64   `json:"visitors"`
+	ConversionRate float64 `json:"conversion_rate"` // Share of the first step's visitors
+}
+
+// Funnel reports how many visitors completed each step of an ordered sequence within
+// the window of entering it. Visitors are users, or sessions for anonymous events.
+type Funnel struct {
+	From          time.Time    `json:"from"`
+	To            time.Time    `json:"to"`
+	WindowSeconds float64      `json:"window_seconds"`
+	Steps         []FunnelStep `json:"steps"`
+}
+
+// HistorySource names where a historical query was answered from
+type HistorySource string
+
+const (
+	HistorySourceClickHouse HistorySource = "clickhouse" // Archived raw events in ClickHouse
+	HistorySourceMemory     HistorySource = "memory"     // In-memory analytics and the rollup store
+)
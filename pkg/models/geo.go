@@ -0,0 +1,66 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Metadata keys carrying the visitor's location: an ISO 3166-1 alpha-2 country code such
+// as "US" and an ISO 3166-2 subdivision code within it such as "CA". Trackers may set
+// them, and the producer fills them from CDN GeoIP headers when they are missing.
+const (
+	MetadataCountry = "country"
+	MetadataRegion  = "region"
+)
+
+// GeoStats summarizes page views by visitor location
+type GeoStats struct {
+	Countries        []DimensionCount  `json:"countries"`          // Top countries, with their share of located page views
+	Regions          []DimensionCount  `json:"regions"`            // Top regions as "country-region", e.g. "US-CA"
+	Hourly           []HourlyGeoMetric `json:"hourly"`             // Page views of the top countries per hour, oldest first
+	UnknownPageViews int64             `json:"unknown_page_views"` // Page views without a known country
+}
+
+// HourlyGeoMetric holds one hour's page views per country
+type HourlyGeoMetric struct {
+	Hour      time.Time        `json:"hour"`
+	Countries map[string]int64 `json:"countries"`
+}
+
+// GeoFromEvent returns the event's country and region codes in upper case. The country
+// is "" unless it is two letters and not the "XX" or "T1" placeholders CDNs send for
+// unknown and Tor traffic; the region is "" when the country is.
+func GeoFromEvent(event *AnalyticsEvent) (country, region string) {
+	rawCountry, _ := event.Metadata[MetadataCountry].(string)
+	if country = NormalizeCountry(rawCountry); country == "" {
+		return "", ""
+	}
+	rawRegion, _ := event.Metadata[MetadataRegion].(string)
+	region = strings.ToUpper(strings.TrimSpace(rawRegion))
+	// Accept full ISO 3166-2 codes such as "US-CA" as well as the bare subdivision
+	region = strings.TrimPrefix(region, country+"-")
+	if len(region) > 3 || !isAlphanumeric(region) {
+		region = ""
+	}
+	return country, region
+}
+
+// NormalizeCountry returns an upper case ISO 3166-1 alpha-2 country code, or "" if code
+// is not one
+func NormalizeCountry(code string) string {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if len(code) != 2 || code[0] < 'A' || code[0] > 'Z' || code[1] < 'A' || code[1] > 'Z' || code == "XX" {
+		return ""
+	}
+	return code
+}
+
+// isAlphanumeric reports whether s holds only ASCII upper case letters and digits
+func isAlphanumeric(s string) bool {
+	for _, c := range s {
+		if (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
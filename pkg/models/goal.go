@@ -0,0 +1,52 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Goal defines the event that counts as a conversion, for experiments and campaigns
+type Goal struct {
+	EventType  EventType `json:"event_type"`
+	PathPrefix string    `json:"path_prefix,omitempty"` // Optional URL path prefix the event must match
+}
+
+// Matches reports whether the event is a conversion for this goal
+func (g Goal) Matches(event *AnalyticsEvent) bool {
+	if g.EventType != "" && event.Type != g.EventType {
+		return false
+	}
+	return strings.HasPrefix(event.Path, g.PathPrefix)
+}
+
+// ConversionGoal is a user-defined goal whose completions are tracked in real time. An
+// event completes the goal when it meets every condition that is set; at least one is
+// required.
+type ConversionGoal struct {
+	Name       string            `json:"name"`
+	EventType  EventType         `json:"event_type,omitempty"`
+	Path       string            `json:"path,omitempty"`        // URL path visited; a trailing * matches any path with the prefix
+	Metadata   map[string]string `json:"metadata,omitempty"`    // Metadata field -> required value
+	Value      float64           `json:"value,omitempty"`       // Value credited for each completion
+	ValueField string            `json:"value_field,omitempty"` // Numeric metadata field with the value, used when present
+}
+
+// GoalMetric reports the completions of a conversion goal. A goal completes at most once
+// per session; events without a session each count as a completion.
+type GoalMetric struct {
+	Name           string  `json:"name"`
+	Completions    int64   `json:"completions"`
+	Sessions       int64   `json:"sessions"`        // Sessions that completed the goal
+	ConversionRate float64 `json:"conversion_rate"` // Percentage of all sessions that completed the goal
+	Value          float64 `json:"value"`           // Total value of the completions
+}
+
+// GoalCompletion is published when an event completes a conversion goal
+type GoalCompletion struct {
+	Goal      string    `json:"goal"`
+	Timestamp time.Time `json:"timestamp"`
+	UserID    string    `json:"user_id,omitempty"`
+	SessionID string    `json:"session_id,omitempty"`
+	Path      string    `json:"path,omitempty"`
+	Value     float64   `json:"value,omitempty"`
+}
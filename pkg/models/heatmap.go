@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// Event metadata keys of click positions, in pixels from the top left of the viewport
+const (
+	MetadataXPosition    = "x_position"
+	MetadataYPosition    = "y_position"
+	MetadataScreenWidth  = "screen_width"
+	MetadataScreenHeight = "screen_height"
+)
+
+// Heatmap counts clicks on a page in a grid over the screen. Cells are indexed
+// [row][column], from the top left.
+type Heatmap struct {
+	URL      string    `json:"url"`
+	Path     string    `json:"path"`
+	GridSize int       `json:"grid_size"`
+	Clicks   int64     `json:"clicks"`
+	MaxCell  int64     `json:"max_cell"` // Largest cell count, for scaling colours
+	Cells    [][]int64 `json:"cells"`
+	Updated  time.Time `json:"updated"`
+}
+
+// HeatmapSummary lists a page with a heatmap
+type HeatmapSummary struct {
+	URL    string `json:"url"`
+	Path   string `json:"path"`
+	Clicks int64  `json:"clicks"`
+}
+
+// ClickPosition returns a click's position as fractions of the screen size in [0, 1).
+// It reports false unless the event carries a position inside a known screen size.
+func ClickPosition(event *AnalyticsEvent) (x, y float64, ok bool) {
+	px, okX := event.Metadata[MetadataXPosition].(float64)
+	py, okY := event.Metadata[MetadataYPosition].(float64)
+	width, okW := event.Metadata[MetadataScreenWidth].(float64)
+	height, okH := event.Metadata[MetadataScreenHeight].(float64)
+	if !okX || !okY || !okW || !okH || width <= 0 || height <= 0 {
+		return 0, 0, false
+	}
+
+	x, y = px/width, py/height
+	if x < 0 || x >= 1 || y < 0 || y >= 1 {
+		return 0, 0, false
+	}
+	return x, y, true
+}
@@ -0,0 +1,22 @@
+package models
+
+// Identify events are user_event events whose action metadata is "identify". They link
+// the anonymous ID a visitor was tracked under before logging in to their user ID.
+const (
+	MetadataAction      = "action"
+	MetadataAnonymousID = "anonymous_id" // Defaults to the other users of the event's session
+	ActionIdentify      = "identify"
+)
+
+// IdentityStats reports identity resolution: anonymous IDs merged into identified users,
+// and the aliases still remembered to attribute their later events
+type IdentityStats struct {
+	StitchedUsers int64 `json:"stitched_users"`
+	Aliases       int64 `json:"aliases"`
+}
+
+// IsIdentify reports whether an event identifies its user
+func IsIdentify(event *AnalyticsEvent) bool {
+	action, _ := event.Metadata[MetadataAction].(string)
+	return event.Type == UserEvent && action == ActionIdentify && event.UserID != ""
+}
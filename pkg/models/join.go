@@ -0,0 +1,7 @@
+package models
+
+// ConversionPath is the event type of derived events linking two events of a session,
+// produced by stream join rules. The event takes the later event's time, user, session
+// and URL; Referrer is the earlier event's URL and Metadata holds the rule name, both
+// event IDs, types and paths, and the elapsed milliseconds.
+const ConversionPath EventType = "conversion_path"
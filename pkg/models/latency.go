@@ -0,0 +1,24 @@
+package models
+
+// LatencyStats reports how long events take to move through the pipeline, as measured by
+// the consumer. Percentiles cover the recent window; counts and sums cover every event
+// measured since startup.
+type LatencyStats struct {
+	WindowMinutes int             `json:"window_minutes"`
+	Ingest        LatencyPercents `json:"ingest"`     // Event timestamp to the producer's write to Kafka
+	Broker        LatencyPercents `json:"broker"`     // Producer write to consumer processing
+	EndToEnd      LatencyPercents `json:"end_to_end"` // Event timestamp to consumer processing
+}
+
+// LatencyPercents holds the latency of one pipeline stage, in milliseconds
+type LatencyPercents struct {
+	Count       int64   `json:"count"`        // Events measured since startup
+	SumMs       float64 `json:"sum_ms"`       // Total latency of the events measured since startup
+	WindowCount int64   `json:"window_count"` // Events measured in the window
+	AverageMs   float64 `json:"average_ms"`
+	P50Ms       float64 `json:"p50_ms"`
+	P90Ms       float64 `json:"p90_ms"`
+	P95Ms       float64 `json:"p95_ms"`
+	P99Ms       float64 `json:"p99_ms"`
+	MaxMs       float64 `json:"max_ms"`
+}
@@ -0,0 +1,17 @@
+package models
+
+// Operations reported as pipeline meta events
+const (
+	OperationIngestError       = "ingest_error"
+	OperationKafkaWriteError   = "kafka_write_error"
+	OperationDecodeError       = "decode_error"
+	OperationProcessingFailed  = "processing_failed"
+	OperationDLQRoute          = "dlq_route" // A consumed message was given up on and dropped; there is no dead-letter topic
+	OperationAlertFired        = "alert_fired"
+	OperationConsumerRebalance = "consumer_rebalance"
+	OperationConsumerOutage    = "consumer_outage"
+	OperationConsumerRecovered = "consumer_recovered"
+)
+
+// OperationalHook receives operational events from pipeline components
+type OperationalHook func(operation string, details map[string]interface{})
@@ -0,0 +1,20 @@
+package models
+
+// PathTransition counts sessions that navigated from one page to another at a step of
+// their journey; step 1 leaves the entry page
+type PathTransition struct {
+	Step  int    `json:"step"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Count int64  `json:"count"`
+}
+
+// PathFlow lists the most common transitions of each step, as the links of a Sankey
+// diagram whose nodes are a path at a step
+type PathFlow struct {
+	Depth       int              `json:"depth"`
+	MinCount    int64            `json:"min_count"`
+	Sessions    int64            `json:"sessions"` // Sessions with a page view
+	Transitions []PathTransition `json:"transitions"`
+	Truncated   bool             `json:"truncated"` // Some transitions were not tracked once the limit was reached
+}
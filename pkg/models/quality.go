@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// Problems found by data quality checks
+const (
+	QualityMalformed       = "malformed"        // Payload that is not a valid event
+	QualityMissingField    = "missing_field"    // Required field absent or empty
+	QualityFutureTimestamp = "future_timestamp" // Timestamp further ahead than the allowed clock skew
+	QualityStaleTimestamp  = "stale_timestamp"  // Timestamp older than the allowed age
+	QualityDuplicate       = "duplicate"        // Event ID seen before
+)
+
+// QualityStats reports the data quality of received events since startup, with rates over
+// a recent window for alerting
+type QualityStats struct {
+	Events           int64            `json:"events"`            // Events checked, including malformed payloads
+	Malformed        int64            `json:"malformed"`         // Payloads that could not be decoded as events
+	IncompleteEvents int64            `json:"incomplete_events"` // Events missing at least one required field
+	MissingFields    map[string]int64 `json:"missing_fields"`    // Required field -> events without it
+	FutureTimestamps int64            `json:"future_timestamps"`
+	StaleTimestamps  int64            `json:"stale_timestamps"`
+	Duplicates       int64            `json:"duplicates"`
+	Window           QualityWindow    `json:"window"`
+	RecentIssues     []QualityIssue   `json:"recent_issues"` // Latest events with problems, newest first
+}
+
+// QualityWindow holds the share of events with each kind of problem over the last minutes.
+// Rates are fractions of the events checked in the window, e.g. 0.02 for 2%.
+type QualityWindow struct {
+	Minutes              int     `json:"minutes"`
+	Events               int64   `json:"events"`
+	MalformedRate        float64 `json:"malformed_rate"`
+	IncompleteRate       float64 `json:"incomplete_rate"`
+	TimestampAnomalyRate float64 `json:"timestamp_anomaly_rate"` // Future and stale timestamps
+	DuplicateRate        float64 `json:"duplicate_rate"`
+}
+
+// QualityIssue describes one event that failed quality checks
+type QualityIssue struct {
+	Time      time.Time `json:"time"`
+	EventID   string    `json:"event_id,omitempty"`
+	EventType EventType `json:"event_type,omitempty"`
+	Problems  []string  `json:"problems"` // e.g. "missing_field:user_id", "future_timestamp"
+}
@@ -0,0 +1,17 @@
+package models
+
+// MetadataSampleRate is stamped on events kept by ingestion sampling with the share
+// of events of their type that were kept, e.g. 0.1
+const MetadataSampleRate = "sample_rate"
+
+// SampleWeight returns how many ingested events a sampled event stands for, so counts
+// can be upweighted to estimate the unsampled total. Unsampled events weigh 1. The
+// weight is exact, e.g. 2.5 at a rate of 0.4; integer counters should carry the
+// fractions over rather than round each event's weight.
+func SampleWeight(event *AnalyticsEvent) float64 {
+	rate, ok := event.Metadata[MetadataSampleRate].(float64)
+	if !ok || rate <= 0 || rate >= 1 {
+		return 1
+	}
+	return 1 / rate
+}
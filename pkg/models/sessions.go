@@ -0,0 +1,21 @@
+package models
+
+// SessionStats describes the length of sessions that have ended, either reported by a
+// session event or derived from the session's events once it timed out
+type SessionStats struct {
+	Sessions               int64                 `json:"sessions"` // Sessions ended since startup
+	AverageDurationSeconds float64               `json:"average_duration_seconds"`
+	MedianDurationSeconds  float64               `json:"median_duration_seconds"`
+	PagesPerSession        float64               `json:"pages_per_session"`
+	DurationHistogram      []SessionLengthBucket `json:"duration_histogram"`
+}
+
+// SessionLengthBucket counts sessions whose duration falls in a range. MaxSeconds is 0
+// for the last, open-ended bucket.
+type SessionLengthBucket struct {
+	Label      string  `json:"label"` // e.g. "10-60s"
+	MinSeconds float64 `json:"min_seconds"`
+	MaxSeconds float64 `json:"max_seconds,omitempty"`
+	Count      int64   `json:"count"`
+	Percent    float64 `json:"percent"`
+}
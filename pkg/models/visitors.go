@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// ActiveVisitors counts the visitors active right now: users, or sessions of anonymous
+// visitors, with an event in the last one and five minutes
+type ActiveVisitors struct {
+	Timestamp       time.Time    `json:"timestamp"`
+	LastMinute      int64        `json:"last_minute"`
+	LastFiveMinutes int64        `json:"last_5_minutes"`
+	Pages           []ActivePage `json:"pages"` // Busiest pages first
+}
+
+// ActivePage counts the active visitors whose latest event was on a page
+type ActivePage struct {
+	Path            string `json:"path"`
+	LastMinute      int64  `json:"last_minute"`
+	LastFiveMinutes int64  `json:"last_5_minutes"`
+}
@@ -0,0 +1,335 @@
+// Package notify posts scheduled analytics reports and milestone notifications to
+// webhooks.
+package notify
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/logging"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+// Kind identifies what a notification reports
+type Kind string
+
+const (
+	KindReport        Kind = "report"         // Scheduled summary of a period
+	KindMilestone     Kind = "milestone"      // Total events reached a configured count
+	KindTrafficRecord Kind = "traffic_record" // A completed hour had more events than any before it
+)
+
+// Period is how often a scheduled report is sent
+type Period string
+
+const (
+	Hourly Period = "hourly"
+	Daily  Period = "daily"
+)
+
+// reportTopPages is the number of pages listed in a report
+const reportTopPages = 5
+
+// Notification is the payload posted to webhooks, and the data payload templates render
+type Notification struct {
+	Kind      Kind       `json:"kind"`
+	Title     string     `json:"title"`
+	Timestamp time.Time  `json:"timestamp"`
+	Report    *Report    `json:"report,omitempty"`
+	Milestone *Milestone `json:"milestone,omitempty"`
+}
+
+// Report summarizes the analytics of one period
+type Report struct {
+	Period          Period              `json:"period"`
+	Start           time.Time           `json:"start"`
+	End             time.Time           `json:"end"`
+	Events          int64               `json:"events"` // Events counted during the period
+	TotalEvents     int64               `json:"total_events"`
+	UniqueUsers     int64               `json:"unique_users"`
+	ActiveSessions  int64               `json:"active_sessions"`
+	EventsByType    map[string]int64    `json:"events_by_type"`
+	TopPages        []models.PageMetric `json:"top_pages"`
+	AverageLoadTime float64             `json:"average_load_time_ms"`
+	ErrorRate       float64             `json:"error_rate"`
+}
+
+// Milestone describes a reached event count or a new hourly traffic record
+type Milestone struct {
+	Metric   string     `json:"metric"` // "total_events" or "hourly_events"
+	Value    int64      `json:"value"`
+	Previous int64      `json:"previous,omitempty"` // Traffic records: the record beaten
+	Hour     *time.Time `json:"hour,omitempty"`     // Traffic records: the hour that set it
+}
+
+// Source provides the snapshots notifications are computed from
+type Source interface {
+	GetSnapshot() *models.MetricsSnapshot
+}
+
+// Config selects the notifications sent and how they are delivered
+type Config struct {
+	URLs           []string      // Webhooks every notification is posted to
+	Template       string        // text/template rendering the JSON body; empty posts the Notification
+	Reports        []Period      // Scheduled reports, sent at each period boundary in UTC
+	Milestones     []int64       // Total event counts to announce
+	TrafficRecords bool          // Announce hours with more events than any before them
+	MaxAttempts    int           // Deliveries per webhook before a notification is dropped
+	InitialBackoff time.Duration // Wait before the first retry, doubling after each
+	Timeout        time.Duration // Per-request timeout
+}
+
+// DefaultConfig returns the delivery defaults, with no webhooks or notifications selected
+func DefaultConfig() Config {
+	return Config{
+		MaxAttempts:    5,
+		InitialBackoff: time.Second,
+		Timeout:        10 * time.Second,
+	}
+}
+
+// ParsePeriods parses report periods such as "hourly" and "daily"
+func ParsePeriods(values []string) ([]Period, error) {
+	var periods []Period
+	for _, value := range values {
+		period := Period(strings.ToLower(strings.TrimSpace(value)))
+		if period != Hourly && period != Daily {
+			return nil, fmt.Errorf("unknown report period %q (use hourly or daily)", value)
+		}
+		periods = append(periods, period)
+	}
+	return periods, nil
+}
+
+// ParseMilestones parses positive event counts, returning them in ascending order
+func ParseMilestones(values []string) ([]int64, error) {
+	var milestones []int64
+	for _, value := range values {
+		n, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid milestone %q: must be a positive event count", value)
+		}
+		milestones = append(milestones, n)
+	}
+	sort.Slice(milestones, func(i, j int) bool { return milestones[i] < milestones[j] })
+	return milestones, nil
+}
+
+// Scheduler checks the analytics for due reports and reached milestones and posts them
+// to the configured webhooks. Progress made before the first check is not announced, so
+// a restart doesn't repeat earlier notifications.
+type Scheduler struct {
+	source  Source
+	webhook *webhook
+	config  Config
+	queue   chan Notification
+
+	started     bool
+	periodStart map[Period]time.Time // Start of the period each report is due at the end of
+	periodTotal map[Period]int64     // Total events when that period started
+	milestone   int                  // Index of the next milestone to announce
+	record      int64                // Most events in a completed hour
+	recordHour  time.Time            // Latest hour considered for the record
+}
+
+// New creates a scheduler; it fails if the payload template does not parse
+func New(config Config, source Source) (*Scheduler, error) {
+	defaults := DefaultConfig()
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = defaults.MaxAttempts
+	}
+	if config.InitialBackoff <= 0 {
+		config.InitialBackoff = defaults.InitialBackoff
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaults.Timeout
+	}
+
+	hook, err := newWebhook(config)
+	if err != nil {
+		return nil, err
+	}
+	return &Scheduler{
+		source:      source,
+		webhook:     hook,
+		config:      config,
+		queue:       make(chan Notification, 100),
+		periodStart: make(map[Period]time.Time),
+		periodTotal: make(map[Period]int64),
+	}, nil
+}
+
+// Run checks for notifications every interval and delivers them until the context is
+// cancelled. Deliveries run separately so retries don't delay the checks.
+func (s *Scheduler) Run(ctx context.Context, interval time.Duration) {
+	go s.deliver(ctx)
+
+	s.enqueue(s.Check(time.Now()))
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			s.enqueue(s.Check(now))
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// enqueue queues notifications for delivery, dropping them when the queue is full
+func (s *Scheduler) enqueue(notifications []Notification) {
+	for _, notification := range notifications {
+		select {
+		case s.queue <- notification:
+		default:
+			logging.Warn("Webhook queue is full, dropping notification", "kind", notification.Kind, "title", notification.Title)
+		}
+	}
+}
+
+// deliver posts queued notifications until the context is cancelled
+func (s *Scheduler) deliver(ctx context.Context) {
+	for {
+		select {
+		case notification := <-s.queue:
+			s.webhook.post(ctx, notification)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Check returns the notifications due at now. It is not safe for concurrent use.
+func (s *Scheduler) Check(now time.Time) []Notification {
+	now = now.UTC()
+	snapshot := s.source.GetSnapshot()
+	if !s.started {
+		s.start(snapshot, now)
+		return nil
+	}
+
+	var notifications []Notification
+	for _, period := range s.config.Reports {
+		if end := periodStart(period, now); end.After(s.periodStart[period]) {
+			notifications = append(notifications, s.report(period, end, snapshot, now))
+		}
+	}
+
+	for ; s.milestone < len(s.config.Milestones) && snapshot.TotalEvents >= s.config.Milestones[s.milestone]; s.milestone++ {
+		value := s.config.Milestones[s.milestone]
+		notifications = append(notifications, Notification{
+			Kind:      KindMilestone,
+			Title:     fmt.Sprintf("Milestone reached: %d events", value),
+			Timestamp: now,
+			Milestone: &Milestone{Metric: "total_events", Value: value},
+		})
+	}
+
+	if s.config.TrafficRecords {
+		if notification, ok := s.trafficRecord(snapshot, now); ok {
+			notifications = append(notifications, notification)
+		}
+	}
+	return notifications
+}
+
+// start records the state at the first check without announcing it
+func (s *Scheduler) start(snapshot *models.MetricsSnapshot, now time.Time) {
+	s.started = true
+	for _, period := range s.config.Reports {
+		s.periodStart[period] = periodStart(period, now)
+		s.periodTotal[period] = snapshot.TotalEvents
+	}
+	for s.milestone < len(s.config.Milestones) && snapshot.TotalEvents >= s.config.Milestones[s.milestone] {
+		s.milestone++
+	}
+	s.trafficRecord(snapshot, now)
+}
+
+// report summarizes the period ending at end and starts the next one
+func (s *Scheduler) report(period Period, end time.Time, snapshot *models.MetricsSnapshot, now time.Time) Notification {
+	start := s.periodStart[period]
+	eventsByType := make(map[string]int64, len(snapshot.EventsByType))
+	for eventType, count := range snapshot.EventsByType {
+		eventsByType[string(eventType)] = count
+	}
+	topPages := snapshot.TopPages
+	if len(topPages) > reportTopPages {
+		topPages = topPages[:reportTopPages]
+	}
+
+	report := &Report{
+		Period:          period,
+		Start:           start,
+		End:             end,
+		Events:          max(snapshot.TotalEvents-s.periodTotal[period], 0),
+		TotalEvents:     snapshot.TotalEvents,
+		UniqueUsers:     snapshot.UniqueUsers,
+		ActiveSessions:  snapshot.ActiveSessions,
+		EventsByType:    eventsByType,
+		TopPages:        topPages,
+		AverageLoadTime: snapshot.PerformanceMetrics.AverageLoadTime,
+		ErrorRate:       snapshot.Errors.ErrorRate,
+	}
+	s.periodStart[period] = end
+	s.periodTotal[period] = snapshot.TotalEvents
+
+	return Notification{
+		Kind:      KindReport,
+		Title:     fmt.Sprintf("%s analytics report: %d events", strings.ToUpper(string(period[:1]))+string(period[1:]), report.Events),
+		Timestamp: now,
+		Report:    report,
+	}
+}
+
+// trafficRecord announces the latest completed hour when it beat the record. Hours
+// are only considered once, so a record is announced when its hour completes.
+func (s *Scheduler) trafficRecord(snapshot *models.MetricsSnapshot, now time.Time) (Notification, bool) {
+	current := now.Truncate(time.Hour)
+	latest := s.recordHour
+	var best *models.HourlyMetric
+	for i := range snapshot.HourlyPageViews {
+		hour := &snapshot.HourlyPageViews[i]
+		if !hour.Hour.Before(current) || !hour.Hour.After(s.recordHour) {
+			continue
+		}
+		if hour.Hour.After(latest) {
+			latest = hour.Hour
+		}
+		if hour.Events > s.record && (best == nil || hour.Events > best.Events) {
+			best = hour
+		}
+	}
+	s.recordHour = latest
+	if best == nil {
+		return Notification{}, false
+	}
+
+	previous := s.record
+	s.record = best.Events
+	if previous == 0 {
+		// The first hour seen sets the record without announcing it
+		return Notification{}, false
+	}
+	hour := best.Hour
+	return Notification{
+		Kind:      KindTrafficRecord,
+		Title:     fmt.Sprintf("New traffic record: %d events in an hour", best.Events),
+		Timestamp: now,
+		Milestone: &Milestone{Metric: "hourly_events", Value: best.Events, Previous: previous, Hour: &hour},
+	}, true
+}
+
+// periodStart returns the start of the period containing t, in UTC
+func periodStart(period Period, t time.Time) time.Time {
+	t = t.UTC()
+	if period == Daily {
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	}
+	return t.Truncate(time.Hour)
+}
@@ -0,0 +1,164 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+// staticSource serves a snapshot the test changes between checks
+type staticSource struct {
+	snapshot models.MetricsSnapshot
+}
+
+func (s *staticSource) GetSnapshot() *models.MetricsSnapshot {
+	snapshot := s.snapshot
+	return &snapshot
+}
+
+func TestScheduledReports(t *testing.T) {
+	source := &staticSource{snapshot: models.MetricsSnapshot{TotalEvents: 100}}
+	scheduler, err := New(Config{Reports: []Period{Hourly, Daily}}, source)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	start := time.Date(2024, 3, 1, 22, 30, 0, 0, time.UTC)
+	if got := scheduler.Check(start); len(got) != 0 {
+		t.Fatalf("Expected nothing on the first check, got %+v", got)
+	}
+
+	source.snapshot.TotalEvents = 160
+	if got := scheduler.Check(start.Add(20 * time.Minute)); len(got) != 0 {
+		t.Fatalf("Expected nothing within the hour, got %+v", got)
+	}
+
+	// The hourly report covers the partial first hour
+	got := scheduler.Check(start.Add(31 * time.Minute))
+	if len(got) != 1 || got[0].Kind != KindReport || got[0].Report.Period != Hourly {
+		t.Fatalf("Expected an hourly report, got %+v", got)
+	}
+	if report := got[0].Report; report.Events != 60 || report.TotalEvents != 160 || !report.End.Equal(time.Date(2024, 3, 1, 23, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected report %+v", report)
+	}
+
+	// Both reports are due at midnight
+	source.snapshot.TotalEvents = 200
+	got = scheduler.Check(time.Date(2024, 3, 2, 0, 0, 5, 0, time.UTC))
+	if len(got) != 2 || got[0].Report.Events != 40 || got[1].Report.Period != Daily || got[1].Report.Events != 100 {
+		t.Fatalf("Expected hourly and daily reports, got %+v", got)
+	}
+}
+
+func TestMilestones(t *testing.T) {
+	source := &staticSource{snapshot: models.MetricsSnapshot{TotalEvents: 1500}}
+	scheduler, err := New(Config{Milestones: []int64{1000, 10000, 100000}}, source)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	// Milestones passed before the first check are not announced
+	now := time.Now()
+	scheduler.Check(now)
+	source.snapshot.TotalEvents = 150000
+	got := scheduler.Check(now.Add(time.Minute))
+	if len(got) != 2 || got[0].Milestone.Value != 10000 || got[1].Milestone.Value != 100000 {
+		t.Fatalf("Expected the 10000 and 100000 milestones, got %+v", got)
+	}
+	if got := scheduler.Check(now.Add(2 * time.Minute)); len(got) != 0 {
+		t.Errorf("Expected milestones to be announced once, got %+v", got)
+	}
+}
+
+func TestTrafficRecords(t *testing.T) {
+	hour := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	source := &staticSource{snapshot: models.MetricsSnapshot{HourlyPageViews: []models.HourlyMetric{
+		{Hour: hour.Add(-2 * time.Hour), Events: 50},
+		{Hour: hour.Add(-time.Hour), Events: 80},
+		{Hour: hour, Events: 500}, // Still in progress
+	}}}
+	scheduler, err := New(Config{TrafficRecords: true}, source)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	scheduler.Check(hour.Add(30 * time.Minute))
+
+	got := scheduler.Check(hour.Add(61 * time.Minute))
+	if len(got) != 1 || got[0].Kind != KindTrafficRecord {
+		t.Fatalf("Expected a traffic record once the hour completed, got %+v", got)
+	}
+	if milestone := got[0].Milestone; milestone.Value != 500 || milestone.Previous != 80 || !milestone.Hour.Equal(hour) {
+		t.Errorf("Unexpected record %+v", milestone)
+	}
+	if got := scheduler.Check(hour.Add(62 * time.Minute)); len(got) != 0 {
+		t.Errorf("Expected the record to be announced once, got %+v", got)
+	}
+}
+
+func TestTemplatedDeliveryWithRetry(t *testing.T) {
+	var attempts atomic.Int32
+	bodies := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		bodies <- string(body)
+	}))
+	defer server.Close()
+
+	hook, err := newWebhook(Config{
+		URLs:           []string{server.URL},
+		Template:       `{"text": {{json .Title}}, "value": {{.Milestone.Value}}}`,
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		Timeout:        time.Second,
+	})
+	if err != nil {
+		t.Fatalf("newWebhook failed: %v", err)
+	}
+	hook.post(context.Background(), Notification{Kind: KindMilestone, Title: `1M "events"`, Milestone: &Milestone{Value: 1000000}})
+
+	var payload struct {
+		Text  string `json:"text"`
+		Value int64  `json:"value"`
+	}
+	if err := json.Unmarshal([]byte(<-bodies), &payload); err != nil {
+		t.Fatalf("Expected a JSON payload: %v", err)
+	}
+	if payload.Text != `1M "events"` || payload.Value != 1000000 || attempts.Load() != 2 {
+		t.Errorf("Unexpected payload %+v after %d attempts", payload, attempts.Load())
+	}
+}
+
+func TestDeliveryGivesUpOnClientErrors(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	hook, _ := newWebhook(Config{URLs: []string{server.URL}, MaxAttempts: 3, InitialBackoff: time.Millisecond, Timeout: time.Second})
+	if err := hook.send(context.Background(), server.URL, []byte(`{}`)); err == nil || attempts.Load() != 1 {
+		t.Errorf("Expected one failed attempt, got %d (err %v)", attempts.Load(), err)
+	}
+}
+
+func TestInvalidTemplate(t *testing.T) {
+	if _, err := New(Config{Template: "{{.Title"}, &staticSource{}); err == nil {
+		t.Error("Expected a template parse error")
+	}
+	hook, _ := newWebhook(Config{Template: `{"text": {{.Title}}}`})
+	if _, err := hook.render(Notification{Title: "not quoted"}); err == nil {
+		t.Error("Expected invalid rendered JSON to be rejected")
+	}
+}
@@ -0,0 +1,131 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"text/template"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/logging"
+)
+
+// maxBackoff caps the wait between delivery attempts
+const maxBackoff = 5 * time.Minute
+
+// webhook renders notifications and posts them to every configured URL with retries
+type webhook struct {
+	urls           []string
+	template       *template.Template // nil posts the Notification as JSON
+	client         *http.Client
+	maxAttempts    int
+	initialBackoff time.Duration
+}
+
+// templateFuncs are available to payload templates; json encodes a value, so strings
+// can be embedded safely: {"text": {{json .Title}}}
+var templateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}
+
+func newWebhook(config Config) (*webhook, error) {
+	hook := &webhook{
+		urls:           config.URLs,
+		client:         &http.Client{Timeout: config.Timeout},
+		maxAttempts:    config.MaxAttempts,
+		initialBackoff: config.InitialBackoff,
+	}
+	if config.Template != "" {
+		tmpl, err := template.New("webhook").Funcs(templateFuncs).Parse(config.Template)
+		if err != nil {
+			return nil, fmt.Errorf("invalid webhook template: %w", err)
+		}
+		hook.template = tmpl
+	}
+	return hook, nil
+}
+
+// render returns the JSON body posted for a notification
+func (w *webhook) render(notification Notification) ([]byte, error) {
+	if w.template == nil {
+		return json.Marshal(notification)
+	}
+
+	var body bytes.Buffer
+	if err := w.template.Execute(&body, notification); err != nil {
+		return nil, fmt.Errorf("failed to render webhook template: %w", err)
+	}
+	if !json.Valid(body.Bytes()) {
+		return nil, fmt.Errorf("webhook template rendered invalid JSON: %s", body.String())
+	}
+	return body.Bytes(), nil
+}
+
+// post delivers a notification to every URL, logging the ones that fail
+func (w *webhook) post(ctx context.Context, notification Notification) {
+	body, err := w.render(notification)
+	if err != nil {
+		logging.Error("Failed to build webhook payload", "kind", notification.Kind, "error", err)
+		return
+	}
+	for _, url := range w.urls {
+		if err := w.send(ctx, url, body); err != nil {
+			logging.Error("Failed to deliver webhook", "url", url, "kind", notification.Kind, "error", err)
+		}
+	}
+}
+
+// send posts the body to url, retrying network errors, 429 and 5xx responses with
+// exponential backoff
+func (w *webhook) send(ctx context.Context, url string, body []byte) error {
+	backoff := w.initialBackoff
+	var err error
+	for attempt := 1; attempt <= w.maxAttempts; attempt++ {
+		var retry bool
+		if retry, err = w.attempt(ctx, url, body); err == nil || !retry {
+			return err
+		}
+		if attempt == w.maxAttempts {
+			break
+		}
+
+		logging.Warn("Webhook delivery failed, retrying", "url", url, "attempt", attempt, "backoff", backoff, "error", err)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff = min(2*backoff, maxBackoff)
+	}
+	return fmt.Errorf("giving up after %d attempts: %w", w.maxAttempts, err)
+}
+
+// attempt makes one delivery, reporting whether a failure is worth retrying
+func (w *webhook) attempt(ctx context.Context, url string, body []byte) (retry bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "go-kafka-analytics-pipeline-webhook")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, fmt.Errorf("webhook responded %s", resp.Status)
+	default:
+		return false, fmt.Errorf("webhook responded %s", resp.Status)
+	}
+}
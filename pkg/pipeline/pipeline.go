@@ -0,0 +1,207 @@
+// Package pipeline embeds the analytics engine in other Go programs. An Engine runs
+// events through registered processors, aggregates them with an analytics.Service
+// (which also detects bots, parses user agents and evaluates alerts) and publishes
+// alerts and snapshots on channels. It depends on no transport: Kafka, HTTP and
+// WebSocket are wired up by the commands in cmd/.
+package pipeline
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/analytics"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/logging"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+// Processor examines or changes an event. Returning false drops the event without
+// an error; returning an error stops its processing.
+type Processor interface {
+	Process(ctx context.Context, event *models.AnalyticsEvent) (bool, error)
+}
+
+// ProcessorFunc adapts a function to a Processor
+type ProcessorFunc func(ctx context.Context, event *models.AnalyticsEvent) (bool, error)
+
+// Process calls f
+func (f ProcessorFunc) Process(ctx context.Context, event *models.AnalyticsEvent) (bool, error) {
+	return f(ctx, event)
+}
+
+// Engine processes events into analytics
+type Engine struct {
+	service *analytics.Service
+
+	// Processors run before aggregation, outputs after it
+	processors []Processor
+	outputs    []Processor
+	mu         sync.RWMutex
+
+	alerts    *broadcaster[models.Alert]
+	snapshots *broadcaster[*models.MetricsSnapshot]
+
+	// How often Run evaluates alerts
+	alertInterval time.Duration
+}
+
+// NewEngine creates an engine aggregating into service, which is configured
+// (bot policy, alerts, custom metrics and so on) through its own setters
+func NewEngine(service *analytics.Service) *Engine {
+	e := &Engine{
+		service:       service,
+		alerts:        newBroadcaster[models.Alert](),
+		snapshots:     newBroadcaster[*models.MetricsSnapshot](),
+		alertInterval: analytics.DefaultAlertInterval,
+	}
+	service.SubscribeAlerts(e.alerts.publish)
+	return e
+}
+
+// SetAlertInterval sets how often Run evaluates alerts; it must be called before Run
+func (e *Engine) SetAlertInterval(interval time.Duration) {
+	e.alertInterval = interval
+}
+
+// Service returns the analytics service events are aggregated into
+func (e *Engine) Service() *analytics.Service {
+	return e.service
+}
+
+// RegisterProcessor adds a processor run, in registration order, before events are
+// aggregated, for example to filter, deduplicate or enrich them
+func (e *Engine) RegisterProcessor(p Processor) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.processors = append(e.processors, p)
+}
+
+// RegisterOutput adds a processor run after events are aggregated, for example to
+// export them. Its result and errors do not affect the event, which is already
+// counted; errors are logged.
+func (e *Engine) RegisterOutput(p Processor) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.outputs = append(e.outputs, p)
+}
+
+// Process runs an event through the processors, aggregates it and passes it to the
+// outputs. It is safe for concurrent use.
+func (e *Engine) Process(ctx context.Context, event *models.AnalyticsEvent) error {
+	e.mu.RLock()
+	processors, outputs := e.processors, e.outputs
+	e.mu.RUnlock()
+
+	for _, p := range processors {
+		keep, err := p.Process(ctx, event)
+		if err != nil {
+			return err
+		}
+		if !keep {
+			return nil
+		}
+	}
+
+	if err := e.service.ProcessEvent(event); err != nil {
+		return err
+	}
+
+	for _, p := range outputs {
+		if _, err := p.Process(ctx, event); err != nil {
+			logging.Warn("Output failed to process event", "event_id", event.ID, "error", err)
+		}
+	}
+	return nil
+}
+
+// Snapshot returns the current analytics
+func (e *Engine) Snapshot() *models.MetricsSnapshot {
+	return e.service.GetSnapshot()
+}
+
+// SubscribeAlerts returns a channel receiving alerts as they fire or resolve, whether
+// evaluated by Run or by the service's RunAlerts, and a function that ends the
+// subscription and closes the channel. Alerts are dropped for a subscriber whose
+// buffer is full.
+func (e *Engine) SubscribeAlerts(buffer int) (<-chan models.Alert, func()) {
+	return e.alerts.subscribe(buffer)
+}
+
+// SubscribeSnapshots returns a channel receiving a snapshot every interval of Run,
+// and a function that ends the subscription and closes the channel. Snapshots are
+// dropped for a subscriber whose buffer is full.
+func (e *Engine) SubscribeSnapshots(buffer int) (<-chan *models.MetricsSnapshot, func()) {
+	return e.snapshots.subscribe(buffer)
+}
+
+// Run expires old in-memory data, evaluates alerts every alert interval and publishes
+// a snapshot to the snapshot subscribers every interval, until ctx is done
+func (e *Engine) Run(ctx context.Context, interval time.Duration) {
+	go e.service.RunCleanup(ctx)
+	go e.service.RunAlerts(ctx, e.alertInterval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if e.snapshots.active() {
+				e.snapshots.publish(e.Snapshot())
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// broadcaster fans values out to subscriber channels without blocking the publisher
+type broadcaster[T any] struct {
+	subscribers map[int]chan T
+	next        int
+	mu          sync.Mutex
+}
+
+func newBroadcaster[T any]() *broadcaster[T] {
+	return &broadcaster[T]{subscribers: make(map[int]chan T)}
+}
+
+// subscribe adds a subscriber with the given channel buffer
+func (b *broadcaster[T]) subscribe(buffer int) (<-chan T, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	id := b.next
+	b.next++
+	ch := make(chan T, max(buffer, 0))
+	b.subscribers[id] = ch
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			delete(b.subscribers, id)
+			close(ch)
+		})
+	}
+}
+
+// active reports whether anyone is subscribed
+func (b *broadcaster[T]) active() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subscribers) > 0
+}
+
+// publish sends value to every subscriber with room for it
+func (b *broadcaster[T]) publish(value T) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, ch := range b.subscribers {
+		select {
+		case ch <- value:
+		default:
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/analytics"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+func newEvent(id string) *models.AnalyticsEvent {
+	return &models.AnalyticsEvent{ID: id, Type: models.PageView, UserID: "u1", SessionID: "s1", URL: "https://example.com/", Timestamp: time.Now()}
+}
+
+func TestEngineProcessors(t *testing.T) {
+	engine := NewEngine(analytics.NewService())
+
+	// Processors run in order and can change or drop events
+	var order []string
+	engine.RegisterProcessor(ProcessorFunc(func(ctx context.Context, event *models.AnalyticsEvent) (bool, error) {
+		order = append(order, "tag")
+		event.Path = "/tagged"
+		return true, nil
+	}))
+	engine.RegisterProcessor(ProcessorFunc(func(ctx context.Context, event *models.AnalyticsEvent) (bool, error) {
+		order = append(order, "filter")
+		return event.ID != "drop", nil
+	}))
+	var exported []string
+	engine.RegisterOutput(ProcessorFunc(func(ctx context.Context, event *models.AnalyticsEvent) (bool, error) {
+		exported = append(exported, event.Path)
+		return true, errors.New("output errors are only logged")
+	}))
+
+	for _, id := range []string{"keep", "drop"} {
+		if err := engine.Process(context.Background(), newEvent(id)); err != nil {
+			t.Fatalf("Process(%s) failed: %v", id, err)
+		}
+	}
+
+	if len(order) != 4 || order[0] != "tag" || order[1] != "filter" {
+		t.Errorf("processors ran as %v, want tag then filter per event", order)
+	}
+	if len(exported) != 1 || exported[0] != "/tagged" {
+		t.Errorf("exported %v, want only the kept, tagged event", exported)
+	}
+	if snapshot := engine.Snapshot(); snapshot.TotalEvents != 1 {
+		t.Errorf("TotalEvents = %d, want 1", snapshot.TotalEvents)
+	}
+
+	// A processor error stops processing before aggregation
+	engine.RegisterProcessor(ProcessorFunc(func(ctx context.Context, event *models.AnalyticsEvent) (bool, error) {
+		return false, errors.New("boom")
+	}))
+	if err := engine.Process(context.Background(), newEvent("failed")); err == nil {
+		t.Fatal("expected the processor error")
+	}
+	if snapshot := engine.Snapshot(); snapshot.TotalEvents != 1 {
+		t.Errorf("TotalEvents = %d after a failed event, want 1", snapshot.TotalEvents)
+	}
+}
+
+func TestEngineSubscriptions(t *testing.T) {
+	service := analytics.NewService()
+	service.SetAlerts([]models.AlertConfig{{
+		Name: "Any Traffic", Type: "traffic", Metric: "total_events", Threshold: 0, Operator: "gt", Enabled: true,
+	}})
+	engine := NewEngine(service)
+
+	alerts, unsubscribe := engine.SubscribeAlerts(1)
+	if err := engine.Process(context.Background(), newEvent("e1")); err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	select {
+	case alert := <-alerts:
+		t.Fatalf("Alert %q published while processing; alerts are evaluated on a schedule", alert.Name)
+	default:
+	}
+
+	service.EvaluateAlerts()
+	select {
+	case alert := <-alerts:
+		if alert.Name != "Any Traffic" {
+			t.Errorf("alert = %q, want Any Traffic", alert.Name)
+		}
+	default:
+		t.Fatal("expected an alert on the subscription")
+	}
+	unsubscribe()
+	unsubscribe()
+	if _, open := <-alerts; open {
+		t.Error("unsubscribe should close the channel")
+	}
+
+	snapshots, unsubscribeSnapshots := engine.SubscribeSnapshots(1)
+	defer unsubscribeSnapshots()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go engine.Run(ctx, 10*time.Millisecond)
+
+	select {
+	case snapshot := <-snapshots:
+		if snapshot.TotalEvents != 1 {
+			t.Errorf("snapshot TotalEvents = %d, want 1", snapshot.TotalEvents)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected a snapshot")
+	}
+}
@@ -0,0 +1,176 @@
+package privacy
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+// IPMode is how client IP addresses are anonymized
+type IPMode string
+
+const (
+	IPKeep     IPMode = "keep"     // Stored as received
+	IPTruncate IPMode = "truncate" // Last octet of IPv4 and last 80 bits of IPv6 zeroed
+	IPHash     IPMode = "hash"     // Replaced by a salted hash
+	IPRemove   IPMode = "remove"   // Removed
+)
+
+// ParseIPMode parses an IP mode name; empty keeps addresses
+func ParseIPMode(name string) (IPMode, error) {
+	switch mode := IPMode(strings.ToLower(strings.TrimSpace(name))); mode {
+	case "":
+		return IPKeep, nil
+	case IPKeep, IPTruncate, IPHash, IPRemove:
+		return mode, nil
+	default:
+		return IPKeep, fmt.Errorf("unknown IP mode %q (use keep, truncate, hash or remove)", name)
+	}
+}
+
+// DefaultSaltRotation is how long a hashing salt is used before the next one
+const DefaultSaltRotation = 24 * time.Hour
+
+// Config selects what is anonymized
+type Config struct {
+	IPMode      IPMode
+	HashUserIDs bool
+
+	// Secret the salts are derived from. Producers sharing a secret hash identically;
+	// empty uses a random secret, so hashes also change on restart.
+	SaltSecret   string
+	SaltRotation time.Duration // 0 uses DefaultSaltRotation
+
+	// Metadata keys removed from every event, matched case-insensitively
+	StripMetadata []string
+}
+
+// Enabled reports whether the config changes events at all
+func (c Config) Enabled() bool {
+	return (c.IPMode != "" && c.IPMode != IPKeep) || c.HashUserIDs || len(c.StripMetadata) > 0
+}
+
+// Scrubber anonymizes events before they leave the collector. Hashes use a salt that
+// rotates every SaltRotation, so hashed IDs can be counted within a period but cannot be
+// linked across periods or reversed by hashing known values without the secret.
+type Scrubber struct {
+	config Config
+	secret []byte
+	strip  map[string]bool
+	now    func() time.Time
+
+	mu         sync.Mutex
+	saltPeriod int64
+	salt       []byte
+}
+
+// NewScrubber creates a scrubber
+func NewScrubber(config Config) *Scrubber {
+	if config.SaltRotation <= 0 {
+		config.SaltRotation = DefaultSaltRotation
+	}
+
+	secret := []byte(config.SaltSecret)
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		rand.Read(secret)
+	}
+
+	strip := make(map[string]bool, len(config.StripMetadata))
+	for _, key := range config.StripMetadata {
+		strip[strings.ToLower(key)] = true
+	}
+
+	return &Scrubber{
+		config:     config,
+		secret:     secret,
+		strip:      strip,
+		now:        time.Now,
+		saltPeriod: -1,
+	}
+}
+
+// Scrub anonymizes the event in place
+func (s *Scrubber) Scrub(event *models.AnalyticsEvent) {
+	switch s.config.IPMode {
+	case IPTruncate:
+		event.IPAddress = TruncateIP(event.IPAddress)
+	case IPHash:
+		if event.IPAddress != "" {
+			event.IPAddress = s.hash("ip", event.IPAddress)
+		}
+	case IPRemove:
+		event.IPAddress = ""
+	}
+
+	event.UserID = s.UserID(event.UserID)
+
+	if len(s.strip) > 0 {
+		for key := range event.Metadata {
+			if s.strip[strings.ToLower(key)] {
+				delete(event.Metadata, key)
+			}
+		}
+	}
+}
+
+// UserID returns the form a user ID is stored in: its hash under the current salt when
+// user IDs are hashed, otherwise the ID itself
+func (s *Scrubber) UserID(userID string) string {
+	if !s.config.HashUserIDs || userID == "" {
+		return userID
+	}
+	return s.hash("user", userID)
+}
+
+// hash returns a salted HMAC of the value; kind separates the IP and user ID namespaces
+func (s *Scrubber) hash(kind, value string) string {
+	mac := hmac.New(sha256.New, s.currentSalt())
+	mac.Write([]byte(kind + ":" + value))
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// currentSalt returns the salt of the current rotation period, derived from the secret
+// so every producer sharing it uses the same salt
+func (s *Scrubber) currentSalt() []byte {
+	period := s.now().UnixNano() / int64(s.config.SaltRotation)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if period != s.saltPeriod {
+		mac := hmac.New(sha256.New, s.secret)
+		binary.Write(mac, binary.BigEndian, period)
+		s.salt = mac.Sum(nil)
+		s.saltPeriod = period
+	}
+	return s.salt
+}
+
+// TruncateIP zeroes the host part of an address: the last octet of IPv4 addresses and
+// all but the first 48 bits of IPv6 addresses. Values that are not IPs are removed.
+func TruncateIP(address string) string {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return ""
+	}
+	if v4 := ip.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(24, 32)).String()
+	}
+	return ip.Mask(net.CIDRMask(48, 128)).String()
+}
+
+// DoNotTrack reports whether the request carries a Do Not Track or Global Privacy
+// Control signal
+func DoNotTrack(r *http.Request) bool {
+	return r.Header.Get("DNT") == "1" || r.Header.Get("Sec-GPC") == "1"
+}
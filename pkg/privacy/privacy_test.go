@@ -0,0 +1,59 @@
+package privacy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+func TestTruncateIP(t *testing.T) {
+	tests := map[string]string{
+		"203.0.113.57":                 "203.0.113.0",
+		"2001:db8:85a3:1234::8a2e:370": "2001:db8:85a3::",
+		"not an ip":                    "",
+	}
+	for input, want := range tests {
+		if got := TruncateIP(input); got != want {
+			t.Errorf("TruncateIP(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestScrubHashesWithRotatingSalt(t *testing.T) {
+	scrubber := NewScrubber(Config{
+		IPMode:        IPHash,
+		HashUserIDs:   true,
+		SaltSecret:    "secret",
+		SaltRotation:  time.Hour,
+		StripMetadata: []string{"Email"},
+	})
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	scrubber.now = func() time.Time { return now }
+
+	scrub := func() models.AnalyticsEvent {
+		event := models.AnalyticsEvent{
+			UserID:    "user-1",
+			IPAddress: "203.0.113.57",
+			Metadata:  map[string]interface{}{"email": "a@example.com", "plan": "pro"},
+		}
+		scrubber.Scrub(&event)
+		return event
+	}
+
+	first, second := scrub(), scrub()
+	if first.UserID == "user-1" || first.IPAddress == "203.0.113.57" || first.UserID == first.IPAddress {
+		t.Fatalf("Expected distinct hashes, got %+v", first)
+	}
+	if first.UserID != second.UserID {
+		t.Error("Hashes should be stable within a rotation period")
+	}
+	if _, ok := first.Metadata["email"]; ok || first.Metadata["plan"] != "pro" {
+		t.Errorf("Expected only email to be stripped, got %v", first.Metadata)
+	}
+
+	now = now.Add(time.Hour)
+	if rotated := scrub(); rotated.UserID == first.UserID {
+		t.Error("Hashes should change when the salt rotates")
+	}
+}
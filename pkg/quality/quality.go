@@ -0,0 +1,300 @@
+// Package quality tracks the data quality of received events: payloads that can't be
+// decoded, missing required fields, suspicious timestamps and repeated event IDs.
+package quality
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/idset"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+// recentIssues is the number of events with problems kept for reporting
+const recentIssues = 20
+
+// Config sets what the tracker treats as a problem
+type Config struct {
+	RequiredFields  []string      // Event fields that must be set, by JSON name, e.g. user_id
+	MaxFutureSkew   time.Duration // How far ahead of the clock a timestamp may be
+	MaxAge          time.Duration // How old a timestamp may be
+	Window          time.Duration // Period the alerting rates cover
+	DuplicateIDs    int           // Event IDs remembered to detect duplicates
+	DuplicateWindow time.Duration // How long an event ID is remembered
+}
+
+// DefaultConfig returns the default quality checks
+func DefaultConfig() Config {
+	return Config{
+		RequiredFields:  []string{"user_id", "session_id", "url"},
+		MaxFutureSkew:   5 * time.Minute,
+		MaxAge:          7 * 24 * time.Hour,
+		Window:          5 * time.Minute,
+		DuplicateIDs:    100000,
+		DuplicateWindow: time.Hour,
+	}
+}
+
+// eventFields reads the fields that can be required, by JSON name
+var eventFields = map[string]func(*models.AnalyticsEvent) string{
+	"id":         func(e *models.AnalyticsEvent) string { return e.ID },
+	"user_id":    func(e *models.AnalyticsEvent) string { return e.UserID },
+	"session_id": func(e *models.AnalyticsEvent) string { return e.SessionID },
+	"url":        func(e *models.AnalyticsEvent) string { return e.URL },
+	"path":       func(e *models.AnalyticsEvent) string { return e.Path },
+	"referrer":   func(e *models.AnalyticsEvent) string { return e.Referrer },
+	"user_agent": func(e *models.AnalyticsEvent) string { return e.UserAgent },
+	"ip_address": func(e *models.AnalyticsEvent) string { return e.IPAddress },
+	"timestamp": func(e *models.AnalyticsEvent) string {
+		if e.Timestamp.IsZero() {
+			return ""
+		}
+		return "set"
+	},
+}
+
+// ValidateConfig checks that every required field can be checked
+func ValidateConfig(config Config) error {
+	for _, field := range config.RequiredFields {
+		if _, ok := eventFields[field]; !ok {
+			return fmt.Errorf("unknown required field %q", field)
+		}
+	}
+	return nil
+}
+
+// counts holds the problems found in one minute
+type counts struct {
+	events, malformed, incomplete, timestamps, duplicates int64
+}
+
+// Tracker records the quality of events as they are received. It is safe for concurrent use.
+type Tracker struct {
+	config Config
+	seen   *idset.Set
+
+	mu               sync.Mutex
+	events           int64
+	malformed        int64
+	incomplete       int64
+	missingFields    map[string]int64
+	futureTimestamps int64
+	staleTimestamps  int64
+	duplicates       int64
+	minutes          map[int64]*counts // Unix minute -> problems, for the window rates
+	issues           []models.QualityIssue
+}
+
+// NewTracker creates a tracker with the given checks. Unknown required fields are ignored;
+// use ValidateConfig to report them.
+func NewTracker(config Config) *Tracker {
+	fields := config.RequiredFields[:0:0]
+	for _, field := range config.RequiredFields {
+		if _, ok := eventFields[field]; ok {
+			fields = append(fields, field)
+		}
+	}
+	config.RequiredFields = fields
+
+	return &Tracker{
+		config:        config,
+		seen:          idset.New(config.DuplicateIDs, config.DuplicateWindow),
+		missingFields: make(map[string]int64),
+		minutes:       make(map[int64]*counts),
+	}
+}
+
+// Malformed records a payload that could not be decoded as an event
+func (t *Tracker) Malformed(now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.events++
+	t.malformed++
+	minute := t.minute(now)
+	minute.events++
+	minute.malformed++
+	t.addIssue(models.QualityIssue{Time: now, Problems: []string{models.QualityMalformed}})
+}
+
+// Observe checks a decoded event and returns its problems, if any. Events without an ID
+// are not checked for duplicates.
+func (t *Tracker) Observe(event *models.AnalyticsEvent, now time.Time) []string {
+	var problems, missing []string
+	for _, field := range t.config.RequiredFields {
+		if eventFields[field](event) == "" {
+			missing = append(missing, field)
+			problems = append(problems, models.QualityMissingField+":"+field)
+		}
+	}
+
+	future := !event.Timestamp.IsZero() && event.Timestamp.After(now.Add(t.config.MaxFutureSkew))
+	stale := !event.Timestamp.IsZero() && event.Timestamp.Before(now.Add(-t.config.MaxAge))
+	if future {
+		problems = append(problems, models.QualityFutureTimestamp)
+	}
+	if stale {
+		problems = append(problems, models.QualityStaleTimestamp)
+	}
+
+	duplicate := false
+	if event.ID != "" {
+		duplicate = t.seen.Add(event.ID)
+	}
+	if duplicate {
+		problems = append(problems, models.QualityDuplicate)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.events++
+	minute := t.minute(now)
+	minute.events++
+	if len(missing) > 0 {
+		t.incomplete++
+		minute.incomplete++
+		for _, field := range missing {
+			t.missingFields[field]++
+		}
+	}
+	if future {
+		t.futureTimestamps++
+	}
+	if stale {
+		t.staleTimestamps++
+	}
+	if future || stale {
+		minute.timestamps++
+	}
+	if duplicate {
+		t.duplicates++
+		minute.duplicates++
+	}
+	if len(problems) > 0 {
+		t.addIssue(models.QualityIssue{Time: now, EventID: event.ID, EventType: event.Type, Problems: problems})
+	}
+	return problems
+}
+
+// minute returns the counts of now's minute, dropping minutes that left the window.
+// The caller must hold mu.
+func (t *Tracker) minute(now time.Time) *counts {
+	key := now.Truncate(time.Minute).Unix()
+	minute := t.minutes[key]
+	if minute == nil {
+		cutoff := t.windowStart(now)
+		for start := range t.minutes {
+			if start < cutoff {
+				delete(t.minutes, start)
+			}
+		}
+		minute = &counts{}
+		t.minutes[key] = minute
+	}
+	return minute
+}
+
+// windowStart returns the first minute counted towards the rates at now
+func (t *Tracker) windowStart(now time.Time) int64 {
+	return now.Add(-t.config.Window).Truncate(time.Minute).Unix()
+}
+
+// addIssue keeps an event with problems among the most recent ones. The caller must hold mu.
+func (t *Tracker) addIssue(issue models.QualityIssue) {
+	t.issues = append(t.issues, issue)
+	if len(t.issues) > recentIssues {
+		t.issues = t.issues[len(t.issues)-recentIssues:]
+	}
+}
+
+// Stats returns the totals since the tracker was created and the rates over the window
+// ending at now
+func (t *Tracker) Stats(now time.Time) models.QualityStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	stats := models.QualityStats{
+		Events:           t.events,
+		Malformed:        t.malformed,
+		IncompleteEvents: t.incomplete,
+		MissingFields:    make(map[string]int64, len(t.missingFields)),
+		FutureTimestamps: t.futureTimestamps,
+		StaleTimestamps:  t.staleTimestamps,
+		Duplicates:       t.duplicates,
+		Window:           models.QualityWindow{Minutes: int(t.config.Window / time.Minute)},
+		RecentIssues:     make([]models.QualityIssue, 0, len(t.issues)),
+	}
+	for field, count := range t.missingFields {
+		stats.MissingFields[field] = count
+	}
+	for i := len(t.issues) - 1; i >= 0; i-- {
+		stats.RecentIssues = append(stats.RecentIssues, t.issues[i])
+	}
+
+	var window counts
+	cutoff := t.windowStart(now)
+	for start, minute := range t.minutes {
+		if start >= cutoff {
+			window.events += minute.events
+			window.malformed += minute.malformed
+			window.incomplete += minute.incomplete
+			window.timestamps += minute.timestamps
+			window.duplicates += minute.duplicates
+		}
+	}
+	stats.Window.Events = window.events
+	if window.events > 0 {
+		total := float64(window.events)
+		stats.Window.MalformedRate = float64(window.malformed) / total
+		stats.Window.IncompleteRate = float64(window.incomplete) / total
+		stats.Window.TimestampAnomalyRate = float64(window.timestamps) / total
+		stats.Window.DuplicateRate = float64(window.duplicates) / total
+	}
+	return stats
+}
+
+// WritePrometheus writes quality counters in the Prometheus text format, with metric
+// names starting with prefix, e.g. "analytics_quality"
+func WritePrometheus(w io.Writer, prefix string, stats models.QualityStats) {
+	fmt.Fprintf(w, "# HELP %s_events_total Events checked for data quality, including malformed payloads.\n", prefix)
+	fmt.Fprintf(w, "# TYPE %s_events_total counter\n", prefix)
+	fmt.Fprintf(w, "%s_events_total %d\n", prefix, stats.Events)
+
+	fmt.Fprintf(w, "# HELP %s_problems_total Events with a data quality problem, by problem.\n", prefix)
+	fmt.Fprintf(w, "# TYPE %s_problems_total counter\n", prefix)
+	fmt.Fprintf(w, "%s_problems_total{problem=%q} %d\n", prefix, models.QualityMalformed, stats.Malformed)
+	fmt.Fprintf(w, "%s_problems_total{problem=%q} %d\n", prefix, models.QualityMissingField, stats.IncompleteEvents)
+	fmt.Fprintf(w, "%s_problems_total{problem=%q} %d\n", prefix, models.QualityFutureTimestamp, stats.FutureTimestamps)
+	fmt.Fprintf(w, "%s_problems_total{problem=%q} %d\n", prefix, models.QualityStaleTimestamp, stats.StaleTimestamps)
+	fmt.Fprintf(w, "%s_problems_total{problem=%q} %d\n", prefix, models.QualityDuplicate, stats.Duplicates)
+
+	fields := make([]string, 0, len(stats.MissingFields))
+	for field := range stats.MissingFields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	fmt.Fprintf(w, "# HELP %s_missing_fields_total Events without a required field, by field.\n", prefix)
+	fmt.Fprintf(w, "# TYPE %s_missing_fields_total counter\n", prefix)
+	for _, field := range fields {
+		fmt.Fprintf(w, "%s_missing_fields_total{field=%q} %d\n", prefix, field, stats.MissingFields[field])
+	}
+
+	rates := []struct {
+		problem string
+		rate    float64
+	}{
+		{models.QualityMalformed, stats.Window.MalformedRate},
+		{models.QualityMissingField, stats.Window.IncompleteRate},
+		{"timestamp", stats.Window.TimestampAnomalyRate},
+		{models.QualityDuplicate, stats.Window.DuplicateRate},
+	}
+	fmt.Fprintf(w, "# HELP %s_rate Share of recent events with a data quality problem, over the last %d minutes.\n", prefix, stats.Window.Minutes)
+	fmt.Fprintf(w, "# TYPE %s_rate gauge\n", prefix)
+	for _, rate := range rates {
+		fmt.Fprintf(w, "%s_rate{problem=%q} %g\n", prefix, rate.problem, rate.rate)
+	}
+}
@@ -0,0 +1,91 @@
+package quality
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+func TestTrackerFindsProblems(t *testing.T) {
+	tracker := NewTracker(DefaultConfig())
+	now := time.Now()
+
+	complete := &models.AnalyticsEvent{ID: "e1", Type: models.PageView, UserID: "u1", SessionID: "s1", URL: "https://example.com", Timestamp: now}
+	if problems := tracker.Observe(complete, now); len(problems) != 0 {
+		t.Errorf("expected no problems, got %v", problems)
+	}
+	if problems := tracker.Observe(complete, now); len(problems) != 1 || problems[0] != models.QualityDuplicate {
+		t.Errorf("expected a repeated ID to be a duplicate, got %v", problems)
+	}
+
+	incomplete := &models.AnalyticsEvent{ID: "e2", Type: models.Click, SessionID: "s1", Timestamp: now.Add(time.Hour)}
+	problems := tracker.Observe(incomplete, now)
+	expected := []string{"missing_field:user_id", "missing_field:url", models.QualityFutureTimestamp}
+	if strings.Join(problems, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected %v, got %v", expected, problems)
+	}
+
+	stale := &models.AnalyticsEvent{ID: "e3", Type: models.Click, UserID: "u1", SessionID: "s1", URL: "https://example.com", Timestamp: now.Add(-30 * 24 * time.Hour)}
+	if problems := tracker.Observe(stale, now); len(problems) != 1 || problems[0] != models.QualityStaleTimestamp {
+		t.Errorf("expected a stale timestamp, got %v", problems)
+	}
+	tracker.Malformed(now)
+
+	stats := tracker.Stats(now)
+	if stats.Events != 5 || stats.Malformed != 1 || stats.IncompleteEvents != 1 || stats.Duplicates != 1 ||
+		stats.FutureTimestamps != 1 || stats.StaleTimestamps != 1 {
+		t.Errorf("unexpected totals %+v", stats)
+	}
+	if stats.MissingFields["user_id"] != 1 || stats.MissingFields["url"] != 1 || stats.MissingFields["session_id"] != 0 {
+		t.Errorf("unexpected missing fields %v", stats.MissingFields)
+	}
+	if stats.Window.Events != 5 || stats.Window.MalformedRate != 0.2 || stats.Window.TimestampAnomalyRate != 0.4 {
+		t.Errorf("unexpected window %+v", stats.Window)
+	}
+	if len(stats.RecentIssues) != 4 || stats.RecentIssues[0].Problems[0] != models.QualityMalformed {
+		t.Errorf("expected 4 issues, newest first, got %+v", stats.RecentIssues)
+	}
+
+	// The window only covers recent minutes while totals keep counting
+	later := tracker.Stats(now.Add(time.Hour))
+	if later.Window.Events != 0 || later.Window.MalformedRate != 0 || later.Events != 5 {
+		t.Errorf("expected the window to expire, got %+v", later)
+	}
+}
+
+func TestNewTrackerIgnoresUnknownFields(t *testing.T) {
+	config := DefaultConfig()
+	config.RequiredFields = []string{"user_id", "favourite_colour"}
+	if err := ValidateConfig(config); err == nil {
+		t.Error("expected an unknown field to be reported")
+	}
+
+	tracker := NewTracker(config)
+	problems := tracker.Observe(&models.AnalyticsEvent{Type: models.Click, UserID: "u1"}, time.Now())
+	if len(problems) != 0 {
+		t.Errorf("expected only known fields checked, got %v", problems)
+	}
+}
+
+func TestWritePrometheus(t *testing.T) {
+	tracker := NewTracker(DefaultConfig())
+	tracker.Malformed(time.Now())
+	tracker.Observe(&models.AnalyticsEvent{Type: models.Click}, time.Now())
+
+	var output bytes.Buffer
+	WritePrometheus(&output, "analytics_quality", tracker.Stats(time.Now()))
+	for _, line := range []string{
+		"analytics_quality_events_total 2",
+		`analytics_quality_problems_total{problem="malformed"} 1`,
+		`analytics_quality_problems_total{problem="missing_field"} 1`,
+		`analytics_quality_missing_fields_total{field="user_id"} 1`,
+		`analytics_quality_rate{problem="malformed"} 0.5`,
+	} {
+		if !strings.Contains(output.String(), line+"\n") {
+			t.Errorf("expected %q in:\n%s", line, output.String())
+		}
+	}
+}
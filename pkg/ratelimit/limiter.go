@@ -0,0 +1,102 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// limit is a refill rate and bucket size
+type limit struct {
+	rate  float64 // tokens added per second
+	burst float64 // maximum bucket size
+}
+
+// newLimit converts a per-minute rate and burst into a limit
+func newLimit(requestsPerMinute, burst int) limit {
+	if burst < 1 {
+		burst = 1
+	}
+	return limit{
+		rate:  float64(requestsPerMinute) / 60,
+		burst: float64(burst),
+	}
+}
+
+// bucket is a single token bucket
+type bucket struct {
+	limit
+	tokens   float64
+	lastSeen time.Time
+}
+
+// Limiter is a keyed token-bucket rate limiter
+type Limiter struct {
+	defaultLimit limit
+	overrides    map[string]limit
+
+	buckets map[string]*bucket
+	mu      sync.Mutex
+}
+
+// NewLimiter creates a limiter allowing requestsPerMinute per key with the given burst size
+func NewLimiter(requestsPerMinute, burst int) *Limiter {
+	return &Limiter{
+		defaultLimit: newLimit(requestsPerMinute, burst),
+		overrides:    make(map[string]limit),
+		buckets:      make(map[string]*bucket),
+	}
+}
+
+// SetKeyLimit overrides the default limit for a single key
+func (l *Limiter) SetKeyLimit(key string, requestsPerMinute, burst int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.overrides[key] = newLimit(requestsPerMinute, burst)
+	delete(l.buckets, key)
+}
+
+// Allow reports whether a request for key may proceed, consuming a token if so
+func (l *Limiter) Allow(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+
+	// Drop idle buckets occasionally to keep memory bounded
+	if len(l.buckets) > 10000 {
+		l.evictIdle(now)
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		keyLimit, ok := l.overrides[key]
+		if !ok {
+			keyLimit = l.defaultLimit
+		}
+		b = &bucket{limit: keyLimit, tokens: keyLimit.burst, lastSeen: now}
+		l.buckets[key] = b
+	}
+
+	// Refill tokens for the time elapsed since the last request
+	b.tokens += now.Sub(b.lastSeen).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.lastSeen = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// evictIdle removes buckets that have fully refilled
+func (l *Limiter) evictIdle(now time.Time) {
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.lastSeen).Seconds()*b.rate >= b.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package ratelimit
+
+import "testing"
+
+func TestLimiterBurst(t *testing.T) {
+	limiter := NewLimiter(60, 3)
+
+	for i := 0; i < 3; i++ {
+		if !limiter.Allow("client") {
+			t.Fatalf("Request %d should be allowed within burst", i+1)
+		}
+	}
+	if limiter.Allow("client") {
+		t.Error("Request beyond burst should be rejected")
+	}
+
+	// Other keys have their own bucket
+	if !limiter.Allow("other") {
+		t.Error("Separate key should be allowed")
+	}
+}
+
+func TestLimiterKeyOverride(t *testing.T) {
+	limiter := NewLimiter(60, 1)
+	limiter.SetKeyLimit("premium", 600, 5)
+
+	allowed := 0
+	for i := 0; i < 10; i++ {
+		if limiter.Allow("premium") {
+			allowed++
+		}
+	}
+	if allowed != 5 {
+		t.Errorf("Expected 5 requests allowed for overridden key, got %d", allowed)
+	}
+}
@@ -0,0 +1,123 @@
+// Package reload re-applies configuration while the pipeline runs, on SIGHUP or on
+// request, so operators can tune it under traffic without a restart.
+package reload
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/logging"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/utils"
+)
+
+// Step applies one group of settings from the current environment
+type Step struct {
+	Name  string                          // Reported in results, e.g. "sampling"
+	Keys  []string                        // Environment variables the step reads
+	Apply func(ctx context.Context) error // Keeps the current settings when it fails
+}
+
+// Result reports what a reload changed
+type Result struct {
+	Time            time.Time         `json:"time"`
+	Changed         []string          `json:"changed"`          // Variables whose value changed in the config file
+	Applied         []string          `json:"applied"`          // Steps applied
+	RestartRequired []string          `json:"restart_required"` // Changed variables that only take effect on restart
+	Errors          map[string]string `json:"errors,omitempty"` // Failed steps, or "config_file", and why
+}
+
+// Reloader re-reads the config file and applies the registered steps. Reloads run one
+// at a time.
+type Reloader struct {
+	configFile string // KEY=VALUE file re-read on each reload; empty uses only the environment
+	refresh    func() // Re-reads settings from the environment before the steps run
+	steps      []Step
+	mu         sync.Mutex
+}
+
+// New creates a reloader for the config file, which may be empty. refresh, if not nil,
+// runs after the file is loaded, e.g. to re-read package-level settings.
+func New(configFile string, refresh func()) *Reloader {
+	return &Reloader{configFile: configFile, refresh: refresh}
+}
+
+// Register adds a step run on every reload, in registration order. Steps must be
+// registered before reloads start.
+func (r *Reloader) Register(step Step) {
+	r.steps = append(r.steps, step)
+}
+
+// Reload re-reads the config file into the environment and applies every step. Steps
+// run even when the file cannot be read, re-applying the current environment.
+func (r *Reloader) Reload(ctx context.Context) Result {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := Result{Time: time.Now(), Changed: []string{}, Applied: []string{}, RestartRequired: []string{}}
+	if r.configFile != "" {
+		changed, err := utils.LoadConfigFile(r.configFile)
+		if err != nil {
+			result.addError("config_file", err)
+		}
+		if changed != nil {
+			result.Changed = changed
+		}
+	}
+
+	if r.refresh != nil {
+		r.refresh()
+	}
+
+	reloadable := make(map[string]bool)
+	for _, step := range r.steps {
+		for _, key := range step.Keys {
+			reloadable[key] = true
+		}
+		if err := step.Apply(ctx); err != nil {
+			result.addError(step.Name, err)
+			continue
+		}
+		result.Applied = append(result.Applied, step.Name)
+	}
+	for _, key := range result.Changed {
+		if !reloadable[key] {
+			result.RestartRequired = append(result.RestartRequired, key)
+		}
+	}
+	sort.Strings(result.RestartRequired)
+
+	logging.Info("Configuration reloaded", "changed", result.Changed, "applied", result.Applied, "restart_required", result.RestartRequired, "errors", len(result.Errors))
+	for name, message := range result.Errors {
+		logging.Warn("Configuration reload step failed, keeping the current settings", "step", name, "error", message)
+	}
+	return result
+}
+
+// Run reloads on every SIGHUP until the context is cancelled
+func (r *Reloader) Run(ctx context.Context) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	for {
+		select {
+		case <-hangup:
+			logging.Info("Received SIGHUP, reloading configuration")
+			r.Reload(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (r *Result) addError(name string, err error) {
+	if r.Errors == nil {
+		r.Errors = make(map[string]string)
+	}
+	r.Errors[name] = err.Error()
+}
@@ -0,0 +1,75 @@
+package reload
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/utils"
+)
+
+func TestReloadAppliesConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pipeline.env")
+	write := func(content string) {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("RELOAD_TEST_RATE=10\nRELOAD_TEST_PORT=8080\n")
+	t.Cleanup(func() {
+		os.Unsetenv("RELOAD_TEST_RATE")
+		os.Unsetenv("RELOAD_TEST_PORT")
+	})
+
+	var rate int
+	reloader := New(path, nil)
+	reloader.Register(Step{
+		Name: "rate",
+		Keys: []string{"RELOAD_TEST_RATE"},
+		Apply: func(context.Context) error {
+			rate = utils.GetEnvInt("RELOAD_TEST_RATE", 0)
+			return nil
+		},
+	})
+	reloader.Register(Step{Name: "broken", Apply: func(context.Context) error { return errors.New("invalid rules") }})
+
+	result := reloader.Reload(context.Background())
+	if rate != 10 || len(result.Applied) != 1 || result.Errors["broken"] != "invalid rules" {
+		t.Fatalf("Unexpected first reload: rate %d, result %+v", rate, result)
+	}
+
+	write("# Tuned under load\nRELOAD_TEST_RATE=\"25\"\nRELOAD_TEST_PORT=9090\n")
+	result = reloader.Reload(context.Background())
+	if rate != 25 {
+		t.Errorf("Expected the new rate applied, got %d", rate)
+	}
+	if len(result.Changed) != 2 || len(result.RestartRequired) != 1 || result.RestartRequired[0] != "RELOAD_TEST_PORT" {
+		t.Errorf("Expected the port change to require a restart, got %+v", result)
+	}
+}
+
+func TestEnvironmentTakesPrecedence(t *testing.T) {
+	t.Setenv("RELOAD_TEST_LIMIT", "5")
+	path := filepath.Join(t.TempDir(), "pipeline.env")
+	os.WriteFile(path, []byte("RELOAD_TEST_LIMIT=50\n"), 0o644)
+
+	result := New(path, nil).Reload(context.Background())
+	if got, _ := strconv.Atoi(os.Getenv("RELOAD_TEST_LIMIT")); got != 5 || slices.Contains(result.Changed, "RELOAD_TEST_LIMIT") {
+		t.Errorf("Expected the environment to win over the file, got %d (changed %v)", got, result.Changed)
+	}
+}
+
+func TestUnreadableConfigFile(t *testing.T) {
+	applied := false
+	reloader := New(filepath.Join(t.TempDir(), "missing.env"), nil)
+	reloader.Register(Step{Name: "alerts", Apply: func(context.Context) error { applied = true; return nil }})
+
+	result := reloader.Reload(context.Background())
+	if result.Errors["config_file"] == "" || !applied {
+		t.Errorf("Expected the error reported and steps still applied, got %+v", result)
+	}
+}
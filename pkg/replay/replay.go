@@ -0,0 +1,103 @@
+package replay
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+// Metadata keys of session_replay events
+const (
+	MetadataSequence = "sequence" // Position of the chunk within the session, starting at 0
+	MetadataData     = "data"     // Base64-encoded gzip of a JSON array of DOM events
+)
+
+// Limits that keep a single chunk from exhausting memory
+const (
+	MaxCompressedSize   = 512 << 10 // 512KB
+	MaxDecompressedSize = 8 << 20   // 8MB
+)
+
+// sessionIDPattern restricts session IDs to characters that are safe as file names
+var sessionIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,128}$`)
+
+// Chunk is a compressed batch of DOM events recorded for a session
+type Chunk struct {
+	SessionID string    `json:"session_id"`
+	Sequence  int       `json:"sequence"`
+	Timestamp time.Time `json:"timestamp"`
+	Data      []byte    `json:"data"` // gzip-compressed JSON array of DOM events
+}
+
+// ValidSessionID reports whether a session ID can be used to store replays
+func ValidSessionID(sessionID string) bool {
+	return sessionIDPattern.MatchString(sessionID)
+}
+
+// ChunkFromEvent extracts and validates the replay chunk carried by a session_replay event
+func ChunkFromEvent(event *models.AnalyticsEvent) (*Chunk, error) {
+	if event.Type != models.SessionReplay {
+		return nil, fmt.Errorf("event type %q is not %q", event.Type, models.SessionReplay)
+	}
+	if !ValidSessionID(event.SessionID) {
+		return nil, fmt.Errorf("invalid session ID %q", event.SessionID)
+	}
+
+	sequence, ok := event.Metadata[MetadataSequence].(float64)
+	if !ok || sequence < 0 || sequence != float64(int(sequence)) {
+		return nil, fmt.Errorf("metadata.%s must be a non-negative integer", MetadataSequence)
+	}
+
+	encoded, ok := event.Metadata[MetadataData].(string)
+	if !ok || encoded == "" {
+		return nil, fmt.Errorf("metadata.%s is required", MetadataData)
+	}
+	if base64.StdEncoding.DecodedLen(len(encoded)) > MaxCompressedSize {
+		return nil, fmt.Errorf("replay chunk exceeds %d bytes", MaxCompressedSize)
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("metadata.%s is not valid base64: %w", MetadataData, err)
+	}
+
+	chunk := &Chunk{
+		SessionID: event.SessionID,
+		Sequence:  int(sequence),
+		Timestamp: event.Timestamp,
+		Data:      data,
+	}
+
+	// Reject payloads that are not gzip-compressed JSON so readers can trust stored chunks
+	if _, err := chunk.Events(); err != nil {
+		return nil, err
+	}
+	return chunk, nil
+}
+
+// Events decompresses the chunk's DOM events
+func (c *Chunk) Events() (json.RawMessage, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(c.Data))
+	if err != nil {
+		return nil, fmt.Errorf("replay data is not gzip-compressed: %w", err)
+	}
+	defer reader.Close()
+
+	raw, err := io.ReadAll(io.LimitReader(reader, MaxDecompressedSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress replay data: %w", err)
+	}
+	if len(raw) > MaxDecompressedSize {
+		return nil, fmt.Errorf("decompressed replay chunk exceeds %d bytes", MaxDecompressedSize)
+	}
+	if !json.Valid(raw) {
+		return nil, fmt.Errorf("replay data is not valid JSON")
+	}
+	return raw, nil
+}
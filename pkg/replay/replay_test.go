@@ -0,0 +1,129 @@
+package replay
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+func replayEvent(t *testing.T, sessionID string, sequence int, payload string) *models.AnalyticsEvent {
+	t.Helper()
+
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	writer.Write([]byte(payload))
+	writer.Close()
+
+	return &models.AnalyticsEvent{
+		Type:      models.SessionReplay,
+		SessionID: sessionID,
+		Timestamp: time.Now(),
+		Metadata: map[string]interface{}{
+			MetadataSequence: float64(sequence),
+			MetadataData:     base64.StdEncoding.EncodeToString(buf.Bytes()),
+		},
+	}
+}
+
+func TestChunkFromEventValidation(t *testing.T) {
+	if _, err := ChunkFromEvent(replayEvent(t, "session-1", 0, `[{"type":"click"}]`)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	invalid := map[string]*models.AnalyticsEvent{
+		"path traversal session": replayEvent(t, "../etc", 0, `[]`),
+		"negative sequence":      replayEvent(t, "session-1", -1, `[]`),
+		"non-JSON payload":       replayEvent(t, "session-1", 0, `not json`),
+	}
+	notGzip := replayEvent(t, "session-1", 0, `[]`)
+	notGzip.Metadata[MetadataData] = base64.StdEncoding.EncodeToString([]byte(`[]`))
+	invalid["uncompressed payload"] = notGzip
+
+	for name, event := range invalid {
+		if _, err := ChunkFromEvent(event); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestFileStoreOrdersChunksBySequence(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	ctx := context.Background()
+
+	for _, sequence := range []int{2, 0, 10, 1} {
+		chunk, err := ChunkFromEvent(replayEvent(t, "session-1", sequence, `[]`))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := store.SaveChunk(ctx, chunk); err != nil {
+			t.Fatalf("failed to save chunk: %v", err)
+		}
+	}
+
+	chunks, err := store.SessionChunks(ctx, "session-1")
+	if err != nil {
+		t.Fatalf("failed to read chunks: %v", err)
+	}
+	var sequences []int
+	for _, chunk := range chunks {
+		sequences = append(sequences, chunk.Sequence)
+	}
+	if len(sequences) != 4 || sequences[0] != 0 || sequences[1] != 1 || sequences[2] != 2 || sequences[3] != 10 {
+		t.Errorf("expected sequences [0 1 2 10], got %v", sequences)
+	}
+
+	if chunks, err := store.SessionChunks(ctx, "unknown"); err != nil || len(chunks) != 0 {
+		t.Errorf("expected no chunks for an unknown session, got %d (%v)", len(chunks), err)
+	}
+
+	if err := store.DeleteSession(ctx, "session-1"); err != nil {
+		t.Fatalf("failed to delete session: %v", err)
+	}
+	if chunks, err := store.SessionChunks(ctx, "session-1"); err != nil || len(chunks) != 0 {
+		t.Errorf("expected no chunks after deletion, got %d (%v)", len(chunks), err)
+	}
+}
+
+func TestFileStoreExpiresIdleSessions(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	ctx := context.Background()
+
+	for _, sessionID := range []string{"idle", "active"} {
+		chunk, err := ChunkFromEvent(replayEvent(t, sessionID, 0, `[]`))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := store.SaveChunk(ctx, chunk); err != nil {
+			t.Fatalf("failed to save chunk: %v", err)
+		}
+	}
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, "idle"), old, old); err != nil {
+		t.Fatalf("failed to age session: %v", err)
+	}
+
+	removed, err := store.Expire(ctx, time.Now().Add(-time.Hour))
+	if err != nil || removed != 1 {
+		t.Fatalf("expected 1 session expired, got %d (%v)", removed, err)
+	}
+	if chunks, _ := store.SessionChunks(ctx, "idle"); len(chunks) != 0 {
+		t.Errorf("expected the idle session removed, got %d chunks", len(chunks))
+	}
+	if chunks, _ := store.SessionChunks(ctx, "active"); len(chunks) != 1 {
+		t.Errorf("expected the active session kept, got %d chunks", len(chunks))
+	}
+}
@@ -0,0 +1,172 @@
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Store persists replay chunks grouped by session
+type Store interface {
+	// SaveChunk stores a chunk, replacing any chunk with the same session and sequence
+	SaveChunk(ctx context.Context, chunk *Chunk) error
+
+	// SessionChunks returns a session's chunks ordered by sequence
+	SessionChunks(ctx context.Context, sessionID string) ([]*Chunk, error)
+
+	// DeleteSession removes every chunk of a session
+	DeleteSession(ctx context.Context, sessionID string) error
+
+	// Expire removes sessions without chunks saved since before, returning how many
+	Expire(ctx context.Context, before time.Time) (int, error)
+
+	Close() error
+}
+
+// FileStore stores each chunk as dir/<session_id>/<sequence>.json
+type FileStore struct {
+	dir string
+	mu  sync.RWMutex
+}
+
+// NewFileStore creates a file-backed replay store rooted at dir, creating it if needed
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create replay directory: %w", err)
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// SaveChunk writes the chunk atomically so readers never see partial data
+func (f *FileStore) SaveChunk(ctx context.Context, chunk *Chunk) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if !ValidSessionID(chunk.SessionID) {
+		return fmt.Errorf("invalid session ID %q", chunk.SessionID)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	dir := filepath.Join(f.dir, chunk.SessionID)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create session directory: %w", err)
+	}
+
+	data, err := json.Marshal(chunk)
+	if err != nil {
+		return fmt.Errorf("failed to marshal replay chunk: %w", err)
+	}
+
+	// Zero-padded sequence numbers keep lexical and sequence order the same
+	path := filepath.Join(dir, fmt.Sprintf("%08d.json", chunk.Sequence))
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write replay chunk: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to commit replay chunk: %w", err)
+	}
+	return nil
+}
+
+// SessionChunks reads all chunks stored for the session
+func (f *FileStore) SessionChunks(ctx context.Context, sessionID string) ([]*Chunk, error) {
+	if !ValidSessionID(sessionID) {
+		return nil, fmt.Errorf("invalid session ID %q", sessionID)
+	}
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	dir := filepath.Join(f.dir, sessionID)
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list replay chunks: %w", err)
+	}
+
+	var chunks []*Chunk
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read replay chunk: %w", err)
+		}
+		var chunk Chunk
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return nil, fmt.Errorf("failed to decode replay chunk %s: %w", entry.Name(), err)
+		}
+		chunks = append(chunks, &chunk)
+	}
+
+	sort.Slice(chunks, func(i, j int) bool {
+		return chunks[i].Sequence < chunks[j].Sequence
+	})
+	return chunks, nil
+}
+
+// DeleteSession removes the session's directory
+func (f *FileStore) DeleteSession(ctx context.Context, sessionID string) error {
+	if !ValidSessionID(sessionID) {
+		return fmt.Errorf("invalid session ID %q", sessionID)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := os.RemoveAll(filepath.Join(f.dir, sessionID)); err != nil {
+		return fmt.Errorf("failed to delete replay session: %w", err)
+	}
+	return nil
+}
+
+// Expire removes session directories last modified before the cutoff. Saving a chunk
+// renames it into the directory, which updates the directory's modification time.
+func (f *FileStore) Expire(ctx context.Context, before time.Time) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	entries, err := os.ReadDir(f.dir)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list replay sessions: %w", err)
+	}
+	removed := 0
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return removed, err
+		}
+		if !entry.IsDir() || !ValidSessionID(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || !info.ModTime().Before(before) {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(f.dir, entry.Name())); err != nil {
+			return removed, fmt.Errorf("failed to expire replay session: %w", err)
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// Close releases resources held by the store
+func (f *FileStore) Close() error {
+	return nil
+}
@@ -0,0 +1,231 @@
+package sampling
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/ratelimit"
+)
+
+// DefaultRuleKey is the rule event type that applies to types without their own rule
+const DefaultRuleKey = "*"
+
+// Rule controls how many events of a type are accepted
+type Rule struct {
+	EventType    models.EventType `json:"event_type"`
+	Rate         float64          `json:"rate"`           // Share of sessions whose events are kept, 0 to 1
+	MaxPerMinute int              `json:"max_per_minute"` // Cap on accepted events after sampling, 0 for none
+}
+
+// Decision is the outcome of applying the rules to an event
+type Decision int
+
+const (
+	Keep       Decision = iota
+	SampledOut          // Dropped by sampling; the kept events are upweighted to account for it
+	Throttled           // Rejected by the per-type rate cap
+)
+
+// TypeStats counts decisions for one event type
+type TypeStats struct {
+	Kept       int64 `json:"kept"`
+	SampledOut int64 `json:"sampled_out"`
+	Throttled  int64 `json:"throttled"`
+}
+
+// Sampler applies sampling and throttling rules at ingestion
+type Sampler struct {
+	rules atomic.Pointer[ruleSet] // Replaced as a whole by SetRules
+
+	stats map[models.EventType]*TypeStats
+	mu    sync.Mutex
+}
+
+// ruleSet is the rules in effect with the rate caps they set
+type ruleSet struct {
+	rules    map[models.EventType]Rule
+	fallback Rule
+	limiter  *ratelimit.Limiter
+}
+
+// NewSampler creates a sampler; event types without a rule, and without a "*" rule, are all kept
+func NewSampler(rules []Rule) *Sampler {
+	s := &Sampler{stats: make(map[models.EventType]*TypeStats)}
+	s.SetRules(rules)
+	return s
+}
+
+// SetRules replaces the rules while the sampler is in use. Rate caps start afresh, and
+// decision counts are kept.
+func (s *Sampler) SetRules(rules []Rule) {
+	set := &ruleSet{
+		rules:    make(map[models.EventType]Rule),
+		fallback: Rule{Rate: 1},
+		limiter:  ratelimit.NewLimiter(0, 1),
+	}
+	for _, rule := range rules {
+		if rule.EventType == DefaultRuleKey {
+			set.fallback = rule
+		} else {
+			set.rules[rule.EventType] = rule
+		}
+		if rule.MaxPerMinute > 0 {
+			set.limiter.SetKeyLimit(string(rule.EventType), rule.MaxPerMinute, rule.MaxPerMinute)
+		}
+	}
+	s.rules.Store(set)
+}
+
+// rule returns the rule for an event type
+func (set *ruleSet) rule(eventType models.EventType) Rule {
+	if rule, ok := set.rules[eventType]; ok {
+		return rule
+	}
+	return set.fallback
+}
+
+// Apply decides whether to accept an event. Sampling is deterministic by session, so a
+// session is either kept or dropped as a whole, and sessions kept at a low rate are also
+// kept for every type with a higher rate. Kept events are stamped with their sample rate;
+// any rate supplied by the client is discarded so it cannot inflate upweighted counts.
+func (s *Sampler) Apply(event *models.AnalyticsEvent) Decision {
+	set := s.rules.Load()
+	rule := set.rule(event.Type)
+	delete(event.Metadata, models.MetadataSampleRate)
+
+	decision := Keep
+	switch {
+	case rule.Rate < 1 && position(event) >= rule.Rate:
+		decision = SampledOut
+	case rule.MaxPerMinute > 0 && !set.limiter.Allow(string(rule.EventType)):
+		decision = Throttled
+	}
+
+	if decision == Keep && rule.Rate < 1 {
+		if event.Metadata == nil {
+			event.Metadata = make(map[string]interface{})
+		}
+		event.Metadata[models.MetadataSampleRate] = rule.Rate
+	}
+
+	s.record(event.Type, decision)
+	return decision
+}
+
+// position maps the event's session to a stable point in [0, 1)
+func position(event *models.AnalyticsEvent) float64 {
+	key := event.SessionID
+	if key == "" {
+		key = event.UserID
+	}
+	if key == "" {
+		key = event.ID
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(key))
+
+	// FNV's high bits barely change between similar keys such as "session-1" and
+	// "session-2", so mix them with the murmur3 finalizer before scaling
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return float64(x>>11) / (1 << 53)
+}
+
+// record counts a decision
+func (s *Sampler) record(eventType models.EventType, decision Decision) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats, ok := s.stats[eventType]
+	if !ok {
+		stats = &TypeStats{}
+		s.stats[eventType] = stats
+	}
+	switch decision {
+	case Keep:
+		stats.Kept++
+	case SampledOut:
+		stats.SampledOut++
+	case Throttled:
+		stats.Throttled++
+	}
+}
+
+// Rules returns the configured rules, the "*" rule last
+func (s *Sampler) Rules() []Rule {
+	set := s.rules.Load()
+	rules := make([]Rule, 0, len(set.rules)+1)
+	for _, rule := range set.rules {
+		rules = append(rules, rule)
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		return rules[i].EventType < rules[j].EventType
+	})
+	if set.fallback.EventType == DefaultRuleKey {
+		rules = append(rules, set.fallback)
+	}
+	return rules
+}
+
+// Stats returns decision counts by event type
+func (s *Sampler) Stats() map[models.EventType]TypeStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := make(map[models.EventType]TypeStats, len(s.stats))
+	for eventType, typeStats := range s.stats {
+		stats[eventType] = *typeStats
+	}
+	return stats
+}
+
+// ParseRules parses rules of the form "event_type=rate[:max_per_minute]" separated by
+// semicolons, where "*" sets the rule for all other types.
+// For example: "click=0.1;purchase=1;page_view=0.5:6000"
+func ParseRules(spec string) ([]Rule, error) {
+	var rules []Rule
+
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		eventType, definition, ok := strings.Cut(entry, "=")
+		eventType = strings.TrimSpace(eventType)
+		if !ok || eventType == "" {
+			return nil, fmt.Errorf("invalid sampling rule %q: expected event_type=rate", entry)
+		}
+		if eventType != DefaultRuleKey && !models.EventType(eventType).Valid() {
+			return nil, fmt.Errorf("invalid sampling rule %q: invalid event type", entry)
+		}
+
+		rateText, maxText, hasMax := strings.Cut(strings.TrimSpace(definition), ":")
+		rate, err := strconv.ParseFloat(rateText, 64)
+		if err != nil || rate < 0 || rate > 1 {
+			return nil, fmt.Errorf("invalid sampling rule %q: rate must be between 0 and 1", entry)
+		}
+
+		rule := Rule{EventType: models.EventType(eventType), Rate: rate}
+		if hasMax {
+			rule.MaxPerMinute, err = strconv.Atoi(maxText)
+			if err != nil || rule.MaxPerMinute <= 0 {
+				return nil, fmt.Errorf("invalid sampling rule %q: max_per_minute must be a positive integer", entry)
+			}
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
@@ -0,0 +1,104 @@
+package sampling
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+func TestSamplerKeepsWholeSessions(t *testing.T) {
+	sampler := NewSampler([]Rule{
+		{EventType: models.Click, Rate: 0.1},
+		{EventType: models.PageView, Rate: 0.5},
+	})
+
+	kept := 0
+	for i := 0; i < 2000; i++ {
+		sessionID := fmt.Sprintf("session-%d", i)
+		click := &models.AnalyticsEvent{Type: models.Click, SessionID: sessionID}
+		decision := sampler.Apply(click)
+
+		// The same session gets the same decision every time
+		again := &models.AnalyticsEvent{Type: models.Click, SessionID: sessionID}
+		if sampler.Apply(again) != decision {
+			t.Fatalf("Session %s sampled inconsistently", sessionID)
+		}
+
+		if decision == Keep {
+			kept++
+			if click.Metadata[models.MetadataSampleRate] != 0.1 || models.SampleWeight(click) != 10 {
+				t.Fatalf("Kept click should carry its sample rate, got %v", click.Metadata)
+			}
+
+			// Sessions kept at 10% are also kept at the higher page view rate
+			view := &models.AnalyticsEvent{Type: models.PageView, SessionID: sessionID}
+			if sampler.Apply(view) != Keep {
+				t.Fatalf("Session %s kept for clicks but dropped for page views", sessionID)
+			}
+		}
+	}
+
+	if share := float64(kept) / 2000; math.Abs(share-0.1) > 0.03 {
+		t.Errorf("Kept %.3f of sessions, want about 0.1", share)
+	}
+
+	// Types without a rule are kept unstamped, and client-supplied rates are discarded
+	event := &models.AnalyticsEvent{Type: models.Session, Metadata: map[string]interface{}{models.MetadataSampleRate: 0.001}}
+	if sampler.Apply(event) != Keep || models.SampleWeight(event) != 1 {
+		t.Errorf("Unsampled event should weigh 1, got metadata %v", event.Metadata)
+	}
+}
+
+func TestSamplerThrottles(t *testing.T) {
+	sampler := NewSampler([]Rule{{EventType: models.Click, Rate: 1, MaxPerMinute: 2}})
+
+	var decisions []Decision
+	for i := 0; i < 3; i++ {
+		decisions = append(decisions, sampler.Apply(&models.AnalyticsEvent{Type: models.Click, SessionID: "s"}))
+	}
+	if decisions[0] != Keep || decisions[1] != Keep || decisions[2] != Throttled {
+		t.Errorf("Expected two kept and one throttled, got %v", decisions)
+	}
+
+	stats := sampler.Stats()[models.Click]
+	if stats.Kept != 2 || stats.Throttled != 1 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestParseRules(t *testing.T) {
+	rules, err := ParseRules("click=0.1; purchase=1 ;page_view=0.5:6000;*=1")
+	if err != nil {
+		t.Fatalf("ParseRules: %v", err)
+	}
+	if len(rules) != 4 || rules[2].Rate != 0.5 || rules[2].MaxPerMinute != 6000 || rules[3].EventType != DefaultRuleKey {
+		t.Errorf("Unexpected rules: %+v", rules)
+	}
+
+	for _, spec := range []string{"click", "click=2", "click=0.5:0", "Bad Type=1"} {
+		if _, err := ParseRules(spec); err == nil {
+			t.Errorf("ParseRules(%q) should fail", spec)
+		}
+	}
+}
+
+func TestSetRulesReplacesRules(t *testing.T) {
+	sampler := NewSampler([]Rule{{EventType: models.Click, Rate: 1, MaxPerMinute: 1}})
+	sampler.Apply(&models.AnalyticsEvent{Type: models.Click, SessionID: "s"})
+	if decision := sampler.Apply(&models.AnalyticsEvent{Type: models.Click, SessionID: "s"}); decision != Throttled {
+		t.Fatalf("Expected the cap to throttle, got %v", decision)
+	}
+
+	sampler.SetRules([]Rule{{EventType: DefaultRuleKey, Rate: 1}})
+	if decision := sampler.Apply(&models.AnalyticsEvent{Type: models.Click, SessionID: "s"}); decision != Keep {
+		t.Errorf("Expected the cap removed, got %v", decision)
+	}
+	if rules := sampler.Rules(); len(rules) != 1 || rules[0].EventType != DefaultRuleKey {
+		t.Errorf("Unexpected rules %+v", rules)
+	}
+	if stats := sampler.Stats()[models.Click]; stats.Kept != 2 || stats.Throttled != 1 {
+		t.Errorf("Expected decision counts kept across rule changes, got %+v", stats)
+	}
+}
@@ -0,0 +1,127 @@
+// Package scaling reports the load of the pipeline's services in the forms autoscalers
+// read: a JSON document for KEDA's metrics-api scaler, and Prometheus gauges for KEDA's
+// prometheus scaler or an HPA external metrics adapter.
+package scaling
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// DefaultWindow is the period event rates are averaged over
+const DefaultWindow = time.Minute
+
+// RateMeter measures events per second over a sliding window of one-second buckets. It
+// is safe for concurrent use.
+type RateMeter struct {
+	counts  []int64 // Events per second, indexed by Unix second modulo the window
+	seconds []int64 // Unix second each bucket counts
+	now     func() time.Time
+	mu      sync.Mutex
+}
+
+// NewRateMeter creates a meter averaging over window, rounded to whole seconds
+func NewRateMeter(window time.Duration) *RateMeter {
+	size := int(window / time.Second)
+	if size < 1 {
+		size = 1
+	}
+	return &RateMeter{
+		counts:  make([]int64, size),
+		seconds: make([]int64, size),
+		now:     time.Now,
+	}
+}
+
+// Add counts n events in the current second
+func (m *RateMeter) Add(n int64) {
+	second := m.now().Unix()
+	i := int(second % int64(len(m.counts)))
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.seconds[i] != second {
+		m.seconds[i], m.counts[i] = second, 0
+	}
+	m.counts[i] += n
+}
+
+// Rate returns the average events per second over the window
+func (m *RateMeter) Rate() float64 {
+	oldest := m.now().Unix() - int64(len(m.counts))
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var total int64
+	for i, second := range m.seconds {
+		if second > oldest {
+			total += m.counts[i]
+		}
+	}
+	return float64(total) / float64(len(m.counts))
+}
+
+// Window returns the period the rate is averaged over
+func (m *RateMeter) Window() time.Duration {
+	return time.Duration(len(m.counts)) * time.Second
+}
+
+// Metrics is a service's load as reported to autoscalers. Values a service doesn't
+// measure are nil and left out.
+type Metrics struct {
+	Service         string  `json:"service"`
+	EventsPerSecond float64 `json:"events_per_second"`
+	WindowSeconds   int     `json:"window_seconds"`
+	ConsumerLag     *int64  `json:"consumer_lag,omitempty"` // Messages the consumer group has not yet committed
+	QueueDepth      *int    `json:"queue_depth,omitempty"`  // Fetched messages waiting for or being handled by a worker
+
+	// Stateless reports whether replicas can be added and removed without losing state,
+	// and LocalState names the state kept in process memory when they can't
+	Stateless  *bool    `json:"stateless,omitempty"`
+	LocalState []string `json:"local_state,omitempty"`
+}
+
+// NewMetrics returns the metrics of service with the rate measured by meter
+func NewMetrics(service string, meter *RateMeter) Metrics {
+	return Metrics{
+		Service:         service,
+		EventsPerSecond: meter.Rate(),
+		WindowSeconds:   int(meter.Window() / time.Second),
+	}
+}
+
+// WithState records the state a service keeps in process memory
+func (m Metrics) WithState(localState []string) Metrics {
+	stateless := len(localState) == 0
+	m.Stateless, m.LocalState = &stateless, localState
+	return m
+}
+
+// WritePrometheus writes the metrics as gauges named prefix_<metric>
+func WritePrometheus(w io.Writer, prefix string, m Metrics) {
+	fmt.Fprintf(w, "# HELP %s_events_per_second Events per second averaged over the last %d seconds.\n", prefix, m.WindowSeconds)
+	fmt.Fprintf(w, "# TYPE %s_events_per_second gauge\n", prefix)
+	fmt.Fprintf(w, "%s_events_per_second %g\n", prefix, m.EventsPerSecond)
+
+	if m.ConsumerLag != nil {
+		fmt.Fprintf(w, "# HELP %s_consumer_lag Messages the consumer group has not yet committed.\n", prefix)
+		fmt.Fprintf(w, "# TYPE %s_consumer_lag gauge\n", prefix)
+		fmt.Fprintf(w, "%s_consumer_lag %d\n", prefix, *m.ConsumerLag)
+	}
+	if m.QueueDepth != nil {
+		fmt.Fprintf(w, "# HELP %s_queue_depth Fetched messages waiting for or being handled by a worker.\n", prefix)
+		fmt.Fprintf(w, "# TYPE %s_queue_depth gauge\n", prefix)
+		fmt.Fprintf(w, "%s_queue_depth %d\n", prefix, *m.QueueDepth)
+	}
+	if m.Stateless != nil {
+		stateless := 0
+		if *m.Stateless {
+			stateless = 1
+		}
+		fmt.Fprintf(w, "# HELP %s_stateless Whether replicas can be added and removed without losing state.\n", prefix)
+		fmt.Fprintf(w, "# TYPE %s_stateless gauge\n", prefix)
+		fmt.Fprintf(w, "%s_stateless %d\n", prefix, stateless)
+	}
+}
@@ -0,0 +1,46 @@
+package scaling
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRateMeter(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	meter := NewRateMeter(10 * time.Second)
+	meter.now = func() time.Time { return now }
+
+	meter.Add(30)
+	now = now.Add(5 * time.Second)
+	meter.Add(20)
+	if rate := meter.Rate(); rate != 5 {
+		t.Errorf("expected 50 events over 10 seconds to be 5/s, got %g", rate)
+	}
+
+	// The first second leaves the window, then its bucket is reused
+	now = now.Add(5 * time.Second)
+	if rate := meter.Rate(); rate != 2 {
+		t.Errorf("expected the expired second to be left out, got %g", rate)
+	}
+	meter.Add(10)
+	if rate := meter.Rate(); rate != 3 {
+		t.Errorf("expected the reused bucket to restart, got %g", rate)
+	}
+}
+
+func TestWritePrometheus(t *testing.T) {
+	lag := int64(42)
+	metrics := Metrics{Service: "consumer", EventsPerSecond: 1.5, WindowSeconds: 60, ConsumerLag: &lag}.WithState([]string{"dedupe"})
+
+	var out strings.Builder
+	WritePrometheus(&out, "test_scaling", metrics)
+	for _, line := range []string{"test_scaling_events_per_second 1.5", "test_scaling_consumer_lag 42", "test_scaling_stateless 0"} {
+		if !strings.Contains(out.String(), line+"\n") {
+			t.Errorf("expected %q in\n%s", line, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "queue_depth") {
+		t.Error("expected an unmeasured queue depth to be left out")
+	}
+}
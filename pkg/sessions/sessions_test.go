@@ -0,0 +1,89 @@
+package sessions
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+func TestFileStore(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileStore failed: %v", err)
+	}
+
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i, event := range []models.AnalyticsEvent{
+		{ID: "e1", Type: models.PageView, SessionID: "s1", UserID: "u1", Timestamp: now},
+		{ID: "e2", Type: models.Click, SessionID: "s1", UserID: "u1", Timestamp: now.Add(time.Second)},
+		{ID: "e3", Type: models.PageView, SessionID: "s2", UserID: "u2", Timestamp: now},
+	} {
+		if err := store.Append(ctx, &event); err != nil {
+			t.Fatalf("Append %d failed: %v", i, err)
+		}
+	}
+	if err := store.Append(ctx, &models.AnalyticsEvent{ID: "e4", SessionID: "../s1"}); err == nil {
+		t.Error("expected an invalid session ID to be rejected")
+	}
+
+	events, err := store.Events(ctx, "s1")
+	if err != nil || len(events) != 2 || events[0].ID != "e1" || events[1].ID != "e2" {
+		t.Fatalf("expected s1's events in order, got %+v, %v", events, err)
+	}
+	if events, err := store.Events(ctx, "unknown"); err != nil || events != nil {
+		t.Errorf("expected no events for an unknown session, got %v, %v", events, err)
+	}
+
+	deleted, err := store.DeleteUser(ctx, "u2")
+	if err != nil || !reflect.DeepEqual(deleted, []string{"s2"}) {
+		t.Errorf("expected s2 deleted with its user, got %v, %v", deleted, err)
+	}
+
+	// Sessions idle since before the cutoff expire
+	old := time.Now().Add(-2 * time.Hour)
+	os.Chtimes(filepath.Join(dir, "s1.jsonl"), old, old)
+	if removed, err := store.Expire(ctx, time.Now().Add(-time.Hour)); err != nil || removed != 1 {
+		t.Errorf("expected s1 to expire, got %d, %v", removed, err)
+	}
+	if events, _ := store.Events(ctx, "s1"); len(events) != 0 {
+		t.Errorf("expected s1 gone, got %d events", len(events))
+	}
+}
+
+func TestBuildTimeline(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	events := []models.AnalyticsEvent{
+		{ID: "e4", Type: models.Click, Path: "/pricing", Timestamp: start.Add(40 * time.Second)},
+		{ID: "e1", Type: models.PageView, Path: "/", Timestamp: start, Metadata: map[string]interface{}{"page_title": "Home"}},
+		{ID: "e2", Type: models.Click, Path: "/", Timestamp: start.Add(5 * time.Second)},
+		{ID: "e3", Type: models.PageView, Path: "/pricing", UserID: "u1", Timestamp: start.Add(30 * time.Second)},
+		{ID: "e5", Type: models.Error, Path: "/pricing", Timestamp: start.Add(90 * time.Second)},
+	}
+	timeline := BuildTimeline("s1", events)
+
+	if timeline.UserID != "u1" || timeline.DurationMs != 90000 || timeline.Events != 5 || timeline.PageViews != 2 ||
+		timeline.Clicks != 2 || timeline.Errors != 1 {
+		t.Errorf("unexpected summary %+v", timeline)
+	}
+	var ids []string
+	for _, entry := range timeline.Entries {
+		ids = append(ids, entry.ID)
+	}
+	if !reflect.DeepEqual(ids, []string{"e1", "e2", "e3", "e4", "e5"}) || timeline.Entries[3].OffsetMs != 40000 {
+		t.Errorf("expected entries in time order, got %v", ids)
+	}
+	want := []PageVisit{
+		{Path: "/", Title: "Home", Start: start, DurationMs: 30000, Clicks: 1},
+		{Path: "/pricing", Start: start.Add(30 * time.Second), DurationMs: 60000, Clicks: 1},
+	}
+	if !reflect.DeepEqual(timeline.Pages, want) {
+		t.Errorf("expected pages %+v, got %+v", want, timeline.Pages)
+	}
+}
@@ -0,0 +1,223 @@
+// Package sessions keeps the events of each session in order, so a single visit can be
+// inspected event by event, e.g. by support teams following up on a user's report.
+package sessions
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/replay"
+)
+
+// MaxSessionBytes bounds the events stored for one session; later events are dropped
+const MaxSessionBytes = 1 << 20
+
+// ErrSessionFull is returned by Append once a session holds MaxSessionBytes
+var ErrSessionFull = errors.New("session store limit reached")
+
+// Store persists the events of sessions
+type Store interface {
+	// Append adds an event to its session
+	Append(ctx context.Context, event *models.AnalyticsEvent) error
+
+	// Events returns a session's events in the order they were appended
+	Events(ctx context.Context, sessionID string) ([]models.AnalyticsEvent, error)
+
+	// DeleteSession removes a session
+	DeleteSession(ctx context.Context, sessionID string) error
+
+	// DeleteUser removes every session with an event of the user, returning their IDs
+	DeleteUser(ctx context.Context, userID string) ([]string, error)
+
+	// Expire removes sessions without events appended since before, returning how many
+	Expire(ctx context.Context, before time.Time) (int, error)
+
+	Close() error
+}
+
+// ValidID reports whether a session ID can be stored; IDs are restricted like those of
+// session replays, as both are file names
+func ValidID(sessionID string) bool {
+	return replay.ValidSessionID(sessionID)
+}
+
+// FileStore stores each session as dir/<session_id>.jsonl, one event per line. A
+// session's last activity is its file's modification time.
+type FileStore struct {
+	dir string
+	mu  sync.RWMutex
+}
+
+// NewFileStore creates a file-backed session store rooted at dir, creating it if needed
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create session directory: %w", err)
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// path returns the file of a session
+func (f *FileStore) path(sessionID string) string {
+	return filepath.Join(f.dir, sessionID+".jsonl")
+}
+
+// Append writes the event as a line of its session's file
+func (f *FileStore) Append(ctx context.Context, event *models.AnalyticsEvent) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if !ValidID(event.SessionID) {
+		return fmt.Errorf("invalid session ID %q", event.SessionID)
+	}
+	line, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	file, err := os.OpenFile(f.path(event.SessionID), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open session: %w", err)
+	}
+	defer file.Close()
+	if info, err := file.Stat(); err == nil && info.Size()+int64(len(line)) >= MaxSessionBytes {
+		return ErrSessionFull
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to append event: %w", err)
+	}
+	return nil
+}
+
+// Events reads a session's file. A line cut short by a crash is skipped.
+func (f *FileStore) Events(ctx context.Context, sessionID string) ([]models.AnalyticsEvent, error) {
+	if !ValidID(sessionID) {
+		return nil, fmt.Errorf("invalid session ID %q", sessionID)
+	}
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return readEvents(ctx, f.path(sessionID))
+}
+
+// readEvents decodes a session file, returning nil if it doesn't exist
+func readEvents(ctx context.Context, path string) ([]models.AnalyticsEvent, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open session: %w", err)
+	}
+	defer file.Close()
+
+	var events []models.AnalyticsEvent
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64<<10), MaxSessionBytes)
+	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		var event models.AnalyticsEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			continue
+		}
+		events = append(events, event)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read session: %w", err)
+	}
+	return events, nil
+}
+
+// DeleteSession removes a session's file
+func (f *FileStore) DeleteSession(ctx context.Context, sessionID string) error {
+	if !ValidID(sessionID) {
+		return fmt.Errorf("invalid session ID %q", sessionID)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := os.Remove(f.path(sessionID)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+	return nil
+}
+
+// DeleteUser reads every session to find the user's, since sessions aren't indexed by
+// user; erasures are rare enough for that
+func (f *FileStore) DeleteUser(ctx context.Context, userID string) ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	entries, err := os.ReadDir(f.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	var deleted []string
+	for _, entry := range entries {
+		sessionID, ok := strings.CutSuffix(entry.Name(), ".jsonl")
+		if !ok || entry.IsDir() {
+			continue
+		}
+		events, err := readEvents(ctx, filepath.Join(f.dir, entry.Name()))
+		if err != nil {
+			return deleted, err
+		}
+		for _, event := range events {
+			if event.UserID == userID {
+				if err := os.Remove(filepath.Join(f.dir, entry.Name())); err != nil && !os.IsNotExist(err) {
+					return deleted, fmt.Errorf("failed to delete session: %w", err)
+				}
+				deleted = append(deleted, sessionID)
+				break
+			}
+		}
+	}
+	return deleted, nil
+}
+
+// Expire removes session files last modified before the cutoff
+func (f *FileStore) Expire(ctx context.Context, before time.Time) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	entries, err := os.ReadDir(f.dir)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	removed := 0
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return removed, err
+		}
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".jsonl") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || !info.ModTime().Before(before) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(f.dir, entry.Name())); err != nil && !os.IsNotExist(err) {
+			return removed, fmt.Errorf("failed to expire session: %w", err)
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// Close releases resources held by the store
+func (f *FileStore) Close() error {
+	return nil
+}
@@ -0,0 +1,102 @@
+package sessions
+
+import (
+	"sort"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+// Timeline is a session's events in time order with the pages visited
+type Timeline struct {
+	SessionID  string      `json:"session_id"`
+	UserID     string      `json:"user_id,omitempty"` // Latest user ID, as identify events may set one mid-session
+	Start      time.Time   `json:"start"`
+	End        time.Time   `json:"end"`
+	DurationMs int64       `json:"duration_ms"`
+	Events     int         `json:"events"`
+	PageViews  int         `json:"page_views"`
+	Clicks     int         `json:"clicks"`
+	Errors     int         `json:"errors"`
+	Pages      []PageVisit `json:"pages"`
+	Entries    []Entry     `json:"timeline"`
+}
+
+// PageVisit is one page view of a session and the time spent on the page
+type PageVisit struct {
+	Path       string    `json:"path"`
+	URL        string    `json:"url,omitempty"`
+	Title      string    `json:"title,omitempty"`
+	Start      time.Time `json:"start"`
+	DurationMs int64     `json:"duration_ms"` // Until the next page view, or the session's last event
+	Clicks     int       `json:"clicks"`
+}
+
+// Entry is one event of a timeline
+type Entry struct {
+	ID        string                 `json:"id"`
+	Type      models.EventType       `json:"type"`
+	Timestamp time.Time              `json:"timestamp"`
+	OffsetMs  int64                  `json:"offset_ms"` // Since the session's first event
+	Path      string                 `json:"path,omitempty"`
+	URL       string                 `json:"url,omitempty"`
+	Metadata  map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// BuildTimeline orders a session's events by timestamp, keeping events of the same
+// timestamp in the order they were stored, and derives the pages visited
+func BuildTimeline(sessionID string, events []models.AnalyticsEvent) Timeline {
+	ordered := append([]models.AnalyticsEvent(nil), events...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].Timestamp.Before(ordered[j].Timestamp)
+	})
+
+	timeline := Timeline{
+		SessionID: sessionID,
+		Events:    len(ordered),
+		Pages:     []PageVisit{},
+		Entries:   make([]Entry, 0, len(ordered)),
+	}
+	if len(ordered) == 0 {
+		return timeline
+	}
+	timeline.Start = ordered[0].Timestamp
+	timeline.End = ordered[len(ordered)-1].Timestamp
+	timeline.DurationMs = timeline.End.Sub(timeline.Start).Milliseconds()
+
+	for _, event := range ordered {
+		if event.UserID != "" {
+			timeline.UserID = event.UserID
+		}
+		timeline.Entries = append(timeline.Entries, Entry{
+			ID:        event.ID,
+			Type:      event.Type,
+			Timestamp: event.Timestamp,
+			OffsetMs:  event.Timestamp.Sub(timeline.Start).Milliseconds(),
+			Path:      event.Path,
+			URL:       event.URL,
+			Metadata:  event.Metadata,
+		})
+
+		switch event.Type {
+		case models.PageView:
+			timeline.PageViews++
+			if n := len(timeline.Pages); n > 0 {
+				timeline.Pages[n-1].DurationMs = event.Timestamp.Sub(timeline.Pages[n-1].Start).Milliseconds()
+			}
+			title, _ := event.Metadata["page_title"].(string)
+			timeline.Pages = append(timeline.Pages, PageVisit{Path: event.Path, URL: event.URL, Title: title, Start: event.Timestamp})
+		case models.Click:
+			timeline.Clicks++
+			if n := len(timeline.Pages); n > 0 {
+				timeline.Pages[n-1].Clicks++
+			}
+		case models.Error:
+			timeline.Errors++
+		}
+	}
+	if n := len(timeline.Pages); n > 0 {
+		timeline.Pages[n-1].DurationMs = timeline.End.Sub(timeline.Pages[n-1].Start).Milliseconds()
+	}
+	return timeline
+}
@@ -0,0 +1,225 @@
+// Package shadow mirrors a share of live traffic to a shadow topic, so a new consumer
+// version can be run against real events without touching the primary analytics, and
+// compares the outputs of the primary and shadow consumers.
+package shadow
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"net/http"
+	"sort"
+	"sync/atomic"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+// DefaultQueueSize is the number of selected events waiting to be written to the shadow
+// topic when no size is configured
+const DefaultQueueSize = 1000
+
+// Mirror selects the events copied to a shadow topic. Selection is by user, falling back
+// to session and event ID, so the shadow consumer sees every event of a selected visitor
+// and its per-user and per-session metrics stay comparable. Selected events are written
+// in the background by Run from a bounded queue, and dropped when the queue is full, so
+// a slow shadow topic never delays ingestion.
+type Mirror struct {
+	topic   string
+	percent int
+	queue   chan *models.AnalyticsEvent
+
+	seen     atomic.Int64 // Events offered to the mirror
+	mirrored atomic.Int64 // Events written to the shadow topic
+	failed   atomic.Int64 // Selected events the shadow topic could not take
+	dropped  atomic.Int64 // Selected events dropped because the queue was full
+}
+
+// Stats reports what a mirror has sent
+type Stats struct {
+	Topic    string `json:"topic"`
+	Percent  int    `json:"percent"`
+	Seen     int64  `json:"seen"`
+	Mirrored int64  `json:"mirrored"`
+	Failed   int64  `json:"failed"`
+	Dropped  int64  `json:"dropped"`
+}
+
+// NewMirror creates a mirror copying percent (1-100) of visitors to topic, queueing up to
+// queueSize events (DefaultQueueSize if not positive). It returns nil, which mirrors
+// nothing, when topic is empty or percent is not positive.
+func NewMirror(topic string, percent, queueSize int) *Mirror {
+	if topic == "" || percent <= 0 {
+		return nil
+	}
+	if queueSize <= 0 {
+		queueSize = DefaultQueueSize
+	}
+	return &Mirror{topic: topic, percent: min(percent, 100), queue: make(chan *models.AnalyticsEvent, queueSize)}
+}
+
+// Topic returns the shadow topic
+func (m *Mirror) Topic() string {
+	return m.topic
+}
+
+// Selects reports whether the event should be mirrored, counting it as seen
+func (m *Mirror) Selects(event *models.AnalyticsEvent) bool {
+	if m == nil {
+		return false
+	}
+	m.seen.Add(1)
+
+	key := event.UserID
+	if key == "" {
+		key = event.SessionID
+	}
+	if key == "" {
+		key = event.ID
+	}
+	hash := fnv.New32a()
+	hash.Write([]byte(key))
+	return int(hash.Sum32()%100) < m.percent
+}
+
+// Enqueue queues a selected event for Run to write, dropping it and reporting false when
+// the queue is full
+func (m *Mirror) Enqueue(event *models.AnalyticsEvent) bool {
+	select {
+	case m.queue <- event:
+		return true
+	default:
+		m.dropped.Add(1)
+		return false
+	}
+}
+
+// Run writes queued events with send until the context is cancelled, counting the
+// outcome of each write
+func (m *Mirror) Run(ctx context.Context, send func(context.Context, *models.AnalyticsEvent) error) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-m.queue:
+			m.record(send(ctx, event))
+		}
+	}
+}
+
+// record counts the outcome of writing a selected event to the shadow topic
+func (m *Mirror) record(err error) {
+	if err != nil {
+		m.failed.Add(1)
+	} else {
+		m.mirrored.Add(1)
+	}
+}
+
+// Stats returns the mirror's counters
+func (m *Mirror) Stats() Stats {
+	return Stats{
+		Topic:    m.topic,
+		Percent:  m.percent,
+		Seen:     m.seen.Load(),
+		Mirrored: m.mirrored.Load(),
+		Failed:   m.failed.Load(),
+		Dropped:  m.dropped.Load(),
+	}
+}
+
+// Fraction returns the share of seen events that were mirrored, or the configured
+// percentage before any event was seen
+func (m *Mirror) Fraction() float64 {
+	stats := m.Stats()
+	if stats.Seen == 0 {
+		return float64(stats.Percent) / 100
+	}
+	return float64(stats.Mirrored) / float64(stats.Seen)
+}
+
+// MetricComparison compares one counter of the primary and shadow consumers
+type MetricComparison struct {
+	Metric   string  `json:"metric"`
+	Primary  int64   `json:"primary"`
+	Expected float64 `json:"expected"` // Primary value scaled to the mirrored share of traffic
+	Shadow   int64   `json:"shadow"`
+	DiffPct  float64 `json:"diff_pct"` // Shadow relative to expected, in percent
+}
+
+// Comparison holds the differences between the primary and shadow consumers' outputs
+type Comparison struct {
+	Fraction   float64            `json:"fraction"` // Share of primary traffic the shadow consumer receives
+	Metrics    []MetricComparison `json:"metrics"`
+	MaxDiffPct float64            `json:"max_diff_pct"` // Largest absolute difference of any metric
+}
+
+// Compare scales the primary snapshot's counters by fraction, the share of traffic that
+// was mirrored, and compares them with the shadow snapshot's. Counters of distinct users
+// and sessions only scale exactly when the whole traffic is mirrored.
+func Compare(primary, shadow *models.MetricsSnapshot, fraction float64) Comparison {
+	comparison := Comparison{Fraction: fraction}
+	add := func(metric string, primaryValue, shadowValue int64) {
+		expected := float64(primaryValue) * fraction
+		diff := 0.0
+		switch {
+		case expected > 0:
+			diff = (float64(shadowValue) - expected) / expected * 100
+		case shadowValue > 0:
+			diff = 100
+		}
+		comparison.Metrics = append(comparison.Metrics, MetricComparison{
+			Metric:   metric,
+			Primary:  primaryValue,
+			Expected: expected,
+			Shadow:   shadowValue,
+			DiffPct:  diff,
+		})
+		comparison.MaxDiffPct = math.Max(comparison.MaxDiffPct, math.Abs(diff))
+	}
+
+	add("total_events", primary.TotalEvents, shadow.TotalEvents)
+	add("unique_users", primary.UniqueUsers, shadow.UniqueUsers)
+	add("active_sessions", primary.ActiveSessions, shadow.ActiveSessions)
+	add("bot_events", primary.BotEvents, shadow.BotEvents)
+
+	types := make(map[models.EventType]bool)
+	for eventType := range primary.EventsByType {
+		types[eventType] = true
+	}
+	for eventType := range shadow.EventsByType {
+		types[eventType] = true
+	}
+	sorted := make([]string, 0, len(types))
+	for eventType := range types {
+		sorted = append(sorted, string(eventType))
+	}
+	sort.Strings(sorted)
+	for _, eventType := range sorted {
+		add("events_by_type."+eventType, primary.EventsByType[models.EventType(eventType)], shadow.EventsByType[models.EventType(eventType)])
+	}
+	return comparison
+}
+
+// FetchSnapshot reads a consumer's analytics snapshot from its /stats URL
+func FetchSnapshot(ctx context.Context, client *http.Client, url string) (*models.MetricsSnapshot, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %s", url, response.Status)
+	}
+	var snapshot models.MetricsSnapshot
+	if err := json.NewDecoder(response.Body).Decode(&snapshot); err != nil {
+		return nil, fmt.Errorf("decode %s: %w", url, err)
+	}
+	return &snapshot, nil
+}
@@ -0,0 +1,106 @@
+package shadow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+func TestMirrorSelectsWholeVisitors(t *testing.T) {
+	if NewMirror("", 50, 0) != nil || NewMirror("shadow", 0, 0) != nil {
+		t.Fatal("expected no mirror without a topic or percentage")
+	}
+
+	mirror := NewMirror("shadow", 25, 0)
+	selected := 0
+	for i := 0; i < 1000; i++ {
+		user := fmt.Sprintf("user-%d", i)
+		first := mirror.Selects(&models.AnalyticsEvent{ID: "a", UserID: user})
+		if second := mirror.Selects(&models.AnalyticsEvent{ID: "b", UserID: user}); second != first {
+			t.Fatalf("expected every event of %s mirrored alike", user)
+		}
+		if first {
+			selected++
+			mirror.record(nil)
+		}
+	}
+	if selected < 200 || selected > 300 {
+		t.Errorf("expected about 25%% of users selected, got %d of 1000", selected)
+	}
+
+	mirror.record(errors.New("unavailable"))
+	stats := mirror.Stats()
+	if stats.Seen != 2000 || stats.Mirrored != int64(selected) || stats.Failed != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	if all := NewMirror("shadow", 150, 0); !all.Selects(&models.AnalyticsEvent{ID: "x"}) {
+		t.Error("expected percentages above 100 to mirror everything")
+	}
+}
+
+func TestMirrorDropsWhenQueueIsFull(t *testing.T) {
+	mirror := NewMirror("shadow", 100, 1)
+	if !mirror.Enqueue(&models.AnalyticsEvent{ID: "a"}) || mirror.Enqueue(&models.AnalyticsEvent{ID: "b"}) {
+		t.Fatal("expected the second event dropped while the first is queued")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sent := make(chan string, 1)
+	go mirror.Run(ctx, func(_ context.Context, event *models.AnalyticsEvent) error {
+		sent <- event.ID
+		cancel()
+		return nil
+	})
+	if id := <-sent; id != "a" {
+		t.Errorf("expected the queued event written, got %s", id)
+	}
+	<-ctx.Done()
+	if stats := mirror.Stats(); stats.Dropped != 1 {
+		t.Errorf("expected one dropped event, got %+v", stats)
+	}
+}
+
+func TestCompareScalesPrimaryCounters(t *testing.T) {
+	primary := &models.MetricsSnapshot{TotalEvents: 1000, UniqueUsers: 200, EventsByType: map[models.EventType]int64{models.PageView: 800, models.Click: 200}}
+	mirrored := &models.MetricsSnapshot{TotalEvents: 110, UniqueUsers: 20, EventsByType: map[models.EventType]int64{models.PageView: 80, models.Click: 30}}
+
+	comparison := Compare(primary, mirrored, 0.1)
+	metrics := make(map[string]MetricComparison)
+	for _, metric := range comparison.Metrics {
+		metrics[metric.Metric] = metric
+	}
+	if got := metrics["total_events"]; got.Expected != 100 || got.DiffPct != 10 {
+		t.Errorf("expected 100 total events with a 10%% difference, got %+v", got)
+	}
+	if got := metrics["events_by_type.page_view"]; got.DiffPct != 0 {
+		t.Errorf("expected page views to match, got %+v", got)
+	}
+	if got := metrics["events_by_type.click"]; got.DiffPct != 50 || comparison.MaxDiffPct != 50 {
+		t.Errorf("expected clicks 50%% over, got %+v (max %.1f)", got, comparison.MaxDiffPct)
+	}
+}
+
+func TestFetchSnapshot(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/stats" {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(models.MetricsSnapshot{TotalEvents: 42})
+	}))
+	defer server.Close()
+
+	snapshot, err := FetchSnapshot(context.Background(), server.Client(), server.URL+"/stats")
+	if err != nil || snapshot.TotalEvents != 42 {
+		t.Fatalf("expected the snapshot, got %+v, %v", snapshot, err)
+	}
+	if _, err := FetchSnapshot(context.Background(), server.Client(), server.URL+"/missing"); err == nil {
+		t.Error("expected an error for a non-200 response")
+	}
+}
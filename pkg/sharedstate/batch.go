@@ -0,0 +1,90 @@
+package sharedstate
+
+import "github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/analytics"
+
+// batch merges the updates recorded between two flushes, so a flush sends each counter
+// once however many events touched it
+type batch struct {
+	events       int64
+	byType       map[string]int64
+	users        map[string]bool
+	sessions     map[string]int64 // Latest activity as a Unix time
+	pages        map[string]int64
+	visitors     map[string]map[string]bool // Users by page path
+	sources      map[string]int64
+	sourcesTotal int64
+	devices      map[string]int64
+	browsers     map[string]int64
+	os           map[string]int64
+	entries      int // Distinct entries held, which bounds the batch's memory
+}
+
+func newBatch() *batch {
+	return &batch{
+		byType:   make(map[string]int64),
+		users:    make(map[string]bool),
+		sessions: make(map[string]int64),
+		pages:    make(map[string]int64),
+		visitors: make(map[string]map[string]bool),
+		sources:  make(map[string]int64),
+		devices:  make(map[string]int64),
+		browsers: make(map[string]int64),
+		os:       make(map[string]int64),
+	}
+}
+
+// add merges an update into the batch
+func (b *batch) add(update analytics.SharedUpdate) {
+	b.events += update.Weight
+	b.count(b.byType, string(update.Type), update.Weight)
+	if update.UserID != "" && !b.users[update.UserID] {
+		b.users[update.UserID] = true
+		b.entries++
+	}
+	if update.SessionID != "" {
+		last, ok := b.sessions[update.SessionID]
+		if !ok {
+			b.entries++
+		}
+		// Keep the latest activity even if events arrive out of order
+		if seen := update.Timestamp.Unix(); seen > last {
+			b.sessions[update.SessionID] = seen
+		}
+	}
+	if update.Path != "" {
+		b.count(b.pages, update.Path, update.Weight)
+		if update.UserID != "" {
+			users := b.visitors[update.Path]
+			if users == nil {
+				users = make(map[string]bool)
+				b.visitors[update.Path] = users
+			}
+			if !users[update.UserID] {
+				users[update.UserID] = true
+				b.entries++
+			}
+		}
+	}
+	if update.Source != "" {
+		b.count(b.sources, update.Source, update.Weight)
+		b.sourcesTotal += update.Weight
+	}
+	if update.Device != "" || update.Browser != "" || update.OS != "" {
+		b.count(b.devices, update.Device, update.Weight)
+		b.count(b.browsers, update.Browser, update.Weight)
+		b.count(b.os, update.OS, update.Weight)
+	}
+}
+
+// count adds weight to a counter of the batch
+func (b *batch) count(counters map[string]int64, name string, weight int64) {
+	if _, ok := counters[name]; !ok {
+		b.entries++
+	}
+	counters[name] += weight
+}
+
+// empty reports whether nothing was recorded since the batch was created
+func (b *batch) empty() bool {
+	return b.entries == 0
+}
@@ -0,0 +1,42 @@
+package sharedstate
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/analytics"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/errs"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+func TestRecordBatchesWeightedUpdates(t *testing.T) {
+	state := &RedisState{options: Options{BatchSize: 4}, pending: newBatch(), flushes: make(chan struct{}, 1)}
+	now := time.Now()
+
+	update := analytics.SharedUpdate{Timestamp: now, Weight: 5, Type: models.PageView, UserID: "u1", SessionID: "s1", Path: "/pricing", Source: "google.com"}
+	for i := 0; i < 2; i++ {
+		if err := state.Record(context.Background(), update); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	b := state.pending
+	if b.events != 10 || b.pages["/pricing"] != 10 || b.sources["google.com"] != 10 || len(b.users) != 1 || b.sessions["s1"] != now.Unix() {
+		t.Errorf("expected two weighted updates merged, got %+v", b)
+	}
+	select {
+	case <-state.flushes:
+	default:
+		t.Error("expected a full batch to request a flush")
+	}
+
+	// Past twice the batch size updates are dropped rather than buffered
+	for i := 0; state.pending.entries < 8; i++ {
+		update.UserID = string(rune('a' + i))
+		state.Record(context.Background(), update)
+	}
+	if err := state.Record(context.Background(), update); !errors.Is(err, errs.ErrThrottled) {
+		t.Errorf("expected a full batch to throttle, got %v", err)
+	}
+}
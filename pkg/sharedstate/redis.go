@@ -0,0 +1,345 @@
+// Package sharedstate stores analytics counters shared by several replicas
+package sharedstate
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/analytics"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/errs"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/logging"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+// RedisState keeps the shared counters in Redis: plain counters and hashes for
+// totals and breakdowns, HyperLogLogs for distinct users and page visitors, sorted
+// sets ranking pages and sources, and a sorted set of sessions by last activity.
+// Recorded events are merged into a batch in memory and written by Run, so counting
+// an event never waits on Redis.
+type RedisState struct {
+	client  *redis.Client
+	prefix  string
+	options Options
+
+	mu      sync.Mutex
+	pending *batch
+	dropped int64         // Updates dropped since the batch was full
+	flushes chan struct{} // Signals Run that the batch is large enough to flush
+	flushMu sync.Mutex    // Serializes flushes
+}
+
+// Options bound the keyspace and the pending batch
+type Options struct {
+	MaxKeys   int           // Pages and sources kept ranked, 0 for all
+	TTL       time.Duration // Keys not written for this long expire, 0 for never
+	BatchSize int           // Distinct entries that trigger a flush; twice as many are held at most
+}
+
+// NewRedisState connects to the Redis server at redisURL (e.g. redis://localhost:6379/0)
+func NewRedisState(ctx context.Context, redisURL string, options Options) (*RedisState, error) {
+	redisOptions, err := redis.ParseURL(redisURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis URL: %w", err)
+	}
+
+	if options.BatchSize <= 0 {
+		options.BatchSize = 1000
+	}
+
+	client := redis.NewClient(redisOptions)
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to redis: %w", err)
+	}
+
+	return &RedisState{
+		client:  client,
+		prefix:  "analytics:shared:",
+		options: options,
+		pending: newBatch(),
+		flushes: make(chan struct{}, 1),
+	}, nil
+}
+
+// key returns the full Redis key for name
+func (r *RedisState) key(name string) string {
+	return r.prefix + name
+}
+
+// Record adds an event to the pending batch. It fails with errs.ErrThrottled when the
+// batch is full because Redis can't keep up; the event is then not shared.
+func (r *RedisState) Record(ctx context.Context, update analytics.SharedUpdate) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.pending.entries >= 2*r.options.BatchSize {
+		r.dropped++
+		return errs.Errorf(errs.ErrThrottled, "shared state batch is full, %d updates dropped", r.dropped)
+	}
+	r.pending.add(update)
+	if r.pending.entries >= r.options.BatchSize {
+		select {
+		case r.flushes <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Run writes the pending batch to Redis every interval, or sooner once it holds
+// BatchSize entries, until ctx is cancelled. Close writes what is left.
+func (r *RedisState) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-r.flushes:
+		case <-ctx.Done():
+			return
+		}
+		flushCtx, cancel := context.WithTimeout(ctx, interval+5*time.Second)
+		if err := r.Flush(flushCtx); err != nil {
+			logging.Warn("Failed to write shared analytics counters", "error", err)
+		}
+		cancel()
+	}
+}
+
+// Flush writes the pending batch to Redis in a single transaction. A batch that fails
+// to be written is dropped, since retrying could count it twice.
+func (r *RedisState) Flush(ctx context.Context) error {
+	r.flushMu.Lock()
+	defer r.flushMu.Unlock()
+
+	r.mu.Lock()
+	b := r.pending
+	r.pending = newBatch()
+	r.mu.Unlock()
+	if b.empty() {
+		return nil
+	}
+
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.IncrBy(ctx, r.key("events"), b.events)
+		r.expire(ctx, pipe, "events")
+		r.incrHash(ctx, pipe, "events_by_type", b.byType)
+		if len(b.users) > 0 {
+			pipe.PFAdd(ctx, r.key("users"), members(b.users)...)
+			r.expire(ctx, pipe, "users")
+		}
+		if len(b.sessions) > 0 {
+			sessions := make([]redis.Z, 0, len(b.sessions))
+			for sessionID, seen := range b.sessions {
+				sessions = append(sessions, redis.Z{Score: float64(seen), Member: sessionID})
+			}
+			pipe.ZAddGT(ctx, r.key("sessions"), sessions...)
+			r.expire(ctx, pipe, "sessions")
+		}
+		if len(b.pages) > 0 {
+			r.incrRanking(ctx, pipe, "pages", b.pages)
+			for path, users := range b.visitors {
+				pipe.PFAdd(ctx, r.key("page_visitors:"+path), members(users)...)
+				r.expire(ctx, pipe, "page_visitors:"+path)
+			}
+		}
+		if len(b.sources) > 0 {
+			r.incrRanking(ctx, pipe, "sources", b.sources)
+			pipe.IncrBy(ctx, r.key("sources_total"), b.sourcesTotal)
+			r.expire(ctx, pipe, "sources_total")
+		}
+		r.incrHash(ctx, pipe, "devices", b.devices)
+		r.incrHash(ctx, pipe, "browsers", b.browsers)
+		r.incrHash(ctx, pipe, "os", b.os)
+		return nil
+	})
+	return errs.StoreError(err)
+}
+
+// incrHash adds counts to the fields of a hash
+func (r *RedisState) incrHash(ctx context.Context, pipe redis.Pipeliner, name string, counts map[string]int64) {
+	if len(counts) == 0 {
+		return
+	}
+	for field, count := range counts {
+		pipe.HIncrBy(ctx, r.key(name), field, count)
+	}
+	r.expire(ctx, pipe, name)
+}
+
+// incrRanking adds counts to a sorted set, then trims it to the MaxKeys highest. The
+// visitor counts of pages trimmed away expire with their TTL.
+func (r *RedisState) incrRanking(ctx context.Context, pipe redis.Pipeliner, name string, counts map[string]int64) {
+	for member, count := range counts {
+		pipe.ZIncrBy(ctx, r.key(name), float64(count), member)
+	}
+	if r.options.MaxKeys > 0 {
+		pipe.ZRemRangeByRank(ctx, r.key(name), 0, -int64(r.options.MaxKeys)-1)
+	}
+	r.expire(ctx, pipe, name)
+}
+
+// expire sets the TTL of a key written by a flush
+func (r *RedisState) expire(ctx context.Context, pipe redis.Pipeliner, name string) {
+	if r.options.TTL > 0 {
+		pipe.Expire(ctx, r.key(name), r.options.TTL)
+	}
+}
+
+// Reset deletes every shared counter, along with the events pending in the batch
+func (r *RedisState) Reset(ctx context.Context) error {
+	r.mu.Lock()
+	r.pending = newBatch()
+	r.mu.Unlock()
+
+	var keys []string
+	iter := r.client.Scan(ctx, 0, r.prefix+"*", 500).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		return errs.StoreError(err)
+	}
+	for start := 0; start < len(keys); start += 500 {
+		end := min(start+500, len(keys))
+		if err := r.client.Del(ctx, keys[start:end]...).Err(); err != nil {
+			return errs.StoreError(err)
+		}
+	}
+	return nil
+}
+
+// EraseSessions removes sessions from the active sessions. Distinct user and visitor
+// counts are HyperLogLogs, which cannot forget a user.
+func (r *RedisState) EraseSessions(ctx context.Context, sessionIDs []string) error {
+	if len(sessionIDs) == 0 {
+		return nil
+	}
+	r.mu.Lock()
+	for _, sessionID := range sessionIDs {
+		delete(r.pending.sessions, sessionID)
+	}
+	r.mu.Unlock()
+
+	sessions := make([]interface{}, len(sessionIDs))
+	for i, sessionID := range sessionIDs {
+		sessions[i] = sessionID
+	}
+	return errs.StoreError(r.client.ZRem(ctx, r.key("sessions"), sessions...).Err())
+}
+
+// Read returns the counters with the top pages and sources, first removing sessions
+// idle for longer than sessionTimeout
+func (r *RedisState) Read(ctx context.Context, sessionTimeout time.Duration, top int) (*analytics.SharedCounters, error) {
+	cutoff := strconv.FormatInt(time.Now().Add(-sessionTimeout).Unix(), 10)
+
+	var (
+		events       *redis.StringCmd
+		eventsByType *redis.MapStringStringCmd
+		users        *redis.IntCmd
+		sessions     *redis.IntCmd
+		pages        *redis.ZSliceCmd
+		sources      *redis.ZSliceCmd
+		sourcesTotal *redis.StringCmd
+		devices      *redis.MapStringStringCmd
+		browsers     *redis.MapStringStringCmd
+		osTypes      *redis.MapStringStringCmd
+	)
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRemRangeByScore(ctx, r.key("sessions"), "-inf", "("+cutoff)
+		events = pipe.Get(ctx, r.key("events"))
+		eventsByType = pipe.HGetAll(ctx, r.key("events_by_type"))
+		users = pipe.PFCount(ctx, r.key("users"))
+		sessions = pipe.ZCard(ctx, r.key("sessions"))
+		pages = pipe.ZRevRangeWithScores(ctx, r.key("pages"), 0, int64(top)-1)
+		sources = pipe.ZRevRangeWithScores(ctx, r.key("sources"), 0, int64(top)-1)
+		sourcesTotal = pipe.Get(ctx, r.key("sources_total"))
+		devices = pipe.HGetAll(ctx, r.key("devices"))
+		browsers = pipe.HGetAll(ctx, r.key("browsers"))
+		osTypes = pipe.HGetAll(ctx, r.key("os"))
+		return nil
+	})
+	// Counters that were never written read as redis.Nil
+	if err != nil && err != redis.Nil {
+		return nil, errs.StoreError(err)
+	}
+
+	counters := &analytics.SharedCounters{
+		TotalEvents:    parseCount(events.Val()),
+		UniqueUsers:    users.Val(),
+		ActiveSessions: sessions.Val(),
+		EventsByType:   make(map[models.EventType]int64),
+		Sources:        make(map[string]int64),
+		SourceTotal:    parseCount(sourcesTotal.Val()),
+		Devices:        parseCounts(devices.Val()),
+		Browsers:       parseCounts(browsers.Val()),
+		OS:             parseCounts(osTypes.Val()),
+	}
+	for eventType, count := range parseCounts(eventsByType.Val()) {
+		counters.EventsByType[models.EventType(eventType)] = count
+	}
+	for _, source := range sources.Val() {
+		counters.Sources[source.Member.(string)] = int64(source.Score)
+	}
+
+	// Distinct visitors are only counted for the top pages
+	visitors := make([]*redis.IntCmd, len(pages.Val()))
+	if len(visitors) > 0 {
+		_, err = r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for i, page := range pages.Val() {
+				visitors[i] = pipe.PFCount(ctx, r.key("page_visitors:"+page.Member.(string)))
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, errs.StoreError(err)
+		}
+	}
+	for i, page := range pages.Val() {
+		counters.Pages = append(counters.Pages, analytics.SharedPage{
+			Path:           page.Member.(string),
+			Views:          int64(page.Score),
+			UniqueVisitors: visitors[i].Val(),
+		})
+	}
+	return counters, nil
+}
+
+// Close writes the pending batch and closes the Redis client
+func (r *RedisState) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := r.Flush(ctx); err != nil {
+		logging.Warn("Failed to write shared analytics counters", "error", err)
+	}
+	return r.client.Close()
+}
+
+// members returns the keys of a set as arguments to a Redis command
+func members(set map[string]bool) []interface{} {
+	result := make([]interface{}, 0, len(set))
+	for member := range set {
+		result = append(result, member)
+	}
+	return result
+}
+
+// parseCount parses a counter value, treating a missing counter as zero
+func parseCount(value string) int64 {
+	count, _ := strconv.ParseInt(value, 10, 64)
+	return count
+}
+
+// parseCounts parses a hash of counters
+func parseCounts(values map[string]string) map[string]int64 {
+	counts := make(map[string]int64, len(values))
+	for name, value := range values {
+		counts[name] = parseCount(value)
+	}
+	return counts
+}
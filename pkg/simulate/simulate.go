@@ -0,0 +1,228 @@
+// Package simulate generates deterministic synthetic website traffic, for running the
+// dashboard and demos without trackers or Kafka
+package simulate
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+// Config shapes the generated traffic
+type Config struct {
+	Seed            int64
+	Site            string  // Host of generated URLs
+	Users           int     // Size of the visitor pool
+	Rate            float64 // Average events per second over a day
+	DailyAmplitude  float64 // 0–1: how far the daily curve swings above and below Rate
+	PeakHour        int     // UTC hour of day with the most traffic
+	SpikeEvery      time.Duration
+	SpikeDuration   time.Duration
+	SpikeMultiplier float64 // Traffic multiplier during a spike
+	SessionLength   time.Duration
+}
+
+// DefaultConfig returns a small site with a daily curve peaking at 15:00 UTC and a
+// five minute spike every hour
+func DefaultConfig() Config {
+	return Config{
+		Seed:            1,
+		Site:            "demo.example.com",
+		Users:           500,
+		Rate:            5,
+		DailyAmplitude:  0.6,
+		PeakHour:        15,
+		SpikeEvery:      time.Hour,
+		SpikeDuration:   5 * time.Minute,
+		SpikeMultiplier: 4,
+		SessionLength:   30 * time.Minute,
+	}
+}
+
+// withDefaults replaces unset or invalid values with the defaults
+func (c Config) withDefaults() Config {
+	defaults := DefaultConfig()
+	if c.Site == "" {
+		c.Site = defaults.Site
+	}
+	if c.Users <= 0 {
+		c.Users = defaults.Users
+	}
+	if c.Rate <= 0 {
+		c.Rate = defaults.Rate
+	}
+	c.DailyAmplitude = math.Max(0, math.Min(1, c.DailyAmplitude))
+	if c.SpikeMultiplier < 1 {
+		c.SpikeMultiplier = 1
+	}
+	if c.SessionLength < time.Second {
+		c.SessionLength = defaults.SessionLength
+	}
+	return c
+}
+
+// Realistic values for the fields the analytics service breaks down by
+var (
+	userAgents = []string{
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+		"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.1 Safari/605.1.15",
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:121.0) Gecko/20100101 Firefox/121.0",
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 17_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.1 Mobile/15E148 Safari/604.1",
+		"Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Mobile Safari/537.36",
+		"Mozilla/5.0 (iPad; CPU OS 17_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.1 Mobile/15E148 Safari/604.1",
+	}
+	referrers = []string{
+		"", "", "", "https://www.google.com/", "https://www.google.com/", "https://www.bing.com/",
+		"https://twitter.com/", "https://news.ycombinator.com/", "https://github.com/", "https://mail.google.com/",
+	}
+	paths = []string{
+		"/", "/pricing", "/features", "/blog", "/docs", "/docs/getting-started", "/blog/release-notes",
+		"/signup", "/about", "/contact", "/docs/api", "/blog/case-study", "/careers", "/changelog",
+	}
+	elements = []string{"signup-button", "nav-pricing", "buy-now", "read-more", "footer-contact"}
+)
+
+// visitor is a simulated user with a stable browser and address
+type visitor struct {
+	id        string
+	userAgent string
+	ip        string
+	phase     int64 // Offsets session boundaries so visitors don't all start sessions together
+}
+
+// Simulator generates traffic as a pure function of the seed and time: the events of
+// any second are the same on every run, so ranges can be regenerated or backfilled
+// reproducibly
+type Simulator struct {
+	config   Config
+	visitors []visitor
+}
+
+// New creates a simulator; unset config values use DefaultConfig
+func New(config Config) *Simulator {
+	config = config.withDefaults()
+	rng := rand.New(rand.NewSource(config.Seed))
+
+	s := &Simulator{config: config}
+	for i := 0; i < config.Users; i++ {
+		s.visitors = append(s.visitors, visitor{
+			id:        fmt.Sprintf("sim-user-%d", i),
+			userAgent: userAgents[rng.Intn(len(userAgents))],
+			ip:        fmt.Sprintf("203.0.113.%d", rng.Intn(254)+1),
+			phase:     rng.Int63n(int64(config.SessionLength / time.Second)),
+		})
+	}
+	return s
+}
+
+// Rate returns the expected events per second at t: the daily curve, multiplied
+// during spikes
+func (s *Simulator) Rate(t time.Time) float64 {
+	t = t.UTC()
+	hour := float64(t.Hour()) + float64(t.Minute())/60
+	rate := s.config.Rate * (1 + s.config.DailyAmplitude*math.Cos(2*math.Pi*(hour-float64(s.config.PeakHour))/24))
+	if s.inSpike(t) {
+		rate *= s.config.SpikeMultiplier
+	}
+	return rate
+}
+
+// inSpike reports whether t falls in a spike; spikes start at multiples of SpikeEvery
+func (s *Simulator) inSpike(t time.Time) bool {
+	if s.config.SpikeEvery <= 0 || s.config.SpikeDuration <= 0 {
+		return false
+	}
+	return time.Duration(t.UnixNano())%s.config.SpikeEvery < s.config.SpikeDuration
+}
+
+// Events returns the events with timestamps in [from, to), oldest first
+func (s *Simulator) Events(from, to time.Time) []models.AnalyticsEvent {
+	var events []models.AnalyticsEvent
+	for sec := from.Unix(); sec <= to.Unix(); sec++ {
+		for _, event := range s.second(sec) {
+			if !event.Timestamp.Before(from) && event.Timestamp.Before(to) {
+				events = append(events, event)
+			}
+		}
+	}
+	return events
+}
+
+// second generates the events of one second from a generator seeded by the seed and
+// the second
+func (s *Simulator) second(sec int64) []models.AnalyticsEvent {
+	rng := rand.New(rand.NewSource(s.config.Seed*1_000_003 + sec))
+	n := poisson(rng, s.Rate(time.Unix(sec, 0)))
+
+	events := make([]models.AnalyticsEvent, 0, n)
+	for i := 0; i < n; i++ {
+		events = append(events, s.event(rng, sec, i))
+	}
+	// Offsets within the second are random; keep the events in time order
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Timestamp.Before(events[j].Timestamp)
+	})
+	return events
+}
+
+// event generates the i-th event of a second
+func (s *Simulator) event(rng *rand.Rand, sec int64, i int) models.AnalyticsEvent {
+	index := rng.Intn(len(s.visitors))
+	v := s.visitors[index]
+	session := (sec + v.phase) / int64(s.config.SessionLength/time.Second)
+
+	// Popular pages get most views: squaring skews the pick towards the start of the list
+	path := paths[int(math.Pow(rng.Float64(), 2)*float64(len(paths)))]
+
+	event := models.AnalyticsEvent{
+		ID:        fmt.Sprintf("sim-%d-%d-%d", s.config.Seed, sec, i),
+		Timestamp: time.Unix(sec, rng.Int63n(int64(time.Second))).UTC(),
+		UserID:    v.id,
+		SessionID: fmt.Sprintf("sim-session-%d-%d", index, session),
+		URL:       "https://" + s.config.Site + path,
+		Path:      path,
+		Referrer:  referrers[rng.Intn(len(referrers))],
+		UserAgent: v.userAgent,
+		IPAddress: v.ip,
+		Metadata:  map[string]interface{}{"source": "simulator"},
+	}
+
+	switch n := rng.Intn(100); {
+	case n < 70:
+		event.Type = models.PageView
+		// Pages load slower during spikes
+		loadTime := 300 + rng.ExpFloat64()*700
+		if s.inSpike(event.Timestamp) {
+			loadTime *= 2
+		}
+		event.Metadata["load_time"] = math.Round(loadTime)
+	case n < 95:
+		event.Type = models.Click
+		event.Metadata["element_id"] = elements[rng.Intn(len(elements))]
+	default:
+		event.Type = models.Session
+		event.Metadata["duration"] = float64(10 + rng.Intn(600))
+	}
+	return event
+}
+
+// poisson draws a Poisson-distributed count with mean lambda, using a normal
+// approximation for large means
+func poisson(rng *rand.Rand, lambda float64) int {
+	if lambda <= 0 {
+		return 0
+	}
+	if lambda > 30 {
+		return max(0, int(math.Round(lambda+rng.NormFloat64()*math.Sqrt(lambda))))
+	}
+	limit, product, n := math.Exp(-lambda), rng.Float64(), 0
+	for product > limit {
+		product *= rng.Float64()
+		n++
+	}
+	return n
+}
@@ -0,0 +1,76 @@
+package simulate
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEventsAreDeterministic(t *testing.T) {
+	from := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	to := from.Add(2 * time.Minute)
+
+	first := New(DefaultConfig()).Events(from, to)
+	if len(first) == 0 {
+		t.Fatal("expected generated events")
+	}
+	if second := New(DefaultConfig()).Events(from, to); !reflect.DeepEqual(first, second) {
+		t.Error("expected the same events for the same seed and range")
+	}
+
+	// Generating in pieces gives the same events as one range
+	middle := from.Add(45*time.Second + 500*time.Millisecond)
+	sim := New(DefaultConfig())
+	pieces := append(sim.Events(from, middle), sim.Events(middle, to)...)
+	if !reflect.DeepEqual(first, pieces) {
+		t.Error("expected split ranges to generate the same events")
+	}
+
+	for i, event := range first {
+		if event.Timestamp.Before(from) || !event.Timestamp.Before(to) {
+			t.Fatalf("event %s at %v outside [%v, %v)", event.ID, event.Timestamp, from, to)
+		}
+		if i > 0 && event.Timestamp.Before(first[i-1].Timestamp) {
+			t.Fatal("expected events in time order")
+		}
+	}
+
+	config := DefaultConfig()
+	config.Seed = 2
+	if other := New(config).Events(from, to); reflect.DeepEqual(first, other) {
+		t.Error("expected a different seed to generate different events")
+	}
+}
+
+func TestRateFollowsDailyCurveAndSpikes(t *testing.T) {
+	sim := New(DefaultConfig())
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	peak := sim.Rate(day.Add(15*time.Hour + 30*time.Minute))
+	trough := sim.Rate(day.Add(3*time.Hour + 30*time.Minute))
+	if peak <= trough {
+		t.Errorf("expected more traffic at the peak hour, got %.2f at peak and %.2f at trough", peak, trough)
+	}
+
+	spike := sim.Rate(day.Add(15*time.Hour + 2*time.Minute))
+	if spike < 3*peak {
+		t.Errorf("expected a spike at the start of the hour, got %.2f against %.2f", spike, peak)
+	}
+
+	// A spike shows in the generated volume too
+	quiet := len(sim.Events(day.Add(15*time.Hour+30*time.Minute), day.Add(15*time.Hour+33*time.Minute)))
+	busy := len(sim.Events(day.Add(15*time.Hour), day.Add(15*time.Hour+3*time.Minute)))
+	if busy <= 2*quiet {
+		t.Errorf("expected spike traffic well above normal, got %d against %d events", busy, quiet)
+	}
+}
+
+func TestConfigDefaults(t *testing.T) {
+	sim := New(Config{Seed: 7, SessionLength: time.Millisecond})
+	if len(sim.visitors) != DefaultConfig().Users || sim.config.Rate != DefaultConfig().Rate {
+		t.Errorf("expected unset values to use the defaults, got %+v", sim.config)
+	}
+	if sim.config.DailyAmplitude != 0 || sim.inSpike(time.Now()) {
+		t.Error("expected no daily curve or spikes unless configured")
+	}
+}
@@ -0,0 +1,204 @@
+package sinks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/logging"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+	"github.com/google/uuid"
+	"github.com/parquet-go/parquet-go"
+)
+
+// ObjectStore stores the files written by an ArchiveSink
+type ObjectStore interface {
+	// Name identifies the store in logs, e.g. "s3"
+	Name() string
+
+	// Put writes an object under key, replacing any existing one
+	Put(ctx context.Context, key, contentType string, data []byte) error
+}
+
+// ArchiveConfig sets the layout and size of archived event files
+type ArchiveConfig struct {
+	Prefix        string        // Key prefix of every file
+	FileRows      int           // A partition is written as soon as it holds this many events
+	FlushInterval time.Duration // Partial partitions are written on the first Flush after this long
+	MaxAttempts   int           // Uploads of a partition before its events are dropped
+}
+
+// DefaultArchiveConfig returns the default archive settings
+func DefaultArchiveConfig() ArchiveConfig {
+	return ArchiveConfig{
+		Prefix:        "analytics",
+		FileRows:      100000,
+		FlushInterval: 5 * time.Minute,
+		MaxAttempts:   5,
+	}
+}
+
+// archivePartition identifies the files an event belongs in
+type archivePartition struct {
+	hour      time.Time
+	eventType string
+}
+
+// ArchiveSink buffers raw events and writes them as Parquet files partitioned by event
+// hour and type, e.g. events/dt=2024-01-01/hour=12/type=page_view/<id>.parquet, so
+// they can be queried directly by Athena, DuckDB or Spark. A partition is written when
+// it reaches FileRows events, and partial partitions once FlushInterval has passed since
+// the last write or when the sink is closed. Snapshots are written as individual JSON
+// objects.
+type ArchiveSink struct {
+	store  ObjectStore
+	config ArchiveConfig
+
+	partitions map[archivePartition][]eventRow
+	failures   map[archivePartition]int // Failed uploads of the buffered partitions
+	lastWrite  time.Time
+	mu         sync.Mutex
+}
+
+// NewArchiveSink creates a sink that writes to store
+func NewArchiveSink(store ObjectStore, config ArchiveConfig) *ArchiveSink {
+	defaults := DefaultArchiveConfig()
+	if config.FileRows <= 0 {
+		config.FileRows = defaults.FileRows
+	}
+	if config.FlushInterval < 0 {
+		config.FlushInterval = 0
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = defaults.MaxAttempts
+	}
+	return &ArchiveSink{
+		store:      store,
+		config:     config,
+		partitions: make(map[archivePartition][]eventRow),
+		failures:   make(map[archivePartition]int),
+		lastWrite:  time.Now(),
+	}
+}
+
+// Name identifies the sink in logs
+func (s *ArchiveSink) Name() string {
+	return s.store.Name()
+}
+
+// WriteEvents buffers events, writing the partitions that reach FileRows events. The
+// events are accepted even if a write fails, since the sink retries the partition itself.
+func (s *ArchiveSink) WriteEvents(ctx context.Context, events []models.AnalyticsEvent) error {
+	s.mu.Lock()
+	full := make(map[archivePartition][]eventRow)
+	for i := range events {
+		row := newEventRow(&events[i])
+		key := archivePartition{hour: row.Timestamp.Truncate(time.Hour), eventType: row.Type}
+		rows := append(s.partitions[key], row)
+		if len(rows) >= s.config.FileRows {
+			full[key] = rows
+			delete(s.partitions, key)
+			continue
+		}
+		s.partitions[key] = rows
+	}
+	s.mu.Unlock()
+
+	if err := s.writePartitions(ctx, full); err != nil {
+		logging.Warn("Failed to write archive partition, retrying on the next flush", "store", s.store.Name(), "error", err)
+	}
+	return nil
+}
+
+// WriteSnapshot writes a snapshot as JSON
+func (s *ArchiveSink) WriteSnapshot(ctx context.Context, snapshot *models.MetricsSnapshot) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+
+	ts := snapshot.Timestamp.UTC()
+	key := path.Join(s.config.Prefix, "snapshots", "dt="+ts.Format("2006-01-02"), ts.Format("150405.000")+".json")
+	return s.store.Put(ctx, key, "application/json", data)
+}
+
+// Flush writes the buffered partitions once FlushInterval has passed since the last write
+func (s *ArchiveSink) Flush(ctx context.Context) error {
+	s.mu.Lock()
+	due := time.Since(s.lastWrite) >= s.config.FlushInterval
+	s.mu.Unlock()
+
+	if !due {
+		return nil
+	}
+	return s.flushAll(ctx)
+}
+
+// flushAll writes every buffered partition
+func (s *ArchiveSink) flushAll(ctx context.Context) error {
+	s.mu.Lock()
+	partitions := s.partitions
+	s.partitions = make(map[archivePartition][]eventRow)
+	s.lastWrite = time.Now()
+	s.mu.Unlock()
+
+	return s.writePartitions(ctx, partitions)
+}
+
+// writePartitions writes one Parquet file per partition. Partitions that fail are kept
+// for the next flush, until they have failed MaxAttempts times and are dropped.
+func (s *ArchiveSink) writePartitions(ctx context.Context, partitions map[archivePartition][]eventRow) error {
+	var firstErr error
+	for key, rows := range partitions {
+		err := s.writeFile(ctx, key, rows)
+		s.mu.Lock()
+		switch {
+		case err == nil:
+			delete(s.failures, key)
+		case s.failures[key]+1 >= s.config.MaxAttempts:
+			delete(s.failures, key)
+			err = fmt.Errorf("dropped %d events after %d failed uploads: %w", len(rows), s.config.MaxAttempts, err)
+		default:
+			s.failures[key]++
+			s.partitions[key] = append(rows, s.partitions[key]...)
+		}
+		s.mu.Unlock()
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// writeFile writes one partition's rows as a Parquet file
+func (s *ArchiveSink) writeFile(ctx context.Context, key archivePartition, rows []eventRow) error {
+	var buf bytes.Buffer
+	if err := parquet.Write(&buf, rows); err != nil {
+		return fmt.Errorf("failed to encode Parquet: %w", err)
+	}
+
+	name := path.Join(s.config.Prefix, "events",
+		"dt="+key.hour.Format("2006-01-02"), "hour="+key.hour.Format("15"), "type="+partitionValue(key.eventType),
+		uuid.NewString()+".parquet")
+	return s.store.Put(ctx, name, "application/vnd.apache.parquet", buf.Bytes())
+}
+
+// partitionValue makes an event type safe to use as a path segment
+func partitionValue(eventType string) string {
+	if eventType == "" {
+		return "unknown"
+	}
+	return url.PathEscape(eventType)
+}
+
+// Close writes the remaining buffered events
+func (s *ArchiveSink) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	return s.flushAll(ctx)
+}
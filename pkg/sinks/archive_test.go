@@ -0,0 +1,109 @@
+package sinks
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+	"github.com/parquet-go/parquet-go"
+)
+
+// archivedFiles lists the Parquet files under dir, relative to it
+func archivedFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	var files []string
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && strings.HasSuffix(path, ".parquet") {
+			rel, _ := filepath.Rel(dir, path)
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	sort.Strings(files)
+	return files
+}
+
+func TestArchiveSinkPartitionsByHourAndType(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewLocalStore(dir)
+	if err != nil {
+		t.Fatalf("NewLocalStore failed: %v", err)
+	}
+	sink := NewArchiveSink(store, ArchiveConfig{Prefix: "raw", FileRows: 2, FlushInterval: time.Hour})
+
+	hour := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	events := []models.AnalyticsEvent{
+		{ID: "1", Type: models.PageView, Timestamp: hour.Add(time.Minute), UserID: "u1"},
+		{ID: "2", Type: models.Click, Timestamp: hour.Add(2 * time.Minute), UserID: "u1"},
+		{ID: "3", Type: models.PageView, Timestamp: hour.Add(3 * time.Minute), UserID: "u2"},
+		{ID: "4", Type: models.PageView, Timestamp: hour.Add(time.Hour), UserID: "u2"},
+	}
+	ctx := context.Background()
+	if err := sink.WriteEvents(ctx, events); err != nil {
+		t.Fatalf("WriteEvents failed: %v", err)
+	}
+
+	// Only the partition that reached FileRows is written before the flush interval
+	files := archivedFiles(t, dir)
+	if len(files) != 1 || !strings.HasPrefix(files[0], "raw/events/dt=2024-03-01/hour=12/type=page_view/") {
+		t.Fatalf("Expected one full page view file, got %v", files)
+	}
+	rows, err := parquet.ReadFile[eventRow](filepath.Join(dir, files[0]))
+	if err != nil || len(rows) != 2 || rows[0].ID != "1" || rows[1].ID != "3" {
+		t.Fatalf("Unexpected rows %+v (%v)", rows, err)
+	}
+
+	if err := sink.Flush(ctx); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if files := archivedFiles(t, dir); len(files) != 1 {
+		t.Fatalf("Expected partial partitions to wait for the flush interval, got %v", files)
+	}
+
+	// Closing writes the partial partitions
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	files = archivedFiles(t, dir)
+	if len(files) != 3 {
+		t.Fatalf("Expected three files after closing, got %v", files)
+	}
+	if !strings.HasPrefix(files[0], "raw/events/dt=2024-03-01/hour=12/type=click/") || !strings.HasPrefix(files[2], "raw/events/dt=2024-03-01/hour=13/type=page_view/") {
+		t.Errorf("Unexpected partitions %v", files)
+	}
+}
+
+// failingStore is an ObjectStore whose uploads always fail
+type failingStore struct{ puts int }
+
+func (s *failingStore) Name() string { return "failing" }
+
+func (s *failingStore) Put(ctx context.Context, key, contentType string, data []byte) error {
+	s.puts++
+	return errors.New("service unavailable")
+}
+
+func TestArchiveSinkBoundsRetries(t *testing.T) {
+	store := &failingStore{}
+	sink := NewArchiveSink(store, ArchiveConfig{FileRows: 10, MaxAttempts: 3})
+
+	ctx := context.Background()
+	sink.WriteEvents(ctx, []models.AnalyticsEvent{{ID: "1", Type: models.PageView, Timestamp: time.Now()}})
+	for i := 0; i < 2; i++ {
+		if err := sink.Flush(ctx); err == nil || strings.Contains(err.Error(), "dropped") {
+			t.Fatalf("flush %d: expected the partition kept for a retry, got %v", i+1, err)
+		}
+	}
+	if err := sink.Flush(ctx); err == nil || !strings.Contains(err.Error(), "dropped 1 events after 3 failed uploads") {
+		t.Fatalf("expected the partition dropped after 3 uploads, got %v", err)
+	}
+	if err := sink.Flush(ctx); err != nil || store.puts != 3 {
+		t.Errorf("expected nothing left to upload, got %v after %d uploads", err, store.puts)
+	}
+}
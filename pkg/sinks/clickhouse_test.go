@@ -0,0 +1,71 @@
+package sinks
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+func TestClickHouseSinkInsertsJSONEachRow(t *testing.T) {
+	var queries []string
+	var rows []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, _ := r.BasicAuth(); user != "writer" || pass != "secret" {
+			http.Error(w, "auth failed", http.StatusUnauthorized)
+			return
+		}
+
+		if query := r.URL.Query().Get("query"); query != "" {
+			queries = append(queries, query)
+			scanner := bufio.NewScanner(r.Body)
+			for scanner.Scan() {
+				var row map[string]interface{}
+				if err := json.Unmarshal(scanner.Bytes(), &row); err != nil {
+					t.Errorf("Invalid row %q: %v", scanner.Text(), err)
+				}
+				rows = append(rows, row)
+			}
+		} else {
+			var body strings.Builder
+			bufio.NewReader(r.Body).WriteTo(&body)
+			queries = append(queries, body.String())
+		}
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	endpoint := strings.Replace(server.URL, "http://", "http://writer:secret@", 1)
+	sink, err := NewClickHouseSink(ctx, endpoint, "analytics")
+	if err != nil {
+		t.Fatalf("NewClickHouseSink: %v", err)
+	}
+	defer sink.Close()
+
+	events := []models.AnalyticsEvent{{
+		ID:        "evt-1",
+		Type:      models.PageView,
+		Timestamp: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		UserID:    "user-1",
+		Metadata:  map[string]interface{}{"load_time": 1200},
+	}}
+	if err := sink.WriteEvents(ctx, events); err != nil {
+		t.Fatalf("WriteEvents: %v", err)
+	}
+
+	if len(queries) != 3 || !strings.HasPrefix(queries[2], "INSERT INTO analytics.analytics_events FORMAT JSONEachRow") {
+		t.Fatalf("Unexpected queries: %q", queries)
+	}
+	if len(rows) != 1 {
+		t.Fatalf("Expected 1 row, got %d", len(rows))
+	}
+	if rows[0]["timestamp"] != "2024-01-02 03:04:05.000" || rows[0]["metadata"] != `{"load_time":1200}` {
+		t.Errorf("Unexpected row: %v", rows[0])
+	}
+}
@@ -0,0 +1,213 @@
+package sinks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+// ClickHouseWarehouse answers historical queries with SQL over the analytics_events table
+// the ClickHouse sink writes. FINAL collapses events delivered more than once.
+type ClickHouseWarehouse struct {
+	clickHouseClient
+}
+
+// NewClickHouseWarehouse queries the events table in database over the ClickHouse HTTP
+// endpoint; it doesn't contact ClickHouse until the first query
+func NewClickHouseWarehouse(endpoint, database string, timeout time.Duration) *ClickHouseWarehouse {
+	return &ClickHouseWarehouse{clickHouseClient{
+		endpoint: endpoint,
+		database: database,
+		client:   &http.Client{Timeout: timeout},
+	}}
+}
+
+// Close releases idle connections
+func (w *ClickHouseWarehouse) Close() error {
+	w.client.CloseIdleConnections()
+	return nil
+}
+
+// clickHouseRange restricts a query to events in [from, to), bound as query parameters
+const clickHouseRange = `timestamp >= fromUnixTimestamp64Milli({from:Int64}, 'UTC') AND timestamp < fromUnixTimestamp64Milli({to:Int64}, 'UTC')`
+
+// QueryRollups counts events per hour or day of [from, to), filling buckets without
+// events with zeroes. Unique users and sessions are exact for every bucket.
+func (w *ClickHouseWarehouse) QueryRollups(ctx context.Context, granularity models.Granularity, from, to time.Time) ([]models.Rollup, error) {
+	bucket := "toStartOfHour(timestamp)"
+	switch granularity {
+	case models.GranularityDay:
+		bucket = "toStartOfDay(timestamp)"
+	case models.GranularityWeek:
+		bucket = "toDateTime(toMonday(timestamp))"
+	case models.GranularityMonth:
+		bucket = "toDateTime(toStartOfMonth(timestamp))"
+	}
+
+	query := fmt.Sprintf(`SELECT
+	toUnixTimestamp(%s) AS bucket,
+	count() AS events,
+	countIf(type = {page_view:String}) AS page_views,
+	uniqExactIf(user_id, user_id != '') AS unique_users,
+	uniqExactIf(session_id, session_id != '') AS sessions,
+	sumMap(map(toString(type), toUInt64(1))) AS events_by_type
+FROM %s.analytics_events FINAL
+WHERE %s
+GROUP BY bucket
+ORDER BY bucket`, bucket, w.database, clickHouseRange)
+
+	params := rangeParams(from, to)
+	params.Set("param_page_view", string(models.PageView))
+	var rows []struct {
+		Bucket       int64            `json:"bucket"`
+		Events       int64            `json:"events"`
+		PageViews    int64            `json:"page_views"`
+		UniqueUsers  int64            `json:"unique_users"`
+		Sessions     int64            `json:"sessions"`
+		EventsByType map[string]int64 `json:"events_by_type"`
+	}
+	if err := w.query(ctx, query, params, &rows); err != nil {
+		return nil, err
+	}
+
+	buckets := make(map[int64]models.Rollup, len(rows))
+	for _, row := range rows {
+		rollup := models.Rollup{
+			Start:        time.Unix(row.Bucket, 0).UTC(),
+			Granularity:  granularity,
+			Events:       row.Events,
+			PageViews:    row.PageViews,
+			UniqueUsers:  row.UniqueUsers,
+			Sessions:     row.Sessions,
+			EventsByType: make(map[models.EventType]int64, len(row.EventsByType)),
+		}
+		for eventType, count := range row.EventsByType {
+			rollup.EventsByType[models.EventType(eventType)] = count
+		}
+		buckets[row.Bucket] = rollup
+	}
+
+	var result []models.Rollup
+	for begin := granularity.Start(from); begin.Before(to); begin = granularity.Next(begin) {
+		rollup, ok := buckets[begin.Unix()]
+		if !ok {
+			rollup = models.Rollup{Start: begin, Granularity: granularity, EventsByType: make(map[models.EventType]int64)}
+		}
+		result = append(result, rollup)
+	}
+	return result, nil
+}
+
+// TopPages returns the most viewed paths of [from, to) with their unique users. Time on
+// page is not archived, so AverageTime is zero.
+func (w *ClickHouseWarehouse) TopPages(ctx context.Context, from, to time.Time, limit int) ([]models.PageMetric, error) {
+	query := fmt.Sprintf(`SELECT
+	path,
+	any(url) AS url,
+	count() AS views,
+	uniqExactIf(user_id, user_id != '') AS unique_visitors
+FROM %s.analytics_events FINAL
+WHERE type = {page_view:String} AND path != '' AND %s
+GROUP BY path
+ORDER BY views DESC, path
+LIMIT %d`, w.database, clickHouseRange, limit)
+
+	params := rangeParams(from, to)
+	params.Set("param_page_view", string(models.PageView))
+	var rows []struct {
+		Path           string `json:"path"`
+		URL            string `json:"url"`
+		Views          int64  `json:"views"`
+		UniqueVisitors int64  `json:"unique_visitors"`
+	}
+	if err := w.query(ctx, query, params, &rows); err != nil {
+		return nil, err
+	}
+
+	pages := make([]models.PageMetric, 0, len(rows))
+	for _, row := range rows {
+		pages = append(pages, models.PageMetric{URL: row.URL, Path: row.Path, Views: row.Views, UniqueVisitors: row.UniqueVisitors})
+	}
+	return pages, nil
+}
+
+// Funnel counts the visitors of [from, to) reaching each step with windowFunnel, which
+// matches the steps in order within the window of the first. Visitors are users, or
+// sessions for anonymous events.
+func (w *ClickHouseWarehouse) Funnel(ctx context.Context, steps []models.Goal, from, to time.Time, window time.Duration) ([]int64, error) {
+	params := rangeParams(from, to)
+	conditions := make([]string, len(steps))
+	for i, goal := range steps {
+		// Goals are bound as parameters; only their position is formatted into the SQL
+		condition := fmt.Sprintf("startsWith(path, {path%d:String})", i)
+		params.Set(fmt.Sprintf("param_path%d", i), goal.PathPrefix)
+		if goal.EventType != "" {
+			condition = fmt.Sprintf("type = {type%d:String} AND %s", i, condition)
+			params.Set(fmt.Sprintf("param_type%d", i), string(goal.EventType))
+		}
+		conditions[i] = condition
+	}
+
+	query := fmt.Sprintf(`SELECT level, count() AS visitors
+FROM (
+	SELECT
+		if(user_id != '', concat('user:', user_id), concat('session:', session_id)) AS visitor,
+		windowFunnel(%d)(toDateTime(timestamp), %s) AS level
+	FROM %s.analytics_events FINAL
+	WHERE (user_id != '' OR session_id != '') AND %s
+	GROUP BY visitor
+)
+WHERE level > 0
+GROUP BY level`, int64(window.Seconds()), strings.Join(conditions, ", "), w.database, clickHouseRange)
+
+	var rows []struct {
+		Level    int   `json:"level"`
+		Visitors int64 `json:"visitors"`
+	}
+	if err := w.query(ctx, query, params, &rows); err != nil {
+		return nil, err
+	}
+
+	// Visitors reaching a step include those that went further
+	reached := make([]int64, len(steps))
+	for _, row := range rows {
+		for step := 0; step < row.Level && step < len(steps); step++ {
+			reached[step] += row.Visitors
+		}
+	}
+	return reached, nil
+}
+
+// query runs a SELECT and decodes the rows of its JSON result into rows
+func (w *ClickHouseWarehouse) query(ctx context.Context, query string, params url.Values, rows interface{}) error {
+	// Integers are quoted as strings by default, since they may exceed JavaScript's range
+	params.Set("output_format_json_quote_64bit_integers", "0")
+
+	var body bytes.Buffer
+	if err := w.post(ctx, query+"\nFORMAT JSON", params, nil, &body); err != nil {
+		return err
+	}
+	result := struct {
+		Data interface{} `json:"data"`
+	}{Data: rows}
+	if err := json.Unmarshal(body.Bytes(), &result); err != nil {
+		return fmt.Errorf("failed to decode ClickHouse result: %w", err)
+	}
+	return nil
+}
+
+// rangeParams binds the from and to placeholders of clickHouseRange
+func rangeParams(from, to time.Time) url.Values {
+	return url.Values{
+		"param_from": {strconv.FormatInt(from.UnixMilli(), 10)},
+		"param_to":   {strconv.FormatInt(to.UnixMilli(), 10)},
+	}
+}
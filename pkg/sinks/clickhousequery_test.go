@@ -0,0 +1,101 @@
+package sinks
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+// clickHouseStub answers every query with result and records the queries and parameters
+func clickHouseStub(result string, queries *[]string, params *[]map[string]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		*queries = append(*queries, string(body))
+		values := make(map[string]string)
+		for key := range r.URL.Query() {
+			values[key] = r.URL.Query().Get(key)
+		}
+		*params = append(*params, values)
+		io.WriteString(w, result)
+	}))
+}
+
+func TestClickHouseWarehouseRollups(t *testing.T) {
+	from := time.Date(2024, 3, 1, 10, 30, 0, 0, time.UTC)
+	to := time.Date(2024, 3, 1, 13, 0, 0, 0, time.UTC)
+	var queries []string
+	var params []map[string]string
+	server := clickHouseStub(`{"meta": [], "data": [
+		{"bucket": 1709290800, "events": 5, "page_views": 3, "unique_users": 2, "sessions": 2, "events_by_type": {"page_view": 3, "click": 2}}
+	], "rows": 1}`, &queries, &params)
+	defer server.Close()
+
+	warehouse := NewClickHouseWarehouse(server.URL, "analytics", time.Second)
+	rollups, err := warehouse.QueryRollups(context.Background(), models.GranularityHour, from, to)
+	if err != nil {
+		t.Fatalf("QueryRollups failed: %v", err)
+	}
+
+	// 10:00 to 12:00, with the hours without events filled in
+	if len(rollups) != 3 {
+		t.Fatalf("Expected 3 hourly buckets, got %+v", rollups)
+	}
+	if got := rollups[1]; got.Events != 5 || got.UniqueUsers != 2 || got.EventsByType[models.Click] != 2 || !got.Start.Equal(from.Truncate(time.Hour).Add(time.Hour)) {
+		t.Errorf("Unexpected rollup %+v", got)
+	}
+	if rollups[0].Events != 0 || rollups[2].Events != 0 {
+		t.Errorf("Expected empty buckets around the result, got %+v", rollups)
+	}
+
+	if !strings.Contains(queries[0], "FROM analytics.analytics_events FINAL") || !strings.HasSuffix(queries[0], "FORMAT JSON") {
+		t.Errorf("Unexpected query %s", queries[0])
+	}
+	if params[0]["param_from"] != "1709289000000" || params[0]["param_page_view"] != "page_view" || params[0]["output_format_json_quote_64bit_integers"] != "0" {
+		t.Errorf("Unexpected parameters %v", params[0])
+	}
+}
+
+func TestClickHouseWarehouseFunnel(t *testing.T) {
+	var queries []string
+	var params []map[string]string
+	server := clickHouseStub(`{"data": [{"level": 1, "visitors": 5}, {"level": 3, "visitors": 2}]}`, &queries, &params)
+	defer server.Close()
+
+	warehouse := NewClickHouseWarehouse(server.URL, "analytics", time.Second)
+	steps := []models.Goal{{EventType: models.PageView, PathPrefix: "/pricing"}, {PathPrefix: "/signup' OR 1=1"}, {EventType: models.Purchase}}
+	now := time.Now()
+	reached, err := warehouse.Funnel(context.Background(), steps, now.Add(-time.Hour), now, 30*time.Minute)
+	if err != nil {
+		t.Fatalf("Funnel failed: %v", err)
+	}
+	if len(reached) != 3 || reached[0] != 7 || reached[1] != 2 || reached[2] != 2 {
+		t.Errorf("Unexpected visitors per step %v", reached)
+	}
+
+	// Goals are bound as parameters rather than formatted into the SQL
+	if strings.Contains(queries[0], "OR 1=1") || params[0]["param_path1"] != "/signup' OR 1=1" {
+		t.Errorf("Expected step values bound as parameters, got %s with %v", queries[0], params[0])
+	}
+	if !strings.Contains(queries[0], "windowFunnel(1800)") || strings.Contains(queries[0], "{type1:String}") {
+		t.Errorf("Unexpected funnel query %s", queries[0])
+	}
+}
+
+func TestClickHouseWarehouseErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Code: 60. DB::Exception: Table analytics.analytics_events does not exist", http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	warehouse := NewClickHouseWarehouse(server.URL, "analytics", time.Second)
+	now := time.Now()
+	if _, err := warehouse.TopPages(context.Background(), now.Add(-time.Hour), now, 10); err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Errorf("Expected the ClickHouse error, got %v", err)
+	}
+}
@@ -0,0 +1,46 @@
+package sinks
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// LocalStore stores archive files in a local directory, e.g. for DuckDB or a mounted
+// volume synced elsewhere
+type LocalStore struct {
+	dir string
+}
+
+// NewLocalStore creates a store writing under dir, creating it if needed
+func NewLocalStore(dir string) (*LocalStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create archive directory: %w", err)
+	}
+	return &LocalStore{dir: dir}, nil
+}
+
+// Name identifies the store in logs
+func (s *LocalStore) Name() string {
+	return "local"
+}
+
+// Put writes an object to a file, renaming it into place so readers never see a
+// partial file
+func (s *LocalStore) Put(ctx context.Context, key, contentType string, data []byte) error {
+	path := filepath.Join(s.dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,258 @@
+package sinks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/logging"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/spool"
+)
+
+// Pipeline batches processed events and delivers them, along with periodic
+// snapshots, to every configured sink
+type Pipeline struct {
+	sinks     []Sink
+	batchSize int
+
+	buffer []models.AnalyticsEvent
+	mu     sync.Mutex
+
+	// writeMu serialises deliveries so batches reach each sink in order
+	writeMu sync.Mutex
+
+	// Batches each sink failed to write, by sink name; nil drops them
+	retries map[string]*spool.Spool
+}
+
+// NewPipeline creates a pipeline that writes events to the sinks in batches of batchSize
+func NewPipeline(sinks []Sink, batchSize int) *Pipeline {
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	return &Pipeline{
+		sinks:     sinks,
+		batchSize: batchSize,
+		buffer:    make([]models.AnalyticsEvent, 0, batchSize),
+	}
+}
+
+// Add queues an event, writing the batch when it is full. The caller is blocked
+// while a full batch is written, which applies backpressure to consumption.
+func (p *Pipeline) Add(ctx context.Context, event *models.AnalyticsEvent) {
+	p.mu.Lock()
+	p.buffer = append(p.buffer, *event)
+	var batch []models.AnalyticsEvent
+	if len(p.buffer) >= p.batchSize {
+		batch = p.takeBuffer()
+	}
+	p.mu.Unlock()
+
+	if batch != nil {
+		p.writeEvents(ctx, batch)
+	}
+}
+
+// SetSpool keeps the batches a sink fails to write on disk in dir, one spool per sink of
+// at most maxBytes (0 for no limit), and retries them on every flush. Events are only
+// dropped once a sink's spool is full.
+func (p *Pipeline) SetSpool(dir string, maxBytes int64) error {
+	retries := make(map[string]*spool.Spool, len(p.sinks))
+	for _, sink := range p.sinks {
+		retry, err := spool.Open(filepath.Join(dir, sink.Name()), maxBytes)
+		if err != nil {
+			return fmt.Errorf("failed to open spool of sink %s: %w", sink.Name(), err)
+		}
+		if pending := retry.Pending(); pending > 0 {
+			logging.Info("Recovered spooled sink events", "sink", sink.Name(), "events", pending)
+		}
+		retries[sink.Name()] = retry
+	}
+
+	p.writeMu.Lock()
+	defer p.writeMu.Unlock()
+	p.retries = retries
+	return nil
+}
+
+// takeBuffer swaps out the buffered events; the caller must hold mu
+func (p *Pipeline) takeBuffer() []models.AnalyticsEvent {
+	batch := p.buffer
+	p.buffer = make([]models.AnalyticsEvent, 0, p.batchSize)
+	return batch
+}
+
+// writeEvents delivers a batch to every sink. A failing sink is logged and does not
+// block the others; its batch is spooled for a retry rather than stalling consumption,
+// or dropped without a spool. While a sink has spooled events, new batches are spooled
+// behind them so they arrive in order.
+func (p *Pipeline) writeEvents(ctx context.Context, batch []models.AnalyticsEvent) {
+	p.writeMu.Lock()
+	defer p.writeMu.Unlock()
+
+	for _, sink := range p.sinks {
+		retry := p.retries[sink.Name()]
+		if retry != nil && retry.Pending() > 0 {
+			spoolEvents(sink, retry, batch)
+			continue
+		}
+		if err := sink.WriteEvents(ctx, batch); err != nil {
+			logging.Error("Sink failed to write events", "sink", sink.Name(), "events", len(batch), "error", err)
+			if retry != nil {
+				spoolEvents(sink, retry, batch)
+			}
+		}
+	}
+}
+
+// spoolEvents keeps a batch a sink could not write for a retry
+func spoolEvents(sink Sink, retry *spool.Spool, batch []models.AnalyticsEvent) {
+	for i := range batch {
+		err := retry.Append(spool.Record{Topic: sink.Name(), Event: batch[i]})
+		if errors.Is(err, spool.ErrFull) {
+			logging.Error("Sink spool is full, dropping events", "sink", sink.Name(), "events", len(batch)-i)
+			return
+		}
+		if err != nil {
+			logging.Error("Failed to spool sink events, dropping them", "sink", sink.Name(), "events", len(batch)-i, "error", err)
+			return
+		}
+	}
+}
+
+// retrySpooled writes the spooled batches of every sink, oldest first, stopping at a
+// sink's first failure. The caller must hold writeMu.
+func (p *Pipeline) retrySpooled(ctx context.Context) {
+	for _, sink := range p.sinks {
+		retry := p.retries[sink.Name()]
+		if retry == nil || retry.Pending() == 0 {
+			continue
+		}
+		sent, err := retry.DrainBatches(ctx, p.batchSize, func(records []spool.Record) error {
+			events := make([]models.AnalyticsEvent, len(records))
+			for i := range records {
+				events[i] = records[i].Event
+			}
+			return sink.WriteEvents(ctx, events)
+		})
+		if err != nil {
+			logging.Warn("Sink still failing, keeping events spooled", "sink", sink.Name(), "sent", sent, "pending", retry.Pending(), "error", err)
+		} else if sent > 0 {
+			logging.Info("Wrote spooled sink events", "sink", sink.Name(), "events", sent)
+		}
+	}
+}
+
+// WriteSnapshot delivers a snapshot to every sink
+func (p *Pipeline) WriteSnapshot(ctx context.Context, snapshot *models.MetricsSnapshot) {
+	p.writeMu.Lock()
+	defer p.writeMu.Unlock()
+
+	for _, sink := range p.sinks {
+		if err := sink.WriteSnapshot(ctx, snapshot); err != nil {
+			logging.Error("Sink failed to write snapshot", "sink", sink.Name(), "error", err)
+		}
+	}
+}
+
+// Flush writes buffered events, retries spooled ones and flushes every sink
+func (p *Pipeline) Flush(ctx context.Context) {
+	p.mu.Lock()
+	batch := p.takeBuffer()
+	p.mu.Unlock()
+
+	if len(batch) > 0 {
+		p.writeEvents(ctx, batch)
+	}
+
+	p.writeMu.Lock()
+	defer p.writeMu.Unlock()
+	p.retrySpooled(ctx)
+	for _, sink := range p.sinks {
+		if err := sink.Flush(ctx); err != nil {
+			logging.Error("Sink failed to flush", "sink", sink.Name(), "error", err)
+		}
+	}
+}
+
+// DeleteUser drops the user's buffered events and deletes their stored events from every
+// sink that supports it. Sinks that cannot delete, such as S3, are logged and skipped.
+func (p *Pipeline) DeleteUser(ctx context.Context, userID string) error {
+	p.mu.Lock()
+	kept := p.buffer[:0]
+	for _, event := range p.buffer {
+		if event.UserID != userID {
+			kept = append(kept, event)
+		}
+	}
+	p.buffer = kept
+	p.mu.Unlock()
+
+	p.writeMu.Lock()
+	defer p.writeMu.Unlock()
+
+	var errs []error
+	for _, sink := range p.sinks {
+		if retry := p.retries[sink.Name()]; retry != nil {
+			if _, err := retry.Remove(func(record spool.Record) bool { return record.Event.UserID == userID }); err != nil {
+				errs = append(errs, fmt.Errorf("spool of sink %s: %w", sink.Name(), err))
+			}
+		}
+		eraser, ok := sink.(Eraser)
+		if !ok {
+			logging.Warn("Sink does not support erasure; events of the user remain there", "sink", sink.Name())
+			continue
+		}
+		if err := eraser.DeleteUser(ctx, userID); err != nil {
+			errs = append(errs, fmt.Errorf("sink %s: %w", sink.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Run flushes every flushInterval and, if snapshotInterval is positive, writes the
+// snapshot returned by snapshot every snapshotInterval. When ctx is cancelled it
+// flushes one final time and closes the sinks.
+func (p *Pipeline) Run(ctx context.Context, flushInterval, snapshotInterval time.Duration, snapshot func() *models.MetricsSnapshot) {
+	flushTicker := time.NewTicker(flushInterval)
+	defer flushTicker.Stop()
+
+	var snapshots <-chan time.Time
+	if snapshotInterval > 0 {
+		snapshotTicker := time.NewTicker(snapshotInterval)
+		defer snapshotTicker.Stop()
+		snapshots = snapshotTicker.C
+	}
+
+	for {
+		select {
+		case <-flushTicker.C:
+			p.Flush(ctx)
+		case <-snapshots:
+			p.WriteSnapshot(ctx, snapshot())
+		case <-ctx.Done():
+			// Use a fresh context so the final flush is not cancelled
+			flushCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			p.Flush(flushCtx)
+			cancel()
+			p.Close()
+			return
+		}
+	}
+}
+
+// Close closes every sink and spool; spooled events are retried after a restart
+func (p *Pipeline) Close() {
+	for _, sink := range p.sinks {
+		if err := sink.Close(); err != nil {
+			logging.Error("Failed to close sink", "sink", sink.Name(), "error", err)
+		}
+		if retry := p.retries[sink.Name()]; retry != nil {
+			retry.Close()
+		}
+	}
+}
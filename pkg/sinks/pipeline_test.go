@@ -0,0 +1,161 @@
+package sinks
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+// recordingSink records everything written to it
+type recordingSink struct {
+	batches   [][]models.AnalyticsEvent
+	snapshots int
+	flushes   int
+	closed    bool
+	mu        sync.Mutex
+}
+
+func (s *recordingSink) Name() string { return "recording" }
+
+func (s *recordingSink) WriteEvents(ctx context.Context, events []models.AnalyticsEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batches = append(s.batches, events)
+	return nil
+}
+
+func (s *recordingSink) WriteSnapshot(ctx context.Context, snapshot *models.MetricsSnapshot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.snapshots++
+	return nil
+}
+
+func (s *recordingSink) Flush(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.flushes++
+	return nil
+}
+
+func (s *recordingSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return nil
+}
+
+// erasingSink is a recordingSink that also supports erasure
+type erasingSink struct {
+	recordingSink
+	deleted []string
+}
+
+func (s *erasingSink) DeleteUser(ctx context.Context, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deleted = append(s.deleted, userID)
+	return nil
+}
+
+func TestPipelineDeleteUser(t *testing.T) {
+	eraser := &erasingSink{}
+	pipeline := NewPipeline([]Sink{eraser, &recordingSink{}}, 10)
+
+	ctx := context.Background()
+	pipeline.Add(ctx, &models.AnalyticsEvent{ID: "1", UserID: "u1"})
+	pipeline.Add(ctx, &models.AnalyticsEvent{ID: "2", UserID: "u2"})
+	if err := pipeline.DeleteUser(ctx, "u1"); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+	pipeline.Flush(ctx)
+
+	if len(eraser.deleted) != 1 || eraser.deleted[0] != "u1" {
+		t.Errorf("expected the erasing sink to delete u1, got %v", eraser.deleted)
+	}
+	if len(eraser.batches) != 1 || len(eraser.batches[0]) != 1 || eraser.batches[0][0].UserID != "u2" {
+		t.Errorf("expected only u2's buffered event to be written, got %+v", eraser.batches)
+	}
+}
+
+func TestPipelineBatchesAndFlushesOnShutdown(t *testing.T) {
+	sink := &recordingSink{}
+	pipeline := NewPipeline([]Sink{sink}, 2)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		pipeline.Run(ctx, time.Hour, time.Hour, func() *models.MetricsSnapshot { return &models.MetricsSnapshot{} })
+		close(done)
+	}()
+
+	for i := 0; i < 3; i++ {
+		pipeline.Add(ctx, &models.AnalyticsEvent{ID: fmt.Sprintf("evt-%d", i)})
+	}
+
+	sink.mu.Lock()
+	if len(sink.batches) != 1 || len(sink.batches[0]) != 2 {
+		t.Fatalf("Expected one full batch of 2 before shutdown, got %v", sink.batches)
+	}
+	sink.mu.Unlock()
+
+	cancel()
+	<-done
+
+	if len(sink.batches) != 2 || len(sink.batches[1]) != 1 || sink.batches[1][0].ID != "evt-2" {
+		t.Errorf("Expected the partial batch to be written on shutdown, got %v", sink.batches)
+	}
+	if sink.flushes != 1 || !sink.closed {
+		t.Errorf("Expected a final flush and close, got %d flushes, closed=%v", sink.flushes, sink.closed)
+	}
+}
+
+// flakySink is a recordingSink whose writes fail while failing is set
+type flakySink struct {
+	recordingSink
+	failing bool
+}
+
+func (s *flakySink) WriteEvents(ctx context.Context, events []models.AnalyticsEvent) error {
+	if s.failing {
+		return fmt.Errorf("connection refused")
+	}
+	return s.recordingSink.WriteEvents(ctx, events)
+}
+
+func TestPipelineSpoolsFailedBatches(t *testing.T) {
+	sink := &flakySink{failing: true}
+	pipeline := NewPipeline([]Sink{sink}, 2)
+	if err := pipeline.SetSpool(t.TempDir(), 0); err != nil {
+		t.Fatalf("SetSpool: %v", err)
+	}
+
+	ctx := context.Background()
+	for i, userID := range []string{"u1", "u2", "u1", "u3"} {
+		pipeline.Add(ctx, &models.AnalyticsEvent{ID: fmt.Sprint(i), UserID: userID})
+	}
+	if len(sink.batches) != 0 {
+		t.Fatalf("expected no batch written while the sink fails, got %d", len(sink.batches))
+	}
+
+	// Failed batches wait on disk, and erasure reaches them there
+	if err := pipeline.DeleteUser(ctx, "u1"); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+	sink.failing = false
+	pipeline.Flush(ctx)
+
+	var ids []string
+	for _, batch := range sink.batches {
+		for _, event := range batch {
+			ids = append(ids, event.ID)
+		}
+	}
+	if fmt.Sprint(ids) != "[1 3]" {
+		t.Errorf("expected the spooled events of u2 and u3 written in order, got %v", ids)
+	}
+}
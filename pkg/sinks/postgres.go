@@ -0,0 +1,119 @@
+package sinks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Postgres tables, created on startup if missing
+const postgresSchema = `
+CREATE TABLE IF NOT EXISTS analytics_events (
+	id TEXT PRIMARY KEY,
+	type TEXT NOT NULL,
+	timestamp TIMESTAMPTZ NOT NULL,
+	user_id TEXT NOT NULL,
+	session_id TEXT NOT NULL,
+	url TEXT NOT NULL,
+	path TEXT NOT NULL,
+	referrer TEXT NOT NULL,
+	user_agent TEXT NOT NULL,
+	ip_address TEXT NOT NULL,
+	metadata JSONB NOT NULL
+);
+CREATE INDEX IF NOT EXISTS analytics_events_timestamp_idx ON analytics_events (timestamp);
+CREATE TABLE IF NOT EXISTS analytics_snapshots (
+	timestamp TIMESTAMPTZ NOT NULL,
+	total_events BIGINT NOT NULL,
+	unique_users BIGINT NOT NULL,
+	active_sessions BIGINT NOT NULL,
+	snapshot JSONB NOT NULL
+);`
+
+// PostgresSink writes to PostgreSQL (or a compatible warehouse) using the native protocol
+type PostgresSink struct {
+	pool *pgxpool.Pool
+}
+
+// NewPostgresSink connects using dsn and creates the events and snapshots tables if they don't exist
+func NewPostgresSink(ctx context.Context, dsn string) (*PostgresSink, error) {
+	pool, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to Postgres: %w", err)
+	}
+
+	if _, err := pool.Exec(ctx, postgresSchema); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("failed to create Postgres tables: %w", err)
+	}
+	return &PostgresSink{pool: pool}, nil
+}
+
+// Name identifies the sink in logs
+func (s *PostgresSink) Name() string {
+	return "postgres"
+}
+
+// WriteEvents inserts a batch of events in one round trip. Events already stored,
+// e.g. redelivered after a consumer restart, are ignored.
+func (s *PostgresSink) WriteEvents(ctx context.Context, events []models.AnalyticsEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	batch := &pgx.Batch{}
+	for i := range events {
+		row := newEventRow(&events[i])
+		batch.Queue(`INSERT INTO analytics_events
+			(id, type, timestamp, user_id, session_id, url, path, referrer, user_agent, ip_address, metadata)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+			ON CONFLICT (id) DO NOTHING`,
+			row.ID, row.Type, row.Timestamp, row.UserID, row.SessionID, row.URL,
+			row.Path, row.Referrer, row.UserAgent, row.IPAddress, row.Metadata)
+	}
+
+	if err := s.pool.SendBatch(ctx, batch).Close(); err != nil {
+		return fmt.Errorf("failed to insert events: %w", err)
+	}
+	return nil
+}
+
+// WriteSnapshot inserts a snapshot, keeping the headline counters as columns
+func (s *PostgresSink) WriteSnapshot(ctx context.Context, snapshot *models.MetricsSnapshot) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+
+	_, err = s.pool.Exec(ctx, `INSERT INTO analytics_snapshots
+		(timestamp, total_events, unique_users, active_sessions, snapshot)
+		VALUES ($1, $2, $3, $4, $5)`,
+		snapshot.Timestamp.UTC(), snapshot.TotalEvents, snapshot.UniqueUsers, snapshot.ActiveSessions, string(data))
+	if err != nil {
+		return fmt.Errorf("failed to insert snapshot: %w", err)
+	}
+	return nil
+}
+
+// DeleteUser deletes the user's events
+func (s *PostgresSink) DeleteUser(ctx context.Context, userID string) error {
+	if _, err := s.pool.Exec(ctx, `DELETE FROM analytics_events WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to delete user events: %w", err)
+	}
+	return nil
+}
+
+// Flush is a no-op: every write is a synchronous insert
+func (s *PostgresSink) Flush(ctx context.Context) error {
+	return nil
+}
+
+// Close closes the connection pool
+func (s *PostgresSink) Close() error {
+	s.pool.Close()
+	return nil
+}
@@ -0,0 +1,55 @@
+package sinks
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// Uploader is the subset of the S3 client used by the S3 store
+type Uploader interface {
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+}
+
+// S3Store stores archive files in an S3 bucket
+type S3Store struct {
+	client Uploader
+	bucket string
+}
+
+// NewS3Store creates a store for bucket using the default AWS credential chain and region
+func NewS3Store(ctx context.Context, bucket string) (*S3Store, error) {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	return NewS3StoreWithClient(s3.NewFromConfig(cfg), bucket), nil
+}
+
+// NewS3StoreWithClient creates a store that uploads through client
+func NewS3StoreWithClient(client Uploader, bucket string) *S3Store {
+	return &S3Store{client: client, bucket: bucket}
+}
+
+// Name identifies the store in logs
+func (s *S3Store) Name() string {
+	return "s3"
+}
+
+// Put uploads one object
+func (s *S3Store) Put(ctx context.Context, key, contentType string, data []byte) error {
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String(contentType),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload s3://%s/%s: %w", s.bucket, key, err)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package sinks
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+// Sink writes processed analytics to an external system such as a data warehouse
+type Sink interface {
+	// Name identifies the sink in logs
+	Name() string
+
+	// WriteEvents writes a batch of raw events
+	WriteEvents(ctx context.Context, events []models.AnalyticsEvent) error
+
+	// WriteSnapshot writes a point-in-time analytics snapshot
+	WriteSnapshot(ctx context.Context, snapshot *models.MetricsSnapshot) error
+
+	// Flush makes previously written data durable, for sinks that buffer internally
+	Flush(ctx context.Context) error
+
+	Close() error
+}
+
+// Eraser is implemented by sinks that can delete stored events, for right-to-erasure requests
+type Eraser interface {
+	// DeleteUser deletes every stored event of the user
+	DeleteUser(ctx context.Context, userID string) error
+}
+
+// eventRow is the flat, warehouse-friendly form of an event shared by all sinks
+type eventRow struct {
+	ID        string    `json:"id" parquet:"id"`
+	Type      string    `json:"type" parquet:"type"`
+	Timestamp time.Time `json:"timestamp" parquet:"timestamp,timestamp(millisecond)"`
+	UserID    string    `json:"user_id" parquet:"user_id"`
+	SessionID string    `json:"session_id" parquet:"session_id"`
+	URL       string    `json:"url" parquet:"url"`
+	Path      string    `json:"path" parquet:"path"`
+	Referrer  string    `json:"referrer" parquet:"referrer"`
+	UserAgent string    `json:"user_agent" parquet:"user_agent"`
+	IPAddress string    `json:"ip_address" parquet:"ip_address"`
+	Metadata  string    `json:"metadata" parquet:"metadata"` // JSON-encoded metadata object
+}
+
+// newEventRow flattens an event, encoding its metadata as JSON
+func newEventRow(event *models.AnalyticsEvent) eventRow {
+	metadata := "{}"
+	if len(event.Metadata) > 0 {
+		if data, err := json.Marshal(event.Metadata); err == nil {
+			metadata = string(data)
+		}
+	}
+
+	return eventRow{
+		ID:        event.ID,
+		Type:      string(event.Type),
+		Timestamp: event.Timestamp.UTC(),
+		UserID:    event.UserID,
+		SessionID: event.SessionID,
+		URL:       event.URL,
+		Path:      event.Path,
+		Referrer:  event.Referrer,
+		UserAgent: event.UserAgent,
+		IPAddress: event.IPAddress,
+		Metadata:  metadata,
+	}
+}
@@ -0,0 +1,379 @@
+package spool
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+const (
+	segmentSuffix = ".jsonl"
+
+	// Largest record accepted when reading segments back
+	maxRecordBytes = 16 << 20
+)
+
+// ErrFull is returned by Append when the spool has reached its size limit
+var ErrFull = errors.New("spool is full")
+
+// Record is an event waiting to be written to a topic
+type Record struct {
+	Topic string                `json:"topic"`
+	Key   string                `json:"key"`
+	Event models.AnalyticsEvent `json:"event"`
+}
+
+// id identifies a record for deduplication; an event fanned out to several topics spools once per topic
+func (r Record) id() string {
+	return r.Topic + "/" + r.Event.ID
+}
+
+// Spool is a disk-backed FIFO of records, stored as append-only JSON-lines segment files.
+// Appends go to the newest segment; Drain sends the older segments in order and deletes
+// them once every record has been sent.
+type Spool struct {
+	dir      string
+	maxBytes int64
+
+	file    *os.File // Segment receiving appends
+	seq     int64    // Number of the segment receiving appends
+	size    int64    // Bytes across all segments
+	pending map[string]bool
+	mu      sync.Mutex
+
+	drainMu sync.Mutex // Only one drain runs at a time
+}
+
+// Open opens the spool in dir, creating it if needed, and recovers records left by a previous run.
+// maxBytes limits the total size of spooled records; 0 means no limit.
+func Open(dir string, maxBytes int64) (*Spool, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create spool directory: %w", err)
+	}
+
+	s := &Spool{
+		dir:      dir,
+		maxBytes: maxBytes,
+		pending:  make(map[string]bool),
+	}
+
+	segments, err := s.segments()
+	if err != nil {
+		return nil, err
+	}
+	for _, seq := range segments {
+		records, size, err := s.readSegment(seq)
+		if err != nil {
+			return nil, err
+		}
+		s.seq = seq
+		if size == 0 {
+			// Each run starts a segment, which stays empty if Kafka never failed
+			os.Remove(s.segmentPath(seq))
+			continue
+		}
+		for _, record := range records {
+			s.pending[record.id()] = true
+		}
+		s.size += size
+	}
+
+	if err := s.rotate(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Append adds a record to the end of the spool and syncs it to disk. Records already
+// waiting in the spool are skipped, so a retried event is not sent twice.
+func (s *Spool) Append(record Record) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal spool record: %w", err)
+	}
+	data = append(data, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.pending[record.id()] {
+		return nil
+	}
+	if s.maxBytes > 0 && s.size+int64(len(data)) > s.maxBytes {
+		return ErrFull
+	}
+
+	if _, err := s.file.Write(data); err != nil {
+		return fmt.Errorf("failed to write spool record: %w", err)
+	}
+	if err := s.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync spool: %w", err)
+	}
+
+	s.pending[record.id()] = true
+	s.size += int64(len(data))
+	return nil
+}
+
+// Pending returns the number of records waiting to be sent
+func (s *Spool) Pending() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.pending)
+}
+
+// Size returns the bytes used by spooled records
+func (s *Spool) Size() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.size
+}
+
+// Full reports whether the spool has reached its size limit
+func (s *Spool) Full() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.maxBytes > 0 && s.size >= s.maxBytes
+}
+
+// Drain sends spooled records in the order they were appended, stopping at the first
+// failure. Records appended while draining are left for the next drain. A segment is
+// only rewritten or deleted after its records are sent, so a crash mid-drain can resend
+// records but never loses them.
+func (s *Spool) Drain(ctx context.Context, send func(Record) error) (int, error) {
+	return s.DrainBatches(ctx, 1, func(records []Record) error {
+		return send(records[0])
+	})
+}
+
+// DrainBatches is Drain for receivers that write several records at once: it sends up
+// to batchSize records at a time, each batch from a single segment
+func (s *Spool) DrainBatches(ctx context.Context, batchSize int, send func([]Record) error) (int, error) {
+	batchSize = max(batchSize, 1)
+	s.drainMu.Lock()
+	defer s.drainMu.Unlock()
+
+	// Start a new segment so the ones being drained no longer change
+	s.mu.Lock()
+	if len(s.pending) == 0 {
+		s.mu.Unlock()
+		return 0, nil
+	}
+	current := s.seq
+	err := s.rotate()
+	s.mu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+
+	segments, err := s.segments()
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, seq := range segments {
+		if seq > current {
+			break
+		}
+
+		records, size, err := s.readSegment(seq)
+		if err != nil {
+			return sent, err
+		}
+
+		for i := 0; i < len(records); i += batchSize {
+			if err := ctx.Err(); err != nil {
+				return sent, s.keep(seq, records[:i], records[i:], size)
+			}
+			end := min(i+batchSize, len(records))
+			if err := send(records[i:end]); err != nil {
+				return sent, errors.Join(err, s.keep(seq, records[:i], records[i:], size))
+			}
+			sent += end - i
+		}
+
+		if err := os.Remove(s.segmentPath(seq)); err != nil {
+			return sent, fmt.Errorf("failed to remove spool segment: %w", err)
+		}
+		s.forget(records, size)
+	}
+	return sent, nil
+}
+
+// Remove deletes the spooled records matching drop, e.g. the events of an erased user,
+// and returns how many it deleted
+func (s *Spool) Remove(drop func(Record) bool) (int, error) {
+	s.drainMu.Lock()
+	defer s.drainMu.Unlock()
+
+	s.mu.Lock()
+	if len(s.pending) == 0 {
+		s.mu.Unlock()
+		return 0, nil
+	}
+	current := s.seq
+	err := s.rotate()
+	s.mu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+
+	segments, err := s.segments()
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, seq := range segments {
+		if seq > current {
+			break
+		}
+
+		records, size, err := s.readSegment(seq)
+		if err != nil {
+			return removed, err
+		}
+		var kept, dropped []Record
+		for _, record := range records {
+			if drop(record) {
+				dropped = append(dropped, record)
+			} else {
+				kept = append(kept, record)
+			}
+		}
+		if len(dropped) == 0 {
+			continue
+		}
+		if err := s.keep(seq, dropped, kept, size); err != nil {
+			return removed, err
+		}
+		removed += len(dropped)
+	}
+	return removed, nil
+}
+
+// keep rewrites a partially drained segment with its unsent records
+func (s *Spool) keep(seq int64, sent, remaining []Record, size int64) error {
+	path := s.segmentPath(seq)
+	tmp := path + ".tmp"
+
+	var buf []byte
+	for _, record := range remaining {
+		data, err := json.Marshal(record)
+		if err != nil {
+			return fmt.Errorf("failed to marshal spool record: %w", err)
+		}
+		buf = append(append(buf, data...), '\n')
+	}
+	if err := os.WriteFile(tmp, buf, 0o644); err != nil {
+		return fmt.Errorf("failed to rewrite spool segment: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to commit spool segment: %w", err)
+	}
+
+	s.forget(sent, size-int64(len(buf)))
+	return nil
+}
+
+// forget removes sent records from the pending set
+func (s *Spool) forget(records []Record, size int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, record := range records {
+		delete(s.pending, record.id())
+	}
+	s.size -= size
+}
+
+// rotate closes the current segment and starts the next one; the caller must hold s.mu
+func (s *Spool) rotate() error {
+	if s.file != nil {
+		if err := s.file.Close(); err != nil {
+			return fmt.Errorf("failed to close spool segment: %w", err)
+		}
+	}
+
+	s.seq++
+	file, err := os.OpenFile(s.segmentPath(s.seq), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open spool segment: %w", err)
+	}
+	s.file = file
+	return nil
+}
+
+// segments lists segment numbers in order
+func (s *Spool) segments() ([]int64, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list spool: %w", err)
+	}
+
+	var segments []int64
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), segmentSuffix)
+		if !ok {
+			continue
+		}
+		if seq, err := strconv.ParseInt(name, 10, 64); err == nil {
+			segments = append(segments, seq)
+		}
+	}
+	sort.Slice(segments, func(i, j int) bool { return segments[i] < segments[j] })
+	return segments, nil
+}
+
+// readSegment decodes a segment's records and returns its size in bytes.
+// A torn final line from a crash during append is ignored.
+func (s *Spool) readSegment(seq int64) ([]Record, int64, error) {
+	file, err := os.Open(s.segmentPath(seq))
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open spool segment: %w", err)
+	}
+	defer file.Close()
+
+	var (
+		records []Record
+		size    int64
+	)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), maxRecordBytes)
+	for scanner.Scan() {
+		size += int64(len(scanner.Bytes())) + 1
+
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			continue
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to read spool segment: %w", err)
+	}
+	return records, size, nil
+}
+
+// segmentPath returns the file path of a segment; zero-padding keeps names in order
+func (s *Spool) segmentPath(seq int64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%020d%s", seq, segmentSuffix))
+}
+
+// Close closes the segment receiving appends; spooled records stay on disk for the next Open
+func (s *Spool) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
@@ -0,0 +1,89 @@
+package spool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+func record(id string) Record {
+	return Record{Topic: "events", Key: id, Event: models.AnalyticsEvent{ID: id, Type: models.PageView}}
+}
+
+func TestSpoolDrainInOrder(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(dir, 0)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	for i := 0; i < 5; i++ {
+		if err := s.Append(record(fmt.Sprintf("evt-%d", i))); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+	}
+	// Retried events are not spooled twice
+	s.Append(record("evt-0"))
+	if s.Pending() != 5 {
+		t.Fatalf("Expected 5 pending records, got %d", s.Pending())
+	}
+
+	// The first drain fails part way through and keeps the rest
+	var sent []string
+	failAfter := 2
+	_, err = s.Drain(context.Background(), func(r Record) error {
+		if len(sent) == failAfter {
+			return errors.New("broker unavailable")
+		}
+		sent = append(sent, r.Event.ID)
+		return nil
+	})
+	if err == nil || s.Pending() != 3 {
+		t.Fatalf("Expected a failed drain with 3 pending records, got %d (%v)", s.Pending(), err)
+	}
+
+	// Records survive a restart and are sent in their original order
+	s.Close()
+	s, err = Open(dir, 0)
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer s.Close()
+	s.Append(record("evt-5"))
+
+	failAfter = -1
+	n, err := s.Drain(context.Background(), func(r Record) error {
+		sent = append(sent, r.Event.ID)
+		return nil
+	})
+	if err != nil || n != 4 {
+		t.Fatalf("Expected 4 records drained, got %d (%v)", n, err)
+	}
+	for i, id := range sent {
+		if want := fmt.Sprintf("evt-%d", i); id != want {
+			t.Fatalf("Record %d: got %s, want %s", i, id, want)
+		}
+	}
+	if s.Pending() != 0 || s.Size() != 0 {
+		t.Errorf("Expected an empty spool, got %d records, %d bytes", s.Pending(), s.Size())
+	}
+}
+
+func TestSpoolFull(t *testing.T) {
+	s, err := Open(t.TempDir(), 200)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer s.Close()
+
+	var appendErr error
+	for i := 0; i < 10 && appendErr == nil; i++ {
+		appendErr = s.Append(record(fmt.Sprintf("evt-%d", i)))
+	}
+	if !errors.Is(appendErr, ErrFull) {
+		t.Errorf("Expected ErrFull, got %v", appendErr)
+	}
+}
@@ -0,0 +1,87 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+// alertConfigFile is the file alert configurations are kept in, relative to the store directory
+const alertConfigFile = "alerts.json"
+
+// AlertConfigStore persists alert configurations managed at runtime
+type AlertConfigStore interface {
+	// LoadAlertConfigs returns the saved configurations; ok is false if none have been saved yet
+	LoadAlertConfigs(ctx context.Context) (configs []models.AlertConfig, ok bool, err error)
+
+	// SaveAlertConfigs replaces the saved configurations
+	SaveAlertConfigs(ctx context.Context, configs []models.AlertConfig) error
+}
+
+// LoadAlertConfigs reads the alert configurations file
+func (f *FileStore) LoadAlertConfigs(_ context.Context) ([]models.AlertConfig, bool, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	data, err := os.ReadFile(filepath.Join(f.dir, alertConfigFile))
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read alert configs: %w", err)
+	}
+
+	var configs []models.AlertConfig
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return nil, false, fmt.Errorf("failed to decode alert configs: %w", err)
+	}
+	return configs, true, nil
+}
+
+// SaveAlertConfigs writes the alert configurations file atomically
+func (f *FileStore) SaveAlertConfigs(_ context.Context, configs []models.AlertConfig) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if configs == nil {
+		configs = []models.AlertConfig{}
+	}
+	data, err := json.MarshalIndent(configs, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal alert configs: %w", err)
+	}
+
+	path := filepath.Join(f.dir, alertConfigFile)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write alert configs: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to commit alert configs: %w", err)
+	}
+	return nil
+}
+
+// LoadAlertConfigs returns the configurations saved in memory
+func (m *MemoryStore) LoadAlertConfigs(_ context.Context) ([]models.AlertConfig, bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.alertConfigs == nil {
+		return nil, false, nil
+	}
+	return append([]models.AlertConfig(nil), m.alertConfigs...), true, nil
+}
+
+// SaveAlertConfigs replaces the configurations saved in memory
+func (m *MemoryStore) SaveAlertConfigs(_ context.Context, configs []models.AlertConfig) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.alertConfigs = append([]models.AlertConfig{}, configs...)
+	return nil
+}
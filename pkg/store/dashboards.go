@@ -0,0 +1,82 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+// dashboardFile is the file dashboards are kept in, relative to the store directory
+const dashboardFile = "dashboards.json"
+
+// DashboardStore persists user-defined dashboards
+type DashboardStore interface {
+	// LoadDashboards returns the saved dashboards, or none if nothing has been saved yet
+	LoadDashboards(ctx context.Context) ([]models.Dashboard, error)
+
+	// SaveDashboards replaces the saved dashboards
+	SaveDashboards(ctx context.Context, dashboards []models.Dashboard) error
+}
+
+// LoadDashboards reads the dashboards file
+func (f *FileStore) LoadDashboards(_ context.Context) ([]models.Dashboard, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	data, err := os.ReadFile(filepath.Join(f.dir, dashboardFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dashboards: %w", err)
+	}
+
+	var dashboards []models.Dashboard
+	if err := json.Unmarshal(data, &dashboards); err != nil {
+		return nil, fmt.Errorf("failed to decode dashboards: %w", err)
+	}
+	return dashboards, nil
+}
+
+// SaveDashboards writes the dashboards file atomically
+func (f *FileStore) SaveDashboards(_ context.Context, dashboards []models.Dashboard) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if dashboards == nil {
+		dashboards = []models.Dashboard{}
+	}
+	data, err := json.MarshalIndent(dashboards, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal dashboards: %w", err)
+	}
+
+	path := filepath.Join(f.dir, dashboardFile)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write dashboards: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to commit dashboards: %w", err)
+	}
+	return nil
+}
+
+// LoadDashboards returns the dashboards saved in memory
+func (m *MemoryStore) LoadDashboards(_ context.Context) ([]models.Dashboard, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]models.Dashboard(nil), m.dashboards...), nil
+}
+
+// SaveDashboards replaces the dashboards saved in memory
+func (m *MemoryStore) SaveDashboards(_ context.Context, dashboards []models.Dashboard) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.dashboards = append([]models.Dashboard{}, dashboards...)
+	return nil
+}
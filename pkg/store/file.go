@@ -0,0 +1,155 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+// fileTimeLayout names rollup files so that lexical order matches time order
+const fileTimeLayout = "20060102T15"
+
+// FileStore persists rollups as one JSON file per bucket under dir/<granularity>/
+type FileStore struct {
+	dir string
+	mu  sync.RWMutex
+}
+
+// NewFileStore creates a file-backed store rooted at dir, creating it if needed
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create store directory: %w", err)
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// SaveRollups writes each rollup to its own file, replacing existing data atomically
+func (f *FileStore) SaveRollups(ctx context.Context, rollups []models.Rollup) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, rollup := range rollups {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		dir := filepath.Join(f.dir, string(rollup.Granularity))
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("failed to create rollup directory: %w", err)
+		}
+
+		data, err := json.Marshal(rollup)
+		if err != nil {
+			return fmt.Errorf("failed to marshal rollup: %w", err)
+		}
+
+		// Write to a temp file and rename so readers never see partial data
+		path := filepath.Join(dir, rollup.Start.UTC().Format(fileTimeLayout)+".json")
+		tmp := path + ".tmp"
+		if err := os.WriteFile(tmp, data, 0o644); err != nil {
+			return fmt.Errorf("failed to write rollup: %w", err)
+		}
+		if err := os.Rename(tmp, path); err != nil {
+			return fmt.Errorf("failed to commit rollup: %w", err)
+		}
+	}
+	return nil
+}
+
+// QueryRollups reads all rollup files of the granularity within the time range
+func (f *FileStore) QueryRollups(ctx context.Context, granularity models.Granularity, from, to time.Time) ([]models.Rollup, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	entries, err := os.ReadDir(filepath.Join(f.dir, string(granularity)))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list rollups: %w", err)
+	}
+
+	var result []models.Rollup
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		name, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok {
+			continue
+		}
+		start, err := time.Parse(fileTimeLayout, name)
+		if err != nil || start.Before(from.UTC().Truncate(time.Hour)) || !start.Before(to) {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(f.dir, string(granularity), entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read rollup: %w", err)
+		}
+
+		var rollup models.Rollup
+		if err := json.Unmarshal(data, &rollup); err != nil {
+			return nil, fmt.Errorf("failed to decode rollup %s: %w", entry.Name(), err)
+		}
+		if !rollup.Start.Before(from) {
+			result = append(result, rollup)
+		}
+	}
+
+	// ReadDir returns entries sorted by name, which is chronological
+	return result, nil
+}
+
+// DeleteRollups removes the rollup files of the granularity starting before the cutoff
+func (f *FileStore) DeleteRollups(ctx context.Context, granularity models.Granularity, before time.Time) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	dir := filepath.Join(f.dir, string(granularity))
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to list rollups: %w", err)
+	}
+
+	deleted := 0
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return deleted, err
+		}
+
+		name, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok {
+			continue
+		}
+		start, err := time.Parse(fileTimeLayout, name)
+		if err != nil {
+			continue
+		}
+		// Entries are sorted chronologically, so the rest are newer
+		if !start.Before(before) {
+			break
+		}
+		if err := os.Remove(filepath.Join(dir, entry.Name())); err != nil {
+			return deleted, fmt.Errorf("failed to delete rollup: %w", err)
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// Close is a no-op for the file store
+func (f *FileStore) Close() error {
+	return nil
+}
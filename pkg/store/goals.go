@@ -0,0 +1,86 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+// goalFile is the file conversion goals are kept in, relative to the store directory
+const goalFile = "goals.json"
+
+// GoalStore persists conversion goals managed at runtime
+type GoalStore interface {
+	// LoadGoals returns the saved goals; ok is false if none have been saved yet
+	LoadGoals(ctx context.Context) (goals []models.ConversionGoal, ok bool, err error)
+
+	// SaveGoals replaces the saved goals
+	SaveGoals(ctx context.Context, goals []models.ConversionGoal) error
+}
+
+// LoadGoals reads the goals file
+func (f *FileStore) LoadGoals(_ context.Context) ([]models.ConversionGoal, bool, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	data, err := os.ReadFile(filepath.Join(f.dir, goalFile))
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read goals: %w", err)
+	}
+
+	var goals []models.ConversionGoal
+	if err := json.Unmarshal(data, &goals); err != nil {
+		return nil, false, fmt.Errorf("failed to decode goals: %w", err)
+	}
+	return goals, true, nil
+}
+
+// SaveGoals writes the goals file atomically
+func (f *FileStore) SaveGoals(_ context.Context, goals []models.ConversionGoal) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if goals == nil {
+		goals = []models.ConversionGoal{}
+	}
+	data, err := json.MarshalIndent(goals, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal goals: %w", err)
+	}
+
+	path := filepath.Join(f.dir, goalFile)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write goals: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to commit goals: %w", err)
+	}
+	return nil
+}
+
+// LoadGoals returns the goals saved in memory
+func (m *MemoryStore) LoadGoals(_ context.Context) ([]models.ConversionGoal, bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.goals == nil {
+		return nil, false, nil
+	}
+	return append([]models.ConversionGoal(nil), m.goals...), true, nil
+}
+
+// SaveGoals replaces the goals saved in memory
+func (m *MemoryStore) SaveGoals(_ context.Context, goals []models.ConversionGoal) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.goals = append([]models.ConversionGoal{}, goals...)
+	return nil
+}
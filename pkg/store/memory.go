@@ -0,0 +1,79 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+// MemoryStore is a non-persistent Store, useful for tests and ephemeral deployments
+type MemoryStore struct {
+	rollups      map[models.Granularity]map[int64]models.Rollup
+	alertConfigs []models.AlertConfig // nil until saved
+	dashboards   []models.Dashboard
+	goals        []models.ConversionGoal // nil until saved
+	recentAlerts []models.Alert
+	mu           sync.RWMutex
+}
+
+// NewMemoryStore creates a new in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		rollups: make(map[models.Granularity]map[int64]models.Rollup),
+	}
+}
+
+// SaveRollups stores rollups, replacing any with the same granularity and start time
+func (m *MemoryStore) SaveRollups(_ context.Context, rollups []models.Rollup) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, rollup := range rollups {
+		if m.rollups[rollup.Granularity] == nil {
+			m.rollups[rollup.Granularity] = make(map[int64]models.Rollup)
+		}
+		m.rollups[rollup.Granularity][rollup.Start.Unix()] = rollup
+	}
+	return nil
+}
+
+// QueryRollups returns stored rollups within the time range
+func (m *MemoryStore) QueryRollups(_ context.Context, granularity models.Granularity, from, to time.Time) ([]models.Rollup, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var result []models.Rollup
+	for _, rollup := range m.rollups[granularity] {
+		if !rollup.Start.Before(from) && rollup.Start.Before(to) {
+			result = append(result, rollup)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Start.Before(result[j].Start)
+	})
+	return result, nil
+}
+
+// DeleteRollups removes stored rollups starting before the cutoff
+func (m *MemoryStore) DeleteRollups(_ context.Context, granularity models.Granularity, before time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	deleted := 0
+	for start, rollup := range m.rollups[granularity] {
+		if rollup.Start.Before(before) {
+			delete(m.rollups[granularity], start)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// Close is a no-op for the memory store
+func (m *MemoryStore) Close() error {
+	return nil
+}
@@ -0,0 +1,82 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+// recentAlertsFile is the file recent alerts are kept in, relative to the store directory
+const recentAlertsFile = "recent_alerts.json"
+
+// AlertLogStore persists recently fired alerts so they survive restarts
+type AlertLogStore interface {
+	// LoadRecentAlerts returns the saved alerts, oldest first; none if nothing was saved
+	LoadRecentAlerts(ctx context.Context) ([]models.Alert, error)
+
+	// SaveRecentAlerts replaces the saved alerts
+	SaveRecentAlerts(ctx context.Context, alerts []models.Alert) error
+}
+
+// LoadRecentAlerts reads the recent alerts file
+func (f *FileStore) LoadRecentAlerts(_ context.Context) ([]models.Alert, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	data, err := os.ReadFile(filepath.Join(f.dir, recentAlertsFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read recent alerts: %w", err)
+	}
+
+	var alerts []models.Alert
+	if err := json.Unmarshal(data, &alerts); err != nil {
+		return nil, fmt.Errorf("failed to decode recent alerts: %w", err)
+	}
+	return alerts, nil
+}
+
+// SaveRecentAlerts writes the recent alerts file atomically
+func (f *FileStore) SaveRecentAlerts(_ context.Context, alerts []models.Alert) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if alerts == nil {
+		alerts = []models.Alert{}
+	}
+	data, err := json.MarshalIndent(alerts, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal recent alerts: %w", err)
+	}
+
+	path := filepath.Join(f.dir, recentAlertsFile)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write recent alerts: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to commit recent alerts: %w", err)
+	}
+	return nil
+}
+
+// LoadRecentAlerts returns the alerts saved in memory
+func (m *MemoryStore) LoadRecentAlerts(_ context.Context) ([]models.Alert, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]models.Alert(nil), m.recentAlerts...), nil
+}
+
+// SaveRecentAlerts replaces the alerts saved in memory
+func (m *MemoryStore) SaveRecentAlerts(_ context.Context, alerts []models.Alert) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.recentAlerts = append([]models.Alert(nil), alerts...)
+	return nil
+}
@@ -0,0 +1,24 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+// Store persists aggregated analytics beyond the in-memory retention window
+type Store interface {
+	// SaveRollups inserts or replaces rollups keyed by granularity and start time
+	SaveRollups(ctx context.Context, rollups []models.Rollup) error
+
+	// QueryRollups returns rollups of the given granularity starting in [from, to), ordered by start time
+	QueryRollups(ctx context.Context, granularity models.Granularity, from, to time.Time) ([]models.Rollup, error)
+
+	// DeleteRollups removes rollups of the given granularity starting before the cutoff
+	// and returns how many were removed
+	DeleteRollups(ctx context.Context, granularity models.Granularity, before time.Time) (int, error)
+
+	// Close releases any resources held by the store
+	Close() error
+}
@@ -0,0 +1,291 @@
+// Package streamjoin correlates events of a session within a time window and
+// publishes the matches as derived conversion path events
+package streamjoin
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/analytics"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+// DefaultMaxPending is how many unmatched events each rule keeps per session
+const DefaultMaxPending = 100
+
+// Rule joins an event matching From with a later event of the same session matching
+// To, at most Window apart
+type Rule struct {
+	Name   string
+	From   models.Goal
+	To     models.Goal
+	Window time.Duration
+}
+
+// ParseRules parses join rules separated by ";", each as
+// "name=from>to@window" where from and to are "event_type[:path_prefix]" and window
+// is a duration, e.g. "click_to_purchase=click>purchase@30m;pricing=click:/pricing>page_view@1m"
+func ParseRules(definition string) ([]Rule, error) {
+	var rules []Rule
+	names := make(map[string]bool)
+	for _, part := range strings.Split(definition, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		name, spec, ok := strings.Cut(part, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("join rule %q: expected name=from>to@window", part)
+		}
+		if names[name] {
+			return nil, fmt.Errorf("join rule %q is defined twice", name)
+		}
+		events, window, ok := strings.Cut(spec, "@")
+		if !ok {
+			return nil, fmt.Errorf("join rule %q: missing @window", name)
+		}
+		from, to, ok := strings.Cut(events, ">")
+		if !ok {
+			return nil, fmt.Errorf("join rule %q: expected from>to", name)
+		}
+
+		rule := Rule{Name: name, From: analytics.ParseGoal(from), To: analytics.ParseGoal(to)}
+		for _, goal := range []models.Goal{rule.From, rule.To} {
+			if !goal.EventType.Valid() {
+				return nil, fmt.Errorf("join rule %q: invalid event type %q", name, goal.EventType)
+			}
+		}
+		duration, err := time.ParseDuration(strings.TrimSpace(window))
+		if err != nil || duration <= 0 {
+			return nil, fmt.Errorf("join rule %q: invalid window %q", name, window)
+		}
+		rule.Window = duration
+
+		names[name] = true
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// Publisher writes derived events; kafka.Producer implements it
+type Publisher interface {
+	SendEvent(ctx context.Context, key string, value interface{}) error
+}
+
+// Stats reports join counters
+type Stats struct {
+	Joined  int64 `json:"joined"`  // Derived events published
+	Expired int64 `json:"expired"` // From events dropped unmatched after their window
+	Pending int   `json:"pending"` // From events waiting for a match
+	Errors  int64 `json:"errors"`  // Derived events that failed to publish
+}
+
+// pendingKey identifies the unmatched events of one rule in one session
+type pendingKey struct {
+	rule    int
+	session string
+}
+
+// Joiner matches events against the join rules. Matching uses event timestamps, so
+// events may arrive out of order within the window. A To event joins the most recent
+// unmatched From event before it, which is then consumed, so each From event appears
+// in at most one derived event per rule.
+type Joiner struct {
+	rules      []Rule
+	publisher  Publisher
+	maxPending int
+
+	mu      sync.Mutex
+	pending map[pendingKey][]*models.AnalyticsEvent
+	latest  time.Time // Newest event timestamp seen, but never later than now; the clock pending events expire by
+	stats   Stats
+}
+
+// NewJoiner creates a joiner publishing derived events to publisher
+func NewJoiner(rules []Rule, publisher Publisher) *Joiner {
+	return &Joiner{
+		rules:      rules,
+		publisher:  publisher,
+		maxPending: DefaultMaxPending,
+		pending:    make(map[pendingKey][]*models.AnalyticsEvent),
+	}
+}
+
+// SetMaxPending sets how many unmatched events each rule keeps per session; the
+// oldest are dropped beyond it
+func (j *Joiner) SetMaxPending(n int) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if n > 0 {
+		j.maxPending = n
+	}
+}
+
+// Process matches an event against the rules and publishes any derived events. It
+// implements pipeline.Processor and never drops the event.
+func (j *Joiner) Process(ctx context.Context, event *models.AnalyticsEvent) (bool, error) {
+	if event.SessionID == "" || event.Type == models.ConversionPath {
+		return true, nil
+	}
+
+	derived := j.match(event)
+
+	var firstErr error
+	for _, d := range derived {
+		if err := j.publisher.SendEvent(ctx, d.SessionID, d); err != nil {
+			j.mu.Lock()
+			j.stats.Errors++
+			j.mu.Unlock()
+			if firstErr == nil {
+				firstErr = fmt.Errorf("failed to publish %s event: %w", models.ConversionPath, err)
+			}
+		}
+	}
+	return true, firstErr
+}
+
+// match records the event and returns the derived events it completes
+func (j *Joiner) match(event *models.AnalyticsEvent) []*models.AnalyticsEvent {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	// A client clock running ahead must not expire every pending event
+	latest := event.Timestamp
+	if now := time.Now(); latest.After(now) {
+		latest = now
+	}
+	if latest.After(j.latest) {
+		j.latest = latest
+	}
+
+	var derived []*models.AnalyticsEvent
+	for i, rule := range j.rules {
+		key := pendingKey{rule: i, session: event.SessionID}
+
+		// Match before recording, so an event matching both sides never joins itself
+		if rule.To.Matches(event) {
+			if from := j.take(key, event, rule.Window); from != nil {
+				derived = append(derived, newConversionPath(rule, from, event))
+				j.stats.Joined++
+			}
+		}
+		if rule.From.Matches(event) {
+			queue := append(j.pending[key], event)
+			if len(queue) > j.maxPending {
+				j.stats.Expired += int64(len(queue) - j.maxPending)
+				queue = queue[len(queue)-j.maxPending:]
+			}
+			j.pending[key] = queue
+		}
+	}
+	return derived
+}
+
+// take removes and returns the most recent pending event at most window before to
+func (j *Joiner) take(key pendingKey, to *models.AnalyticsEvent, window time.Duration) *models.AnalyticsEvent {
+	queue := j.pending[key]
+	best := -1
+	for i, from := range queue {
+		if from.ID == to.ID || from.Timestamp.After(to.Timestamp) || to.Timestamp.Sub(from.Timestamp) > window {
+			continue
+		}
+		if best < 0 || !from.Timestamp.Before(queue[best].Timestamp) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return nil
+	}
+
+	from := queue[best]
+	queue = append(queue[:best], queue[best+1:]...)
+	if len(queue) == 0 {
+		delete(j.pending, key)
+	} else {
+		j.pending[key] = queue
+	}
+	return from
+}
+
+// Expire drops pending events that can no longer be matched because they are older
+// than their rule's window, measured from the newest event seen
+func (j *Joiner) Expire() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	for key, queue := range j.pending {
+		cutoff := j.latest.Add(-j.rules[key.rule].Window)
+		kept := queue[:0]
+		for _, from := range queue {
+			if from.Timestamp.Before(cutoff) {
+				j.stats.Expired++
+				continue
+			}
+			kept = append(kept, from)
+		}
+		if len(kept) == 0 {
+			delete(j.pending, key)
+		} else {
+			j.pending[key] = kept
+		}
+	}
+}
+
+// Run expires pending events every interval until the context is cancelled
+func (j *Joiner) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			j.Expire()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Stats returns the current counters
+func (j *Joiner) Stats() Stats {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	stats := j.stats
+	for _, queue := range j.pending {
+		stats.Pending += len(queue)
+	}
+	return stats
+}
+
+// newConversionPath builds the derived event for a match. Its ID is derived from the
+// rule and both event IDs, so reprocessing the same events yields the same ID and
+// downstream deduplication drops the repeat.
+func newConversionPath(rule Rule, from, to *models.AnalyticsEvent) *models.AnalyticsEvent {
+	return &models.AnalyticsEvent{
+		ID:        uuid.NewSHA1(uuid.NameSpaceOID, []byte(rule.Name+"/"+from.ID+"/"+to.ID)).String(),
+		Type:      models.ConversionPath,
+		Timestamp: to.Timestamp,
+		UserID:    to.UserID,
+		SessionID: to.SessionID,
+		URL:       to.URL,
+		Path:      to.Path,
+		Referrer:  from.URL,
+		Metadata: map[string]interface{}{
+			"rule":          rule.Name,
+			"from_event_id": from.ID,
+			"from_type":     string(from.Type),
+			"from_path":     from.Path,
+			"to_event_id":   to.ID,
+			"to_type":       string(to.Type),
+			"to_path":       to.Path,
+			"elapsed_ms":    to.Timestamp.Sub(from.Timestamp).Milliseconds(),
+		},
+	}
+}
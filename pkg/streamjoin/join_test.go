@@ -0,0 +1,146 @@
+package streamjoin
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+type recordingPublisher struct {
+	events []*models.AnalyticsEvent
+	err    error
+}
+
+func (p *recordingPublisher) SendEvent(ctx context.Context, key string, value interface{}) error {
+	if p.err != nil {
+		return p.err
+	}
+	p.events = append(p.events, value.(*models.AnalyticsEvent))
+	return nil
+}
+
+func event(id string, eventType models.EventType, path string, at time.Time) *models.AnalyticsEvent {
+	return &models.AnalyticsEvent{
+		ID: id, Type: eventType, Timestamp: at, UserID: "u1", SessionID: "s1",
+		URL: "https://example.com" + path, Path: path,
+	}
+}
+
+func TestParseRules(t *testing.T) {
+	rules, err := ParseRules("click_to_purchase=click>purchase@30m; pricing=page_view:/pricing>signup@1h")
+	if err != nil {
+		t.Fatalf("ParseRules failed: %v", err)
+	}
+	if len(rules) != 2 || rules[0].Window != 30*time.Minute || rules[1].From.PathPrefix != "/pricing" || rules[1].To.EventType != "signup" {
+		t.Errorf("unexpected rules %+v", rules)
+	}
+
+	for _, definition := range []string{
+		"click>purchase@30m",
+		"a=click>purchase",
+		"a=click@30m",
+		"a=click>purchase@soon",
+		"a=click>purchase@-1m",
+		"a=Click!>purchase@1m",
+		"a=click>purchase@1m;a=click>page_view@1m",
+	} {
+		if _, err := ParseRules(definition); err == nil {
+			t.Errorf("expected %q to be rejected", definition)
+		}
+	}
+}
+
+func TestJoinerMatchesWithinWindow(t *testing.T) {
+	rules, _ := ParseRules("click_to_purchase=click>purchase@10m")
+	publisher := &recordingPublisher{}
+	joiner := NewJoiner(rules, publisher)
+	ctx := context.Background()
+	now := time.Now()
+
+	joiner.Process(ctx, event("c1", models.Click, "/a", now))
+	joiner.Process(ctx, event("c2", models.Click, "/b", now.Add(time.Minute)))
+	// Arrives out of order, but happened after the purchase and must not join it
+	joiner.Process(ctx, event("c3", models.Click, "/c", now.Add(5*time.Minute)))
+	joiner.Process(ctx, event("p1", models.Purchase, "/checkout", now.Add(2*time.Minute)))
+
+	if len(publisher.events) != 1 {
+		t.Fatalf("expected 1 conversion path, got %d", len(publisher.events))
+	}
+	derived := publisher.events[0]
+	if derived.Type != models.ConversionPath || derived.Metadata["from_event_id"] != "c2" || derived.Metadata["to_event_id"] != "p1" {
+		t.Errorf("expected the latest earlier click to join, got %+v", derived.Metadata)
+	}
+	if derived.Metadata["elapsed_ms"] != int64(time.Minute/time.Millisecond) || derived.SessionID != "s1" {
+		t.Errorf("unexpected derived event %+v", derived)
+	}
+
+	// c2 is used up; c1 is next, while a purchase beyond the window joins nothing
+	joiner.Process(ctx, event("p2", models.Purchase, "/checkout", now.Add(3*time.Minute)))
+	joiner.Process(ctx, event("p3", models.Purchase, "/checkout", now.Add(30*time.Minute)))
+	if len(publisher.events) != 2 || publisher.events[1].Metadata["from_event_id"] != "c1" {
+		t.Errorf("expected c1 to join p2 only, got %d events", len(publisher.events))
+	}
+
+	first := newConversionPath(rules[0], event("c1", models.Click, "/a", now), event("p2", models.Purchase, "/", now))
+	if publisher.events[1].ID != first.ID {
+		t.Error("expected derived IDs to be deterministic")
+	}
+}
+
+func TestJoinerSeparatesSessionsAndExpires(t *testing.T) {
+	rules, _ := ParseRules("view_to_click=page_view>click@1m")
+	publisher := &recordingPublisher{}
+	joiner := NewJoiner(rules, publisher)
+	ctx := context.Background()
+	now := time.Now().Add(-time.Hour)
+
+	joiner.Process(ctx, event("v1", models.PageView, "/", now))
+	other := event("k1", models.Click, "/", now.Add(time.Second))
+	other.SessionID = "s2"
+	joiner.Process(ctx, other)
+	if len(publisher.events) != 0 {
+		t.Fatal("expected no join across sessions")
+	}
+
+	joiner.Process(ctx, event("v2", models.PageView, "/later", now.Add(5*time.Minute)))
+	joiner.Expire()
+	stats := joiner.Stats()
+	if stats.Pending != 1 || stats.Expired != 1 {
+		t.Errorf("expected v1 to expire and v2 to stay pending, got %+v", stats)
+	}
+}
+
+func TestJoinerClampsFutureTimestamps(t *testing.T) {
+	rules, _ := ParseRules("view_to_click=page_view>click@10m")
+	publisher := &recordingPublisher{}
+	joiner := NewJoiner(rules, publisher)
+	ctx := context.Background()
+	now := time.Now()
+
+	joiner.Process(ctx, event("v1", models.PageView, "/", now.Add(-time.Minute)))
+	// An event from a clock a day ahead must not expire v1
+	joiner.Process(ctx, event("v2", models.PageView, "/", now.Add(24*time.Hour)))
+	joiner.Expire()
+	if stats := joiner.Stats(); stats.Expired != 0 || stats.Pending != 2 {
+		t.Errorf("expected both views pending, got %+v", stats)
+	}
+}
+
+func TestJoinerReportsPublishErrors(t *testing.T) {
+	rules, _ := ParseRules("view_to_click=page_view>click@1m")
+	joiner := NewJoiner(rules, &recordingPublisher{err: errors.New("broker down")})
+	ctx := context.Background()
+	now := time.Now()
+
+	joiner.Process(ctx, event("v1", models.PageView, "/", now))
+	keep, err := joiner.Process(ctx, event("k1", models.Click, "/", now.Add(time.Second)))
+	if !keep || err == nil {
+		t.Errorf("expected the event kept and the error returned, got %v, %v", keep, err)
+	}
+	if joiner.Stats().Errors != 1 {
+		t.Errorf("expected 1 publish error, got %+v", joiner.Stats())
+	}
+}
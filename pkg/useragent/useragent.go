@@ -0,0 +1,81 @@
+package useragent
+
+import (
+	"strings"
+
+	"github.com/mileusna/useragent"
+)
+
+// Device types reported by parsers
+const (
+	DeviceDesktop = "Desktop"
+	DeviceMobile  = "Mobile"
+	DeviceTablet  = "Tablet"
+	DeviceBot     = "Bot"
+	DeviceOther   = "Other"
+)
+
+// Info is the parsed form of a user agent string
+type Info struct {
+	Browser        string
+	BrowserVersion string // Major version only, to keep aggregation cardinality low
+	OS             string
+	OSVersion      string // Major version only
+	Device         string
+	Bot            bool
+}
+
+// Parser extracts browser, OS and device details from user agent strings
+type Parser interface {
+	Parse(userAgent string) Info
+}
+
+// defaultParser is a Parser backed by github.com/mileusna/useragent
+type defaultParser struct{}
+
+// NewParser returns the default user agent parser
+func NewParser() Parser {
+	return defaultParser{}
+}
+
+// Parse parses a user agent string, reporting unknown fields as "Other"
+func (defaultParser) Parse(userAgent string) Info {
+	ua := useragent.Parse(userAgent)
+
+	info := Info{
+		Browser:        orOther(ua.Name),
+		BrowserVersion: majorVersion(ua.Version),
+		OS:             orOther(ua.OS),
+		OSVersion:      majorVersion(ua.OSVersion),
+		Bot:            ua.Bot,
+	}
+
+	switch {
+	case ua.Bot:
+		info.Device = DeviceBot
+	case ua.Tablet:
+		info.Device = DeviceTablet
+	case ua.Mobile:
+		info.Device = DeviceMobile
+	case ua.Desktop:
+		info.Device = DeviceDesktop
+	default:
+		info.Device = DeviceOther
+	}
+
+	return info
+}
+
+// orOther returns value, or "Other" when it is empty
+func orOther(value string) string {
+	if value == "" {
+		return "Other"
+	}
+	return value
+}
+
+// majorVersion returns the leading component of a dotted version string
+func majorVersion(version string) string {
+	major, _, _ := strings.Cut(version, ".")
+	return major
+}
@@ -0,0 +1,37 @@
+package useragent
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	parser := NewParser()
+
+	tests := []struct {
+		name      string
+		userAgent string
+		want      Info
+	}{
+		{
+			name:      "Edge on Windows",
+			userAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 Edg/120.0.2210.91",
+			want:      Info{Browser: "Edge", BrowserVersion: "120", OS: "Windows", OSVersion: "10", Device: DeviceDesktop},
+		},
+		{
+			name:      "Chrome on iOS",
+			userAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) CriOS/120.0.6099.119 Mobile/15E148 Safari/604.1",
+			want:      Info{Browser: "Chrome", BrowserVersion: "120", OS: "iOS", OSVersion: "17", Device: DeviceMobile},
+		},
+		{
+			name:      "Googlebot",
+			userAgent: "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			want:      Info{Browser: "Googlebot", BrowserVersion: "2", OS: "Other", Device: DeviceBot, Bot: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parser.Parse(tt.userAgent); got != tt.want {
+				t.Errorf("Parse() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
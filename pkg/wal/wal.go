@@ -0,0 +1,506 @@
+// Package wal is an append-only log of the events a consumer has counted, so its
+// in-memory analytics can be rebuilt after a crash or restart instead of starting empty.
+// Records are stored as JSON-lines segment files, like the producer's spool; old
+// segments are deleted once they fall out of the retention window. Erased users are
+// recorded in a file of their own and skipped on replay until Prune has rewritten the
+// segments without their records.
+package wal
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/logging"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+const (
+	segmentSuffix = ".jsonl"
+
+	// File listing the users erased since segments were last scrubbed, as JSON strings
+	erasuresFile = "erased-users.log"
+
+	// DefaultSegmentBytes is the size past which appends start a new segment
+	DefaultSegmentBytes = 64 << 20
+
+	// Largest record accepted when reading segments back
+	maxRecordBytes = 16 << 20
+)
+
+// Record is a counted event and the message it was consumed from
+type Record struct {
+	Topic     string                `json:"topic,omitempty"`
+	Partition int                   `json:"partition"`
+	Offset    int64                 `json:"offset"`
+	Logged    time.Time             `json:"logged"`
+	Event     models.AnalyticsEvent `json:"event"`
+}
+
+// Options configures a log
+type Options struct {
+	SegmentBytes int64         // Appends start a new segment past this size; 0 uses DefaultSegmentBytes
+	Retention    time.Duration // Segments last written longer ago are deleted by Prune; 0 keeps them
+}
+
+// Log is a write-ahead log of counted events. Appends are written to the newest segment
+// straight away, so they survive a crash of the process; Sync flushes them to disk so
+// they survive a crash of the machine too. It is safe for concurrent use.
+type Log struct {
+	dir     string
+	options Options
+
+	file *os.File // Segment receiving appends
+	seq  int64    // Number of the segment receiving appends
+	size int64    // Bytes in the segment receiving appends
+
+	// Users erased since segments were last scrubbed, and the file recording them
+	erased   map[string]bool
+	erasures *os.File
+	mu       sync.Mutex
+
+	pruneMu sync.Mutex // Serializes Prune, which rewrites segments without holding mu
+}
+
+// Open opens the log in dir, creating it if needed. Records of previous runs are kept
+// for Replay; appends go to a new segment.
+func Open(dir string, options Options) (*Log, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create WAL directory: %w", err)
+	}
+	if options.SegmentBytes <= 0 {
+		options.SegmentBytes = DefaultSegmentBytes
+	}
+
+	l := &Log{dir: dir, options: options}
+	if err := l.openErasures(); err != nil {
+		return nil, err
+	}
+	segments, err := l.segments()
+	if err != nil {
+		return nil, err
+	}
+	for _, seq := range segments {
+		l.seq = seq
+		// Each run starts a segment, which stays empty if nothing was counted
+		if info, err := os.Stat(l.segmentPath(seq)); err == nil && info.Size() == 0 {
+			os.Remove(l.segmentPath(seq))
+		}
+	}
+	if err := l.rotate(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Append adds a record to the end of the log, stamping it with the time it was logged
+func (l *Log) Append(record Record) error {
+	if record.Logged.IsZero() {
+		record.Logged = time.Now()
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal WAL record: %w", err)
+	}
+	data = append(data, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.size > 0 && l.size+int64(len(data)) > l.options.SegmentBytes {
+		if err := l.rotate(); err != nil {
+			return err
+		}
+	}
+	if _, err := l.file.Write(data); err != nil {
+		return fmt.Errorf("failed to write WAL record: %w", err)
+	}
+	l.size += int64(len(data))
+	return nil
+}
+
+// Replay passes the records logged since the given time to fn in the order they were
+// appended, skipping those of erased users and stopping at the first error. Call it
+// before appending, as records appended while replaying may or may not be included.
+func (l *Log) Replay(ctx context.Context, since time.Time, fn func(Record) error) (int, error) {
+	segments, err := l.segments()
+	if err != nil {
+		return 0, err
+	}
+	erased := l.erasedUsers()
+
+	replayed := 0
+	for _, seq := range segments {
+		records, err := l.readSegment(seq)
+		if err != nil {
+			return replayed, err
+		}
+		for _, record := range records {
+			if err := ctx.Err(); err != nil {
+				return replayed, err
+			}
+			if record.Logged.Before(since) || erased[record.Event.UserID] {
+				continue
+			}
+			if err := fn(record); err != nil {
+				return replayed, err
+			}
+			replayed++
+		}
+	}
+	return replayed, nil
+}
+
+// DeleteUser erases a user's records, for right-to-erasure requests. The user is
+// recorded durably and skipped by Replay straight away; the records themselves are
+// removed from the segments by the next Prune, so erasing never rewrites segments in
+// the caller's path.
+func (l *Log) DeleteUser(userID string) error {
+	data, err := json.Marshal(userID)
+	if err != nil {
+		return fmt.Errorf("failed to marshal erased user: %w", err)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.erased[userID] {
+		return nil
+	}
+	if _, err := l.erasures.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to record erased user: %w", err)
+	}
+	if err := l.erasures.Sync(); err != nil {
+		return fmt.Errorf("failed to sync erased users: %w", err)
+	}
+	l.erased[userID] = true
+	return nil
+}
+
+// erasedUsers returns a copy of the users erased since segments were last scrubbed
+func (l *Log) erasedUsers() map[string]bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	erased := make(map[string]bool, len(l.erased))
+	for userID := range l.erased {
+		erased[userID] = true
+	}
+	return erased
+}
+
+// Prune deletes segments last written before the retention window, other than the one
+// receiving appends, and returns how many were deleted. It then rewrites the remaining
+// segments without the records of erased users and forgets those users.
+func (l *Log) Prune(now time.Time) (int, error) {
+	l.pruneMu.Lock()
+	defer l.pruneMu.Unlock()
+
+	pruned, err := l.deleteExpired(now)
+	if err != nil {
+		return pruned, err
+	}
+	return pruned, l.scrub()
+}
+
+// deleteExpired deletes the segments last written before the retention window
+func (l *Log) deleteExpired(now time.Time) (int, error) {
+	if l.options.Retention <= 0 {
+		return 0, nil
+	}
+	cutoff := now.Add(-l.options.Retention)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	segments, err := l.segments()
+	if err != nil {
+		return 0, err
+	}
+	pruned := 0
+	for _, seq := range segments {
+		if seq == l.seq {
+			continue
+		}
+		info, err := os.Stat(l.segmentPath(seq))
+		if err != nil || !info.ModTime().Before(cutoff) {
+			continue
+		}
+		if err := os.Remove(l.segmentPath(seq)); err != nil && !os.IsNotExist(err) {
+			return pruned, fmt.Errorf("failed to remove WAL segment: %w", err)
+		}
+		pruned++
+	}
+	return pruned, nil
+}
+
+// scrub rewrites the segments holding records of erased users without them. Appends
+// move to a new segment first, so the rewritten ones are no longer written to and
+// appends aren't held up while they are rewritten.
+func (l *Log) scrub() error {
+	l.mu.Lock()
+	if len(l.erased) == 0 {
+		l.mu.Unlock()
+		return nil
+	}
+	if err := l.rotate(); err != nil {
+		l.mu.Unlock()
+		return err
+	}
+	current := l.seq
+	l.mu.Unlock()
+	erased := l.erasedUsers()
+
+	segments, err := l.segments()
+	if err != nil {
+		return err
+	}
+	for _, seq := range segments {
+		if seq >= current {
+			continue
+		}
+		records, err := l.readSegment(seq)
+		if err != nil {
+			return err
+		}
+		kept := records[:0]
+		for _, record := range records {
+			if !erased[record.Event.UserID] {
+				kept = append(kept, record)
+			}
+		}
+		if len(kept) == len(records) {
+			continue
+		}
+		if err := l.rewrite(seq, kept); err != nil {
+			return err
+		}
+	}
+
+	// Users erased while scrubbing stay recorded for the next run
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for userID := range erased {
+		delete(l.erased, userID)
+	}
+	return l.writeErasures()
+}
+
+// rewrite replaces a segment with the given records, syncing the new file before it
+// takes the old one's place
+func (l *Log) rewrite(seq int64, records []Record) error {
+	path := l.segmentPath(seq)
+	tmp := path + ".tmp"
+
+	var buf []byte
+	for _, record := range records {
+		data, err := json.Marshal(record)
+		if err != nil {
+			return fmt.Errorf("failed to marshal WAL record: %w", err)
+		}
+		buf = append(append(buf, data...), '\n')
+	}
+	if err := writeSynced(tmp, buf); err != nil {
+		return fmt.Errorf("failed to rewrite WAL segment: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to commit WAL segment: %w", err)
+	}
+	return nil
+}
+
+// writeSynced writes a file and flushes it to disk
+func writeSynced(path string, data []byte) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// openErasures loads the erased users recorded by previous runs and opens their file
+// for appends. A torn final line from a crash is ignored.
+func (l *Log) openErasures() error {
+	l.erased = make(map[string]bool)
+	path := filepath.Join(l.dir, erasuresFile)
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read erased users: %w", err)
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		var userID string
+		if json.Unmarshal([]byte(line), &userID) == nil {
+			l.erased[userID] = true
+		}
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open erased users: %w", err)
+	}
+	l.erasures = file
+	return nil
+}
+
+// writeErasures replaces the erased users file with the users still to be scrubbed;
+// the caller must hold l.mu
+func (l *Log) writeErasures() error {
+	var buf []byte
+	for userID := range l.erased {
+		data, err := json.Marshal(userID)
+		if err != nil {
+			return fmt.Errorf("failed to marshal erased user: %w", err)
+		}
+		buf = append(append(buf, data...), '\n')
+	}
+
+	path := filepath.Join(l.dir, erasuresFile)
+	if err := writeSynced(path+".tmp", buf); err != nil {
+		return fmt.Errorf("failed to rewrite erased users: %w", err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return fmt.Errorf("failed to commit erased users: %w", err)
+	}
+	l.erasures.Close()
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open erased users: %w", err)
+	}
+	l.erasures = file
+	return nil
+}
+
+// Sync flushes appended records to disk
+func (l *Log) Sync() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync WAL: %w", err)
+	}
+	return nil
+}
+
+// Run syncs the log every interval and prunes expired segments, until ctx is cancelled
+func (l *Log) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			if err := l.Sync(); err != nil {
+				logging.Warn("Failed to sync write-ahead log", "error", err)
+			}
+			if pruned, err := l.Prune(now); err != nil {
+				logging.Warn("Failed to prune write-ahead log", "error", err)
+			} else if pruned > 0 {
+				logging.Debug("Pruned write-ahead log", "segments", pruned)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// rotate closes the current segment and starts the next one; the caller must hold l.mu
+// or own the log
+func (l *Log) rotate() error {
+	if l.file != nil {
+		if err := l.file.Sync(); err != nil {
+			return fmt.Errorf("failed to sync WAL segment: %w", err)
+		}
+		if err := l.file.Close(); err != nil {
+			return fmt.Errorf("failed to close WAL segment: %w", err)
+		}
+	}
+
+	l.seq++
+	file, err := os.OpenFile(l.segmentPath(l.seq), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open WAL segment: %w", err)
+	}
+	l.file, l.size = file, 0
+	return nil
+}
+
+// segments lists segment numbers in order
+func (l *Log) segments() ([]int64, error) {
+	entries, err := os.ReadDir(l.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list WAL: %w", err)
+	}
+
+	var segments []int64
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), segmentSuffix)
+		if !ok {
+			continue
+		}
+		if seq, err := strconv.ParseInt(name, 10, 64); err == nil {
+			segments = append(segments, seq)
+		}
+	}
+	sort.Slice(segments, func(i, j int) bool { return segments[i] < segments[j] })
+	return segments, nil
+}
+
+// readSegment decodes a segment's records. A torn final line from a crash during
+// append is ignored.
+func (l *Log) readSegment(seq int64) ([]Record, error) {
+	file, err := os.Open(l.segmentPath(seq))
+	if os.IsNotExist(err) {
+		return nil, nil // Pruned since it was listed
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open WAL segment: %w", err)
+	}
+	defer file.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), maxRecordBytes)
+	for scanner.Scan() {
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			continue
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read WAL segment: %w", err)
+	}
+	return records, nil
+}
+
+// segmentPath returns the file path of a segment; zero-padding keeps names in order
+func (l *Log) segmentPath(seq int64) string {
+	return filepath.Join(l.dir, fmt.Sprintf("%020d%s", seq, segmentSuffix))
+}
+
+// Close syncs and closes the segment receiving appends; records stay on disk for the
+// next Open
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.erasures.Close()
+	if err := l.file.Sync(); err != nil {
+		l.file.Close()
+		return fmt.Errorf("failed to sync WAL: %w", err)
+	}
+	return l.file.Close()
+}
@@ -0,0 +1,129 @@
+package wal
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+func record(id, userID string, offset int64) Record {
+	return Record{Topic: "analytics-events", Offset: offset, Event: models.AnalyticsEvent{ID: id, Type: models.PageView, UserID: userID}}
+}
+
+func replayIDs(t *testing.T, l *Log, since time.Time) []string {
+	t.Helper()
+	var ids []string
+	if _, err := l.Replay(context.Background(), since, func(r Record) error {
+		ids = append(ids, r.Event.ID)
+		return nil
+	}); err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	return ids
+}
+
+func TestReplayAfterReopen(t *testing.T) {
+	dir := t.TempDir()
+	l, err := Open(dir, Options{SegmentBytes: 200})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	old := record("e0", "u1", 0)
+	old.Logged = time.Now().Add(-2 * time.Hour)
+	for _, r := range []Record{old, record("e1", "u1", 1), record("e2", "u2", 2), record("e3", "u1", 3)} {
+		if err := l.Append(r); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+	}
+	l.Close()
+
+	// A line torn by a crash is skipped
+	segments, _ := l.segments()
+	file, _ := os.OpenFile(l.segmentPath(segments[len(segments)-1]), os.O_APPEND|os.O_WRONLY, 0o644)
+	file.WriteString(`{"topic":"analytics-events","offs`)
+	file.Close()
+
+	l, err = Open(dir, Options{SegmentBytes: 200})
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer l.Close()
+	if ids := replayIDs(t, l, time.Now().Add(-time.Hour)); len(ids) != 3 || ids[0] != "e1" || ids[2] != "e3" {
+		t.Fatalf("expected e1 to e3 in order, got %v", ids)
+	}
+	if len(segments) < 2 {
+		t.Errorf("expected appends past SegmentBytes to start new segments, got %d", len(segments))
+	}
+
+	if err := l.DeleteUser("u1"); err != nil {
+		t.Fatalf("DeleteUser failed: %v", err)
+	}
+	l.Append(record("e4", "u3", 4))
+	if ids := replayIDs(t, l, time.Time{}); len(ids) != 2 || ids[0] != "e2" || ids[1] != "e4" {
+		t.Errorf("expected e2 and e4 after the erasure, got %v", ids)
+	}
+}
+
+func TestErasureSurvivesReopenAndIsScrubbed(t *testing.T) {
+	dir := t.TempDir()
+	l, err := Open(dir, Options{})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	l.Append(record("e1", "u1", 1))
+	l.Append(record("e2", "u2", 2))
+	if err := l.DeleteUser("u1"); err != nil {
+		t.Fatalf("DeleteUser failed: %v", err)
+	}
+	l.Close()
+
+	l, err = Open(dir, Options{})
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer l.Close()
+	if ids := replayIDs(t, l, time.Time{}); len(ids) != 1 || ids[0] != "e2" {
+		t.Fatalf("expected the erasure to outlive a restart, got %v", ids)
+	}
+
+	if _, err := l.Prune(time.Now()); err != nil {
+		t.Fatalf("Prune failed: %v", err)
+	}
+	segments, _ := l.segments()
+	for _, seq := range segments {
+		data, _ := os.ReadFile(l.segmentPath(seq))
+		if strings.Contains(string(data), `"u1"`) {
+			t.Errorf("expected u1's records removed from segment %d, got %s", seq, data)
+		}
+	}
+	if erased := l.erasedUsers(); len(erased) != 0 {
+		t.Errorf("expected scrubbed users forgotten, got %v", erased)
+	}
+	if ids := replayIDs(t, l, time.Time{}); len(ids) != 1 || ids[0] != "e2" {
+		t.Errorf("expected e2 to remain, got %v", ids)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	l, err := Open(t.TempDir(), Options{SegmentBytes: 1, Retention: time.Hour})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer l.Close()
+	l.Append(record("e1", "u1", 1))
+	l.Append(record("e2", "u1", 2)) // Starts a new segment
+
+	if pruned, _ := l.Prune(time.Now()); pruned != 0 {
+		t.Errorf("expected recent segments to be kept, pruned %d", pruned)
+	}
+	if pruned, _ := l.Prune(time.Now().Add(2 * time.Hour)); pruned != 1 {
+		t.Errorf("expected the full segment to be pruned but not the current one, pruned %d", pruned)
+	}
+	if ids := replayIDs(t, l, time.Time{}); len(ids) != 1 || ids[0] != "e2" {
+		t.Errorf("expected only e2 to remain, got %v", ids)
+	}
+}
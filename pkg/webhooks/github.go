@@ -0,0 +1,81 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+// GitHub receives GitHub webhooks as custom events named github.<event>, e.g.
+// github.push or github.pull_request, attributed to the sender's login
+type GitHub struct {
+	secret []byte
+}
+
+// NewGitHub creates a GitHub adapter verifying deliveries with the webhook's secret
+func NewGitHub(secret string) *GitHub {
+	return &GitHub{secret: []byte(secret)}
+}
+
+// Name is the provider segment of the endpoint
+func (g *GitHub) Name() string {
+	return "github"
+}
+
+// Verify checks the X-Hub-Signature-256 header, an HMAC of the body
+func (g *GitHub) Verify(header http.Header, body []byte) error {
+	return verifySHA256(g.secret, header.Get("X-Hub-Signature-256"), body)
+}
+
+// githubPayload holds the fields read from every GitHub payload
+type githubPayload struct {
+	Action string `json:"action"`
+	Ref    string `json:"ref"`
+	Sender struct {
+		Login string `json:"login"`
+	} `json:"sender"`
+	Repository struct {
+		FullName string `json:"full_name"`
+		HTMLURL  string `json:"html_url"`
+	} `json:"repository"`
+}
+
+// Translate maps a delivery to one event. Pings, sent when a webhook is created, are
+// acknowledged without an event.
+func (g *GitHub) Translate(header http.Header, body []byte) ([]models.AnalyticsEvent, error) {
+	name := strings.ToLower(header.Get("X-GitHub-Event"))
+	if name == "" {
+		return nil, fmt.Errorf("missing X-GitHub-Event header")
+	}
+	if name == "ping" {
+		return nil, nil
+	}
+	var payload githubPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("invalid GitHub payload: %w", err)
+	}
+
+	metadata := make(map[string]interface{}, 4)
+	for key, value := range map[string]string{
+		models.MetadataAction: payload.Action,
+		"repository":          payload.Repository.FullName,
+		"ref":                 payload.Ref,
+	} {
+		if value != "" {
+			metadata[key] = value
+		}
+	}
+	event := models.AnalyticsEvent{
+		Type:     models.EventType("github." + name),
+		UserID:   payload.Sender.Login,
+		URL:      payload.Repository.HTMLURL,
+		Metadata: withProvider(g.Name(), metadata),
+	}
+	if delivery := header.Get("X-GitHub-Delivery"); delivery != "" {
+		event.ID = "github:" + delivery
+	}
+	return []models.AnalyticsEvent{event}, nil
+}
@@ -0,0 +1,166 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+// StripeTolerance is how old a Stripe signature may be, as in Stripe's own libraries
+const StripeTolerance = 5 * time.Minute
+
+// stripeZeroDecimal lists the currencies Stripe amounts are not in hundredths of
+var stripeZeroDecimal = map[string]bool{
+	"bif": true, "clp": true, "djf": true, "gnf": true, "jpy": true, "kmf": true,
+	"krw": true, "mga": true, "pyg": true, "rwf": true, "ugx": true, "vnd": true,
+	"vuv": true, "xaf": true, "xof": true, "xpf": true,
+}
+
+// Stripe receives Stripe webhooks. Succeeded payment intents become purchase events,
+// so payments made through Checkout, Payment Links or the API are all counted once;
+// other events become custom events named stripe.<type>.
+type Stripe struct {
+	secret []byte
+	now    func() time.Time
+}
+
+// NewStripe creates a Stripe adapter verifying deliveries with the endpoint's signing
+// secret (whsec_...)
+func NewStripe(secret string) *Stripe {
+	return &Stripe{secret: []byte(secret), now: time.Now}
+}
+
+// Name is the provider segment of the endpoint
+func (s *Stripe) Name() string {
+	return "stripe"
+}
+
+// Verify checks the Stripe-Signature header: a timestamp and one or more v1 HMACs of
+// the timestamp and body. Signatures older than StripeTolerance are rejected, so a
+// captured delivery can't be replayed later.
+func (s *Stripe) Verify(header http.Header, body []byte) error {
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(header.Get("Stripe-Signature"), ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || len(signatures) == 0 {
+		return fmt.Errorf("%w: malformed Stripe-Signature header", ErrInvalidSignature)
+	}
+	if age := s.now().Sub(time.Unix(seconds, 0)); age > StripeTolerance || age < -StripeTolerance {
+		return fmt.Errorf("%w: timestamp outside the tolerance", ErrInvalidSignature)
+	}
+	expected := sign(s.secret, []byte(timestamp), []byte("."), body)
+	for _, signature := range signatures {
+		if hmac.Equal([]byte(signature), []byte(expected)) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+// stripeEvent is the envelope of a Stripe webhook
+type stripeEvent struct {
+	ID      string `json:"id"`
+	Type    string `json:"type"`
+	Created int64  `json:"created"`
+	Data    struct {
+		Object json.RawMessage `json:"object"`
+	} `json:"data"`
+}
+
+// stripeObject holds the fields read from the event's object
+type stripeObject struct {
+	ID                string            `json:"id"`
+	Amount            int64             `json:"amount"`
+	AmountReceived    int64             `json:"amount_received"`
+	AmountTotal       int64             `json:"amount_total"`
+	Currency          string            `json:"currency"`
+	Customer          string            `json:"customer"`
+	ClientReferenceID string            `json:"client_reference_id"`
+	Metadata          map[string]string `json:"metadata"`
+}
+
+// Translate maps a Stripe event to one analytics event
+func (s *Stripe) Translate(header http.Header, body []byte) ([]models.AnalyticsEvent, error) {
+	var envelope stripeEvent
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return nil, fmt.Errorf("invalid Stripe event: %w", err)
+	}
+	if envelope.ID == "" || envelope.Type == "" {
+		return nil, fmt.Errorf("invalid Stripe event: no id or type")
+	}
+	var object stripeObject
+	if len(envelope.Data.Object) > 0 {
+		if err := json.Unmarshal(envelope.Data.Object, &object); err != nil {
+			return nil, fmt.Errorf("invalid Stripe object: %w", err)
+		}
+	}
+
+	// Merchants pass their own IDs through Stripe metadata
+	metadata := make(map[string]interface{}, len(object.Metadata)+6)
+	for key, value := range object.Metadata {
+		metadata[key] = value
+	}
+	userID := object.Metadata["user_id"]
+	if userID == "" {
+		userID = object.ClientReferenceID
+	}
+	if userID == "" {
+		userID = object.Customer
+	}
+	if object.Customer != "" {
+		metadata["stripe_customer"] = object.Customer
+	}
+	if object.ID != "" {
+		metadata["stripe_object"] = object.ID
+	}
+
+	event := models.AnalyticsEvent{
+		ID:       "stripe:" + envelope.ID,
+		Type:     models.EventType("stripe." + strings.ToLower(envelope.Type)),
+		UserID:   userID,
+		Metadata: withProvider(s.Name(), metadata),
+	}
+	if envelope.Created > 0 {
+		event.Timestamp = time.Unix(envelope.Created, 0).UTC()
+	}
+
+	if envelope.Type == "payment_intent.succeeded" {
+		event.Type = models.Purchase
+		if _, ok := metadata[models.MetadataOrderID]; !ok {
+			metadata[models.MetadataOrderID] = object.ID
+		}
+		metadata[models.MetadataRevenue] = stripeAmount(object.AmountReceived, object.Currency)
+		if object.Currency != "" {
+			metadata[models.MetadataCurrency] = strings.ToUpper(object.Currency)
+		}
+	} else if amount := max(object.AmountTotal, object.Amount); amount > 0 && object.Currency != "" {
+		// Other objects with an amount, e.g. refunds or invoices, keep it for reference
+		metadata["amount"] = stripeAmount(amount, object.Currency)
+		metadata[models.MetadataCurrency] = strings.ToUpper(object.Currency)
+	}
+	return []models.AnalyticsEvent{event}, nil
+}
+
+// stripeAmount converts an amount in the currency's smallest unit to a decimal amount
+func stripeAmount(amount int64, currency string) float64 {
+	if stripeZeroDecimal[strings.ToLower(currency)] {
+		return float64(amount)
+	}
+	return float64(amount) / 100
+}
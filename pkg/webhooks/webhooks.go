@@ -0,0 +1,65 @@
+// Package webhooks translates webhooks sent by other services, such as Stripe payments
+// or GitHub activity, into analytics events. Each service is an Adapter that checks the
+// signature the service sends with every delivery and maps its payload to events; new
+// sources are added by implementing Adapter.
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+// MetadataProvider names the service a webhook event came from
+const MetadataProvider = "webhook_provider"
+
+// ErrInvalidSignature is returned by Verify for deliveries that are unsigned, signed
+// with another secret or, for services that timestamp their signatures, too old
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// Adapter receives the webhooks of one service
+type Adapter interface {
+	// Name is the provider segment of the endpoint, as in /webhooks/stripe
+	Name() string
+
+	// Verify checks that the delivery was signed by the service, returning an error
+	// wrapping ErrInvalidSignature if not
+	Verify(header http.Header, body []byte) error
+
+	// Translate maps a verified delivery to events. Deliveries with nothing to record,
+	// such as pings, return no events. Event IDs are derived from the service's delivery
+	// IDs, so redeliveries are deduplicated by the consumers.
+	Translate(header http.Header, body []byte) ([]models.AnalyticsEvent, error)
+}
+
+// sign returns the hex HMAC-SHA256 of message with secret
+func sign(secret []byte, message ...[]byte) string {
+	mac := hmac.New(sha256.New, secret)
+	for _, part := range message {
+		mac.Write(part)
+	}
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// verifySHA256 checks a "sha256=<hex>" signature of body
+func verifySHA256(secret []byte, signature string, body []byte) error {
+	digest, ok := strings.CutPrefix(signature, "sha256=")
+	if !ok || !hmac.Equal([]byte(digest), []byte(sign(secret, body))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// withProvider returns metadata tagged with the provider's name
+func withProvider(name string, metadata map[string]interface{}) map[string]interface{} {
+	if metadata == nil {
+		metadata = make(map[string]interface{})
+	}
+	metadata[MetadataProvider] = name
+	return metadata
+}
@@ -0,0 +1,118 @@
+package webhooks
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+const paymentIntent = `{"id":"evt_1","type":"payment_intent.succeeded","created":1704110400,"data":{"object":{
+	"id":"pi_1","object":"payment_intent","amount_received":4250,"currency":"eur","customer":"cus_9","metadata":{"user_id":"user-1"}}}}`
+
+func stripeHeader(secret string, at time.Time, body string) http.Header {
+	timestamp := fmt.Sprint(at.Unix())
+	signature := sign([]byte(secret), []byte(timestamp), []byte("."), []byte(body))
+	return http.Header{"Stripe-Signature": {fmt.Sprintf("t=%s,v1=0000,v1=%s", timestamp, signature)}}
+}
+
+func TestStripeVerify(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	stripe := NewStripe("whsec_test")
+	stripe.now = func() time.Time { return now }
+
+	if err := stripe.Verify(stripeHeader("whsec_test", now.Add(-time.Minute), paymentIntent), []byte(paymentIntent)); err != nil {
+		t.Errorf("expected a valid signature, got %v", err)
+	}
+	for name, header := range map[string]http.Header{
+		"other secret": stripeHeader("whsec_other", now, paymentIntent),
+		"too old":      stripeHeader("whsec_test", now.Add(-10*time.Minute), paymentIntent),
+		"other body":   stripeHeader("whsec_test", now, paymentIntent+" "),
+		"unsigned":     {},
+	} {
+		if err := stripe.Verify(header, []byte(paymentIntent)); !errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("%s: expected an invalid signature, got %v", name, err)
+		}
+	}
+}
+
+func TestStripeTranslate(t *testing.T) {
+	stripe := NewStripe("whsec_test")
+	events, err := stripe.Translate(nil, []byte(paymentIntent))
+	if err != nil || len(events) != 1 {
+		t.Fatalf("expected one event, got %d, %v", len(events), err)
+	}
+	purchase := events[0]
+	if purchase.ID != "stripe:evt_1" || purchase.Type != models.Purchase || purchase.UserID != "user-1" || purchase.Timestamp.Unix() != 1704110400 {
+		t.Errorf("unexpected purchase %+v", purchase)
+	}
+	order, err := models.OrderFromEvent(&purchase, "USD")
+	if err != nil || order.OrderID != "pi_1" || order.Revenue != 42.5 || order.Currency != "EUR" {
+		t.Errorf("unexpected order %+v, %v", order, err)
+	}
+	if purchase.Metadata[MetadataProvider] != "stripe" || purchase.Metadata["stripe_customer"] != "cus_9" {
+		t.Errorf("unexpected metadata %v", purchase.Metadata)
+	}
+
+	events, _ = stripe.Translate(nil, []byte(`{"id":"evt_2","type":"charge.refunded","data":{"object":{"id":"ch_1","amount":1000,"currency":"jpy","customer":"cus_9"}}}`))
+	if refund := events[0]; refund.Type != "stripe.charge.refunded" || refund.UserID != "cus_9" || refund.Metadata["amount"] != 1000.0 || !refund.Timestamp.IsZero() {
+		t.Errorf("unexpected refund %+v", refund)
+	}
+	if _, err := stripe.Translate(nil, []byte(`{"type":"charge.refunded"}`)); err == nil {
+		t.Error("expected an event without an ID to be rejected")
+	}
+}
+
+func TestGitHub(t *testing.T) {
+	github := NewGitHub("secret")
+	body := `{"action":"opened","sender":{"login":"octocat"},"repository":{"full_name":"acme/site","html_url":"https://github.com/acme/site"}}`
+	header := http.Header{
+		"X-Hub-Signature-256": {"sha256=" + sign([]byte("secret"), []byte(body))},
+		"X-Github-Event":      {"pull_request"},
+		"X-Github-Delivery":   {"72d3162e"},
+	}
+	if err := github.Verify(header, []byte(body)); err != nil {
+		t.Fatalf("expected a valid signature, got %v", err)
+	}
+	if err := github.Verify(header, []byte(body+" ")); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("expected a changed body to fail verification, got %v", err)
+	}
+
+	events, err := github.Translate(header, []byte(body))
+	if err != nil || len(events) != 1 {
+		t.Fatalf("expected one event, got %d, %v", len(events), err)
+	}
+	event := events[0]
+	if event.ID != "github:72d3162e" || event.Type != "github.pull_request" || event.UserID != "octocat" ||
+		event.URL != "https://github.com/acme/site" || event.Metadata["action"] != "opened" || event.Metadata["repository"] != "acme/site" {
+		t.Errorf("unexpected event %+v", event)
+	}
+
+	header.Set("X-GitHub-Event", "ping")
+	if events, err := github.Translate(header, []byte(body)); err != nil || len(events) != 0 {
+		t.Errorf("expected pings to translate to no events, got %v, %v", events, err)
+	}
+}
+
+func TestGeneric(t *testing.T) {
+	generic := NewGeneric("secret")
+	body := `[{"type":"signup","user_id":"u1"},{"type":"page_view","metadata":{"plan":"pro"}}]`
+	header := http.Header{"X-Webhook-Signature": {"sha256=" + sign([]byte("secret"), []byte(body))}}
+	if err := generic.Verify(header, []byte(body)); err != nil {
+		t.Fatalf("expected a valid signature, got %v", err)
+	}
+	if err := generic.Verify(http.Header{"X-Webhook-Signature": {sign([]byte("secret"), []byte(body))}}, []byte(body)); err == nil {
+		t.Error("expected a signature without the sha256= prefix to be rejected")
+	}
+
+	events, err := generic.Translate(header, []byte(body))
+	if err != nil || len(events) != 2 || events[0].UserID != "u1" || events[1].Metadata["plan"] != "pro" || events[1].Metadata[MetadataProvider] != "generic" {
+		t.Errorf("unexpected events %+v, %v", events, err)
+	}
+	if events, err := generic.Translate(header, []byte(`{"type":"signup"}`)); err != nil || len(events) != 1 {
+		t.Errorf("expected a single event, got %v, %v", events, err)
+	}
+}
@@ -0,0 +1,234 @@
+package websocket
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// AccessLevel is what an authenticated client may receive and do
+type AccessLevel int
+
+const (
+	// AccessReadOnly clients receive aggregate dashboard data: snapshots without the recent
+	// events, alerts, experiment results and goal completions without user or session IDs
+	AccessReadOnly AccessLevel = iota + 1
+
+	// AccessAdmin clients additionally receive the real-time event stream and the other
+	// data carrying user IDs and URLs, and may use admin requests such as listing
+	// connected clients
+	AccessAdmin
+)
+
+// String returns the level name used in tokens and replies
+func (l AccessLevel) String() string {
+	switch l {
+	case AccessAdmin:
+		return "admin"
+	case AccessReadOnly:
+		return "read"
+	default:
+		return "none"
+	}
+}
+
+// adminMessageTypes are only sent to admin clients
+var adminMessageTypes = map[string]bool{
+	"real_time_event":     true,
+	"real_time_throttled": true,
+}
+
+// allows reports whether a client at this level may receive messages of the given type
+func (l AccessLevel) allows(messageType string) bool {
+	return l >= AccessAdmin || !adminMessageTypes[messageType]
+}
+
+// personalSnapshotFields are the snapshot fields that carry user IDs and URLs; read-only
+// clients receive snapshots and deltas without them
+var personalSnapshotFields = []string{"real_time_events"}
+
+// redactSnapshot returns a copy of a snapshot document, or of a delta patch, without
+// the personal fields
+func redactSnapshot(document map[string]interface{}) map[string]interface{} {
+	redacted := make(map[string]interface{}, len(document))
+	for key, value := range document {
+		redacted[key] = value
+	}
+	for _, field := range personalSnapshotFields {
+		delete(redacted, field)
+	}
+	return redacted
+}
+
+// parseAccessLevel parses a JWT role claim; anything other than "admin" is read-only
+func parseAccessLevel(role string) AccessLevel {
+	if role == "admin" {
+		return AccessAdmin
+	}
+	return AccessReadOnly
+}
+
+var (
+	errMissingToken = errors.New("missing token")
+	errInvalidToken = errors.New("invalid token")
+)
+
+// AuthConfig controls which WebSocket connections are accepted
+type AuthConfig struct {
+	// Origins allowed to connect, e.g. "https://dashboard.example.com"; "*" or empty allows any
+	AllowedOrigins []string
+
+	// Static tokens granting read-only or admin access
+	ReadTokens  []string
+	AdminTokens []string
+
+	// Secret for HS256 JWTs; the "role" claim selects the access level
+	JWTSecret string
+}
+
+// authenticator checks the origin and credentials of WebSocket handshakes
+type authenticator struct {
+	origins     map[string]bool
+	anyOrigin   bool
+	readTokens  []string
+	adminTokens []string
+	jwtSecret   []byte
+}
+
+// newAuthenticator compiles an auth configuration
+func newAuthenticator(config AuthConfig) *authenticator {
+	a := &authenticator{
+		origins:     make(map[string]bool),
+		anyOrigin:   len(config.AllowedOrigins) == 0,
+		readTokens:  config.ReadTokens,
+		adminTokens: config.AdminTokens,
+		jwtSecret:   []byte(config.JWTSecret),
+	}
+	for _, origin := range config.AllowedOrigins {
+		if origin == "*" {
+			a.anyOrigin = true
+			continue
+		}
+		a.origins[strings.ToLower(strings.TrimSuffix(origin, "/"))] = true
+	}
+	return a
+}
+
+// enabled reports whether clients must present a token
+func (a *authenticator) enabled() bool {
+	return len(a.readTokens) > 0 || len(a.adminTokens) > 0 || len(a.jwtSecret) > 0
+}
+
+// checkOrigin allows same-origin requests, requests without an Origin header
+// (non-browser clients) and configured origins
+func (a *authenticator) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || a.anyOrigin {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	return a.origins[strings.ToLower(u.Scheme+"://"+u.Host)]
+}
+
+// authorize returns the access level granted by the request's token. Browsers cannot
+// set headers on WebSocket handshakes, so the token may also be passed as ?token=.
+// With authentication disabled every client is an admin.
+func (a *authenticator) authorize(r *http.Request) (AccessLevel, error) {
+	if !a.enabled() {
+		return AccessAdmin, nil
+	}
+
+	token := r.URL.Query().Get("token")
+	if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		token = bearer
+	}
+	if token == "" {
+		return 0, errMissingToken
+	}
+
+	// Compare against every token in constant time; admin wins if listed in both
+	level := AccessLevel(0)
+	for _, t := range a.readTokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+			level = AccessReadOnly
+		}
+	}
+	for _, t := range a.adminTokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+			level = AccessAdmin
+		}
+	}
+	if level != 0 {
+		return level, nil
+	}
+
+	if len(a.jwtSecret) > 0 && strings.Count(token, ".") == 2 {
+		return a.verifyJWT(token, time.Now())
+	}
+	return 0, errInvalidToken
+}
+
+// jwtClaims are the claims read from dashboard tokens
+type jwtClaims struct {
+	Role      string `json:"role"`
+	ExpiresAt int64  `json:"exp"`
+	NotBefore int64  `json:"nbf"`
+}
+
+// verifyJWT validates an HS256 JWT signed with the configured secret and returns
+// the access level from its role claim
+func (a *authenticator) verifyJWT(token string, now time.Time) (AccessLevel, error) {
+	parts := strings.Split(token, ".")
+
+	header, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return 0, errInvalidToken
+	}
+	var h struct {
+		Alg string `json:"alg"`
+	}
+	if err := json.Unmarshal(header, &h); err != nil || h.Alg != "HS256" {
+		return 0, errInvalidToken
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return 0, errInvalidToken
+	}
+	mac := hmac.New(sha256.New, a.jwtSecret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return 0, errInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return 0, errInvalidToken
+	}
+	var claims jwtClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return 0, errInvalidToken
+	}
+	if claims.ExpiresAt != 0 && now.Unix() >= claims.ExpiresAt {
+		return 0, errors.New("token expired")
+	}
+	if claims.NotBefore != 0 && now.Unix() < claims.NotBefore {
+		return 0, errors.New("token not yet valid")
+	}
+
+	return parseAccessLevel(claims.Role), nil
+}
@@ -0,0 +1,97 @@
+package websocket
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// signJWT builds an HS256 token for tests
+func signJWT(secret, payload string) string {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+	body := base64.RawURLEncoding.EncodeToString([]byte(payload))
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(header + "." + body))
+	return header + "." + body + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestAuthenticatorAuthorize(t *testing.T) {
+	auth := newAuthenticator(AuthConfig{
+		ReadTokens:  []string{"viewer"},
+		AdminTokens: []string{"operator"},
+		JWTSecret:   "secret",
+	})
+	future := time.Now().Add(time.Hour).Unix()
+	past := time.Now().Add(-time.Hour).Unix()
+
+	tests := []struct {
+		name    string
+		target  string
+		header  string
+		want    AccessLevel
+		wantErr bool
+	}{
+		{"MissingToken", "/ws", "", 0, true},
+		{"ReadToken", "/ws?token=viewer", "", AccessReadOnly, false},
+		{"AdminHeader", "/ws", "Bearer operator", AccessAdmin, false},
+		{"UnknownToken", "/ws?token=guess", "", 0, true},
+		{"AdminJWT", "/ws?token=" + signJWT("secret", `{"role":"admin","exp":`+strconv.FormatInt(future, 10)+`}`), "", AccessAdmin, false},
+		{"ReadJWT", "/ws?token=" + signJWT("secret", `{"role":"viewer"}`), "", AccessReadOnly, false},
+		{"ExpiredJWT", "/ws?token=" + signJWT("secret", `{"role":"admin","exp":`+strconv.FormatInt(past, 10)+`}`), "", 0, true},
+		{"WrongSecretJWT", "/ws?token=" + signJWT("other", `{"role":"admin"}`), "", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", tt.target, nil)
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+			got, err := auth.authorize(r)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("authorize() = %v, %v; want %v, error %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+
+	if level, err := newAuthenticator(AuthConfig{}).authorize(httptest.NewRequest("GET", "/ws", nil)); err != nil || level != AccessAdmin {
+		t.Errorf("Without configured tokens clients should be admins, got %v, %v", level, err)
+	}
+}
+
+func TestAuthenticatorCheckOrigin(t *testing.T) {
+	auth := newAuthenticator(AuthConfig{AllowedOrigins: []string{"https://dashboard.example.com/"}})
+
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{"", true},
+		{"http://analytics.internal:8080", true}, // Same origin as the request host
+		{"https://dashboard.example.com", true},
+		{"https://evil.example.com", false},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "http://analytics.internal:8080/ws", nil)
+		if tt.origin != "" {
+			r.Header.Set("Origin", tt.origin)
+		}
+		if got := auth.checkOrigin(r); got != tt.want {
+			t.Errorf("checkOrigin(%q) = %v, want %v", tt.origin, got, tt.want)
+		}
+	}
+}
+
+func TestAccessLevelAllows(t *testing.T) {
+	if AccessReadOnly.allows("real_time_event") || !AccessReadOnly.allows("analytics_update") {
+		t.Error("Read-only clients should get aggregates but not the real-time event stream")
+	}
+	if !AccessAdmin.allows("real_time_event") {
+		t.Error("Admins should get the real-time event stream")
+	}
+}
@@ -0,0 +1,124 @@
+package websocket
+
+import (
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+// HubConfig controls how often the hub broadcasts and how much it buffers
+type HubConfig struct {
+	// How often analytics updates, experiment results and heatmaps are broadcast
+	SnapshotInterval time.Duration
+
+	// How often active visitor counts are checked and broadcast if they changed
+	ActiveVisitorsInterval time.Duration
+
+	// How often WebSocket clients are pinged; a client that does not answer within
+	// 10/9 of this period is disconnected
+	PingPeriod time.Duration
+
+	// Maximum real_time_event messages broadcast per second, 0 for no limit. Events over
+	// the limit are counted and reported once a second in a real_time_throttled message.
+	EventRateLimit int
+
+	// Messages buffered between publishers and the hub, and per client
+	BroadcastBufferSize int
+	QueueSize           int
+}
+
+// DefaultHubConfig returns the settings used when none are configured
+func DefaultHubConfig() HubConfig {
+	return HubConfig{
+		SnapshotInterval:       5 * time.Second,
+		ActiveVisitorsInterval: time.Second,
+		PingPeriod:             54 * time.Second,
+		BroadcastBufferSize:    256,
+		QueueSize:              256,
+	}
+}
+
+// withDefaults replaces unset or invalid values with the defaults
+func (c HubConfig) withDefaults() HubConfig {
+	defaults := DefaultHubConfig()
+	if c.SnapshotInterval <= 0 {
+		c.SnapshotInterval = defaults.SnapshotInterval
+	}
+	if c.ActiveVisitorsInterval <= 0 {
+		c.ActiveVisitorsInterval = defaults.ActiveVisitorsInterval
+	}
+	if c.PingPeriod <= 0 {
+		c.PingPeriod = defaults.PingPeriod
+	}
+	if c.EventRateLimit < 0 {
+		c.EventRateLimit = 0
+	}
+	if c.BroadcastBufferSize <= 0 {
+		c.BroadcastBufferSize = defaults.BroadcastBufferSize
+	}
+	if c.QueueSize <= 0 {
+		c.QueueSize = defaults.QueueSize
+	}
+	return c
+}
+
+// pongWait is how long a client may take to answer a ping
+func (c HubConfig) pongWait() time.Duration {
+	return c.PingPeriod * 10 / 9
+}
+
+// ThrottleSummary reports the real-time events dropped by the rate limit in the last window
+type ThrottleSummary struct {
+	Dropped        uint64                      `json:"dropped"`
+	ByType         map[models.EventType]uint64 `json:"by_type"`
+	LimitPerSecond int                         `json:"limit_per_second"`
+}
+
+// eventThrottle limits real-time events to a fixed number per second and counts the
+// rest, so a traffic spike becomes one summary message instead of a flood; only
+// accessed from Run
+type eventThrottle struct {
+	limit       int
+	windowStart time.Time
+	sent        int
+	dropped     map[models.EventType]uint64
+}
+
+// newEventThrottle creates a throttle allowing limit events per second, or nil for no limit
+func newEventThrottle(limit int) *eventThrottle {
+	if limit <= 0 {
+		return nil
+	}
+	return &eventThrottle{limit: limit, dropped: make(map[models.EventType]uint64)}
+}
+
+// allow reports whether the message may be broadcast, counting it as dropped if not.
+// Only real-time events are limited.
+func (t *eventThrottle) allow(message outboundMessage, now time.Time) bool {
+	if t == nil || message.messageType != "real_time_event" {
+		return true
+	}
+	if now.Sub(t.windowStart) >= time.Second {
+		t.windowStart = now
+		t.sent = 0
+	}
+	if t.sent < t.limit {
+		t.sent++
+		return true
+	}
+	t.dropped[message.eventType]++
+	return false
+}
+
+// flush returns and resets the events dropped since the last flush, if any
+func (t *eventThrottle) flush() (ThrottleSummary, bool) {
+	if t == nil || len(t.dropped) == 0 {
+		return ThrottleSummary{}, false
+	}
+	summary := ThrottleSummary{ByType: t.dropped, LimitPerSecond: t.limit}
+	for _, count := range t.dropped {
+		summary.Dropped += count
+	}
+	t.dropped = make(map[models.EventType]uint64)
+	return summary, true
+}
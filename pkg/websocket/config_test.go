@@ -0,0 +1,37 @@
+package websocket
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+func TestEventThrottleLimitsRealTimeEvents(t *testing.T) {
+	throttle := newEventThrottle(2)
+	now := time.Now()
+	click := outboundMessage{messageType: "real_time_event", eventType: models.Click}
+	alert := outboundMessage{messageType: "alert"}
+
+	for i, want := range []bool{true, true, false, false} {
+		if got := throttle.allow(click, now); got != want {
+			t.Errorf("Event %d: allow = %v, want %v", i, got, want)
+		}
+	}
+	if !throttle.allow(alert, now) {
+		t.Error("Only real-time events should be limited")
+	}
+
+	summary, ok := throttle.flush()
+	if !ok || summary.Dropped != 2 || summary.ByType[models.Click] != 2 || summary.LimitPerSecond != 2 {
+		t.Errorf("Unexpected summary %+v", summary)
+	}
+	if _, ok := throttle.flush(); ok {
+		t.Error("Flush should reset the dropped counts")
+	}
+
+	// A new window allows events again
+	if !throttle.allow(click, now.Add(time.Second)) {
+		t.Error("Expected the limit to reset after a second")
+	}
+}
@@ -0,0 +1,59 @@
+package websocket
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+// fullSnapshotEvery is how many analytics broadcasts pass between full snapshots sent to
+// delta clients, bounding how long a client that missed a delta stays out of date
+const fullSnapshotEvery = 12
+
+// snapshotDocument converts a snapshot to its generic JSON form for diffing
+func snapshotDocument(snapshot interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return nil, err
+	}
+	var document map[string]interface{}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+	return document, nil
+}
+
+// mergePatch returns a JSON Merge Patch (RFC 7386) that turns previous into current:
+// objects are diffed field by field, removed fields are set to null and any other
+// changed value, including arrays, is replaced whole
+func mergePatch(previous, current map[string]interface{}) map[string]interface{} {
+	patch := make(map[string]interface{})
+
+	for key, value := range current {
+		old, existed := previous[key]
+		if !existed {
+			patch[key] = value
+			continue
+		}
+
+		oldObject, oldIsObject := old.(map[string]interface{})
+		newObject, newIsObject := value.(map[string]interface{})
+		if oldIsObject && newIsObject {
+			if nested := mergePatch(oldObject, newObject); len(nested) > 0 {
+				patch[key] = nested
+			}
+			continue
+		}
+
+		if !reflect.DeepEqual(old, value) {
+			patch[key] = value
+		}
+	}
+
+	for key := range previous {
+		if _, ok := current[key]; !ok {
+			patch[key] = nil
+		}
+	}
+
+	return patch
+}
@@ -0,0 +1,37 @@
+package websocket
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMergePatch(t *testing.T) {
+	previous := map[string]interface{}{
+		"total_events": 10.0,
+		"unique_users": 4.0,
+		"top_pages":    []interface{}{"/home"},
+		"device_stats": map[string]interface{}{"desktop": 3.0, "mobile": 1.0},
+		"bot_events":   map[string]interface{}{"Googlebot": 1.0},
+	}
+	current := map[string]interface{}{
+		"total_events": 12.0,
+		"unique_users": 4.0,
+		"top_pages":    []interface{}{"/home", "/pricing"},
+		"device_stats": map[string]interface{}{"desktop": 3.0, "mobile": 2.0, "tablet": 1.0},
+		"new_field":    "x",
+	}
+
+	patch, err := json.Marshal(mergePatch(previous, current))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{"bot_events":null,"device_stats":{"mobile":2,"tablet":1},"new_field":"x","top_pages":["/home","/pricing"],"total_events":12}`
+	if string(patch) != want {
+		t.Errorf("Patch = %s\nwant    %s", patch, want)
+	}
+
+	if len(mergePatch(current, current)) != 0 {
+		t.Error("Identical documents should produce an empty patch")
+	}
+}
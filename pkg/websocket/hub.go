@@ -1,32 +1,34 @@
 package websocket
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
-	"log"
+	"fmt"
+	"math/rand"
 	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/analytics"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/logging"
 	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 )
 
-var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
-		// Allow all origins for demo purposes
-		// In production, implement proper origin checking
-		return true
-	},
-}
-
 // Hub maintains the set of active clients and broadcasts messages to the clients
 type Hub struct {
 	// Registered clients
 	clients map[*Client]bool
 
-	// Inbound messages from the clients
-	broadcast chan []byte
+	// Outbound messages to broadcast to subscribed clients
+	broadcast chan outboundMessage
 
 	// Register requests from the clients
 	register chan *Client
@@ -37,7 +39,77 @@ type Hub struct {
 	// Analytics service
 	analyticsService *analytics.Service
 
-	// Mutex for thread safety
+	// Short-lived snapshot cache shared by clients connecting at the same time and by
+	// the periodic broadcast
+	snapshots *analytics.SnapshotCache
+
+	// Formatting applied to snapshots before they are sent
+	format analytics.FormatOptions
+
+	// Origin and token checks applied to handshakes
+	auth     *authenticator
+	upgrader websocket.Upgrader
+
+	// Identifies this hub process so resume tokens from a previous run can be recognized
+	instanceID string
+
+	// Version of the most recently broadcast snapshot, only accessed from Run
+	snapshotVersion uint64
+
+	// Most recently broadcast snapshot that deltas are computed against, and the number of
+	// broadcasts since delta clients last received a full snapshot; only accessed from Run
+	lastSnapshot    map[string]interface{}
+	broadcastsSince int
+
+	// When heatmaps were last broadcast, only accessed from Run
+	lastHeatmapBroadcast time.Time
+
+	// Most recently broadcast active visitor counts, only accessed from Run
+	lastActiveVisitors *models.ActiveVisitors
+
+	// Most recently broadcast geo stats, encoded; only accessed from Run
+	lastGeo []byte
+
+	// Sequence number of the last broadcast message and the most recent broadcasts,
+	// replayed to SSE clients resuming with Last-Event-ID; only accessed from Run
+	sequence uint64
+	history  []outboundMessage
+
+	// Clients whose staggered initial snapshot is due
+	snapshotRequests chan *Client
+
+	// Requests to disconnect a client by ID, handled by Run
+	disconnects chan disconnectRequest
+
+	// Registration rate tracking for reconnect storm detection, only accessed from Run
+	connectWindowStart time.Time
+	connectWindowCount int
+
+	// Closed to stop the hub, and by Run once all clients are closed
+	done    chan struct{}
+	stopped chan struct{}
+	stop    sync.Once
+
+	// Tracks running write pumps so shutdown can wait for close frames to be sent
+	pumps sync.WaitGroup
+
+	// Broadcast intervals and buffer sizes
+	config HubConfig
+
+	// Broadcast intervals changed while running, picked up by Run
+	intervals chan HubConfig
+
+	// Rate limit on real-time events, only accessed from Run; nil when unlimited
+	throttle *eventThrottle
+
+	// Messages dropped because the broadcast channel was full, before reaching any client
+	broadcastDropped atomic.Uint64
+
+	// Real-time events dropped by the rate limit
+	eventsThrottled atomic.Uint64
+
+	// Guards clients; Run is the only writer and holds the write lock while changing it.
+	// Client filter locks may be taken while holding it, never the other way round.
 	mu sync.RWMutex
 }
 
@@ -48,30 +120,126 @@ type Client struct {
 	// The websocket connection
 	conn *websocket.Conn
 
-	// Buffered channel of outbound messages
-	send chan []byte
+	// Outbound messages from the hub
+	queue *sendQueue
+
+	// Direct responses to client requests, written by the read pump
+	replies chan []byte
+
+	// Message filter set by the client's subscription, nil for all messages
+	filter   *subscriptionFilter
+	filterMu sync.RWMutex
 
 	// Client ID for identification
 	id string
+
+	// When the client connected
+	connectedAt time.Time
+
+	// When the last pong was received, in Unix nanoseconds; zero before the first and for SSE clients
+	lastPong atomic.Int64
+
+	// What the client is authorized to receive
+	access AccessLevel
+
+	// Whether the client receives snapshot deltas instead of full updates
+	deltas bool
+
+	// Resume token presented when connecting, if any
+	resumeToken string
+
+	// Last-Event-ID presented by a reconnecting SSE client, if any
+	lastEventID string
+
+	// Close frame sent when the send channel is closed
+	closeCode int
+	closeText string
+
+	// Suggested reconnect delay when the hub shuts down, sent to SSE clients as retry
+	reconnectAfter time.Duration
 }
 
 // NewHub creates a new WebSocket hub
-func NewHub(analyticsService *analytics.Service) *Hub {
-	return &Hub{
-		broadcast:        make(chan []byte, 256),
+func NewHub(analyticsService *analytics.Service, format analytics.FormatOptions) *Hub {
+	config := DefaultHubConfig()
+	h := &Hub{
+		broadcast:        make(chan outboundMessage, config.BroadcastBufferSize),
 		register:         make(chan *Client),
 		unregister:       make(chan *Client),
 		clients:          make(map[*Client]bool),
 		analyticsService: analyticsService,
+		snapshots:        analytics.NewSnapshotCache(analyticsService, time.Second),
+		format:           format,
+		instanceID:       uuid.New().String()[:8],
+		snapshotRequests: make(chan *Client, 256),
+		disconnects:      make(chan disconnectRequest),
+		done:             make(chan struct{}),
+		stopped:          make(chan struct{}),
+		auth:             newAuthenticator(AuthConfig{}),
+		config:           config,
+		intervals:        make(chan HubConfig, 1),
+	}
+	h.upgrader = websocket.Upgrader{
+		CheckOrigin: func(r *http.Request) bool {
+			return h.auth.checkOrigin(r)
+		},
 	}
+	return h
+}
+
+// SetConfig sets the broadcast intervals, event rate limit and buffer sizes; unset
+// values keep their defaults. It must be called before Run and before the hub serves
+// connections.
+func (h *Hub) SetConfig(config HubConfig) {
+	h.config = config.withDefaults()
+	h.broadcast = make(chan outboundMessage, h.config.BroadcastBufferSize)
+	h.throttle = newEventThrottle(h.config.EventRateLimit)
+}
+
+// SetSnapshotCache shares a snapshot cache with the hub, so broadcasts and HTTP reads of
+// the snapshot reuse one computation. The cache should expire well within the snapshot
+// interval, or broadcasts repeat stale data. It must be called before Run.
+func (h *Hub) SetSnapshotCache(cache *analytics.SnapshotCache) {
+	h.snapshots = cache
+}
+
+// SetBroadcastIntervals changes how often snapshots and active visitor counts are
+// broadcast while the hub runs; non-positive intervals keep the defaults
+func (h *Hub) SetBroadcastIntervals(snapshot, activeVisitors time.Duration) {
+	config := HubConfig{SnapshotInterval: snapshot, ActiveVisitorsInterval: activeVisitors}.withDefaults()
+	// Replace a change Run has not picked up yet
+	select {
+	case <-h.intervals:
+	default:
+	}
+	h.intervals <- config
+}
+
+// SetAuth restricts connections to the configured origins and, if any tokens or a JWT
+// secret are configured, to clients presenting a valid token. It must be called before
+// the hub serves connections.
+func (h *Hub) SetAuth(config AuthConfig) {
+	h.auth = newAuthenticator(config)
 }
 
 // Run starts the WebSocket hub
 func (h *Hub) Run() {
 	// Start periodic analytics broadcast
-	ticker := time.NewTicker(5 * time.Second)
+	ticker := time.NewTicker(h.config.SnapshotInterval)
 	defer ticker.Stop()
 
+	// Check active visitors more often than full snapshots, since the message is small
+	activeVisitors := time.NewTicker(h.config.ActiveVisitorsInterval)
+	defer activeVisitors.Stop()
+
+	// Report throttled real-time events once a second
+	var throttleReports <-chan time.Time
+	if h.throttle != nil {
+		throttleTicker := time.NewTicker(time.Second)
+		defer throttleTicker.Stop()
+		throttleReports = throttleTicker.C
+	}
+
 	for {
 		select {
 		case client := <-h.register:
@@ -79,72 +247,498 @@ func (h *Hub) Run() {
 			h.clients[client] = true
 			h.mu.Unlock()
 
-			// Send initial analytics snapshot to new client
-			snapshot := h.analyticsService.GetSnapshot()
-			message := models.WebSocketMessage{
-				Type:      "analytics_snapshot",
-				Timestamp: time.Now(),
-				Data:      snapshot,
+			if client.lastEventID == "" || !h.replay(client) {
+				h.scheduleInitialSnapshot(client)
 			}
 
-			if data, err := json.Marshal(message); err == nil {
-				select {
-				case client.send <- data:
-				default:
-					h.removeClient(client)
-				}
-			}
+			logging.Info("Client connected", "transport", client.transport(), "client_id", client.id, "access", client.access)
+
+		case client := <-h.snapshotRequests:
+			h.sendSnapshot(client)
 
-			log.Printf("WebSocket client connected: %s", client.id)
+		case request := <-h.disconnects:
+			request.result <- h.disconnect(request.id)
 
 		case client := <-h.unregister:
+			h.mu.Lock()
 			h.removeClient(client)
-			log.Printf("WebSocket client disconnected: %s", client.id)
+			h.mu.Unlock()
+
+			stats := client.queue.stats()
+			logging.Info("Client disconnected", "transport", client.transport(), "client_id", client.id,
+				"sent", stats.Sent, "dropped", stats.Dropped, "coalesced", stats.Coalesced)
 
 		case message := <-h.broadcast:
-			h.mu.RLock()
-			for client := range h.clients {
-				select {
-				case client.send <- message:
-				default:
-					h.removeClient(client)
-				}
+			if h.throttle.allow(message, time.Now()) {
+				h.deliver(message)
+			} else {
+				h.eventsThrottled.Add(1)
 			}
-			h.mu.RUnlock()
+
+		case <-throttleReports:
+			h.reportThrottled()
+
+		case <-activeVisitors.C:
+			h.broadcastActiveVisitors()
 
 		case <-ticker.C:
-			// Broadcast analytics update every 5 seconds
+			// Broadcast analytics update every snapshot interval
 			h.broadcastAnalyticsUpdate()
+			h.broadcastExperimentResults()
+			h.broadcastHeatmaps()
+			h.broadcastDashboards()
+			h.broadcastGeo()
+
+		case config := <-h.intervals:
+			ticker.Reset(config.SnapshotInterval)
+			activeVisitors.Reset(config.ActiveVisitorsInterval)
+			logging.Info("Broadcast intervals changed", "snapshot", config.SnapshotInterval, "active_visitors", config.ActiveVisitorsInterval)
+
+		case <-h.done:
+			h.closeAllClients()
+			close(h.stopped)
+			return
 		}
 	}
 }
 
-// removeClient removes a client from the hub
+// deliver numbers a broadcast message, records it for SSE resumption and queues it for
+// every client that wants it
+func (h *Hub) deliver(message outboundMessage) {
+	h.sequence++
+	message.id = h.sequence
+	h.history = append(h.history, message)
+	if len(h.history) > eventHistorySize {
+		h.history = h.history[1:]
+	}
+
+	// Enqueueing never blocks, so a slow client cannot stall the others
+	h.mu.RLock()
+	for client := range h.clients {
+		if client.wants(message) {
+			client.queue.pushID(message.id, message.messageType, message.data)
+		}
+	}
+	h.mu.RUnlock()
+}
+
+// reportThrottled sends one summary of the real-time events dropped by the rate limit
+// in the last second
+func (h *Hub) reportThrottled() {
+	summary, ok := h.throttle.flush()
+	if !ok {
+		return
+	}
+
+	message := models.WebSocketMessage{
+		Type:      "real_time_throttled",
+		Timestamp: time.Now(),
+		Data:      summary,
+	}
+	if data, err := json.Marshal(message); err == nil {
+		h.deliver(outboundMessage{messageType: message.Type, data: data})
+	}
+}
+
+// scheduleInitialSnapshot decides when a newly registered client receives its first snapshot.
+// Clients resuming with a current token skip it and wait for the next periodic update, while
+// reconnecting clients and connection bursts get a jittered delay to smooth reconnect storms.
+func (h *Hub) scheduleInitialSnapshot(client *Client) {
+	burst := h.trackConnect()
+	instanceID, version, resuming := parseResumeToken(client.resumeToken)
+
+	switch {
+	case resuming && instanceID == h.instanceID && version+1 >= h.snapshotVersion:
+		// Client already holds a current snapshot
+		return
+	case resuming || burst:
+		delay := time.Duration(rand.Int63n(int64(snapshotStaggerWindow)))
+		time.AfterFunc(delay, func() {
+			h.requestSnapshot(client)
+		})
+	default:
+		h.sendSnapshot(client)
+	}
+}
+
+// requestSnapshot asks Run to send the client a full snapshot
+func (h *Hub) requestSnapshot(client *Client) {
+	select {
+	case h.snapshotRequests <- client:
+	case <-h.done:
+	}
+}
+
+// trackConnect records a registration and reports whether connections are arriving in a burst
+func (h *Hub) trackConnect() bool {
+	now := time.Now()
+	if now.Sub(h.connectWindowStart) > time.Second {
+		h.connectWindowStart = now
+		h.connectWindowCount = 0
+	}
+	h.connectWindowCount++
+	return h.connectWindowCount > connectBurstThreshold
+}
+
+// sendSnapshot sends the current analytics snapshot to a single registered client
+func (h *Hub) sendSnapshot(client *Client) {
+	h.mu.RLock()
+	_, ok := h.clients[client]
+	h.mu.RUnlock()
+	if !ok {
+		return
+	}
+	defer h.sendRecentAlerts(client)
+
+	// Dashboard clients start with their dashboard's data instead of the full snapshot
+	if name := client.dashboard(); name != "" {
+		if data, ok := h.dashboardMessage(name); ok {
+			client.queue.pushID(h.sequence, "dashboard_update", data)
+		}
+		return
+	}
+
+	message := models.WebSocketMessage{
+		Type:        "analytics_snapshot",
+		Timestamp:   time.Now(),
+		Data:        analytics.FormatSnapshot(h.snapshots.Get(), h.format),
+		Version:     h.snapshotVersion,
+		ResumeToken: h.resumeToken(),
+	}
+
+	// Delta clients need exactly the state the next delta is computed against
+	if client.deltas && h.lastSnapshot != nil {
+		message.Data = h.lastSnapshot
+	}
+	if client.access < AccessAdmin {
+		document, err := snapshotDocument(message.Data)
+		if err != nil {
+			return
+		}
+		message.Data = redactSnapshot(document)
+	}
+
+	if data, err := json.Marshal(message); err == nil {
+		client.queue.pushID(h.sequence, message.Type, data)
+	}
+}
+
+// sendRecentAlerts sends a new client the alerts that fired before it connected and are
+// still unresolved, if it receives alerts
+func (h *Hub) sendRecentAlerts(client *Client) {
+	if !client.wants(outboundMessage{messageType: "alert"}) {
+		return
+	}
+	alerts := h.analyticsService.RecentAlerts(0, true)
+	if len(alerts) == 0 {
+		return
+	}
+
+	message := models.WebSocketMessage{
+		Type:      "recent_alerts",
+		Timestamp: time.Now(),
+		Data:      map[string]interface{}{"alerts": alerts},
+	}
+	if data, err := json.Marshal(message); err == nil {
+		client.queue.pushID(h.sequence, message.Type, data)
+	}
+}
+
+// resumeToken returns the token a client should present to resume at the current snapshot version
+func (h *Hub) resumeToken() string {
+	return h.instanceID + "." + strconv.FormatUint(h.snapshotVersion, 10)
+}
+
+// parseResumeToken splits a resume token into its hub instance ID and snapshot version
+func parseResumeToken(token string) (string, uint64, bool) {
+	instanceID, versionText, ok := strings.Cut(token, ".")
+	if !ok || instanceID == "" {
+		return "", 0, false
+	}
+	version, err := strconv.ParseUint(versionText, 10, 64)
+	if err != nil {
+		return "", 0, false
+	}
+	return instanceID, version, true
+}
+
+// closeAllClients disconnects every client with a jittered reconnect hint in the close frame
+func (h *Hub) closeAllClients() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for client := range h.clients {
+		reconnectAfter := reconnectMinDelay + time.Duration(rand.Int63n(int64(reconnectMaxDelay-reconnectMinDelay)))
+		client.closeCode = websocket.CloseServiceRestart
+		client.closeText = fmt.Sprintf(`{"reconnect_after_ms":%d}`, reconnectAfter.Milliseconds())
+		client.reconnectAfter = reconnectAfter
+		h.removeClient(client)
+	}
+}
+
+// disconnectRequest asks Run to disconnect the client with an ID and report whether it was connected
+type disconnectRequest struct {
+	id     string
+	result chan bool
+}
+
+// disconnect closes the client with an ID, reporting false if no such client is connected
+func (h *Hub) disconnect(id string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for client := range h.clients {
+		if client.id == id {
+			client.closeCode = websocket.ClosePolicyViolation
+			client.closeText = "disconnected by administrator"
+			h.removeClient(client)
+			logging.Info("Client disconnected by administrator", "transport", client.transport(), "client_id", id)
+			return true
+		}
+	}
+	return false
+}
+
+// Disconnect closes the connection of the client with an ID, reporting false if no such
+// client is connected. WebSocket clients receive close code 1008 (policy violation).
+func (h *Hub) Disconnect(id string) bool {
+	request := disconnectRequest{id: id, result: make(chan bool, 1)}
+	select {
+	case h.disconnects <- request:
+	case <-h.done:
+		return false
+	}
+	return <-request.result
+}
+
+// Shutdown stops the hub, closing client connections with reconnect hints, and waits
+// until the close frames are written or the context expires
+func (h *Hub) Shutdown(ctx context.Context) error {
+	h.stop.Do(func() { close(h.done) })
+
+	select {
+	case <-h.stopped:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	pumpsDone := make(chan struct{})
+	go func() {
+		h.pumps.Wait()
+		close(pumpsDone)
+	}()
+
+	select {
+	case <-pumpsDone:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// removeClient removes a client from the hub; the caller must hold the write lock
 func (h *Hub) removeClient(client *Client) {
 	if _, ok := h.clients[client]; ok {
 		delete(h.clients, client)
-		close(client.send)
+		client.queue.close()
+	}
+}
+
+// publish queues a message for broadcast, counting it as dropped if the hub is backed up
+func (h *Hub) publish(message outboundMessage) {
+	select {
+	case h.broadcast <- message:
+	default:
+		h.broadcastDropped.Add(1)
 	}
 }
 
-// broadcastAnalyticsUpdate sends analytics updates to all connected clients
+// publishRedacted queues a message carrying personal data: admins receive it whole and,
+// when tokens are configured, read-only clients receive the redacted encoding instead
+func (h *Hub) publishRedacted(message outboundMessage, redacted []byte) {
+	if !h.auth.enabled() {
+		h.publish(message)
+		return
+	}
+	message.access = AccessAdmin
+	h.publish(message)
+	message.access, message.data = AccessReadOnly, redacted
+	h.publish(message)
+}
+
+// publishSnapshot encodes and publishes a snapshot message whose data is a snapshot
+// document or a delta patch, leaving the personal fields out for read-only clients
+func (h *Hub) publishSnapshot(message models.WebSocketMessage, document map[string]interface{}, audience audience) {
+	message.Data = document
+	data, err := json.Marshal(message)
+	if err != nil {
+		return
+	}
+	message.Data = redactSnapshot(document)
+	redacted, err := json.Marshal(message)
+	if err != nil {
+		return
+	}
+	h.publishRedacted(outboundMessage{messageType: message.Type, audience: audience, data: data}, redacted)
+}
+
+// broadcastAnalyticsUpdate sends analytics updates to all connected clients. Delta clients
+// receive only the fields that changed since the previous update, plus a full snapshot
+// every fullSnapshotEvery updates.
 func (h *Hub) broadcastAnalyticsUpdate() {
-	snapshot := h.analyticsService.GetSnapshot()
+	snapshot, err := snapshotDocument(analytics.FormatSnapshot(h.snapshots.Get(), h.format))
+	if err != nil {
+		logging.Error("Failed to encode analytics snapshot", "error", err)
+		return
+	}
+
+	previous, baseVersion := h.lastSnapshot, h.snapshotVersion
+	h.snapshotVersion++
+	h.lastSnapshot = snapshot
+	h.broadcastsSince++
+
+	now := time.Now()
+	full := models.WebSocketMessage{
+		Type:        "analytics_update",
+		Timestamp:   now,
+		Version:     h.snapshotVersion,
+		ResumeToken: h.resumeToken(),
+	}
+
+	// Periodically resync delta clients with a full snapshot
+	if previous == nil || h.broadcastsSince >= fullSnapshotEvery {
+		h.broadcastsSince = 0
+		h.publishSnapshot(full, snapshot, allClients)
+		return
+	}
+
+	h.publishSnapshot(full, snapshot, fullSnapshotClients)
+
+	delta := models.WebSocketMessage{
+		Type:        "analytics_delta",
+		Timestamp:   now,
+		Version:     h.snapshotVersion,
+		BaseVersion: baseVersion,
+		ResumeToken: h.resumeToken(),
+	}
+	h.publishSnapshot(delta, mergePatch(previous, snapshot), deltaClients)
+}
+
+// broadcastExperimentResults sends live experiment results to all connected clients
+func (h *Hub) broadcastExperimentResults() {
+	results := h.analyticsService.GetExperimentResults()
+	if len(results) == 0 {
+		return
+	}
+
 	message := models.WebSocketMessage{
-		Type:      "analytics_update",
+		Type:      "experiment_results",
 		Timestamp: time.Now(),
-		Data:      snapshot,
+		Data:      results,
 	}
 
 	if data, err := json.Marshal(message); err == nil {
-		select {
-		case h.broadcast <- data:
-		default:
-			// Broadcast channel is full, skip this update
+		h.publish(outboundMessage{messageType: message.Type, data: data})
+	}
+}
+
+// broadcastHeatmaps sends the heatmap of each page clicked since the last broadcast,
+// one message per page so clients can subscribe to the paths they render
+func (h *Hub) broadcastHeatmaps() {
+	now := time.Now()
+	heatmaps := h.analyticsService.GetHeatmapsUpdatedSince(h.lastHeatmapBroadcast)
+	h.lastHeatmapBroadcast = now
+
+	for _, heatmap := range heatmaps {
+		message := models.WebSocketMessage{
+			Type:      "heatmap",
+			Timestamp: now,
+			Data:      heatmap,
+		}
+		if data, err := json.Marshal(message); err == nil {
+			h.publish(outboundMessage{messageType: message.Type, path: heatmap.Path, data: data})
+		}
+	}
+}
+
+// broadcastDashboards sends the data of every dashboard with subscribers to its
+// subscribers, computing each dashboard once
+func (h *Hub) broadcastDashboards() {
+	names := make(map[string]bool)
+	h.mu.RLock()
+	for client := range h.clients {
+		if name := client.dashboard(); name != "" {
+			names[name] = true
+		}
+	}
+	h.mu.RUnlock()
+
+	for name := range names {
+		if data, ok := h.dashboardMessage(name); ok {
+			h.publish(outboundMessage{messageType: "dashboard_update", dashboard: name, data: data})
 		}
 	}
 }
 
+// dashboardMessage encodes a dashboard_update message with the dashboard's current data,
+// reporting false if the dashboard no longer exists
+func (h *Hub) dashboardMessage(name string) ([]byte, bool) {
+	dashboard, ok := h.analyticsService.Dashboard(name)
+	if !ok {
+		return nil, false
+	}
+	message := models.WebSocketMessage{
+		Type:      "dashboard_update",
+		Timestamp: time.Now(),
+		Data:      h.analyticsService.DashboardData(dashboard),
+	}
+	data, err := json.Marshal(message)
+	return data, err == nil
+}
+
+// broadcastActiveVisitors sends the active visitor counts to all connected clients when
+// they differ from the last ones sent
+func (h *Hub) broadcastActiveVisitors() {
+	visitors := h.analyticsService.GetActiveVisitors()
+	if last := h.lastActiveVisitors; last != nil && sameActiveVisitors(*last, visitors) {
+		return
+	}
+	h.lastActiveVisitors = &visitors
+
+	message := models.WebSocketMessage{
+		Type:      "active_visitors",
+		Timestamp: visitors.Timestamp,
+		Data:      visitors,
+	}
+	if data, err := json.Marshal(message); err == nil {
+		h.publish(outboundMessage{messageType: message.Type, data: data})
+	}
+}
+
+// broadcastGeo sends page views by country and region to all connected clients when they
+// differ from the last ones sent, for live map widgets
+func (h *Hub) broadcastGeo() {
+	geo, err := json.Marshal(h.analyticsService.GetGeoStats())
+	if err != nil || bytes.Equal(geo, h.lastGeo) {
+		return
+	}
+	h.lastGeo = geo
+
+	message := models.WebSocketMessage{
+		Type:      "geo_update",
+		Timestamp: time.Now(),
+		Data:      json.RawMessage(geo),
+	}
+	if data, err := json.Marshal(message); err == nil {
+		h.publish(outboundMessage{messageType: message.Type, data: data})
+	}
+}
+
+// sameActiveVisitors reports whether two active visitor reports hold the same counts
+func sameActiveVisitors(a, b models.ActiveVisitors) bool {
+	return a.LastMinute == b.LastMinute && a.LastFiveMinutes == b.LastFiveMinutes && slices.Equal(a.Pages, b.Pages)
+}
+
 // BroadcastEvent sends a real-time event to all connected clients
 func (h *Hub) BroadcastEvent(event *models.AnalyticsEvent) {
 	recentEvent := models.RecentEvent{
@@ -152,7 +746,7 @@ func (h *Hub) BroadcastEvent(event *models.AnalyticsEvent) {
 		Type:      event.Type,
 		URL:       event.URL,
 		UserID:    event.UserID,
-		Location:  "Unknown", // Simplified for demo
+		Location:  analytics.EventLocation(event),
 	}
 
 	message := models.WebSocketMessage{
@@ -162,11 +756,13 @@ func (h *Hub) BroadcastEvent(event *models.AnalyticsEvent) {
 	}
 
 	if data, err := json.Marshal(message); err == nil {
-		select {
-		case h.broadcast <- data:
-		default:
-			// Broadcast channel is full, skip this event
+		outbound := outboundMessage{
+			messageType: message.Type,
+			eventType:   event.Type,
+			path:        event.Path,
+			data:        data,
 		}
+		h.publish(outbound)
 	}
 }
 
@@ -179,12 +775,97 @@ func (h *Hub) BroadcastAlert(alert models.Alert) {
 	}
 
 	if data, err := json.Marshal(message); err == nil {
-		select {
-		case h.broadcast <- data:
-		default:
-			// Broadcast channel is full, skip this alert
-		}
+		h.publish(outboundMessage{messageType: message.Type, data: data})
+	}
+}
+
+// BroadcastGoalCompletion sends a conversion goal completion to all connected clients
+func (h *Hub) BroadcastGoalCompletion(completion models.GoalCompletion) {
+	message := models.WebSocketMessage{
+		Type:      "goal_completed",
+		Timestamp: time.Now(),
+		Data:      completion,
+	}
+	data, err := json.Marshal(message)
+	if err != nil {
+		return
+	}
+
+	// Read-only clients see the completion without who completed the goal
+	completion.UserID, completion.SessionID = "", ""
+	message.Data = completion
+	if redacted, err := json.Marshal(message); err == nil {
+		h.publishRedacted(outboundMessage{messageType: message.Type, path: completion.Path, data: data}, redacted)
+	}
+}
+
+// ClientStats reports delivery to one client
+type ClientStats struct {
+	ID           string               `json:"id"`
+	Transport    string               `json:"transport"` // websocket or sse
+	Access       string               `json:"access"`
+	ConnectedAt  time.Time            `json:"connected_at"`
+	LastPong     *time.Time           `json:"last_pong,omitempty"`    // WebSocket clients only, once a pong is received
+	Subscription *models.Subscription `json:"subscription,omitempty"` // Nil when the client receives all messages
+	Queued       int                  `json:"queued"`
+	Sent         uint64               `json:"sent"`
+	Dropped      uint64               `json:"dropped"`   // Oldest messages discarded because the queue was full
+	Coalesced    uint64               `json:"coalesced"` // Queued snapshots replaced by a newer one
+}
+
+// stats reports the client's connection details and delivery counters
+func (c *Client) stats() ClientStats {
+	stats := c.queue.stats()
+	stats.ID = c.id
+	stats.Transport = strings.ToLower(c.transport())
+	stats.Access = c.access.String()
+	stats.ConnectedAt = c.connectedAt
+	if nanos := c.lastPong.Load(); nanos != 0 {
+		lastPong := time.Unix(0, nanos)
+		stats.LastPong = &lastPong
+	}
+
+	c.filterMu.RLock()
+	stats.Subscription = c.filter.subscription()
+	c.filterMu.RUnlock()
+	return stats
+}
+
+// HubStats reports delivery across all connected clients
+type HubStats struct {
+	Clients          int           `json:"clients"`
+	BroadcastDropped uint64        `json:"broadcast_dropped"` // Messages dropped before reaching any client
+	Throttled        uint64        `json:"throttled"`         // Real-time events dropped by the rate limit
+	Dropped          uint64        `json:"dropped"`
+	Coalesced        uint64        `json:"coalesced"`
+	PerClient        []ClientStats `json:"per_client"`
+}
+
+// Stats returns delivery metrics for the connected clients, those dropping the most first
+func (h *Hub) Stats() HubStats {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	stats := HubStats{
+		Clients:          len(h.clients),
+		BroadcastDropped: h.broadcastDropped.Load(),
+		Throttled:        h.eventsThrottled.Load(),
+		PerClient:        make([]ClientStats, 0, len(h.clients)),
+	}
+	for client := range h.clients {
+		clientStats := client.stats()
+		stats.Dropped += clientStats.Dropped
+		stats.Coalesced += clientStats.Coalesced
+		stats.PerClient = append(stats.PerClient, clientStats)
 	}
+
+	sort.Slice(stats.PerClient, func(i, j int) bool {
+		if stats.PerClient[i].Dropped != stats.PerClient[j].Dropped {
+			return stats.PerClient[i].Dropped > stats.PerClient[j].Dropped
+		}
+		return stats.PerClient[i].ID < stats.PerClient[j].ID
+	})
+	return stats
 }
 
 // GetClientCount returns the number of connected clients
@@ -194,11 +875,62 @@ func (h *Hub) GetClientCount() int {
 	return len(h.clients)
 }
 
+// Clients returns the connected clients, longest connected first
+func (h *Hub) Clients() []ClientStats {
+	h.mu.RLock()
+	clients := make([]ClientStats, 0, len(h.clients))
+	for client := range h.clients {
+		clients = append(clients, client.stats())
+	}
+	h.mu.RUnlock()
+
+	sort.Slice(clients, func(i, j int) bool {
+		if !clients[i].ConnectedAt.Equal(clients[j].ConnectedAt) {
+			return clients[i].ConnectedAt.Before(clients[j].ConnectedAt)
+		}
+		return clients[i].ID < clients[j].ID
+	})
+	return clients
+}
+
+// Client returns the stats of the connected client with an ID
+func (h *Hub) Client(id string) (ClientStats, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for client := range h.clients {
+		if client.id == id {
+			return client.stats(), true
+		}
+	}
+	return ClientStats{}, false
+}
+
 // ServeWS handles websocket requests from clients
 func (h *Hub) ServeWS(w http.ResponseWriter, r *http.Request) {
-	conn, err := upgrader.Upgrade(w, r, nil)
+	logger := logging.FromContext(r.Context())
+	if !h.auth.checkOrigin(r) {
+		logger.Warn("WebSocket connection rejected", "origin", r.Header.Get("Origin"))
+		http.Error(w, "Origin not allowed", http.StatusForbidden)
+		return
+	}
+
+	access, err := h.auth.authorize(r)
+	if err != nil {
+		logger.Warn("WebSocket connection rejected", "remote_addr", r.RemoteAddr, "error", err)
+		w.Header().Set("WWW-Authenticate", `Bearer realm="analytics"`)
+		http.Error(w, "Invalid or missing token", http.StatusUnauthorized)
+		return
+	}
+
+	dashboard := r.URL.Query().Get("dashboard")
+	if _, ok := h.analyticsService.Dashboard(dashboard); dashboard != "" && !ok {
+		http.Error(w, "Dashboard not found", http.StatusNotFound)
+		return
+	}
+
+	conn, err := h.upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		log.Printf("WebSocket upgrade error: %v", err)
+		logger.Error("WebSocket upgrade failed", "error", err)
 		return
 	}
 
@@ -206,16 +938,29 @@ func (h *Hub) ServeWS(w http.ResponseWriter, r *http.Request) {
 	clientID := generateClientID()
 
 	client := &Client{
-		hub:  h,
-		conn: conn,
-		send: make(chan []byte, 256),
-		id:   clientID,
+		hub:         h,
+		conn:        conn,
+		queue:       newSendQueue(h.config.QueueSize),
+		replies:     make(chan []byte, 16),
+		filter:      newSubscriptionFilter(models.Subscription{Dashboard: dashboard}),
+		id:          clientID,
+		connectedAt: time.Now(),
+		access:      access,
+		deltas:      r.URL.Query().Get("delta") == "true",
+		resumeToken: r.URL.Query().Get("resume"),
 	}
 
-	client.hub.register <- client
+	select {
+	case client.hub.register <- client:
+	case <-h.done:
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseServiceRestart, ""))
+		conn.Close()
+		return
+	}
 
 	// Allow collection of memory referenced by the caller by doing all work in
 	// new goroutines
+	h.pumps.Add(1)
 	go client.writePump()
 	go client.readPump()
 }
@@ -224,72 +969,90 @@ const (
 	// Time allowed to write a message to the peer
 	writeWait = 10 * time.Second
 
-	// Time allowed to read the next pong message from the peer
-	pongWait = 60 * time.Second
+	// Maximum message size allowed from peer
+	maxMessageSize = 4096
 
-	// Send pings to peer with this period. Must be less than pongWait
-	pingPeriod = (pongWait * 9) / 10
+	// Range of reconnect delays suggested to clients when the hub shuts down
+	reconnectMinDelay = 1 * time.Second
+	reconnectMaxDelay = 15 * time.Second
 
-	// Maximum message size allowed from peer
-	maxMessageSize = 512
+	// Window over which initial snapshots are spread for reconnecting clients
+	snapshotStaggerWindow = 3 * time.Second
+
+	// Registrations per second above which connections are treated as a reconnect storm
+	connectBurstThreshold = 20
+
+	// Number of recent broadcasts kept for SSE clients resuming with Last-Event-ID
+	eventHistorySize = 256
 )
 
-// readPump pumps messages from the websocket connection to the hub
+// readPump reads control messages such as subscriptions from the websocket connection
 func (c *Client) readPump() {
 	defer func() {
-		c.hub.unregister <- c
+		select {
+		case c.hub.unregister <- c:
+		case <-c.hub.done:
+		}
 		c.conn.Close()
 	}()
 
 	c.conn.SetReadLimit(maxMessageSize)
+	pongWait := c.hub.config.pongWait()
 	c.conn.SetReadDeadline(time.Now().Add(pongWait))
 	c.conn.SetPongHandler(func(string) error {
-		c.conn.SetReadDeadline(time.Now().Add(pongWait))
+		now := time.Now()
+		c.lastPong.Store(now.UnixNano())
+		c.conn.SetReadDeadline(now.Add(pongWait))
 		return nil
 	})
 
 	for {
-		_, _, err := c.conn.ReadMessage()
+		_, data, err := c.conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				log.Printf("WebSocket error: %v", err)
+				logging.Warn("WebSocket read failed", "client_id", c.id, "error", err)
 			}
 			break
 		}
+		c.handleRequest(data)
 	}
 }
 
 // writePump pumps messages from the hub to the websocket connection
 func (c *Client) writePump() {
-	ticker := time.NewTicker(pingPeriod)
+	ticker := time.NewTicker(c.hub.config.PingPeriod)
 	defer func() {
 		ticker.Stop()
 		c.conn.Close()
+		c.hub.pumps.Done()
 	}()
 
 	for {
 		select {
-		case message, ok := <-c.send:
-			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
-			if !ok {
-				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
-				return
-			}
+		case <-c.queue.ready:
+			messages, closed := c.queue.take()
 
-			w, err := c.conn.NextWriter(websocket.TextMessage)
-			if err != nil {
-				return
+			// Each message is its own frame so clients can parse every frame as JSON
+			for _, message := range messages {
+				c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+				if err := c.conn.WriteMessage(websocket.TextMessage, message.data); err != nil {
+					return
+				}
 			}
-			w.Write(message)
 
-			// Add queued messages to the current websocket message
-			n := len(c.send)
-			for i := 0; i < n; i++ {
-				w.Write([]byte{'\n'})
-				w.Write(<-c.send)
+			if closed {
+				c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+				closeMessage := []byte{}
+				if c.closeCode != 0 {
+					closeMessage = websocket.FormatCloseMessage(c.closeCode, c.closeText)
+				}
+				c.conn.WriteMessage(websocket.CloseMessage, closeMessage)
+				return
 			}
 
-			if err := w.Close(); err != nil {
+		case reply := <-c.replies:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.TextMessage, reply); err != nil {
 				return
 			}
 
@@ -302,8 +1065,7 @@ func (c *Client) writePump() {
 	}
 }
 
-// generateClientID generates a unique client ID
+// generateClientID generates a unique client ID, so clients can be looked up and disconnected by ID
 func generateClientID() string {
-	return "client_" + time.Now().Format("20060102150405") + "_" +
-		string(rune(time.Now().UnixNano()%1000))
+	return "client_" + time.Now().Format("20060102150405") + "_" + uuid.New().String()[:8]
 }
@@ -0,0 +1,257 @@
+package websocket
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/analytics"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+	"github.com/gorilla/websocket"
+)
+
+func TestHubListsAndDisconnectsClients(t *testing.T) {
+	hub := NewHub(analytics.NewService(), analytics.FormatOptions{})
+	go hub.Run()
+	defer hub.Shutdown(context.Background())
+
+	server := httptest.NewServer(http.HandlerFunc(hub.ServeWS))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Connecting: %v", err)
+	}
+	defer conn.Close()
+	if err := conn.WriteJSON(map[string]interface{}{"action": "subscribe", "message_types": []string{"alert"}}); err != nil {
+		t.Fatalf("Subscribing: %v", err)
+	}
+
+	// Wait for the subscription to be applied
+	var clients []ClientStats
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		clients = hub.Clients()
+		if len(clients) == 1 && clients[0].Subscription != nil || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(clients) != 1 || hub.GetClientCount() != 1 {
+		t.Fatalf("Expected one client, got %+v", clients)
+	}
+	client := clients[0]
+	if client.Transport != "websocket" || client.ConnectedAt.IsZero() {
+		t.Errorf("Expected a websocket client with a connect time, got %+v", client)
+	}
+	if client.Subscription == nil || len(client.Subscription.MessageTypes) != 1 || client.Subscription.MessageTypes[0] != "alert" {
+		t.Errorf("Expected the alert subscription, got %+v", client.Subscription)
+	}
+	if _, ok := hub.Client(client.ID); !ok {
+		t.Errorf("Expected client %s to be found", client.ID)
+	}
+
+	if !hub.Disconnect(client.ID) {
+		t.Fatalf("Expected client %s to be disconnected", client.ID)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		if _, _, err = conn.ReadMessage(); err != nil {
+			break
+		}
+	}
+	if !websocket.IsCloseError(err, websocket.ClosePolicyViolation) && err != io.EOF {
+		t.Errorf("Expected a policy violation close, got %v", err)
+	}
+	if hub.Disconnect(client.ID) {
+		t.Error("Expected disconnecting an unknown client to report false")
+	}
+	if _, ok := hub.Client(client.ID); ok {
+		t.Error("Expected the disconnected client to be removed")
+	}
+}
+
+// TestHubConcurrentUse connects and disconnects clients while messages are broadcast
+// and stats read; run with -race
+func TestHubConcurrentUse(t *testing.T) {
+	hub := NewHub(analytics.NewService(), analytics.FormatOptions{})
+	hub.SetConfig(HubConfig{SnapshotInterval: 5 * time.Millisecond, ActiveVisitorsInterval: 5 * time.Millisecond})
+	go hub.Run()
+	defer hub.Shutdown(context.Background())
+
+	server := httptest.NewServer(http.HandlerFunc(hub.ServeWS))
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	stop := make(chan struct{})
+	var background sync.WaitGroup
+	loop := func(work func()) {
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					work()
+				}
+			}
+		}()
+	}
+	loop(func() {
+		hub.BroadcastEvent(&models.AnalyticsEvent{Type: models.PageView, Path: "/", Timestamp: time.Now()})
+		hub.BroadcastAlert(models.Alert{ID: "a1"})
+	})
+	loop(func() {
+		hub.Stats()
+		hub.GetClientCount()
+		for _, client := range hub.Clients() {
+			hub.Client(client.ID)
+		}
+	})
+	loop(func() {
+		if clients := hub.Clients(); len(clients) > 0 {
+			hub.Disconnect(clients[0].ID)
+		}
+		time.Sleep(time.Millisecond)
+	})
+
+	var clients sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		clients.Add(1)
+		go func(i int) {
+			defer clients.Done()
+			for j := 0; j < 5; j++ {
+				conn, _, err := websocket.DefaultDialer.Dial(url+"?delta="+strconv.FormatBool(i%2 == 0), nil)
+				if err != nil {
+					t.Errorf("Connecting: %v", err)
+					return
+				}
+				conn.WriteJSON(map[string]interface{}{"action": "subscribe", "message_types": []string{"alert", "analytics_update"}})
+				conn.SetReadDeadline(time.Now().Add(20 * time.Millisecond))
+				for {
+					if _, _, err := conn.ReadMessage(); err != nil {
+						break
+					}
+				}
+				conn.Close()
+			}
+		}(i)
+	}
+	clients.Wait()
+	close(stop)
+	background.Wait()
+}
+
+func TestHubSendsRecentAlertsOnConnect(t *testing.T) {
+	service := analytics.NewService()
+	service.SetAlerts([]models.AlertConfig{
+		{Name: "No Users", Type: "traffic", Metric: "unique_users", Threshold: 1, Operator: "lt", Enabled: true},
+	})
+	service.CheckAlerts()
+
+	hub := NewHub(service, analytics.FormatOptions{})
+	go hub.Run()
+	defer hub.Shutdown(context.Background())
+
+	server := httptest.NewServer(http.HandlerFunc(hub.ServeWS))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Connecting: %v", err)
+	}
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var types []string
+	for {
+		var message struct {
+			Type string `json:"type"`
+			Data struct {
+				Alerts []models.Alert `json:"alerts"`
+			} `json:"data"`
+		}
+		if err := conn.ReadJSON(&message); err != nil {
+			t.Fatalf("Expected a recent_alerts message after %v: %v", types, err)
+		}
+		types = append(types, message.Type)
+		if message.Type == "recent_alerts" {
+			if len(message.Data.Alerts) != 1 || message.Data.Alerts[0].Name != "No Users" {
+				t.Errorf("Expected the active alert, got %+v", message.Data.Alerts)
+			}
+			break
+		}
+	}
+	if types[0] != "analytics_snapshot" {
+		t.Errorf("Expected the snapshot first, got %v", types)
+	}
+}
+
+func TestHubRedactsPersonalDataForReadOnlyClients(t *testing.T) {
+	service := analytics.NewService()
+	service.ProcessEvent(&models.AnalyticsEvent{ID: "e1", Type: models.PageView, UserID: "u1", SessionID: "s1", URL: "https://example.com/", Timestamp: time.Now()})
+
+	hub := NewHub(service, analytics.FormatOptions{})
+	hub.SetAuth(AuthConfig{ReadTokens: []string{"read"}, AdminTokens: []string{"admin"}})
+	go hub.Run()
+	defer hub.Shutdown(context.Background())
+
+	server := httptest.NewServer(http.HandlerFunc(hub.ServeWS))
+	defer server.Close()
+
+	connect := func(token string) *websocket.Conn {
+		conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"?token="+token, nil)
+		if err != nil {
+			t.Fatalf("Connecting: %v", err)
+		}
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		return conn
+	}
+	// read returns the data of the next message of the given type
+	read := func(conn *websocket.Conn, messageType string) map[string]interface{} {
+		t.Helper()
+		for {
+			var message struct {
+				Type string                 `json:"type"`
+				Data map[string]interface{} `json:"data"`
+			}
+			if err := conn.ReadJSON(&message); err != nil {
+				t.Fatalf("Expected a %s message: %v", messageType, err)
+			}
+			if message.Type == messageType {
+				return message.Data
+			}
+		}
+	}
+
+	reader, admin := connect("read"), connect("admin")
+	defer reader.Close()
+	defer admin.Close()
+
+	if _, ok := read(reader, "analytics_snapshot")["real_time_events"]; ok {
+		t.Error("Expected the snapshot of a read-only client to leave out the recent events")
+	}
+	if _, ok := read(admin, "analytics_snapshot")["real_time_events"]; !ok {
+		t.Error("Expected the snapshot of an admin to include the recent events")
+	}
+
+	// Wait for both clients to be registered before broadcasting
+	for deadline := time.Now().Add(5 * time.Second); hub.GetClientCount() < 2 && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+	hub.BroadcastGoalCompletion(models.GoalCompletion{Goal: "signup", UserID: "u1", SessionID: "s1", Path: "/"})
+	if completion := read(reader, "goal_completed"); completion["user_id"] != nil || completion["session_id"] != nil || completion["goal"] != "signup" {
+		t.Errorf("Expected the completion without user or session ID, got %v", completion)
+	}
+	if completion := read(admin, "goal_completed"); completion["user_id"] != "u1" {
+		t.Errorf("Expected admins to see who completed the goal, got %v", completion)
+	}
+}
@@ -0,0 +1,129 @@
+package websocket
+
+import "sync"
+
+// coalescedMessageTypes carry complete state, so a newer message makes a queued one of
+// the same type obsolete
+var coalescedMessageTypes = map[string]bool{
+	"analytics_snapshot": true,
+	"analytics_update":   true,
+	"experiment_results": true,
+	"active_visitors":    true,
+	"dashboard_update":   true,
+	"geo_update":         true,
+}
+
+// queuedMessage is an encoded message waiting to be written
+type queuedMessage struct {
+	id          uint64 // Broadcast sequence number, used as the SSE event ID
+	messageType string
+	data        []byte
+}
+
+// sendQueue is a client's bounded outbound queue. Enqueueing never blocks the hub:
+// a snapshot replaces any queued message of the same type, and when the queue is
+// full the oldest message is dropped so a slow client falls behind on events
+// rather than on current state.
+type sendQueue struct {
+	messages []queuedMessage
+	limit    int
+	closed   bool
+
+	// Signalled when messages are added or the queue is closed
+	ready chan struct{}
+
+	// Delivery counters
+	sent      uint64
+	dropped   uint64
+	coalesced uint64
+
+	mu sync.Mutex
+}
+
+// newSendQueue creates a queue holding at most limit messages
+func newSendQueue(limit int) *sendQueue {
+	if limit < 1 {
+		limit = 1
+	}
+	return &sendQueue{
+		limit: limit,
+		ready: make(chan struct{}, 1),
+	}
+}
+
+// push queues a message, returning false if the queue is closed
+func (q *sendQueue) push(messageType string, data []byte) bool {
+	return q.pushID(0, messageType, data)
+}
+
+// pushID queues a message with its broadcast sequence number
+func (q *sendQueue) pushID(id uint64, messageType string, data []byte) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return false
+	}
+
+	if coalescedMessageTypes[messageType] {
+		for i := range q.messages {
+			if q.messages[i].messageType == messageType {
+				q.messages[i].id = id
+				q.messages[i].data = data
+				q.coalesced++
+				return true
+			}
+		}
+	}
+
+	if len(q.messages) >= q.limit {
+		q.messages = q.messages[1:]
+		q.dropped++
+	}
+	q.messages = append(q.messages, queuedMessage{id: id, messageType: messageType, data: data})
+	q.signal()
+	return true
+}
+
+// take removes and returns every queued message, and whether the queue has been closed
+func (q *sendQueue) take() ([]queuedMessage, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	messages := q.messages
+	q.messages = nil
+	q.sent += uint64(len(messages))
+	return messages, q.closed
+}
+
+// close stops the queue accepting messages; already queued messages can still be taken
+func (q *sendQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if !q.closed {
+		q.closed = true
+		q.signal()
+	}
+}
+
+// signal wakes the writer without blocking; the caller must hold mu
+func (q *sendQueue) signal() {
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+// stats returns the queue length and delivery counters
+func (q *sendQueue) stats() ClientStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return ClientStats{
+		Queued:    len(q.messages),
+		Sent:      q.sent,
+		Dropped:   q.dropped,
+		Coalesced: q.coalesced,
+	}
+}
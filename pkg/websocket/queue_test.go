@@ -0,0 +1,57 @@
+package websocket
+
+import "testing"
+
+func TestSendQueueDropsOldestAndCoalescesSnapshots(t *testing.T) {
+	q := newSendQueue(3)
+
+	q.push("analytics_update", []byte("update-1"))
+	q.push("real_time_event", []byte("event-1"))
+	q.push("analytics_update", []byte("update-2")) // Replaces update-1 in place
+	q.push("real_time_event", []byte("event-2"))
+	q.push("real_time_event", []byte("event-3")) // Full: drops update-2
+
+	messages, closed := q.take()
+	if closed {
+		t.Fatal("Queue should not be closed")
+	}
+
+	var got []string
+	for _, message := range messages {
+		got = append(got, string(message.data))
+	}
+	want := []string{"event-1", "event-2", "event-3"}
+	if len(got) != len(want) {
+		t.Fatalf("Got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Got %v, want %v", got, want)
+		}
+	}
+
+	stats := q.stats()
+	if stats.Sent != 3 || stats.Dropped != 1 || stats.Coalesced != 1 || stats.Queued != 0 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestSendQueueClose(t *testing.T) {
+	q := newSendQueue(2)
+	q.push("alert", []byte("alert-1"))
+	q.close()
+
+	if q.push("alert", []byte("alert-2")) {
+		t.Error("Closed queue should reject messages")
+	}
+
+	select {
+	case <-q.ready:
+	default:
+		t.Fatal("Writer should be signalled")
+	}
+	messages, closed := q.take()
+	if !closed || len(messages) != 1 {
+		t.Errorf("Expected the queued message and closed=true, got %d messages, closed=%v", len(messages), closed)
+	}
+}
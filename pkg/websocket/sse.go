@@ -0,0 +1,191 @@
+package websocket
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/logging"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+const (
+	// Comment lines are sent this often so proxies keep idle streams open
+	sseHeartbeatPeriod = 15 * time.Second
+
+	// Reconnect delay suggested to EventSource clients when the stream drops
+	sseRetry = 3 * time.Second
+)
+
+// transport names the protocol a client is connected with
+func (c *Client) transport() string {
+	if c.conn == nil {
+		return "SSE"
+	}
+	return "WebSocket"
+}
+
+// eventID formats a broadcast sequence number as an SSE event ID. IDs carry the hub
+// instance so IDs from a previous run are not mistaken for current ones.
+func (h *Hub) eventID(sequence uint64) string {
+	return h.instanceID + "-" + strconv.FormatUint(sequence, 10)
+}
+
+// replay queues the broadcasts a reconnecting SSE client missed since its Last-Event-ID.
+// It reports false if the ID is from another hub instance or older than the history,
+// in which case the client needs a full snapshot instead.
+func (h *Hub) replay(client *Client) bool {
+	instanceID, sequenceText, ok := strings.Cut(client.lastEventID, "-")
+	if !ok || instanceID != h.instanceID {
+		return false
+	}
+	sequence, err := strconv.ParseUint(sequenceText, 10, 64)
+	if err != nil || sequence > h.sequence {
+		return false
+	}
+	if len(h.history) > 0 && sequence+1 < h.history[0].id {
+		return false
+	}
+
+	for _, message := range h.history {
+		if message.id > sequence && client.wants(message) {
+			client.queue.pushID(message.id, message.messageType, message.data)
+		}
+	}
+	return true
+}
+
+// ServeSSE streams the hub's messages as Server-Sent Events, for clients behind proxies
+// that break WebSockets. Each message is an event named after its type whose data is the
+// same JSON sent over /ws. Clients reconnecting with a Last-Event-ID header (or
+// ?last_event_id=) receive the messages they missed if they are still in the history.
+// Subscriptions are fixed for the stream and given as comma-separated message_types,
+// event_types and paths query parameters, plus dashboard for a dashboard's updates.
+func (h *Hub) ServeSSE(w http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context())
+	if !h.auth.checkOrigin(r) {
+		logger.Warn("SSE connection rejected", "origin", r.Header.Get("Origin"))
+		http.Error(w, "Origin not allowed", http.StatusForbidden)
+		return
+	}
+
+	access, err := h.auth.authorize(r)
+	if err != nil {
+		logger.Warn("SSE connection rejected", "remote_addr", r.RemoteAddr, "error", err)
+		w.Header().Set("WWW-Authenticate", `Bearer realm="analytics"`)
+		http.Error(w, "Invalid or missing token", http.StatusUnauthorized)
+		return
+	}
+
+	query := r.URL.Query()
+	subscription := models.Subscription{
+		MessageTypes: splitList(query.Get("message_types")),
+		Paths:        splitList(query.Get("paths")),
+		Dashboard:    query.Get("dashboard"),
+	}
+	if _, ok := h.analyticsService.Dashboard(subscription.Dashboard); subscription.Dashboard != "" && !ok {
+		http.Error(w, "Dashboard not found", http.StatusNotFound)
+		return
+	}
+	for _, eventType := range splitList(query.Get("event_types")) {
+		subscription.EventTypes = append(subscription.EventTypes, models.EventType(eventType))
+	}
+	for _, messageType := range subscription.MessageTypes {
+		if !access.allows(messageType) {
+			http.Error(w, "Not authorized for "+messageType, http.StatusForbidden)
+			return
+		}
+	}
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = query.Get("last_event_id")
+	}
+
+	client := &Client{
+		hub:         h,
+		queue:       newSendQueue(h.config.QueueSize),
+		filter:      newSubscriptionFilter(subscription),
+		id:          generateClientID(),
+		connectedAt: time.Now(),
+		access:      access,
+		lastEventID: lastEventID,
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // Disable nginx response buffering
+
+	select {
+	case h.register <- client:
+	case <-h.done:
+		http.Error(w, "Server shutting down", http.StatusServiceUnavailable)
+		return
+	}
+
+	h.pumps.Add(1)
+	defer func() {
+		select {
+		case h.unregister <- client:
+		case <-h.done:
+		}
+		h.pumps.Done()
+	}()
+
+	// The stream outlives the server's write timeout, so each write gets its own deadline
+	controller := http.NewResponseController(w)
+	write := func(format string, args ...interface{}) bool {
+		controller.SetWriteDeadline(time.Now().Add(writeWait))
+		if _, err := fmt.Fprintf(w, format, args...); err != nil {
+			return false
+		}
+		return controller.Flush() == nil
+	}
+
+	if !write("retry: %d\n\n", sseRetry.Milliseconds()) {
+		return
+	}
+
+	ticker := time.NewTicker(sseHeartbeatPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-client.queue.ready:
+			messages, closed := client.queue.take()
+			for _, message := range messages {
+				if !write("id: %s\nevent: %s\ndata: %s\n\n", h.eventID(message.id), message.messageType, message.data) {
+					return
+				}
+			}
+			if closed {
+				if client.reconnectAfter > 0 {
+					write("retry: %d\n\n", client.reconnectAfter.Milliseconds())
+				}
+				return
+			}
+
+		case <-ticker.C:
+			if !write(": ping\n\n") {
+				return
+			}
+
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// splitList splits a comma-separated query parameter, ignoring empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,93 @@
+package websocket
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/analytics"
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+// sseEvent is one parsed Server-Sent Event
+type sseEvent struct {
+	id, name, data string
+}
+
+// readSSEEvent reads the next event that has a name, skipping retry and comment blocks
+func readSSEEvent(t *testing.T, reader *bufio.Reader) sseEvent {
+	t.Helper()
+	var event sseEvent
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Reading stream: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			if event.name != "" {
+				return event
+			}
+			continue
+		}
+		field, value, _ := strings.Cut(line, ": ")
+		switch field {
+		case "id":
+			event.id = value
+		case "event":
+			event.name = value
+		case "data":
+			event.data = value
+		}
+	}
+}
+
+func TestServeSSEResumesFromLastEventID(t *testing.T) {
+	hub := NewHub(analytics.NewService(), analytics.FormatOptions{})
+	go hub.Run()
+	defer hub.Shutdown(context.Background())
+
+	server := httptest.NewServer(http.HandlerFunc(hub.ServeSSE))
+	defer server.Close()
+
+	connect := func(lastEventID string) (*bufio.Reader, func()) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"?message_types=analytics_snapshot,alert", nil)
+		if lastEventID != "" {
+			req.Header.Set("Last-Event-ID", lastEventID)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Connecting: %v", err)
+		}
+		if got := resp.Header.Get("Content-Type"); got != "text/event-stream" {
+			t.Fatalf("Expected text/event-stream, got %q", got)
+		}
+		return bufio.NewReader(resp.Body), func() { resp.Body.Close(); cancel() }
+	}
+
+	stream, closeStream := connect("")
+	snapshot := readSSEEvent(t, stream)
+	if snapshot.name != "analytics_snapshot" || snapshot.id == "" {
+		t.Fatalf("Expected an initial snapshot with an ID, got %+v", snapshot)
+	}
+	closeStream()
+
+	// The alert is broadcast while the client is disconnected
+	hub.BroadcastAlert(models.Alert{ID: "a1", Message: "missed"})
+	time.Sleep(100 * time.Millisecond)
+
+	stream, closeStream = connect(snapshot.id)
+	defer closeStream()
+	replayed := readSSEEvent(t, stream)
+	if replayed.name != "alert" || !strings.Contains(replayed.data, `"missed"`) {
+		t.Fatalf("Expected the missed alert to be replayed instead of a snapshot, got %+v", replayed)
+	}
+	if replayed.id == snapshot.id {
+		t.Errorf("Expected the alert to have a new ID, got %s", replayed.id)
+	}
+}
@@ -0,0 +1,202 @@
+package websocket
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+// outboundMessage is an encoded message plus the attributes clients filter on
+type outboundMessage struct {
+	id          uint64 // Sequence number assigned by Run when broadcast
+	messageType string
+	eventType   models.EventType // Only set for real-time events
+	path        string           // Only set for real-time events and heatmaps
+	dashboard   string           // Only set for dashboard updates
+	audience    audience
+	access      AccessLevel // Only set for messages redacted for read-only clients
+	data        []byte
+}
+
+// audience selects clients by the snapshot encoding they asked for
+type audience int
+
+const (
+	allClients          audience = iota
+	fullSnapshotClients          // Clients receiving full analytics updates
+	deltaClients                 // Clients receiving analytics deltas
+)
+
+// subscriptionFilter is the compiled form of a client's subscription
+type subscriptionFilter struct {
+	messageTypes map[string]bool
+	eventTypes   map[models.EventType]bool
+	paths        []string
+	dashboard    string
+
+	// Subscription the filter was compiled from, reported in client stats
+	source models.Subscription
+}
+
+// newSubscriptionFilter compiles a subscription; a nil filter matches every message
+// except dashboard updates. Subscribing to a dashboard without listing message types
+// selects only that dashboard's updates.
+func newSubscriptionFilter(sub models.Subscription) *subscriptionFilter {
+	if len(sub.MessageTypes) == 0 && len(sub.EventTypes) == 0 && len(sub.Paths) == 0 && sub.Dashboard == "" {
+		return nil
+	}
+
+	filter := &subscriptionFilter{
+		messageTypes: make(map[string]bool),
+		eventTypes:   make(map[models.EventType]bool),
+		paths:        sub.Paths,
+		dashboard:    sub.Dashboard,
+		source:       sub,
+	}
+	for _, messageType := range sub.MessageTypes {
+		filter.messageTypes[messageType] = true
+	}
+	if sub.Dashboard != "" {
+		filter.messageTypes["dashboard_update"] = true
+	}
+	for _, eventType := range sub.EventTypes {
+		filter.eventTypes[eventType] = true
+	}
+	return filter
+}
+
+// subscription returns the subscription the filter was compiled from, or nil for a nil filter
+func (f *subscriptionFilter) subscription() *models.Subscription {
+	if f == nil {
+		return nil
+	}
+	subscription := f.source
+	return &subscription
+}
+
+// matches reports whether the message passes the filter
+func (f *subscriptionFilter) matches(message outboundMessage) bool {
+	// Dashboard updates only go to the dashboard's subscribers
+	if message.messageType == "dashboard_update" {
+		return f != nil && f.dashboard == message.dashboard
+	}
+	if f == nil {
+		return true
+	}
+	// Deltas stand in for updates, so subscribing to updates covers both encodings
+	messageType := message.messageType
+	if messageType == "analytics_delta" {
+		messageType = "analytics_update"
+	}
+	if len(f.messageTypes) > 0 && !f.messageTypes[messageType] {
+		return false
+	}
+
+	// Event type filters only narrow real-time events, and path filters real-time events and heatmaps
+	switch message.messageType {
+	case "real_time_event":
+		if len(f.eventTypes) > 0 && !f.eventTypes[message.eventType] {
+			return false
+		}
+	case "heatmap":
+	default:
+		return true
+	}
+	if len(f.paths) > 0 {
+		for _, prefix := range f.paths {
+			if strings.HasPrefix(message.path, prefix) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// wants reports whether the client is authorized for and subscribed to the message
+func (c *Client) wants(message outboundMessage) bool {
+	if !c.access.allows(message.messageType) {
+		return false
+	}
+	if message.audience == fullSnapshotClients && c.deltas || message.audience == deltaClients && !c.deltas {
+		return false
+	}
+	if message.access != 0 && message.access != c.access {
+		return false
+	}
+
+	c.filterMu.RLock()
+	defer c.filterMu.RUnlock()
+	return c.filter.matches(message)
+}
+
+// handleRequest applies a control message received from the client
+func (c *Client) handleRequest(data []byte) {
+	var request models.ClientRequest
+	if err := json.Unmarshal(data, &request); err != nil {
+		c.reply("error", map[string]string{"error": "invalid request"})
+		return
+	}
+
+	switch request.Action {
+	case "subscribe":
+		for _, messageType := range request.Subscription.MessageTypes {
+			if !c.access.allows(messageType) {
+				c.reply("error", map[string]string{"error": "not authorized for " + messageType})
+				return
+			}
+		}
+		dashboard, ok := c.hub.analyticsService.Dashboard(request.Subscription.Dashboard)
+		if request.Subscription.Dashboard != "" && !ok {
+			c.reply("error", map[string]string{"error": "unknown dashboard: " + request.Subscription.Dashboard})
+			return
+		}
+		c.filterMu.Lock()
+		c.filter = newSubscriptionFilter(request.Subscription)
+		c.filterMu.Unlock()
+		c.reply("subscribed", request.Subscription)
+		if ok {
+			// Dashboard data is sent at once rather than at the next broadcast
+			c.reply("dashboard_update", c.hub.analyticsService.DashboardData(dashboard))
+		}
+	case "unsubscribe":
+		c.filterMu.Lock()
+		c.filter = nil
+		c.filterMu.Unlock()
+		c.reply("unsubscribed", models.Subscription{})
+	case "resync":
+		// Sent by delta clients that missed a delta
+		c.hub.requestSnapshot(c)
+	default:
+		c.reply("error", map[string]string{"error": "unknown action: " + request.Action})
+	}
+}
+
+// dashboard returns the dashboard the client is subscribed to, if any
+func (c *Client) dashboard() string {
+	c.filterMu.RLock()
+	defer c.filterMu.RUnlock()
+	if c.filter == nil {
+		return ""
+	}
+	return c.filter.dashboard
+}
+
+// reply sends a direct response to the client, dropping it if the send buffer is full
+func (c *Client) reply(messageType string, payload interface{}) {
+	data, err := json.Marshal(models.WebSocketMessage{
+		Type:      messageType,
+		Timestamp: time.Now(),
+		Data:      payload,
+	})
+	if err != nil {
+		return
+	}
+
+	select {
+	case c.replies <- data:
+	default:
+	}
+}
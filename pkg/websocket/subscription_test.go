@@ -0,0 +1,52 @@
+package websocket
+
+import (
+	"testing"
+
+	"github.com/Hilina-t/go-kafka-analytics-pipeline/pkg/models"
+)
+
+func TestSubscriptionFilter(t *testing.T) {
+	alertsOnly := newSubscriptionFilter(models.Subscription{MessageTypes: []string{"alert"}})
+	checkoutClicks := newSubscriptionFilter(models.Subscription{
+		EventTypes: []models.EventType{models.Click},
+		Paths:      []string{"/checkout"},
+	})
+	salesDashboard := newSubscriptionFilter(models.Subscription{Dashboard: "sales"})
+
+	alert := outboundMessage{messageType: "alert"}
+	update := outboundMessage{messageType: "analytics_update"}
+	checkoutClick := outboundMessage{messageType: "real_time_event", eventType: models.Click, path: "/checkout/pay"}
+	homeClick := outboundMessage{messageType: "real_time_event", eventType: models.Click, path: "/home"}
+	checkoutView := outboundMessage{messageType: "real_time_event", eventType: models.PageView, path: "/checkout"}
+	salesUpdate := outboundMessage{messageType: "dashboard_update", dashboard: "sales"}
+	opsUpdate := outboundMessage{messageType: "dashboard_update", dashboard: "ops"}
+
+	tests := []struct {
+		name    string
+		filter  *subscriptionFilter
+		message outboundMessage
+		want    bool
+	}{
+		{"NoFilterMatchesAll", nil, homeClick, true},
+		{"AlertsOnlyAlert", alertsOnly, alert, true},
+		{"AlertsOnlyUpdate", alertsOnly, update, false},
+		{"AlertsOnlyEvent", alertsOnly, checkoutClick, false},
+		{"CheckoutClicksMatch", checkoutClicks, checkoutClick, true},
+		{"CheckoutClicksWrongPath", checkoutClicks, homeClick, false},
+		{"CheckoutClicksWrongType", checkoutClicks, checkoutView, false},
+		{"CheckoutClicksPassUpdates", checkoutClicks, update, true},
+		{"NoFilterSkipsDashboards", nil, salesUpdate, false},
+		{"DashboardOwnUpdate", salesDashboard, salesUpdate, true},
+		{"DashboardOtherUpdate", salesDashboard, opsUpdate, false},
+		{"DashboardSkipsSnapshots", salesDashboard, update, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.matches(tt.message); got != tt.want {
+				t.Errorf("matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	configMu      sync.Mutex
+	configFileSet = make(map[string]bool) // Variables set from the config file
+	configFileErr error
+)
+
+// The config file named by CONFIG_FILE is loaded before any configuration is read
+func init() {
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		_, configFileErr = LoadConfigFile(path)
+	}
+}
+
+// ConfigFileError returns the error loading CONFIG_FILE at startup, if any
+func ConfigFileError() error {
+	return configFileErr
+}
+
+// LoadConfigFile sets the variables of a file of KEY=VALUE lines in the environment and
+// returns the names of those that changed. Variables set in the environment by other
+// means take precedence and are left alone; variables removed from the file since the
+// last load are unset. Blank lines and lines starting with # are skipped, and values
+// may be double-quoted.
+func LoadConfigFile(path string) ([]string, error) {
+	values, err := readConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	configMu.Lock()
+	defer configMu.Unlock()
+
+	var changed []string
+	for key, value := range values {
+		current, set := os.LookupEnv(key)
+		if set && !configFileSet[key] {
+			continue
+		}
+		if !set || current != value {
+			os.Setenv(key, value)
+			changed = append(changed, key)
+		}
+		configFileSet[key] = true
+	}
+	for key := range configFileSet {
+		if _, ok := values[key]; !ok {
+			os.Unsetenv(key)
+			delete(configFileSet, key)
+			changed = append(changed, key)
+		}
+	}
+	sort.Strings(changed)
+	return changed, nil
+}
+
+// readConfigFile parses a file of KEY=VALUE lines
+func readConfigFile(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open config file: %w", err)
+	}
+	defer file.Close()
+
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(strings.TrimPrefix(text, "export "), "=")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("%s:%d: expected KEY=VALUE", path, line)
+		}
+		if strings.HasPrefix(value, `"`) {
+			unquoted, err := strconv.Unquote(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: invalid quoted value", path, line)
+			}
+			value = unquoted
+		}
+		values[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	return values, nil
+}
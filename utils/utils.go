@@ -1,6 +1,10 @@
 package utils
 
-import "os"
+import (
+	"os"
+	"strconv"
+	"strings"
+)
 
 func GetEnv(key, defaultValue string) string {
 	value := os.Getenv(key)
@@ -9,3 +13,32 @@ func GetEnv(key, defaultValue string) string {
 	}
 	return value
 }
+
+// GetEnvInt returns the integer value of an environment variable, or the default if unset or invalid
+func GetEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+// GetEnvList returns a comma-separated environment variable as a trimmed list, skipping empty entries
+func GetEnvList(key, defaultValue string) []string {
+	var result []string
+	for _, item := range strings.Split(GetEnv(key, defaultValue), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// GetEnvBool returns the boolean value of an environment variable, or the default if unset or invalid
+func GetEnvBool(key string, defaultValue bool) bool {
+	value, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}